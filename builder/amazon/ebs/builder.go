@@ -35,8 +35,11 @@ type Config struct {
 	awscommon.AMIConfig    `mapstructure:",squash"`
 	awscommon.RunConfig    `mapstructure:",squash"`
 	// If true, Packer will not create the AMI. Useful for setting to `true`
-	// during a build test stage. Default `false`.
+	// during a build test stage. Default `false`. `packer build
+	// -skip-create-artifact` sets it too.
 	AMISkipCreateImage bool `mapstructure:"skip_create_ami" required:"false"`
+	// Set by `packer build -skip-create-artifact`, implies `skip_create_ami`.
+	PackerSkipCreateArtifact bool `mapstructure:"packer_skip_create_artifact" undocumented:"true"`
 	// Add one or more block device mappings to the AMI. These will be attached
 	// when booting a new instance from your AMI. To add a block device during
 	// the Packer build see `launch_block_device_mappings` below. Your options
@@ -114,6 +117,9 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	if b.config.PackerConfig.PackerForce {
 		b.config.AMIForceDeregister = true
 	}
+	if b.config.PackerSkipCreateArtifact {
+		b.config.AMISkipCreateImage = true
+	}
 
 	// Accumulate any errors
 	var errs *packersdk.MultiError
@@ -78,6 +78,23 @@ func TestBuilderPrepare_AMIName(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_SkipCreateArtifact(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["packer_skip_create_artifact"] = true
+	_, warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !b.config.AMISkipCreateImage {
+		t.Fatal("packer_skip_create_artifact should imply skip_create_ami")
+	}
+}
+
 func TestBuilderPrepare_InvalidKey(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -0,0 +1,106 @@
+// Package events lets builders report what they do to packer, for it to
+// output events, like the steps they start, to the UIs outputting them, like
+// the json UI of packer build -json.
+//
+// It only relies on the methods of the builder plugin interface, for builders
+// running as plugins to support it: builders run the HookStepStarted hook
+// when they start a step, and packer outputs the step event. Multistep
+// builders report all their steps by running them through ReportSteps.
+package events
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// HookStepStarted is the hook builders run when they start a step, with the
+// name of the step as data, for packer to output a step event. Builders not
+// running it output no step events.
+const HookStepStarted = "packer_step_started"
+
+// StepStarted reports that the builder starts step through hook. Packer
+// versions that do not output step events ignore it.
+func StepStarted(ctx context.Context, hook packersdk.Hook, ui packersdk.Ui, step string) error {
+	if hook == nil {
+		return nil
+	}
+	// The data of hooks goes through the plugin RPC, a string always can.
+	return hook.Run(ctx, HookStepStarted, ui, new(packersdk.MockCommunicator), step)
+}
+
+// ReportedStep is a step of a multistep builder reporting when it starts,
+// with the hook and the ui of the state bag.
+type ReportedStep struct {
+	// Name is the name of the step in its events.
+	Name string
+	Step multistep.Step
+}
+
+var _ multistep.Step = new(ReportedStep)
+
+func (s *ReportedStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	hook, _ := state.Get("hook").(packersdk.Hook)
+	ui, _ := state.Get("ui").(packersdk.Ui)
+	if err := StepStarted(ctx, hook, ui, s.Name); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	return s.Step.Run(ctx, state)
+}
+
+func (s *ReportedStep) Cleanup(state multistep.StateBag) {
+	s.Step.Cleanup(state)
+}
+
+// NamedStep is a step naming itself in its events, like the steps of the
+// resume package.
+type NamedStep interface {
+	multistep.Step
+	StepName() string
+}
+
+// ReportSteps returns steps, each of them reporting when it starts. The name
+// of a step is its StepName when it is a NamedStep, or its type in snake case
+// without its step prefix otherwise: a *stepCreateDisk is create_disk.
+func ReportSteps(steps []multistep.Step) []multistep.Step {
+	reported := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		reported[i] = &ReportedStep{Name: StepName(step), Step: step}
+	}
+	return reported
+}
+
+// StepName returns the name of step in its events.
+func StepName(step multistep.Step) string {
+	if named, ok := step.(NamedStep); ok {
+		return named.StepName()
+	}
+	t := reflect.TypeOf(step)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := t.Name()
+	if trimmed := strings.TrimPrefix(strings.TrimPrefix(name, "step"), "Step"); trimmed != "" {
+		name = trimmed
+	}
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// words start at an upper case letter, acronyms like VM are
+			// one word
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
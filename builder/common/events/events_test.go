@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type stepCreateVM struct{}
+
+func (stepCreateVM) Run(context.Context, multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (stepCreateVM) Cleanup(multistep.StateBag) {}
+
+type namedStep struct{ stepCreateVM }
+
+func (namedStep) StepName() string { return "named" }
+
+func TestStepName(t *testing.T) {
+	tc := []struct {
+		step multistep.Step
+		want string
+	}{
+		{new(stepCreateVM), "create_vm"},
+		{stepCreateVM{}, "create_vm"},
+		{new(commonsteps.StepDownload), "download"},
+		{new(commonsteps.StepCleanupTempKeys), "cleanup_temp_keys"},
+		{new(commonsteps.StepHTTPServer), "http_server"},
+		{namedStep{}, "named"},
+	}
+	for _, tt := range tc {
+		if got := StepName(tt.step); got != tt.want {
+			t.Errorf("StepName(%T) = %q, expected %q", tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestReportSteps(t *testing.T) {
+	hook := &packersdk.MockHook{}
+	state := new(multistep.BasicStateBag)
+	state.Put("hook", hook)
+	state.Put("ui", packersdk.TestUi(t))
+
+	steps := ReportSteps([]multistep.Step{new(stepCreateVM)})
+	if action := steps[0].Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %v", action)
+	}
+	if hook.RunName != HookStepStarted || hook.RunData != "create_vm" {
+		t.Fatalf("the step must be reported, got hook %q with %v", hook.RunName, hook.RunData)
+	}
+}
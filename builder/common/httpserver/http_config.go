@@ -0,0 +1,39 @@
+//go:generate struct-markdown
+
+package httpserver
+
+import (
+	"errors"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// HTTPConfig adds `http_content` to the HTTP server settings of the SDK, so
+// that a builder can serve files rendered from the template, like preseed or
+// kickstart files, without writing them to an `http_directory` first:
+//
+//	http_content = {
+//	  "/preseed.cfg" = "d-i passwd/username string ${var.user}"
+//	}
+type HTTPConfig struct {
+	commonsteps.HTTPConfig `mapstructure:",squash"`
+	// Key/Values to serve using an HTTP server. `http_content` works like and
+	// conflicts with `http_directory`. The keys are the paths of the files
+	// and the values their contents. Paths are requested by the virtual
+	// machine like `http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg`, and
+	// the paths that are not keys of the map are not found. The values are
+	// rendered with the variables of the template; in HCL2, the `file`
+	// function reads a value from a file.
+	HTTPContent map[string]string `mapstructure:"http_content"`
+}
+
+// Prepare validates the settings of the server and sets their defaults.
+func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
+	errs := c.HTTPConfig.Prepare(ctx)
+	if c.HTTPDir != "" && len(c.HTTPContent) > 0 {
+		errs = append(errs,
+			errors.New("either http_directory or http_content can be specified"))
+	}
+	return errs
+}
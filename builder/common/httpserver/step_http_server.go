@@ -0,0 +1,112 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepHTTPServer creates and runs the HTTP server serving the files of
+// HTTPDir, or the contents of HTTPContent. Nothing is started when both are
+// empty.
+//
+// Uses:
+//
+//	ui     packersdk.Ui
+//
+// Produces:
+//
+//	http_port int - The port the HTTP server started on, or 0.
+type StepHTTPServer struct {
+	HTTPDir     string
+	HTTPContent map[string]string
+	HTTPPortMin int
+	HTTPPortMax int
+	HTTPAddress string
+
+	l *net.Listener
+}
+
+// HTTPServerFromHTTPConfig returns the step starting the server configured by
+// cfg.
+func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
+	return &StepHTTPServer{
+		HTTPDir:     cfg.HTTPDir,
+		HTTPContent: cfg.HTTPContent,
+		HTTPPortMin: cfg.HTTPPortMin,
+		HTTPPortMax: cfg.HTTPPortMax,
+		HTTPAddress: cfg.HTTPAddress,
+	}
+}
+
+func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.HTTPDir == "" && len(s.HTTPContent) == 0 {
+		state.Put("http_port", 0)
+		return multistep.ActionContinue
+	}
+
+	// Find an available TCP port for our HTTP server
+	var err error
+	s.l, err = net.ListenRangeConfig{
+		Min:     s.HTTPPortMin,
+		Max:     s.HTTPPortMax,
+		Addr:    s.HTTPAddress,
+		Network: "tcp",
+	}.Listen(ctx)
+
+	if err != nil {
+		err := fmt.Errorf("Error finding port: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+
+	// Start the HTTP server and run it in the background
+	server := &http.Server{Handler: s.handler()}
+	go server.Serve(s.l)
+
+	// Save the address into the state so it can be accessed in the future
+	state.Put("http_port", s.l.Port)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepHTTPServer) handler() http.Handler {
+	if s.HTTPDir != "" {
+		return http.FileServer(http.Dir(s.HTTPDir))
+	}
+	return contentHandler(s.HTTPContent)
+}
+
+// contentHandler serves the values of content at the paths of their keys.
+type contentHandler map[string]string
+
+func (c contentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + r.URL.Path)
+	content, found := c[p]
+	if !found {
+		// keys may be written without their leading slash
+		content, found = c[p[1:]]
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write([]byte(content))
+}
+
+func (s *StepHTTPServer) Cleanup(multistep.StateBag) {
+	if s.l != nil {
+		// Close the listener so that the HTTP server stops
+		s.l.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStepHTTPServer_content(t *testing.T) {
+	s := &StepHTTPServer{HTTPContent: map[string]string{
+		"/preseed.cfg":  "preseed",
+		"ks/fedora.cfg": "kickstart",
+	}}
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	tc := []struct {
+		path    string
+		status  int
+		content string
+	}{
+		{"/preseed.cfg", http.StatusOK, "preseed"},
+		{"/ks/fedora.cfg", http.StatusOK, "kickstart"},
+		{"/ks/../preseed.cfg", http.StatusOK, "preseed"},
+		{"/missing.cfg", http.StatusNotFound, ""},
+	}
+	for _, tt := range tc {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %s", tt.path, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status %d, expected %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if tt.status == http.StatusOK && string(body) != tt.content {
+			t.Errorf("GET %s: got %q, expected %q", tt.path, body, tt.content)
+		}
+	}
+}
+
+func TestHTTPConfigPrepare(t *testing.T) {
+	c := &HTTPConfig{HTTPContent: map[string]string{"/preseed.cfg": ""}}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	c.HTTPDir = "http"
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("expected http_directory and http_content to conflict, got %v", errs)
+	}
+}
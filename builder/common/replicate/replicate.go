@@ -0,0 +1,63 @@
+// Package replicate lets builders copy their artifact to other targets, like
+// the regions or the accounts of a cloud, for the replicate block of a build.
+//
+// It only relies on the methods of the builder plugin interface, for builders
+// running as plugins to support it:
+//
+//   - a builder able to copy its artifacts declares the TargetConfigKey
+//     setting in its ConfigSpec;
+//   - for every copy, packer starts the builder again and prepares it with the
+//     configuration of the build, TargetConfigKey set to the target and
+//     ArtifactConfigKey to the artifact to copy, encoded by EncodeArtifact;
+//   - the builder then copies the artifact in Run, instead of building it,
+//     and returns the copy.
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// TargetConfigKey is the setting holding the target to copy the artifact
+	// to. Builders able to copy their artifacts declare it in their
+	// ConfigSpec, undocumented.
+	TargetConfigKey = "packer_replicate_target"
+
+	// ArtifactConfigKey is the setting holding the artifact to copy, encoded
+	// by EncodeArtifact.
+	ArtifactConfigKey = "packer_replicate_artifact"
+)
+
+// Artifact is the artifact of a builder to copy.
+type Artifact struct {
+	BuilderId string   `json:"builder_id"`
+	Id        string   `json:"id"`
+	Files     []string `json:"files,omitempty"`
+}
+
+// EncodeArtifact encodes artifact as the value of the ArtifactConfigKey
+// setting.
+func EncodeArtifact(artifact packersdk.Artifact) (string, error) {
+	js, err := json.Marshal(Artifact{
+		BuilderId: artifact.BuilderId(),
+		Id:        artifact.Id(),
+		Files:     artifact.Files(),
+	})
+	return string(js), err
+}
+
+// DecodeArtifact decodes the value of the ArtifactConfigKey setting, checking
+// that the artifact was created by the builder builderId.
+func DecodeArtifact(value, builderId string) (*Artifact, error) {
+	var artifact Artifact
+	if err := json.Unmarshal([]byte(value), &artifact); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", ArtifactConfigKey, err)
+	}
+	if artifact.BuilderId != builderId {
+		return nil, fmt.Errorf("cannot copy an artifact of builder %q", artifact.BuilderId)
+	}
+	return &artifact, nil
+}
@@ -0,0 +1,234 @@
+// Package resume lets builders resume the builds interrupted while `packer
+// build -resume` saves their state: the steps that completed in the
+// interrupted run, like downloading an ISO or creating a VM, are skipped and
+// what they created is reused.
+//
+// It only relies on the methods of the builder plugin interface, for builders
+// running as plugins to support it:
+//
+//   - a builder able to resume builds declares the StateConfigKey setting in
+//     its ConfigSpec;
+//   - packer then sets it, in the configuration given to Prepare, to the path
+//     of the file the state of the build is saved in;
+//   - the builder reads the steps that completed with Steps, and reports the
+//     steps that complete by running the HookStepCompleted hook, for packer
+//     to save them;
+//   - a builder starting a new machine when resumed, on which the
+//     provisioners have to run again, runs StepNewMachine before.
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// StateConfigKey is the setting holding the path of the file the state
+	// of the build is saved in. Builders able to resume builds declare it
+	// in their ConfigSpec, undocumented, and packer only sets it with
+	// -resume.
+	StateConfigKey = "packer_resume_state"
+
+	// HookStepCompleted is the hook builders run when a step completes, with
+	// a CompletedStep encoded as JSON as data, for packer to save it.
+	HookStepCompleted = "packer_step_completed"
+
+	// HookNewMachine is the hook builders run before they start a new
+	// machine, for packer to run the provisioners completed by the
+	// interrupted build again.
+	HookNewMachine = "packer_new_machine"
+)
+
+// CompletedStep is a step of a builder that completed, with what the next
+// steps need from it.
+type CompletedStep struct {
+	Step  string            `json:"step"`
+	State map[string]string `json:"state,omitempty"`
+}
+
+// StepState records the steps of a builder that completed, so that an
+// interrupted build can skip them when it is resumed.
+type StepState interface {
+	// CompletedStep returns the state saved by step when it completed, and
+	// whether it did.
+	CompletedStep(step string) (map[string]string, bool)
+	// CompleteStep records that step completed, with its state.
+	CompleteStep(step string, state map[string]string) error
+}
+
+// savedState is the part of the file saved by packer read by builders.
+type savedState struct {
+	Steps map[string]map[string]string `json:"steps"`
+}
+
+// Steps returns the state of the steps of the build saved at path, the value
+// of the StateConfigKey setting, reporting the steps that complete through
+// hook. It returns nil when path is empty, as the build is then not resumable.
+func Steps(ctx context.Context, path string, ui packersdk.Ui, hook packersdk.Hook) (StepState, error) {
+	if path == "" {
+		return nil, nil
+	}
+	s := &hookStepState{ctx: ctx, ui: ui, hook: hook}
+	js, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(js, &s.saved); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return s, nil
+}
+
+// Resumed tells whether the build whose state is saved at path, the value of
+// the StateConfigKey setting, resumes an interrupted run that completed steps.
+// Builders refusing to overwrite what a previous run left, like an output
+// directory, let a resumed build reuse it.
+func Resumed(path string) bool {
+	if path == "" {
+		return false
+	}
+	js, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var saved savedState
+	if err := json.Unmarshal(js, &saved); err != nil {
+		return false
+	}
+	return len(saved.Steps) > 0
+}
+
+// hookStepState is the StepState of a builder, reporting the steps that
+// complete to packer through a hook.
+type hookStepState struct {
+	ctx  context.Context
+	ui   packersdk.Ui
+	hook packersdk.Hook
+
+	l     sync.Mutex
+	saved savedState
+}
+
+func (s *hookStepState) CompletedStep(step string) (map[string]string, bool) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	state, ok := s.saved.Steps[step]
+	return state, ok
+}
+
+func (s *hookStepState) CompleteStep(step string, state map[string]string) error {
+	js, err := json.Marshal(CompletedStep{Step: step, State: state})
+	if err != nil {
+		return err
+	}
+	// The data of hooks goes through the plugin RPC, a string always can.
+	if err := s.hook.Run(s.ctx, HookStepCompleted, s.ui, new(packersdk.MockCommunicator), string(js)); err != nil {
+		return err
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.saved.Steps == nil {
+		s.saved.Steps = map[string]map[string]string{}
+	}
+	s.saved.Steps[step] = state
+	return nil
+}
+
+// CheckpointedStep is a step of a builder that is skipped when it completed
+// in the interrupted run of a build being resumed.
+type CheckpointedStep struct {
+	// Name identifies the step in the state of the build.
+	Name string
+	Step multistep.Step
+	// Steps is the state returned by Steps, nil to always run the step.
+	Steps StepState
+	// Save returns what Restore needs to put back in the state bag what the
+	// step created, when the step completed.
+	Save func(state multistep.StateBag) map[string]string
+	// Restore puts back in the state bag what the step created in the
+	// interrupted run.
+	Restore func(saved map[string]string, state multistep.StateBag)
+
+	completed bool
+}
+
+var _ multistep.Step = new(CheckpointedStep)
+
+// StepName returns the name of the step, also naming it in its events.
+func (s *CheckpointedStep) StepName() string {
+	return s.Name
+}
+
+func (s *CheckpointedStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Steps == nil {
+		return s.Step.Run(ctx, state)
+	}
+	if saved, ok := s.Steps.CompletedStep(s.Name); ok {
+		if ui, ok := state.Get("ui").(packersdk.Ui); ok {
+			ui.Say(fmt.Sprintf("Resuming after step %s, completed by the interrupted build", s.Name))
+		}
+		if s.Restore != nil {
+			s.Restore(saved, state)
+		}
+		s.completed = true
+		return multistep.ActionContinue
+	}
+
+	action := s.Step.Run(ctx, state)
+	if action != multistep.ActionContinue {
+		return action
+	}
+	var saved map[string]string
+	if s.Save != nil {
+		saved = s.Save(state)
+	}
+	if err := s.Steps.CompleteStep(s.Name, saved); err != nil {
+		log.Printf("[WARN] Failed to save the state of step %s, it won't be resumed: %s", s.Name, err)
+		return action
+	}
+	s.completed = true
+	return action
+}
+
+// Cleanup cleans up after the step, unless the build was interrupted after
+// the step completed: what it created is kept for the build to be resumed.
+func (s *CheckpointedStep) Cleanup(state multistep.StateBag) {
+	if _, cancelled := state.GetOk(multistep.StateCancelled); cancelled && s.completed {
+		log.Printf("Keeping what step %s created, for the build to be resumed", s.Name)
+		return
+	}
+	s.Step.Cleanup(state)
+}
+
+// StepNewMachine is the step of a builder before the one starting the machine
+// the provisioners run on, when the machine of an interrupted build cannot be
+// reused: the provisioners completed by the interrupted build run again.
+type StepNewMachine struct{}
+
+var _ multistep.Step = new(StepNewMachine)
+
+func (s *StepNewMachine) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	hook, ok := state.Get("hook").(packersdk.Hook)
+	if !ok {
+		return multistep.ActionContinue
+	}
+	ui, _ := state.Get("ui").(packersdk.Ui)
+	// The data of hooks goes through the plugin RPC, a string always can.
+	if err := hook.Run(ctx, HookNewMachine, ui, new(packersdk.MockCommunicator), ""); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepNewMachine) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,171 @@
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stateFileHook saves the completed steps to the state file, like packer.
+type stateFileHook struct {
+	path  string
+	saved savedState
+}
+
+func (h *stateFileHook) Run(_ context.Context, name string, _ packersdk.Ui, _ packersdk.Communicator, data interface{}) error {
+	if name != HookStepCompleted {
+		return nil
+	}
+	var step CompletedStep
+	if err := json.Unmarshal([]byte(data.(string)), &step); err != nil {
+		return err
+	}
+	if h.saved.Steps == nil {
+		h.saved.Steps = map[string]map[string]string{}
+	}
+	h.saved.Steps[step.Step] = step.State
+	js, err := json.Marshal(h.saved)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.path, js, 0644)
+}
+
+type stateStep struct {
+	runs, cleanups int
+	action         multistep.StepAction
+}
+
+func (s *stateStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	s.runs++
+	state.Put("vm", "created")
+	return s.action
+}
+
+func (s *stateStep) Cleanup(multistep.StateBag) {
+	s.cleanups++
+}
+
+func TestSteps_notResumable(t *testing.T) {
+	steps, err := Steps(context.Background(), "", packersdk.TestUi(t), nil)
+	if err != nil || steps != nil {
+		t.Fatalf("a build without state file must not be resumable: %v, %v", steps, err)
+	}
+}
+
+func TestCheckpointedStep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "build.json")
+	hook := &stateFileHook{path: path}
+	ui := packersdk.TestUi(t)
+
+	newStep := func(inner *stateStep) *CheckpointedStep {
+		steps, err := Steps(context.Background(), path, ui, hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &CheckpointedStep{
+			Name:  "create_vm",
+			Step:  inner,
+			Steps: steps,
+			Save: func(state multistep.StateBag) map[string]string {
+				return map[string]string{"vm": state.Get("vm").(string)}
+			},
+			Restore: func(saved map[string]string, state multistep.StateBag) {
+				state.Put("vm", "restored "+saved["vm"])
+			},
+		}
+	}
+
+	// The build gets interrupted after the step: what it created is kept.
+	inner := &stateStep{action: multistep.ActionContinue}
+	step := newStep(inner)
+	state := new(multistep.BasicStateBag)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %v", action)
+	}
+	state.Put(multistep.StateCancelled, true)
+	step.Cleanup(state)
+	if inner.runs != 1 || inner.cleanups != 0 {
+		t.Fatalf("bad runs %d and cleanups %d", inner.runs, inner.cleanups)
+	}
+
+	// The resumed build reads the saved state, skips the step and restores
+	// its state.
+	inner = &stateStep{action: multistep.ActionContinue}
+	step = newStep(inner)
+	state = new(multistep.BasicStateBag)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %v", action)
+	}
+	if inner.runs != 0 {
+		t.Fatal("the completed step must be skipped")
+	}
+	if vm := state.Get("vm"); vm != "restored created" {
+		t.Fatalf("bad restored state: %v", vm)
+	}
+	step.Cleanup(state)
+	if inner.cleanups != 1 {
+		t.Fatal("a build that is not interrupted must clean up")
+	}
+
+	// A step that fails is not recorded.
+	inner = &stateStep{action: multistep.ActionHalt}
+	step = newStep(inner)
+	step.Name = "export"
+	step.Run(context.Background(), new(multistep.BasicStateBag))
+	if _, ok := step.Steps.CompletedStep("export"); ok {
+		t.Fatal("a failed step must not complete")
+	}
+	if _, ok := hook.saved.Steps["export"]; ok {
+		t.Fatal("a failed step must not be reported")
+	}
+}
+
+func TestResumed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	if Resumed("") || Resumed(path) {
+		t.Fatal("a build without saved state is not resumed")
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"steps":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if Resumed(path) {
+		t.Fatal("a build without completed steps is not resumed")
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"steps":{"create_vm":{}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !Resumed(path) {
+		t.Fatal("a build with completed steps is resumed")
+	}
+}
+
+func TestStepNewMachine(t *testing.T) {
+	hook := &packersdk.MockHook{}
+	state := new(multistep.BasicStateBag)
+	state.Put("hook", hook)
+	state.Put("ui", packersdk.TestUi(t))
+	if action := new(StepNewMachine).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %v", action)
+	}
+	if !hook.RunCalled || hook.RunName != HookNewMachine {
+		t.Fatalf("the %s hook must run, got %q", HookNewMachine, hook.RunName)
+	}
+}
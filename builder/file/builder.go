@@ -16,10 +16,17 @@ import (
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/events"
+	"github.com/hashicorp/packer/builder/common/replicate"
+	"github.com/hashicorp/packer/builder/common/resume"
 )
 
 const BuilderId = "packer.file"
 
+// stepWriteTarget is the step writing the target, skipped when a build
+// interrupted after it is resumed.
+const stepWriteTarget = "write_target"
+
 type Builder struct {
 	config Config
 	runner multistep.Runner
@@ -38,6 +45,44 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 
 // Run is where the actual build should take place. It takes a Build and a Ui.
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	if b.config.PackerReplicateTarget != "" {
+		artifact, err := replicate.DecodeArtifact(b.config.PackerReplicateArtifact, BuilderId)
+		if err != nil {
+			return nil, err
+		}
+		return b.replicate(ui, artifact, b.config.PackerReplicateTarget)
+	}
+
+	steps, err := resume.Steps(ctx, b.config.PackerResumeState, ui, hook)
+	if err != nil {
+		return nil, err
+	}
+	if steps != nil {
+		if _, ok := steps.CompletedStep(stepWriteTarget); ok {
+			if _, err := os.Stat(b.config.Target); err == nil {
+				ui.Say(fmt.Sprintf("Resuming after step %s, completed by the interrupted build", stepWriteTarget))
+				return b.provision(ctx, ui, hook, &FileArtifact{filename: b.config.Target})
+			}
+		}
+	}
+
+	if err := events.StepStarted(ctx, hook, ui, stepWriteTarget); err != nil {
+		return nil, err
+	}
+	artifact, err := b.writeTarget(ui)
+	if err != nil {
+		return nil, err
+	}
+	if steps != nil {
+		if err := steps.CompleteStep(stepWriteTarget, nil); err != nil {
+			return nil, err
+		}
+	}
+	return b.provision(ctx, ui, hook, artifact)
+}
+
+// writeTarget writes the content or copies the source to the target.
+func (b *Builder) writeTarget(ui packersdk.Ui) (*FileArtifact, error) {
 	artifact := new(FileArtifact)
 
 	// Create all directories leading to target
@@ -78,7 +123,10 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		}
 		artifact.filename = b.config.Target
 	}
+	return artifact, nil
+}
 
+func (b *Builder) provision(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook, artifact *FileArtifact) (packersdk.Artifact, error) {
 	if hook != nil {
 		if err := hook.Run(ctx, packersdk.HookProvision, ui, new(packersdk.MockCommunicator), nil); err != nil {
 			return nil, err
@@ -87,3 +135,33 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 	return artifact, nil
 }
+
+// replicate copies the file of artifact to target, a directory, for the
+// replicate block of a build.
+func (b *Builder) replicate(ui packersdk.Ui, artifact *replicate.Artifact, target string) (packersdk.Artifact, error) {
+	files := artifact.Files
+	if len(files) != 1 {
+		return nil, fmt.Errorf("expected an artifact with one file, got %d", len(files))
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(target, filepath.Base(files[0]))
+
+	source, err := os.Open(files[0])
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	f, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ui.Say(fmt.Sprintf("Copying %s to %s", source.Name(), dest))
+	if _, err := io.Copy(f, source); err != nil {
+		return nil, err
+	}
+	return &FileArtifact{filename: dest}, nil
+}
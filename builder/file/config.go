@@ -20,6 +20,14 @@ type Config struct {
 	Source  string `mapstructure:"source"`
 	Target  string `mapstructure:"target"`
 	Content string `mapstructure:"content"`
+
+	// PackerResumeState is the file the state of the build is saved in, set
+	// by packer build -resume.
+	PackerResumeState string `mapstructure:"packer_resume_state" undocumented:"true"`
+	// PackerReplicateTarget is the directory to copy the artifact of
+	// PackerReplicateArtifact to, for the replicate block of a build.
+	PackerReplicateTarget   string `mapstructure:"packer_replicate_target" undocumented:"true"`
+	PackerReplicateArtifact string `mapstructure:"packer_replicate_artifact" undocumented:"true"`
 }
 
 func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
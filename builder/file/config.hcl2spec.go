@@ -10,17 +10,20 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion   *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug         *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce         *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError       *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars      map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	Source              *string           `mapstructure:"source" cty:"source" hcl:"source"`
-	Target              *string           `mapstructure:"target" cty:"target" hcl:"target"`
-	Content             *string           `mapstructure:"content" cty:"content" hcl:"content"`
+	PackerBuildName         *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType       *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion       *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug             *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce             *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError           *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars          map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars     []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Source                  *string           `mapstructure:"source" cty:"source" hcl:"source"`
+	Target                  *string           `mapstructure:"target" cty:"target" hcl:"target"`
+	Content                 *string           `mapstructure:"content" cty:"content" hcl:"content"`
+	PackerResumeState       *string           `mapstructure:"packer_resume_state" undocumented:"true" cty:"packer_resume_state" hcl:"packer_resume_state"`
+	PackerReplicateTarget   *string           `mapstructure:"packer_replicate_target" undocumented:"true" cty:"packer_replicate_target" hcl:"packer_replicate_target"`
+	PackerReplicateArtifact *string           `mapstructure:"packer_replicate_artifact" undocumented:"true" cty:"packer_replicate_artifact" hcl:"packer_replicate_artifact"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -46,6 +49,9 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"source":                     &hcldec.AttrSpec{Name: "source", Type: cty.String, Required: false},
 		"target":                     &hcldec.AttrSpec{Name: "target", Type: cty.String, Required: false},
 		"content":                    &hcldec.AttrSpec{Name: "content", Type: cty.String, Required: false},
+		"packer_resume_state":        &hcldec.AttrSpec{Name: "packer_resume_state", Type: cty.String, Required: false},
+		"packer_replicate_target":    &hcldec.AttrSpec{Name: "packer_replicate_target", Type: cty.String, Required: false},
+		"packer_replicate_artifact":  &hcldec.AttrSpec{Name: "packer_replicate_artifact", Type: cty.String, Required: false},
 	}
 	return s
 }
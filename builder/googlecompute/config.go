@@ -77,7 +77,10 @@ type Config struct {
 	// Whether to use an IAP proxy.
 	IAPConfig `mapstructure:",squash"`
 	// Skip creating the image. Useful for setting to `true` during a build test stage. Defaults to `false`.
+	// `packer build -skip-create-artifact` sets it too.
 	SkipCreateImage bool `mapstructure:"skip_create_image" required:"false"`
+	// Set by `packer build -skip-create-artifact`, implies `skip_create_image`.
+	PackerSkipCreateArtifact bool `mapstructure:"packer_skip_create_artifact" undocumented:"true"`
 	// The unique name of the resulting image. Defaults to
 	// `packer-{{timestamp}}`.
 	ImageName string `mapstructure:"image_name" required:"false"`
@@ -321,6 +324,10 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	var errs *packersdk.MultiError
 
+	if c.PackerSkipCreateArtifact {
+		c.SkipCreateImage = true
+	}
+
 	// Set defaults.
 	if c.Network == "" && c.Subnetwork == "" {
 		c.Network = "default"
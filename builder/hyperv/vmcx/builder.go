@@ -20,6 +20,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	hypervcommon "github.com/hashicorp/packer/builder/hyperv/common"
 	powershell "github.com/hashicorp/packer/builder/hyperv/common/powershell"
 )
@@ -45,7 +46,7 @@ type Builder struct {
 
 type Config struct {
 	common.PackerConfig            `mapstructure:",squash"`
-	commonsteps.HTTPConfig         `mapstructure:",squash"`
+	httpserver.HTTPConfig          `mapstructure:",squash"`
 	commonsteps.ISOConfig          `mapstructure:",squash"`
 	bootcommand.BootConfig         `mapstructure:",squash"`
 	hypervcommon.OutputConfig      `mapstructure:",squash"`
@@ -251,12 +252,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Directories: b.config.FloppyConfig.FloppyDirectories,
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
-		&commonsteps.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
-			HTTPAddress: b.config.HTTPAddress,
-		},
+		httpserver.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
 		},
@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	parallelscommon "github.com/hashicorp/packer/builder/parallels/common"
 )
 
@@ -30,7 +31,7 @@ type Builder struct {
 
 type Config struct {
 	common.PackerConfig                 `mapstructure:",squash"`
-	commonsteps.HTTPConfig              `mapstructure:",squash"`
+	httpserver.HTTPConfig               `mapstructure:",squash"`
 	commonsteps.ISOConfig               `mapstructure:",squash"`
 	commonsteps.FloppyConfig            `mapstructure:",squash"`
 	bootcommand.BootConfig              `mapstructure:",squash"`
@@ -209,12 +210,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Directories: b.config.FloppyConfig.FloppyDirectories,
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
-		&commonsteps.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
-			HTTPAddress: b.config.HTTPAddress,
-		},
+		httpserver.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		new(stepCreateVM),
 		new(stepCreateDisk),
 		new(stepSetBootOrder),
@@ -8,16 +8,31 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/events"
+	"github.com/hashicorp/packer/builder/common/httpserver"
+	"github.com/hashicorp/packer/builder/common/resume"
 )
 
 const BuilderId = "transcend.qemu"
 
+// The steps skipped when a build interrupted after them is resumed: what they
+// download or create in the output directory is reused. The steps after them
+// run the VM, which does not survive the interruption.
+const (
+	stepDownloadISO    = "download_iso"
+	stepPrepareOutput  = "prepare_output_dir"
+	stepCreateDiskName = "create_disk"
+	stepCopyDiskName   = "copy_disk"
+	stepResizeDiskName = "resize_disk"
+)
+
 type Builder struct {
 	config Config
 	runner multistep.Runner
@@ -41,15 +56,30 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, fmt.Errorf("Failed creating Qemu driver: %s", err)
 	}
 
+	completed, err := resume.Steps(ctx, b.config.PackerResumeState, ui, hook)
+	if err != nil {
+		return nil, err
+	}
+
 	steps := []multistep.Step{}
 	if !b.config.ISOSkipCache {
-		steps = append(steps, &commonsteps.StepDownload{
-			Checksum:    b.config.ISOChecksum,
-			Description: "ISO",
-			Extension:   b.config.TargetExtension,
-			ResultKey:   "iso_path",
-			TargetPath:  b.config.TargetPath,
-			Url:         b.config.ISOUrls,
+		steps = append(steps, &resume.CheckpointedStep{
+			Name: stepDownloadISO,
+			Step: &commonsteps.StepDownload{
+				Checksum:    b.config.ISOChecksum,
+				Description: "ISO",
+				Extension:   b.config.TargetExtension,
+				ResultKey:   "iso_path",
+				TargetPath:  b.config.TargetPath,
+				Url:         b.config.ISOUrls,
+			},
+			Steps: completed,
+			Save: func(state multistep.StateBag) map[string]string {
+				return map[string]string{"iso_path": state.Get("iso_path").(string)}
+			},
+			Restore: func(saved map[string]string, state multistep.StateBag) {
+				state.Put("iso_path", saved["iso_path"])
+			},
 		})
 	} else {
 		steps = append(steps, &stepSetISO{
@@ -58,7 +88,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		})
 	}
 
-	steps = append(steps, new(stepPrepareOutputDir),
+	steps = append(steps,
+		&resume.CheckpointedStep{
+			Name:  stepPrepareOutput,
+			Step:  new(stepPrepareOutputDir),
+			Steps: completed,
+		},
 		&commonsteps.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
@@ -68,45 +103,61 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Files: b.config.CDConfig.CDFiles,
 			Label: b.config.CDConfig.CDLabel,
 		},
-		&stepCreateDisk{
-			AdditionalDiskSize: b.config.AdditionalDiskSize,
-			DiskImage:          b.config.DiskImage,
-			DiskSize:           b.config.DiskSize,
-			Format:             b.config.Format,
-			OutputDir:          b.config.OutputDir,
-			UseBackingFile:     b.config.UseBackingFile,
-			VMName:             b.config.VMName,
-			QemuImgArgs:        b.config.QemuImgArgs,
+		&resume.CheckpointedStep{
+			Name: stepCreateDiskName,
+			Step: &stepCreateDisk{
+				AdditionalDiskSize: b.config.AdditionalDiskSize,
+				DiskImage:          b.config.DiskImage,
+				DiskSize:           b.config.DiskSize,
+				Format:             b.config.Format,
+				OutputDir:          b.config.OutputDir,
+				UseBackingFile:     b.config.UseBackingFile,
+				VMName:             b.config.VMName,
+				QemuImgArgs:        b.config.QemuImgArgs,
+			},
+			Steps: completed,
+			Save: func(state multistep.StateBag) map[string]string {
+				paths := state.Get("qemu_disk_paths").([]string)
+				return map[string]string{"qemu_disk_paths": strings.Join(paths, "\n")}
+			},
+			Restore: func(saved map[string]string, state multistep.StateBag) {
+				state.Put("qemu_disk_paths", strings.Split(saved["qemu_disk_paths"], "\n"))
+			},
 		},
-		&stepCopyDisk{
-			DiskImage:      b.config.DiskImage,
-			Format:         b.config.Format,
-			OutputDir:      b.config.OutputDir,
-			UseBackingFile: b.config.UseBackingFile,
-			VMName:         b.config.VMName,
+		&resume.CheckpointedStep{
+			Name: stepCopyDiskName,
+			Step: &stepCopyDisk{
+				DiskImage:      b.config.DiskImage,
+				Format:         b.config.Format,
+				OutputDir:      b.config.OutputDir,
+				UseBackingFile: b.config.UseBackingFile,
+				VMName:         b.config.VMName,
+			},
+			Steps: completed,
 		},
-		&stepResizeDisk{
-			DiskCompression: b.config.DiskCompression,
-			DiskImage:       b.config.DiskImage,
-			Format:          b.config.Format,
-			OutputDir:       b.config.OutputDir,
-			SkipResizeDisk:  b.config.SkipResizeDisk,
-			VMName:          b.config.VMName,
-			DiskSize:        b.config.DiskSize,
-			QemuImgArgs:     b.config.QemuImgArgs,
+		&resume.CheckpointedStep{
+			Name: stepResizeDiskName,
+			Step: &stepResizeDisk{
+				DiskCompression: b.config.DiskCompression,
+				DiskImage:       b.config.DiskImage,
+				Format:          b.config.Format,
+				OutputDir:       b.config.OutputDir,
+				SkipResizeDisk:  b.config.SkipResizeDisk,
+				VMName:          b.config.VMName,
+				DiskSize:        b.config.DiskSize,
+				QemuImgArgs:     b.config.QemuImgArgs,
+			},
+			Steps: completed,
 		},
 		new(stepHTTPIPDiscover),
-		&commonsteps.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
-			HTTPAddress: b.config.HTTPAddress,
-		},
+		httpserver.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&stepPortForward{
 			CommunicatorType: b.config.CommConfig.Comm.Type,
 			NetBridge:        b.config.NetBridge,
 		},
 		new(stepConfigureVNC),
+		// the VM of an interrupted build is gone
+		new(resume.StepNewMachine),
 		&stepRun{
 			DiskImage: b.config.DiskImage,
 		},
@@ -154,7 +205,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("ui", ui)
 
 	// Run
-	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
+	b.runner = commonsteps.NewRunnerWithPauseFn(events.ReportSteps(steps), b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
 
 	// If there was an error, return that
@@ -20,6 +20,8 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
+	"github.com/hashicorp/packer/builder/common/resume"
 )
 
 var accels = map[string]struct{}{
@@ -66,7 +68,7 @@ type QemuImgArgs struct {
 
 type Config struct {
 	common.PackerConfig            `mapstructure:",squash"`
-	commonsteps.HTTPConfig         `mapstructure:",squash"`
+	httpserver.HTTPConfig          `mapstructure:",squash"`
 	commonsteps.ISOConfig          `mapstructure:",squash"`
 	bootcommand.VNCConfig          `mapstructure:",squash"`
 	shutdowncommand.ShutdownConfig `mapstructure:",squash"`
@@ -392,6 +394,10 @@ type Config struct {
 	// TODO(mitchellh): deprecate
 	RunOnce bool `mapstructure:"run_once"`
 
+	// PackerResumeState is the file the state of the build is saved in, set
+	// by packer build -resume.
+	PackerResumeState string `mapstructure:"packer_resume_state" undocumented:"true"`
+
 	ctx interpolate.Context
 }
 
@@ -586,7 +592,8 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			errs, errors.New("unrecognized disk detect zeroes setting"))
 	}
 
-	if !c.PackerForce {
+	// a resumed build reuses the output directory of the interrupted one
+	if !c.PackerForce && !resume.Resumed(c.PackerResumeState) {
 		if _, err := os.Stat(c.OutputDir); err == nil {
 			errs = packersdk.MultiErrorAppend(
 				errs,
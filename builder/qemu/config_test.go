@@ -396,6 +396,18 @@ func TestBuilderPrepare_OutputDir(t *testing.T) {
 		t.Fatal("should have error")
 	}
 
+	// Test with the output of an interrupted build being resumed
+	stateFile := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(stateFile, []byte(`{"steps":{"create_disk":{}}}`), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config["packer_resume_state"] = stateFile
+	c = Config{}
+	if _, err := c.Prepare(config); err != nil {
+		t.Fatalf("a resumed build should reuse the output directory: %s", err)
+	}
+	delete(config, "packer_resume_state")
+
 	// Test with a good one
 	config["output_directory"] = "i-hope-i-dont-exist"
 	c = Config{}
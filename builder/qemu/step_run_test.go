@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,8 +41,10 @@ func Test_UserOverrides(t *testing.T) {
 	testcases := []testCase{
 		{
 			&Config{
-				HTTPConfig: commonsteps.HTTPConfig{
-					HTTPDir: "http/directory",
+				HTTPConfig: httpserver.HTTPConfig{
+					HTTPConfig: commonsteps.HTTPConfig{
+						HTTPDir: "http/directory",
+					},
 				},
 				OutputDir: "output/directory",
 				VMName:    "myvm",
@@ -17,6 +17,7 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 )
 
@@ -29,7 +30,7 @@ type Builder struct {
 
 type Config struct {
 	common.PackerConfig             `mapstructure:",squash"`
-	commonsteps.HTTPConfig          `mapstructure:",squash"`
+	httpserver.HTTPConfig           `mapstructure:",squash"`
 	commonsteps.ISOConfig           `mapstructure:",squash"`
 	commonsteps.FloppyConfig        `mapstructure:",squash"`
 	commonsteps.CDConfig            `mapstructure:",squash"`
@@ -266,12 +267,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Label: b.config.CDConfig.CDLabel,
 		},
 		new(vboxcommon.StepHTTPIPDiscover),
-		&commonsteps.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
-			HTTPAddress: b.config.HTTPAddress,
-		},
+		httpserver.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&vboxcommon.StepSshKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
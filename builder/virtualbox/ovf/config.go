@@ -12,13 +12,14 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 )
 
 // Config is the configuration structure for the builder.
 type Config struct {
 	common.PackerConfig             `mapstructure:",squash"`
-	commonsteps.HTTPConfig          `mapstructure:",squash"`
+	httpserver.HTTPConfig           `mapstructure:",squash"`
 	commonsteps.FloppyConfig        `mapstructure:",squash"`
 	commonsteps.CDConfig            `mapstructure:",squash"`
 	bootcommand.BootConfig          `mapstructure:",squash"`
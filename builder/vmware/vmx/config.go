@@ -14,13 +14,14 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	vmwcommon "github.com/hashicorp/packer/builder/vmware/common"
 )
 
 // Config is the configuration structure for the builder.
 type Config struct {
 	common.PackerConfig            `mapstructure:",squash"`
-	commonsteps.HTTPConfig         `mapstructure:",squash"`
+	httpserver.HTTPConfig          `mapstructure:",squash"`
 	commonsteps.FloppyConfig       `mapstructure:",squash"`
 	bootcommand.VNCConfig          `mapstructure:",squash"`
 	commonsteps.CDConfig           `mapstructure:",squash"`
@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	"github.com/hashicorp/packer/builder/vsphere/common"
 	"github.com/hashicorp/packer/builder/vsphere/driver"
 )
@@ -89,12 +90,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 				HTTPIP:  b.config.BootConfig.HTTPIP,
 				Network: b.config.WaitIpConfig.GetIPNet(),
 			},
-			&commonsteps.StepHTTPServer{
-				HTTPDir:     b.config.HTTPDir,
-				HTTPPortMin: b.config.HTTPPortMin,
-				HTTPPortMax: b.config.HTTPPortMax,
-				HTTPAddress: b.config.HTTPAddress,
-			},
+			httpserver.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 			&common.StepSshKeyPair{
 				Debug:        b.config.PackerDebug,
 				DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
@@ -10,12 +10,13 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/builder/common/httpserver"
 	"github.com/hashicorp/packer/builder/vsphere/common"
 )
 
 type Config struct {
 	packerCommon.PackerConfig `mapstructure:",squash"`
-	commonsteps.HTTPConfig    `mapstructure:",squash"`
+	httpserver.HTTPConfig     `mapstructure:",squash"`
 	commonsteps.CDConfig      `mapstructure:",squash"`
 
 	common.ConnectConfig      `mapstructure:",squash"`
@@ -3,9 +3,14 @@ package command
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,11 +19,12 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"github.com/hashicorp/packer-plugin-sdk/template"
 	"github.com/hashicorp/packer/hcl2template"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/hashicorp/packer/version"
-	"golang.org/x/sync/semaphore"
 
 	"github.com/hako/durafmt"
 	"github.com/posener/complete"
@@ -29,7 +35,7 @@ type BuildCommand struct {
 }
 
 func (c *BuildCommand) Run(args []string) int {
-	ctx, cleanup := handleTermInterrupt(c.Ui)
+	ctx, forced, cleanup := handleTermInterrupt(c.Ui)
 	defer cleanup()
 
 	cfg, ret := c.ParseArgs(args)
@@ -37,7 +43,9 @@ func (c *BuildCommand) Run(args []string) int {
 		return ret
 	}
 
-	return c.RunContext(ctx, cfg)
+	return runUntilForced(forced, func() int {
+		return c.RunContext(ctx, cfg)
+	})
 }
 
 func (c *BuildCommand) ParseArgs(args []string) (*BuildArgs, int) {
@@ -46,31 +54,104 @@ func (c *BuildCommand) ParseArgs(args []string) (*BuildArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
+	}
+
+	if cfg.FailFast && cfg.KeepGoing {
+		c.Ui.Error("-fail-fast and -keep-going are mutually exclusive.")
+		return &cfg, ExitUsage
+	}
+	if _, ok := c.Ui.(*packer.MachineReadableUi); ok && cfg.JSON {
+		c.Ui.Error("-json and -machine-readable are mutually exclusive.")
+		return &cfg, ExitUsage
+	}
+
+	// Fail before starting builds that would wait for an answer that cannot
+	// come.
+	if !c.canAsk() || cfg.JSON {
+		if cfg.OnError == "ask" {
+			c.Ui.Error("-on-error=ask needs a terminal to prompt on. Set -on-error to " +
+				"cleanup, abort or run-cleanup-provisioner instead.")
+			return &cfg, ExitUsage
+		}
+		if cfg.Debug && !cfg.DebugNoPause {
+			c.Ui.Error("-debug pauses between steps and needs a terminal to prompt on. " +
+				"Set -debug-no-pause to not pause.")
+			return &cfg, ExitUsage
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "retry" {
+			cfg.RetrySet = true
+		}
+	})
+	if cfg.Retry < 0 {
+		c.Ui.Error("-retry must be a zero or positive number of retries.")
+		return &cfg, ExitUsage
 	}
 
-	if cfg.ParallelBuilds < 1 {
+	if cfg.Heartbeat < 0 {
+		c.Ui.Error("-heartbeat must be a positive duration, like 1m.")
+		return &cfg, ExitUsage
+	}
+
+	if cfg.ParallelBuilds < 1 && !cfg.ParallelBuildsAuto {
 		cfg.ParallelBuilds = math.MaxInt64
 	}
 
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 	cfg.Path = args[0]
+	if cfg.RootDir != "" && cfg.Path != "-" {
+		c.Ui.Error("-root-dir only applies to templates read from stdin, with '-'.")
+		return &cfg, ExitUsage
+	}
+	if cfg.Resume && cfg.Path == "-" {
+		c.Ui.Error("-resume needs a template file: builds of a template read from stdin cannot be resumed.")
+		return &cfg, ExitUsage
+	}
 	return &cfg, 0
 }
 
 func (m *Meta) GetConfigFromHCL(cla *MetaArgs) (*hcl2template.PackerConfig, int) {
+	cfg, files, diags, err := m.parseHCL(cla)
+	if err != nil {
+		m.Ui.Error(err.Error())
+		return nil, 1
+	}
+	diags, _ = cla.promoteWarnings(diags)
+	return cfg, writeDiags(m.Ui, files, diags)
+}
+
+// parseHCL parses the HCL2 config of cla. It returns the parsed files with the
+// diagnostics, to show their snippets of config.
+func (m *Meta) parseHCL(cla *MetaArgs) (*hcl2template.PackerConfig, map[string]*hcl.File, hcl.Diagnostics, error) {
 	parser := &hcl2template.Parser{
 		CorePackerVersion:       version.SemVer,
 		CorePackerVersionString: version.FormattedVersion(),
 		Parser:                  hclparse.NewParser(),
 		PluginConfig:            m.CoreConfig.Components.PluginConfig,
 	}
-	cfg, diags := parser.Parse(cla.Path, cla.VarFiles, cla.Vars)
-	return cfg, writeDiags(m.Ui, parser.Files(), diags)
+	var cfg *hcl2template.PackerConfig
+	var diags hcl.Diagnostics
+	if cla.Path == "-" {
+		src, err := cla.readStdin()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rootDir := cla.RootDir
+		if rootDir == "" {
+			rootDir = "."
+		}
+		cfg, diags = parser.ParseSource(src, cla.stdinFilename(), rootDir, cla.VarFiles, cla.Vars)
+	} else {
+		cfg, diags = parser.Parse(cla.Path, cla.VarFiles, cla.Vars)
+	}
+	return cfg, parser.Files(), diags, nil
 }
 
 func writeDiags(ui packersdk.Ui, files map[string]*hcl.File, diags hcl.Diagnostics) int {
@@ -84,6 +165,7 @@ func writeDiags(ui packersdk.Ui, files map[string]*hcl.File, diags hcl.Diagnosti
 	if b.Len() != 0 {
 		if diags.HasErrors() {
 			ui.Error(b.String())
+			messages.MachineCodes(ui, messages.DiagnosticCodes(diags))
 			return 1
 		}
 		ui.Say(b.String())
@@ -91,6 +173,29 @@ func writeDiags(ui packersdk.Ui, files map[string]*hcl.File, diags hcl.Diagnosti
 	return 0
 }
 
+// promoteWarnings turns the warnings of diags into errors when WarnAsError is
+// set, and tells whether it did.
+func (ma *MetaArgs) promoteWarnings(diags hcl.Diagnostics) (hcl.Diagnostics, bool) {
+	if !ma.WarnAsError {
+		return diags, false
+	}
+	return messages.WarningsAsErrors(diags)
+}
+
+// pluginConflicts returns the warnings about the plugins found at several
+// paths, or errors when FailOnPluginConflict is set.
+func (m *Meta) pluginConflicts(cla *MetaArgs) hcl.Diagnostics {
+	plugins := m.CoreConfig.Components.PluginConfig
+	if plugins == nil {
+		return nil
+	}
+	diags := plugins.ConflictDiagnostics()
+	if cla.FailOnPluginConflict {
+		diags, _ = messages.WarningsAsErrors(diags)
+	}
+	return diags
+}
+
 func (m *Meta) GetConfig(cla *MetaArgs) (packer.Handler, int) {
 	cfgType, err := cla.GetConfigType()
 	if err != nil {
@@ -114,19 +219,10 @@ func (m *Meta) GetConfig(cla *MetaArgs) (packer.Handler, int) {
 }
 
 func (m *Meta) GetConfigFromJSON(cla *MetaArgs) (packer.Handler, int) {
-	// Parse the template
-	var tpl *template.Template
-	var err error
-	if cla.Path == "" {
-		// here cla validation passed so this means we want a default builder
-		// and we probably are in the console command
-		tpl, err = template.Parse(TiniestBuilder)
-	} else {
-		tpl, err = template.ParseFile(cla.Path)
-	}
-
+	tpl, err := parseTemplate(cla)
 	if err != nil {
-		m.Ui.Error(fmt.Sprintf("Failed to parse template: %s", err))
+		m.Ui.Error(fmt.Sprintf("Failed to parse template: %s", messages.WithCode(messages.CodeTemplateParse, err)))
+		messages.MachineCodes(m.Ui, []messages.Code{messages.CodeTemplateParse})
 		return nil, 1
 	}
 
@@ -135,55 +231,153 @@ func (m *Meta) GetConfigFromJSON(cla *MetaArgs) (packer.Handler, int) {
 	ret := 0
 	if err != nil {
 		m.Ui.Error(err.Error())
+		messages.MachineCodes(m.Ui, messages.Codes(err))
 		ret = 1
 	}
 	return &CoreWrapper{core}, ret
 }
 
+// parseTemplate parses the JSON template of cla.
+func parseTemplate(cla *MetaArgs) (*template.Template, error) {
+	var tpl *template.Template
+	var err error
+	switch cla.Path {
+	case "":
+		// here cla validation passed so this means we want a default builder
+		// and we probably are in the console command
+		tpl, err = template.Parse(TiniestBuilder)
+	case "-":
+		var src []byte
+		src, err = cla.readStdin()
+		if err == nil {
+			tpl, err = template.Parse(bytes.NewReader(src))
+		}
+		if err == nil && cla.RootDir != "" {
+			// template_dir is the folder of the template
+			tpl.Path = filepath.Join(cla.RootDir, "-")
+		}
+	default:
+		tpl, err = template.ParseFile(cla.Path)
+	}
+	return tpl, err
+}
+
 func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int {
+	if cla.JSON && !packer.IsStructuredUi(c.Ui) {
+		c.Ui = &packer.JSONUi{Writer: &uiLineWriter{Ui: c.Ui}}
+	}
+	if root, ok := workspaceRoot(cla.Path); ok {
+		return c.runWorkspace(buildCtx, root, cla)
+	}
+	ret, _ := c.runBuilds(buildCtx, cla, newBuildScheduler(cla))
+	return ret
+}
+
+// runBuilds runs the builds of the template at cla.Path, scheduling them with
+// scheduler. It returns the exit code of the command and the summary of every
+// build that was started or could have been.
+func (c *BuildCommand) runBuilds(buildCtx context.Context, cla *BuildArgs, scheduler *buildScheduler) (int, []buildSummary) {
 	packerStarter, ret := c.GetConfig(&cla.MetaArgs)
 	if ret != 0 {
-		return ret
+		return ExitValidation, nil
+	}
+	if c.askUnsetVariables(packerStarter, &cla.MetaArgs) {
+		// load the config again, with the values that were asked for.
+		packerStarter, ret = c.GetConfig(&cla.MetaArgs)
+		if ret != 0 {
+			return ExitValidation, nil
+		}
 	}
-	diags := packerStarter.Initialize(packer.InitializeOptions{})
+	diags := packerStarter.Initialize(packer.InitializeOptions{Context: buildCtx})
+	diags, _ = cla.promoteWarnings(append(diags, c.pluginConflicts(&cla.MetaArgs)...))
 	ret = writeDiags(c.Ui, nil, diags)
+	if buildCtx.Err() != nil {
+		// variables could have been prompted for, or data sources read, while
+		// packer was interrupted.
+		messages.Error(c.Ui, messages.BuildsCancelledBeforeStart)
+		return ExitCancelled, nil
+	}
 	if ret != 0 {
-		return ret
+		return ExitValidation, nil
 	}
 
-	builds, diags := packerStarter.GetBuilds(packer.GetBuildsOptions{
-		Only:    cla.Only,
-		Except:  cla.Except,
-		Debug:   cla.Debug,
-		Force:   cla.Force,
-		OnError: cla.OnError,
-	})
+	getBuildsOptions := packer.GetBuildsOptions{
+		Only:               cla.Only,
+		Except:             cla.Except,
+		Debug:              cla.Debug,
+		Force:              cla.Force,
+		OnError:            cla.OnError,
+		SkipCreateArtifact: cla.SkipCreateArtifact,
+	}
+	if cla.Resume {
+		getBuildsOptions.ResumeStateDir = packer.StateDir
+	}
+	builds, diags := packerStarter.GetBuilds(getBuildsOptions)
+
+	// post-processors running on the artifacts of all the builds
+	var combinedPostProcessors [][]packer.CoreBuildPostProcessor
+	if getter, ok := packerStarter.(packer.CombinedPostProcessorsGetter); ok {
+		var moreDiags hcl.Diagnostics
+		combinedPostProcessors, moreDiags = getter.GetCombinedPostProcessors(getBuildsOptions)
+		diags = append(diags, moreDiags...)
+	}
 
 	// here, something could have gone wrong but we still want to run valid
-	// builds.
+	// builds, unless warnings are errors.
+	diags, promoted := cla.promoteWarnings(diags)
 	ret = writeDiags(c.Ui, nil, diags)
+	if promoted {
+		return ExitValidation, nil
+	}
+
+	if cla.DryRun {
+		// Builds are prepared at this point, so their configuration was
+		// validated by the plugins.
+		if ret != 0 {
+			return ExitValidation, nil
+		}
+		c.dryRun(packerStarter, builds)
+		return ExitSuccess, nil
+	}
 
 	if cla.Debug {
-		c.Ui.Say("Debug mode enabled. Builds will not be parallelized.")
+		messages.Say(c.Ui, messages.DebugNotParallel)
 	}
 
 	// Compile all the UIs for the builds
-	colors := [5]packer.UiColor{
-		packer.UiColorGreen,
-		packer.UiColorCyan,
-		packer.UiColorMagenta,
-		packer.UiColorYellow,
-		packer.UiColorBlue,
+	theme := c.colorTheme()
+	names := make([]string, len(builds))
+	for i, b := range builds {
+		names[i] = b.Name()
+	}
+	colors := theme.BuildColors(names)
+	// The names of the builds and of their post-processors are padded to
+	// line up their output
+	width := 0
+	if len(builds) > 1 && !packer.IsStructuredUi(c.Ui) {
+		for _, b := range builds {
+			targets := []string{b.Name()}
+			if cb, ok := b.(*packer.CoreBuild); ok {
+				targets = cb.UiTargets()
+			}
+			for _, target := range targets {
+				if len(target) > width {
+					width = len(target)
+				}
+			}
+		}
 	}
 	buildUis := make(map[packersdk.Build]packersdk.Ui)
 	for i := range builds {
 		ui := c.Ui
-		if cla.Color {
+		if cla.Color && theme.Mode != packer.ColorModeNo {
 			// Only set up UI colors if -machine-readable isn't set.
-			if _, ok := c.Ui.(*packer.MachineReadableUi); !ok {
+			if !packer.IsStructuredUi(c.Ui) {
 				ui = &packer.ColoredUi{
-					Color: colors[i%len(colors)],
-					Ui:    ui,
+					Color:      colors[i],
+					ErrorColor: theme.ErrorColor(),
+					Force:      theme.Mode == packer.ColorModeForce,
+					Ui:         ui,
 				}
 				ui.Say(fmt.Sprintf("%s: output will be in this color.", builds[i].Name()))
 				if i+1 == len(builds) {
@@ -193,11 +387,32 @@ func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int
 			}
 		}
 		// Now add timestamps if requested
-		if cla.TimestampUi {
+		// JSON events are timestamped already
+		if cla.TimestampUi && !cla.JSON {
 			ui = &packer.TimestampedUi{
 				Ui: ui,
 			}
 		}
+		if cla.DebugNoPause {
+			ui = &packer.AnsweringUi{
+				Ui:      ui,
+				Answers: map[string]string{packer.DebugPausePrompt: ""},
+			}
+		}
+		if width > 0 {
+			ui = &packer.AlignedUi{
+				Ui:     ui,
+				Target: builds[i].Name(),
+				Width:  width,
+			}
+		}
+		if cla.Heartbeat > 0 {
+			ui = &packer.HeartbeatUi{
+				Ui:       ui,
+				Target:   builds[i].Name(),
+				Interval: cla.Heartbeat,
+			}
+		}
 
 		buildUis[builds[i]] = ui
 	}
@@ -205,6 +420,36 @@ func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int
 	log.Printf("Build debug mode: %v", cla.Debug)
 	log.Printf("Force build: %v", cla.Force)
 	log.Printf("On error: %v", cla.OnError)
+	log.Printf("Skip create artifact: %v", cla.SkipCreateArtifact)
+
+	if cla.SkipCreateArtifact {
+		c.Ui.Say("Builders that support it will run their provisioners without creating " +
+			"their artifact. The other builders create theirs as usual.")
+	}
+
+	// Fingerprint builds before they run, so that what gets recorded is
+	// what they were started with. Builds that did not create their
+	// artifact are not worth recording.
+	var fingerprints map[string]buildFingerprint
+	if cla.HistoryFile != "" && !cla.SkipCreateArtifact {
+		var err error
+		fingerprints, err = fingerprintBuilds(&cla.MetaArgs, packerStarter, builds, cla.HistoryFile)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to fingerprint builds, they won't be recorded: %s", err))
+		}
+		if len(fingerprints) > 0 {
+			c.forgetDowngradedBuilds(cla.HistoryFile, fingerprints)
+		}
+	}
+
+	var checkpoints map[string]*packer.BuildCheckpoint
+	if cla.Resume {
+		checkpoints = c.openCheckpoints(cla, packerStarter, builds)
+	}
+
+	if limiter, ok := packerStarter.(packer.BuildTypeLimiter); ok {
+		scheduler.SetTypeLimits(limiter.ParallelBuildsByType())
+	}
 
 	// Get the start of the build command
 	buildCommandStart := time.Now()
@@ -220,50 +465,107 @@ func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int
 		sync.RWMutex
 		m map[string]error
 	}{m: make(map[string]error)}
-	limitParallel := semaphore.NewWeighted(cla.ParallelBuilds)
+	var results = struct {
+		sync.RWMutex
+		m map[string]buildResult
+	}{m: make(map[string]buildResult)}
+	for _, b := range builds {
+		results.m[b.Name()] = buildResult{Status: buildNotStarted}
+	}
+
+	// With -fail-fast, the first failing build cancels the other ones
+	// through runCtx. buildCtx is only cancelled when packer is interrupted.
+	runCtx, cancelRun := context.WithCancel(buildCtx)
+	defer cancelRun()
+
+	// notStarted records why the build name never started.
+	notStarted := func(name string) {
+		reason := errBuildNotStartedFailFast
+		if buildCtx.Err() != nil {
+			reason = errBuildNotStartedInterrupted
+		}
+		log.Printf("Build '%s' not started: %s", name, reason)
+		results.Lock()
+		results.m[name] = buildResult{Status: buildNotStarted, Err: reason}
+		results.Unlock()
+	}
+
 	for i := range builds {
-		if err := buildCtx.Err(); err != nil {
-			log.Println("Interrupted, not going to start any more builds.")
+		if runCtx.Err() != nil {
+			for _, b := range builds[i:] {
+				notStarted(b.Name())
+			}
 			break
 		}
 
 		b := builds[i]
 		name := b.Name()
 		ui := buildUis[b]
-		if err := limitParallel.Acquire(buildCtx, 1); err != nil {
-			ui.Error(fmt.Sprintf("Build '%s' failed to acquire semaphore: %s", name, err))
-			errors.Lock()
-			errors.m[name] = err
-			errors.Unlock()
-			break
-		}
+		queued := scheduler.Queue(b)
 		// Increment the waitgroup so we wait for this item to finish properly
 		wg.Add(1)
 
 		// Run the build in a goroutine
 		go func() {
-			// Get the start of the build
-			buildStart := time.Now()
-
 			defer wg.Done()
 
-			defer limitParallel.Release(1)
+			release, err := scheduler.Wait(runCtx, queued, ui)
+			if err != nil {
+				// Packer was interrupted, or another build failed with
+				// -fail-fast set, before this one could start.
+				notStarted(name)
+				return
+			}
+			defer release()
+
+			// Get the start of the build
+			buildStart := time.Now()
 
 			log.Printf("Starting build run: %s", name)
-			runArtifacts, err := b.Run(buildCtx, ui)
+			if hb, ok := ui.(*packer.HeartbeatUi); ok {
+				hb.Start()
+			}
+			runArtifacts, err := runBuildWithRetries(runCtx, cla, b, ui, checkpoints[name])
+			if hb, ok := ui.(*packer.HeartbeatUi); ok {
+				hb.Stop()
+			}
 
 			// Get the duration of the build and parse it
 			buildEnd := time.Now()
 			buildDuration := buildEnd.Sub(buildStart)
 			fmtBuildDuration := durafmt.Parse(buildDuration).LimitFirstN(2)
 
-			if err != nil {
-				ui.Error(fmt.Sprintf("Build '%s' errored after %s: %s", name, fmtBuildDuration, err))
+			switch {
+			case err != nil && runCtx.Err() != nil:
+				// Either packer was interrupted or another build failed
+				// with -fail-fast set.
+				messages.Error(ui, messages.BuildCancelled, name, fmtBuildDuration, err)
+				results.Lock()
+				results.m[name] = buildResult{Status: buildCancelled, Duration: buildDuration, Err: err}
+				results.Unlock()
+			case err != nil:
+				messages.Error(ui, messages.BuildErrored, name, fmtBuildDuration, err)
 				errors.Lock()
 				errors.m[name] = err
 				errors.Unlock()
-			} else {
-				ui.Say(fmt.Sprintf("Build '%s' finished after %s.", name, fmtBuildDuration))
+				results.Lock()
+				results.m[name] = buildResult{Status: buildFailed, Duration: buildDuration, Err: err}
+				results.Unlock()
+				if cla.FailFast {
+					log.Printf("Build '%s' failed and -fail-fast is set, cancelling the other builds.", name)
+					cancelRun()
+				}
+			default:
+				if cp := checkpoints[name]; cp != nil {
+					if err := cp.Remove(); err != nil {
+						log.Printf("[WARN] Failed to remove the state of %s: %s", name, err)
+					}
+				}
+				results.Lock()
+				results.m[name] = buildResult{Status: buildSucceeded, Duration: buildDuration}
+				results.Unlock()
+				messages.Say(ui, messages.BuildFinished, name, fmtBuildDuration)
+				emitArtifactEvents(ui, name, runArtifacts)
 				if nil != runArtifacts {
 					artifacts.Lock()
 					artifacts.m[name] = runArtifacts
@@ -293,18 +595,49 @@ func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int
 	buildCommandEnd := time.Now()
 	buildCommandDuration := buildCommandEnd.Sub(buildCommandStart)
 	fmtBuildCommandDuration := durafmt.Parse(buildCommandDuration).LimitFirstN(2)
-	c.Ui.Say(fmt.Sprintf("\n==> Wait completed after %s", fmtBuildCommandDuration))
+	messages.Say(c.Ui, messages.BuildsWaitCompleted, fmtBuildCommandDuration)
+
+	var combinedArtifacts []packersdk.Artifact
+	var combinedErr error
+	if len(combinedPostProcessors) > 0 && len(artifacts.m) > 0 && buildCtx.Err() == nil {
+		combined := &packer.CombinedArtifact{}
+		for _, b := range builds {
+			for _, artifact := range artifacts.m[b.Name()] {
+				if artifact != nil {
+					combined.Builds = append(combined.Builds, b.Name())
+					combined.Artifacts = append(combined.Artifacts, artifact)
+				}
+			}
+		}
+		combinedArtifacts, combinedErr = packer.RunCombinedPostProcessors(buildCtx, c.Ui, combinedPostProcessors, combined)
+		emitArtifactEvents(c.Ui, combinedPostProcessorsName, combinedArtifacts)
+	}
 
 	if err := buildCtx.Err(); err != nil {
-		c.Ui.Say("Cleanly cancelled builds after being interrupted.")
-		return 1
+		messages.Say(c.Ui, messages.BuildsCancelled)
+		return ExitCancelled, nil
 	}
 
-	if len(errors.m) > 0 {
-		c.Ui.Machine("error-count", strconv.FormatInt(int64(len(errors.m)), 10))
+	if len(fingerprints) > 0 {
+		c.recordBuilds(cla.HistoryFile, fingerprints, results.m)
+	}
 
-		c.Ui.Error("\n==> Some builds didn't complete successfully and had errors:")
-		for name, err := range errors.m {
+	if len(errors.m) > 0 || combinedErr != nil {
+		errorCount := len(errors.m)
+		if combinedErr != nil {
+			errorCount++
+		}
+		c.Ui.Machine("error-count", strconv.FormatInt(int64(errorCount), 10))
+
+		messages.Error(c.Ui, messages.BuildsErrored)
+		// Errors and artifacts are reported in the order of the builds, so
+		// that identical runs have identical outputs.
+		for _, b := range builds {
+			name := b.Name()
+			err, ok := errors.m[name]
+			if !ok {
+				continue
+			}
 			// Create a UI for the machine readable stuff to be targeted
 			ui := &packer.TargetedUI{
 				Target: name,
@@ -315,62 +648,131 @@ func (c *BuildCommand) RunContext(buildCtx context.Context, cla *BuildArgs) int
 
 			c.Ui.Error(fmt.Sprintf("--> %s: %s", name, err))
 		}
-	}
-
-	if len(artifacts.m) > 0 {
-		c.Ui.Say("\n==> Builds finished. The artifacts of successful builds are:")
-		for name, buildArtifacts := range artifacts.m {
-			// Create a UI for the machine readable stuff to be targeted
+		if combinedErr != nil {
 			ui := &packer.TargetedUI{
-				Target: name,
+				Target: combinedPostProcessorsName,
 				Ui:     c.Ui,
 			}
+			ui.Machine("error", combinedErr.Error())
+			c.Ui.Error(fmt.Sprintf("--> %s: %s", combinedPostProcessorsName, combinedErr))
+		}
+	}
 
-			// Machine-readable helpful
-			ui.Machine("artifact-count", strconv.FormatInt(int64(len(buildArtifacts)), 10))
+	if len(artifacts.m) > 0 || len(combinedArtifacts) > 0 {
+		messages.Say(c.Ui, messages.BuildsArtifacts)
+		for _, b := range builds {
+			name := b.Name()
+			buildArtifacts, ok := artifacts.m[name]
+			if !ok {
+				continue
+			}
+			c.writeArtifacts(name, buildArtifacts)
+		}
+		if len(combinedArtifacts) > 0 {
+			c.writeArtifacts(combinedPostProcessorsName, combinedArtifacts)
+		}
+	} else {
+		messages.Say(c.Ui, messages.BuildsNoArtifacts)
+	}
 
-			for i, artifact := range buildArtifacts {
-				var message bytes.Buffer
-				fmt.Fprintf(&message, "--> %s: ", name)
+	summaries := c.reportResults(builds, results.m, artifacts.m)
 
-				if artifact != nil {
-					fmt.Fprint(&message, artifact.String())
-				} else {
-					fmt.Fprint(&message, "<nothing>")
-				}
+	succeeded := 0
+	for _, res := range results.m {
+		if res.Status == buildSucceeded {
+			succeeded++
+		}
+	}
+	switch {
+	case len(errors.m) > 0 && succeeded == 0:
+		return ExitError, summaries
+	case len(errors.m) > 0, combinedErr != nil:
+		return ExitPartialFailure, summaries
+	case ret != 0:
+		// some builds could not be prepared
+		return ExitValidation, summaries
+	}
+	return ExitSuccess, summaries
+}
 
-				iStr := strconv.FormatInt(int64(i), 10)
-				if artifact != nil {
-					ui.Machine("artifact", iStr, "builder-id", artifact.BuilderId())
-					ui.Machine("artifact", iStr, "id", artifact.Id())
-					ui.Machine("artifact", iStr, "string", artifact.String())
-
-					files := artifact.Files()
-					ui.Machine("artifact",
-						iStr,
-						"files-count", strconv.FormatInt(int64(len(files)), 10))
-					for fi, file := range files {
-						fiStr := strconv.FormatInt(int64(fi), 10)
-						ui.Machine("artifact", iStr, "file", fiStr, file)
-					}
-				} else {
-					ui.Machine("artifact", iStr, "nil")
-				}
+// combinedPostProcessorsName is the name the post-processors running on the
+// artifacts of all builds are reported under.
+const combinedPostProcessorsName = "post-processors"
 
-				ui.Machine("artifact", iStr, "end")
-				c.Ui.Say(message.String())
-			}
+// emitArtifactEvents outputs the artifacts of a build, or of the
+// post-processors of all builds, as events when ui outputs events.
+func emitArtifactEvents(ui packersdk.Ui, name string, buildArtifacts []packersdk.Artifact) {
+	for _, artifact := range buildArtifacts {
+		if artifact != nil {
+			packer.EmitEvent(ui, packer.NewArtifactEvent(name, artifact))
 		}
-	} else {
-		c.Ui.Say("\n==> Builds finished but no artifacts were created.")
 	}
+}
 
-	if len(errors.m) > 0 {
-		// If any errors occurred, exit with a non-zero exit status
-		ret = 1
+// writeArtifacts writes the artifacts of a build, or of the post-processors
+// of all builds. UIs outputting events got them as artifact events already.
+func (c *BuildCommand) writeArtifacts(name string, buildArtifacts []packersdk.Artifact) {
+	// Create a UI for the machine readable stuff to be targeted
+	ui := &packer.TargetedUI{
+		Target: name,
+		Ui:     c.Ui,
 	}
+	_, events := c.Ui.(packer.EventUi)
 
-	return ret
+	// Machine-readable helpful
+	if !events {
+		ui.Machine("artifact-count", strconv.FormatInt(int64(len(buildArtifacts)), 10))
+	}
+
+	for i, artifact := range buildArtifacts {
+		var message bytes.Buffer
+		fmt.Fprintf(&message, "--> %s: ", name)
+
+		if artifact != nil {
+			fmt.Fprint(&message, artifact.String())
+		} else {
+			fmt.Fprint(&message, "<nothing>")
+		}
+
+		if !events {
+			writeArtifactMachine(ui, i, artifact)
+		}
+		c.Ui.Say(message.String())
+	}
+}
+
+// writeArtifactMachine writes the machine-readable messages of the i-th
+// artifact of a build.
+func writeArtifactMachine(ui packersdk.Ui, i int, artifact packersdk.Artifact) {
+	iStr := strconv.FormatInt(int64(i), 10)
+	if artifact != nil {
+		ui.Machine("artifact", iStr, "builder-id", artifact.BuilderId())
+		ui.Machine("artifact", iStr, "id", artifact.Id())
+		ui.Machine("artifact", iStr, "string", artifact.String())
+
+		files := artifact.Files()
+		ui.Machine("artifact",
+			iStr,
+			"files-count", strconv.FormatInt(int64(len(files)), 10))
+		for fi, file := range files {
+			fiStr := strconv.FormatInt(int64(fi), 10)
+			ui.Machine("artifact", iStr, "file", fiStr, file)
+		}
+
+		metadata := packer.ArtifactMetadata(artifact)
+		keys := make([]string, 0, len(metadata))
+		for k := range metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ui.Machine("artifact", iStr, "metadata", k, metadata[k])
+		}
+	} else {
+		ui.Machine("artifact", iStr, "nil")
+	}
+
+	ui.Machine("artifact", iStr, "end")
 }
 
 func (*BuildCommand) Help() string {
@@ -380,22 +782,45 @@ Usage: packer build [options] TEMPLATE
   Will execute multiple builds in parallel as defined in the template.
   The various artifacts created by the template will be outputted.
 
+  When TEMPLATE is a folder followed by "/...", like "./images/...", every
+  folder below it holding HCL2 templates is built with the same options.
+
+  When TEMPLATE is "-", the template, HCL2 or JSON, is read from stdin.
+
 Options:
 
   -color=false                  Disable color output. (Default: color)
   -debug                        Debug mode enabled for builds.
+  -debug-no-pause               Do not pause between steps in debug mode, to use -debug without a terminal.
+  -dry-run                      Evaluate the template and prepare the builds, then print what would be built and exit.
   -except=foo,bar,baz           Run all builds and post-processors other than these.
+  -fail-fast                    Cancel all other builds as soon as one build fails.
+  -fail-on-plugin-conflict      Stop when a plugin is installed several times, like in the plugins folder and for required_plugins, instead of warning.
   -only=foo,bar,baz             Build only the specified builds.
   -force                        Force a build to continue if artifacts exist, deletes existing artifacts.
+  -keep-going                   Run all builds to completion even when some fail. (Default)
+  -heartbeat=1m                 With -machine-readable, output a heartbeat message naming the running step when a build is silent for this long. (Default: 0, disabled)
+  -history-file=path            Record successful builds in this file, to be compared with by 'packer plan'.
+  -json                         Output the events of the builds, like their steps, the output of provisioners, artifacts and errors, as lines of JSON.
   -machine-readable             Produce machine-readable output.
   -on-error=[cleanup|abort|ask|run-cleanup-provisioner] If the build fails do: clean up (default), abort, ask, or run-cleanup-provisioner.
-  -parallel-builds=1            Number of builds to run in parallel. 1 disables parallelization. 0 means no limit (Default: 0)
+  -parallel-builds=1            Number of builds to run in parallel. 1 disables parallelization. 0 means no limit. "auto" sizes it from the host CPU and memory, hypervisor builds weighing more (Default: 0)
+  -parallel-builds-by-type=T=N  Number of builds of builder type T, or of the builder types of plugin T like vmware, to run in parallel, over the parallel_builds_by_type setting of the packer block. Can be set several times. 0 means no limit.
+  -parallel-cpu=N               Number of host CPUs parallel builds can use. Builds are scheduled using what their source declared in its scheduling block. (Default: all)
+  -parallel-memory=8GB          Amount of host memory parallel builds can use. (Default: available memory)
+  -parallel-templates=1         Number of templates of a workspace built at the same time. Builds of all templates share the -parallel-* limits above. 0 means no limit. (Default: 0)
+  -resume                       Save the state of the builds as they run, and resume the builds interrupted in the previous run with -resume. Only some builders support it.
+  -retry=N                      Run a failed build again from scratch up to N times, waiting longer after every failure, over the attempts of the retry block of its build. -retry=0 never retries. (Default: the retry block)
+  -root-dir=path                Folder a template read from stdin is considered to be in, the value of path.root. (Default: the working directory)
+  -skip-create-artifact         Run provisioners but ask builders not to create their artifact, like an AMI. Only some builders support it.
   -timestamp-ui                 Enable prefixing of each ui output with an RFC3339 timestamp.
   -var 'key=value'              Variable for templates, can be used multiple times.
+                                A value of @FILE is read from FILE.
   -var-file=path                JSON or HCL2 file containing user variables.
+  -warn-as-error                Turn warnings into errors, stopping before any build runs.
 `
 
-	return strings.TrimSpace(helpText)
+	return strings.TrimSpace(helpText) + "\n\n" + exitCodesHelp
 }
 
 func (*BuildCommand) Synopsis() string {
@@ -408,16 +833,102 @@ func (*BuildCommand) AutocompleteArgs() complete.Predictor {
 
 func (*BuildCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-color":            complete.PredictNothing,
-		"-debug":            complete.PredictNothing,
-		"-except":           complete.PredictNothing,
-		"-only":             complete.PredictNothing,
-		"-force":            complete.PredictNothing,
-		"-machine-readable": complete.PredictNothing,
-		"-on-error":         complete.PredictNothing,
-		"-parallel":         complete.PredictNothing,
-		"-timestamp-ui":     complete.PredictNothing,
-		"-var":              complete.PredictNothing,
-		"-var-file":         complete.PredictNothing,
+		"-color":                   complete.PredictNothing,
+		"-debug":                   complete.PredictNothing,
+		"-debug-no-pause":          complete.PredictNothing,
+		"-dry-run":                 complete.PredictNothing,
+		"-except":                  complete.PredictNothing,
+		"-fail-fast":               complete.PredictNothing,
+		"-keep-going":              complete.PredictNothing,
+		"-only":                    complete.PredictNothing,
+		"-force":                   complete.PredictNothing,
+		"-heartbeat":               complete.PredictNothing,
+		"-history-file":            complete.PredictNothing,
+		"-json":                    complete.PredictNothing,
+		"-resume":                  complete.PredictNothing,
+		"-machine-readable":        complete.PredictNothing,
+		"-on-error":                complete.PredictNothing,
+		"-parallel":                complete.PredictNothing,
+		"-parallel-builds-by-type": complete.PredictNothing,
+		"-parallel-cpu":            complete.PredictNothing,
+		"-parallel-memory":         complete.PredictNothing,
+		"-parallel-templates":      complete.PredictNothing,
+		"-retry":                   complete.PredictNothing,
+		"-root-dir":                complete.PredictNothing,
+		"-skip-create-artifact":    complete.PredictNothing,
+		"-timestamp-ui":            complete.PredictNothing,
+		"-var":                     complete.PredictNothing,
+		"-var-file":                complete.PredictNothing,
+		"-warn-as-error":           complete.PredictNothing,
+		"-fail-on-plugin-conflict": complete.PredictNothing,
+	}
+}
+
+// uiLineWriter says every line written to it to Ui, to output the lines of
+// JSON of build -json to the UI of the command.
+type uiLineWriter struct {
+	Ui packersdk.Ui
+}
+
+func (w *uiLineWriter) Write(p []byte) (int, error) {
+	w.Ui.Say(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// colorTheme returns the color theme of PACKER_COLOR_THEME, or of the theme
+// file of the packer config directory. An invalid theme is reported, and the
+// default theme is used instead.
+func (c *BuildCommand) colorTheme() *packer.ColorTheme {
+	filename := os.Getenv("PACKER_COLOR_THEME")
+	if filename == "" {
+		configDir, err := pathing.ConfigDir()
+		if err != nil {
+			log.Printf("[WARN] Not loading the color theme: %s", err)
+			return packer.DefaultColorTheme()
+		}
+		filename = filepath.Join(configDir, packer.ColorThemeFile)
+	}
+	theme, err := packer.LoadColorTheme(filename)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Invalid color theme, using the default one: %s", err))
+		return packer.DefaultColorTheme()
+	}
+	return theme
+}
+
+// openCheckpoints returns the checkpoints saving the state of builds in
+// packer.StateDir, by build name, loading the states of the builds that were
+// interrupted. Builds are only resumed when their configuration did not
+// change, which is told by their fingerprint.
+func (c *BuildCommand) openCheckpoints(cla *BuildArgs, handler packer.Handler, builds []packersdk.Build) map[string]*packer.BuildCheckpoint {
+	fingerprints, err := fingerprintBuilds(&cla.MetaArgs, handler, builds, cla.HistoryFile)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to fingerprint builds, they cannot be resumed: %s", err))
+		return nil
+	}
+	checkpoints := map[string]*packer.BuildCheckpoint{}
+	for _, b := range builds {
+		cb, ok := b.(*packer.CoreBuild)
+		if !ok {
+			continue
+		}
+		fp := fingerprints[b.Name()]
+		fp.Time = time.Time{}
+		js, err := json.Marshal(fp)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Build '%s' cannot be resumed: %s", b.Name(), err))
+			continue
+		}
+		cp, err := packer.OpenBuildCheckpoint(packer.StateDir, b.Name(), hashString(string(js)))
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Build '%s' cannot be resumed: %s", b.Name(), err))
+			continue
+		}
+		if cp.Resumed() && packer.CanResume(cb.Builder) {
+			c.Ui.Say(fmt.Sprintf("Resuming the interrupted build '%s'.", b.Name()))
+		}
+		cb.SetCheckpoint(cp)
+		checkpoints[b.Name()] = cp
 	}
+	return checkpoints
 }
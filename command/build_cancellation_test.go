@@ -20,32 +20,32 @@ func TestBuildCommand_RunContext_CtxCancel(t *testing.T) {
 		{"cancel 1 pending build - parallel=true",
 			[]string{"-parallel-builds=10", filepath.Join(testFixture("parallel"), "1lock-5wg.json")},
 			5,
-			1,
+			ExitCancelled,
 		},
 		{"cancel in the middle with 2 pending builds - parallel=true",
 			[]string{"-parallel-builds=10", filepath.Join(testFixture("parallel"), "2lock-4wg.json")},
 			4,
-			1,
+			ExitCancelled,
 		},
 		{"cancel 1 locked build - debug - parallel=true",
 			[]string{"-parallel-builds=10", "-debug=true", filepath.Join(testFixture("parallel"), "1lock.json")},
 			0,
-			1,
+			ExitCancelled,
 		},
 		{"cancel 2 locked builds - debug - parallel=true",
 			[]string{"-parallel-builds=10", "-debug=true", filepath.Join(testFixture("parallel"), "2lock.json")},
 			0,
-			1,
+			ExitCancelled,
 		},
 		{"cancel 1 locked build - debug - parallel=false",
 			[]string{"-parallel-builds=1", "-debug=true", filepath.Join(testFixture("parallel"), "1lock.json")},
 			0,
-			1,
+			ExitCancelled,
 		},
 		{"cancel 2 locked builds - debug - parallel=false",
 			[]string{"-parallel-builds=1", "-debug=true", filepath.Join(testFixture("parallel"), "2lock.json")},
 			0,
-			1,
+			ExitCancelled,
 		},
 	}
 
@@ -85,3 +85,26 @@ func TestBuildCommand_RunContext_CtxCancel(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildCommand_RunContext_CtxCancelledBeforeBuilds(t *testing.T) {
+	defer cleanup()
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	cfg, ret := c.ParseArgs([]string{testFixture("hcl", "datasource.pkr.hcl")})
+	if ret != 0 {
+		t.Fatal("ParseArgs failed.")
+	}
+
+	// packer was interrupted while data sources were read
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	cancelCtx()
+
+	if code := c.RunContext(ctx, cfg); code != ExitCancelled {
+		t.Errorf("expected exit code %d, got %d", ExitCancelled, code)
+	}
+	if fileExists("chocolate.txt") {
+		t.Error("expected no build to be started")
+	}
+}
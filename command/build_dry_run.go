@@ -0,0 +1,138 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/packer"
+)
+
+// dryRunSensitiveValue replaces the values of sensitive variables in the
+// output of a dry run.
+const dryRunSensitiveValue = "<sensitive>"
+
+// dryRun prints what `packer build` would do with the already prepared
+// builds, without running any of them.
+func (c *BuildCommand) dryRun(handler packer.Handler, builds []packersdk.Build) {
+	c.Ui.Say(fmt.Sprintf("==> Dry run: %d build(s) would run.", len(builds)))
+
+	vars := dryRunVariables(handler, builds)
+	if len(vars) > 0 {
+		c.Ui.Say("\n==> Variables:")
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			c.Ui.Say(fmt.Sprintf("    %s: %q", k, vars[k]))
+		}
+	}
+
+	for _, b := range builds {
+		name := b.Name()
+		ui := &packer.TargetedUI{
+			Target: name,
+			Ui:     c.Ui,
+		}
+		ui.Machine("dry-run", "build")
+		c.Ui.Say(fmt.Sprintf("\n==> %s:", name))
+
+		cb, ok := b.(*packer.CoreBuild)
+		if !ok {
+			continue
+		}
+		c.Ui.Say(fmt.Sprintf("    builder: %s", cb.BuilderType))
+		ui.Machine("dry-run", "builder", cb.BuilderType)
+		if cb.Resources != nil {
+			c.Ui.Say(fmt.Sprintf("    scheduling: cpu=%d memory=%d", cb.Resources.CPU, cb.Resources.Memory))
+		}
+		if cb.Timeout > 0 {
+			c.Ui.Say(fmt.Sprintf("    timeout: %s", cb.Timeout))
+		}
+
+		provisioners := make([]string, 0, len(cb.Provisioners))
+		for _, p := range cb.Provisioners {
+			provisioners = append(provisioners, componentName(p.PType, p.PName))
+			ui.Machine("dry-run", "provisioner", componentName(p.PType, p.PName))
+		}
+		if len(provisioners) == 0 {
+			provisioners = append(provisioners, "<no provisioner>")
+		}
+		c.Ui.Say(fmt.Sprintf("    provisioners: %s", strings.Join(provisioners, ", ")))
+
+		c.Ui.Say("    post-processors:")
+		if len(cb.PostProcessors) == 0 {
+			c.Ui.Say("      <no post-processor>")
+		}
+		for i, ppList := range cb.PostProcessors {
+			pps := make([]string, 0, len(ppList))
+			for _, pp := range ppList {
+				pps = append(pps, componentName(pp.PType, pp.PName))
+				ui.Machine("dry-run", "post-processor", fmt.Sprintf("%d", i), componentName(pp.PType, pp.PName))
+			}
+			c.Ui.Say(fmt.Sprintf("      %d: %s", i, strings.Join(pps, " -> ")))
+		}
+	}
+}
+
+// dryRunVariables returns the resolved variables of the template, with the
+// values of sensitive ones hidden.
+func dryRunVariables(handler packer.Handler, builds []packersdk.Build) map[string]string {
+	vars := map[string]string{}
+	if cfg, ok := handler.(*hcl2template.PackerConfig); ok {
+		for name, v := range cfg.InputVariables {
+			if v.Sensitive {
+				vars["var."+name] = dryRunSensitiveValue
+			}
+		}
+	}
+	for k, v := range buildVariables(handler, builds) {
+		if _, hidden := vars[k]; hidden {
+			continue
+		}
+		// hide values of sensitive variables used in other variables.
+		vars[k] = packersdk.LogSecretFilter.FilterString(v)
+	}
+	return vars
+}
+
+// buildVariables returns the resolved variables of the template, with the
+// values of sensitive ones.
+func buildVariables(handler packer.Handler, builds []packersdk.Build) map[string]string {
+	vars := map[string]string{}
+	if cfg, ok := handler.(*hcl2template.PackerConfig); ok {
+		for name, v := range cfg.InputVariables {
+			val, _ := v.Value()
+			val, _ = val.UnmarkDeep()
+			vars["var."+name] = hcl2template.PrintableCtyValue(val)
+		}
+		for name, v := range cfg.LocalVariables {
+			val, _ := v.Value()
+			val, _ = val.UnmarkDeep()
+			vars["local."+name] = hcl2template.PrintableCtyValue(val)
+		}
+		return vars
+	}
+	for _, b := range builds {
+		if cb, ok := b.(*packer.CoreBuild); ok {
+			for k, v := range cb.Variables {
+				if k != "" {
+					vars[k] = v
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// componentName returns "type.name" or "type" if the component is unnamed.
+func componentName(typ, name string) string {
+	if name == "" {
+		return typ
+	}
+	return typ + "." + name
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
 
@@ -83,10 +85,18 @@ func testMetaParallel(t *testing.T, builder *ParallelTestBuilder, locked *Locked
 		Ui: &packersdk.BasicUi{
 			Writer:      &out,
 			ErrorWriter: &err,
+			// debug mode needs a terminal to pause on
+			TTY: testTTY{},
 		},
 	}
 }
 
+// testTTY answers all prompts with an empty line.
+type testTTY struct{}
+
+func (testTTY) Close() error                { return nil }
+func (testTTY) ReadString() (string, error) { return "\n", nil }
+
 func TestBuildParallel_1(t *testing.T) {
 	// testfile has 6 builds, with first one locks 'forever', other builds
 	// should go through.
@@ -173,3 +183,113 @@ func TestBuildParallel_Timeout(t *testing.T) {
 	close(locked.unlock) // unlock locking one
 	wg.Wait()            // wait for termination
 }
+
+func TestBuildParallel_FailFast(t *testing.T) {
+	// testfile has 2 builds, the first one locks 'forever' and the second
+	// one times out; with -fail-fast the timeout cancels the locked build.
+	locked := &LockedBuilder{unlock: make(chan interface{})}
+	defer close(locked.unlock)
+
+	c := &BuildCommand{
+		Meta: testMetaParallel(t, nil, locked),
+	}
+
+	args := []string{
+		"-fail-fast",
+		filepath.Join(testFixture("parallel"), "1lock-timeout.json"),
+	}
+
+	if code := c.Run(args); code != ExitError {
+		fatalCommand(t, c.Meta)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	for _, expected := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^build0 +cancelled `),
+		regexp.MustCompile(`(?m)^timeout-build +failed `),
+	} {
+		if !expected.MatchString(out) {
+			t.Errorf("expected %s in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestBuildParallel_byType(t *testing.T) {
+	// testfile has 6 builds, 2 of them lock 'forever'. Only one lock build
+	// runs at a time, and the second one, pending, does not hold back the
+	// builds queued after it.
+	b := NewParallelTestBuilder(4)
+	locked := &LockedBuilder{unlock: make(chan interface{})}
+
+	c := &BuildCommand{
+		Meta: testMetaParallel(t, b, locked),
+	}
+
+	args := []string{
+		"-parallel-builds-by-type=lock=1",
+		filepath.Join(testFixture("parallel"), "2lock-4wg.json"),
+	}
+
+	wg := errgroup.Group{}
+
+	wg.Go(func() error {
+		if code := c.Run(args); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+		return nil
+	})
+
+	b.wg.Wait()          // ran 4 times
+	close(locked.unlock) // unlock locking ones
+	wg.Wait()            // wait for termination
+
+	out, _ := outputCommand(t, c.Meta)
+	for _, expected := range []string{
+		"Build 'build3' is pending: 1 lock build(s) running, the most allowed.",
+		"Build 'build3' is starting, after waiting",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestTypeLimitKeys(t *testing.T) {
+	limits := map[string]int64{"vmware": 2, "vmware-iso": 1, "docker": 0, "qemu": 1}
+	for builderType, expected := range map[string]string{
+		"vmware-iso": "vmware,vmware-iso",
+		"vmware-vmx": "vmware",
+		"vmwarex":    "",
+		"docker":     "",
+		"qemu":       "qemu",
+	} {
+		if keys := strings.Join(typeLimitKeys(limits, builderType), ","); keys != expected {
+			t.Errorf("%s: got %q, expected %q", builderType, keys, expected)
+		}
+	}
+}
+
+func TestBuildScheduler_lateTypeLimits(t *testing.T) {
+	s := newBuildScheduler(&BuildArgs{})
+	first := s.Queue(&packer.CoreBuild{Type: "first", BuilderType: "vmware-iso"})
+	// the limits of another template of the workspace
+	s.SetTypeLimits(map[string]int64{"vmware": 1})
+	second := s.Queue(&packer.CoreBuild{Type: "second", BuilderType: "vmware-iso"})
+
+	select {
+	case <-first.ready:
+	default:
+		t.Fatal("expected the first build to start")
+	}
+	select {
+	case <-second.ready:
+		t.Fatal("expected the second build to wait for the first one, the limits being set after it was queued")
+	default:
+	}
+	s.release(first)
+	select {
+	case <-second.ready:
+	default:
+		t.Fatal("expected the second build to start once the first one is done")
+	}
+}
@@ -0,0 +1,221 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+)
+
+// buildStatus is the outcome of a build, as shown in the final report.
+type buildStatus string
+
+const (
+	buildSucceeded buildStatus = "succeeded"
+	buildFailed    buildStatus = "failed"
+	// buildCancelled is a build that was stopped while running, either
+	// because packer was interrupted or because another build failed with
+	// -fail-fast set.
+	buildCancelled buildStatus = "cancelled"
+	// buildNotStarted is a build that was never started for one of the
+	// reasons above.
+	buildNotStarted buildStatus = "not-started"
+)
+
+var (
+	// errBuildNotStartedInterrupted is why builds are not started once
+	// packer is interrupted.
+	errBuildNotStartedInterrupted = errors.New("packer was interrupted before the build started")
+	// errBuildNotStartedFailFast is why builds are not started once another
+	// build failed with -fail-fast set.
+	errBuildNotStartedFailFast = errors.New("another build failed with -fail-fast set before the build started")
+)
+
+// buildResult is what the final summary knows about a build.
+type buildResult struct {
+	Status   buildStatus
+	Duration time.Duration
+	Err      error
+}
+
+// buildSummary is a line of the final summary, it is also the payload of the
+// machine-readable `summary` event.
+type buildSummary struct {
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	Duration    string   `json:"duration"`
+	ArtifactIDs []string `json:"artifact_ids"`
+	// Metadata is what the provisioners of the build attached to its
+	// artifacts.
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	ErrorClass string            `json:"error_class,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	// Replicas are the copies of the artifact of the build to the targets of
+	// its replicate block.
+	Replicas []replicaSummary `json:"replicas,omitempty"`
+}
+
+// replicaSummary is the outcome of the copy of the artifact of a build to a
+// target.
+type replicaSummary struct {
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Duration   string `json:"duration,omitempty"`
+	ArtifactID string `json:"artifact_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// errorClass tells broadly why a build did not succeed.
+func errorClass(res buildResult) string {
+	switch {
+	case res.Status == buildCancelled || res.Status == buildNotStarted:
+		return string(res.Status)
+	case res.Err == nil:
+		return ""
+	case errors.Is(res.Err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "error"
+}
+
+// summarizeBuilds returns the summary of every build, in the order they were
+// started.
+func summarizeBuilds(builds []packersdk.Build, results map[string]buildResult, artifacts map[string][]packersdk.Artifact) []buildSummary {
+	summaries := make([]buildSummary, 0, len(builds))
+	for _, b := range builds {
+		name := b.Name()
+		res := results[name]
+		s := buildSummary{
+			Name:        name,
+			Status:      string(res.Status),
+			ArtifactIDs: []string{},
+			ErrorClass:  errorClass(res),
+		}
+		if res.Status != buildNotStarted {
+			s.Duration = res.Duration.Round(time.Second).String()
+		}
+		if res.Err != nil {
+			s.Error = packersdk.LogSecretFilter.FilterString(res.Err.Error())
+		}
+		for _, a := range artifacts[name] {
+			if a != nil && a.Id() != "" {
+				s.ArtifactIDs = append(s.ArtifactIDs, a.Id())
+			}
+			if s.Metadata == nil {
+				s.Metadata = packer.ArtifactMetadata(a)
+			}
+		}
+		if cb, ok := b.(*packer.CoreBuild); ok {
+			for _, replica := range cb.Replicas() {
+				r := replicaSummary{
+					Target: replica.Target,
+					Status: string(replica.Status),
+				}
+				if replica.Duration > 0 {
+					r.Duration = replica.Duration.Round(time.Second).String()
+				}
+				if replica.Artifact != nil {
+					r.ArtifactID = replica.Artifact.Id()
+				}
+				if replica.Err != nil {
+					r.Error = packersdk.LogSecretFilter.FilterString(replica.Err.Error())
+				}
+				s.Replicas = append(s.Replicas, r)
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// reportResults prints a summary table of all builds and returns it. In
+// machine-readable mode every build also gets a `build-result` and a
+// `summary` message with the fields of its line; the json UI gets the whole
+// table as a single `summary` event instead.
+func (c *BuildCommand) reportResults(builds []packersdk.Build, results map[string]buildResult, artifacts map[string][]packersdk.Artifact) []buildSummary {
+	if len(builds) == 0 {
+		return nil
+	}
+	summaries := summarizeBuilds(builds, results, artifacts)
+
+	_, events := c.Ui.(packer.EventUi)
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILD\tSTATUS\tDURATION\tARTIFACTS\tERROR")
+	for _, s := range summaries {
+		ui := &packer.TargetedUI{
+			Target: s.Name,
+			Ui:     c.Ui,
+		}
+		ui.Machine("build-result", s.Status)
+		if !events {
+			ui.Machine(packer.EventSummary,
+				append([]string{s.Status, s.Duration, s.ErrorClass, s.Error}, s.ArtifactIDs...)...)
+		}
+
+		ids := strings.Join(s.ArtifactIDs, ",")
+		if ids == "" {
+			ids = "-"
+		}
+		duration, class := s.Duration, s.ErrorClass
+		if duration == "" {
+			duration = "-"
+		}
+		if class == "" {
+			class = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Status, duration, ids, class)
+	}
+	w.Flush()
+
+	c.Ui.Say("\n==> Build summary:")
+	c.Ui.Say(strings.TrimSuffix(table.String(), "\n"))
+	if replicas := replicasTable(summaries); replicas != "" {
+		c.Ui.Say("\n==> Replication summary:")
+		c.Ui.Say(replicas)
+	}
+
+	if payload, err := json.Marshal(summaries); err == nil {
+		packer.EmitEvent(c.Ui, packer.UiEvent{Type: packer.EventSummary, Summary: payload})
+	}
+	return summaries
+}
+
+// replicasTable returns a table of the copies of the artifacts of the builds
+// of summaries, or an empty string when no build replicated its artifact.
+func replicasTable(summaries []buildSummary) string {
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILD\tTARGET\tSTATUS\tDURATION\tARTIFACT\tERROR")
+	found := false
+	for _, s := range summaries {
+		for _, r := range s.Replicas {
+			found = true
+			duration, id, errMsg := r.Duration, r.ArtifactID, r.Error
+			if duration == "" {
+				duration = "-"
+			}
+			if id == "" {
+				id = "-"
+			}
+			if errMsg == "" {
+				errMsg = "-"
+			}
+			errMsg = strings.SplitN(errMsg, "\n", 2)[0]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, r.Target, r.Status, duration, id, errMsg)
+		}
+	}
+	if !found {
+		return ""
+	}
+	w.Flush()
+	return strings.TrimSuffix(table.String(), "\n")
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+)
+
+func Test_summarizeBuilds(t *testing.T) {
+	// the error of a build running longer than its timeout
+	slow := &packer.CoreBuild{
+		Type: "slow",
+		Builder: &packersdk.MockBuilder{RunFn: func(ctx context.Context) {
+			<-ctx.Done()
+		}},
+		Timeout: time.Millisecond,
+	}
+	slow.Prepare()
+	_, timeoutErr := slow.Run(context.Background(), packer.TestUi(t))
+
+	builds := []packersdk.Build{
+		&packer.CoreBuild{Type: "ok"},
+		&packer.CoreBuild{Type: "broken"},
+		&packer.CoreBuild{Type: "slow"},
+		&packer.CoreBuild{Type: "skipped"},
+	}
+	results := map[string]buildResult{
+		"ok":      {Status: buildSucceeded, Duration: 90 * time.Second},
+		"broken":  {Status: buildFailed, Duration: time.Second, Err: fmt.Errorf("boom")},
+		"slow":    {Status: buildFailed, Duration: time.Minute, Err: timeoutErr},
+		"skipped": {Status: buildNotStarted},
+	}
+	artifacts := map[string][]packersdk.Artifact{
+		"ok": {&packersdk.MockArtifact{
+			IdValue: "ami-1234",
+			StateValues: map[string]interface{}{
+				packer.ArtifactMetadataState: &map[string]string{"nginx_version": "1.18.0"},
+			},
+		}, nil},
+	}
+
+	want := []buildSummary{
+		{Name: "ok", Status: "succeeded", Duration: "1m30s", ArtifactIDs: []string{"ami-1234"},
+			Metadata: map[string]string{"nginx_version": "1.18.0"}},
+		{Name: "broken", Status: "failed", Duration: "1s", ArtifactIDs: []string{}, ErrorClass: "error", Error: "boom"},
+		{Name: "slow", Status: "failed", Duration: "1m0s", ArtifactIDs: []string{}, ErrorClass: "timeout", Error: "Build timed out after 1ms: context deadline exceeded"},
+		{Name: "skipped", Status: "not-started", ArtifactIDs: []string{}, ErrorClass: "not-started"},
+	}
+	if diff := cmp.Diff(want, summarizeBuilds(builds, results, artifacts)); diff != "" {
+		t.Fatalf("unexpected summary: %s", diff)
+	}
+}
+
+func TestBuildCommand_reportResults_machine(t *testing.T) {
+	builds := []packersdk.Build{&packer.CoreBuild{Type: "ok"}}
+	results := map[string]buildResult{
+		"ok": {Status: buildSucceeded, Duration: time.Second},
+	}
+	artifacts := map[string][]packersdk.Artifact{
+		"ok": {&packersdk.MockArtifact{IdValue: "ami-1"}, &packersdk.MockArtifact{IdValue: "ami-2"}},
+	}
+
+	// the json UI gets the summary as JSON
+	var js bytes.Buffer
+	c := &BuildCommand{Meta: Meta{Ui: &packer.JSONUi{Writer: &js}}}
+	c.reportResults(builds, results, artifacts)
+	var summary *packer.UiEvent
+	dec := json.NewDecoder(&js)
+	for dec.More() {
+		var event packer.UiEvent
+		if err := dec.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type == packer.EventSummary {
+			summary = &event
+		}
+	}
+	if summary == nil {
+		t.Fatalf("no summary event in:\n%s", js.String())
+	}
+	var got []buildSummary
+	if err := json.Unmarshal(summary.Summary, &got); err != nil {
+		t.Fatalf("the summary must be a JSON array: %s", err)
+	}
+	if len(got) != 1 || got[0].Name != "ok" || len(got[0].ArtifactIDs) != 2 {
+		t.Fatalf("bad summary: %#v", got)
+	}
+
+	// the machine-readable UI gets a line of fields per build
+	var out bytes.Buffer
+	c = &BuildCommand{Meta: Meta{Ui: &packer.MachineReadableUi{Writer: &out}}}
+	c.reportResults(builds, results, artifacts)
+	if !strings.Contains(out.String(), ",ok,summary,succeeded,1s,,,ami-1,ami-2\n") {
+		t.Fatalf("no summary line of the build in:\n%s", out.String())
+	}
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+)
+
+// machineBuildRetry is the type of the machine-readable message telling
+// that a failed build is run again, with the attempt, the number of attempts
+// and how long packer waits before it.
+const machineBuildRetry = "build-retry"
+
+// buildRetry returns how many times b runs at most when it fails: the retry
+// block of its build, with the attempts set by -retry when passed, even 0.
+func buildRetry(cla *BuildArgs, b packersdk.Build) packer.BuildRetry {
+	retry := packer.BuildRetry{
+		Attempts:   1,
+		MinBackoff: packer.DefaultBuildRetryMinBackoff,
+		MaxBackoff: packer.DefaultBuildRetryMaxBackoff,
+	}
+	if cb, ok := b.(*packer.CoreBuild); ok && cb.Retry != nil {
+		retry = *cb.Retry
+	}
+	if cla.RetrySet {
+		retry.Attempts = cla.Retry + 1
+	}
+	return retry
+}
+
+// runBuildWithRetries runs b, running it again from scratch after a backoff
+// when it fails, as many times as its retry policy says. Builds are not
+// retried when they are cancelled, nor with -on-error=abort or ask, which
+// leave what the failed build created for someone to look at.
+func runBuildWithRetries(ctx context.Context, cla *BuildArgs, b packersdk.Build, ui packersdk.Ui, checkpoint *packer.BuildCheckpoint) ([]packersdk.Artifact, error) {
+	retry := buildRetry(cla, b)
+	if retry.Attempts > 1 && (cla.OnError == "abort" || cla.OnError == "ask") {
+		log.Printf("-on-error=%s is set, build '%s' won't be retried", cla.OnError, b.Name())
+		retry.Attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		artifacts, err := b.Run(ctx, ui)
+		if err == nil || ctx.Err() != nil || attempt >= retry.Attempts {
+			return artifacts, err
+		}
+
+		backoff := retry.Backoff(attempt)
+		ui.Error(fmt.Sprintf("Build '%s' failed: %s. Retrying it from scratch in %s (attempt %d of %d).",
+			b.Name(), err, backoff, attempt+1, retry.Attempts))
+		ui.Machine(fmt.Sprintf("%s,%s", b.Name(), machineBuildRetry),
+			fmt.Sprint(attempt+1), fmt.Sprint(retry.Attempts), backoff.String())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return artifacts, err
+		case <-timer.C:
+		}
+		if checkpoint != nil {
+			// the state of the failed attempt must not be resumed
+			checkpoint.Reset()
+		}
+	}
+}
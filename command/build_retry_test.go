@@ -0,0 +1,89 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+)
+
+// FlakyBuilder fails its first failures runs.
+type FlakyBuilder struct {
+	failures, runs int
+}
+
+func (b *FlakyBuilder) ConfigSpec() hcldec.ObjectSpec { return hcldec.ObjectSpec{} }
+
+func (b *FlakyBuilder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return nil, nil, nil
+}
+
+func (b *FlakyBuilder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	b.runs++
+	if b.runs <= b.failures {
+		return nil, errors.New("no spot capacity")
+	}
+	return nil, nil
+}
+
+func testMetaFlaky(t *testing.T, builder *FlakyBuilder) Meta {
+	var out, err bytes.Buffer
+	return Meta{
+		CoreConfig: &packer.CoreConfig{
+			Components: packer.ComponentFinder{
+				PluginConfig: &packer.PluginConfig{
+					Builders: packer.MapOfBuilder{
+						"flaky": func() (packersdk.Builder, error) { return builder, nil },
+					},
+				},
+			},
+		},
+		Ui: &packersdk.BasicUi{
+			Writer:      &out,
+			ErrorWriter: &err,
+		},
+	}
+}
+
+func TestBuild_retry(t *testing.T) {
+	fixture := filepath.Join(testFixture("build-retry"), "template.pkr.hcl")
+
+	tc := []struct {
+		name     string
+		args     []string
+		failures int
+		wantCode int
+		wantRuns int
+		retried  bool
+	}{
+		{"succeeds after retries", nil, 2, 0, 3, true},
+		{"fails every attempt", nil, 5, ExitError, 3, true},
+		{"-retry overrides the attempts", []string{"-retry=4"}, 4, 0, 5, true},
+		{"-retry=0 turns the retry block off", []string{"-retry=0"}, 1, ExitError, 1, false},
+		{"-on-error=abort does not retry", []string{"-on-error=abort"}, 1, ExitError, 1, false},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := &FlakyBuilder{failures: tt.failures}
+			c := &BuildCommand{
+				Meta: testMetaFlaky(t, builder),
+			}
+			if code := c.Run(append(tt.args, fixture)); code != tt.wantCode {
+				fatalCommand(t, c.Meta)
+			}
+			if builder.runs != tt.wantRuns {
+				t.Errorf("expected %d runs, got %d", tt.wantRuns, builder.runs)
+			}
+			_, stderr := outputCommand(t, c.Meta)
+			if retried := strings.Contains(stderr, "Retrying it from scratch"); retried != tt.retried {
+				t.Errorf("expected retried to be %t, the errors are:\n%s", tt.retried, stderr)
+			}
+		})
+	}
+}
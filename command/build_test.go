@@ -2,6 +2,7 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -79,7 +80,7 @@ func TestBuild(t *testing.T) {
 				"-var-file=" + filepath.Join(testFixture("var-arg"), "potato.json"),
 				filepath.Join(testFixture("var-arg"), "fruit_builder.json"),
 			},
-			expectedCode: 1,
+			expectedCode: ExitValidation,
 			fileCheck:    fileCheck{notExpected: []string{"potato.txt"}},
 		},
 
@@ -89,7 +90,7 @@ func TestBuild(t *testing.T) {
 				"-var-file=" + filepath.Join(testFixture("var-arg"), "potato.json"),
 				testFixture("var-arg"),
 			},
-			expectedCode: 1,
+			expectedCode: ExitValidation,
 			fileCheck:    fileCheck{notExpected: []string{"potato.txt"}},
 		},
 
@@ -99,7 +100,7 @@ func TestBuild(t *testing.T) {
 				"-var-file=" + filepath.Join(testFixture("var-arg"), "potato.hcl"),
 				testFixture("var-arg"),
 			},
-			expectedCode: 1,
+			expectedCode: ExitValidation,
 			fileCheck:    fileCheck{notExpected: []string{"potato.hcl"}},
 		},
 
@@ -349,7 +350,7 @@ func TestBuild(t *testing.T) {
 				"-var-file", filepath.Join(testFixture("hcl", "validation", "map", "invalid_value.pkrvars.hcl")),
 				filepath.Join(testFixture("hcl", "validation", "map")),
 			},
-			expectedCode: 1,
+			expectedCode: ExitValidation,
 		},
 
 		{
@@ -369,7 +370,7 @@ func TestBuild(t *testing.T) {
 				"-var", `image_metadata={key = "?", something = { foo = "wrong" }}`,
 				filepath.Join(testFixture("hcl", "validation", "map")),
 			},
-			expectedCode: 1,
+			expectedCode: ExitValidation,
 		},
 		{
 			name: "hcl - execute and use datasource",
@@ -382,6 +383,23 @@ func TestBuild(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "var-args: json - dry run does not build",
+			args: []string{
+				"-dry-run",
+				"-var=fruit=pear",
+				filepath.Join(testFixture("var-arg"), "fruit_builder.json"),
+			},
+			fileCheck: fileCheck{notExpected: []string{"pear.txt"}},
+		},
+		{
+			name: "hcl - dry run does not execute builds",
+			args: []string{
+				"-dry-run",
+				testFixture("hcl", "datasource.pkr.hcl"),
+			},
+			fileCheck: fileCheck{notExpected: []string{"chocolate.txt"}},
+		},
 	}
 
 	for _, tt := range tc {
@@ -509,6 +527,39 @@ func TestBuildStdin(t *testing.T) {
 	}
 }
 
+func TestBuildStdin_hcl2(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	f, err := os.Open(filepath.Join(testFixture("build-stdin"), "template.pkr.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	defer cleanup()
+	args := []string{"-var", "flavor=dark", "-root-dir", "images/dessert", "-"}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	content, err := ioutil.ReadFile("chocolate.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Abs(filepath.Join("images", "dessert"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("dark from "+filepath.ToSlash(root), string(content)); diff != "" {
+		t.Fatalf("unexpected content: %s", diff)
+	}
+}
+
 func TestBuildOnlyFileMultipleFlags(t *testing.T) {
 	c := &BuildCommand{
 		Meta: testMetaFile(t),
@@ -595,6 +646,182 @@ func TestBuildEverything(t *testing.T) {
 	}
 }
 
+func TestBuild_combinedPostProcessors(t *testing.T) {
+	fixture := filepath.Join(testFixture("build-combined-post-processors"), "template.pkr.hcl")
+
+	t.Run("run on the artifacts of all builds", func(t *testing.T) {
+		c := &BuildCommand{
+			Meta: testMetaFile(t),
+		}
+		defer cleanup()
+
+		if code := c.Run([]string{fixture}); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+		b, err := ioutil.ReadFile("tomato.txt")
+		if err != nil {
+			t.Fatalf("the post-processors of all builds did not run: %s", err)
+		}
+		if string(b) != "chocolatevanilla" {
+			t.Errorf("the post-processors of all builds ran before the builds were done: %q", b)
+		}
+		out, _ := outputCommand(t, c.Meta)
+		if !strings.Contains(out, "--> post-processors: 2 artifacts:") {
+			t.Errorf("expected the artifact of the post-processors to be reported:\n%s", out)
+		}
+	})
+
+	t.Run("except", func(t *testing.T) {
+		c := &BuildCommand{
+			Meta: testMetaFile(t),
+		}
+		defer cleanup()
+
+		if code := c.Run([]string{"-except=tomato", fixture}); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+		if fileExists("tomato.txt") {
+			t.Error("the tomato post-processor should have been excepted")
+		}
+	})
+}
+
+func TestBuild_replicate(t *testing.T) {
+	fixture := filepath.Join(testFixture("build-replicate"), "template.pkr.hcl")
+
+	t.Run("copy to every target", func(t *testing.T) {
+		c := &BuildCommand{
+			Meta: testMetaFile(t),
+		}
+		defer cleanup("replicas")
+
+		if code := c.Run([]string{fixture}); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+		for _, f := range []string{"replicas/eu/chocolate.txt", "replicas/us/chocolate.txt"} {
+			if !fileExists(f) {
+				t.Errorf("Expected to find %s", f)
+			}
+		}
+		out, _ := outputCommand(t, c.Meta)
+		for _, expected := range []string{"==> Replication summary:", "replicas/eu  succeeded", "replicas/us  succeeded"} {
+			if !strings.Contains(out, expected) {
+				t.Errorf("expected %q in the output:\n%s", expected, out)
+			}
+		}
+	})
+
+	t.Run("failed copy", func(t *testing.T) {
+		c := &BuildCommand{
+			Meta: testMetaFile(t),
+		}
+		defer cleanup("replicas")
+
+		// chocolate.txt is a file, it cannot be a folder.
+		args := []string{"-var", `targets=["replicas/eu", "chocolate.txt/us"]`, fixture}
+		if code := c.Run(args); code != ExitError {
+			fatalCommand(t, c.Meta)
+		}
+		if !fileExists("replicas/eu/chocolate.txt") {
+			t.Error("the other copies should still be done")
+		}
+		out, _ := outputCommand(t, c.Meta)
+		for _, expected := range []string{"replicas/eu       succeeded", "chocolate.txt/us  failed"} {
+			if !strings.Contains(out, expected) {
+				t.Errorf("expected %q in the output:\n%s", expected, out)
+			}
+		}
+	})
+}
+
+func TestBuild_machineReadableOrder(t *testing.T) {
+	var out bytes.Buffer
+	c := &BuildCommand{
+		Meta: Meta{
+			CoreConfig: testCoreConfigBuilder(t),
+			Ui:         &packer.MachineReadableUi{Writer: &out},
+		},
+	}
+
+	args := []string{
+		"-except=apple,peach,pear,banana,tomato,unnamed",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, out.String())
+	}
+
+	var targets []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) > 2 && fields[2] == "artifact-count" {
+			targets = append(targets, fields[1])
+		}
+	}
+	expected := []string{"cherry", "chocolate", "vanilla"}
+	if diff := cmp.Diff(expected, targets); diff != "" {
+		t.Fatalf("artifacts are not reported in the order of the builds: %s", diff)
+	}
+}
+
+func TestBuild_json(t *testing.T) {
+	// the build command replaces its UI with the json one, writing to this
+	// one
+	meta := testMetaFile(t)
+	c := &BuildCommand{
+		Meta: meta,
+	}
+
+	args := []string{
+		"-json",
+		filepath.Join(testFixture("build-json"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		out, stderr := outputCommand(t, meta)
+		t.Fatalf("unexpected exit code %d: %s %s", code, out, stderr)
+	}
+
+	// the events are output where they happen, not parsed back out of
+	// messages
+	out, _ := outputCommand(t, meta)
+	var steps, provisioners, provisionerOutput int
+	var artifact *packer.ArtifactEvent
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var event packer.UiEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%q is not a JSON event: %s", line, err)
+		}
+		if strings.Contains(event.Message, "==> chocolate") {
+			t.Errorf("event with a prefixed message: %s", line)
+		}
+		if event.Target != "chocolate" {
+			continue
+		}
+		switch {
+		case event.Type == packer.EventStep && event.Message == "write_target":
+			steps++
+		case event.Type == packer.EventProvisioner && event.Provisioner == "shell-local":
+			provisioners++
+		case event.Provisioner == "shell-local" && strings.Contains(event.Message, "provisioned"):
+			provisionerOutput++
+		case event.Type == packer.EventArtifact:
+			artifact = event.Artifact
+		}
+	}
+	if steps != 1 || provisioners != 1 || provisionerOutput == 0 {
+		t.Fatalf("missing events of the chocolate build: %s", out)
+	}
+	if artifact == nil || artifact.BuilderID != "packer.file" || artifact.String != "Stored file: chocolate.txt" {
+		t.Fatalf("unexpected artifact event %#v: %s", artifact, out)
+	}
+}
+
 func TestBuildExceptFileCommaFlags(t *testing.T) {
 	c := &BuildCommand{
 		Meta: testMetaFile(t),
@@ -746,6 +973,21 @@ func TestBuildCommand_HCLOnlyExceptOptions(t *testing.T) {
 			[]string{"cherry.txt"},
 			[]string{"vanilla.txt", "chocolate.txt"},
 		},
+		{
+			[]string{`-only=/^my_build\.file\.(chocolate|vanilla)$/`},
+			[]string{"chocolate.txt", "vanilla.txt"},
+			[]string{"cherry.txt"},
+		},
+		{
+			[]string{`-except=/^my_build\./`},
+			[]string{"cherry.txt"},
+			[]string{"chocolate.txt", "vanilla.txt"},
+		},
+		{
+			[]string{"-only=/cherry/", "-only=*vanilla"},
+			[]string{"cherry.txt", "vanilla.txt"},
+			[]string{"chocolate.txt"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -768,8 +1010,8 @@ func TestBuildWithNonExistingBuilder(t *testing.T) {
 
 	defer cleanup()
 
-	if code := c.Run(args); code != 1 {
-		t.Errorf("Expected to find exit code 1, found %d", code)
+	if code := c.Run(args); code != ExitValidation {
+		t.Errorf("Expected to find exit code %d, found %d", ExitValidation, code)
 	}
 	if !fileExists("chocolate.txt") {
 		t.Errorf("Expected to find chocolate.txt")
@@ -954,6 +1196,81 @@ func TestBuildCommand_ParseArgs(t *testing.T) {
 			},
 			0,
 		},
+		{fields{defaultMeta},
+			args{[]string{"-parallel-builds=auto", "file.json"}},
+			&BuildArgs{
+				MetaArgs:           MetaArgs{Path: "file.json"},
+				ParallelBuildsAuto: true,
+				Color:              true,
+			},
+			0,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-parallel-builds=auto", "-parallel-builds=5", "file.json"}},
+			&BuildArgs{
+				MetaArgs:       MetaArgs{Path: "file.json"},
+				ParallelBuilds: 5,
+				Color:          true,
+			},
+			0,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-parallel-cpu=4", "-parallel-memory=8GB", "file.json"}},
+			&BuildArgs{
+				MetaArgs:       MetaArgs{Path: "file.json"},
+				ParallelBuilds: math.MaxInt64,
+				ParallelCPU:    4,
+				ParallelMemory: 8 << 30,
+				Color:          true,
+			},
+			0,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-fail-fast", "file.json"}},
+			&BuildArgs{
+				MetaArgs:       MetaArgs{Path: "file.json"},
+				ParallelBuilds: math.MaxInt64,
+				Color:          true,
+				FailFast:       true,
+			},
+			0,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-fail-fast", "-keep-going", "file.json"}},
+			&BuildArgs{
+				Color:     true,
+				FailFast:  true,
+				KeepGoing: true,
+			},
+			ExitUsage,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-on-error=ask", "file.json"}},
+			&BuildArgs{
+				Color:   true,
+				OnError: "ask",
+			},
+			ExitUsage,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-debug", "file.json"}},
+			&BuildArgs{
+				Color: true,
+				Debug: true,
+			},
+			ExitUsage,
+		},
+		{fields{defaultMeta},
+			args{[]string{"-debug", "-debug-no-pause", "file.json"}},
+			&BuildArgs{
+				MetaArgs:       MetaArgs{Path: "file.json"},
+				ParallelBuilds: math.MaxInt64,
+				Color:          true,
+				Debug:          true,
+				DebugNoPause:   true,
+			},
+			0,
+		},
 		{fields{defaultMeta},
 			args{[]string{"-parallel-builds=1", "-parallel-builds=5", "otherfile.json"}},
 			&BuildArgs{
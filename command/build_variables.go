@@ -0,0 +1,111 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	kvflag "github.com/hashicorp/packer/command/flag-kv"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/packer"
+)
+
+// unsetVariable is a required variable of a config that has no value.
+type unsetVariable struct {
+	Name        string
+	Type        string
+	Description string
+	Sensitive   bool
+}
+
+// unsetVariables returns the required variables of the config of handler that
+// are not set, sorted by name. vars are the variables set for a JSON
+// template.
+func unsetVariables(handler packer.Handler, vars map[string]string) []unsetVariable {
+	var unset []unsetVariable
+	switch cfg := handler.(type) {
+	case *hcl2template.PackerConfig:
+		for name, v := range cfg.InputVariables {
+			if len(v.Values) > 0 {
+				continue
+			}
+			unset = append(unset, unsetVariable{
+				Name:        name,
+				Type:        typeexpr.TypeString(v.Type),
+				Description: v.Description,
+				Sensitive:   v.Sensitive,
+			})
+		}
+	case *CoreWrapper:
+		for name, v := range cfg.Template.Variables {
+			if _, set := vars[name]; !v.Required || set {
+				continue
+			}
+			sensitive := false
+			for _, s := range cfg.Template.SensitiveVariables {
+				sensitive = sensitive || s.Key == name
+			}
+			unset = append(unset, unsetVariable{Name: name, Type: "string", Sensitive: sensitive})
+		}
+	}
+	sort.Slice(unset, func(i, j int) bool { return unset[i].Name < unset[j].Name })
+	return unset
+}
+
+// askUnsetVariables lists the required variables of the config of handler
+// that are not set, then asks for their values one after the other and sets
+// them in cla.Vars. It only asks when the Ui is a terminal, and tells whether
+// a value was set, in which case the config must be loaded again.
+func (c *BuildCommand) askUnsetVariables(handler packer.Handler, cla *MetaArgs) bool {
+	if !c.canAsk() {
+		return false
+	}
+	unset := unsetVariables(handler, cla.Vars)
+	if len(unset) == 0 {
+		return false
+	}
+
+	var b strings.Builder
+	b.WriteString("The following required variables are not set:\n")
+	for _, v := range unset {
+		fmt.Fprintf(&b, "  - %s (%s", v.Name, v.Type)
+		if v.Sensitive {
+			b.WriteString(", sensitive")
+		}
+		b.WriteString(")")
+		if v.Description != "" {
+			fmt.Fprintf(&b, ": %s", v.Description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Enter their values, they are read like -var values. Leave a value empty to keep the variable unset.")
+	c.Ui.Say(b.String())
+
+	set := false
+	for _, v := range unset {
+		for {
+			ask := c.Ui.Ask
+			if v.Sensitive {
+				ask = c.askSensitive
+			}
+			value, err := ask(fmt.Sprintf("%s:", v.Name))
+			if err != nil {
+				log.Printf("[WARN] could not ask for the value of variable %q: %s", v.Name, err)
+				return set
+			}
+			if value == "" {
+				break
+			}
+			// the value is read like a -var one, @file included
+			if err := (*kvflag.Flag)(&cla.Vars).Set(v.Name + "=" + value); err != nil {
+				c.Ui.Error(err.Error())
+				continue
+			}
+			set = true
+			break
+		}
+	}
+	return set
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// answersTTY answers the prompts with its answers, in order, then with empty
+// lines.
+type answersTTY struct {
+	answers []string
+}
+
+func (*answersTTY) Close() error { return nil }
+
+func (t *answersTTY) ReadString() (string, error) {
+	if len(t.answers) == 0 {
+		return "\n", nil
+	}
+	answer := t.answers[0]
+	t.answers = t.answers[1:]
+	return answer + "\n", nil
+}
+
+func TestBuild_askUnsetVariables(t *testing.T) {
+	for _, template := range []string{"fruit_builder.pkr.hcl", "fruit_builder.json"} {
+		t.Run(template, func(t *testing.T) {
+			defer cleanup()
+
+			var out, errOut bytes.Buffer
+			c := &BuildCommand{
+				Meta: testMetaFile(t),
+			}
+			c.Ui = &packersdk.BasicUi{
+				Writer:      &out,
+				ErrorWriter: &errOut,
+				TTY:         &answersTTY{answers: []string{"banana"}},
+			}
+
+			if code := c.Run([]string{filepath.Join(testFixture("var-arg"), template)}); code != 0 {
+				t.Fatalf("bad exit code %d, output:\n%s\n%s", code, out.String(), errOut.String())
+			}
+			if !strings.Contains(out.String(), "The following required variables are not set:\n  - fruit (string)") {
+				t.Errorf("expected the unset variables to be listed, got:\n%s", out.String())
+			}
+			if !fileExists("banana.txt") {
+				t.Error("expected banana.txt to be created with the value that was asked for")
+			}
+		})
+	}
+}
+
+func TestBuild_askUnsetVariables_emptyAnswer(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	c.Ui = &packersdk.BasicUi{
+		Writer:      &bytes.Buffer{},
+		ErrorWriter: &bytes.Buffer{},
+		TTY:         &answersTTY{},
+	}
+
+	if code := c.Run([]string{filepath.Join(testFixture("var-arg"), "fruit_builder.pkr.hcl")}); code != ExitValidation {
+		t.Fatalf("expected exit code %d when the variable is left unset, got %d", ExitValidation, code)
+	}
+}
+
+func TestBuild_askUnsetVariables_fileValue(t *testing.T) {
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "packer-ask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	valueFile := filepath.Join(dir, "fruit")
+	if err := ioutil.WriteFile(valueFile, []byte("cherry\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	c.Ui = &packersdk.BasicUi{
+		Writer:      &out,
+		ErrorWriter: &errOut,
+		TTY:         &answersTTY{answers: []string{"@" + filepath.Join(dir, "missing"), "@" + valueFile}},
+	}
+
+	if code := c.Run([]string{filepath.Join(testFixture("var-arg"), "fruit_builder.pkr.hcl")}); code != 0 {
+		t.Fatalf("bad exit code %d, output:\n%s\n%s", code, out.String(), errOut.String())
+	}
+	if !strings.Contains(errOut.String(), "reading the value of fruit") {
+		t.Errorf("expected the missing file to be reported, got:\n%s", errOut.String())
+	}
+	if !fileExists("cherry.txt") {
+		t.Error("expected cherry.txt to be created with the value read from the file")
+	}
+}
@@ -0,0 +1,298 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+	"golang.org/x/sync/semaphore"
+)
+
+// workspaceSuffix marks a path as a tree of templates to build, like in
+// `packer build ./images/...`.
+const workspaceSuffix = "..."
+
+// workspaceRoot tells whether path designates a workspace and returns the
+// folder its templates are searched in.
+func workspaceRoot(path string) (string, bool) {
+	path = filepath.ToSlash(path)
+	if path != workspaceSuffix && !strings.HasSuffix(path, "/"+workspaceSuffix) {
+		return "", false
+	}
+	root := strings.TrimSuffix(path, workspaceSuffix)
+	if root == "" {
+		root = "."
+	}
+	return filepath.Clean(filepath.FromSlash(root)), true
+}
+
+// workspaceTemplates returns every folder under root holding HCL2 template
+// files, in lexical order. Hidden folders are skipped.
+func workspaceTemplates(root string) ([]string, error) {
+	found := map[string]bool{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".pkr.hcl") && !strings.HasSuffix(path, ".pkr.json") {
+			return nil
+		}
+		found[filepath.Dir(path)] = true
+		return nil
+	})
+	templates := make([]string, 0, len(found))
+	for dir := range found {
+		templates = append(templates, dir)
+	}
+	sort.Strings(templates)
+	return templates, err
+}
+
+// templateStatus is the outcome of the builds of a template of a workspace.
+type templateStatus string
+
+const (
+	templateSucceeded templateStatus = "succeeded"
+	templateFailed    templateStatus = "failed"
+	// templatePartiallyFailed is a template of which some builds failed
+	// while others succeeded.
+	templatePartiallyFailed templateStatus = "partially-failed"
+	// templateInvalid is a template that could not be parsed or of which
+	// some builds could not be prepared.
+	templateInvalid    templateStatus = "invalid"
+	templateCancelled  templateStatus = "cancelled"
+	templateNotStarted templateStatus = "not-started"
+)
+
+// templateStatusFromExitCode maps the exit code of the builds of a template
+// to its status.
+func templateStatusFromExitCode(code int) templateStatus {
+	switch code {
+	case ExitSuccess:
+		return templateSucceeded
+	case ExitPartialFailure:
+		return templatePartiallyFailed
+	case ExitValidation:
+		return templateInvalid
+	case ExitCancelled:
+		return templateCancelled
+	}
+	return templateFailed
+}
+
+// templateSummary is a line of the workspace summary, it is also the payload
+// of the machine-readable `workspace-summary` event.
+type templateSummary struct {
+	Path     string         `json:"path"`
+	Status   templateStatus `json:"status"`
+	Duration string         `json:"duration"`
+	Builds   []buildSummary `json:"builds"`
+}
+
+// prefixedUi prefixes every line of output with the template it comes from,
+// so that the output of templates built at the same time can be told apart.
+type prefixedUi struct {
+	Prefix string
+	Ui     packersdk.Ui
+}
+
+var _ packersdk.Ui = new(prefixedUi)
+
+func (u *prefixedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(u.prefixLines(query))
+}
+
+func (u *prefixedUi) Say(message string) {
+	u.Ui.Say(u.prefixLines(message))
+}
+
+func (u *prefixedUi) Message(message string) {
+	u.Ui.Message(u.prefixLines(message))
+}
+
+func (u *prefixedUi) Error(message string) {
+	u.Ui.Error(u.prefixLines(message))
+}
+
+func (u *prefixedUi) Machine(t string, args ...string) {
+	u.Ui.Machine(t, args...)
+}
+
+func (u *prefixedUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return u.Ui.TrackProgress(src, currentSize, totalSize, stream)
+}
+
+func (u *prefixedUi) prefixLines(message string) string {
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("[%s] %s", u.Prefix, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runWorkspace builds every template found under root. Templates share the
+// var files and variables of cla, and their builds are scheduled together so
+// that -parallel-builds, -parallel-cpu and -parallel-memory apply to the whole
+// workspace.
+func (c *BuildCommand) runWorkspace(buildCtx context.Context, root string, cla *BuildArgs) int {
+	if cla.HistoryFile != "" {
+		c.Ui.Error("-history-file cannot be used when building a workspace: builds " +
+			"of different templates can have the same name.")
+		return ExitUsage
+	}
+	paths, err := workspaceTemplates(root)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to list the templates of %s: %s", root, err))
+		return ExitError
+	}
+	if len(paths) == 0 {
+		c.Ui.Error(fmt.Sprintf("No templates found in %s.", root))
+		return ExitError
+	}
+	c.Ui.Say(fmt.Sprintf("Building %d template(s) from %s: %s", len(paths), root, strings.Join(paths, ", ")))
+
+	// With -fail-fast, the first failing template cancels the other ones
+	// through runCtx.
+	runCtx, cancelRun := context.WithCancel(buildCtx)
+	defer cancelRun()
+
+	scheduler := newBuildScheduler(cla)
+	var templates *semaphore.Weighted
+	if cla.ParallelTemplates > 0 {
+		templates = semaphore.NewWeighted(cla.ParallelTemplates)
+	}
+
+	summaries := make([]templateSummary, len(paths))
+	for i, path := range paths {
+		summaries[i] = templateSummary{Path: path, Status: templateNotStarted, Builds: []buildSummary{}}
+	}
+
+	workspaceStart := time.Now()
+	var wg sync.WaitGroup
+	for i := range paths {
+		if runCtx.Err() != nil {
+			log.Println("Not going to start any more templates.")
+			break
+		}
+		if templates != nil {
+			if err := templates.Acquire(runCtx, 1); err != nil {
+				break
+			}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if templates != nil {
+				defer templates.Release(1)
+			}
+
+			tc := &BuildCommand{Meta: c.Meta}
+			if !packer.IsStructuredUi(c.Ui) {
+				tc.Ui = &prefixedUi{Prefix: paths[i], Ui: c.Ui}
+			}
+			tcla := *cla
+			tcla.Path = paths[i]
+
+			log.Printf("Starting builds of template %s", paths[i])
+			start := time.Now()
+			ret, builds := tc.runBuilds(runCtx, &tcla, scheduler)
+
+			summaries[i].Status = templateStatusFromExitCode(ret)
+			summaries[i].Duration = time.Since(start).Round(time.Second).String()
+			if builds != nil {
+				summaries[i].Builds = builds
+			}
+			if ret != ExitSuccess && cla.FailFast && runCtx.Err() == nil {
+				log.Printf("Template %s failed and -fail-fast is set, cancelling the other templates.", paths[i])
+				cancelRun()
+			}
+		}(i)
+	}
+	wg.Wait()
+	log.Printf("Workspace built in %s", time.Since(workspaceStart))
+
+	c.reportWorkspace(summaries)
+
+	if buildCtx.Err() != nil {
+		return ExitCancelled
+	}
+	counts := map[templateStatus]int{}
+	for _, s := range summaries {
+		counts[s.Status]++
+	}
+	switch {
+	case counts[templateFailed] > 0 && counts[templateSucceeded]+counts[templatePartiallyFailed] == 0:
+		return ExitError
+	case counts[templateFailed]+counts[templatePartiallyFailed] > 0:
+		return ExitPartialFailure
+	case counts[templateInvalid] > 0:
+		return ExitValidation
+	case counts[templateSucceeded] != len(summaries):
+		// templates were cancelled or not started
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+// reportWorkspace prints a summary table of all templates. In
+// machine-readable mode every template also gets a `workspace-summary`
+// message with the fields of its line; the json UI gets the whole table as a
+// single `workspace-summary` event instead.
+func (c *BuildCommand) reportWorkspace(summaries []templateSummary) {
+	_, events := c.Ui.(packer.EventUi)
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TEMPLATE\tSTATUS\tDURATION\tSUCCEEDED\tFAILED")
+	for _, s := range summaries {
+		succeeded, failed := 0, 0
+		for _, b := range s.Builds {
+			switch buildStatus(b.Status) {
+			case buildSucceeded:
+				succeeded++
+			case buildFailed:
+				failed++
+			}
+		}
+		duration := s.Duration
+		if duration == "" {
+			duration = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", s.Path, s.Status, duration, succeeded, failed)
+		if !events {
+			ui := &packer.TargetedUI{
+				Target: s.Path,
+				Ui:     c.Ui,
+			}
+			ui.Machine(packer.EventWorkspaceSummary, string(s.Status), s.Duration,
+				strconv.Itoa(succeeded), strconv.Itoa(failed))
+		}
+	}
+	w.Flush()
+
+	c.Ui.Say("\n==> Workspace summary:")
+	c.Ui.Say(strings.TrimSuffix(table.String(), "\n"))
+
+	if payload, err := json.Marshal(summaries); err == nil {
+		packer.EmitEvent(c.Ui, packer.UiEvent{Type: packer.EventWorkspaceSummary, Summary: payload})
+	}
+}
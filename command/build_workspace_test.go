@@ -0,0 +1,132 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer/packer"
+)
+
+func Test_workspaceRoot(t *testing.T) {
+	tests := []struct {
+		path     string
+		wantRoot string
+		wantOk   bool
+	}{
+		{"./images/...", "images", true},
+		{"images/...", "images", true},
+		{"...", ".", true},
+		{"./...", ".", true},
+		{"/abs/images/...", "/abs/images", true},
+		{"images", "", false},
+		{"images/build.pkr.hcl", "", false},
+		{"images...", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			root, ok := workspaceRoot(tt.path)
+			if ok != tt.wantOk || root != filepath.FromSlash(tt.wantRoot) {
+				t.Fatalf("workspaceRoot(%q) = %q, %t; want %q, %t", tt.path, root, ok, tt.wantRoot, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_workspaceTemplates(t *testing.T) {
+	templates, err := workspaceTemplates(testFixture("workspace"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		testFixture("workspace", "fruits"),
+		testFixture("workspace", "vegetables", "tomato"),
+	}
+	if diff := cmp.Diff(expected, templates); diff != "" {
+		t.Fatalf("unexpected templates: %s", diff)
+	}
+}
+
+func TestBuild_workspace(t *testing.T) {
+	defer cleanup()
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		"-var-file", testFixture("workspace", "shared.pkrvars.hcl"),
+		"-parallel-templates=1",
+		testFixture("workspace") + "/...",
+	}
+	if code := c.Run(args); code != ExitSuccess {
+		fatalCommand(t, c.Meta)
+	}
+
+	for _, file := range []string{"apple.txt", "tomato.txt"} {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile: %v", err)
+		}
+		if string(content) != "sweet" {
+			t.Errorf("content of %s is %q, expected the value of the shared var file", file, content)
+		}
+	}
+}
+
+func TestBuild_workspaceInvalidTemplate(t *testing.T) {
+	defer cleanup()
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		testFixture("workspace-invalid") + "/...",
+	}
+	if code := c.Run(args); code != ExitValidation {
+		t.Errorf("expected exit code %d, got %d", ExitValidation, code)
+	}
+	if !fileExists("banana.txt") {
+		t.Error("expected the valid template to be built")
+	}
+}
+
+func TestBuild_workspaceHistoryFile(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		"-history-file", "history.json",
+		testFixture("workspace") + "/...",
+	}
+	if code := c.Run(args); code != ExitUsage {
+		t.Errorf("expected exit code %d, got %d", ExitUsage, code)
+	}
+}
+
+func TestBuildCommand_reportWorkspace_machine(t *testing.T) {
+	summaries := []templateSummary{{
+		Path:     "images/base",
+		Status:   templateSucceeded,
+		Duration: "1s",
+		Builds:   []buildSummary{{Name: "file.base", Status: string(buildSucceeded)}},
+	}}
+
+	// the json UI gets the summary as JSON
+	var js bytes.Buffer
+	c := &BuildCommand{Meta: Meta{Ui: &packer.JSONUi{Writer: &js}}}
+	c.reportWorkspace(summaries)
+	if !strings.Contains(js.String(), `"type":"workspace-summary","summary":[{"path":"images/base"`) {
+		t.Fatalf("no workspace-summary event in:\n%s", js.String())
+	}
+
+	// the machine-readable UI gets a line of fields per template
+	var out bytes.Buffer
+	c = &BuildCommand{Meta: Meta{Ui: &packer.MachineReadableUi{Writer: &out}}}
+	c.reportWorkspace(summaries)
+	if !strings.Contains(out.String(), ",images/base,workspace-summary,succeeded,1s,1,0\n") {
+		t.Fatalf("no workspace-summary line of the template in:\n%s", out.String())
+	}
+}
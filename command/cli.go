@@ -1,12 +1,19 @@
 package command
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer/command/enumflag"
 	kvflag "github.com/hashicorp/packer/command/flag-kv"
 	sliceflag "github.com/hashicorp/packer/command/flag-slice"
+	"github.com/hashicorp/packer/lint"
 )
 
 //go:generate enumer -type configType -trimprefix ConfigType -transform snake
@@ -34,12 +41,21 @@ func (ma *MetaArgs) GetConfigType() (configType, error) {
 	}
 	name := ma.Path
 	if name == "-" {
-		// TODO(azr): To allow piping HCL2 confs (when args is "-"), we probably
-		// will need to add a setting that says "this is an HCL config".
-		return ma.ConfigType, nil
+		if ma.ConfigType == ConfigTypeHCL2 {
+			return ConfigTypeHCL2, nil
+		}
+		src, err := ma.readStdin()
+		if err != nil {
+			return ma.ConfigType, err
+		}
+		if isJSONTemplateSource(src) {
+			return ConfigTypeJSON, nil
+		}
+		return ConfigTypeHCL2, nil
 	}
 	if strings.HasSuffix(name, ".pkr.hcl") ||
-		strings.HasSuffix(name, ".pkr.json") {
+		strings.HasSuffix(name, ".pkr.json") ||
+		strings.HasSuffix(name, ".pkr.yaml") {
 		return ConfigTypeHCL2, nil
 	}
 	isDir, err := isDir(name)
@@ -49,6 +65,40 @@ func (ma *MetaArgs) GetConfigType() (configType, error) {
 	return ma.ConfigType, err
 }
 
+// readStdin returns the config read from stdin, when Path is "-". Stdin is
+// only read once, as its type is sniffed before it is parsed.
+func (ma *MetaArgs) readStdin() ([]byte, error) {
+	if ma.Stdin == nil {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading config from stdin: %w", err)
+		}
+		ma.Stdin = src
+	}
+	return ma.Stdin, nil
+}
+
+// isJSONTemplateSource tells whether src is a legacy JSON template: a JSON
+// object with builders. Other JSON objects are HCL2 configs in the JSON
+// syntax.
+func isJSONTemplateSource(src []byte) bool {
+	var tpl map[string]json.RawMessage
+	if err := json.Unmarshal(src, &tpl); err != nil {
+		return false
+	}
+	_, found := tpl["builders"]
+	return found
+}
+
+// stdinFilename is the name of the HCL2 config read from stdin in
+// diagnostics. Its suffix tells its syntax.
+func (ma *MetaArgs) stdinFilename() string {
+	if json.Valid(bytes.TrimSpace(ma.Stdin)) {
+		return "<stdin>.pkr.json"
+	}
+	return "<stdin>.pkr.hcl"
+}
+
 // NewMetaArgs parses cli args and put possible values
 func (ma *MetaArgs) AddFlagSets(fs *flag.FlagSet) {
 	fs.Var((*sliceflag.StringFlag)(&ma.Only), "only", "")
@@ -56,6 +106,7 @@ func (ma *MetaArgs) AddFlagSets(fs *flag.FlagSet) {
 	fs.Var((*kvflag.Flag)(&ma.Vars), "var", "")
 	fs.Var((*kvflag.StringSlice)(&ma.VarFiles), "var-file", "")
 	fs.Var(&ma.ConfigType, "config-type", "set to 'hcl2' to run in hcl2 mode when no file is passed.")
+	fs.StringVar(&ma.RootDir, "root-dir", "", "folder a config read from stdin is considered to be in.")
 }
 
 // MetaArgs defines commonalities between all comands
@@ -68,16 +119,48 @@ type MetaArgs struct {
 	VarFiles     []string
 	// set to "hcl2" to force hcl2 mode
 	ConfigType configType
+	// RootDir is the folder a config read from stdin is considered to be in:
+	// the value of path.root, or of template_dir for JSON templates. It
+	// defaults to the working directory.
+	RootDir string
+
+	// WarnAsError turns warnings into errors, set by the -warn-as-error flag
+	// of the commands supporting it.
+	WarnAsError bool
+	// FailOnPluginConflict makes an error of a plugin found at several
+	// paths, set by the -fail-on-plugin-conflict flag of the commands
+	// supporting it.
+	FailOnPluginConflict bool
+
+	// Stdin is the config read from stdin when Path is "-". It is only read
+	// once, see readStdin.
+	Stdin []byte
 }
 
 func (ba *BuildArgs) AddFlagSets(flags *flag.FlagSet) {
 	flags.BoolVar(&ba.Color, "color", true, "")
 	flags.BoolVar(&ba.Debug, "debug", false, "")
+	flags.BoolVar(&ba.DebugNoPause, "debug-no-pause", false, "")
+	flags.BoolVar(&ba.DryRun, "dry-run", false, "")
+	flags.BoolVar(&ba.FailFast, "fail-fast", false, "")
+	flags.BoolVar(&ba.KeepGoing, "keep-going", false, "")
+	flags.StringVar(&ba.HistoryFile, "history-file", "", "")
+	flags.DurationVar(&ba.Heartbeat, "heartbeat", 0, "")
 	flags.BoolVar(&ba.Force, "force", false, "")
+	flags.BoolVar(&ba.SkipCreateArtifact, "skip-create-artifact", false, "")
 	flags.BoolVar(&ba.TimestampUi, "timestamp-ui", false, "")
 	flags.BoolVar(&ba.MachineReadable, "machine-readable", false, "")
+	flags.BoolVar(&ba.JSON, "json", false, "")
+	flags.BoolVar(&ba.Resume, "resume", false, "")
+	flags.IntVar(&ba.Retry, "retry", 0, "")
+	flags.BoolVar(&ba.WarnAsError, "warn-as-error", false, "")
+	flags.BoolVar(&ba.FailOnPluginConflict, "fail-on-plugin-conflict", false, "")
 
-	flags.Int64Var(&ba.ParallelBuilds, "parallel-builds", 0, "")
+	flags.Var(&parallelBuildsFlag{ba}, "parallel-builds", "")
+	flags.Var((*typeLimitsFlag)(&ba.ParallelBuildsByType), "parallel-builds-by-type", "")
+	flags.Int64Var(&ba.ParallelCPU, "parallel-cpu", 0, "")
+	flags.Var((*byteSizeFlag)(&ba.ParallelMemory), "parallel-memory", "")
+	flags.Int64Var(&ba.ParallelTemplates, "parallel-templates", 0, "")
 
 	flagOnError := enumflag.New(&ba.OnError, "cleanup", "abort", "ask", "run-cleanup-provisioner")
 	flags.Var(flagOnError, "on-error", "")
@@ -89,12 +172,71 @@ func (ba *BuildArgs) AddFlagSets(flags *flag.FlagSet) {
 type BuildArgs struct {
 	MetaArgs
 	Color, Debug, Force, TimestampUi, MachineReadable bool
-	ParallelBuilds                                    int64
-	OnError                                           string
+	// DebugNoPause answers the debug mode pauses, so that -debug can be used
+	// without a terminal.
+	DebugNoPause bool
+	// DryRun stops the build command once builds are prepared and prints
+	// what would be built.
+	DryRun bool
+	// FailFast cancels all builds as soon as one fails, KeepGoing - the
+	// default - runs them all to completion.
+	FailFast, KeepGoing bool
+	// HistoryFile is where fingerprints of successful builds are recorded
+	// for `packer plan`.
+	HistoryFile    string
+	ParallelBuilds int64
+	// ParallelBuildsAuto is set when -parallel-builds=auto was passed, the
+	// limit is then computed from the host resources.
+	ParallelBuildsAuto bool
+	// ParallelBuildsByType are the most builds running at the same time by
+	// builder type or plugin name, over the limits of the template.
+	ParallelBuildsByType map[string]int64
+	// ParallelCPU and ParallelMemory are the host budget parallel builds are
+	// packed into, by default all CPUs and the available memory.
+	ParallelCPU    int64
+	ParallelMemory uint64
+	// ParallelTemplates is the number of templates of a workspace built at
+	// the same time, 0 means no limit.
+	ParallelTemplates int64
+	OnError           string
+	// SkipCreateArtifact asks the builders supporting it to run their
+	// provisioners without creating their artifact.
+	SkipCreateArtifact bool
+	// Heartbeat is how long a build can stay silent before a heartbeat
+	// machine-readable message is output, 0 disables them.
+	Heartbeat time.Duration
+	// JSON outputs the events of the builds as lines of JSON, like the json
+	// UI.
+	JSON bool
+	// Resume saves the state of the builds as they run, and resumes the
+	// builds interrupted in the previous run.
+	Resume bool
+	// Retry is how many times a failed build is run again from scratch,
+	// over the attempts of the retry block of its build, when RetrySet.
+	Retry int
+	// RetrySet tells that -retry was passed, an explicit -retry=0 turning
+	// the retry blocks off.
+	RetrySet bool
+}
+
+func (pa *PlanArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.StringVar(&pa.HistoryFile, "history-file", "", "")
+	flags.BoolVar(&pa.DetailedExitCode, "detailed-exitcode", false, "")
+
+	pa.MetaArgs.AddFlagSets(flags)
+}
+
+// PlanArgs represents a parsed cli line for a `packer plan`
+type PlanArgs struct {
+	MetaArgs
+	HistoryFile      string
+	DetailedExitCode bool
 }
 
 func (ia *InitArgs) AddFlagSets(flags *flag.FlagSet) {
 	flags.BoolVar(&ia.Upgrade, "upgrade", false, "upgrade any present plugin to the highest allowed version.")
+	flags.BoolVar(&ia.Infer, "infer", false, "add the plugins providing the components used in the config to its required_plugins block.")
+	flags.StringVar(&ia.RegistryURL, "registry-url", os.Getenv(registryURLEnvVar), "address of the registry to get plugins from, instead of GitHub.")
 
 	ia.MetaArgs.AddFlagSets(flags)
 }
@@ -102,12 +244,22 @@ func (ia *InitArgs) AddFlagSets(flags *flag.FlagSet) {
 // InitArgs represents a parsed cli line for a `packer build`
 type InitArgs struct {
 	MetaArgs
-	Upgrade bool
+	Upgrade     bool
+	Infer       bool
+	RegistryURL string
+}
+
+func (va *ConsoleArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.BoolVar(&va.SkipDatasources, "skip-datasources", false, "do not execute the data sources, their values are unknown")
+	va.MetaArgs.AddFlagSets(flags)
 }
 
 // ConsoleArgs represents a parsed cli line for a `packer console`
 type ConsoleArgs struct {
 	MetaArgs
+	// SkipDatasources does not execute the data sources of HCL2 configs,
+	// their values are then unknown.
+	SkipDatasources bool
 }
 
 func (fa *FixArgs) AddFlagSets(flags *flag.FlagSet) {
@@ -124,6 +276,11 @@ type FixArgs struct {
 
 func (va *ValidateArgs) AddFlagSets(flags *flag.FlagSet) {
 	flags.BoolVar(&va.SyntaxOnly, "syntax-only", false, "check syntax only")
+	flags.BoolVar(&va.SchemaOnly, "schema-only", false, "check the config against the plugin schemas without preparing the plugins")
+	flags.BoolVar(&va.WarnAsError, "warn-as-error", false, "turn warnings into errors")
+	flags.BoolVar(&va.FailOnPluginConflict, "fail-on-plugin-conflict", false, "fail when a plugin is installed several times")
+	flags.BoolVar(&va.Strict, "strict", false, "report unused variables, locals and data sources as errors")
+	flags.StringVar(&va.Output, "output", "text", "output format: text or json")
 
 	va.MetaArgs.AddFlagSets(flags)
 }
@@ -132,19 +289,43 @@ func (va *ValidateArgs) AddFlagSets(flags *flag.FlagSet) {
 type ValidateArgs struct {
 	MetaArgs
 	SyntaxOnly bool
+	// SchemaOnly checks the settings of the plugins against their schema
+	// instead of preparing them, so that no credentials or network are
+	// needed.
+	SchemaOnly bool
+	// Strict reports the unused declarations of HCL2 configs as errors
+	// instead of warnings.
+	Strict bool
+	// Output is the format of the diagnostics: "text" or "json".
+	Output string
 }
 
 func (va *InspectArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.BoolVar(&va.ShowSensitive, "show-sensitive", false, "Display the values of sensitive variables")
+	flags.StringVar(&va.Output, "output", "text", "output format: text or json")
+	flags.BoolVar(&va.Resolve, "resolve", false, "Display the values of the variables and where they were set from")
 	va.MetaArgs.AddFlagSets(flags)
 }
 
 // InspectArgs represents a parsed cli line for a `packer inspect`
 type InspectArgs struct {
 	MetaArgs
+	ShowSensitive bool
+	// Output is the format of the inspection: "text" or "json".
+	Output string
+	// Resolve displays the values of the variables once set, instead of the
+	// components of the template.
+	Resolve bool
 }
 
 func (va *HCL2UpgradeArgs) AddFlagSets(flags *flag.FlagSet) {
 	flags.StringVar(&va.OutputFile, "output-file", "", "File where to put the hcl2 generated config. Defaults to JSON_TEMPLATE.pkr.hcl")
+	flags.BoolVar(&va.SplitFiles, "split-files", false, "Write the variables, sources and build to separate files")
+	flags.StringVar(&va.OutputDir, "output-dir", "", "With -split-files, folder where to put the files. Defaults to the folder of JSON_TEMPLATE")
+	flags.BoolVar(&va.Check, "check", false, "Show the diff with the existing HCL2 files instead of writing them")
+	flags.BoolVar(&va.KeepBuilderNames, "keep-builder-names", false, "Name sources after their builder instead of autogenerated_N")
+	flags.StringVar(&va.NamesFile, "names-file", "", "File where to write the mapping of builder names to source addresses")
+	flags.BoolVar(&va.WithValidation, "with-validation", false, "Add validation blocks to the variables that must be set")
 
 	va.MetaArgs.AddFlagSets(flags)
 }
@@ -153,12 +334,63 @@ func (va *HCL2UpgradeArgs) AddFlagSets(flags *flag.FlagSet) {
 type HCL2UpgradeArgs struct {
 	MetaArgs
 	OutputFile string
+	// SplitFiles writes variables.pkr.hcl, sources.pkr.hcl and build.pkr.hcl
+	// to OutputDir instead of a single OutputFile.
+	SplitFiles bool
+	OutputDir  string
+	// Paths are the folders, glob patterns or templates to upgrade at once.
+	// When set, Path and OutputFile are not used.
+	Paths []string
+	// Check compares the HCL2 files with what they would be upgraded to,
+	// without writing anything. An OutputFile of "-" prints the HCL2 config
+	// instead of writing it.
+	Check bool
+	// KeepBuilderNames names the sources of unnamed builders after their
+	// type, as JSON does, instead of autogenerated_N.
+	KeepBuilderNames bool
+	// NamesFile is where to write the addresses of the sources of the
+	// builders, by builder name.
+	NamesFile string
+	// WithValidation adds a validation block to the variables that must be
+	// set: the required ones and the ones defaulting to an environment
+	// variable.
+	WithValidation bool
+}
+
+func (va *HCL2DowngradeArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.StringVar(&va.OutputFile, "output-file", "", "write the JSON template to this file")
+	flags.BoolVar(&va.WarnAsError, "warn-as-error", false, "fail when the config cannot be fully represented in JSON")
+
+	va.MetaArgs.AddFlagSets(flags)
+}
+
+// HCL2DowngradeArgs represents a parsed cli line for `packer hcl2_downgrade`
+type HCL2DowngradeArgs struct {
+	MetaArgs
+	OutputFile string
+}
+
+func (va *LintArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.StringVar(&va.Config, "config", "", "the rule settings file, "+lint.SettingsFile+" of the config folder by default")
+	flags.StringVar(&va.Format, "format", "text", "output format: text, json or sarif")
+	flags.BoolVar(&va.WarnAsError, "warn-as-error", false, "report all issues as errors")
+
+	va.MetaArgs.AddFlagSets(flags)
+}
+
+// LintArgs represents a parsed cli line for `packer lint`
+type LintArgs struct {
+	MetaArgs
+	Config string
+	Format string
 }
 
 func (va *FormatArgs) AddFlagSets(flags *flag.FlagSet) {
 	flags.BoolVar(&va.Check, "check", false, "check if the input is formatted")
 	flags.BoolVar(&va.Diff, "diff", false, "display the diff of formatting changes")
 	flags.BoolVar(&va.Write, "write", true, "overwrite source files instead of writing to stdout")
+	flags.BoolVar(&va.Recursive, "recursive", false, "also format the files of the subdirectories")
+	flags.BoolVar(&va.SortAttributes, "sort-attributes", false, "sort the attributes of blocks by name")
 
 	va.MetaArgs.AddFlagSets(flags)
 }
@@ -167,4 +399,20 @@ func (va *FormatArgs) AddFlagSets(flags *flag.FlagSet) {
 type FormatArgs struct {
 	MetaArgs
 	Check, Diff, Write bool
+	Recursive          bool
+	SortAttributes     bool
+}
+
+func (va *ConvertArgs) AddFlagSets(flags *flag.FlagSet) {
+	flags.StringVar(&va.To, "to", "", "syntax to convert to: hcl or json")
+	flags.StringVar(&va.OutputFile, "output-file", "", "write the converted config to this file")
+
+	va.MetaArgs.AddFlagSets(flags)
+}
+
+// ConvertArgs represents a parsed cli line for `packer convert`
+type ConvertArgs struct {
+	MetaArgs
+	To         string
+	OutputFile string
 }
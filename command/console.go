@@ -5,9 +5,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"unicode"
 
 	"github.com/chzyer/readline"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"github.com/hashicorp/packer/helper/wrappedreadline"
 	"github.com/hashicorp/packer/helper/wrappedstreams"
 	"github.com/hashicorp/packer/packer"
@@ -44,7 +51,7 @@ func (c *ConsoleCommand) ParseArgs(args []string) (*ConsoleArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
@@ -60,13 +67,27 @@ func (c *ConsoleCommand) RunContext(ctx context.Context, cla *ConsoleArgs) int {
 		return ret
 	}
 
-	_ = packerStarter.Initialize(packer.InitializeOptions{})
+	// The console still starts when the variables, locals or data sources
+	// cannot all be evaluated, their values are then unknown. The
+	// diagnostics tell why.
+	diags := packerStarter.Initialize(packer.InitializeOptions{
+		SkipDatasourcesExecution: cla.SkipDatasources,
+		Context:                  ctx,
+	})
 
 	// Determine if stdin is a pipe. If so, we evaluate directly.
 	if c.StdinPiped() {
+		// only the result is output when piped
+		for _, diag := range diags {
+			log.Printf("[WARN] %s", diag.Error())
+		}
 		return c.modePiped(packerStarter)
 	}
 
+	if len(diags) > 0 {
+		writeDiags(c.Ui, nil, diags)
+	}
+
 	return c.modeInteractive(packerStarter)
 }
 
@@ -79,8 +100,17 @@ Usage: packer console [options] [TEMPLATE]
   variables defined therein into its context to be referenced during
   interpolation.
 
+  TEMPLATE can be a folder of HCL2 files: its variables, locals and data
+  sources are evaluated, to be referenced as var.foo, local.bar and
+  data.TYPE.NAME.ATTRIBUTE.
+
 Options:
+  -skip-datasources      Do not execute the data sources of an HCL2 template,
+                         for example when their credentials are not
+                         available. Their values, and the locals using them,
+                         are unknown.
   -var 'key=value'       Variable for templates, can be used multiple times.
+                         A value of @FILE is read from FILE.
   -var-file=path         JSON or HCL2 file containing user variables.
 `
 
@@ -97,8 +127,9 @@ func (*ConsoleCommand) AutocompleteArgs() complete.Predictor {
 
 func (*ConsoleCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-var":      complete.PredictNothing,
-		"-var-file": complete.PredictNothing,
+		"-skip-datasources": complete.PredictNothing,
+		"-var":              complete.PredictNothing,
+		"-var-file":         complete.PredictNothing,
 	}
 }
 
@@ -106,14 +137,25 @@ func (c *ConsoleCommand) modePiped(cfg packer.Evaluator) int {
 	var lastResult string
 	scanner := bufio.NewScanner(wrappedstreams.Stdin())
 	ret := 0
-	for scanner.Scan() {
-		result, _, diags := cfg.EvaluateExpression(strings.TrimSpace(scanner.Text()))
+	var lines []string
+	evaluate := func() {
+		result, _, diags := cfg.EvaluateExpression(strings.TrimSpace(strings.Join(lines, "\n")))
+		lines = nil
 		if len(diags) > 0 {
 			ret = writeDiags(c.Ui, nil, diags)
 		}
 		// Store the last result
 		lastResult = result
 	}
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if !incompleteExpression(lines) {
+			evaluate()
+		}
+	}
+	if len(lines) > 0 {
+		evaluate()
+	}
 
 	// Output the final result
 	c.Ui.Message(lastResult)
@@ -122,31 +164,50 @@ func (c *ConsoleCommand) modePiped(cfg packer.Evaluator) int {
 
 func (c *ConsoleCommand) modeInteractive(cfg packer.Evaluator) int {
 	// Setup the UI so we can output directly to stdout
-	l, err := readline.NewEx(wrappedreadline.Override(&readline.Config{
+	rlConfig := &readline.Config{
 		Prompt:            "> ",
 		InterruptPrompt:   "^C",
 		EOFPrompt:         "exit",
+		HistoryFile:       consoleHistoryFile(),
 		HistorySearchFold: true,
-	}))
+	}
+	if completer, ok := cfg.(packer.ConsoleCompleter); ok {
+		rlConfig.AutoComplete = &consoleAutoCompleter{names: completer.CompletionNames()}
+	}
+	l, err := readline.NewEx(wrappedreadline.Override(rlConfig))
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Error initializing console: %s",
 			err))
 		return 1
 	}
+	defer l.Close()
+
+	// lines are the lines of an expression spanning several lines, read
+	// until its brackets are closed.
+	var lines []string
 	for {
 		// Read a line
 		line, err := l.Readline()
 		if err == readline.ErrInterrupt {
-			if len(line) == 0 {
+			if len(line) == 0 && len(lines) == 0 {
 				break
-			} else {
-				continue
 			}
+			lines = nil
+			l.SetPrompt("> ")
+			continue
 		} else if err == io.EOF {
 			break
 		}
-		out, exit, diags := cfg.EvaluateExpression(line)
+		lines = append(lines, line)
+		if incompleteExpression(lines) {
+			l.SetPrompt(". ")
+			continue
+		}
+		l.SetPrompt("> ")
+		expr := strings.Join(lines, "\n")
+		lines = nil
+		out, exit, diags := cfg.EvaluateExpression(expr)
 		ret := writeDiags(c.Ui, nil, diags)
 		if exit {
 			return ret
@@ -159,3 +220,69 @@ func (c *ConsoleCommand) modeInteractive(cfg packer.Evaluator) int {
 
 	return 0
 }
+
+// consoleHistoryFile returns the file the console history is kept in, in the
+// packer config directory, or "" to not keep it.
+func consoleHistoryFile() string {
+	configDir, err := pathing.ConfigDir()
+	if err != nil {
+		log.Printf("[WARN] Not keeping the console history: %s", err)
+		return ""
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		log.Printf("[WARN] Not keeping the console history: %s", err)
+		return ""
+	}
+	return filepath.Join(configDir, "console_history")
+}
+
+// incompleteExpression tells whether lines are the beginning of an expression
+// spanning more lines: brackets, parentheses, braces or template sequences
+// are left open, or a heredoc is not closed yet.
+func incompleteExpression(lines []string) bool {
+	src := strings.Join(lines, "\n") + "\n"
+	tokens, _ := hclsyntax.LexExpression([]byte(src), "<console-input>", hcl.Pos{Line: 1, Column: 1})
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+			hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl,
+			hclsyntax.TokenOHeredoc:
+			depth++
+		case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+			hclsyntax.TokenTemplateSeqEnd, hclsyntax.TokenCHeredoc:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// consoleAutoCompleter completes the names of the functions, variables, locals
+// and data sources of a config in the console.
+type consoleAutoCompleter struct {
+	// names are the names to complete, sorted.
+	names []string
+}
+
+// Do returns the ends of the names starting with the word before pos in line,
+// along with the length of that word.
+func (c *consoleAutoCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && isConsoleNameRune(line[start-1]) {
+		start--
+	}
+	word := string(line[start:pos])
+	var candidates [][]rune
+	for _, name := range c.names {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, []rune(name[len(word):]))
+		}
+	}
+	return candidates, len([]rune(word))
+}
+
+// isConsoleNameRune tells whether r can be part of a name to complete: an HCL
+// identifier, or the attributes of var, local and data.
+func isConsoleNameRune(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
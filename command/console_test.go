@@ -17,6 +17,10 @@ func Test_console(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Getwd: %v", err)
 	}
+	root, err := filepath.Abs(testFixture("var-arg"))
+	if err != nil {
+		t.Fatalf("Abs: %v", err)
+	}
 
 	tc := []struct {
 		piped    string
@@ -31,12 +35,20 @@ func Test_console(t *testing.T) {
 		{"1 + 5", []string{"console", "--config-type=hcl2"}, nil, "6\n"},
 		{"var.images", []string{"console", filepath.Join(testFixture("var-arg"), "map.pkr.hcl")}, nil, "{\n" + `  "key" = "value"` + "\n}\n"},
 		{"path.cwd", []string{"console", filepath.Join(testFixture("var-arg"), "map.pkr.hcl")}, nil, strings.ReplaceAll(cwd, `\`, `/`) + "\n"},
-		{"path.root", []string{"console", filepath.Join(testFixture("var-arg"), "map.pkr.hcl")}, nil, strings.ReplaceAll(testFixture("var-arg"), `\`, `/`) + "\n"},
+		{"path.root", []string{"console", filepath.Join(testFixture("var-arg"), "map.pkr.hcl")}, nil, strings.ReplaceAll(root, `\`, `/`) + "\n"},
+		{"path.config", []string{"console", filepath.Join(testFixture("var-arg"), "map.pkr.hcl")}, []string{"PACKER_CONFIG_DIR=" + root}, strings.ReplaceAll(filepath.Join(root, ".packer.d"), `\`, `/`) + "\n"},
 		{"var.list_of_string[0]", []string{"console", `-var=list_of_string=["first"]`, filepath.Join(testFixture("hcl", "variables", "list_of_string"))}, nil, "first\n"},
 		{"var.untyped[2]", []string{"console", filepath.Join(testFixture("hcl", "variables", "untyped_var"))}, nil, "strings\n"},
 		{"var.untyped", []string{"console", `-var=untyped=just_a_string`, filepath.Join(testFixture("hcl", "variables", "untyped_var"))}, nil, "just_a_string\n"},
 		{"var.untyped", []string{"console", filepath.Join(testFixture("hcl", "variables", "untyped_var", "var.pkr.hcl"))}, nil, "<unknown>\n"},
 		{"var.untyped", []string{"console", filepath.Join(testFixture("hcl", "variables", "untyped_var", "var.pkr.hcl"))}, []string{"PKR_VAR_untyped=just_a_string"}, "just_a_string\n"},
+		{"local.name", []string{"console", "-var=region=eu-west-1", testFixture("console")}, nil, "image-eu-west-1\n"},
+		{"data.mock.base.foo", []string{"console", "-var=region=eu-west-1", testFixture("console")}, nil, "ami-eu-west-1\n"},
+		{"local.base_ami", []string{"console", testFixture("console")}, nil, "ami-us-east-1\n"},
+		{"local.base_ami", []string{"console", "-skip-datasources", testFixture("console")}, nil, "<unknown>\n"},
+		{"upper(\n  var.fruit\n)", []string{"console", filepath.Join(testFixture("var-arg"), "fruit_builder.pkr.hcl")}, []string{"PKR_VAR_fruit=potato"}, "POTATO\n"},
+		{"<<EOF\n${var.fruit}\nEOF", []string{"console", filepath.Join(testFixture("var-arg"), "fruit_builder.pkr.hcl")}, []string{"PKR_VAR_fruit=potato"}, "potato\n\n"},
+		{"datasources", []string{"console", testFixture("console")}, nil, "> data-sources:\n\ndata.mock.base: \"{\\n  \\\"foo\\\" = \\\"ami-us-east-1\\\"\\n}\"\n\n"},
 	}
 
 	for _, tc := range tc {
@@ -52,3 +64,50 @@ func Test_console(t *testing.T) {
 		})
 	}
 }
+
+func Test_incompleteExpression(t *testing.T) {
+	tc := []struct {
+		lines      []string
+		incomplete bool
+	}{
+		{[]string{"1 + 5"}, false},
+		{[]string{"upper("}, true},
+		{[]string{"upper(", "var.fruit"}, true},
+		{[]string{"upper(", "var.fruit", ")"}, false},
+		{[]string{"[", "1,"}, true},
+		{[]string{"{", "a = [1]", "}"}, false},
+		{[]string{"<<EOF", "${var.fruit}"}, true},
+		{[]string{"<<EOF", "${var.fruit}", "EOF"}, false},
+		{[]string{"<<-EOF", "  ${var.fruit}", "  EOF"}, false},
+		{[]string{"upper())"}, false},
+	}
+	for _, tc := range tc {
+		if got := incompleteExpression(tc.lines); got != tc.incomplete {
+			t.Errorf("incompleteExpression(%q) = %t, want %t", tc.lines, got, tc.incomplete)
+		}
+	}
+}
+
+func Test_consoleAutoCompleter(t *testing.T) {
+	c := &consoleAutoCompleter{names: []string{"local.name", "upper(", "uuidv4(", "var.fruit", "var.fruits"}}
+	tc := []struct {
+		line       string
+		candidates []string
+		length     int
+	}{
+		{"u", []string{"pper(", "uidv4("}, 1},
+		{"upper(var.f", []string{"ruit", "ruits"}, 5},
+		{"local.", []string{"name"}, 6},
+		{"var.x", nil, 5},
+	}
+	for _, tc := range tc {
+		line := []rune(tc.line)
+		candidates, length := c.Do(line, len(line))
+		var got []string
+		for _, candidate := range candidates {
+			got = append(got, string(candidate))
+		}
+		assert.Equal(t, tc.candidates, got, tc.line)
+		assert.Equal(t, tc.length, length, tc.line)
+	}
+}
@@ -0,0 +1,167 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/posener/complete"
+)
+
+type ConvertCommand struct {
+	Meta
+}
+
+func (c *ConvertCommand) Run(args []string) int {
+	ctx := context.Background()
+	cfg, ret := c.ParseArgs(args)
+	if ret != 0 {
+		return ret
+	}
+
+	return c.RunContext(ctx, cfg)
+}
+
+func (c *ConvertCommand) ParseArgs(args []string) (*ConvertArgs, int) {
+	var cfg ConvertArgs
+	flags := c.Meta.FlagSet("convert", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	cfg.AddFlagSets(flags)
+	if err := flags.Parse(args); err != nil {
+		return &cfg, ExitUsage
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return &cfg, ExitUsage
+	}
+	cfg.Path = args[0]
+
+	if cfg.To == "" {
+		switch {
+		case strings.HasSuffix(cfg.Path, ".pkr.hcl"):
+			cfg.To = "json"
+		case strings.HasSuffix(cfg.Path, ".pkr.json"):
+			cfg.To = "hcl"
+		}
+	}
+	if cfg.To != "hcl" && cfg.To != "json" {
+		c.Ui.Error("Cannot tell what to convert to, please set -to=hcl or -to=json.")
+		return &cfg, ExitUsage
+	}
+	return &cfg, 0
+}
+
+func (c *ConvertCommand) RunContext(ctx context.Context, cla *ConvertArgs) int {
+	var src []byte
+	var err error
+	if cla.Path == "-" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(cla.Path)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read %s: %s", cla.Path, err))
+		return ExitError
+	}
+
+	converter := &hcl2template.HCL2Converter{
+		PluginSpec: c.pluginSpec,
+	}
+	var out []byte
+	var diags hcl.Diagnostics
+	if cla.To == "json" {
+		out, diags = converter.ToJSON(cla.Path, src)
+	} else {
+		out, diags = converter.ToHCL(cla.Path, src)
+	}
+	if ret := writeDiags(c.Ui, nil, diags); ret != 0 {
+		return ExitValidation
+	}
+
+	if cla.OutputFile == "" {
+		if _, err := os.Stdout.Write(out); err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to write the converted config: %s", err))
+			return ExitError
+		}
+		return ExitSuccess
+	}
+	if err := ioutil.WriteFile(cla.OutputFile, out, 0644); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write the converted config: %s", err))
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+// pluginSpec returns the config spec of a plugin, to tell nested blocks from
+// object attributes when converting to HCL.
+func (c *ConvertCommand) pluginSpec(kind, typ string) hcldec.ObjectSpec {
+	plugins := c.CoreConfig.Components.PluginConfig
+	if plugins == nil {
+		return nil
+	}
+	var plugin interface{ ConfigSpec() hcldec.ObjectSpec }
+	var err error
+	switch kind {
+	case "source":
+		plugin, err = plugins.Builders.Start(typ)
+	case "data":
+		plugin, err = plugins.DataSources.Start(typ)
+	case "provisioner":
+		plugin, err = plugins.Provisioners.Start(typ)
+	case "post-processor":
+		plugin, err = plugins.PostProcessors.Start(typ)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Printf("[WARN] Could not get the config spec of %s %q, nested blocks will be written as attributes: %s", kind, typ, err)
+		return nil
+	}
+	return plugin.ConfigSpec()
+}
+
+func (*ConvertCommand) Help() string {
+	helpText := `
+Usage: packer convert [options] TEMPLATE
+
+  Converts an HCL2 config file between the native syntax (.pkr.hcl) and the
+  JSON syntax (.pkr.json), and writes it to stdout.
+
+  The direction is guessed from the suffix of TEMPLATE. If TEMPLATE is "-"
+  the config is read from STDIN and -to must be set.
+
+  Comments are not kept. When converting to HCL, the plugins of the config
+  are used to tell nested blocks from object attributes; objects of plugins
+  that cannot be loaded are written as attributes.
+
+Options:
+  -to=hcl|json                  Syntax to convert to.
+  -output-file=path             Write the converted config to this file
+                                instead of stdout.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*ConvertCommand) Synopsis() string {
+	return "Converts HCL2 config files between the native and JSON syntaxes"
+}
+
+func (*ConvertCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (*ConvertCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-to":          complete.PredictSet("hcl", "json"),
+		"-output-file": complete.PredictNothing,
+	}
+}
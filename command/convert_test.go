@@ -0,0 +1,68 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	jsonFile := filepath.Join(dir, "provisioner-override.pkr.json")
+	hclFile := filepath.Join(dir, "provisioner-override.pkr.hcl")
+
+	c := &ConvertCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{"-output-file", jsonFile, testFixture("hcl", "provisioner-override.pkr.hcl")}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	args = []string{"-output-file", hclFile, jsonFile}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	b, err := ioutil.ReadFile(hclFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`source "null" "example1" {`,
+		`provisioner "shell-local" {`,
+		`inline = ["echo yes overridden"]`,
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %q in converted config:\n%s", expected, b)
+		}
+	}
+
+	v := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	for _, path := range []string{jsonFile, hclFile} {
+		if code := v.Run([]string{"-syntax-only", path}); code != 0 {
+			fatalCommand(t, v.Meta)
+		}
+	}
+}
+
+func TestConvert_ParseArgs(t *testing.T) {
+	c := &ConvertCommand{
+		Meta: testMetaFile(t),
+	}
+	if _, code := c.ParseArgs([]string{"template.json"}); code != ExitUsage {
+		t.Errorf("expected a usage error when the syntax to convert to is unknown, got %d", code)
+	}
+	cfg, code := c.ParseArgs([]string{"template.pkr.json"})
+	if code != 0 || cfg.To != "hcl" {
+		t.Errorf("expected to convert to hcl, got %q and %d", cfg.To, code)
+	}
+}
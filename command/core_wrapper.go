@@ -12,8 +12,8 @@ type CoreWrapper struct {
 	*packer.Core
 }
 
-func (c *CoreWrapper) Initialize(_ packer.InitializeOptions) hcl.Diagnostics {
-	err := c.Core.Initialize()
+func (c *CoreWrapper) Initialize(opts packer.InitializeOptions) hcl.Diagnostics {
+	err := c.Core.InitializeWithOptions(opts)
 	if err != nil {
 		return hcl.Diagnostics{
 			&hcl.Diagnostic{
@@ -10,7 +10,9 @@ import (
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer/builder/amazon/ebs"
+	azurearm "github.com/hashicorp/packer/builder/azure/arm"
 	"github.com/hashicorp/packer/builder/file"
+	"github.com/hashicorp/packer/builder/googlecompute"
 	"github.com/hashicorp/packer/builder/null"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/post-processor/manifest"
@@ -120,9 +122,11 @@ func getBareComponentFinder() packer.ComponentFinder {
 	return packer.ComponentFinder{
 		PluginConfig: &packer.PluginConfig{
 			Builders: packer.MapOfBuilder{
-				"file":       func() (packersdk.Builder, error) { return &file.Builder{}, nil },
-				"null":       func() (packersdk.Builder, error) { return &null.Builder{}, nil },
-				"amazon-ebs": func() (packersdk.Builder, error) { return &ebs.Builder{}, nil },
+				"file":          func() (packersdk.Builder, error) { return &file.Builder{}, nil },
+				"null":          func() (packersdk.Builder, error) { return &null.Builder{}, nil },
+				"amazon-ebs":    func() (packersdk.Builder, error) { return &ebs.Builder{}, nil },
+				"azure-arm":     func() (packersdk.Builder, error) { return &azurearm.Builder{}, nil },
+				"googlecompute": func() (packersdk.Builder, error) { return &googlecompute.Builder{}, nil },
 			},
 			Provisioners: packer.MapOfProvisioner{
 				"shell-local": func() (packersdk.Provisioner, error) { return &shell_local.Provisioner{}, nil },
@@ -133,6 +137,9 @@ func getBareComponentFinder() packer.ComponentFinder {
 				"shell-local": func() (packersdk.PostProcessor, error) { return &shell_local_pp.PostProcessor{}, nil },
 				"manifest":    func() (packersdk.PostProcessor, error) { return &manifest.PostProcessor{}, nil },
 			},
+			DataSources: packer.MapOfDatasource{
+				"mock": func() (packersdk.Datasource, error) { return &packersdk.MockDatasource{}, nil },
+			},
 		},
 	}
 }
@@ -0,0 +1,44 @@
+package command
+
+import "strings"
+
+// Exit codes of the packer commands, by class of failure. They allow CI
+// scripts to branch on what went wrong without parsing the output.
+const (
+	// ExitSuccess is returned when everything went fine.
+	ExitSuccess = 0
+	// ExitError is returned when all builds failed, or on any error that
+	// has no more specific code.
+	ExitError = 1
+	// ExitUsage is returned when the command line is invalid: unknown flag,
+	// bad flag value, missing argument.
+	ExitUsage = 2
+	// ExitValidation is returned when the template or its variables are
+	// invalid or when a plugin rejected its configuration.
+	ExitValidation = 3
+	// ExitPartialFailure is returned when some builds failed while others
+	// succeeded.
+	ExitPartialFailure = 4
+	// ExitCancelled is returned when the builds were interrupted.
+	ExitCancelled = 5
+	// ExitChanges is returned by `packer plan -detailed-exitcode` when at
+	// least one build needs to run, and by `packer hcl2_upgrade -check` when
+	// the HCL2 files are out of date.
+	ExitChanges = 6
+	// ExitInterrupted is returned when packer was interrupted a second time,
+	// and exited without waiting for what it was doing to stop.
+	ExitInterrupted = 7
+)
+
+// exitCodesHelp documents the exit codes in the help of the commands.
+var exitCodesHelp = strings.TrimSpace(`
+Exit codes:
+
+  0  Success.
+  1  All builds failed, or an error without a more specific code.
+  2  Usage error: bad flag, flag value or argument.
+  3  Validation error: invalid template, variables or plugin configuration.
+  4  Some builds failed while others succeeded.
+  5  Builds were cancelled after being interrupted.
+  7  Interrupted twice: exited without waiting for the cancellation.
+`)
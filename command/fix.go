@@ -20,7 +20,7 @@ type FixCommand struct {
 }
 
 func (c *FixCommand) Run(args []string) int {
-	ctx, cleanup := handleTermInterrupt(c.Ui)
+	ctx, forced, cleanup := handleTermInterrupt(c.Ui)
 	defer cleanup()
 
 	cfg, ret := c.ParseArgs(args)
@@ -28,7 +28,9 @@ func (c *FixCommand) Run(args []string) int {
 		return ret
 	}
 
-	return c.RunContext(ctx, cfg)
+	return runUntilForced(forced, func() int {
+		return c.RunContext(ctx, cfg)
+	})
 }
 
 func (c *FixCommand) ParseArgs(args []string) (*FixArgs, int) {
@@ -37,13 +39,13 @@ func (c *FixCommand) ParseArgs(args []string) (*FixArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 	cfg.Path = args[0]
 	return &cfg, 0
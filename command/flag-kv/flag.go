@@ -2,11 +2,18 @@ package kvflag
 
 import (
 	"fmt"
+	"io/ioutil"
 	"strings"
+
+	"github.com/mitchellh/go-homedir"
 )
 
 // Flag is a flag.Value implementation for parsing user variables
 // from the command-line in the format of '-var key=value'.
+//
+// A value starting with '@' is read from the file it names, like
+// '-var key=@~/.ssh/id_ed25519.pub', without its final newline. Use '@@' for
+// a value starting with a literal '@'.
 type Flag map[string]string
 
 func (v *Flag) String() string {
@@ -24,6 +31,31 @@ func (v *Flag) Set(raw string) error {
 	}
 
 	key, value := raw[0:idx], raw[idx+1:]
+	switch {
+	case strings.HasPrefix(value, "@@"):
+		value = value[1:]
+	case strings.HasPrefix(value, "@"):
+		content, err := readValueFile(value[1:])
+		if err != nil {
+			return fmt.Errorf("reading the value of %s: %s", key, err)
+		}
+		value = content
+	}
 	(*v)[key] = value
 	return nil
 }
+
+// readValueFile returns the content of the file at path, without its final
+// newline.
+func readValueFile(path string) (string, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	content := strings.TrimSuffix(string(b), "\n")
+	return strings.TrimSuffix(content, "\r"), nil
+}
@@ -39,6 +39,24 @@ func TestFlag(t *testing.T) {
 			nil,
 			true,
 		},
+
+		{
+			"key=@test-fixtures/id_ed25519.pub",
+			map[string]string{"key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIB test@example.com"},
+			false,
+		},
+
+		{
+			"key=@@value",
+			map[string]string{"key": "@value"},
+			false,
+		},
+
+		{
+			"key=@test-fixtures/missing.pub",
+			map[string]string{},
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -29,13 +29,13 @@ func (c *FormatCommand) ParseArgs(args []string) (*FormatArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	cfg.Path = args[0]
@@ -48,9 +48,11 @@ func (c *FormatCommand) RunContext(ctx context.Context, cla *FormatArgs) int {
 	}
 
 	formatter := hclutils.HCL2Formatter{
-		ShowDiff: cla.Diff,
-		Write:    cla.Write,
-		Output:   os.Stdout,
+		ShowDiff:       cla.Diff,
+		Write:          cla.Write,
+		Recursive:      cla.Recursive,
+		SortAttributes: cla.SortAttributes,
+		Output:         os.Stdout,
 	}
 
 	bytesModified, diags := formatter.Format(cla.Path)
@@ -82,13 +84,22 @@ Usage: packer fmt [options] [TEMPLATE]
   not supported.
 
 Options:
-  -check        Check if the input is formatted. Exit status will be 0 if all
-                 input is properly formatted and non-zero otherwise.
+  -check            Check if the input is formatted. Exit status will be 0 if
+                    all input is properly formatted and 3 otherwise.
 
-  -diff         Display diffs of formatting change
+  -diff             Display diffs of formatting change
 
-  -write=false  Don't write to source files
-                (always disabled if using -check)
+  -write=false      Don't write to source files
+                    (always disabled if using -check)
+
+  -recursive        Also process the files in subdirectories. By default,
+                    only the given directory (or current directory) is
+                    processed. Hidden directories, like .git, are skipped.
+
+  -sort-attributes  Sort the attributes of every block by name. Attributes
+                    separated by blank lines or nested blocks are sorted as
+                    separate groups. Comments move with the attribute below
+                    them.
 
 `
 
@@ -105,8 +116,10 @@ func (*FormatCommand) AutocompleteArgs() complete.Predictor {
 
 func (*FormatCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-check": complete.PredictNothing,
-		"-diff":  complete.PredictNothing,
-		"-write": complete.PredictNothing,
+		"-check":           complete.PredictNothing,
+		"-diff":            complete.PredictNothing,
+		"-write":           complete.PredictNothing,
+		"-recursive":       complete.PredictNothing,
+		"-sort-attributes": complete.PredictNothing,
 	}
 }
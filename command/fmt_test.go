@@ -50,3 +50,21 @@ func TestFmt_unfomattedTemlateDirectory(t *testing.T) {
 		fatalCommand(t, c.Meta)
 	}
 }
+
+func TestFmt_recursive(t *testing.T) {
+	s := &strings.Builder{}
+	c := &FormatCommand{
+		Meta: testMeta(t),
+	}
+	c.Ui = &packersdk.BasicUi{
+		Writer: s,
+	}
+
+	dir := testFixture("fmt-recursive")
+	if code := c.Run([]string{"-check", dir}); code != 0 {
+		t.Fatalf("the top directory is formatted, expected exit code 0, got %d", code)
+	}
+	if code := c.Run([]string{"-check", "-recursive", dir}); code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+}
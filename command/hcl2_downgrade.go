@@ -0,0 +1,125 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/posener/complete"
+)
+
+type HCL2DowngradeCommand struct {
+	Meta
+}
+
+func (c *HCL2DowngradeCommand) Run(args []string) int {
+	ctx := context.Background()
+	cfg, ret := c.ParseArgs(args)
+	if ret != 0 {
+		return ret
+	}
+
+	return c.RunContext(ctx, cfg)
+}
+
+func (c *HCL2DowngradeCommand) ParseArgs(args []string) (*HCL2DowngradeArgs, int) {
+	var cfg HCL2DowngradeArgs
+	flags := c.Meta.FlagSet("hcl2_downgrade", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	cfg.AddFlagSets(flags)
+	if err := flags.Parse(args); err != nil {
+		return &cfg, ExitUsage
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return &cfg, ExitUsage
+	}
+	cfg.Path = args[0]
+	return &cfg, 0
+}
+
+func (c *HCL2DowngradeCommand) RunContext(ctx context.Context, cla *HCL2DowngradeArgs) int {
+	var src []byte
+	var err error
+	if cla.Path == "-" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(cla.Path)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read %s: %s", cla.Path, err))
+		return ExitError
+	}
+
+	out, diags := hcl2template.DowngradeToJSONTemplate(cla.Path, src)
+	diags, _ = cla.promoteWarnings(diags)
+	files := map[string]*hcl.File{cla.Path: {Bytes: src}}
+	if diags.HasErrors() {
+		writeDiags(c.Ui, files, diags)
+		return ExitValidation
+	}
+	// The warnings go to stderr, stdout may be the JSON template.
+	if len(diags) > 0 {
+		b := &bytes.Buffer{}
+		if err := hcl.NewDiagnosticTextWriter(b, files, 80, false).WriteDiagnostics(diags); err == nil {
+			c.Ui.Error(b.String())
+		}
+	}
+
+	if cla.OutputFile == "" {
+		if _, err := os.Stdout.Write(out); err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to write the JSON template: %s", err))
+			return ExitError
+		}
+		return ExitSuccess
+	}
+	if err := ioutil.WriteFile(cla.OutputFile, out, 0644); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write the JSON template: %s", err))
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+func (*HCL2DowngradeCommand) Help() string {
+	helpText := `
+Usage: packer hcl2_downgrade [options] FILE
+
+  Converts the HCL2 config file FILE to a legacy JSON template, and writes it
+  to stdout. If FILE is "-" the config is read from STDIN.
+
+  The conversion is lossy: JSON templates cannot represent data sources, most
+  functions, the types of variables, or which build a source belongs to. Each
+  construct that cannot be represented is reported as a warning, and is left
+  out or kept as written.
+
+Options:
+  -output-file=path             Write the JSON template to this file instead
+                                of stdout.
+  -warn-as-error                Fail, without writing the JSON template, when
+                                the config cannot be fully represented.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*HCL2DowngradeCommand) Synopsis() string {
+	return "Converts an HCL2 config file to a legacy JSON template"
+}
+
+func (*HCL2DowngradeCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*.pkr.hcl")
+}
+
+func (*HCL2DowngradeCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-output-file":   complete.PredictNothing,
+		"-warn-as-error": complete.PredictNothing,
+	}
+}
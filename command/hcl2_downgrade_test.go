@@ -0,0 +1,67 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_hcl2_downgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-downgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputPath := filepath.Join(dir, "template.json")
+
+	c := &HCL2DowngradeCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{"-output-file", outputPath, testFixture("hcl2_downgrade", "input.pkr.hcl")}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	_, errOut := outputCommand(t, c.Meta)
+	for _, warning := range []string{"JSON templates have no data blocks", "echo ${upper(var.region)}"} {
+		if !strings.Contains(errOut, warning) {
+			t.Errorf("expected a warning about %q, got:\n%s", warning, errOut)
+		}
+	}
+
+	expected := string(mustBytes(ioutil.ReadFile(testFixture("hcl2_downgrade", "expected.json"))))
+	actual := string(mustBytes(ioutil.ReadFile(outputPath)))
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("unexpected JSON template: %s", diff)
+	}
+
+	v := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := v.Run([]string{"-syntax-only", outputPath}); code != 0 {
+		fatalCommand(t, v.Meta)
+	}
+}
+
+func Test_hcl2_downgrade_warnAsError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-downgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputPath := filepath.Join(dir, "template.json")
+
+	c := &HCL2DowngradeCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{"-warn-as-error", "-output-file", outputPath, testFixture("hcl2_downgrade", "input.pkr.hcl")}
+	if code := c.Run(args); code != ExitValidation {
+		t.Fatalf("expected exit code %d, got %d", ExitValidation, code)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("the JSON template should not be written, got %v", err)
+	}
+}
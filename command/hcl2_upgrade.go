@@ -3,18 +3,33 @@ package command
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	texttemplate "text/template"
 
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	hcl2shim "github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template"
+	kvflag "github.com/hashicorp/packer/command/flag-kv"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/hcl2template/addrs"
+	"github.com/hashicorp/packer/packer"
+	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
 	"github.com/mitchellh/mapstructure"
 	"github.com/posener/complete"
 	"github.com/zclconf/go-cty/cty"
@@ -22,10 +37,13 @@ import (
 
 type HCL2UpgradeCommand struct {
 	Meta
+
+	// specs are the configuration specs of the plugins, by kind and type.
+	specs map[string]hcldec.ObjectSpec
 }
 
 func (c *HCL2UpgradeCommand) Run(args []string) int {
-	ctx, cleanup := handleTermInterrupt(c.Ui)
+	ctx, forced, cleanup := handleTermInterrupt(c.Ui)
 	defer cleanup()
 
 	cfg, ret := c.ParseArgs(args)
@@ -33,7 +51,9 @@ func (c *HCL2UpgradeCommand) Run(args []string) int {
 		return ret
 	}
 
-	return c.RunContext(ctx, cfg)
+	return runUntilForced(forced, func() int {
+		return c.RunContext(ctx, cfg)
+	})
 }
 
 func (c *HCL2UpgradeCommand) ParseArgs(args []string) (*HCL2UpgradeArgs, int) {
@@ -42,15 +62,35 @@ func (c *HCL2UpgradeCommand) ParseArgs(args []string) (*HCL2UpgradeArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 	args = flags.Args()
-	if len(args) != 1 {
+	if len(args) == 0 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
+	}
+	if fi, err := os.Stat(args[0]); len(args) > 1 || hasGlobMeta(args[0]) || err == nil && fi.IsDir() {
+		if cfg.OutputFile != "" || cfg.OutputDir != "" || cfg.NamesFile != "" {
+			c.Ui.Error("-output-file, -output-dir and -names-file can only be set when upgrading a single template")
+			return &cfg, ExitUsage
+		}
+		cfg.Paths = args
+		return &cfg, 0
 	}
 	cfg.Path = args[0]
-	if cfg.OutputFile == "" {
+	switch {
+	case cfg.Check && cfg.OutputFile == "-":
+		c.Ui.Error("-check cannot be set with -output-file=-")
+		return &cfg, ExitUsage
+	case cfg.SplitFiles && cfg.OutputFile != "":
+		c.Ui.Error("-output-file cannot be set with -split-files, set -output-dir instead")
+		return &cfg, ExitUsage
+	case !cfg.SplitFiles && cfg.OutputDir != "":
+		c.Ui.Error("-output-dir can only be set with -split-files")
+		return &cfg, ExitUsage
+	case cfg.SplitFiles && cfg.OutputDir == "":
+		cfg.OutputDir = filepath.Dir(cfg.Path)
+	case cfg.OutputFile == "":
 		cfg.OutputFile = cfg.Path + ".pkr.hcl"
 	}
 	return &cfg, 0
@@ -93,32 +133,49 @@ const (
 # https://www.packer.io/docs/templates/hcl_templates/blocks/build
 build {
 `
-	amazonAmiDataHeader = `
-# The amazon-ami data block is generated from your amazon builder source_ami_filter; a data
-# from this block can be referenced in source and locals blocks.
+	datasourceHeader = `
+# %s
 # Read the documentation for data blocks here:
 # https://www.packer.io/docs/templates/hcl_templates/blocks/data`
+
+	splitFileHeader = `# This file was autogenerated by the 'packer hcl2_upgrade -split-files'
+# command from %s. We recommend double checking that everything is correct
+# before going forward.
+`
+
+	vaultLocalHeader = `# The default of the %[1]q variable calls vault, which HCL2 does not allow in
+# variable defaults, so it was upgraded to local.%[1]s. The secret is now read
+# from Vault every time the config is evaluated, and can no longer be set with
+# -var or a var file. Read the documentation of the vault function here:
+# https://www.packer.io/docs/templates/hcl_templates/functions/contextual/vault
+`
+
+	userLocalHeader = `# The default of the %[1]q variable is computed from other variables or from
+# the time, which HCL2 does not allow in variable defaults, so it was upgraded
+# to local.%[1]s. It is now computed every time the config is evaluated, and
+# can no longer be set with -var or a var file. Read the documentation of
+# locals here:
+# https://www.packer.io/docs/templates/hcl_templates/locals
+`
+
+	varFileHeader = `# This file was autogenerated by the 'packer hcl2_upgrade' command from the
+# %s var file. Packer loads it automatically when building the folder it is in.
+`
+)
+
+// The files written by hcl2_upgrade -split-files.
+const (
+	upgradeVariablesFile = "variables.pkr.hcl"
+	upgradeSourcesFile   = "sources.pkr.hcl"
+	upgradeBuildFile     = "build.pkr.hcl"
 )
 
 func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2UpgradeArgs) int {
-	out := &bytes.Buffer{}
-	var output io.Writer
-	if err := os.MkdirAll(filepath.Dir(cla.OutputFile), 0); err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to create output directory: %v", err))
-		return 1
-	}
-	if f, err := os.Create(cla.OutputFile); err == nil {
-		output = f
-		defer f.Close()
-	} else {
-		c.Ui.Error(fmt.Sprintf("Failed to create output file: %v", err))
-		return 1
+	if len(cla.Paths) > 0 {
+		return c.upgradeTemplates(buildCtx, cla)
 	}
 
-	if _, err := output.Write([]byte(hcl2UpgradeFileHeader)); err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to write to file: %v", err))
-		return 1
-	}
+	out := &bytes.Buffer{}
 
 	hdl, ret := c.GetConfigFromJSON(&cla.MetaArgs)
 	if ret != 0 {
@@ -131,18 +188,27 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 	}
 	tpl := core.Template
 
-	// Packer section
-	if tpl.MinVersion != "" {
-		out.Write([]byte(packerBlockHeader))
-		fileContent := hclwrite.NewEmptyFile()
-		body := fileContent.Body()
-		packerBody := body.AppendNewBlock("packer", nil).Body()
-		packerBody.SetAttributeValue("required_version", cty.StringVal(fmt.Sprintf(">= %s", tpl.MinVersion)))
-		out.Write(fileContent.Bytes())
+	// Root level comments, like "_comment"
+	if len(tpl.Comments) > 0 {
+		keys := make([]string, 0, len(tpl.Comments))
+		for k := range tpl.Comments {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out.Write([]byte("\n"))
+		for _, k := range keys {
+			out.Write(commentTokens(tpl.Comments[k]).Bytes())
+		}
 	}
 
+	// The packer section is written here once the rest is generated, since
+	// its required_version depends on the features used by the rest.
+	packerSectionOffset := out.Len()
+
 	out.Write([]byte(inputVarHeader))
 
+	isotimes := &isotimeLocals{}
+
 	// Output variables section
 
 	variables := []*template.Variable{}
@@ -157,7 +223,18 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		})
 	}
 
+	localVars := map[string]bool{}
 	for _, variable := range variables {
+		if variableLocalHeader(variable, isotimes) != "" {
+			localVars[variable.Key] = true
+		}
+	}
+	for _, variable := range variables {
+		if localVars[variable.Key] {
+			sensitive := isSensitiveVariable(variable.Key, tpl.SensitiveVariables)
+			out.Write(variableLocal(variable, localVars, sensitive, isotimes))
+			continue
+		}
 		variablesContent := hclwrite.NewEmptyFile()
 		variablesBody := variablesContent.Body()
 
@@ -170,12 +247,17 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		if isSensitiveVariable(variable.Key, tpl.SensitiveVariables) {
 			variableBody.SetAttributeValue("sensitive", cty.BoolVal(true))
 		}
+		if cla.WithValidation && (variable.Required || envDefaultRe.MatchString(variable.Default)) {
+			appendNonEmptyValidation(variableBody, variable.Key)
+		}
 		variablesBody.AppendNewline()
-		out.Write(transposeTemplatingCalls(variablesContent.Bytes()))
+		out.Write(transposeTemplatingCalls(variablesContent.Bytes(), isotimes))
 	}
 
-	fmt.Fprintln(out, `# "timestamp" template function replacement`)
-	fmt.Fprintln(out, `locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }`)
+	// The locals are written once all the blocks are transposed, so that
+	// they include the formats of every isotime call.
+	head := out
+	out = &bytes.Buffer{}
 
 	// Output sources section
 
@@ -185,18 +267,25 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		for _, builder := range tpl.Builders {
 			builders = append(builders, builder)
 		}
+		sort.Slice(builders, func(i, j int) bool {
+			return builders[i].Type+builders[i].Name < builders[j].Type+builders[j].Name
+		})
 	}
 
-	if err := c.writeAmazonAmiDatasource(builders, out); err != nil {
+	if err := c.writeUpgradeDatasources(upgradableConfigs(tpl, builders), out, isotimes); err != nil {
 		return 1
 	}
 
-	sort.Slice(builders, func(i, j int) bool {
-		return builders[i].Type+builders[i].Name < builders[j].Type+builders[j].Name
-	})
-
 	out.Write([]byte(sourcesHeader))
 
+	missingPaths := []string{}
+	// sourceNames maps the names of the builders, used by -only, -except and
+	// the only and except of provisioners and post-processors, to the names
+	// of their sources.
+	sourceNames := map[string]string{}
+	// ${source.name} is what {{ .BuildName }} becomes in post-processors: it
+	// must have the value the builder name had in JSON.
+	keepBuilderNames := cla.KeepBuilderNames || postProcessorsUseBuildName(tpl)
 	for i, builderCfg := range builders {
 		sourcesContent := hclwrite.NewEmptyFile()
 		body := sourcesContent.Body()
@@ -206,17 +295,31 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 			c.Ui.Error(fmt.Sprintf("unknown builder type: %q\n", builderCfg.Type))
 			return 1
 		}
-		if builderCfg.Name == "" || builderCfg.Name == builderCfg.Type {
+		jsonName := builderCfg.Name
+		if jsonName == "" {
+			// JSON names unnamed builders after their type
+			jsonName = builderCfg.Type
+		}
+		if !keepBuilderNames && (builderCfg.Name == "" || builderCfg.Name == builderCfg.Type) {
 			builderCfg.Name = fmt.Sprintf("autogenerated_%d", i+1)
 		}
+		sourceNames[jsonName] = builderCfg.Type + "." + builderCfg.Name
+		warnings := c.unknownKeyWarnings(pluginRef{kind: "builder", typ: builderCfg.Type}, builderCfg.Config)
+		appendComments(body, extractComments(builderCfg.Config, warnings))
 		sourceBody := body.AppendNewBlock("source", []string{builderCfg.Type, builderCfg.Name}).Body()
 
-		jsonBodyToHCL2Body(sourceBody, builderCfg.Config)
+		jsonBodyToHCL2Body(sourceBody, builderCfg.Config, warnings)
+
+		_, _ = out.Write(transposeTemplatingCalls(sourcesContent.Bytes(), isotimes, pluginRef{kind: "builder", typ: builderCfg.Type, name: jsonName}))
 
-		_, _ = out.Write(transposeTemplatingCalls(sourcesContent.Bytes()))
+		for _, path := range missingLocalPaths(filepath.Dir(cla.Path), builderCfg.Config) {
+			missingPaths = append(missingPaths, fmt.Sprintf("source.%s.%s: %s", builderCfg.Type, builderCfg.Name, path))
+		}
 	}
 
 	// Output build section
+	sources := out
+	out = &bytes.Buffer{}
 	out.Write([]byte(buildHeader))
 
 	buildContent := hclwrite.NewEmptyFile()
@@ -226,11 +329,11 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		buildBody.AppendNewline()
 	}
 
-	sourceNames := []string{}
+	sourceAddrs := []string{}
 	for _, builder := range builders {
-		sourceNames = append(sourceNames, fmt.Sprintf("source.%s.%s", builder.Type, builder.Name))
+		sourceAddrs = append(sourceAddrs, fmt.Sprintf("source.%s.%s", builder.Type, builder.Name))
 	}
-	buildBody.SetAttributeValue("sources", hcl2shim.HCL2ValueFromConfigValue(sourceNames))
+	buildBody.SetAttributeValue("sources", hcl2shim.HCL2ValueFromConfigValue(sourceAddrs))
 	buildBody.AppendNewline()
 	_, _ = buildContent.WriteTo(out)
 
@@ -239,13 +342,15 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		body := provisionerContent.Body()
 
 		buildBody.AppendNewline()
-		block := body.AppendNewBlock("provisioner", []string{provisioner.Type})
 		cfg := provisioner.Config
+		warnings := c.unknownKeyWarnings(pluginRef{kind: "provisioner", typ: provisioner.Type}, cfg)
+		appendComments(body, extractComments(cfg, warnings))
+		block := body.AppendNewBlock("provisioner", []string{provisioner.Type})
 		if len(provisioner.Except) > 0 {
-			cfg["except"] = provisioner.Except
+			cfg["except"] = upgradeBuilderNames(provisioner.Except, sourceNames)
 		}
 		if len(provisioner.Only) > 0 {
-			cfg["only"] = provisioner.Only
+			cfg["only"] = upgradeBuilderNames(provisioner.Only, sourceNames)
 		}
 		if provisioner.MaxRetries != "" {
 			cfg["max_retries"] = provisioner.MaxRetries
@@ -253,11 +358,11 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 		if provisioner.Timeout > 0 {
 			cfg["timeout"] = provisioner.Timeout.String()
 		}
-		jsonBodyToHCL2Body(block.Body(), cfg)
+		jsonBodyToHCL2Body(block.Body(), cfg, warnings)
 
-		out.Write(transposeTemplatingCalls(provisionerContent.Bytes()))
+		out.Write(transposeTemplatingCalls(provisionerContent.Bytes(), isotimes, pluginRef{kind: "provisioner", typ: provisioner.Type}))
 	}
-	for _, pps := range tpl.PostProcessors {
+	for i, pps := range tpl.PostProcessors {
 		postProcessorContent := hclwrite.NewEmptyFile()
 		body := postProcessorContent.Body()
 
@@ -266,93 +371,819 @@ func (c *HCL2UpgradeCommand) RunContext(buildCtx context.Context, cla *HCL2Upgra
 			continue
 		case 1:
 		default:
+			appendComments(body, []string{postProcessorsChainComment(i+1, pps)})
 			body = body.AppendNewBlock("post-processors", nil).Body()
 		}
+		var plugins []pluginRef
 		for _, pp := range pps {
+			plugins = append(plugins, pluginRef{kind: "post-processor", typ: pp.Type})
+			cfg := pp.Config
+			warnings := c.unknownKeyWarnings(pluginRef{kind: "post-processor", typ: pp.Type}, cfg)
+			appendComments(body, extractComments(cfg, warnings))
 			ppBody := body.AppendNewBlock("post-processor", []string{pp.Type}).Body()
 			if pp.KeepInputArtifact != nil {
 				ppBody.SetAttributeValue("keep_input_artifact", cty.BoolVal(*pp.KeepInputArtifact))
 			}
-			cfg := pp.Config
 			if len(pp.Except) > 0 {
-				cfg["except"] = pp.Except
+				cfg["except"] = upgradeBuilderNames(pp.Except, sourceNames)
 			}
 			if len(pp.Only) > 0 {
-				cfg["only"] = pp.Only
+				cfg["only"] = upgradeBuilderNames(pp.Only, sourceNames)
 			}
 			if pp.Name != "" && pp.Name != pp.Type {
 				cfg["name"] = pp.Name
 			}
-			jsonBodyToHCL2Body(ppBody, cfg)
+			jsonBodyToHCL2Body(ppBody, cfg, warnings)
 		}
 
-		_, _ = out.Write(transposeTemplatingCalls(postProcessorContent.Bytes()))
+		_, _ = out.Write(transposeTemplatingCalls(postProcessorContent.Bytes(), isotimes, plugins...))
 	}
 
 	_, _ = out.Write([]byte("}\n"))
 
-	_, _ = output.Write(hclwrite.Format(out.Bytes()))
+	fmt.Fprintln(head, `# "timestamp" template function replacement`)
+	fmt.Fprintln(head, `locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }`)
+	isotimes.writeLocals(head)
+
+	for key := range localVars {
+		from, to := []byte(fmt.Sprintf("${var.%s}", key)), []byte(fmt.Sprintf("${local.%s}", key))
+		for _, buf := range []*bytes.Buffer{head, sources, out} {
+			content := bytes.ReplaceAll(buf.Bytes(), from, to)
+			buf.Reset()
+			buf.Write(content)
+		}
+	}
+
+	// Packer section
+	plugins := upgradeRequiredPlugins(tpl, pluginInstallationsOptions(c.Meta.CoreConfig.Components.PluginConfig.KnownPluginFolders))
+	if tpl.MinVersion != "" || len(plugins) > 0 {
+		packerSection := &bytes.Buffer{}
+		packerSection.Write([]byte(packerBlockHeader))
+		fileContent := hclwrite.NewEmptyFile()
+		body := fileContent.Body()
+		if tpl.MinVersion != "" {
+			generated := bytes.Join([][]byte{head.Bytes(), sources.Bytes(), out.Bytes()}, nil)
+			features := usedHCL2Features(generated)
+			features["the packer block"] = true
+			if len(plugins) > 0 {
+				features["required_plugins blocks"] = true
+			}
+			minVersion, reasons := requiredPackerVersion(tpl.MinVersion, features)
+			if len(reasons) > 0 {
+				if n := len(reasons); n > 1 {
+					reasons = append(reasons[:n-2], reasons[n-2]+" and "+reasons[n-1])
+				}
+				reason := fmt.Sprintf("required_version was raised from min_packer_version %s to %s:\n%s need Packer %s or later.",
+					tpl.MinVersion, minVersion, strings.Join(reasons, ", "), minVersion)
+				appendComments(body, []string{reason})
+				c.Ui.Error("Warning: " + strings.ReplaceAll(reason, "\n", " "))
+			}
+			packerBody := body.AppendNewBlock("packer", nil).Body()
+			packerBody.SetAttributeValue("required_version", cty.StringVal(fmt.Sprintf(">= %s", minVersion)))
+			addRequiredPlugins(packerBody, plugins)
+		} else {
+			addRequiredPlugins(body.AppendNewBlock("packer", nil).Body(), plugins)
+		}
+		packerSection.Write(fileContent.Bytes())
+
+		content := append([]byte{}, head.Bytes()[:packerSectionOffset]...)
+		content = append(content, packerSection.Bytes()...)
+		content = append(content, head.Bytes()[packerSectionOffset:]...)
+		head.Reset()
+		head.Write(content)
+	}
+
+	output := cla.OutputFile
+	var files []upgradedFile
+	if cla.SplitFiles {
+		output = cla.OutputDir
+		header := fmt.Sprintf(splitFileHeader, filepath.Base(cla.Path))
+		files = []upgradedFile{
+			{filepath.Join(cla.OutputDir, upgradeVariablesFile), header, head.Bytes()},
+			{filepath.Join(cla.OutputDir, upgradeSourcesFile), header, sources.Bytes()},
+			{filepath.Join(cla.OutputDir, upgradeBuildFile), header, out.Bytes()},
+		}
+	} else {
+		_, _ = head.Write(sources.Bytes())
+		_, _ = head.Write(out.Bytes())
+		files = []upgradedFile{{cla.OutputFile, hcl2UpgradeFileHeader, head.Bytes()}}
+	}
+
+	ret = 0
+	switch {
+	case cla.OutputFile == "-":
+		c.Ui.Say(strings.TrimSuffix(string(files[0].bytes()), "\n"))
+	case cla.Check:
+		for _, file := range files {
+			diff, err := file.diff()
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+			if len(diff) > 0 {
+				c.Ui.Say(strings.TrimSuffix(string(diff), "\n"))
+				ret = ExitChanges
+			}
+		}
+		if ret == 0 {
+			c.Ui.Say(fmt.Sprintf("%s is up to date with %s", output, cla.Path))
+		} else {
+			c.Ui.Error(fmt.Sprintf("%s is out of date with %s, run hcl2_upgrade again to update it", output, cla.Path))
+		}
+	default:
+		for _, file := range files {
+			if err := file.write(); err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+		}
+		if cla.SplitFiles {
+			c.Ui.Say(fmt.Sprintf("Successfully created %s, %s and %s in %s ",
+				upgradeVariablesFile, upgradeSourcesFile, upgradeBuildFile, cla.OutputDir))
+		} else {
+			c.Ui.Say(fmt.Sprintf("Successfully created %s ", cla.OutputFile))
+		}
 
-	c.Ui.Say(fmt.Sprintf("Successfully created %s ", cla.OutputFile))
+		if cla.NamesFile != "" {
+			if err := writeSourceNames(cla.NamesFile, sourceNames); err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+			c.Ui.Say(fmt.Sprintf("Successfully created %s ", cla.NamesFile))
+		}
+
+		outputDir := filepath.Dir(cla.OutputFile)
+		if cla.SplitFiles {
+			outputDir = cla.OutputDir
+		}
+		for _, varFile := range cla.VarFiles {
+			path, undeclared, err := upgradeVarFile(varFile, outputDir, tpl)
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+			c.Ui.Say(fmt.Sprintf("Successfully created %s ", path))
+			if len(undeclared) > 0 {
+				c.Ui.Error(fmt.Sprintf("Warning: the following variables of %s are not declared by %s and were left out: %s",
+					varFile, cla.Path, strings.Join(undeclared, ", ")))
+			}
+		}
+	}
+
+	if len(missingPaths) > 0 {
+		c.Ui.Error(fmt.Sprintf("Warning: the following local paths do not exist relative to the directory "+
+			"of %s. Make sure they are correct, in particular if %s is used from another directory:\n  %s",
+			cla.Path, output, strings.Join(missingPaths, "\n  ")))
+	}
 
-	return 0
+	return ret
 }
 
-func (c *HCL2UpgradeCommand) writeAmazonAmiDatasource(builders []*template.Builder, out *bytes.Buffer) error {
-	amazonAmiFilters := []map[string]interface{}{}
-	first := true
-	i := 1
-	for _, builder := range builders {
-		if strings.HasPrefix(builder.Type, "amazon-") {
-			if sourceAmiFilter, ok := builder.Config["source_ami_filter"]; ok {
-				sourceAmiFilterCfg := map[string]interface{}{}
-				if err := mapstructure.Decode(sourceAmiFilter, &sourceAmiFilterCfg); err != nil {
-					c.Ui.Error(fmt.Sprintf("Failed to write amazon-ami data source: %v", err))
-					return err
-				}
+// addRequiredPlugins adds the required_plugins block of plugins to
+// packerBody, if there are any.
+func addRequiredPlugins(packerBody *hclwrite.Body, plugins []*inferredPlugin) {
+	if len(plugins) == 0 {
+		return
+	}
+	requiredPlugins := packerBody.AppendNewBlock("required_plugins", nil).Body()
+	for _, p := range plugins {
+		requiredPlugins.SetAttributeValue(p.Name, cty.ObjectVal(map[string]cty.Value{
+			"version": cty.StringVal(p.Version),
+			"source":  cty.StringVal(p.Source),
+		}))
+	}
+}
 
-				duplicate := false
-				dataSourceName := fmt.Sprintf("autogenerated_%d", i)
-				for j, filter := range amazonAmiFilters {
-					if reflect.DeepEqual(filter, sourceAmiFilter) {
-						duplicate = true
-						dataSourceName = fmt.Sprintf("autogenerated_%d", j+1)
-						continue
-					}
+// hcl2FeatureVersions are the first Packer versions supporting the HCL2
+// features that hcl2_upgrade generates, in the order of the versions.
+var hcl2FeatureVersions = []struct {
+	feature, version string
+}{
+	{"post-processors blocks", "1.6.1"},
+	{"the vault function", "1.6.2"},
+	{"the packer block", "1.6.5"},
+	{"sensitive variables", "1.6.5"},
+	{"the env function", "1.6.6"},
+	{"data blocks", "1.7.0"},
+	{"required_plugins blocks", "1.7.0"},
+}
+
+// usedHCL2Features returns the features of hcl2FeatureVersions used by the
+// generated HCL2 config src.
+func usedHCL2Features(src []byte) map[string]bool {
+	features := map[string]bool{}
+	f, diags := hclwrite.ParseConfig(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		log.Printf("[WARN] could not parse the generated config to find the features it uses: %s", diags)
+		return features
+	}
+
+	var walk func(body *hclwrite.Body)
+	walk = func(body *hclwrite.Body) {
+		for _, block := range body.Blocks() {
+			switch block.Type() {
+			case "post-processors":
+				features["post-processors blocks"] = true
+			case "data":
+				features["data blocks"] = true
+			case "variable":
+				if block.Body().GetAttribute("sensitive") != nil {
+					features["sensitive variables"] = true
 				}
+			}
+			walk(block.Body())
+		}
+	}
+	walk(f.Body())
 
-				// This is a hack...
-				// Use templating so that it could be correctly transformed later into a data resource
-				sourceAmiDataRef := fmt.Sprintf("{{ data `amazon-ami.%s.id` }}", dataSourceName)
+	tokens := f.BuildTokens(nil)
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].Type != hclsyntax.TokenIdent || tokens[i+1].Type != hclsyntax.TokenOParen {
+			continue
+		}
+		switch string(tokens[i].Bytes) {
+		case "env":
+			features["the env function"] = true
+		case "vault":
+			features["the vault function"] = true
+		}
+	}
+	return features
+}
+
+// requiredPackerVersion returns the version of Packer needed to use
+// features, when it is newer than minVersion, with the features needing it.
+// Otherwise it returns minVersion.
+func requiredPackerVersion(minVersion string, features map[string]bool) (string, []string) {
+	required, err := version.NewVersion(minVersion)
+	if err != nil {
+		return minVersion, nil
+	}
+	var reasons []string
+	for _, fv := range hcl2FeatureVersions {
+		if !features[fv.feature] {
+			continue
+		}
+		v := version.Must(version.NewVersion(fv.version))
+		switch {
+		case v.GreaterThan(required):
+			required, reasons = v, []string{fv.feature}
+		case v.Equal(required) && len(reasons) > 0:
+			reasons = append(reasons, fv.feature)
+		}
+	}
+	if len(reasons) == 0 {
+		return minVersion, nil
+	}
+	return required.String(), reasons
+}
+
+// anyPluginVersion is the version constraint of the required plugins that
+// are not installed: required_plugins needs one, and any version will do.
+const anyPluginVersion = ">= 0.0.0"
+
+// upgradeRequiredPlugins returns, sorted by name, the known plugins providing
+// the builders, provisioners and post-processors of tpl, for `packer init` to
+// install them. Each plugin is required from the latest version installed in
+// opts, or from any version when there is none.
+func upgradeRequiredPlugins(tpl *template.Template, opts plugingetter.ListInstallationsOptions) []*inferredPlugin {
+	plugins := map[string]*inferredPlugin{}
+	use := func(typ string) {
+		name, source, found := knownPluginSource(typ)
+		if !found || plugins[name] != nil {
+			return
+		}
+		p := &inferredPlugin{Name: name, Source: source, Version: anyPluginVersion}
+		if identifier, diags := addrs.ParsePluginSourceString(source); !diags.HasErrors() {
+			req := &plugingetter.Requirement{Accessor: name, Identifier: identifier}
+			if installs, err := req.ListInstallations(opts); err == nil && len(installs) > 0 {
+				p.Version = ">= " + strings.TrimPrefix(installs[len(installs)-1].Version, "v")
+			}
+		}
+		plugins[name] = p
+	}
+	for _, builder := range tpl.Builders {
+		use(builder.Type)
+	}
+	for _, provisioner := range tpl.Provisioners {
+		use(provisioner.Type)
+	}
+	for _, pps := range tpl.PostProcessors {
+		for _, pp := range pps {
+			use(pp.Type)
+		}
+	}
+
+	res := make([]*inferredPlugin, 0, len(plugins))
+	for _, p := range plugins {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// variableLocalHeader returns the comment of the local block replacing
+// variable when its default cannot be the default of an HCL2 variable, or an
+// empty string. HCL2 variable defaults can call env, but cannot call vault nor
+// use the value of other variables or locals, like the timestamp, and a local
+// is the closest equivalent.
+func variableLocalHeader(variable *template.Variable, isotimes *isotimeLocals) string {
+	if !strings.Contains(variable.Default, "{{") {
+		return ""
+	}
+	expr := string(transposeTemplatingCalls([]byte(escapeStringLiteral(variable.Default)), isotimes))
+	switch {
+	case strings.Contains(expr, "${vault("):
+		return fmt.Sprintf(vaultLocalHeader, variable.Key)
+	case strings.Contains(expr, "${var."), strings.Contains(expr, "${local."):
+		return fmt.Sprintf(userLocalHeader, variable.Key)
+	}
+	return ""
+}
+
+// variableLocal returns the local block replacing variable, whose default
+// cannot be the default of an HCL2 variable. References to the variable, and
+// to the other variables of localVars, must then use the locals. Locals
+// calling vault are always sensitive.
+func variableLocal(variable *template.Variable, localVars map[string]bool, sensitive bool, isotimes *isotimeLocals) []byte {
+	header := variableLocalHeader(variable, isotimes)
+	expr := string(transposeTemplatingCalls([]byte(escapeStringLiteral(variable.Default)), isotimes))
+	for key := range localVars {
+		expr = strings.ReplaceAll(expr, fmt.Sprintf("${var.%s}", key), fmt.Sprintf("${local.%s}", key))
+	}
+	if strings.HasPrefix(expr, "${") && strings.HasSuffix(expr, "}") && strings.Count(expr, "${") == 1 {
+		// the default is only the call, no need for a string template
+		expr = strings.TrimSuffix(strings.TrimPrefix(expr, "${"), "}")
+	} else {
+		expr = `"` + expr + `"`
+	}
+
+	localContent := hclwrite.NewEmptyFile()
+	localBody := localContent.Body().AppendNewBlock("local", []string{variable.Key}).Body()
+	localBody.SetAttributeRaw("expression", hclwrite.Tokens{&hclwrite.Token{Bytes: []byte(expr)}})
+	if sensitive || strings.Contains(expr, "vault(") {
+		localBody.SetAttributeValue("sensitive", cty.BoolVal(true))
+	}
+	localContent.Body().AppendNewline()
+
+	return append([]byte(header), localContent.Bytes()...)
+}
+
+// hclStringEscaper escapes the text of HCL2 quoted strings: the template
+// sequences of JSON values are literal text, like their quotes.
+var hclStringEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"${", "$${",
+	"%{", "%%{",
+)
+
+// escapeStringLiteral escapes s, a JSON value, for it to be the contents of
+// an HCL2 quoted string once its go template calls are transposed. The calls
+// are left as they are.
+func escapeStringLiteral(s string) string {
+	var b strings.Builder
+	for s != "" {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			b.WriteString(hclStringEscaper.Replace(s))
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += start + 2
+		}
+		b.WriteString(hclStringEscaper.Replace(s[:start]))
+		b.WriteString(s[start:end])
+		s = s[end:]
+	}
+	return b.String()
+}
 
-				if duplicate {
-					delete(builder.Config, "source_ami_filter")
-					builder.Config["source_ami"] = sourceAmiDataRef
-					continue
+// upgradeVarFile writes the values of the JSON var file varFile to a
+// NAME.auto.pkrvars.hcl file of dir, so that the upgraded config is set up
+// like the template was with -var-file. Variables tpl does not declare are
+// left out, HCL2 would refuse them, and returned.
+func upgradeVarFile(varFile, dir string, tpl *template.Template) (string, []string, error) {
+	vars := kvflag.FlagJSON{}
+	if err := vars.Set(varFile); err != nil {
+		return "", nil, err
+	}
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f := hclwrite.NewEmptyFile()
+	var undeclared []string
+	for _, key := range keys {
+		if _, declared := tpl.Variables[key]; !declared {
+			undeclared = append(undeclared, key)
+			continue
+		}
+		f.Body().SetAttributeValue(key, cty.StringVal(vars[key]))
+	}
+
+	name := strings.TrimSuffix(filepath.Base(varFile), filepath.Ext(varFile)) + ".auto.pkrvars.hcl"
+	path := filepath.Join(dir, name)
+	if err := (upgradedFile{path, fmt.Sprintf(varFileHeader, filepath.Base(varFile)), f.Bytes()}).write(); err != nil {
+		return "", nil, err
+	}
+	return path, undeclared, nil
+}
+
+// upgradeBuilderNames returns the names of the sources of the builders named
+// names, for the only and except of provisioners and post-processors. Unknown
+// names are kept as is.
+func upgradeBuilderNames(names []string, sourceNames map[string]string) []string {
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if sourceName, found := sourceNames[name]; found {
+			name = sourceName
+		}
+		res = append(res, name)
+	}
+	return res
+}
+
+// writeSourceNames writes the JSON object mapping the name of each builder to
+// the address of its source, like "source.amazon-ebs.autogenerated_1", so
+// that scripts using builder names can be updated.
+func writeSourceNames(path string, sourceNames map[string]string) error {
+	addresses := make(map[string]string, len(sourceNames))
+	for name, sourceName := range sourceNames {
+		addresses[name] = "source." + sourceName
+	}
+	b, err := json.MarshalIndent(addresses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("Failed to write to file: %v", err)
+	}
+	return nil
+}
+
+// upgradedFile is a file written by hcl2_upgrade.
+type upgradedFile struct {
+	path, header string
+	content      []byte
+}
+
+// bytes returns the header followed by the formatted content.
+func (f upgradedFile) bytes() []byte {
+	return append([]byte(f.header), hclwrite.Format(f.content)...)
+}
+
+func (f upgradedFile) write() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("Failed to create output directory: %v", err)
+	}
+	if err := ioutil.WriteFile(f.path, f.bytes(), 0644); err != nil {
+		return fmt.Errorf("Failed to write to file: %v", err)
+	}
+	return nil
+}
+
+// diff returns the unified diff between the file on disk and what it would
+// be upgraded to, which is empty when the file is up to date. A missing file
+// is diffed as empty.
+func (f upgradedFile) diff() ([]byte, error) {
+	current, err := ioutil.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	upgraded := f.bytes()
+	if bytes.Equal(current, upgraded) {
+		return nil, nil
+	}
+	diff, err := hcl2template.BytesDiff(current, upgraded, f.path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to diff %s: %v", f.path, err)
+	}
+	return diff, nil
+}
+
+// upgradeTemplates upgrades every JSON template of cla.Paths, writing each
+// HCL2 config next to its template, or checking it with -check, and ends with
+// a summary telling how each template went.
+func (c *HCL2UpgradeCommand) upgradeTemplates(ctx context.Context, cla *HCL2UpgradeArgs) int {
+	templates, skipped, err := findJSONTemplates(cla.Paths)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	if len(templates) == 0 {
+		c.Ui.Error(fmt.Sprintf("No JSON template found in %s", strings.Join(cla.Paths, ", ")))
+		return 1
+	}
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TEMPLATE\tSTATUS\tOUTPUT")
+	ret := 0
+	// with -split-files, templates of the same folder would overwrite each
+	// other's files.
+	splitDirs := map[string]string{}
+	for _, path := range templates {
+		if ctx.Err() != nil {
+			fmt.Fprintf(w, "%s\tcancelled\t-\n", path)
+			ret = ExitCancelled
+			continue
+		}
+		args := *cla
+		args.Paths = nil
+		args.Path = path
+		args.OutputFile = path + ".pkr.hcl"
+		output := args.OutputFile
+		if args.SplitFiles {
+			args.OutputFile = ""
+			args.OutputDir = filepath.Dir(path)
+			output = args.OutputDir
+			if other, found := splitDirs[args.OutputDir]; found {
+				c.Ui.Error(fmt.Sprintf("Not upgrading %s, the split files of %s were already written to %s",
+					path, other, args.OutputDir))
+				fmt.Fprintf(w, "%s\tfailed\t-\n", path)
+				if ret == 0 {
+					ret = 1
 				}
+				continue
+			}
+			splitDirs[args.OutputDir] = path
+		}
+		c.Ui.Say(fmt.Sprintf("==> Upgrading %s", path))
+		switch code := c.RunContext(ctx, &args); {
+		case code == ExitChanges:
+			fmt.Fprintf(w, "%s\tout of date\t%s\n", path, output)
+			if ret == 0 {
+				ret = ExitChanges
+			}
+		case code != 0:
+			fmt.Fprintf(w, "%s\tfailed\t-\n", path)
+			if ret == 0 || ret == ExitChanges {
+				ret = 1
+			}
+		case cla.Check:
+			fmt.Fprintf(w, "%s\tup to date\t%s\n", path, output)
+		default:
+			fmt.Fprintf(w, "%s\tupgraded\t%s\n", path, output)
+		}
+	}
+	for _, path := range skipped {
+		fmt.Fprintf(w, "%s\tskipped, not a template\t-\n", path)
+	}
+	w.Flush()
+
+	c.Ui.Say("\n==> Upgrade summary:")
+	c.Ui.Say(strings.TrimSuffix(table.String(), "\n"))
+	return ret
+}
+
+// findJSONTemplates returns the JSON templates of paths. A path is either a
+// template, a folder whose .json files are looked at, or a glob pattern. The
+// JSON files of folders and patterns that are not templates, like variable
+// files, are returned as skipped.
+func findJSONTemplates(paths []string) (templates, skipped []string, err error) {
+	seen := map[string]bool{}
+	for _, path := range paths {
+		var matches []string
+		explicit := false
+		fi, statErr := os.Stat(path)
+		switch {
+		case statErr == nil && fi.IsDir():
+			matches, err = filepath.Glob(filepath.Join(path, "*.json"))
+		case statErr == nil:
+			matches, explicit = []string{path}, true
+		case hasGlobMeta(path):
+			matches, err = filepath.Glob(path)
+		default:
+			return nil, nil, statErr
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid pattern %q: %s", path, err)
+		}
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			if explicit || isJSONTemplate(match) {
+				templates = append(templates, match)
+			} else {
+				skipped = append(skipped, match)
+			}
+		}
+	}
+	return templates, skipped, nil
+}
+
+// isJSONTemplate tells whether the file at path looks like a JSON template,
+// that is a JSON object with builders.
+func isJSONTemplate(path string) bool {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return isJSONTemplateSource(b)
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// hcl2Upgraders are the HCL2Upgraders of the plugins whose configuration
+// needs more than a plain translation. The amazon builders are registered
+// here so that their source_ami_filter is converted to a data source.
+var hcl2Upgraders = &packer.HCL2UpgraderRegistry{
+	Builders: map[string]packer.HCL2Upgrader{
+		"amazon-chroot":       amazonHCL2Upgrader{},
+		"amazon-ebs":          amazonHCL2Upgrader{},
+		"amazon-ebssurrogate": amazonHCL2Upgrader{},
+		"amazon-ebsvolume":    amazonHCL2Upgrader{},
+		"amazon-instance":     amazonHCL2Upgrader{},
+	},
+}
+
+// configSpec returns the configuration spec of plugin, or nil when it is
+// unknown or could not be started. Every type of plugin is started once.
+func (c *HCL2UpgradeCommand) configSpec(plugin pluginRef) hcldec.ObjectSpec {
+	key := plugin.kind + "." + plugin.typ
+	if spec, ok := c.specs[key]; ok {
+		return spec
+	}
+	if c.specs == nil {
+		c.specs = map[string]hcldec.ObjectSpec{}
+	}
+
+	components := c.Meta.CoreConfig.Components.PluginConfig
+	var speccer packersdk.HCL2Speccer
+	var err error
+	switch {
+	case plugin.kind == "builder" && components.Builders.Has(plugin.typ):
+		speccer, err = components.Builders.Start(plugin.typ)
+	case plugin.kind == "provisioner" && components.Provisioners.Has(plugin.typ):
+		speccer, err = components.Provisioners.Start(plugin.typ)
+	case plugin.kind == "post-processor" && components.PostProcessors.Has(plugin.typ):
+		speccer, err = components.PostProcessors.Start(plugin.typ)
+	}
+	var spec hcldec.ObjectSpec
+	if err != nil {
+		log.Printf("[WARN] could not start %s %s to read its settings: %s", plugin.typ, plugin.kind, err)
+	} else if speccer != nil {
+		spec = speccer.ConfigSpec()
+	}
+	c.specs[key] = spec
+	return spec
+}
+
+// unknownKeyWarnings returns a warning for each top-level key of config that
+// the plugin does not declare in its configuration spec, by key. These are
+// most likely misspelled settings. It returns nil when the spec of the plugin
+// cannot be known.
+func (c *HCL2UpgradeCommand) unknownKeyWarnings(plugin pluginRef, config map[string]interface{}) map[string]string {
+	spec := c.configSpec(plugin)
+	if spec == nil {
+		return nil
+	}
+	warnings := map[string]string{}
+	for k := range config {
+		if _, found := spec[k]; !found {
+			warnings[k] = fmt.Sprintf("WARNING: unknown key %q, the %s %s does not declare it", k, plugin.typ, plugin.kind)
+		}
+	}
+	return warnings
+}
+
+// upgradableConfig is the configuration of a plugin of the template.
+type upgradableConfig struct {
+	plugin pluginRef
+	config map[string]interface{}
+}
+
+// upgradableConfigs returns the configurations of the builders, provisioners
+// and post-processors of tpl, in the order they are written.
+func upgradableConfigs(tpl *template.Template, builders []*template.Builder) []upgradableConfig {
+	var configs []upgradableConfig
+	for _, builder := range builders {
+		configs = append(configs, upgradableConfig{pluginRef{kind: "builder", typ: builder.Type}, builder.Config})
+	}
+	for _, provisioner := range tpl.Provisioners {
+		configs = append(configs, upgradableConfig{pluginRef{kind: "provisioner", typ: provisioner.Type}, provisioner.Config})
+	}
+	for _, pps := range tpl.PostProcessors {
+		for _, pp := range pps {
+			configs = append(configs, upgradableConfig{pluginRef{kind: "post-processor", typ: pp.Type}, pp.Config})
+		}
+	}
+	return configs
+}
+
+// writeUpgradeDatasources asks the plugins how to upgrade their
+// configuration, applies it and writes the data sources they need. Identical
+// data sources are written once and shared by the plugins.
+func (c *HCL2UpgradeCommand) writeUpgradeDatasources(configs []upgradableConfig, out *bytes.Buffer, isotimes *isotimeLocals) error {
+	written := map[string][]map[string]interface{}{}
+	for _, cfg := range configs {
+		if cfg.config == nil {
+			continue
+		}
+		upgrader := hcl2Upgraders.Lookup(cfg.plugin.kind, cfg.plugin.typ)
+		if upgrader == nil {
+			continue
+		}
+		upgrade, err := upgrader.HCL2Upgrade(cfg.config)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to upgrade %s %s: %v", cfg.plugin.typ, cfg.plugin.kind, err))
+			return err
+		}
+		if upgrade == nil {
+			continue
+		}
+
+		if upgrade.Config != nil {
+			// The configuration is replaced in place, as it is shared with
+			// the template.
+			for k := range cfg.config {
+				delete(cfg.config, k)
+			}
+			for k, v := range upgrade.Config {
+				cfg.config[k] = v
+			}
+		}
 
-				amazonAmiFilters = append(amazonAmiFilters, sourceAmiFilterCfg)
-				delete(builder.Config, "source_ami_filter")
-				builder.Config["source_ami"] = sourceAmiDataRef
-				i++
+		for from, to := range upgrade.Renamed {
+			if v, found := cfg.config[from]; found {
+				delete(cfg.config, from)
+				cfg.config[to] = v
+			}
+		}
 
-				if first {
-					out.Write([]byte(amazonAmiDataHeader))
-					first = false
+		for _, ds := range upgrade.Datasources {
+			configs := written[ds.Type]
+			index := -1
+			for i, config := range configs {
+				if reflect.DeepEqual(config, ds.Config) {
+					index = i
+					break
+				}
+			}
+			if index == -1 {
+				index = len(configs)
+				written[ds.Type] = append(configs, ds.Config)
+				if index == 0 {
+					fmt.Fprintf(out, datasourceHeader, strings.ReplaceAll(ds.Description, "\n", "\n# "))
 				}
 				datasourceContent := hclwrite.NewEmptyFile()
 				body := datasourceContent.Body()
 				body.AppendNewline()
-				sourceBody := body.AppendNewBlock("data", []string{"amazon-ami", dataSourceName}).Body()
-				jsonBodyToHCL2Body(sourceBody, sourceAmiFilterCfg)
-				_, _ = out.Write(transposeTemplatingCalls(datasourceContent.Bytes()))
+				appendComments(body, extractComments(ds.Config, nil))
+				dsBody := body.AppendNewBlock("data", []string{ds.Type, fmt.Sprintf("autogenerated_%d", index+1)}).Body()
+				jsonBodyToHCL2Body(dsBody, ds.Config, nil)
+				_, _ = out.Write(transposeTemplatingCalls(datasourceContent.Bytes(), isotimes))
+			}
+
+			for _, setting := range ds.Replaces {
+				delete(cfg.config, setting)
 			}
+			// This is a hack...
+			// Use templating so that it could be correctly transformed later into a data resource
+			cfg.config[ds.Setting] = fmt.Sprintf("{{ data `%s.autogenerated_%d.%s` }}", ds.Type, index+1, ds.Output)
 		}
 	}
 
 	return nil
 }
 
+// amazonHCL2Upgrader converts the source_ami_filter of amazon builders to an
+// amazon-ami data source.
+type amazonHCL2Upgrader struct{}
+
+func (amazonHCL2Upgrader) HCL2Upgrade(config map[string]interface{}) (*packer.HCL2Upgrade, error) {
+	sourceAmiFilter, ok := config["source_ami_filter"]
+	if !ok {
+		return nil, nil
+	}
+	sourceAmiFilterCfg := map[string]interface{}{}
+	if err := mapstructure.Decode(sourceAmiFilter, &sourceAmiFilterCfg); err != nil {
+		return nil, fmt.Errorf("failed to write amazon-ami data source: %v", err)
+	}
+	return &packer.HCL2Upgrade{
+		Datasources: []packer.HCL2UpgradeDatasource{{
+			Type: "amazon-ami",
+			Description: "The amazon-ami data block is generated from your amazon builder source_ami_filter; a data\n" +
+				"from this block can be referenced in source and locals blocks.",
+			Config:   sourceAmiFilterCfg,
+			Replaces: []string{"source_ami_filter"},
+			Setting:  "source_ami",
+			Output:   "id",
+		}},
+	}, nil
+}
+
 type UnhandleableArgumentError struct {
 	Call           string
 	Correspondance string
@@ -366,10 +1197,60 @@ func (uc UnhandleableArgumentError) Error() string {
 # Visit %s for more infos.`, uc.Call, uc.Correspondance, uc.Docs)
 }
 
+// pluginRef designates the plugin a block configures.
+type pluginRef struct {
+	kind, typ string
+	// name is the name of builders in JSON, what build_name returns in their
+	// fields.
+	name string
+}
+
+// processTemplatePlaceholders lists, per plugin type, the fields of the go
+// templates that the plugin interpolates itself when it runs, like the path of
+// the script in the execute_command of the shell provisioner. These remain go
+// templates in HCL2.
+var processTemplatePlaceholders = map[string][]string{
+	"shell":         {"Vars", "Path"},
+	"shell-local":   {"Vars", "Script", "Command"},
+	"powershell":    {"Vars", "Path"},
+	"windows-shell": {"Vars", "Path"},
+	"checksum":      {"ChecksumType"},
+	"vagrant":       {"Provider", "ArtifactId"},
+}
+
+// postProcessorPlaceholders are the fields of the go templates of
+// post-processors that have an HCL2 equivalent: the name and the type of the
+// builder of the artifact, which are the ones of the source in HCL2.
+var postProcessorPlaceholders = map[string]string{
+	"BuildName":   "${source.name}",
+	"BuilderType": "${source.type}",
+}
+
+// buildNamePlaceholderRe matches the {{ .BuildName }} placeholder of go
+// templates.
+var buildNamePlaceholderRe = regexp.MustCompile(`{{-?\s*\.BuildName\s*-?}}`)
+
+// postProcessorsUseBuildName tells whether the settings of a post-processor of
+// tpl use the {{ .BuildName }} placeholder.
+func postProcessorsUseBuildName(tpl *template.Template) bool {
+	for _, pps := range tpl.PostProcessors {
+		for _, pp := range pps {
+			cfg, err := json.Marshal(pp.Config)
+			if err == nil && buildNamePlaceholderRe.Match(cfg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // transposeTemplatingCalls executes parts of blocks as go template files and replaces
 // their result with their hcl2 variant. If something goes wrong the template
-// containing the go template string is returned.
-func transposeTemplatingCalls(s []byte) []byte {
+// containing the go template string is returned. The formats of isotime calls
+// are added to isotimes. The placeholders interpolated by the plugins
+// configured by the blocks are left intact, with a comment telling so, unless
+// they have an HCL2 equivalent.
+func transposeTemplatingCalls(s []byte, isotimes *isotimeLocals, plugins ...pluginRef) []byte {
 	fallbackReturn := func(err error) []byte {
 		if strings.Contains(err.Error(), "unhandled") {
 			return append([]byte(fmt.Sprintf("\n# %s\n", err)), s...)
@@ -377,12 +1258,22 @@ func transposeTemplatingCalls(s []byte) []byte {
 
 		return append([]byte(fmt.Sprintf("\n# could not parse template for following block: %q\n", err)), s...)
 	}
+	// inlined are the values of the calls replaced by their value in JSON,
+	// by function.
+	inlined := map[string]string{}
 	funcMap := texttemplate.FuncMap{
 		"timestamp": func() string {
 			return "${local.timestamp}"
 		},
-		"isotime": func() string {
-			return "${local.timestamp}"
+		"isotime": func(format ...string) (string, error) {
+			if len(format) == 0 {
+				return "${local.timestamp}", nil
+			}
+			name, err := isotimes.add(format[0])
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("${local.%s}", name), nil
 		},
 		"user": func(in string) string {
 			return fmt.Sprintf("${var.%s}", in)
@@ -443,7 +1334,12 @@ func transposeTemplatingCalls(s []byte) []byte {
 				"https://www.packer.io/docs/templates/hcl_templates/functions/string/replace or https://www.packer.io/docs/templates/hcl_templates/functions/string/regex_replace",
 			}
 		},
-		"clean_resource_name": func(_ string) (string, error) {
+		"clean_resource_name": func(name string) (string, error) {
+			for _, plugin := range plugins {
+				if expr, ok := cleanResourceNameExpr(plugin, name); ok {
+					return fmt.Sprintf("${%s}", expr), nil
+				}
+			}
 			return "", UnhandleableArgumentError{
 				"clean_resource_name",
 				"use custom validation rules, `replace(string, substring, replacement)` or `regex_replace(string, substring, replacement)`",
@@ -452,11 +1348,30 @@ func transposeTemplatingCalls(s []byte) []byte {
 					" or https://www.packer.io/docs/templates/hcl_templates/functions/string/regex_replace",
 			}
 		},
+		"vault": func(path, key string) string {
+			return fmt.Sprintf("${vault(%q, %q)}", path, key)
+		},
+		// In JSON, build_name and build_type are the name and type of the
+		// builder. Provisioners and post-processors get them from the source
+		// of their build, but source blocks cannot reference themselves, so
+		// builders get the values they had in JSON.
 		"build_name": func() string {
-			return fmt.Sprintf("${build.name}")
+			for _, plugin := range plugins {
+				if plugin.kind == "builder" {
+					inlined["build_name"] = plugin.name
+					return plugin.name
+				}
+			}
+			return "${source.name}"
 		},
 		"build_type": func() string {
-			return fmt.Sprintf("${build.type}")
+			for _, plugin := range plugins {
+				if plugin.kind == "builder" {
+					inlined["build_type"] = plugin.typ
+					return plugin.typ
+				}
+			}
+			return "${source.type}"
 		},
 	}
 
@@ -469,21 +1384,306 @@ func transposeTemplatingCalls(s []byte) []byte {
 	}
 
 	str := &bytes.Buffer{}
-	v := struct {
-		HTTPIP   string
-		HTTPPort string
-	}{
-		HTTPIP:   "{{ .HTTPIP }}",
-		HTTPPort: "{{ .HTTPPort }}",
-	}
-	if err := tpl.Execute(str, v); err != nil {
+	v := map[string]string{
+		"HTTPIP":   "{{ .HTTPIP }}",
+		"HTTPPort": "{{ .HTTPPort }}",
+	}
+	for _, plugin := range plugins {
+		if plugin.kind == "post-processor" {
+			for field, value := range postProcessorPlaceholders {
+				v[field] = value
+			}
+		}
+		for _, field := range processTemplatePlaceholders[plugin.typ] {
+			v[field] = fmt.Sprintf("{{ .%s }}", field)
+		}
+	}
+	if err := tpl.Option("missingkey=error").Execute(str, v); err != nil {
 		return fallbackReturn(err)
 	}
 
+	var comments []string
+	for _, fn := range []string{"build_name", "build_type"} {
+		if value, found := inlined[fn]; found {
+			comments = append(comments, fmt.Sprintf("# {{ %s }} was replaced by %q, its value in JSON: a source block cannot reference its own name or type.",
+				fn, value))
+		}
+	}
+	for _, plugin := range plugins {
+		var kept []string
+		for _, field := range processTemplatePlaceholders[plugin.typ] {
+			if bytes.Contains(str.Bytes(), []byte(v[field])) {
+				kept = append(kept, v[field])
+			}
+		}
+		if len(kept) > 0 {
+			comments = append(comments, fmt.Sprintf("# %s: interpolated by the %s %s when it runs, left as is.",
+				strings.Join(kept, ", "), plugin.typ, plugin.kind))
+		}
+	}
+	if len(comments) > 0 {
+		return append([]byte("\n"+strings.Join(comments, "\n")+"\n"), str.Bytes()...)
+	}
 	return str.Bytes()
 }
 
-func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}) {
+// cleanResourceNameExpr returns the HCL2 expression doing what the
+// clean_resource_name function of the builder of plugin does to name, the
+// result of the go template before the call. Each builder replaces the
+// characters its cloud does not allow in resource names with dashes.
+func cleanResourceNameExpr(plugin pluginRef, name string) (string, bool) {
+	if plugin.kind != "builder" {
+		return "", false
+	}
+	if strings.HasPrefix(name, "${") && strings.HasSuffix(name, "}") && strings.Count(name, "${") == 1 {
+		name = strings.TrimSuffix(strings.TrimPrefix(name, "${"), "}")
+	} else {
+		name = `"` + name + `"`
+	}
+	switch {
+	case strings.HasPrefix(plugin.typ, "amazon-"), strings.HasPrefix(plugin.typ, "osc-"):
+		return fmt.Sprintf(`regex_replace(%s, "[^]a-zA-Z0-9()[ ./'@_-]", "-")`, name), true
+	case strings.HasPrefix(plugin.typ, "azure-"):
+		// azure also trims the characters names cannot end with
+		return fmt.Sprintf(`regex_replace(regex_replace(%s, "[^a-zA-Z0-9._-]", "-"), "[-_.]+$", "")`, name), true
+	case plugin.typ == "googlecompute", plugin.typ == "yandex":
+		return fmt.Sprintf(`regex_replace(lower(%s), "[^a-z0-9]", "-")`, name), true
+	}
+	return "", false
+}
+
+// isotimeLocals holds the locals replacing the isotime calls that have a
+// format argument. There is one local per format, named after the Go layout so
+// that names are stable across upgrades.
+type isotimeLocals struct {
+	names   []string
+	layouts map[string]string
+	specs   map[string]string
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// add returns the name of the local holding the current time formatted with
+// layout.
+func (l *isotimeLocals) add(layout string) (string, error) {
+	spec, err := goLayoutToFormatdate(layout)
+	if err != nil {
+		return "", err
+	}
+	if l.layouts == nil {
+		l.layouts = map[string]string{}
+		l.specs = map[string]string{}
+	}
+	base := strings.Trim("isotime_"+nonIdentifierChars.ReplaceAllString(layout, "_"), "_")
+	name := base
+	for i := 2; ; i++ {
+		existing, found := l.layouts[name]
+		if !found {
+			break
+		}
+		if existing == layout {
+			return name, nil
+		}
+		// another layout with the same identifier characters
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	l.names = append(l.names, name)
+	l.layouts[name] = layout
+	l.specs[name] = spec
+	return name, nil
+}
+
+func (l *isotimeLocals) writeLocals(out io.Writer) {
+	if len(l.names) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\n# \"isotime\" template function replacements")
+	content := hclwrite.NewEmptyFile()
+	body := content.Body().AppendNewBlock("locals", nil).Body()
+	for _, name := range l.names {
+		spec := hclwrite.TokensForValue(cty.StringVal(l.specs[name])).Bytes()
+		body.SetAttributeRaw(name, hclwrite.Tokens{&hclwrite.Token{
+			Bytes: []byte(fmt.Sprintf("formatdate(%s, timestamp())", spec)),
+		}})
+	}
+	_, _ = content.WriteTo(out)
+}
+
+// goLayoutChunks maps the elements of a Go reference time layout to their
+// formatdate equivalent. An empty spec marks elements formatdate cannot
+// represent. Longer elements come first so that they are matched before their
+// prefixes.
+var goLayoutChunks = []struct{ layout, spec string }{
+	{"January", "MMMM"},
+	{"Jan", "MMM"},
+	{"Monday", "EEEE"},
+	{"Mon", "EEE"},
+	{"MST", "ZZZ"},
+	{"2006", "YYYY"},
+	{"002", ""},
+	{"01", "MM"},
+	{"02", "DD"},
+	{"03", "HH"},
+	{"04", "mm"},
+	{"05", "ss"},
+	{"06", "YY"},
+	{"15", "hh"},
+	{"_2", ""},
+	{"PM", "AA"},
+	{"pm", "aa"},
+	{"Z07:00:00", ""},
+	{"Z070000", ""},
+	{"Z07:00", "Z"},
+	{"Z0700", ""},
+	{"Z07", ""},
+	{"-07:00:00", ""},
+	{"-070000", ""},
+	{"-07:00", "ZZZZZ"},
+	{"-0700", "ZZZZ"},
+	{"-07", ""},
+	{"1", "M"},
+	{"2", "D"},
+	{"3", "H"},
+	{"4", "m"},
+	{"5", "s"},
+}
+
+// goLayoutToFormatdate converts a Go reference time layout, as used by the
+// isotime template function, to a formatdate specification.
+func goLayoutToFormatdate(layout string) (string, error) {
+	unhandled := UnhandleableArgumentError{
+		fmt.Sprintf("isotime %q", layout),
+		"`formatdate(format, timestamp())`",
+		"https://www.packer.io/docs/templates/hcl_templates/functions/datetime/formatdate",
+	}
+	spec := &strings.Builder{}
+	literal := &strings.Builder{}
+	flushLiteral := func() {
+		lit := literal.String()
+		literal.Reset()
+		if strings.IndexFunc(lit, func(r rune) bool {
+			return r == '\'' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		}) == -1 {
+			spec.WriteString(lit)
+			return
+		}
+		// letters are verbs unless quoted
+		spec.WriteString("'" + strings.ReplaceAll(lit, "'", "''") + "'")
+	}
+
+	for i := 0; i < len(layout); {
+		if c := layout[i]; (c == '.' || c == ',') && i+1 < len(layout) && (layout[i+1] == '0' || layout[i+1] == '9') {
+			j := i + 1
+			for j < len(layout) && layout[j] == layout[i+1] {
+				j++
+			}
+			if j == len(layout) || layout[j] < '0' || layout[j] > '9' {
+				// fractional seconds
+				return "", unhandled
+			}
+		}
+		matched := false
+		for _, chunk := range goLayoutChunks {
+			if !strings.HasPrefix(layout[i:], chunk.layout) {
+				continue
+			}
+			if chunk.spec == "" {
+				return "", unhandled
+			}
+			if literal.Len() > 0 {
+				flushLiteral()
+			} else if prev := spec.String(); prev != "" && prev[len(prev)-1] == chunk.spec[0] {
+				// two consecutive verbs of the same letter would be read as
+				// a single one.
+				return "", unhandled
+			}
+			spec.WriteString(chunk.spec)
+			i += len(chunk.layout)
+			matched = true
+			break
+		}
+		if !matched {
+			literal.WriteByte(layout[i])
+			i++
+		}
+	}
+	flushLiteral()
+	return spec.String(), nil
+}
+
+// postProcessorsChainComment describes how artifacts flow through the
+// post-processors of the nth chain of the template, since the order of the
+// post-processor blocks of a post-processors block is easy to overlook.
+func postProcessorsChainComment(n int, pps []*template.PostProcessor) string {
+	name := func(pp *template.PostProcessor) string {
+		if pp.Name != "" && pp.Name != pp.Type {
+			return fmt.Sprintf("%s %q", pp.Type, pp.Name)
+		}
+		return pp.Type
+	}
+	lines := []string{fmt.Sprintf("Post-processors chain %d, each post-processor gets the artifact of the previous one:", n)}
+	filtered := false
+	for i, pp := range pps {
+		input := "the artifact of the build"
+		if i > 0 {
+			input = "the artifact of " + name(pps[i-1])
+		}
+		line := fmt.Sprintf("  %d. %s gets %s", i+1, name(pp), input)
+		if pp.KeepInputArtifact != nil && *pp.KeepInputArtifact {
+			line += " and keeps it"
+		}
+		lines = append(lines, line)
+		filtered = filtered || len(pp.Only) > 0 || len(pp.Except) > 0
+	}
+	if filtered {
+		lines = append(lines, "A post-processor skipped by its only or except passes the artifact it gets on to the next one.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// extractComments removes the comments of a JSON object from kvs and returns
+// them, sorted by key. Like at the root of templates, keys starting with an
+// underscore, like "_comment", are comments when their value is a string. A
+// "comment" key is one too, when the plugin does not declare it, as told by
+// its unknown key warnings.
+func extractComments(kvs map[string]interface{}, warnings map[string]string) []string {
+	var keys []string
+	for k, v := range kvs {
+		if _, isString := v.(string); !isString {
+			continue
+		}
+		if _, unknown := warnings[k]; strings.HasPrefix(k, "_") || (k == "comment" && unknown) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	comments := make([]string, 0, len(keys))
+	for _, k := range keys {
+		comments = append(comments, kvs[k].(string))
+		delete(kvs, k)
+	}
+	return comments
+}
+
+// appendComments appends comments to out, one "#" line per line of comment.
+func appendComments(out *hclwrite.Body, comments []string) {
+	for _, comment := range comments {
+		out.AppendUnstructuredTokens(commentTokens(comment))
+	}
+}
+
+func commentTokens(comment string) hclwrite.Tokens {
+	var tokens hclwrite.Tokens
+	for _, line := range strings.Split(strings.TrimRight(comment, "\n"), "\n") {
+		tokens = append(tokens, &hclwrite.Token{
+			Type:  hclsyntax.TokenComment,
+			Bytes: []byte(strings.TrimRight("# "+line, " ") + "\n"),
+		})
+	}
+	return tokens
+}
+
+func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}, warnings map[string]string) {
 	ks := []string{}
 	for k := range kvs {
 		ks = append(ks, k)
@@ -493,6 +1693,10 @@ func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}) {
 	for _, k := range ks {
 		value := kvs[k]
 
+		if warning, found := warnings[k]; found {
+			out.AppendUnstructuredTokens(commentTokens(warning))
+		}
+
 		switch value := value.(type) {
 		case map[string]interface{}:
 			var mostComplexElem interface{}
@@ -523,8 +1727,9 @@ func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}) {
 			case string, int, float64, bool:
 				out.SetAttributeValue(k, hcl2shim.HCL2ValueFromConfigValue(value))
 			default:
+				appendComments(out, extractComments(value, nil))
 				nestedBlockBody := out.AppendNewBlock(k, nil).Body()
-				jsonBodyToHCL2Body(nestedBlockBody, value)
+				jsonBodyToHCL2Body(nestedBlockBody, value, nil)
 			}
 		case map[string]string, map[string]int, map[string]float64:
 			out.SetAttributeValue(k, hcl2shim.HCL2ValueFromConfigValue(value))
@@ -556,8 +1761,9 @@ func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}) {
 				// this might not work everywhere.
 				for i := range value {
 					value := value[i].(map[string]interface{})
+					appendComments(out, extractComments(value, nil))
 					nestedBlockBody := out.AppendNewBlock(k, nil).Body()
-					jsonBodyToHCL2Body(nestedBlockBody, value)
+					jsonBodyToHCL2Body(nestedBlockBody, value, nil)
 				}
 				continue
 			default:
@@ -569,6 +1775,64 @@ func jsonBodyToHCL2Body(out *hclwrite.Body, kvs map[string]interface{}) {
 	}
 }
 
+// localPathKeys are the builder settings referencing local files or
+// directories.
+var localPathKeys = []string{"cd_files", "floppy_dirs", "floppy_files", "http_directory"}
+
+var templateDirCall = regexp.MustCompile(`{{\s*template_dir\s*}}`)
+
+// missingLocalPaths returns the local paths of a builder config that do not
+// exist relative to templateDir, formatted as "key: path". Paths containing
+// template calls other than template_dir cannot be checked and are ignored.
+func missingLocalPaths(templateDir string, cfg map[string]interface{}) []string {
+	missing := []string{}
+	for _, key := range localPathKeys {
+		var paths []interface{}
+		switch v := cfg[key].(type) {
+		case string:
+			paths = []interface{}{v}
+		case []interface{}:
+			paths = v
+		}
+		for _, path := range paths {
+			path, ok := path.(string)
+			if !ok || path == "" {
+				continue
+			}
+			resolved := path
+			if !filepath.IsAbs(resolved) && !templateDirCall.MatchString(resolved) {
+				resolved = filepath.Join(templateDir, resolved)
+			}
+			resolved = templateDirCall.ReplaceAllLiteralString(resolved, templateDir)
+			if strings.Contains(resolved, "{{") {
+				continue
+			}
+			// floppy_files and cd_files accept glob patterns
+			if matches, err := filepath.Glob(resolved); err == nil && len(matches) > 0 {
+				continue
+			}
+			missing = append(missing, fmt.Sprintf("%s: %s", key, path))
+		}
+	}
+	return missing
+}
+
+// envDefaultRe matches variable defaults that are only an environment
+// variable, which are empty when it is not set.
+var envDefaultRe = regexp.MustCompile("^{{\\s*env\\s+(`[^`]*`|\"[^\"]*\")\\s*}}$")
+
+// appendNonEmptyValidation appends a validation block failing when the
+// variable is empty, which is how JSON fails when a required variable is not
+// set.
+func appendNonEmptyValidation(variableBody *hclwrite.Body, key string) {
+	variableBody.AppendNewline()
+	validationBody := variableBody.AppendNewBlock("validation", nil).Body()
+	validationBody.SetAttributeRaw("condition", hclwrite.Tokens{
+		&hclwrite.Token{Bytes: []byte(fmt.Sprintf("length(var.%s) > 0", key))},
+	})
+	validationBody.SetAttributeValue("error_message", cty.StringVal(fmt.Sprintf("The %s variable must be set to a non-empty value.", key)))
+}
+
 func isSensitiveVariable(key string, vars []*template.Variable) bool {
 	for _, v := range vars {
 		if v.Key == key {
@@ -580,9 +1844,42 @@ func isSensitiveVariable(key string, vars []*template.Variable) bool {
 
 func (*HCL2UpgradeCommand) Help() string {
 	helpText := `
-Usage: packer hcl2_upgrade -output-file=JSON_TEMPLATE.pkr.hcl JSON_TEMPLATE...
+Usage: packer hcl2_upgrade [-check] [-output-file=JSON_TEMPLATE.pkr.hcl|-] JSON_TEMPLATE
+       packer hcl2_upgrade -split-files [-output-dir=FOLDER] JSON_TEMPLATE
+       packer hcl2_upgrade [-split-files] FOLDER|PATTERN|JSON_TEMPLATE...
 
   Will transform your JSON template into an HCL2 configuration.
+
+  When given a folder, a glob pattern or several templates, every JSON
+  template found is upgraded to a TEMPLATE.pkr.hcl file next to it, and a
+  summary of the upgrades is shown at the end. JSON files that are not
+  templates, like variable files, are skipped.
+
+Options:
+
+  -output-file=path    File where to put the HCL2 config of a single template.
+                       Defaults to JSON_TEMPLATE.pkr.hcl.
+  -split-files         Write the variables and locals, the sources and the
+                       build to variables.pkr.hcl, sources.pkr.hcl and
+                       build.pkr.hcl instead of a single file.
+  -output-dir=path     With -split-files, folder where to put the files of a
+                       single template. Defaults to the folder of the template.
+  -var-file=path       JSON var file the template is used with. Its values are
+                       written to NAME.auto.pkrvars.hcl next to the HCL2
+                       config. Can be set several times.
+  -keep-builder-names  Name the sources after their builder even when the
+                       builder has no name, instead of autogenerated_N.
+  -names-file=path     Write a JSON object mapping the name of each builder to
+                       the address of its source to this file.
+  -with-validation     Add a validation block failing on empty values to the
+                       required variables and to the variables defaulting to
+                       an environment variable.
+  -check               Write nothing, show the diff between the HCL2 files and
+                       what they would be upgraded to instead. Exits with 6 if
+                       they are out of date.
+
+  Set -output-file=- to print the HCL2 config of a single template instead of
+  writing it.
 `
 
 	return strings.TrimSpace(helpText)
@@ -1,12 +1,23 @@
 package command
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template"
+	"github.com/hashicorp/packer/packer"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func Test_hcl2_upgrade(t *testing.T) {
@@ -20,6 +31,14 @@ func Test_hcl2_upgrade(t *testing.T) {
 		folder string
 	}{
 		{"hcl2_upgrade_basic"},
+		{"hcl2_upgrade_local_paths"},
+		{"hcl2_upgrade_vault"},
+		{"hcl2_upgrade_clean_resource_name"},
+		{"hcl2_upgrade_user_locals"},
+		{"hcl2_upgrade_post_processor_placeholders"},
+		{"hcl2_upgrade_comments"},
+		{"hcl2_upgrade_build_name"},
+		{"hcl2_upgrade_escape"},
 	}
 
 	for _, tc := range tc {
@@ -43,9 +62,515 @@ func Test_hcl2_upgrade(t *testing.T) {
 	}
 }
 
+func Test_hcl2_upgrade_missingLocalPaths(t *testing.T) {
+	templateDir := testFixture("hcl2_upgrade_local_paths")
+	cfg := map[string]interface{}{
+		"http_directory": "http",
+		"floppy_files": []interface{}{
+			"{{template_dir}}/floppy/*.sh",
+			"{{ template_dir }}/floppy/*.cmd",
+			"floppy/autounattend.xml",
+			"{{user `scripts_dir`}}/setup.sh",
+		},
+		"floppy_dirs": []interface{}{"drivers"},
+		"cd_files":    []interface{}{"http/*.cfg"},
+	}
+
+	expected := []string{
+		"floppy_dirs: drivers",
+		"floppy_files: {{ template_dir }}/floppy/*.cmd",
+		"floppy_files: floppy/autounattend.xml",
+	}
+	if diff := cmp.Diff(expected, missingLocalPaths(templateDir, cfg)); diff != "" {
+		t.Fatalf("unexpected missing paths: %s", diff)
+	}
+}
+
 func mustBytes(b []byte, e error) []byte {
 	if e != nil {
 		panic(e)
 	}
 	return b
 }
+
+// mockSpecBuilder declares the settings of the mock-iso builder.
+type mockSpecBuilder struct {
+	packersdk.MockBuilder
+}
+
+func (b *mockSpecBuilder) ConfigSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"image_family": &hcldec.AttrSpec{Name: "image_family", Type: cty.String},
+		"source_image": &hcldec.AttrSpec{Name: "source_image", Type: cty.String},
+		"ssh_password": &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String},
+	}
+}
+
+// mockImageUpgrader renames ssh_pass and turns image_family into a mock-image
+// data source.
+type mockImageUpgrader struct{}
+
+func (mockImageUpgrader) HCL2Upgrade(config map[string]interface{}) (*packer.HCL2Upgrade, error) {
+	return &packer.HCL2Upgrade{
+		Renamed: map[string]string{"ssh_pass": "ssh_password"},
+		Datasources: []packer.HCL2UpgradeDatasource{{
+			Type:        "mock-image",
+			Description: "The mock-image data block is generated from your mock-iso builder image_family.",
+			Config:      map[string]interface{}{"family": config["image_family"]},
+			Replaces:    []string{"image_family"},
+			Setting:     "source_image",
+			Output:      "id",
+		}},
+	}, nil
+}
+
+func Test_hcl2_upgrade_pluginUpgrader(t *testing.T) {
+	meta := commandMeta()
+	meta.CoreConfig.Components.PluginConfig.Builders.Set("mock-iso", func() (packersdk.Builder, error) {
+		return &mockSpecBuilder{}, nil
+	})
+	hcl2Upgraders.Builders["mock-iso"] = mockImageUpgrader{}
+	defer delete(hcl2Upgraders.Builders, "mock-iso")
+	c := &HCL2UpgradeCommand{Meta: meta}
+
+	inputPath := testFixture("hcl2_upgrade_plugin", "input.json")
+	outputPath := inputPath + ".pkr.hcl"
+	defer os.Remove(outputPath)
+	if code := c.Run([]string{inputPath}); code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+
+	expected := mustBytes(ioutil.ReadFile(testFixture("hcl2_upgrade_plugin", "expected.pkr.hcl")))
+	actual := mustBytes(ioutil.ReadFile(outputPath))
+	if diff := cmp.Diff(string(expected), string(actual)); diff != "" {
+		t.Fatalf("unexpected output: %s", diff)
+	}
+}
+
+// mockEnvironmentUpgrader turns the environment object of a provisioner into
+// a list of environment_vars.
+type mockEnvironmentUpgrader struct{}
+
+func (mockEnvironmentUpgrader) HCL2Upgrade(config map[string]interface{}) (*packer.HCL2Upgrade, error) {
+	env, ok := config["environment"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	upgraded := map[string]interface{}{}
+	for k, v := range config {
+		upgraded[k] = v
+	}
+	delete(upgraded, "environment")
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(vars)
+	upgraded["environment_vars"] = vars
+	return &packer.HCL2Upgrade{Config: upgraded}, nil
+}
+
+func Test_hcl2_upgrade_registeredUpgrader(t *testing.T) {
+	hcl2Upgraders.Provisioners = map[string]packer.HCL2Upgrader{"shell-local": mockEnvironmentUpgrader{}}
+	defer func() { hcl2Upgraders.Provisioners = nil }()
+
+	inputPath := testFixture("hcl2_upgrade_registry", "input.json")
+	outputPath := inputPath + ".pkr.hcl"
+	defer os.Remove(outputPath)
+	c := &HCL2UpgradeCommand{Meta: commandMeta()}
+	if code := c.Run([]string{inputPath}); code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+
+	expected := mustBytes(ioutil.ReadFile(testFixture("hcl2_upgrade_registry", "expected.pkr.hcl")))
+	actual := mustBytes(ioutil.ReadFile(outputPath))
+	if diff := cmp.Diff(string(expected), string(actual)); diff != "" {
+		t.Fatalf("unexpected output: %s", diff)
+	}
+}
+
+func Test_goLayoutToFormatdate(t *testing.T) {
+	tests := []struct {
+		layout  string
+		want    string
+		wantErr bool
+	}{
+		{"2006-01-02", "YYYY-MM-DD", false},
+		{"20060102150405", "YYYYMMDDhhmmss", false},
+		{"Monday, January 2 2006 3:04PM", "EEEE, MMMM D YYYY H:mmAA", false},
+		{"2006-01-02T15:04:05Z07:00", "YYYY-MM-DD'T'hh:mm:ssZ", false},
+		{"02 Jan 06 15:04 -0700", "DD MMM YY hh:mm ZZZZ", false},
+		{"it's 15h04", "'it''s 'hh'h'mm", false},
+		{"2006-01-02 15:04:05.000", "", true},
+		{"Jan _2", "", true},
+		{"200606", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			got, err := goLayoutToFormatdate(tt.layout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goLayoutToFormatdate(%q) error = %v, wantErr %t", tt.layout, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("goLayoutToFormatdate(%q) = %q, want %q", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isotimeLocals_add(t *testing.T) {
+	l := &isotimeLocals{}
+	var names []string
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "2006-01-02"} {
+		name, err := l.add(layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	expected := []string{"isotime_2006_01_02", "isotime_2006_01_02_2", "isotime_2006_01_02"}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Fatalf("unexpected local names: %s", diff)
+	}
+}
+
+func Test_requiredPackerVersion(t *testing.T) {
+	src := []byte(`
+variable "password" {
+  sensitive = true
+  default   = env("PASSWORD")
+}
+
+build {
+  post-processors {
+    post-processor "manifest" {}
+  }
+}
+`)
+	features := usedHCL2Features(src)
+	expected := map[string]bool{"sensitive variables": true, "the env function": true, "post-processors blocks": true}
+	if diff := cmp.Diff(expected, features); diff != "" {
+		t.Fatalf("unexpected features: %s", diff)
+	}
+
+	tests := []struct {
+		minVersion  string
+		want        string
+		wantReasons []string
+	}{
+		{"1.5.0", "1.6.6", []string{"the env function"}},
+		{"1.6.6", "1.6.6", nil},
+		{"1.8.0", "1.8.0", nil},
+		{"not a version", "not a version", nil},
+	}
+	for _, tt := range tests {
+		got, reasons := requiredPackerVersion(tt.minVersion, features)
+		if got != tt.want {
+			t.Errorf("requiredPackerVersion(%q) = %q, want %q", tt.minVersion, got, tt.want)
+		}
+		if diff := cmp.Diff(tt.wantReasons, reasons); diff != "" {
+			t.Errorf("unexpected reasons for %q: %s", tt.minVersion, diff)
+		}
+	}
+}
+
+func Test_hcl2_upgrade_folder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.json":         `{"builders": [{"type": "null", "communicator": "none"}]}`,
+		"b.json":         `{"builders": [{"type": "file", "target": "b.txt", "content": "b"}]}`,
+		"broken.json":    `{"builders": "not a list"}`,
+		"variables.json": `{"region": "us-east-1"}`,
+		"notes.txt":      `not a template`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	if code := c.Run([]string{dir}); code != 1 {
+		t.Fatalf("expected exit code 1 because of broken.json, got %d", code)
+	}
+
+	for name, expected := range map[string]bool{
+		"a.json.pkr.hcl":         true,
+		"b.json.pkr.hcl":         true,
+		"broken.json.pkr.hcl":    false,
+		"variables.json.pkr.hcl": false,
+		"notes.txt.pkr.hcl":      false,
+	} {
+		if got := fileExists(filepath.Join(dir, name)); got != expected {
+			t.Errorf("%s exists: %t, expected %t", name, got, expected)
+		}
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	statuses := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.SplitN(strings.TrimSpace(line), "  ", 2); len(fields) == 2 {
+			statuses[fields[0]] = strings.Fields(fields[1])[0]
+		}
+	}
+	for name, expected := range map[string]string{
+		"a.json":         "upgraded",
+		"b.json":         "upgraded",
+		"broken.json":    "failed",
+		"variables.json": "skipped,",
+	} {
+		if got := statuses[filepath.Join(dir, name)]; got != expected {
+			t.Errorf("%s: unexpected status %q in summary:\n%s", name, got, out)
+		}
+	}
+}
+
+func Test_hcl2_upgrade_outputFileWithFolder(t *testing.T) {
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	if code := c.Run([]string{"-output-file=out.pkr.hcl", testFixture("hcl2_upgrade_basic")}); code != ExitUsage {
+		t.Fatalf("expected usage exit code, got %d", code)
+	}
+}
+
+func Test_hcl2_upgrade_splitFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	args := []string{"-split-files", "-output-dir", dir, testFixture("hcl2_upgrade_local_paths", "input.json")}
+	if code := c.Run(args); code != 0 {
+		out, stderr := outputCommand(t, c.Meta)
+		t.Fatalf("unexpected exit code %d: %s %s", code, out, stderr)
+	}
+
+	for name, expected := range map[string][]string{
+		upgradeVariablesFile: {`variable "scripts_dir"`, "locals {"},
+		upgradeSourcesFile:   {`source "null" "autogenerated_1"`},
+		upgradeBuildFile:     {"build {", `sources = ["source.null.autogenerated_1"]`},
+	} {
+		content := string(mustBytes(ioutil.ReadFile(filepath.Join(dir, name))))
+		if !strings.HasPrefix(content, "# This file was autogenerated by the 'packer hcl2_upgrade -split-files'") {
+			t.Errorf("%s does not start with the header:\n%s", name, content)
+		}
+		for _, s := range expected {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s does not contain %q:\n%s", name, s, content)
+			}
+		}
+	}
+	if fileExists(testFixture("hcl2_upgrade_local_paths", "input.json.pkr.hcl")) {
+		t.Error("the single output file should not be written")
+	}
+}
+
+func Test_hcl2_upgrade_splitFilesWithOutputFile(t *testing.T) {
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	args := []string{"-split-files", "-output-file=out.pkr.hcl", testFixture("hcl2_upgrade_local_paths", "input.json")}
+	if code := c.Run(args); code != ExitUsage {
+		t.Fatalf("expected usage exit code, got %d", code)
+	}
+}
+
+func Test_hcl2_upgrade_varFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	varFile := filepath.Join(dir, "prod.json")
+	content := `{"scripts_dir": "prod/scripts", "unknown": "x"}`
+	if err := ioutil.WriteFile(varFile, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	args := []string{
+		"-var-file", varFile,
+		"-output-file", filepath.Join(dir, "hcl", "template.pkr.hcl"),
+		testFixture("hcl2_upgrade_local_paths", "input.json"),
+	}
+	if code := c.Run(args); code != 0 {
+		out, stderr := outputCommand(t, c.Meta)
+		t.Fatalf("unexpected exit code %d: %s %s", code, out, stderr)
+	}
+
+	expected := `# This file was autogenerated by the 'packer hcl2_upgrade' command from the
+# prod.json var file. Packer loads it automatically when building the folder it is in.
+scripts_dir = "prod/scripts"
+`
+	actual := string(mustBytes(ioutil.ReadFile(filepath.Join(dir, "hcl", "prod.auto.pkrvars.hcl"))))
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("unexpected var file: %s", diff)
+	}
+	if _, stderr := outputCommand(t, c.Meta); !strings.Contains(stderr, "are not declared by") || !strings.Contains(stderr, "unknown") {
+		t.Fatalf("expected a warning about the undeclared variable, got %q", stderr)
+	}
+}
+
+func Test_hcl2_upgrade_stdout(t *testing.T) {
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	args := []string{"-output-file=-", testFixture("hcl2_upgrade_local_paths", "input.json")}
+	if code := c.Run(args); code != 0 {
+		out, stderr := outputCommand(t, c.Meta)
+		t.Fatalf("unexpected exit code %d: %s %s", code, out, stderr)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	expected := string(mustBytes(ioutil.ReadFile(testFixture("hcl2_upgrade_local_paths", "expected.pkr.hcl"))))
+	if diff := cmp.Diff(expected, out); diff != "" {
+		t.Fatalf("unexpected output: %s", diff)
+	}
+	if fileExists(testFixture("hcl2_upgrade_local_paths", "input.json.pkr.hcl")) {
+		t.Error("no file should be written")
+	}
+}
+
+func Test_hcl2_upgrade_check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "template.pkr.hcl")
+	expected := mustBytes(ioutil.ReadFile(testFixture("hcl2_upgrade_local_paths", "expected.pkr.hcl")))
+	if err := ioutil.WriteFile(output, expected, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	args := []string{"-check", "-output-file", output, testFixture("hcl2_upgrade_local_paths", "input.json")}
+
+	c := &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	if code := c.Run(args); code != 0 {
+		out, stderr := outputCommand(t, c.Meta)
+		t.Fatalf("expected the file to be up to date, got exit code %d: %s %s", code, out, stderr)
+	}
+
+	drifted := bytes.Replace(expected, []byte(`default = "scripts"`), []byte(`default = "drifted"`), 1)
+	if err := ioutil.WriteFile(output, drifted, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	c = &HCL2UpgradeCommand{Meta: testMetaFile(t)}
+	if code := c.Run(args); code != ExitChanges {
+		t.Fatalf("expected exit code %d for an out of date file, got %d", ExitChanges, code)
+	}
+	out, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(out, `-  default = "drifted"`) || !strings.Contains(out, `+  default = "scripts"`) {
+		t.Errorf("expected the diff in the output, got:\n%s", out)
+	}
+	if !strings.Contains(stderr, "is out of date") {
+		t.Errorf("expected an out of date error, got %q", stderr)
+	}
+	if !bytes.Equal(drifted, mustBytes(ioutil.ReadFile(output))) {
+		t.Error("-check should not write the file")
+	}
+}
+
+func Test_hcl2_upgrade_withValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "template.pkr.hcl")
+	p := helperCommand(t, "hcl2_upgrade", "-with-validation", "-output-file", output,
+		testFixture("hcl2_upgrade_basic", "input.json"))
+	if bs, err := p.CombinedOutput(); err != nil {
+		t.Fatalf("%v %s", err, bs)
+	}
+
+	content := string(mustBytes(ioutil.ReadFile(output)))
+	for _, v := range []string{"aws_region", "aws_secondary_region"} {
+		expected := fmt.Sprintf("condition     = length(var.%s) > 0", v)
+		if !strings.Contains(content, expected) {
+			t.Errorf("expected %q in the config:\n%s", expected, content)
+		}
+	}
+	if n := strings.Count(content, "validation {"); n != 2 {
+		t.Errorf("expected 2 validation blocks, got %d:\n%s", n, content)
+	}
+}
+
+func Test_hcl2_upgrade_keepBuilderNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-hcl2-upgrade")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "template.pkr.hcl")
+	namesFile := filepath.Join(dir, "names.json")
+	p := helperCommand(t, "hcl2_upgrade", "-keep-builder-names", "-names-file", namesFile,
+		"-output-file", output, testFixture("hcl2_upgrade_basic", "input.json"))
+	if bs, err := p.CombinedOutput(); err != nil {
+		t.Fatalf("%v %s", err, bs)
+	}
+
+	content := string(mustBytes(ioutil.ReadFile(output)))
+	for _, expected := range []string{
+		`source "amazon-ebs" "amazon-ebs" {`,
+		`source "amazon-ebs" "named_builder" {`,
+		`only    = ["amazon-ebs.amazon-ebs"]`,
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("expected %q in the config:\n%s", expected, content)
+		}
+	}
+
+	expected := `{
+  "amazon-ebs": "source.amazon-ebs.amazon-ebs",
+  "named_builder": "source.amazon-ebs.named_builder"
+}
+`
+	if diff := cmp.Diff(expected, string(mustBytes(ioutil.ReadFile(namesFile)))); diff != "" {
+		t.Errorf("unexpected names file: %s", diff)
+	}
+}
+
+func Test_upgradeRequiredPlugins_installedVersion(t *testing.T) {
+	pluginDir, err := ioutil.TempDir("", "packer-upgrade-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pluginDir)
+
+	opts := pluginInstallationsOptions([]string{pluginDir})
+	binary := filepath.Join(pluginDir, "github.com", "hashicorp", "amazon",
+		"packer-plugin-amazon_v1.2.3_x"+opts.APIVersionMajor+"."+opts.APIVersionMinor+
+			"_"+opts.OS+"_"+opts.ARCH+opts.Ext)
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("amazon")
+	sum := sha256.Sum256(content)
+	if err := ioutil.WriteFile(binary, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(binary+"_SHA256SUM", []byte(hex.EncodeToString(sum[:])), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &template.Template{
+		Builders: map[string]*template.Builder{
+			"amazon-ebs":    {Type: "amazon-ebs"},
+			"googlecompute": {Type: "googlecompute"},
+		},
+	}
+	var got []string
+	for _, p := range upgradeRequiredPlugins(tpl, opts) {
+		got = append(got, p.Name+" "+p.Version)
+	}
+	want := []string{"amazon >= 1.2.3", "googlecompute >= 0.0.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected required plugins: %s", diff)
+	}
+}
@@ -0,0 +1,466 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/go-homedir"
+)
+
+// buildFingerprint records what a build depended on. Values are sha256
+// hashes so that no secret ends up in the history file.
+type buildFingerprint struct {
+	// Time of the build
+	Time time.Time `json:"time"`
+	// Components is the builder, provisioners and post-processors of the
+	// build.
+	Components string `json:"components"`
+	// Template holds the hash of every template and var file.
+	Template map[string]string `json:"template"`
+	// Variables holds the hash of the resolved value of every variable.
+	Variables map[string]string `json:"variables"`
+	// Files holds the hash of the local files the template references, like
+	// provisioning scripts, the files read with file() or the content of an
+	// http_directory.
+	Files map[string]string `json:"files"`
+	// Plugins holds the version of the plugins selected by the
+	// required_plugins block, by source address.
+	Plugins map[string]string `json:"plugins,omitempty"`
+}
+
+// buildHistoryVersion is the version of the format of history files. It is
+// bumped when a change of the format cannot be read by older Packers.
+const buildHistoryVersion = 1
+
+// buildHistory is the content of a history file, it is keyed by build name.
+type buildHistory struct {
+	// Version is the version of the format of the file; files written
+	// before it was set have none.
+	Version int                         `json:"version,omitempty"`
+	Builds  map[string]buildFingerprint `json:"builds"`
+}
+
+// loadBuildHistory reads the history file at path. A missing file is an empty
+// history.
+func loadBuildHistory(path string) (*buildHistory, error) {
+	h := &buildHistory{Builds: map[string]buildFingerprint{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The version is read on its own first, so that a file written by a
+	// newer Packer is reported as such rather than failing to decode.
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &header); err == nil && header.Version > buildHistoryVersion {
+		return nil, fmt.Errorf("%s was written by a newer version of Packer, in format version %d, "+
+			"this version only reads up to %d. Upgrade Packer or remove the file to start a new history",
+			path, header.Version, buildHistoryVersion)
+	}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("%s is not a valid build history, remove it to start a new one: %s", path, err)
+	}
+	if h.Builds == nil {
+		h.Builds = map[string]buildFingerprint{}
+	}
+	return h, nil
+}
+
+func (h *buildHistory) save(path string) error {
+	h.Version = buildHistoryVersion
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// forgetDowngradedBuilds removes from the history file the builds that are
+// about to run with an older version of a plugin than the one they were
+// recorded with, so that their record does not outlive a failed build. It
+// tells why, since a downgrade is usually unintended.
+func (c *BuildCommand) forgetDowngradedBuilds(path string, fingerprints map[string]buildFingerprint) {
+	history, err := loadBuildHistory(path)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read build history: %s", err))
+		return
+	}
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	forgotten := false
+	for _, name := range names {
+		previous, found := history.Builds[name]
+		if !found {
+			continue
+		}
+		downgrades := fingerprints[name].pluginDowngrades(previous)
+		if len(downgrades) == 0 {
+			continue
+		}
+		c.Ui.Error(fmt.Sprintf("%s: plugins are older than when the build was recorded, "+
+			"check the version constraints of required_plugins if this is not intended:\n  %s\n"+
+			"The record of the build is removed from %s until it succeeds again.",
+			name, strings.Join(downgrades, "\n  "), path))
+		delete(history.Builds, name)
+		forgotten = true
+	}
+	if !forgotten {
+		return
+	}
+	if err := history.save(path); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to update build history: %s", err))
+	}
+}
+
+// recordBuilds saves the fingerprints of the builds that succeeded in the
+// history file.
+func (c *BuildCommand) recordBuilds(path string, fingerprints map[string]buildFingerprint, results map[string]buildResult) {
+	history, err := loadBuildHistory(path)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read build history, builds won't be recorded: %s", err))
+		return
+	}
+	for name, fp := range fingerprints {
+		if results[name].Status != buildSucceeded {
+			continue
+		}
+		history.Builds[name] = fp
+	}
+	if err := history.save(path); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to record builds: %s", err))
+	}
+}
+
+// fingerprintBuilds computes the current fingerprint of each build.
+func fingerprintBuilds(cla *MetaArgs, handler packer.Handler, builds []packersdk.Build, historyFile string) (map[string]buildFingerprint, error) {
+	if cla.Path == "-" {
+		return nil, fmt.Errorf("builds of a template read from stdin cannot be fingerprinted")
+	}
+	templateFiles := append(templatePaths(cla.Path), cla.VarFiles...)
+	template, err := hashFiles("", templateFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := cla.Path
+	if isDir, _ := isDir(dir); !isDir {
+		dir = filepath.Dir(dir)
+	}
+	// Only the files the template references are hashed: the other files
+	// next to it, like the outputs of builds, are not inputs of the builds.
+	var referenced []string
+	if referencer, ok := handler.(packer.LocalFilesReferencer); ok {
+		referenced = referencer.LocalFiles()
+	}
+	files, err := expandLocalFiles(referenced, historyFile)
+	if err != nil {
+		return nil, err
+	}
+	local, err := hashFiles(dir, files)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedVars := fingerprintVariables(handler, builds)
+
+	var plugins map[string]string
+	if cfg, ok := handler.(*hcl2template.PackerConfig); ok {
+		plugins = cfg.PluginVersions
+	}
+
+	now := time.Now().UTC()
+	res := map[string]buildFingerprint{}
+	for _, b := range builds {
+		res[b.Name()] = buildFingerprint{
+			Time:       now,
+			Components: buildComponents(b),
+			Template:   template,
+			Variables:  hashedVars,
+			Files:      local,
+			Plugins:    plugins,
+		}
+	}
+	return res, nil
+}
+
+// fingerprintVariables returns the hashes of the values of the variables of
+// the builds. Sensitive values are left out: a short secret could be found
+// back from its hash stored in the history file, and a changed credential
+// does not change what is built.
+func fingerprintVariables(handler packer.Handler, builds []packersdk.Build) map[string]string {
+	sensitive := sensitiveVariables(handler)
+	vars := buildVariables(handler, builds)
+	hashed := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if !sensitive[k] {
+			hashed[k] = hashString(v)
+		}
+	}
+	return hashed
+}
+
+// sensitiveVariables returns the variables of handler that are sensitive,
+// named like by buildVariables.
+func sensitiveVariables(handler packer.Handler) map[string]bool {
+	sensitive := map[string]bool{}
+	switch h := handler.(type) {
+	case *hcl2template.PackerConfig:
+		for prefix, vars := range map[string]hcl2template.Variables{
+			"var.":   h.InputVariables,
+			"local.": h.LocalVariables,
+		} {
+			for name, v := range vars {
+				// values computed from sensitive values are marked
+				val, _ := v.Value()
+				if v.Sensitive || val.ContainsMarked() {
+					sensitive[prefix+name] = true
+				}
+			}
+		}
+	case *CoreWrapper:
+		for _, v := range h.Template.SensitiveVariables {
+			sensitive[v.Key] = true
+		}
+	}
+	return sensitive
+}
+
+// diff returns a description of what changed from previous to fp.
+func (fp buildFingerprint) diff(previous buildFingerprint) []string {
+	var changes []string
+	if fp.Components != previous.Components {
+		changes = append(changes, fmt.Sprintf("components: %s -> %s", previous.Components, fp.Components))
+	}
+	changes = append(changes, diffHashes("template", previous.Template, fp.Template)...)
+	changes = append(changes, diffHashes("variable", previous.Variables, fp.Variables)...)
+	changes = append(changes, diffHashes("file", previous.Files, fp.Files)...)
+	// Builds recorded before plugin versions were have none, they are not
+	// reported as changed for it.
+	if previous.Plugins != nil {
+		changes = append(changes, diffPluginVersions(previous.Plugins, fp.Plugins)...)
+	}
+	return changes
+}
+
+// pluginDowngrades returns the plugins of fp that are older than in previous.
+func (fp buildFingerprint) pluginDowngrades(previous buildFingerprint) []string {
+	var downgrades []string
+	for name, v := range fp.Plugins {
+		if isPluginDowngrade(previous.Plugins[name], v) {
+			downgrades = append(downgrades, fmt.Sprintf("%s %s -> %s", name, previous.Plugins[name], v))
+		}
+	}
+	sort.Strings(downgrades)
+	return downgrades
+}
+
+func diffPluginVersions(previous, current map[string]string) []string {
+	// the changes are sorted by plugin name, for a stable output
+	names := make([]string, 0, len(previous)+len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range previous {
+		if _, found := current[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		old, wasUsed := previous[name]
+		v, used := current[name]
+		switch {
+		case !used:
+			changes = append(changes, fmt.Sprintf("- plugin %s", name))
+		case !wasUsed:
+			changes = append(changes, fmt.Sprintf("+ plugin %s %s", name, v))
+		case isPluginDowngrade(old, v):
+			changes = append(changes, fmt.Sprintf("~ plugin %s %s -> %s (downgrade)", name, old, v))
+		case old != v:
+			changes = append(changes, fmt.Sprintf("~ plugin %s %s -> %s", name, old, v))
+		}
+	}
+	return changes
+}
+
+// isPluginDowngrade tells whether current is an older version than previous.
+// Versions that cannot be parsed are never a downgrade.
+func isPluginDowngrade(previous, current string) bool {
+	pv, err := version.NewVersion(previous)
+	if err != nil {
+		return false
+	}
+	cv, err := version.NewVersion(current)
+	if err != nil {
+		return false
+	}
+	return cv.LessThan(pv)
+}
+
+func diffHashes(kind string, previous, current map[string]string) []string {
+	var changes []string
+	for k, v := range current {
+		old, found := previous[k]
+		switch {
+		case !found:
+			changes = append(changes, fmt.Sprintf("+ %s %s", kind, k))
+		case old != v:
+			changes = append(changes, fmt.Sprintf("~ %s %s", kind, k))
+		}
+	}
+	for k := range previous {
+		if _, found := current[k]; !found {
+			changes = append(changes, fmt.Sprintf("- %s %s", kind, k))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// buildComponents describes the plugins used by a build.
+func buildComponents(b packersdk.Build) string {
+	cb, ok := b.(*packer.CoreBuild)
+	if !ok {
+		return ""
+	}
+	parts := []string{cb.BuilderType}
+	for _, p := range cb.Provisioners {
+		parts = append(parts, componentName(p.PType, p.PName))
+	}
+	for _, ppList := range cb.PostProcessors {
+		for _, pp := range ppList {
+			parts = append(parts, componentName(pp.PType, pp.PName))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// templatePaths returns the template files found at path.
+func templatePaths(path string) []string {
+	if isDir, _ := isDir(path); !isDir {
+		return []string{path}
+	}
+	var res []string
+	for _, pattern := range []string{"*.pkr.hcl", "*.pkr.json", "*.pkr.yaml", "*.auto.pkrvars.hcl", "*.auto.pkrvars.json"} {
+		matches, _ := filepath.Glob(filepath.Join(path, pattern))
+		res = append(res, matches...)
+	}
+	return res
+}
+
+// expandLocalFiles returns the regular files of paths: the files matching
+// the glob patterns, and the files found in the folders. Missing paths are
+// left out, and reported as removed by a plan.
+func expandLocalFiles(paths []string, historyFile string) ([]string, error) {
+	seen := map[string]bool{}
+	var res []string
+	add := func(path string) {
+		if !seen[path] && !sameFile(path, historyFile) {
+			seen[path] = true
+			res = append(res, path)
+		}
+	}
+	for _, pattern := range paths {
+		if expanded, err := homedir.Expand(pattern); err == nil {
+			pattern = expanded
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			// not a valid pattern, use it as a path
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// hashFiles returns the sha256 of files, keyed by their path relative to dir.
+func hashFiles(dir string, files []string) (map[string]string, error) {
+	res := make(map[string]string, len(files))
+	for _, file := range files {
+		sum, err := hashFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key := file
+		if dir != "" {
+			if rel, err := filepath.Rel(dir, file); err == nil {
+				key = filepath.ToSlash(rel)
+			}
+		}
+		res[key] = sum
+	}
+	return res, nil
+}
+
+// hashFile returns the sha256 of the content of file, read as a stream as it
+// can be large, like an ISO.
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
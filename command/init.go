@@ -9,18 +9,23 @@ import (
 	"strings"
 
 	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
+	"github.com/hashicorp/packer/hcl2template/addrs"
 	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
 	"github.com/hashicorp/packer/packer/plugin-getter/github"
 	"github.com/hashicorp/packer/version"
 	"github.com/posener/complete"
 )
 
+// registryURLEnvVar is the environment variable setting the default of the
+// -registry-url flag.
+const registryURLEnvVar = "PACKER_PLUGIN_REGISTRY_URL"
+
 type InitCommand struct {
 	Meta
 }
 
 func (c *InitCommand) Run(args []string) int {
-	ctx, cleanup := handleTermInterrupt(c.Ui)
+	ctx, forced, cleanup := handleTermInterrupt(c.Ui)
 	defer cleanup()
 
 	cfg, ret := c.ParseArgs(args)
@@ -28,7 +33,9 @@ func (c *InitCommand) Run(args []string) int {
 		return ret
 	}
 
-	return c.RunContext(ctx, cfg)
+	return runUntilForced(forced, func() int {
+		return c.RunContext(ctx, cfg)
+	})
 }
 
 func (c *InitCommand) ParseArgs(args []string) (*InitArgs, int) {
@@ -37,47 +44,20 @@ func (c *InitCommand) ParseArgs(args []string) (*InitArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 	cfg.Path = args[0]
 	return &cfg, 0
 }
 
 func (c *InitCommand) RunContext(buildCtx context.Context, cla *InitArgs) int {
-	packerStarter, ret := c.GetConfig(&cla.MetaArgs)
-	if ret != 0 {
-		return ret
-	}
-
-	// Get plugins requirements
-	reqs, diags := packerStarter.PluginRequirements()
-	ret = writeDiags(c.Ui, nil, diags)
-	if ret != 0 {
-		return ret
-	}
-
-	opts := plugingetter.ListInstallationsOptions{
-		FromFolders: c.Meta.CoreConfig.Components.PluginConfig.KnownPluginFolders,
-		BinaryInstallationOptions: plugingetter.BinaryInstallationOptions{
-			OS:              runtime.GOOS,
-			ARCH:            runtime.GOARCH,
-			APIVersionMajor: pluginsdk.APIVersionMajor,
-			APIVersionMinor: pluginsdk.APIVersionMinor,
-			Checksummers: []plugingetter.Checksummer{
-				{Type: "sha256", Hash: sha256.New()},
-			},
-		},
-	}
-
-	if runtime.GOOS == "windows" && opts.Ext == "" {
-		opts.BinaryInstallationOptions.Ext = ".exe"
-	}
+	opts := pluginInstallationsOptions(c.Meta.CoreConfig.Components.PluginConfig.KnownPluginFolders)
 
 	log.Printf("[TRACE] init: %#v", opts)
 
@@ -91,10 +71,34 @@ func (c *InitCommand) RunContext(buildCtx context.Context, cla *InitArgs) int {
 			// TODO: allow to set this from the config file or an environment
 			// variable.
 			UserAgent: "packer-getter-github-" + version.String(),
+			BaseURL:   cla.RegistryURL,
 		},
 	}
 
+	if cla.Infer {
+		if ret := c.inferRequiredPlugins(buildCtx, cla, opts, getters); ret != 0 {
+			return ret
+		}
+	}
+
+	packerStarter, ret := c.GetConfig(&cla.MetaArgs)
+	if ret != 0 {
+		return ret
+	}
+
+	// Get plugins requirements
+	reqs, diags := packerStarter.PluginRequirements()
+	ret = writeDiags(c.Ui, nil, diags)
+	if ret != 0 {
+		return ret
+	}
+
 	for _, pluginRequirement := range reqs {
+		if buildCtx.Err() != nil {
+			c.Ui.Error("Cancelled plugin installation after being interrupted.")
+			return ExitCancelled
+		}
+
 		// Get installed plugins that match requirement
 
 		installs, err := pluginRequirement.ListInstallations(opts)
@@ -109,11 +113,15 @@ func (c *InitCommand) RunContext(buildCtx context.Context, cla *InitArgs) int {
 			continue
 		}
 
-		newInstall, err := pluginRequirement.InstallLatest(plugingetter.InstallOptions{
+		newInstall, err := pluginRequirement.InstallLatest(buildCtx, plugingetter.InstallOptions{
 			InFolders:                 opts.FromFolders,
 			BinaryInstallationOptions: opts.BinaryInstallationOptions,
 			Getters:                   getters,
 		})
+		if buildCtx.Err() != nil {
+			c.Ui.Error(fmt.Sprintf("Cancelled installation of plugin %s after being interrupted.", pluginRequirement.Identifier.ForDisplay()))
+			return ExitCancelled
+		}
 		if err != nil {
 			c.Ui.Error(err.Error())
 		}
@@ -125,6 +133,101 @@ func (c *InitCommand) RunContext(buildCtx context.Context, cla *InitArgs) int {
 	return ret
 }
 
+// pluginInstallationsOptions returns the options to list the plugins
+// installed in folders that this version of Packer can run.
+func pluginInstallationsOptions(folders []string) plugingetter.ListInstallationsOptions {
+	opts := plugingetter.ListInstallationsOptions{
+		FromFolders: folders,
+		BinaryInstallationOptions: plugingetter.BinaryInstallationOptions{
+			OS:              runtime.GOOS,
+			ARCH:            runtime.GOARCH,
+			APIVersionMajor: pluginsdk.APIVersionMajor,
+			APIVersionMinor: pluginsdk.APIVersionMinor,
+			Checksummers: []plugingetter.Checksummer{
+				{Type: "sha256", Hash: sha256.New()},
+			},
+		},
+	}
+	if runtime.GOOS == "windows" {
+		opts.BinaryInstallationOptions.Ext = ".exe"
+	}
+	return opts
+}
+
+// inferRequiredPlugins adds the plugins providing the components used in the
+// config but unknown to packer to its required_plugins block. Each plugin is
+// required from the version already installed or, if there is none, from the
+// latest version, which is then installed.
+func (c *InitCommand) inferRequiredPlugins(ctx context.Context, cla *InitArgs, opts plugingetter.ListInstallationsOptions, getters []plugingetter.Getter) int {
+	cfgType, err := cla.GetConfigType()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("%q: %s", cla.Path, err))
+		return 1
+	}
+	if cfgType != ConfigTypeHCL2 {
+		c.Ui.Error("-infer only works with HCL2 configs. Use 'packer hcl2_upgrade' to convert a JSON template first.")
+		return 1
+	}
+	cfg, ret := c.GetConfigFromHCL(&cla.MetaArgs)
+	if ret != 0 {
+		return ret
+	}
+
+	plugins, unknown := inferRequiredPlugins(cfg, c.Meta.CoreConfig.Components.PluginConfig)
+	if len(unknown) > 0 {
+		c.Ui.Error(fmt.Sprintf("Warning: no known plugin provides the following components, "+
+			"add them to required_plugins yourself:\n  %s", strings.Join(unknown, "\n  ")))
+	}
+	if len(plugins) == 0 {
+		c.Ui.Say("No plugin to add to required_plugins.")
+		return 0
+	}
+
+	for _, p := range plugins {
+		identifier, diags := addrs.ParsePluginSourceString(p.Source)
+		if diags.HasErrors() {
+			return writeDiags(c.Ui, nil, diags)
+		}
+		req := &plugingetter.Requirement{Accessor: p.Name, Identifier: identifier}
+		installs, err := req.ListInstallations(opts)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		var install *plugingetter.Installation
+		if len(installs) > 0 {
+			install = installs[len(installs)-1]
+		} else {
+			install, err = req.InstallLatest(ctx, plugingetter.InstallOptions{
+				InFolders:                 opts.FromFolders,
+				BinaryInstallationOptions: opts.BinaryInstallationOptions,
+				Getters:                   getters,
+			})
+			if ctx.Err() != nil {
+				c.Ui.Error(fmt.Sprintf("Cancelled installation of plugin %s after being interrupted.", p))
+				return ExitCancelled
+			}
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Failed to install plugin %s: %s", p, err))
+				return 1
+			}
+			c.Ui.Say(fmt.Sprintf("Installed plugin %s %s in %q", identifier.ForDisplay(), install.Version, install.BinaryPath))
+		}
+		p.Version = ">= " + strings.TrimPrefix(install.Version, "v")
+	}
+
+	file, err := writeRequiredPlugins(cla.Path, plugins)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write required_plugins: %s", err))
+		return 1
+	}
+	for _, p := range plugins {
+		c.Ui.Say(fmt.Sprintf("Added %s %q to the required_plugins of %s, used by %s",
+			p, p.Version, file, strings.Join(p.UsedBy, ", ")))
+	}
+	return 0
+}
+
 func (*InitCommand) Help() string {
 	helpText := `
 Usage: packer init [options] [config.pkr.hcl|folder/]
@@ -139,11 +242,21 @@ Usage: packer init [options] [config.pkr.hcl|folder/]
   give errors, this command will never delete anything.
 
 Options:
+  -infer                       Before installing plugins, add the plugins
+                               providing the sources, data sources,
+                               provisioners and post-processors used in the
+                               config, but unknown to Packer, to the
+                               required_plugins block of the config.
   -upgrade                     On top of installing missing plugins, update
                                installed plugins to the latest available
                                version, if there is a new higher one. Note that
                                this still takes into consideration the version
                                constraint of the config.
+  -registry-url=URL            Get plugins from the registry at URL, serving
+                               the GitHub API and release downloads, instead
+                               of GitHub. Defaults to the value of the
+                               PACKER_PLUGIN_REGISTRY_URL environment
+                               variable.
 `
 
 	return strings.TrimSpace(helpText)
@@ -159,6 +272,8 @@ func (*InitCommand) AutocompleteArgs() complete.Predictor {
 
 func (*InitCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-upgrade": complete.PredictNothing,
+		"-infer":        complete.PredictNothing,
+		"-registry-url": complete.PredictNothing,
+		"-upgrade":      complete.PredictNothing,
 	}
 }
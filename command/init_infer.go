@@ -0,0 +1,235 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// knownPluginSources maps the name of plugins, which prefixes the components
+// they provide, to where `packer init` can install them from.
+var knownPluginSources = map[string]string{
+	"alicloud":      "github.com/hashicorp/alicloud",
+	"amazon":        "github.com/hashicorp/amazon",
+	"ansible":       "github.com/hashicorp/ansible",
+	"azure":         "github.com/hashicorp/azure",
+	"chef":          "github.com/hashicorp/chef",
+	"cloudstack":    "github.com/hashicorp/cloudstack",
+	"digitalocean":  "github.com/hashicorp/digitalocean",
+	"docker":        "github.com/hashicorp/docker",
+	"googlecompute": "github.com/hashicorp/googlecompute",
+	"hcloud":        "github.com/hashicorp/hcloud",
+	"hyperv":        "github.com/hashicorp/hyperv",
+	"linode":        "github.com/hashicorp/linode",
+	"lxc":           "github.com/hashicorp/lxc",
+	"lxd":           "github.com/hashicorp/lxd",
+	"openstack":     "github.com/hashicorp/openstack",
+	"oracle":        "github.com/hashicorp/oracle",
+	"parallels":     "github.com/hashicorp/parallels",
+	"proxmox":       "github.com/hashicorp/proxmox",
+	"puppet":        "github.com/hashicorp/puppet",
+	"qemu":          "github.com/hashicorp/qemu",
+	"vagrant":       "github.com/hashicorp/vagrant",
+	"virtualbox":    "github.com/hashicorp/virtualbox",
+	"vmware":        "github.com/hashicorp/vmware",
+	"vsphere":       "github.com/hashicorp/vsphere",
+}
+
+// knownPluginSource returns the name of the plugin providing the component of
+// type typ, and where to install it from if the plugin is known.
+func knownPluginSource(typ string) (name, source string, found bool) {
+	name = strings.SplitN(typ, "-", 2)[0]
+	source, found = knownPluginSources[name]
+	return name, source, found
+}
+
+// requiredPluginsFile is where the inferred required_plugins block is written
+// when the config of a folder has no packer block yet.
+const requiredPluginsFile = "plugins.pkr.hcl"
+
+// inferredPlugin is a plugin providing components used in a config.
+type inferredPlugin struct {
+	Name   string
+	Source string
+	// UsedBy are the components of the plugin used in the config, for
+	// example "source.amazon-ebs".
+	UsedBy []string
+	// Version is the version to require, set once the plugin is installed.
+	Version string
+}
+
+// inferRequiredPlugins returns, sorted by name, the plugins providing the
+// components used in cfg that packer cannot load and that are not already
+// required. It also returns the components for which no plugin is known.
+func inferRequiredPlugins(cfg *hcl2template.PackerConfig, plugins *packer.PluginConfig) ([]*inferredPlugin, []string) {
+	required := map[string]bool{}
+	for _, block := range cfg.Packer.RequiredPlugins {
+		for name := range block.RequiredPlugins {
+			required[name] = true
+		}
+	}
+
+	type component struct {
+		kind, typ string
+		known     bool
+	}
+	var used []component
+	for ref := range cfg.Sources {
+		used = append(used, component{"source", ref.Type, plugins.Builders.Has(ref.Type)})
+	}
+	for ref := range cfg.Datasources {
+		used = append(used, component{"data", ref.Type, plugins.DataSources.Has(ref.Type)})
+	}
+	for _, build := range cfg.Builds {
+		for _, pb := range build.ProvisionerBlocks {
+			used = append(used, component{"provisioner", pb.PType, plugins.Provisioners.Has(pb.PType)})
+		}
+		for _, pps := range build.PostProcessorsLists {
+			for _, pp := range pps {
+				used = append(used, component{"post-processor", pp.PType, plugins.PostProcessors.Has(pp.PType)})
+			}
+		}
+	}
+
+	inferred := map[string]*inferredPlugin{}
+	unknown := map[string]bool{}
+	for _, c := range used {
+		if c.known {
+			continue
+		}
+		name, source, found := knownPluginSource(c.typ)
+		if required[name] {
+			continue
+		}
+		usedBy := c.kind + "." + c.typ
+		if !found {
+			unknown[usedBy] = true
+			continue
+		}
+		p := inferred[name]
+		if p == nil {
+			p = &inferredPlugin{Name: name, Source: source}
+			inferred[name] = p
+		}
+		if !stringInSlice(p.UsedBy, usedBy) {
+			p.UsedBy = append(p.UsedBy, usedBy)
+		}
+	}
+
+	res := make([]*inferredPlugin, 0, len(inferred))
+	for _, p := range inferred {
+		sort.Strings(p.UsedBy)
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	unknownComponents := make([]string, 0, len(unknown))
+	for c := range unknown {
+		unknownComponents = append(unknownComponents, c)
+	}
+	sort.Strings(unknownComponents)
+	return res, unknownComponents
+}
+
+func stringInSlice(s []string, searchstr string) bool {
+	for _, v := range s {
+		if v == searchstr {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRequiredPlugins adds plugins to the required_plugins block of the
+// config at path and returns the file it wrote. When path is a folder, the
+// first file with a packer block is updated, or plugins.pkr.hcl is created.
+func writeRequiredPlugins(path string, plugins []*inferredPlugin) (string, error) {
+	filename, err := requiredPluginsFilename(path)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", diags
+	}
+
+	body := f.Body()
+	packerBlock := body.FirstMatchingBlock("packer", nil)
+	if packerBlock == nil {
+		if len(body.Blocks()) > 0 || len(body.Attributes()) > 0 {
+			// keep the packer block at the top of the file
+			packerFile := hclwrite.NewEmptyFile()
+			packerFile.Body().AppendNewBlock("packer", nil)
+			packerFile.Body().AppendNewline()
+			src = append(packerFile.Bytes(), src...)
+			f, diags = hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+			if diags.HasErrors() {
+				return "", diags
+			}
+			body = f.Body()
+			packerBlock = body.FirstMatchingBlock("packer", nil)
+		} else {
+			packerBlock = body.AppendNewBlock("packer", nil)
+		}
+	}
+	requiredPlugins := packerBlock.Body().FirstMatchingBlock("required_plugins", nil)
+	if requiredPlugins == nil {
+		requiredPlugins = packerBlock.Body().AppendNewBlock("required_plugins", nil)
+	}
+	for _, p := range plugins {
+		requiredPlugins.Body().SetAttributeValue(p.Name, cty.ObjectVal(map[string]cty.Value{
+			"version": cty.StringVal(p.Version),
+			"source":  cty.StringVal(p.Source),
+		}))
+	}
+
+	return filename, ioutil.WriteFile(filename, hclwrite.Format(f.Bytes()), 0644)
+}
+
+// requiredPluginsFilename returns the file of the config at path where the
+// required_plugins block should be written.
+func requiredPluginsFilename(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return path, nil
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.pkr.hcl"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		f, diags := hclwrite.ParseConfig(src, file, hcl.InitialPos)
+		if diags.HasErrors() {
+			continue
+		}
+		if f.Body().FirstMatchingBlock("packer", nil) != nil {
+			return file, nil
+		}
+	}
+	return filepath.Join(path, requiredPluginsFile), nil
+}
+
+func (p *inferredPlugin) String() string {
+	return fmt.Sprintf("%s (%s)", p.Name, p.Source)
+}
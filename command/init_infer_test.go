@@ -0,0 +1,116 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_inferRequiredPlugins(t *testing.T) {
+	c := &InitCommand{Meta: testMetaFile(t)}
+	cfg, ret := c.GetConfigFromHCL(&MetaArgs{Path: testFixture("init", "infer")})
+	if ret != 0 {
+		t.Fatalf("GetConfigFromHCL: %d", ret)
+	}
+
+	plugins, unknown := inferRequiredPlugins(cfg, c.Meta.CoreConfig.Components.PluginConfig)
+	expected := []*inferredPlugin{
+		{Name: "ansible", Source: "github.com/hashicorp/ansible", UsedBy: []string{"provisioner.ansible"}},
+		{Name: "docker", Source: "github.com/hashicorp/docker", UsedBy: []string{"post-processor.docker-tag", "source.docker"}},
+	}
+	if diff := cmp.Diff(expected, plugins); diff != "" {
+		t.Errorf("unexpected plugins: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"source.mycloud-vm"}, unknown); diff != "" {
+		t.Errorf("unexpected unknown components: %s", diff)
+	}
+}
+
+func Test_writeRequiredPlugins(t *testing.T) {
+	plugins := []*inferredPlugin{
+		{Name: "docker", Source: "github.com/hashicorp/docker", Version: ">= 0.0.7"},
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantFile string
+		want     string
+	}{
+		{
+			name: "folder without packer block",
+			files: map[string]string{
+				"build.pkr.hcl": "source \"docker\" \"ubuntu\" {\n}\n",
+			},
+			wantFile: "plugins.pkr.hcl",
+			want: `packer {
+  required_plugins {
+    docker = {
+      source  = "github.com/hashicorp/docker"
+      version = ">= 0.0.7"
+    }
+  }
+}
+`,
+		},
+		{
+			name: "existing required_plugins",
+			files: map[string]string{
+				"build.pkr.hcl": "source \"docker\" \"ubuntu\" {\n}\n",
+				"packer.pkr.hcl": `packer {
+  required_version = ">= 1.7.0"
+  required_plugins {
+    amazon = {
+      version = ">= 1.0.0"
+      source  = "github.com/hashicorp/amazon"
+    }
+  }
+}
+`,
+			},
+			wantFile: "packer.pkr.hcl",
+			want: `packer {
+  required_version = ">= 1.7.0"
+  required_plugins {
+    amazon = {
+      version = ">= 1.0.0"
+      source  = "github.com/hashicorp/amazon"
+    }
+    docker = {
+      source  = "github.com/hashicorp/docker"
+      version = ">= 0.0.7"
+    }
+  }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "packer-init-infer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file, err := writeRequiredPlugins(dir, plugins)
+			if err != nil {
+				t.Fatalf("writeRequiredPlugins: %s", err)
+			}
+			if file != filepath.Join(dir, tt.wantFile) {
+				t.Fatalf("wrote %s, expected %s", file, tt.wantFile)
+			}
+			if diff := cmp.Diff(tt.want, string(mustBytes(ioutil.ReadFile(file)))); diff != "" {
+				t.Fatalf("unexpected content: %s", diff)
+			}
+		})
+	}
+}
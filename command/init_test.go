@@ -0,0 +1,63 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
+	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
+	"github.com/hashicorp/packer/packer/plugin-getter/github"
+)
+
+func TestInitCommand_registryURL(t *testing.T) {
+	pluginDir, err := ioutil.TempDir("", "packer-init-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pluginDir)
+
+	binOpts := plugingetter.BinaryInstallationOptions{
+		OS:              runtime.GOOS,
+		ARCH:            runtime.GOARCH,
+		APIVersionMajor: pluginsdk.APIVersionMajor,
+		APIVersionMinor: pluginsdk.APIVersionMinor,
+	}
+	if runtime.GOOS == "windows" {
+		binOpts.Ext = ".exe"
+	}
+	installed := func(version string) string {
+		return filepath.Join(pluginDir, "github.com", "hashicorp", "comment",
+			"packer-plugin-comment_"+version+"_x"+binOpts.APIVersionMajor+"."+binOpts.APIVersionMinor+
+				"_"+binOpts.OS+"_"+binOpts.ARCH+binOpts.Ext)
+	}
+
+	registry := github.NewTestRegistry(t)
+	defer registry.Close()
+	registry.AddRelease(t, "hashicorp/packer-plugin-comment", "v0.2.23", binOpts, []byte("v0.2.23"))
+
+	run := func(args ...string) {
+		meta := testMetaFile(t)
+		meta.CoreConfig.Components.PluginConfig.KnownPluginFolders = []string{pluginDir}
+		c := &InitCommand{Meta: meta}
+		if code := c.Run(append(args, testFixture("init", "registry"))); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+	}
+
+	run("-registry-url", registry.URL)
+	if b, err := ioutil.ReadFile(installed("v0.2.23")); err != nil || string(b) != "v0.2.23" {
+		t.Fatalf("v0.2.23 was not installed from the registry: %q, %v", b, err)
+	}
+
+	// the environment variable is the default of the flag
+	registry.AddRelease(t, "hashicorp/packer-plugin-comment", "v0.2.24", binOpts, []byte("v0.2.24"))
+	os.Setenv(registryURLEnvVar, registry.URL)
+	defer os.Unsetenv(registryURLEnvVar)
+	run("-upgrade")
+	if b, err := ioutil.ReadFile(installed("v0.2.24")); err != nil || string(b) != "v0.2.24" {
+		t.Fatalf("v0.2.24 was not installed from the registry: %q, %v", b, err)
+	}
+}
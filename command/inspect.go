@@ -2,6 +2,8 @@ package command
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"strings"
 
 	"github.com/hashicorp/packer/packer"
@@ -29,7 +31,18 @@ func (c *InspectCommand) ParseArgs(args []string) (*InspectArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
+	}
+
+	switch cfg.Output {
+	case "text", "json":
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown output format %q, it must be text or json.", cfg.Output))
+		return &cfg, ExitUsage
+	}
+	if cfg.Resolve && cfg.Output == "json" {
+		c.Ui.Error("-resolve cannot be used with -output=json.")
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
@@ -45,12 +58,30 @@ func (c *InspectCommand) RunContext(ctx context.Context, cla *InspectArgs) int {
 		return ret
 	}
 
-	// here we ignore init diags to allow unknown variables to be used
-	_ = packerStarter.Initialize(packer.InitializeOptions{})
+	if cla.ShowSensitive {
+		log.Printf("[WARN] -show-sensitive is set, the values of the sensitive variables of %s are displayed", cla.Path)
+	}
+
+	// here we ignore init diags to allow unknown variables to be used, unless
+	// resolving them, where they tell what is wrong with their values
+	diags := packerStarter.Initialize(packer.InitializeOptions{
+		ShowSensitive: cla.ShowSensitive,
+		Context:       ctx,
+	})
+	if cla.Resolve {
+		writeDiags(c.Ui, nil, diags)
+	}
 
-	return packerStarter.InspectConfig(packer.InspectConfigOptions{
-		Ui: c.Ui,
+	ret = packerStarter.InspectConfig(packer.InspectConfigOptions{
+		Ui:            c.Ui,
+		ShowSensitive: cla.ShowSensitive,
+		JSON:          cla.Output == "json",
+		Resolve:       cla.Resolve,
 	})
+	if ret == 0 && cla.Resolve && diags.HasErrors() {
+		return 1
+	}
+	return ret
 }
 
 func (*InspectCommand) Help() string {
@@ -64,6 +95,15 @@ Usage: packer inspect TEMPLATE
 Options:
 
   -machine-readable  Machine-readable output
+  -output=text       Format of the output, text or json. The JSON output
+                     lists the variables, locals, sources and builds of the
+                     template, for tools to audit it
+  -resolve           Display the value of every variable and local once set
+                     from the defaults, PKR_VAR_ environment variables, var
+                     files and -var, with where each value was set from and
+                     the values it overrides. HCL2 templates only
+  -show-sensitive    Display the values of sensitive variables, which are
+                     masked by default
 `
 
 	return strings.TrimSpace(helpText)
@@ -80,5 +120,8 @@ func (c *InspectCommand) AutocompleteArgs() complete.Predictor {
 func (c *InspectCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
 		"-machine-readable": complete.PredictNothing,
+		"-output":           complete.PredictSet("text", "json"),
+		"-resolve":          complete.PredictNothing,
+		"-show-sensitive":   complete.PredictNothing,
 	}
 }
@@ -164,6 +164,36 @@ and therefore only show in their raw form here.
 			nil,
 			testFixtureContent("hcl-inspect-with-sensitive-vars", "expected-output.txt"),
 		},
+		{
+			[]string{
+				"inspect", "-show-sensitive", filepath.Join(testFixture("hcl-inspect-with-sensitive-vars")),
+			},
+			nil,
+			testFixtureContent("hcl-inspect-with-sensitive-vars", "expected-output-show-sensitive.txt"),
+		},
+		{
+			[]string{
+				"inspect", "-output=json", filepath.Join(testFixture("inspect-json"), "template.pkr.hcl"),
+			},
+			nil,
+			testFixtureContent("inspect-json", "expected-output.json"),
+		},
+		{
+			[]string{
+				"inspect", "-output=json", filepath.Join(testFixture("inspect-json"), "template.json"),
+			},
+			nil,
+			testFixtureContent("inspect-json", "expected-output-json-template.json"),
+		},
+		{
+			[]string{
+				"inspect", "-resolve", "-var=fruit=peach",
+				"-var-file=" + filepath.Join(testFixture("inspect-resolve"), "override.pkrvars.hcl"),
+				testFixture("inspect-resolve"),
+			},
+			[]string{"PKR_VAR_color=red"},
+			testFixtureContent("inspect-resolve", "expected-output.txt"),
+		},
 	}
 
 	for _, tc := range tc {
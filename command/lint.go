@@ -0,0 +1,180 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/lint"
+	"github.com/posener/complete"
+)
+
+type LintCommand struct {
+	Meta
+}
+
+func (c *LintCommand) Run(args []string) int {
+	ctx := context.Background()
+	cfg, ret := c.ParseArgs(args)
+	if ret != 0 {
+		return ret
+	}
+
+	return c.RunContext(ctx, cfg)
+}
+
+func (c *LintCommand) ParseArgs(args []string) (*LintArgs, int) {
+	var cfg LintArgs
+	flags := c.Meta.FlagSet("lint", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	cfg.AddFlagSets(flags)
+	if err := flags.Parse(args); err != nil {
+		return &cfg, ExitUsage
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return &cfg, ExitUsage
+	}
+	switch cfg.Format {
+	case "text", "json", "sarif":
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown output format %q, it must be text, json or sarif.", cfg.Format))
+		return &cfg, ExitUsage
+	}
+	cfg.Path = args[0]
+	return &cfg, 0
+}
+
+func (c *LintCommand) RunContext(ctx context.Context, cla *LintArgs) int {
+	settingsFile := cla.Config
+	if settingsFile == "" {
+		dir := cla.Path
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+		settingsFile = filepath.Join(dir, lint.SettingsFile)
+	} else if _, err := os.Stat(settingsFile); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read the rule settings: %s", err))
+		return ExitError
+	}
+	settings, diags := lint.LoadSettings(settingsFile)
+	if diags.HasErrors() {
+		return writeDiags(c.Ui, nil, diags)
+	}
+
+	cfg, diags := lint.LoadConfig(cla.Path)
+	if diags.HasErrors() {
+		var files map[string]*hcl.File
+		if cfg != nil {
+			files = cfg.Files
+		}
+		writeDiags(c.Ui, files, diags)
+		return ExitValidation
+	}
+	cfg.Builtin = builtinComponent
+
+	issues := lint.Lint(cfg, settings)
+	if cla.WarnAsError {
+		for i := range issues {
+			issues[i].Severity = lint.SeverityError
+		}
+	}
+
+	switch cla.Format {
+	case "json", "sarif":
+		write := lint.WriteJSON
+		if cla.Format == "sarif" {
+			write = lint.WriteSARIF
+		}
+		if err := write(os.Stdout, issues); err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to write the issues: %s", err))
+			return ExitError
+		}
+	default:
+		writeDiags(c.Ui, cfg.Files, lint.Diagnostics(issues))
+	}
+
+	if lint.HasErrors(issues) {
+		return ExitValidation
+	}
+	return ExitSuccess
+}
+
+// builtinComponent tells whether a component is built into Packer.
+func builtinComponent(kind, typ string) bool {
+	var found bool
+	switch kind {
+	case "source":
+		_, found = Builders[typ]
+	case "data":
+		_, found = Datasources[typ]
+	case "provisioner":
+		_, found = Provisioners[typ]
+	case "post-processor":
+		_, found = PostProcessors[typ]
+	}
+	return found
+}
+
+func (*LintCommand) Help() string {
+	helpText := `
+Usage: packer lint [options] TEMPLATE
+
+  Checks the HCL2 config TEMPLATE, a file or a folder, for problems that do
+  not make it invalid, like unused variables, deprecated settings or
+  hardcoded credentials, and reports them as warnings.
+
+  The rules are configured by the ` + lint.SettingsFile + ` file of the config
+  folder. It can disable a rule, or report its issues as errors:
+
+    rule "unused-source" {
+      enabled = false
+    }
+
+    rule "hardcoded-credential" {
+      severity = "error"
+    }
+
+  The command exits with a non-zero status when an issue is an error.
+
+Options:
+  -config=path                  The rule settings file, instead of the
+                                ` + lint.SettingsFile + ` file of the config folder.
+  -format=text                  Output format of the issues: text, json or
+                                sarif. Defaults to text.
+  -warn-as-error                Report all issues as errors.
+
+Rules:
+` + lintRules()
+
+	return strings.TrimSpace(helpText)
+}
+
+func lintRules() string {
+	var b strings.Builder
+	for _, name := range lint.RuleOrder {
+		fmt.Fprintf(&b, "  %-28s%s\n", name, lint.Rules[name].Synopsis())
+	}
+	return b.String()
+}
+
+func (*LintCommand) Synopsis() string {
+	return "Checks an HCL2 config for likely mistakes and bad practices"
+}
+
+func (*LintCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (*LintCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":        complete.PredictFiles("*.hcl"),
+		"-format":        complete.PredictSet("text", "json", "sarif"),
+		"-warn-as-error": complete.PredictNothing,
+	}
+}
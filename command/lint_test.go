@@ -0,0 +1,57 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tc := []struct {
+		name     string
+		args     []string
+		exitCode int
+		contains []string
+	}{
+		{
+			"settings of the config folder",
+			[]string{filepath.Join(testFixture("lint"), "main.pkr.hcl")},
+			ExitValidation,
+			[]string{"Error: hardcoded-credential", "Warning: unused-variable"},
+		},
+		{
+			"settings file",
+			[]string{"-config", filepath.Join(testFixture("lint-settings"), "warnings-only.hcl"), testFixture("lint")},
+			ExitSuccess,
+			[]string{"Warning: unused-variable"},
+		},
+		{
+			"warnings as errors",
+			[]string{"-warn-as-error", "-config", filepath.Join(testFixture("lint-settings"), "warnings-only.hcl"), testFixture("lint")},
+			ExitValidation,
+			[]string{"Error: unused-variable"},
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &LintCommand{Meta: testMeta(t)}
+			if code := c.Run(tt.args); code != tt.exitCode {
+				out, stderr := outputCommand(t, c.Meta)
+				t.Fatalf("exit code %d, expected %d\nstdout:\n%s\nstderr:\n%s", code, tt.exitCode, out, stderr)
+			}
+			out, stderr := outputCommand(t, c.Meta)
+			for _, s := range tt.contains {
+				if !strings.Contains(out+stderr, s) {
+					t.Errorf("expected the output to contain %q:\n%s%s", s, out, stderr)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_invalidFormat(t *testing.T) {
+	c := &LintCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"-format=xml", testFixture("lint")}); code != ExitUsage {
+		t.Fatalf("exit code %d, expected %d", code, ExitUsage)
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"flag"
 	"io"
 	"os"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template"
 	kvflag "github.com/hashicorp/packer/command/flag-kv"
 	"github.com/hashicorp/packer/helper/wrappedstreams"
 	"github.com/hashicorp/packer/packer"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // FlagSetFlags is an enum to define what flags are present in the
@@ -103,3 +105,33 @@ func (m *Meta) StdinPiped() bool {
 
 	return fi.Mode()&os.ModeNamedPipe != 0
 }
+
+// askSensitive asks query like Ui.Ask, without showing the answer as it is
+// typed when stdin is a terminal, for sensitive values.
+func (m *Meta) askSensitive(query string) (string, error) {
+	stdin := wrappedstreams.Stdin()
+	if !terminal.IsTerminal(int(stdin.Fd())) {
+		return m.Ui.Ask(query)
+	}
+	m.Ui.Say(query + " (the value typed is not shown)")
+	value, err := terminal.ReadPassword(int(stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// canAsk returns true if the Ui can prompt for an answer: it needs a terminal
+// and does not work in machine-readable mode.
+func (m *Meta) canAsk() bool {
+	if packer.IsStructuredUi(m.Ui) {
+		return false
+	}
+	switch ui := m.Ui.(type) {
+	case *packer.QuietUi:
+		return false
+	case *packersdk.BasicUi:
+		return ui.TTY != nil
+	}
+	return true
+}
@@ -0,0 +1,436 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// parallelBuildsAuto is the value of the -parallel-builds flag that makes
+// packer schedule builds from the resources of the host.
+const parallelBuildsAuto = "auto"
+
+const (
+	// machineBuildPending is the type of the machine-readable message
+	// telling that a build waits for others to finish before starting.
+	machineBuildPending = "build-pending"
+	// machineBuildStarted is the type of the machine-readable message
+	// telling that a pending build started, with how long it waited.
+	machineBuildStarted = "build-started"
+)
+
+// hypervisorBuilderPrefixes lists the builder types running their VM on the
+// packer host. These eat CPU and memory and weigh more when scheduling.
+var hypervisorBuilderPrefixes = []string{
+	"hyperv-",
+	"parallels-",
+	"qemu",
+	"virtualbox-",
+	"vmware-",
+}
+
+var (
+	// defaultBuildResources are used for builds that did not declare what
+	// they use and run remotely, like most cloud builds.
+	defaultBuildResources = packer.BuildResources{CPU: 1, Memory: 256 * uint64(datasize.MB)}
+	// defaultHypervisorBuildResources are used for builds that did not
+	// declare what they use and run a VM on the host.
+	defaultHypervisorBuildResources = packer.BuildResources{CPU: 2, Memory: 2 * uint64(datasize.GB)}
+)
+
+// parallelBuildsFlag parses the -parallel-builds flag, which is either a
+// number of builds or "auto".
+type parallelBuildsFlag struct {
+	ba *BuildArgs
+}
+
+func (f *parallelBuildsFlag) String() string {
+	if f.ba == nil {
+		return ""
+	}
+	if f.ba.ParallelBuildsAuto {
+		return parallelBuildsAuto
+	}
+	return strconv.FormatInt(f.ba.ParallelBuilds, 10)
+}
+
+func (f *parallelBuildsFlag) Set(value string) error {
+	if value == parallelBuildsAuto {
+		f.ba.ParallelBuildsAuto = true
+		f.ba.ParallelBuilds = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number or %q", parallelBuildsAuto)
+	}
+	f.ba.ParallelBuildsAuto = false
+	f.ba.ParallelBuilds = n
+	return nil
+}
+
+// byteSizeFlag parses sizes like "8GB" into a number of bytes.
+type byteSizeFlag uint64
+
+func (f *byteSizeFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return datasize.ByteSize(*f).String()
+}
+
+func (f *byteSizeFlag) Set(value string) error {
+	var size datasize.ByteSize
+	if err := size.UnmarshalText([]byte(value)); err != nil {
+		return err
+	}
+	*f = byteSizeFlag(size)
+	return nil
+}
+
+// hostBudget returns the resources builds can share on this host: the ones
+// set with -parallel-cpu and -parallel-memory or else all CPUs and the
+// currently available memory.
+func hostBudget(cla *BuildArgs) packer.BuildResources {
+	budget := packer.BuildResources{
+		CPU:    cla.ParallelCPU,
+		Memory: cla.ParallelMemory,
+	}
+	if budget.CPU < 1 {
+		budget.CPU = int64(runtime.NumCPU())
+	}
+	if budget.Memory == 0 {
+		vm, err := mem.VirtualMemory()
+		if err != nil {
+			log.Printf("[WARN] Could not read available memory, scheduling builds from CPU count only: %s", err)
+			budget.Memory = uint64(budget.CPU) * defaultHypervisorBuildResources.Memory
+		} else {
+			budget.Memory = vm.Available
+		}
+	}
+	return budget
+}
+
+// buildResources returns what the build declared it uses on the host, or a
+// default depending on its builder type. The result never exceeds budget so
+// that every build can eventually run.
+func buildResources(b packersdk.Build, budget packer.BuildResources) packer.BuildResources {
+	res := defaultBuildResources
+	if cb, ok := b.(*packer.CoreBuild); ok {
+		for _, prefix := range hypervisorBuilderPrefixes {
+			if strings.HasPrefix(cb.BuilderType, prefix) {
+				res = defaultHypervisorBuildResources
+				break
+			}
+		}
+		if cb.Resources != nil {
+			if cb.Resources.CPU > 0 {
+				res.CPU = cb.Resources.CPU
+			}
+			if cb.Resources.Memory > 0 {
+				res.Memory = cb.Resources.Memory
+			}
+		}
+	}
+	if res.CPU > budget.CPU {
+		res.CPU = budget.CPU
+	}
+	if res.Memory > budget.Memory {
+		res.Memory = budget.Memory
+	}
+	return res
+}
+
+// typeLimitsFlag parses the -parallel-builds-by-type flag, which can be set
+// several times, like -parallel-builds-by-type=vmware=2.
+type typeLimitsFlag map[string]int64
+
+func (f *typeLimitsFlag) String() string {
+	var limits []string
+	for typ, limit := range *f {
+		limits = append(limits, fmt.Sprintf("%s=%d", typ, limit))
+	}
+	sort.Strings(limits)
+	return strings.Join(limits, ",")
+}
+
+func (f *typeLimitsFlag) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i < 1 {
+		return fmt.Errorf("expected TYPE=N, like vmware=2")
+	}
+	limit, err := strconv.ParseInt(value[i+1:], 10, 64)
+	if err != nil || limit < 0 {
+		return fmt.Errorf("expected a positive number of builds, or 0 for no limit")
+	}
+	if *f == nil {
+		*f = typeLimitsFlag{}
+	}
+	(*f)[value[:i]] = limit
+	return nil
+}
+
+// buildScheduler starts the builds queued in order, keeping the builds
+// running at the same time within limits: a number of builds, a number of
+// builds by builder type, and the CPU and memory budget of the host. A build
+// waiting for builds of its type to finish does not hold back the builds
+// queued after it.
+type buildScheduler struct {
+	// limit is the most builds running at the same time, 0 means no limit.
+	limit int64
+	// typeLimits are the most builds running at the same time by builder
+	// type or plugin name. The flags override the limits of templates.
+	typeLimits, flagTypeLimits map[string]int64
+
+	packing bool
+	budget  packer.BuildResources
+
+	l       sync.Mutex
+	pending []*queuedBuild
+	running []*queuedBuild
+	used    packer.BuildResources
+}
+
+// queuedBuild is a build waiting in the queue of a buildScheduler, until
+// ready is closed.
+type queuedBuild struct {
+	name string
+	// builderType is matched against the type limits when the builds are
+	// dispatched: the templates of a workspace can set limits after the
+	// builds of the other ones were queued, or started.
+	builderType string
+	res         packer.BuildResources
+	queued      time.Time
+	// reason tells why the build is pending.
+	reason string
+	ready  chan struct{}
+}
+
+func newBuildScheduler(cla *BuildArgs) *buildScheduler {
+	s := &buildScheduler{
+		limit:          cla.ParallelBuilds,
+		typeLimits:     map[string]int64{},
+		flagTypeLimits: cla.ParallelBuildsByType,
+	}
+	if s.limit == math.MaxInt64 {
+		s.limit = 0
+	}
+	for typ, limit := range cla.ParallelBuildsByType {
+		s.typeLimits[typ] = limit
+	}
+	if cla.ParallelBuildsAuto || cla.ParallelCPU > 0 || cla.ParallelMemory > 0 {
+		s.packing = true
+		s.budget = hostBudget(cla)
+		log.Printf("Scheduling builds within %d CPU(s) and %s of memory",
+			s.budget.CPU, datasize.ByteSize(s.budget.Memory).HR())
+	}
+	return s
+}
+
+// SetTypeLimits adds the limits by builder type of a template to the ones
+// set with -parallel-builds-by-type, which take precedence. The builds of a
+// workspace share the limits: when templates set different limits for a
+// type, the lowest applies.
+func (s *buildScheduler) SetTypeLimits(limits map[string]int64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for typ, limit := range limits {
+		if _, ok := s.flagTypeLimits[typ]; ok {
+			continue
+		}
+		if current, ok := s.typeLimits[typ]; ok && current > 0 && (limit == 0 || current < limit) {
+			continue
+		}
+		s.typeLimits[typ] = limit
+	}
+}
+
+// typeLimitKeys returns the keys of the type limits applying to builds of
+// builderType: the type itself, and the name of its plugin, "vmware" for
+// vmware-iso, sorted.
+func typeLimitKeys(limits map[string]int64, builderType string) []string {
+	var keys []string
+	for key, limit := range limits {
+		if limit > 0 && (key == builderType || strings.HasPrefix(builderType, key+"-")) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Queue queues b to start once the limits allow it. Builds must be queued in
+// the order they should start in.
+func (s *buildScheduler) Queue(b packersdk.Build) *queuedBuild {
+	s.l.Lock()
+	defer s.l.Unlock()
+	q := &queuedBuild{
+		name:   b.Name(),
+		queued: time.Now(),
+		ready:  make(chan struct{}),
+	}
+	if cb, ok := b.(*packer.CoreBuild); ok {
+		q.builderType = cb.BuilderType
+	}
+	if s.packing {
+		q.res = buildResources(b, s.budget)
+		log.Printf("Build %s needs %d CPU(s) and %s of memory", q.name, q.res.CPU,
+			datasize.ByteSize(q.res.Memory).HR())
+	}
+	s.pending = append(s.pending, q)
+	s.dispatch()
+	return q
+}
+
+// Wait blocks until the queued build q starts, telling on ui why it is
+// pending. The returned func must be called once the build is done to give
+// its place back.
+func (s *buildScheduler) Wait(ctx context.Context, q *queuedBuild, ui packersdk.Ui) (func(), error) {
+	release := func() { s.release(q) }
+	s.l.Lock()
+	select {
+	case <-q.ready:
+		s.l.Unlock()
+		return release, nil
+	default:
+	}
+	reason := q.reason
+	s.l.Unlock()
+	ui.Say(fmt.Sprintf("Build '%s' is pending: %s.", q.name, reason))
+	ui.Machine(fmt.Sprintf("%s,%s", q.name, machineBuildPending), reason)
+
+	select {
+	case <-q.ready:
+	case <-ctx.Done():
+		s.l.Lock()
+		defer s.l.Unlock()
+		select {
+		case <-q.ready:
+			// started meanwhile
+			s.stop(q)
+		default:
+			s.pending = removeQueuedBuild(s.pending, q)
+		}
+		s.dispatch()
+		return nil, ctx.Err()
+	}
+
+	waited := time.Since(q.queued).Round(time.Second)
+	running, pending := s.status()
+	msg := fmt.Sprintf("Build '%s' is starting, after waiting %s. Running: %s.", q.name, waited, strings.Join(running, ", "))
+	if len(pending) > 0 {
+		msg += fmt.Sprintf(" Pending: %s.", strings.Join(pending, ", "))
+	}
+	ui.Say(msg)
+	ui.Machine(fmt.Sprintf("%s,%s", q.name, machineBuildStarted), waited.String())
+	return release, nil
+}
+
+// release gives the place of the build q back, once it is done.
+func (s *buildScheduler) release(q *queuedBuild) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.stop(q)
+	s.dispatch()
+}
+
+// status returns the names of the running and pending builds.
+func (s *buildScheduler) status() (running, pending []string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for _, q := range s.running {
+		running = append(running, q.name)
+	}
+	for _, q := range s.pending {
+		pending = append(pending, q.name)
+	}
+	return running, pending
+}
+
+// dispatch starts the pending builds the limits allow, in order, the lock
+// being held. A build held back by the number of builds or the budget of the
+// host holds back the builds queued after it, so that big builds are not
+// overtaken forever by smaller ones.
+func (s *buildScheduler) dispatch() {
+	var pending []*queuedBuild
+	blocked := ""
+	for _, q := range s.pending {
+		if blocked != "" {
+			q.reason = blocked
+			pending = append(pending, q)
+			continue
+		}
+		if reason := s.typeLimitReached(q); reason != "" {
+			q.reason = reason
+			pending = append(pending, q)
+			continue
+		}
+		if reason := s.limitReached(q); reason != "" {
+			blocked = reason
+			q.reason = reason
+			pending = append(pending, q)
+			continue
+		}
+		s.start(q)
+	}
+	s.pending = pending
+}
+
+func (s *buildScheduler) typeLimitReached(q *queuedBuild) string {
+	for _, typ := range typeLimitKeys(s.typeLimits, q.builderType) {
+		running := int64(0)
+		for _, r := range s.running {
+			if r.builderType == typ || strings.HasPrefix(r.builderType, typ+"-") {
+				running++
+			}
+		}
+		if limit := s.typeLimits[typ]; running >= limit {
+			return fmt.Sprintf("%d %s build(s) running, the most allowed", limit, typ)
+		}
+	}
+	return ""
+}
+
+func (s *buildScheduler) limitReached(q *queuedBuild) string {
+	if s.limit > 0 && int64(len(s.running)) >= s.limit {
+		return fmt.Sprintf("%d build(s) running, the most allowed by -parallel-builds", s.limit)
+	}
+	if s.packing && (s.used.CPU+q.res.CPU > s.budget.CPU || s.used.Memory+q.res.Memory > s.budget.Memory) {
+		return "waiting for CPU and memory of the host"
+	}
+	return ""
+}
+
+func (s *buildScheduler) start(q *queuedBuild) {
+	s.running = append(s.running, q)
+	s.used.CPU += q.res.CPU
+	s.used.Memory += q.res.Memory
+	close(q.ready)
+}
+
+func (s *buildScheduler) stop(q *queuedBuild) {
+	s.running = removeQueuedBuild(s.running, q)
+	s.used.CPU -= q.res.CPU
+	s.used.Memory -= q.res.Memory
+}
+
+func removeQueuedBuild(queue []*queuedBuild, q *queuedBuild) []*queuedBuild {
+	for i := range queue {
+		if queue[i] == q {
+			return append(queue[:i], queue[i+1:]...)
+		}
+	}
+	return queue
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/posener/complete"
+)
+
+type PlanCommand struct {
+	Meta
+}
+
+func (c *PlanCommand) Run(args []string) int {
+	ctx := context.Background()
+
+	cfg, ret := c.ParseArgs(args)
+	if ret != 0 {
+		return ret
+	}
+
+	return c.RunContext(ctx, cfg)
+}
+
+func (c *PlanCommand) ParseArgs(args []string) (*PlanArgs, int) {
+	var cfg PlanArgs
+	flags := c.Meta.FlagSet("plan", FlagSetBuildFilter|FlagSetVars)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	cfg.AddFlagSets(flags)
+	if err := flags.Parse(args); err != nil {
+		return &cfg, ExitUsage
+	}
+
+	args = flags.Args()
+	if len(args) != 1 || cfg.HistoryFile == "" {
+		flags.Usage()
+		return &cfg, ExitUsage
+	}
+	cfg.Path = args[0]
+	return &cfg, 0
+}
+
+func (c *PlanCommand) RunContext(ctx context.Context, cla *PlanArgs) int {
+	history, err := loadBuildHistory(cla.HistoryFile)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to read build history: %s", err))
+		return ExitError
+	}
+
+	packerStarter, ret := c.GetConfig(&cla.MetaArgs)
+	if ret != 0 {
+		return ExitValidation
+	}
+	diags := packerStarter.Initialize(packer.InitializeOptions{Context: ctx})
+	if ret := writeDiags(c.Ui, nil, diags); ret != 0 {
+		return ExitValidation
+	}
+	builds, diags := packerStarter.GetBuilds(packer.GetBuildsOptions{
+		Only:   cla.Only,
+		Except: cla.Except,
+	})
+	if ret := writeDiags(c.Ui, nil, diags); ret != 0 {
+		return ExitValidation
+	}
+
+	fingerprints, err := fingerprintBuilds(&cla.MetaArgs, packerStarter, builds, cla.HistoryFile)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to fingerprint builds: %s", err))
+		return ExitError
+	}
+
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rebuilds := 0
+	for _, name := range names {
+		ui := &packer.TargetedUI{
+			Target: name,
+			Ui:     c.Ui,
+		}
+		previous, found := history.Builds[name]
+		if !found {
+			rebuilds++
+			ui.Machine("plan", "never-built")
+			c.Ui.Say(fmt.Sprintf("==> %s: never built, a build is needed.", name))
+			continue
+		}
+		changes := fingerprints[name].diff(previous)
+		if len(changes) == 0 {
+			ui.Machine("plan", "unchanged")
+			c.Ui.Say(fmt.Sprintf("==> %s: unchanged since the build of %s, no rebuild needed.",
+				name, previous.Time.Format("2006-01-02T15:04:05Z07:00")))
+			continue
+		}
+		rebuilds++
+		ui.Machine("plan", "changed")
+		c.Ui.Say(fmt.Sprintf("==> %s: changed since the build of %s:",
+			name, previous.Time.Format("2006-01-02T15:04:05Z07:00")))
+		for _, change := range changes {
+			ui.Machine("plan", "change", change)
+			c.Ui.Say("    " + change)
+		}
+	}
+
+	if rebuilds > 0 && cla.DetailedExitCode {
+		return ExitChanges
+	}
+	return ExitSuccess
+}
+
+func (*PlanCommand) Help() string {
+	helpText := `
+Usage: packer plan [options] -history-file=PATH TEMPLATE
+
+  Shows, for each build of the template, what changed since its last
+  successful build recorded with 'packer build -history-file=PATH': the
+  components used, template and var files, variable values and files next to
+  the template, like provisioning scripts. No build is started.
+
+Options:
+
+  -detailed-exitcode            Exit with 6 when at least one build needs to run.
+  -except=foo,bar,baz           Plan all builds other than these.
+  -history-file=path            File the builds were recorded in.
+  -only=foo,bar,baz             Plan only the specified builds.
+  -var 'key=value'              Variable for templates, can be used multiple times.
+                                A value of @FILE is read from FILE.
+  -var-file=path                JSON or HCL2 file containing user variables.
+`
+
+	return strings.TrimSpace(helpText) + "\n\n" + exitCodesHelp + "\n  6  With -detailed-exitcode, at least one build needs to run."
+}
+
+func (*PlanCommand) Synopsis() string {
+	return "show what changed since the last recorded build"
+}
+
+func (*PlanCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (*PlanCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-detailed-exitcode": complete.PredictNothing,
+		"-except":            complete.PredictNothing,
+		"-history-file":      complete.PredictNothing,
+		"-only":              complete.PredictNothing,
+		"-var":               complete.PredictNothing,
+		"-var-file":          complete.PredictNothing,
+	}
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	historyFile := filepath.Join(dir, "history.json")
+	template := filepath.Join(testFixture("var-arg"), "fruit_builder.json")
+	defer os.Remove("pear.txt")
+
+	plan := func(expectedCode int, args ...string) {
+		t.Helper()
+		c := &PlanCommand{
+			Meta: testMetaFile(t),
+		}
+		args = append([]string{"-detailed-exitcode", "-history-file=" + historyFile}, args...)
+		if code := c.Run(append(args, template)); code != expectedCode {
+			fatalCommand(t, c.Meta)
+		}
+	}
+
+	// never built
+	plan(ExitChanges, "-var=fruit=pear")
+
+	run(t, []string{"-history-file=" + historyFile, "-var=fruit=pear", template}, ExitSuccess)
+
+	plan(ExitSuccess, "-var=fruit=pear")
+	plan(ExitChanges, "-var=fruit=banana")
+}
+
+func TestPlan_requiresHistoryFile(t *testing.T) {
+	c := &PlanCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := c.Run([]string{filepath.Join(testFixture("var-arg"), "fruit_builder.json")}); code != ExitUsage {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestPlan_newerHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	historyFile := filepath.Join(dir, "history.json")
+	if err := ioutil.WriteFile(historyFile, []byte(`{"version": 99, "builds": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &PlanCommand{
+		Meta: testMetaFile(t),
+	}
+	template := filepath.Join(testFixture("var-arg"), "fruit_builder.json")
+	if code := c.Run([]string{"-history-file=" + historyFile, template}); code != ExitError {
+		fatalCommand(t, c.Meta)
+	}
+	if _, stderr := outputCommand(t, c.Meta); !strings.Contains(stderr, "newer version of Packer") {
+		t.Errorf("expected a notice about the format of the history, got %q", stderr)
+	}
+}
+
+func TestBuildCommand_forgetDowngradedBuilds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	historyFile := filepath.Join(dir, "history.json")
+
+	recorded := &buildHistory{Builds: map[string]buildFingerprint{
+		"amazon-ebs.ubuntu": {Plugins: map[string]string{"github.com/hashicorp/amazon": "v1.2.0"}},
+		"amazon-ebs.debian": {Plugins: map[string]string{"github.com/hashicorp/amazon": "v1.1.0"}},
+	}}
+	if err := recorded.save(historyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	current := map[string]string{"github.com/hashicorp/amazon": "v1.1.0"}
+	c.forgetDowngradedBuilds(historyFile, map[string]buildFingerprint{
+		"amazon-ebs.ubuntu": {Plugins: current},
+		"amazon-ebs.debian": {Plugins: current},
+	})
+
+	history, err := loadBuildHistory(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := history.Builds["amazon-ebs.ubuntu"]; found {
+		t.Error("the downgraded build should be forgotten")
+	}
+	if _, found := history.Builds["amazon-ebs.debian"]; !found {
+		t.Error("the build using the same plugin version should be kept")
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "github.com/hashicorp/amazon v1.2.0 -> v1.1.0") {
+		t.Errorf("expected a downgrade notice, got %q", stderr)
+	}
+}
+
+func TestPlan_localFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	historyFile := filepath.Join(dir, "history.json")
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("template.pkr.hcl", `
+variable "secret" {
+  type      = string
+  sensitive = true
+}
+
+source "file" "out" {
+  content = file("content.txt")
+  target  = "${path.root}/output/out.txt"
+}
+
+build {
+  sources = ["source.file.out"]
+}
+`)
+	write("content.txt", "chocolate")
+
+	plan := func(expectedCode int, args ...string) {
+		t.Helper()
+		c := &PlanCommand{
+			Meta: testMetaFile(t),
+		}
+		args = append([]string{"-detailed-exitcode", "-history-file=" + historyFile}, args...)
+		if code := c.Run(append(args, dir)); code != expectedCode {
+			fatalCommand(t, c.Meta)
+		}
+	}
+
+	run(t, []string{"-history-file=" + historyFile, "-var=secret=plan-secret-one", dir}, ExitSuccess)
+
+	// the output of the build and the other files are not inputs
+	write("notes.txt", "unrelated")
+	plan(ExitSuccess, "-var=secret=plan-secret-one")
+
+	// sensitive values are not fingerprinted, not to be found back from
+	// their hash
+	plan(ExitSuccess, "-var=secret=plan-secret-two")
+	history, err := ioutil.ReadFile(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(history), "var.secret") {
+		t.Errorf("the history must not record sensitive variables:\n%s", history)
+	}
+
+	write("content.txt", "vanilla")
+	plan(ExitChanges, "-var=secret=plan-secret-one")
+}
+
+func Test_diffPluginVersions(t *testing.T) {
+	previous := map[string]string{"zeta": "1.0.0", "beta": "2.0.0", "alpha": "1.0.0", "gone": "1.0.0"}
+	current := map[string]string{"zeta": "1.1.0", "beta": "1.0.0", "alpha": "1.0.0", "new": "0.1.0"}
+	want := []string{
+		"~ plugin beta 2.0.0 -> 1.0.0 (downgrade)",
+		"- plugin gone",
+		"+ plugin new 0.1.0",
+		"~ plugin zeta 1.0.0 -> 1.1.0",
+	}
+	// maps are ranged over in a random order
+	for i := 0; i < 10; i++ {
+		if diff := cmp.Diff(want, diffPluginVersions(previous, current)); diff != "" {
+			t.Fatalf("unexpected changes: %s", diff)
+		}
+	}
+}
+
+func Test_fingerprintVariables(t *testing.T) {
+	cfg := &hcl2template.PackerConfig{
+		InputVariables: hcl2template.Variables{
+			"region": &hcl2template.Variable{Name: "region", Values: []hcl2template.VariableAssignment{
+				{From: "default", Value: cty.StringVal("eu-west-1")},
+			}},
+			"token": &hcl2template.Variable{Name: "token", Sensitive: true, Values: []hcl2template.VariableAssignment{
+				{From: "default", Value: cty.StringVal("abc")},
+			}},
+		},
+		LocalVariables: hcl2template.Variables{
+			"auth": &hcl2template.Variable{Name: "auth", Values: []hcl2template.VariableAssignment{
+				{From: "default", Value: cty.StringVal("Bearer abc").Mark("sensitive")},
+			}},
+		},
+	}
+	got := fingerprintVariables(cfg, nil)
+	if _, ok := got["var.region"]; !ok || len(got) != 1 {
+		t.Fatalf("only the variables that are not sensitive must be fingerprinted: %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/cli"
+)
+
+// PluginSubcommand runs a subcommand offered by an installed plugin, like
+// `packer amazon cleanup-amis`.
+type PluginSubcommand struct {
+	Meta
+
+	Command packer.PluginCommand
+}
+
+func (c *PluginSubcommand) Run(args []string) int {
+	cmd := c.Command.Cmd(args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to run %s: %s", c.Command.Path, err))
+		return 1
+	}
+	return 0
+}
+
+// Help asks the plugin for the help of the subcommand, and falls back to its
+// synopsis.
+func (c *PluginSubcommand) Help() string {
+	out, err := c.Command.Cmd("-help").Output()
+	if help := strings.TrimSpace(string(out)); err == nil && help != "" {
+		return help
+	}
+	return fmt.Sprintf("Usage: packer %s %s [options]\n\n  %s",
+		c.Command.Plugin, c.Command.Name, c.Command.Synopsis)
+}
+
+func (c *PluginSubcommand) Synopsis() string {
+	return c.Command.Synopsis
+}
+
+// PluginCommandsCommand is the parent of the subcommands of a plugin, like
+// `packer amazon`. It only shows its help, which lists the subcommands.
+type PluginCommandsCommand struct {
+	Plugin string
+	// Path is the path to the plugin binary.
+	Path string
+}
+
+func (c *PluginCommandsCommand) Run(_ []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *PluginCommandsCommand) Help() string {
+	return fmt.Sprintf("Usage: packer %s <subcommand> [options]\n\n"+
+		"  Runs the commands offered by the %s plugin, installed at %s.",
+		c.Plugin, c.Plugin, c.Path)
+}
+
+func (c *PluginCommandsCommand) Synopsis() string {
+	return fmt.Sprintf("commands offered by the %s plugin", c.Plugin)
+}
+
+// PluginCommandFactories returns the factories of the subcommands offered by
+// plugins, and of their parent commands. Plugins named like a command of
+// reserved are skipped.
+func PluginCommandFactories(meta *Meta, commands map[string]packer.PluginCommand, reserved map[string]cli.CommandFactory) map[string]cli.CommandFactory {
+	keys := make([]string, 0, len(commands))
+	for key := range commands {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := map[string]cli.CommandFactory{}
+	for _, key := range keys {
+		command := commands[key]
+		if _, found := reserved[command.Plugin]; found {
+			log.Printf("[WARN] ignoring the %q command of the %s plugin, it is named like a packer command",
+				command.Name, command.Plugin)
+			continue
+		}
+		if _, found := res[command.Plugin]; !found {
+			parent := &PluginCommandsCommand{Plugin: command.Plugin, Path: command.Path}
+			res[command.Plugin] = func() (cli.Command, error) {
+				return parent, nil
+			}
+		}
+		res[key] = func() (cli.Command, error) {
+			return &PluginSubcommand{Meta: *meta, Command: command}, nil
+		}
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/cli"
+)
+
+func TestPluginCommandFactories(t *testing.T) {
+	commands := map[string]packer.PluginCommand{
+		"cloud cleanup": {Plugin: "cloud", Name: "cleanup"},
+		"cloud list":    {Plugin: "cloud", Name: "list"},
+		"build reset":   {Plugin: "build", Name: "reset"},
+	}
+	reserved := map[string]cli.CommandFactory{"build": nil}
+
+	factories := PluginCommandFactories(&Meta{}, commands, reserved)
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"cloud", "cloud cleanup", "cloud list"}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Fatalf("unexpected commands: %s", diff)
+	}
+}
+
+func TestPluginSubcommand_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake plugin is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "packer-plugin-subcommand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fake plugin exits with the number of arguments of the subcommand
+	plugin := filepath.Join(dir, "packer-plugin-cloud")
+	script := "#!/bin/sh\n[ \"$1 $2\" = \"command cleanup\" ] || exit 100\nshift 2\nexit $#\n"
+	if err := ioutil.WriteFile(plugin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &PluginSubcommand{
+		Meta:    testMetaFile(t),
+		Command: packer.PluginCommand{Plugin: "cloud", Name: "cleanup", Path: plugin},
+	}
+	if code := c.Run([]string{"-older-than", "30d", "-dry-run"}); code != 3 {
+		t.Fatalf("expected the exit code of the plugin, 3, got %d", code)
+	}
+}
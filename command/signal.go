@@ -2,16 +2,20 @@ package command
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
-func handleTermInterrupt(ui packersdk.Ui) (context.Context, func()) {
+// handleTermInterrupt returns a context cancelled on a first interrupt, and a
+// channel closed on a second one, for the command to return without waiting
+// for what cannot be cancelled, see runUntilForced.
+func handleTermInterrupt(ui packersdk.Ui) (context.Context, <-chan struct{}, func()) {
 	ctx, cancelCtx := context.WithCancel(context.Background())
+	forced := make(chan struct{})
 	// Handle interrupts for this build
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
@@ -28,10 +32,33 @@ func handleTermInterrupt(ui packersdk.Ui) (context.Context, func()) {
 				// triggered first
 				return
 			}
-			ui.Error(fmt.Sprintf("Cancelling build after receiving %s", sig))
+			messages.Error(ui, messages.InterruptCancelling, sig)
 			cancelCtx()
 		case <-ctx.Done():
+			return
 		}
+		// Some phases, like the evaluation of data sources, cannot be
+		// cancelled. A second interrupt returns without waiting for them.
+		if sig := <-sigCh; sig != nil {
+			messages.Error(ui, messages.InterruptExiting, sig)
+			close(forced)
+		}
+	}()
+	return ctx, forced, cleanup
+}
+
+// runUntilForced returns the exit code of run, or ExitInterrupted as soon as
+// forced is closed without waiting for run to return. The deferred cleanups
+// of main, like stopping the plugins, still run.
+func runUntilForced(forced <-chan struct{}, run func() int) int {
+	done := make(chan int, 1)
+	go func() {
+		done <- run()
 	}()
-	return ctx, cleanup
+	select {
+	case code := <-done:
+		return code
+	case <-forced:
+		return ExitInterrupted
+	}
 }
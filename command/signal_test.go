@@ -0,0 +1,18 @@
+package command
+
+import "testing"
+
+func TestRunUntilForced(t *testing.T) {
+	forced := make(chan struct{})
+	if code := runUntilForced(forced, func() int { return ExitCancelled }); code != ExitCancelled {
+		t.Fatalf("expected the exit code of the command, got %d", code)
+	}
+
+	// the command is stuck in a phase that cannot be cancelled
+	stuck := make(chan struct{})
+	defer close(stuck)
+	close(forced)
+	if code := runUntilForced(forced, func() int { <-stuck; return ExitSuccess }); code != ExitInterrupted {
+		t.Fatalf("expected %d once forced, got %d", ExitInterrupted, code)
+	}
+}
@@ -0,0 +1,24 @@
+source "file" "chocolate" {
+  content = "chocolate"
+  target  = "chocolate.txt"
+}
+
+source "file" "vanilla" {
+  content = "vanilla"
+  target  = "vanilla.txt"
+}
+
+build {
+  sources = [
+    "sources.file.chocolate",
+    "sources.file.vanilla",
+  ]
+}
+
+// runs once both builds are done.
+post-processors {
+  post-processor "shell-local" {
+    name   = "tomato"
+    inline = ["cat chocolate.txt vanilla.txt > tomato.txt"]
+  }
+}
@@ -0,0 +1,18 @@
+variable "targets" {
+  type    = list(string)
+  default = ["replicas/eu", "replicas/us"]
+}
+
+source "file" "chocolate" {
+  content = "chocolate"
+  target  = "chocolate.txt"
+}
+
+build {
+  sources = ["source.file.chocolate"]
+
+  replicate {
+    targets      = var.targets
+    max_parallel = 1
+  }
+}
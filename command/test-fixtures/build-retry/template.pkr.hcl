@@ -0,0 +1,11 @@
+source "flaky" "spot" {
+}
+
+build {
+  sources = ["source.flaky.spot"]
+
+  retry {
+    attempts    = 3
+    min_backoff = "1ms"
+  }
+}
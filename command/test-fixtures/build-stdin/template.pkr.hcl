@@ -0,0 +1,12 @@
+variable "flavor" {
+  type = string
+}
+
+source "file" "chocolate" {
+  content = "${var.flavor} from ${path.root}"
+  target  = "chocolate.txt"
+}
+
+build {
+  sources = ["source.file.chocolate"]
+}
@@ -0,0 +1,7 @@
+data "mock" "base" {
+  foo = "ami-${var.region}"
+}
+
+locals {
+  base_ami = data.mock.base.foo
+}
@@ -0,0 +1,8 @@
+variable "region" {
+  type    = string
+  default = "us-east-1"
+}
+
+locals {
+  name = "image-${var.region}"
+}
@@ -7,7 +7,7 @@ build {
 		"source.null.example"
 	]
 	provisioner "shell-local" {
-		script = "./${path.root}/test_cmd.cmd"
+		script = "${path.root}/test_cmd.cmd"
 		environment_vars = ["USER=packeruser", "BUILDER=${upper(build.ID)}"]
 	}
 }
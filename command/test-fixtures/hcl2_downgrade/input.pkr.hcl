@@ -0,0 +1,61 @@
+packer {
+  required_version = ">= 1.6.0"
+}
+
+variable "region" {
+  type    = string
+  default = "us-east-1"
+}
+
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+variable "token" {
+  default = env("TOKEN")
+}
+
+locals {
+  prefix = "app-${var.region}"
+}
+
+data "amazon-ami" "ubuntu" {
+  region = var.region
+}
+
+source "null" "web" {
+  communicator = "none"
+}
+
+source "null" "db" {
+  communicator = "none"
+}
+
+build {
+  description = "builds the images"
+
+  sources = ["source.null.web"]
+
+  source "source.null.db" {
+    name = "primary"
+  }
+
+  provisioner "shell-local" {
+    inline = ["echo ${local.prefix}-${source.name}", "echo ${build.ID}"]
+  }
+
+  provisioner "shell-local" {
+    only   = ["null.primary"]
+    inline = ["echo ${upper(var.region)}"]
+  }
+
+  post-processors {
+    post-processor "manifest" {
+      output = "${path.root}/manifest.json"
+    }
+    post-processor "shell-local" {
+      inline = ["echo ${timestamp()}"]
+    }
+  }
+}
@@ -12,8 +12,16 @@
 # executed together and the outcome will be unknown.
 
 # See https://www.packer.io/docs/templates/hcl_templates/blocks/packer for more info
+# required_version was raised from min_packer_version 1.6.0 to 1.7.0:
+# data blocks and required_plugins blocks need Packer 1.7.0 or later.
 packer {
-  required_version = ">= 1.6.0"
+  required_version = ">= 1.7.0"
+  required_plugins {
+    amazon = {
+      source  = "github.com/hashicorp/amazon"
+      version = ">= 0.0.0"
+    }
+  }
 }
 
 # All generated input variables will be of 'string' type as this is how Packer JSON
@@ -50,6 +58,12 @@ variable "secret_account" {
 # "timestamp" template function replacement
 locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
 
+# "isotime" template function replacements
+locals {
+  isotime_2006_01_02                   = formatdate("YYYY-MM-DD", timestamp())
+  isotime_Mon_02_Jan_2006_15_04_05_MST = formatdate("EEE, DD MMM YYYY hh:mm:ss ZZZ", timestamp())
+}
+
 # The amazon-ami data block is generated from your amazon builder source_ami_filter; a data
 # from this block can be referenced in source and locals blocks.
 # Read the documentation for data blocks here:
@@ -130,10 +144,13 @@ source "amazon-ebs" "named_builder" {
 build {
   sources = ["source.amazon-ebs.autogenerated_1", "source.amazon-ebs.named_builder"]
 
+
+  # {{ .Vars }}, {{ .Path }}: interpolated by the shell provisioner when it runs, left as is.
   provisioner "shell" {
-    except      = ["amazon-ebs"]
-    inline      = ["echo ${var.secret_account}", "echo ${build.ID}", "echo ${build.SSHPublicKey} | head -c 14", "echo ${path.root} is not ${path.cwd}", "echo ${packer.version}", "echo ${uuidv4()}"]
-    max_retries = "5"
+    except          = ["amazon-ebs.autogenerated_1"]
+    execute_command = "chmod +x {{ .Path }}; {{ .Vars }} {{ .Path }}"
+    inline          = ["echo ${var.secret_account}", "echo ${build.ID}", "echo ${build.SSHPublicKey} | head -c 14", "echo ${path.root} is not ${path.cwd}", "echo ${packer.version}", "echo ${uuidv4()}", "echo ${local.isotime_2006_01_02}", "echo ${local.isotime_Mon_02_Jan_2006_15_04_05_MST}", "echo ${local.isotime_2006_01_02}"]
+    max_retries     = "5"
   }
 
   # template: hcl2_upgrade:2:38: executing "hcl2_upgrade" at <clean_resource_name>: error calling clean_resource_name: unhandled "clean_resource_name" call:
@@ -185,7 +202,7 @@ build {
   }
   provisioner "shell-local" {
     inline  = ["sleep 100000"]
-    only    = ["amazon-ebs"]
+    only    = ["amazon-ebs.autogenerated_1"]
     timeout = "5s"
   }
   post-processor "amazon-import" {
@@ -197,15 +214,19 @@ build {
       Description = "packer amazon-import ${local.timestamp}"
     }
   }
+  # Post-processors chain 2, each post-processor gets the artifact of the previous one:
+  #   1. artifice "very_special_artifice_post-processor" gets the artifact of the build and keeps it
+  #   2. amazon-import gets the artifact of artifice "very_special_artifice_post-processor"
+  # A post-processor skipped by its only or except passes the artifact it gets on to the next one.
   post-processors {
     post-processor "artifice" {
       keep_input_artifact = true
       files               = ["path/something.ova"]
       name                = "very_special_artifice_post-processor"
-      only                = ["amazon-ebs"]
+      only                = ["amazon-ebs.autogenerated_1"]
     }
     post-processor "amazon-import" {
-      except         = ["amazon-ebs"]
+      except         = ["amazon-ebs.autogenerated_1"]
       license_type   = "BYOL"
       s3_bucket_name = "hashicorp.adrien"
       tags = {
@@ -213,4 +234,10 @@ build {
       }
     }
   }
+
+  # {{ .Vars }}, {{ .Script }}: interpolated by the shell-local post-processor when it runs, left as is.
+  post-processor "shell-local" {
+    execute_command = ["/bin/sh", "-c", "{{ .Vars }} {{ .Script }}"]
+    inline          = ["echo ${source.name}"]
+  }
 }
@@ -0,0 +1,52 @@
+# This file was autogenerated by the 'packer hcl2_upgrade' command. We
+# recommend double checking that everything is correct before going forward. We
+# also recommend treating this file as disposable. The HCL2 blocks in this
+# file can be moved to other files. For example, the variable blocks could be
+# moved to their own 'variables.pkr.hcl' file, etc. Those files need to be
+# suffixed with '.pkr.hcl' to be visible to Packer. To use multiple files at
+# once they also need to be in the same folder. 'packer inspect folder/'
+# will describe to you what is in that folder.
+
+# Avoid mixing go templating calls ( for example ```{{ upper(`string`) }}``` )
+# and HCL2 calls (for example '${ var.string_value_example }' ). They won't be
+# executed together and the outcome will be unknown.
+
+# All generated input variables will be of 'string' type as this is how Packer JSON
+# views them; you can change their type later on. Read the variables type
+# constraints documentation
+# https://www.packer.io/docs/templates/hcl_templates/variables#type-constraints for more info.
+# "timestamp" template function replacement
+locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
+
+# source blocks are generated from your builders; a source can be referenced in
+# build blocks. A build block runs provisioner and post-processors on a
+# source. Read the documentation for source blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/source
+# {{ build_name }} was replaced by "null", its value in JSON: a source block cannot reference its own name or type.
+
+source "null" "autogenerated_1" {
+  communicator = "none"
+  ssh_host     = "null.example.com"
+}
+
+# {{ build_name }} was replaced by "web", its value in JSON: a source block cannot reference its own name or type.
+# {{ build_type }} was replaced by "null", its value in JSON: a source block cannot reference its own name or type.
+
+source "null" "web" {
+  communicator = "none"
+  ssh_host     = "web-null.example.com"
+}
+
+# a build block invokes sources and runs provisioning steps on them. The
+# documentation for build blocks can be found here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/build
+build {
+  sources = ["source.null.autogenerated_1", "source.null.web"]
+
+  provisioner "shell-local" {
+    inline = ["echo ${source.name} ${source.type}"]
+  }
+  post-processor "manifest" {
+    output = "${source.name}.json"
+  }
+}
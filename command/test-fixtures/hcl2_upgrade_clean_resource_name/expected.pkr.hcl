@@ -0,0 +1,86 @@
+# This file was autogenerated by the 'packer hcl2_upgrade' command. We
+# recommend double checking that everything is correct before going forward. We
+# also recommend treating this file as disposable. The HCL2 blocks in this
+# file can be moved to other files. For example, the variable blocks could be
+# moved to their own 'variables.pkr.hcl' file, etc. Those files need to be
+# suffixed with '.pkr.hcl' to be visible to Packer. To use multiple files at
+# once they also need to be in the same folder. 'packer inspect folder/'
+# will describe to you what is in that folder.
+
+# Avoid mixing go templating calls ( for example ```{{ upper(`string`) }}``` )
+# and HCL2 calls (for example '${ var.string_value_example }' ). They won't be
+# executed together and the outcome will be unknown.
+
+# See https://www.packer.io/docs/templates/hcl_templates/blocks/packer for more info
+packer {
+  required_plugins {
+    amazon = {
+      source  = "github.com/hashicorp/amazon"
+      version = ">= 0.0.0"
+    }
+    azure = {
+      source  = "github.com/hashicorp/azure"
+      version = ">= 0.0.0"
+    }
+    googlecompute = {
+      source  = "github.com/hashicorp/googlecompute"
+      version = ">= 0.0.0"
+    }
+  }
+}
+
+# All generated input variables will be of 'string' type as this is how Packer JSON
+# views them; you can change their type later on. Read the variables type
+# constraints documentation
+# https://www.packer.io/docs/templates/hcl_templates/variables#type-constraints for more info.
+variable "name" {
+  type    = string
+  default = "My Image"
+}
+
+# "timestamp" template function replacement
+locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
+
+# "isotime" template function replacements
+locals {
+  isotime_2006_01_02 = formatdate("YYYY.MM.DD", timestamp())
+}
+
+# source blocks are generated from your builders; a source can be referenced in
+# build blocks. A build block runs provisioner and post-processors on a
+# source. Read the documentation for source blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/source
+source "amazon-ebs" "autogenerated_1" {
+  ami_name      = "${var.name} ${regex_replace(local.timestamp, "[^]a-zA-Z0-9()[ ./'@_-]", "-")}"
+  instance_type = "t2.micro"
+  region        = "us-east-1"
+  source_ami    = "ami-0123456789"
+  ssh_username  = "ubuntu"
+}
+
+source "azure-arm" "autogenerated_2" {
+  image_offer                       = "UbuntuServer"
+  image_publisher                   = "Canonical"
+  image_sku                         = "18.04-LTS"
+  location                          = "westus"
+  managed_image_name                = "${var.name}-${regex_replace(regex_replace(local.isotime_2006_01_02, "[^a-zA-Z0-9._-]", "-"), "[-_.]+$", "")}"
+  managed_image_resource_group_name = "images"
+  os_type                           = "Linux"
+  subscription_id                   = "0000"
+}
+
+source "googlecompute" "autogenerated_3" {
+  image_name   = "${regex_replace(lower("My Image"), "[^a-z0-9]", "-")}-${regex_replace(lower(var.name), "[^a-z0-9]", "-")}"
+  project_id   = "my-project"
+  source_image = "debian-10"
+  ssh_username = "debian"
+  zone         = "us-central1-a"
+}
+
+# a build block invokes sources and runs provisioning steps on them. The
+# documentation for build blocks can be found here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/build
+build {
+  sources = ["source.amazon-ebs.autogenerated_1", "source.azure-arm.autogenerated_2", "source.googlecompute.autogenerated_3"]
+
+}
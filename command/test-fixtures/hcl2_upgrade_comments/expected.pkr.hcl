@@ -0,0 +1,78 @@
+# This file was autogenerated by the 'packer hcl2_upgrade' command. We
+# recommend double checking that everything is correct before going forward. We
+# also recommend treating this file as disposable. The HCL2 blocks in this
+# file can be moved to other files. For example, the variable blocks could be
+# moved to their own 'variables.pkr.hcl' file, etc. Those files need to be
+# suffixed with '.pkr.hcl' to be visible to Packer. To use multiple files at
+# once they also need to be in the same folder. 'packer inspect folder/'
+# will describe to you what is in that folder.
+
+# Avoid mixing go templating calls ( for example ```{{ upper(`string`) }}``` )
+# and HCL2 calls (for example '${ var.string_value_example }' ). They won't be
+# executed together and the outcome will be unknown.
+
+# Builds the base Ubuntu AMI.
+# Owned by the platform team.
+
+# See https://www.packer.io/docs/templates/hcl_templates/blocks/packer for more info
+packer {
+  required_plugins {
+    amazon = {
+      source  = "github.com/hashicorp/amazon"
+      version = ">= 0.0.0"
+    }
+  }
+}
+
+# All generated input variables will be of 'string' type as this is how Packer JSON
+# views them; you can change their type later on. Read the variables type
+# constraints documentation
+# https://www.packer.io/docs/templates/hcl_templates/variables#type-constraints for more info.
+# "timestamp" template function replacement
+locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
+
+# The amazon-ami data block is generated from your amazon builder source_ami_filter; a data
+# from this block can be referenced in source and locals blocks.
+# Read the documentation for data blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/data
+# Latest Ubuntu 20.04 from Canonical
+data "amazon-ami" "autogenerated_1" {
+  filters = {
+    name = "ubuntu/images/*ubuntu-focal-20.04-amd64-server-*"
+  }
+  most_recent = true
+  owners      = ["099720109477"]
+}
+
+# source blocks are generated from your builders; a source can be referenced in
+# build blocks. A build block runs provisioner and post-processors on a
+# source. Read the documentation for source blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/source
+# Region and instance type are set by the CI.
+source "amazon-ebs" "autogenerated_1" {
+  ami_name      = "ubuntu-base"
+  instance_type = "t2.micro"
+  # A bigger root volume for the build caches
+  launch_block_device_mappings {
+    device_name = "/dev/sda1"
+    volume_size = 40
+  }
+  region       = "us-east-1"
+  source_ami   = "${data.amazon-ami.autogenerated_1.id}"
+  ssh_username = "ubuntu"
+}
+
+# a build block invokes sources and runs provisioning steps on them. The
+# documentation for build blocks can be found here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/build
+build {
+  sources = ["source.amazon-ebs.autogenerated_1"]
+
+  # Runs on the CI runner
+  provisioner "shell-local" {
+    inline = ["echo hello"]
+  }
+  # Read by the release pipeline
+  post-processor "manifest" {
+  }
+}
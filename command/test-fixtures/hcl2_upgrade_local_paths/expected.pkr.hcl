@@ -0,0 +1,49 @@
+# This file was autogenerated by the 'packer hcl2_upgrade' command. We
+# recommend double checking that everything is correct before going forward. We
+# also recommend treating this file as disposable. The HCL2 blocks in this
+# file can be moved to other files. For example, the variable blocks could be
+# moved to their own 'variables.pkr.hcl' file, etc. Those files need to be
+# suffixed with '.pkr.hcl' to be visible to Packer. To use multiple files at
+# once they also need to be in the same folder. 'packer inspect folder/'
+# will describe to you what is in that folder.
+
+# Avoid mixing go templating calls ( for example ```{{ upper(`string`) }}``` )
+# and HCL2 calls (for example '${ var.string_value_example }' ). They won't be
+# executed together and the outcome will be unknown.
+
+# All generated input variables will be of 'string' type as this is how Packer JSON
+# views them; you can change their type later on. Read the variables type
+# constraints documentation
+# https://www.packer.io/docs/templates/hcl_templates/variables#type-constraints for more info.
+variable "scripts_dir" {
+  type    = string
+  default = "scripts"
+}
+
+# "timestamp" template function replacement
+locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
+
+# source blocks are generated from your builders; a source can be referenced in
+# build blocks. A build block runs provisioner and post-processors on a
+# source. Read the documentation for source blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/source
+source "null" "autogenerated_1" {
+  # WARNING: unknown key "floppy_dirs", the null builder does not declare it
+  floppy_dirs = ["drivers"]
+  # WARNING: unknown key "floppy_files", the null builder does not declare it
+  floppy_files = ["${path.root}/floppy/*.sh", "floppy/autounattend.xml", "${var.scripts_dir}/setup.sh"]
+  # WARNING: unknown key "http_directory", the null builder does not declare it
+  http_directory = "http"
+  # WARNING: unknown key "iso_checksum", the null builder does not declare it
+  iso_checksum = "none"
+  # WARNING: unknown key "iso_url", the null builder does not declare it
+  iso_url = "http://example.com/debian.iso"
+}
+
+# a build block invokes sources and runs provisioning steps on them. The
+# documentation for build blocks can be found here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/build
+build {
+  sources = ["source.null.autogenerated_1"]
+
+}
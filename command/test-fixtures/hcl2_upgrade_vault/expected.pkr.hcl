@@ -0,0 +1,63 @@
+# This file was autogenerated by the 'packer hcl2_upgrade' command. We
+# recommend double checking that everything is correct before going forward. We
+# also recommend treating this file as disposable. The HCL2 blocks in this
+# file can be moved to other files. For example, the variable blocks could be
+# moved to their own 'variables.pkr.hcl' file, etc. Those files need to be
+# suffixed with '.pkr.hcl' to be visible to Packer. To use multiple files at
+# once they also need to be in the same folder. 'packer inspect folder/'
+# will describe to you what is in that folder.
+
+# Avoid mixing go templating calls ( for example ```{{ upper(`string`) }}``` )
+# and HCL2 calls (for example '${ var.string_value_example }' ). They won't be
+# executed together and the outcome will be unknown.
+
+# All generated input variables will be of 'string' type as this is how Packer JSON
+# views them; you can change their type later on. Read the variables type
+# constraints documentation
+# https://www.packer.io/docs/templates/hcl_templates/variables#type-constraints for more info.
+# The default of the "api_token" variable calls vault, which HCL2 does not allow in
+# variable defaults, so it was upgraded to local.api_token. The secret is now read
+# from Vault every time the config is evaluated, and can no longer be set with
+# -var or a var file. Read the documentation of the vault function here:
+# https://www.packer.io/docs/templates/hcl_templates/functions/contextual/vault
+local "api_token" {
+  expression = "token-${vault("/secret/data/api", "token")}"
+  sensitive  = true
+}
+
+# The default of the "password" variable calls vault, which HCL2 does not allow in
+# variable defaults, so it was upgraded to local.password. The secret is now read
+# from Vault every time the config is evaluated, and can no longer be set with
+# -var or a var file. Read the documentation of the vault function here:
+# https://www.packer.io/docs/templates/hcl_templates/functions/contextual/vault
+local "password" {
+  expression = vault("/secret/data/build", "password")
+  sensitive  = true
+}
+
+variable "region" {
+  type    = string
+  default = "us-east-1"
+}
+
+# "timestamp" template function replacement
+locals { timestamp = regex_replace(timestamp(), "[- TZ:]", "") }
+
+# source blocks are generated from your builders; a source can be referenced in
+# build blocks. A build block runs provisioner and post-processors on a
+# source. Read the documentation for source blocks here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/source
+source "null" "autogenerated_1" {
+  communicator = "none"
+}
+
+# a build block invokes sources and runs provisioning steps on them. The
+# documentation for build blocks can be found here:
+# https://www.packer.io/docs/templates/hcl_templates/blocks/build
+build {
+  sources = ["source.null.autogenerated_1"]
+
+  provisioner "shell-local" {
+    inline = ["echo ${var.region}", "./deploy --password ${local.password} --token ${local.api_token}"]
+  }
+}
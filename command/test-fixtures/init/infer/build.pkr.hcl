@@ -0,0 +1,38 @@
+packer {
+  required_plugins {
+    amazon = {
+      version = ">= 1.0.0"
+      source  = "github.com/hashicorp/amazon"
+    }
+  }
+}
+
+source "docker" "ubuntu" {
+  image = "ubuntu:20.04"
+}
+
+source "amazon-ebs" "ubuntu" {
+}
+
+source "null" "test" {
+  communicator = "none"
+}
+
+source "mycloud-vm" "test" {
+}
+
+build {
+  sources = ["source.docker.ubuntu", "source.amazon-ebs.ubuntu", "source.null.test", "source.mycloud-vm.test"]
+
+  provisioner "shell" {
+    inline = ["echo hello"]
+  }
+
+  provisioner "ansible" {
+    playbook_file = "playbook.yml"
+  }
+
+  post-processor "docker-tag" {
+    repository = "example/ubuntu"
+  }
+}
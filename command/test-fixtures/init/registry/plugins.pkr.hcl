@@ -0,0 +1,8 @@
+packer {
+  required_plugins {
+    comment = {
+      source  = "github.com/hashicorp/comment"
+      version = ">= 0.2.23"
+    }
+  }
+}
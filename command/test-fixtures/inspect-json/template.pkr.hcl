@@ -0,0 +1,50 @@
+variable "region" {
+  type        = string
+  description = "Region to build the image in"
+  default     = "us-east-1"
+}
+
+variable "password" {
+  type      = string
+  default   = "hunter2"
+  sensitive = true
+}
+
+variable "image_name" {
+  type = string
+}
+
+locals {
+  name = "image-${var.region}"
+}
+
+source "null" "first" {
+  communicator = "none"
+}
+
+source "null" "second" {
+  communicator = "none"
+}
+
+build {
+  name        = "images"
+  description = "Builds the images"
+
+  sources = [
+    "source.null.first",
+    "source.null.second",
+  ]
+
+  provisioner "shell-local" {
+    inline = ["echo ${local.name}"]
+  }
+
+  provisioner "shell-local" {
+    name   = "only-first"
+    only   = ["null.first"]
+    inline = ["echo first"]
+  }
+
+  post-processor "manifest" {
+  }
+}
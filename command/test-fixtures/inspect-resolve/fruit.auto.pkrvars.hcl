@@ -0,0 +1 @@
+fruit = "apple"
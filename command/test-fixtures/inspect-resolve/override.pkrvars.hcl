@@ -0,0 +1,2 @@
+fruit  = "cherry"
+secret = "hunter2"
@@ -0,0 +1,19 @@
+variable "fruit" {
+  type    = string
+  default = "banana"
+}
+
+variable "color" {
+  type    = string
+  default = "yellow"
+}
+
+variable "secret" {
+  type      = string
+  default   = "s3cr3t"
+  sensitive = true
+}
+
+locals {
+  salad = "${var.color} ${var.fruit} salad"
+}
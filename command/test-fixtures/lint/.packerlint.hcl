@@ -0,0 +1,3 @@
+rule "hardcoded-credential" {
+  severity = "error"
+}
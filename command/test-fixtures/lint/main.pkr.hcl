@@ -0,0 +1,13 @@
+variable "unused" {
+  type = string
+}
+
+source "null" "example" {
+  communicator = "ssh"
+  ssh_host     = "127.0.0.1"
+  ssh_password = "vagrant"
+}
+
+build {
+  sources = ["source.null.example"]
+}
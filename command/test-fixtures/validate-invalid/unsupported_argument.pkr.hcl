@@ -0,0 +1,9 @@
+source "file" "chocolate" {
+  target  = "chocolate.txt"
+  content = "chocolate"
+  flavour = "dark"
+}
+
+build {
+  sources = ["source.file.chocolate"]
+}
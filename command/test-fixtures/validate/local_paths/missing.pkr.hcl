@@ -0,0 +1,18 @@
+source "null" "example" {
+  communicator         = "ssh"
+  ssh_host             = "127.0.0.1"
+  ssh_username         = "packer"
+  ssh_private_key_file = "${path.root}/missing.pem"
+}
+
+build {
+  sources = ["source.null.example"]
+
+  provisioner "shell-local" {
+    scripts = ["${path.root}/hello.sh", "${path.root}/missing.sh"]
+  }
+
+  provisioner "shell-local" {
+    script = "${path.root}/${build.ID}.sh"
+  }
+}
@@ -0,0 +1,13 @@
+// the file builder fails to prepare without a target, like a cloud builder
+// without credentials.
+source "file" "chocolate" {
+  content = "chocolate"
+}
+
+build {
+  sources = ["source.file.chocolate"]
+
+  provisioner "shell-local" {
+    inline = ["echo ${build.ID}"]
+  }
+}
@@ -0,0 +1,22 @@
+variable "target" {
+  type    = string
+  default = "output.txt"
+}
+
+variable "unused" {
+  type    = string
+  default = "unused"
+}
+
+locals {
+  content = "hello"
+}
+
+source "file" "test" {
+  content = local.content
+  target  = var.target
+}
+
+build {
+  sources = ["source.file.test"]
+}
@@ -0,0 +1,3 @@
+source "file" "chocolate" {
+  content = "dark chocolate"
+}
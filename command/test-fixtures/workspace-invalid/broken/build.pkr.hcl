@@ -0,0 +1,6 @@
+source "potato" "mash" {
+}
+
+build {
+  sources = ["source.potato.mash"]
+}
@@ -0,0 +1,8 @@
+source "file" "banana" {
+  target  = "banana.txt"
+  content = "banana"
+}
+
+build {
+  sources = ["source.file.banana"]
+}
@@ -0,0 +1 @@
+this is not a template
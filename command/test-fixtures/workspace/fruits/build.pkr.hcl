@@ -0,0 +1,13 @@
+variable "flavour" {
+  type    = string
+  default = "plain"
+}
+
+source "file" "apple" {
+  target  = "apple.txt"
+  content = var.flavour
+}
+
+build {
+  sources = ["source.file.apple"]
+}
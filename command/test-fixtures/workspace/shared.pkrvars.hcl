@@ -0,0 +1 @@
+flavour = "sweet"
@@ -0,0 +1,13 @@
+variable "flavour" {
+  type    = string
+  default = "plain"
+}
+
+source "file" "tomato" {
+  target  = "tomato.txt"
+  content = var.flavour
+}
+
+build {
+  sources = ["source.file.tomato"]
+}
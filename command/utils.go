@@ -15,7 +15,8 @@ func isDir(name string) (bool, error) {
 
 func isHCLLoaded(name string) (bool, error) {
 	if strings.HasSuffix(name, ".pkr.hcl") ||
-		strings.HasSuffix(name, ".pkr.json") {
+		strings.HasSuffix(name, ".pkr.json") ||
+		strings.HasSuffix(name, ".pkr.yaml") {
 		return true, nil
 	}
 	return isDir(name)
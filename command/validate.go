@@ -2,9 +2,14 @@ package command
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/hcl2template"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 
 	"github.com/posener/complete"
 )
@@ -14,7 +19,7 @@ type ValidateCommand struct {
 }
 
 func (c *ValidateCommand) Run(args []string) int {
-	ctx, cleanup := handleTermInterrupt(c.Ui)
+	ctx, forced, cleanup := handleTermInterrupt(c.Ui)
 	defer cleanup()
 
 	cfg, ret := c.ParseArgs(args)
@@ -22,7 +27,9 @@ func (c *ValidateCommand) Run(args []string) int {
 		return ret
 	}
 
-	return c.RunContext(ctx, cfg)
+	return runUntilForced(forced, func() int {
+		return c.RunContext(ctx, cfg)
+	})
 }
 
 func (c *ValidateCommand) ParseArgs(args []string) (*ValidateArgs, int) {
@@ -32,49 +39,258 @@ func (c *ValidateCommand) ParseArgs(args []string) (*ValidateArgs, int) {
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	cfg.AddFlagSets(flags)
 	if err := flags.Parse(args); err != nil {
-		return &cfg, 1
+		return &cfg, ExitUsage
 	}
 
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
-		return &cfg, 1
+		return &cfg, ExitUsage
+	}
+	switch cfg.Output {
+	case "text", "json":
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown output format %q, it must be text or json.", cfg.Output))
+		return &cfg, ExitUsage
 	}
 	cfg.Path = args[0]
 	return &cfg, 0
 }
 
 func (c *ValidateCommand) RunContext(ctx context.Context, cla *ValidateArgs) int {
+	if cla.Output == "json" {
+		return c.runJSON(ctx, cla)
+	}
+
 	packerStarter, ret := c.GetConfig(&cla.MetaArgs)
 	if ret != 0 {
-		return 1
+		return ExitValidation
 	}
 
 	// If we're only checking syntax, then we're done already
 	if cla.SyntaxOnly {
 		c.Ui.Say("Syntax-only check passed. Everything looks okay.")
-		return 0
+		return ExitSuccess
 	}
 
 	diags := packerStarter.Initialize(packer.InitializeOptions{
 		SkipDatasourcesExecution: true,
 	})
+	diags, _ = cla.promoteWarnings(append(diags, c.pluginConflicts(&cla.MetaArgs)...))
 	ret = writeDiags(c.Ui, nil, diags)
+	if ctx.Err() != nil {
+		c.Ui.Error("Cancelled validation after being interrupted.")
+		return ExitCancelled
+	}
 	if ret != 0 {
-		return ret
+		return ExitValidation
 	}
 
-	_, diags = packerStarter.GetBuilds(packer.GetBuildsOptions{
-		Only:   cla.Only,
-		Except: cla.Except,
-	})
+	getBuildsOptions := cla.getBuildsOptions()
+	_, diags = packerStarter.GetBuilds(getBuildsOptions)
+	if getter, ok := packerStarter.(packer.CombinedPostProcessorsGetter); ok {
+		_, moreDiags := getter.GetCombinedPostProcessors(getBuildsOptions)
+		diags = append(diags, moreDiags...)
+	}
 
 	fixerDiags := packerStarter.FixConfig(packer.FixConfigOptions{
 		Mode: packer.Diff,
 	})
 	diags = append(diags, fixerDiags...)
+	diags = append(diags, cla.unusedDeclarations(packerStarter)...)
+
+	diags, _ = cla.promoteWarnings(diags)
+	if writeDiags(c.Ui, nil, diags) != 0 {
+		return ExitValidation
+	}
+	return ExitSuccess
+}
+
+// runJSON validates the config like RunContext, but writes all the
+// diagnostics at once as a JSON document instead of text.
+func (c *ValidateCommand) runJSON(ctx context.Context, cla *ValidateArgs) int {
+	files, diags := c.validate(cla)
+	if ctx.Err() != nil {
+		c.Ui.Error("Cancelled validation after being interrupted.")
+		return ExitCancelled
+	}
+	diags, _ = cla.promoteWarnings(diags)
+
+	out, err := json.MarshalIndent(newValidateOutput(files, diags), "", "  ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to write the diagnostics: %s", err))
+		return ExitError
+	}
+	c.Ui.Say(string(out))
+	if diags.HasErrors() {
+		return ExitValidation
+	}
+	return ExitSuccess
+}
+
+// validate loads and checks the config of cla, stopping at the first step
+// with errors. It returns the diagnostics of all the steps, with the parsed
+// files of an HCL2 config.
+func (c *ValidateCommand) validate(cla *ValidateArgs) (map[string]*hcl.File, hcl.Diagnostics) {
+	cfgType, err := cla.GetConfigType()
+	if err != nil {
+		return nil, errorDiags(fmt.Sprintf("%q: %s", cla.Path, err))
+	}
+
+	var packerStarter packer.Handler
+	var files map[string]*hcl.File
+	var diags hcl.Diagnostics
+	switch cfgType {
+	case ConfigTypeHCL2:
+		var cfg *hcl2template.PackerConfig
+		cfg, files, diags, err = c.parseHCL(&cla.MetaArgs)
+		if err != nil {
+			return nil, errorDiags(err.Error())
+		}
+		packerStarter = cfg
+	default:
+		tpl, err := parseTemplate(&cla.MetaArgs)
+		if err != nil {
+			return nil, errorDiags("Failed to parse template", messages.WithCode(messages.CodeTemplateParse, err).Error())
+		}
+		core, err := c.Core(tpl, &cla.MetaArgs)
+		if err != nil {
+			return nil, errorDiags(err.Error())
+		}
+		packerStarter = &CoreWrapper{core}
+	}
+	if diags.HasErrors() || cla.SyntaxOnly {
+		return files, diags
+	}
+
+	moreDiags := packerStarter.Initialize(packer.InitializeOptions{
+		SkipDatasourcesExecution: true,
+	})
+	diags = append(diags, moreDiags...)
+	diags = append(diags, c.pluginConflicts(&cla.MetaArgs)...)
+	if diags.HasErrors() {
+		return files, diags
+	}
 
-	return writeDiags(c.Ui, nil, diags)
+	getBuildsOptions := cla.getBuildsOptions()
+	_, moreDiags = packerStarter.GetBuilds(getBuildsOptions)
+	diags = append(diags, moreDiags...)
+	if getter, ok := packerStarter.(packer.CombinedPostProcessorsGetter); ok {
+		_, moreDiags := getter.GetCombinedPostProcessors(getBuildsOptions)
+		diags = append(diags, moreDiags...)
+	}
+	diags = append(diags, packerStarter.FixConfig(packer.FixConfigOptions{
+		Mode: packer.Diff,
+	})...)
+	diags = append(diags, cla.unusedDeclarations(packerStarter)...)
+	return files, diags
+}
+
+// unusedDeclarations returns the warnings about the unused variables, locals
+// and data sources of an HCL2 config, errors with -strict.
+func (cla *ValidateArgs) unusedDeclarations(packerStarter packer.Handler) hcl.Diagnostics {
+	cfg, ok := packerStarter.(*hcl2template.PackerConfig)
+	if !ok {
+		return nil
+	}
+	diags := cfg.UnusedDeclarations()
+	if cla.Strict {
+		diags, _ = messages.WarningsAsErrors(diags)
+	}
+	return diags
+}
+
+func (cla *ValidateArgs) getBuildsOptions() packer.GetBuildsOptions {
+	return packer.GetBuildsOptions{
+		Only:            cla.Only,
+		Except:          cla.Except,
+		CheckLocalPaths: true,
+		SchemaOnly:      cla.SchemaOnly,
+	}
+}
+
+// errorDiags returns an error diagnostic without range, with a summary and an
+// optional detail.
+func errorDiags(summary string, detail ...string) hcl.Diagnostics {
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  summary,
+		Detail:   strings.Join(detail, "\n"),
+	}}
+}
+
+// validateOutput is the JSON document written by validate -output=json.
+type validateOutput struct {
+	Valid        bool             `json:"valid"`
+	ErrorCount   int              `json:"error_count"`
+	WarningCount int              `json:"warning_count"`
+	Diagnostics  []jsonDiagnostic `json:"diagnostics"`
+}
+
+// jsonDiagnostic is a diagnostic of validate -output=json. Range is not set
+// for the diagnostics that are not about a part of the config, like the
+// errors of JSON templates.
+type jsonDiagnostic struct {
+	Severity string     `json:"severity"`
+	Summary  string     `json:"summary"`
+	Detail   string     `json:"detail"`
+	Range    *jsonRange `json:"range,omitempty"`
+	// Snippet is the line of config of the start of Range.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+type jsonRange struct {
+	Filename string  `json:"filename"`
+	Start    jsonPos `json:"start"`
+	End      jsonPos `json:"end"`
+}
+
+// jsonPos is a position in a file. Line and Column start at 1, Byte at 0.
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+func newValidateOutput(files map[string]*hcl.File, diags hcl.Diagnostics) validateOutput {
+	out := validateOutput{
+		Valid:       !diags.HasErrors(),
+		Diagnostics: []jsonDiagnostic{},
+	}
+	for _, diag := range diags {
+		d := jsonDiagnostic{
+			Severity: "warning",
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+		if diag.Severity == hcl.DiagError {
+			d.Severity = "error"
+			out.ErrorCount++
+		} else {
+			out.WarningCount++
+		}
+		if rng := diag.Subject; rng != nil && rng.Filename != "" {
+			d.Range = &jsonRange{
+				Filename: rng.Filename,
+				Start:    jsonPos{rng.Start.Line, rng.Start.Column, rng.Start.Byte},
+				End:      jsonPos{rng.End.Line, rng.End.Column, rng.End.Byte},
+			}
+			if file := files[rng.Filename]; file != nil {
+				d.Snippet = snippet(file.Bytes, rng.Start.Line)
+			}
+		}
+		out.Diagnostics = append(out.Diagnostics, d)
+	}
+	return out
+}
+
+// snippet returns the line of src numbered line, starting at 1.
+func snippet(src []byte, line int) string {
+	lines := strings.Split(string(src), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
 }
 
 func (*ValidateCommand) Help() string {
@@ -91,13 +307,29 @@ Usage: packer validate [options] TEMPLATE
 Options:
 
   -syntax-only           Only check syntax. Do not verify config of the template.
+  -schema-only           Check the settings of the builders, provisioners and
+                         post-processors against their schema, without
+                         preparing them: no credentials or network are needed,
+                         but the values of the settings are not checked.
   -except=foo,bar,baz    Validate all builds other than these.
   -only=foo,bar,baz      Validate only these builds.
   -var 'key=value'       Variable for templates, can be used multiple times.
+                         A value of @FILE is read from FILE.
   -var-file=path         JSON or HCL2 file containing user variables.
+  -warn-as-error         Turn warnings into errors.
+  -fail-on-plugin-conflict
+                         Fail when a plugin is installed several times, like
+                         in the plugins folder and for required_plugins,
+                         instead of warning.
+  -strict                Report the variables, locals and data sources that
+                         are declared but never used as errors instead of
+                         warnings.
+  -output=text           Format of the errors and warnings: text or json. The
+                         json output has the file and range of each of them,
+                         for editors and CI systems.
 `
 
-	return strings.TrimSpace(helpText)
+	return strings.TrimSpace(helpText) + "\n\n" + exitCodesHelp
 }
 
 func (*ValidateCommand) Synopsis() string {
@@ -110,10 +342,15 @@ func (*ValidateCommand) AutocompleteArgs() complete.Predictor {
 
 func (*ValidateCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-syntax-only": complete.PredictNothing,
-		"-except":      complete.PredictNothing,
-		"-only":        complete.PredictNothing,
-		"-var":         complete.PredictNothing,
-		"-var-file":    complete.PredictNothing,
+		"-syntax-only":             complete.PredictNothing,
+		"-schema-only":             complete.PredictNothing,
+		"-except":                  complete.PredictNothing,
+		"-only":                    complete.PredictNothing,
+		"-var":                     complete.PredictNothing,
+		"-var-file":                complete.PredictNothing,
+		"-warn-as-error":           complete.PredictNothing,
+		"-fail-on-plugin-conflict": complete.PredictNothing,
+		"-strict":                  complete.PredictNothing,
+		"-output":                  complete.PredictSet("text", "json"),
 	}
 }
@@ -1,12 +1,16 @@
 package command
 
 import (
+	"encoding/json"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
 func TestValidateCommand(t *testing.T) {
@@ -17,20 +21,20 @@ func TestValidateCommand(t *testing.T) {
 		{path: filepath.Join(testFixture("validate"), "build.json")},
 		{path: filepath.Join(testFixture("validate"), "build.pkr.hcl")},
 		{path: filepath.Join(testFixture("validate"), "build_with_vars.pkr.hcl")},
-		{path: filepath.Join(testFixture("validate-invalid"), "bad_provisioner.json"), exitCode: 1},
-		{path: filepath.Join(testFixture("validate-invalid"), "missing_build_block.pkr.hcl"), exitCode: 1},
-		{path: filepath.Join(testFixture("validate"), "null_var.json"), exitCode: 1},
-		{path: filepath.Join(testFixture("validate"), "var_foo_with_no_default.pkr.hcl"), exitCode: 1},
+		{path: filepath.Join(testFixture("validate-invalid"), "bad_provisioner.json"), exitCode: ExitValidation},
+		{path: filepath.Join(testFixture("validate-invalid"), "missing_build_block.pkr.hcl"), exitCode: ExitValidation},
+		{path: filepath.Join(testFixture("validate"), "null_var.json"), exitCode: ExitValidation},
+		{path: filepath.Join(testFixture("validate"), "var_foo_with_no_default.pkr.hcl"), exitCode: ExitValidation},
 
 		// wrong version fails
-		{path: filepath.Join(testFixture("version_req", "base_failure")), exitCode: 1},
+		{path: filepath.Join(testFixture("version_req", "base_failure")), exitCode: ExitValidation},
 		{path: filepath.Join(testFixture("version_req", "base_success")), exitCode: 0},
 
 		// wrong version field
-		{path: filepath.Join(testFixture("version_req", "wrong_field_name")), exitCode: 1},
+		{path: filepath.Join(testFixture("version_req", "wrong_field_name")), exitCode: ExitValidation},
 
 		// wrong packer block
-		{path: filepath.Join(testFixture("validate", "invalid_packer_block.pkr.hcl")), exitCode: 1},
+		{path: filepath.Join(testFixture("validate", "invalid_packer_block.pkr.hcl")), exitCode: ExitValidation},
 	}
 
 	for _, tc := range tt {
@@ -80,7 +84,7 @@ func TestValidateCommand_SyntaxOnly(t *testing.T) {
 		{path: filepath.Join(testFixture("validate"), "build_with_vars.pkr.hcl")},
 		{path: filepath.Join(testFixture("validate-invalid"), "bad_provisioner.json")},
 		{path: filepath.Join(testFixture("validate-invalid"), "missing_build_block.pkr.hcl")},
-		{path: filepath.Join(testFixture("validate-invalid"), "broken.json"), exitCode: 1},
+		{path: filepath.Join(testFixture("validate-invalid"), "broken.json"), exitCode: ExitValidation},
 		{path: filepath.Join(testFixture("validate"), "null_var.json")},
 		{path: filepath.Join(testFixture("validate"), "var_foo_with_no_default.pkr.hcl")},
 	}
@@ -100,6 +104,81 @@ func TestValidateCommand_SyntaxOnly(t *testing.T) {
 	}
 }
 
+func TestValidateCommand_MissingLocalPaths(t *testing.T) {
+	for _, path := range []string{
+		testFixture("validate", "local_paths", "missing.pkr.hcl"),
+		testFixture("validate", "local_paths", "missing.json"),
+	} {
+		t.Run(path, func(t *testing.T) {
+			c := &ValidateCommand{
+				Meta: testMetaFile(t),
+			}
+			if code := c.Run([]string{path}); code != ExitValidation {
+				fatalCommand(t, c.Meta)
+			}
+
+			_, stderr := outputCommand(t, c.Meta)
+			stderr = strings.Join(strings.Fields(stderr), " ")
+			for _, missing := range []string{"missing.pem", "missing.sh"} {
+				if !strings.Contains(stderr, fmt.Sprintf("%s\", which does not exist", missing)) {
+					t.Errorf("expected %s to be reported as missing, got: %s", missing, stderr)
+				}
+			}
+			if strings.Contains(stderr, "hello.sh\", which does not exist") {
+				t.Errorf("existing hello.sh reported as missing: %s", stderr)
+			}
+		})
+	}
+}
+
+func TestValidateCommand_warnAsError(t *testing.T) {
+	path := testFixture("validate", "warnings")
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := c.Run([]string{path}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	c = &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := c.Run([]string{"-warn-as-error", path}); code != ExitValidation {
+		fatalCommand(t, c.Meta)
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "Override file applied") || !strings.Contains(stderr, string(messages.CodeOverrideApplied)) {
+		t.Errorf("expected the override warning as an error, got: %s", stderr)
+	}
+}
+
+func TestValidateCommand_strict(t *testing.T) {
+	path := testFixture("validate", "unused")
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := c.Run([]string{path}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	stdout, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(stdout, "Warning: Unused variable") || !strings.Contains(stdout, "var.unused is declared but never referenced.") {
+		t.Errorf("expected a warning about var.unused, got: %s", stdout)
+	}
+
+	c = &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	if code := c.Run([]string{"-strict", path}); code != ExitValidation {
+		fatalCommand(t, c.Meta)
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "Error: Unused variable") || strings.Contains(stderr, "var.target") {
+		t.Errorf("expected only var.unused to be an error, got: %s", stderr)
+	}
+}
+
 func TestValidateCommandOKVersion(t *testing.T) {
 	c := &ValidateCommand{
 		Meta: testMetaFile(t),
@@ -125,14 +204,15 @@ func TestValidateCommandBadVersion(t *testing.T) {
 
 	// This should fail with an invalid configuration version
 	c.CoreConfig.Version = "100.0.0"
-	if code := c.Run(args); code != 1 {
-		t.Errorf("Expected exit code 1")
+	if code := c.Run(args); code != ExitValidation {
+		t.Errorf("Expected exit code %d", ExitValidation)
 	}
 
 	stdout, stderr := outputCommand(t, c.Meta)
 	expected := `Error: 
 
 This template requires Packer version 101.0.0 or higher; using 100.0.0
+(PKR1002, see https://www.packer.io/docs/errors#pkr1002)
 
 
 `
@@ -162,7 +242,7 @@ func TestValidateCommandExcept(t *testing.T) {
 				"-except=chocolate,apple",
 				filepath.Join(testFixture("validate"), "validate_except.json"),
 			},
-			exitCode: 1,
+			exitCode: ExitValidation,
 		},
 		{
 			name: "HCL2: validate except build and post-processor",
@@ -177,7 +257,7 @@ func TestValidateCommandExcept(t *testing.T) {
 				"-except=file.chocolate,apple",
 				filepath.Join(testFixture("validate"), "validate_except.pkr.hcl"),
 			},
-			exitCode: 1,
+			exitCode: ExitValidation,
 		},
 	}
 
@@ -197,3 +277,127 @@ func TestValidateCommandExcept(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCommand_jsonOutput(t *testing.T) {
+	tc := []struct {
+		name     string
+		args     []string
+		exitCode int
+		expected validateOutput
+	}{
+		{
+			name:     "valid",
+			args:     []string{filepath.Join(testFixture("validate"), "build.pkr.hcl")},
+			expected: validateOutput{Valid: true, Diagnostics: []jsonDiagnostic{}},
+		},
+		{
+			name:     "hcl2 error",
+			args:     []string{filepath.Join(testFixture("validate-invalid"), "unsupported_argument.pkr.hcl")},
+			exitCode: ExitValidation,
+			expected: validateOutput{
+				ErrorCount: 1,
+				Diagnostics: []jsonDiagnostic{{
+					Severity: "error",
+					Summary:  "Unsupported argument",
+					Detail:   `An argument named "flavour" is not expected here.`,
+					Range: &jsonRange{
+						Filename: filepath.Join(testFixture("validate-invalid"), "unsupported_argument.pkr.hcl"),
+						Start:    jsonPos{Line: 4, Column: 3, Byte: 82},
+						End:      jsonPos{Line: 4, Column: 10, Byte: 89},
+					},
+					Snippet: `  flavour = "dark"`,
+				}},
+			},
+		},
+		{
+			name:     "json template error",
+			args:     []string{filepath.Join(testFixture("validate-invalid"), "broken.json")},
+			exitCode: ExitValidation,
+			expected: validateOutput{
+				ErrorCount: 1,
+				Diagnostics: []jsonDiagnostic{{
+					Severity: "error",
+					Summary:  "Failed to parse template",
+					Detail:   messages.CodeTemplateParse.Annotate("1 error(s) decoding:\n\n* 'Provisioners': source data must be an array or slice, got string"),
+				}},
+			},
+		},
+		{
+			name:     "warning as error",
+			args:     []string{"-warn-as-error", testFixture("validate", "warnings")},
+			exitCode: ExitValidation,
+			expected: validateOutput{
+				ErrorCount: 1,
+				Diagnostics: []jsonDiagnostic{{
+					Severity: "error",
+					Summary:  "Override file applied",
+					Detail:   messages.CodeOverrideApplied.Annotate(filepath.Join(testFixture("validate", "warnings"), "override.pkr.hcl") + " overrides source.file.chocolate."),
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ValidateCommand{
+				Meta: testMetaFile(t),
+			}
+			if code := c.Run(append([]string{"-output=json"}, tt.args...)); code != tt.exitCode {
+				fatalCommand(t, c.Meta)
+			}
+			stdout, _ := outputCommand(t, c.Meta)
+			var out validateOutput
+			if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+				t.Fatalf("invalid JSON output %q: %s", stdout, err)
+			}
+			if diff := cmp.Diff(tt.expected, out); diff != "" {
+				t.Errorf("unexpected output: %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateCommand_schemaOnly(t *testing.T) {
+	tc := []struct {
+		path               string
+		exitCode           int
+		schemaOnlyExitCode int
+	}{
+		{path: testFixture("validate", "schema_only", "template.pkr.hcl"), exitCode: ExitValidation},
+		{path: testFixture("validate", "schema_only", "template.json"), exitCode: ExitValidation},
+		{path: testFixture("validate", "schema_only", "unknown_setting.json"), exitCode: ExitValidation, schemaOnlyExitCode: ExitValidation},
+		{path: testFixture("validate-invalid", "unsupported_argument.pkr.hcl"), exitCode: ExitValidation, schemaOnlyExitCode: ExitValidation},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.path, func(t *testing.T) {
+			c := &ValidateCommand{
+				Meta: testMetaFile(t),
+			}
+			if code := c.Run([]string{tt.path}); code != tt.exitCode {
+				fatalCommand(t, c.Meta)
+			}
+
+			c = &ValidateCommand{
+				Meta: testMetaFile(t),
+			}
+			if code := c.Run([]string{"-schema-only", tt.path}); code != tt.schemaOnlyExitCode {
+				fatalCommand(t, c.Meta)
+			}
+		})
+	}
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	c.Run([]string{"-schema-only", testFixture("validate", "schema_only", "unknown_setting.json")})
+	_, stderr := outputCommand(t, c.Meta)
+	for _, expected := range []string{
+		`The file builder of build "file" has no "flavour" setting.`,
+		`The shell-local provisioner has no "flavour" setting.`,
+	} {
+		if !strings.Contains(stderr, expected) {
+			t.Errorf("expected %q in the output:\n%s", expected, stderr)
+		}
+	}
+}
@@ -26,6 +26,12 @@ func init() {
 			}, nil
 		},
 
+		"convert": func() (cli.Command, error) {
+			return &command.ConvertCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"fix": func() (cli.Command, error) {
 			return &command.FixCommand{
 				Meta: *CommandMeta,
@@ -38,6 +44,12 @@ func init() {
 			}, nil
 		},
 
+		"hcl2_downgrade": func() (cli.Command, error) {
+			return &command.HCL2DowngradeCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"hcl2_upgrade": func() (cli.Command, error) {
 			return &command.HCL2UpgradeCommand{
 				Meta: *CommandMeta,
@@ -56,6 +68,18 @@ func init() {
 			}, nil
 		},
 
+		"lint": func() (cli.Command, error) {
+			return &command.LintCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"plan": func() (cli.Command, error) {
+			return &command.PlanCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"plugin": func() (cli.Command, error) {
 			return &command.PluginCommand{
 				Meta: *CommandMeta,
@@ -26,6 +26,8 @@ type config struct {
 	RawBuilders                map[string]string `json:"builders"`
 	RawProvisioners            map[string]string `json:"provisioners"`
 	RawPostProcessors          map[string]string `json:"post-processors"`
+	// Ui is the name of the UI backend, "plain" by default.
+	Ui string `json:"ui"`
 
 	Plugins *packer.PluginConfig
 }
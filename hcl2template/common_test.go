@@ -329,6 +329,16 @@ var versionConstraintComparer = cmp.Comparer(func(x, y *version.Constraint) bool
 	return x.String() == y.String()
 })
 
+// basedirComparer compares the Basedir of configs once resolved, as the
+// parser makes it absolute.
+var basedirComparer = cmp.FilterPath(func(p cmp.Path) bool {
+	return p.Last().String() == ".Basedir"
+}, cmp.Comparer(func(x, y string) bool {
+	x, _ = resolvePath(x)
+	y, _ = resolvePath(y)
+	return x == y
+}))
+
 var cmpOpts = []cmp.Option{
 	ctyValueComparer,
 	ctyTypeComparer,
@@ -350,8 +360,10 @@ var cmpOpts = []cmp.Option{
 		null.Builder{},
 	),
 	cmpopts.IgnoreFields(PackerConfig{},
-		"Cwd", // Cwd will change for every os type
+		"Cwd",       // Cwd will change for every os type
+		"ConfigDir", // ConfigDir depends on the environment
 	),
+	basedirComparer,
 	cmpopts.IgnoreFields(VariableAssignment{},
 		"Expr", // its an interface
 	),
@@ -0,0 +1,576 @@
+package hcl2template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HCL2Converter converts configuration files between the native syntax of
+// HCL2 and its JSON syntax.
+//
+// Comments are lost during conversion.
+type HCL2Converter struct {
+	// PluginSpec returns the configuration spec of the plugin of type typ,
+	// kind is one of "source", "data", "provisioner" or "post-processor".
+	// When converting to the native syntax, it tells nested blocks from
+	// object attributes in the configuration of a plugin. Objects are written
+	// as attributes when PluginSpec is nil or returns nil.
+	PluginSpec func(kind, typ string) hcldec.ObjectSpec
+}
+
+// ToJSON converts a config file from the native syntax to the JSON syntax.
+func (c *HCL2Converter) ToJSON(filename string, src []byte) ([]byte, hcl.Diagnostics) {
+	src = escapeRawHeredocs(src, "")
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	obj := bodyToJSON(src, file.Body.(*hclsyntax.Body), "")
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(obj); err != nil {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to write JSON",
+			Detail:   err.Error(),
+		})
+	}
+	return buf.Bytes(), diags
+}
+
+// jsonObject is a JSON object that keeps the order of its keys.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]interface{}{}}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, found := o.values[key]; !found {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshalJSON(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal without escaping HTML characters, which are
+// common in boot commands.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func bodyToJSON(src []byte, body *hclsyntax.Body, blockType string) *jsonObject {
+	type item struct {
+		pos   int
+		attr  *hclsyntax.Attribute
+		block *hclsyntax.Block
+	}
+	var items []item
+	for _, attr := range body.Attributes {
+		items = append(items, item{pos: attr.SrcRange.Start.Byte, attr: attr})
+	}
+	blocksByType := map[string][]*hclsyntax.Block{}
+	for _, block := range body.Blocks {
+		if _, found := blocksByType[block.Type]; !found {
+			items = append(items, item{pos: block.TypeRange.Start.Byte, block: block})
+		}
+		blocksByType[block.Type] = append(blocksByType[block.Type], block)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].pos < items[j].pos })
+
+	obj := newJSONObject()
+	for _, item := range items {
+		if item.attr != nil {
+			obj.set(item.attr.Name, attributeToJSON(src, item.attr, blockType))
+			continue
+		}
+		blocks := blocksByType[item.block.Type]
+		if len(blocks) == 1 {
+			obj.set(item.block.Type, blockToJSON(src, blocks[0]))
+			continue
+		}
+		list := make([]interface{}, 0, len(blocks))
+		for _, block := range blocks {
+			list = append(list, blockToJSON(src, block))
+		}
+		obj.set(item.block.Type, list)
+	}
+	return obj
+}
+
+// blockToJSON returns the body of block, nested in an object per label.
+func blockToJSON(src []byte, block *hclsyntax.Block) interface{} {
+	var v interface{} = bodyToJSON(src, block.Body, block.Type)
+	for i := len(block.Labels) - 1; i >= 0; i-- {
+		labelled := newJSONObject()
+		labelled.set(block.Labels[i], v)
+		v = labelled
+	}
+	return v
+}
+
+func attributeToJSON(src []byte, attr *hclsyntax.Attribute, blockType string) interface{} {
+	switch {
+	case blockType == variableLabel && attr.Name == "type":
+		// type constraints are keywords and calls like list(string).
+		return sourceText(src, attr.Expr.Range())
+	case blockType == functionLabel && attr.Name == "params":
+		if tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok {
+			params := make([]interface{}, 0, len(tuple.Exprs))
+			for _, expr := range tuple.Exprs {
+				params = append(params, sourceText(src, expr.Range()))
+			}
+			return params
+		}
+	}
+	return exprToJSON(src, attr.Expr)
+}
+
+func exprToJSON(src []byte, expr hclsyntax.Expression) interface{} {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return ctyToJSON(e.Val)
+	case *hclsyntax.TemplateExpr:
+		var b strings.Builder
+		for _, part := range e.Parts {
+			if lit, ok := part.(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String {
+				b.WriteString(escapeTemplate(lit.Val.AsString()))
+				continue
+			}
+			b.WriteString(interpolation(sourceText(src, part.Range())))
+		}
+		return b.String()
+	case *hclsyntax.TemplateWrapExpr:
+		return interpolation(sourceText(src, e.Wrapped.Range()))
+	case *hclsyntax.TupleConsExpr:
+		list := make([]interface{}, 0, len(e.Exprs))
+		for _, expr := range e.Exprs {
+			list = append(list, exprToJSON(src, expr))
+		}
+		return list
+	case *hclsyntax.ObjectConsExpr:
+		obj := newJSONObject()
+		for _, item := range e.Items {
+			var key string
+			keyExpr, ok := item.KeyExpr.(*hclsyntax.ObjectConsKeyExpr)
+			switch {
+			case ok && !keyExpr.ForceNonLiteral && hcl.ExprAsKeyword(keyExpr.Wrapped) != "":
+				key = hcl.ExprAsKeyword(keyExpr.Wrapped)
+			case ok:
+				key = fmt.Sprint(exprToJSON(src, keyExpr.Wrapped))
+			default:
+				key = interpolation(sourceText(src, item.KeyExpr.Range()))
+			}
+			obj.set(key, exprToJSON(src, item.ValueExpr))
+		}
+		return obj
+	}
+	return interpolation(sourceText(src, expr.Range()))
+}
+
+func ctyToJSON(v cty.Value) interface{} {
+	switch {
+	case v.IsNull():
+		return nil
+	case v.Type() == cty.String:
+		return escapeTemplate(v.AsString())
+	case v.Type() == cty.Number:
+		return json.Number(v.AsBigFloat().Text('f', -1))
+	case v.Type() == cty.Bool:
+		return v.True()
+	}
+	return nil
+}
+
+// interpolation wraps expr in an interpolation sequence, unless it already is
+// a template directive.
+func interpolation(expr string) string {
+	if strings.HasPrefix(expr, "%{") {
+		return expr
+	}
+	return "${" + expr + "}"
+}
+
+// escapeTemplate escapes template sequences from a literal string.
+func escapeTemplate(s string) string {
+	s = strings.ReplaceAll(s, "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{")
+}
+
+func sourceText(src []byte, rng hcl.Range) string {
+	return string(rng.SliceBytes(src))
+}
+
+// convertSchema describes a block when converting to the native syntax, so
+// that nested blocks are not written as object attributes.
+type convertSchema struct {
+	labels int
+	blocks map[string]*convertSchema
+	// plugin is the kind of plugin configured by the block, the type of the
+	// plugin is the first label.
+	plugin string
+	// keywords are attributes written as is, like the type of a variable.
+	keywords map[string]bool
+}
+
+var rootConvertSchema = &convertSchema{
+	blocks: map[string]*convertSchema{
+		packerLabel: {blocks: map[string]*convertSchema{
+			"required_plugins": {},
+		}},
+		variableLabel: {
+			labels:   1,
+			blocks:   map[string]*convertSchema{"validation": {}},
+			keywords: map[string]bool{"type": true},
+		},
+		variablesLabel:    {},
+		localsLabel:       {},
+		localLabel:        {labels: 1},
+		sourceLabel:       {labels: 2, plugin: "source"},
+		dataSourceLabel:   {labels: 2, plugin: "data"},
+		communicatorLabel: {labels: 2},
+		functionLabel:     {labels: 1, keywords: map[string]bool{"params": true}},
+		imageLabel:        {labels: 1},
+		imageMappingLabel: {labels: 1, plugin: "source"},
+		postProcessorsLabel: {blocks: map[string]*convertSchema{
+			buildPostProcessorLabel: {labels: 1, plugin: "post-processor"},
+		}},
+		buildLabel: {blocks: map[string]*convertSchema{
+			buildSourceLabel:      {labels: 1, plugin: "source"},
+			buildProvisionerLabel: {labels: 1, plugin: "provisioner"},
+			buildCleanupLabel:     {},
+			buildReplicateLabel:   {},
+			buildRetryLabel:       {},
+			buildParallelLabel: {blocks: map[string]*convertSchema{
+				buildProvisionerLabel: {labels: 1, plugin: "provisioner"},
+			}},
+			buildPostProcessorLabel: {labels: 1, plugin: "post-processor"},
+			buildPostProcessorsLabel: {blocks: map[string]*convertSchema{
+				buildPostProcessorLabel: {labels: 1, plugin: "post-processor"},
+			}},
+		}},
+	},
+}
+
+// specConvertSchema returns the schema of a block configured by spec.
+func specConvertSchema(spec hcldec.ObjectSpec) *convertSchema {
+	schema := &convertSchema{blocks: map[string]*convertSchema{}}
+	for _, s := range spec {
+		var typeName string
+		var labels int
+		var nested hcldec.Spec
+		switch s := s.(type) {
+		case *hcldec.BlockSpec:
+			typeName, nested = s.TypeName, s.Nested
+		case *hcldec.BlockListSpec:
+			typeName, nested = s.TypeName, s.Nested
+		case *hcldec.BlockSetSpec:
+			typeName, nested = s.TypeName, s.Nested
+		case *hcldec.BlockTupleSpec:
+			typeName, nested = s.TypeName, s.Nested
+		case *hcldec.BlockMapSpec:
+			typeName, labels, nested = s.TypeName, len(s.LabelNames), s.Nested
+		case *hcldec.BlockObjectSpec:
+			typeName, labels, nested = s.TypeName, len(s.LabelNames), s.Nested
+		case *hcldec.BlockAttrsSpec:
+			typeName = s.TypeName
+		default:
+			continue
+		}
+		child := &convertSchema{}
+		switch nested := nested.(type) {
+		case hcldec.ObjectSpec:
+			child = specConvertSchema(nested)
+		case *hcldec.ObjectSpec:
+			child = specConvertSchema(*nested)
+		}
+		child.labels = labels
+		schema.blocks[typeName] = child
+	}
+	return schema
+}
+
+// ToHCL converts a config file from the JSON syntax to the native syntax.
+func (c *HCL2Converter) ToHCL(filename string, src []byte) ([]byte, hcl.Diagnostics) {
+	if _, diags := hcljson.Parse(src, filename); diags.HasErrors() {
+		return nil, diags
+	}
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	root, err := decodeOrderedJSON(dec)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read JSON",
+			Detail:   fmt.Sprintf("%s: %s", filename, err),
+		}}
+	}
+	obj, ok := root.(*jsonObject)
+	if !ok {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid JSON config",
+			Detail:   fmt.Sprintf("%s: the root of a config must be an object.", filename),
+		}}
+	}
+
+	var buf bytes.Buffer
+	c.writeBody(&buf, obj, rootConvertSchema)
+	return hclwrite.Format(buf.Bytes()), nil
+}
+
+// decodeOrderedJSON decodes the next JSON value of dec, objects are decoded
+// in a *jsonObject to keep the order of their keys.
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+func (c *HCL2Converter) writeBody(w io.Writer, obj *jsonObject, schema *convertSchema) {
+	for i, key := range obj.keys {
+		value := obj.values[key]
+		if child := schema.blocks[key]; child != nil && isBlockValue(value) {
+			if i > 0 {
+				fmt.Fprint(w, "\n")
+			}
+			c.writeBlocks(w, key, value, child, nil)
+			continue
+		}
+		if schema.keywords[key] {
+			fmt.Fprintf(w, "%s = %s\n", key, keywordToHCL(value))
+			continue
+		}
+		fmt.Fprintf(w, "%s = %s\n", key, valueToHCL(value))
+	}
+}
+
+func isBlockValue(value interface{}) bool {
+	switch value := value.(type) {
+	case *jsonObject:
+		return true
+	case []interface{}:
+		for _, v := range value {
+			if _, ok := v.(*jsonObject); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (c *HCL2Converter) writeBlocks(w io.Writer, typ string, value interface{}, schema *convertSchema, labels []string) {
+	if list, ok := value.([]interface{}); ok {
+		for i, v := range list {
+			if i > 0 {
+				fmt.Fprint(w, "\n")
+			}
+			c.writeBlocks(w, typ, v, schema, labels)
+		}
+		return
+	}
+	obj, ok := value.(*jsonObject)
+	if !ok {
+		return
+	}
+	if len(labels) < schema.labels {
+		for i, label := range obj.keys {
+			if i > 0 {
+				fmt.Fprint(w, "\n")
+			}
+			c.writeBlocks(w, typ, obj.values[label], schema, append(labels[:len(labels):len(labels)], label))
+		}
+		return
+	}
+
+	fmt.Fprint(w, typ)
+	for _, label := range labels {
+		fmt.Fprintf(w, " %q", label)
+	}
+	fmt.Fprint(w, " {\n")
+	c.writeBody(w, obj, c.bodySchema(schema, labels))
+	fmt.Fprint(w, "}\n")
+}
+
+// bodySchema returns the schema of the body of a block, adding the blocks of
+// the plugin it configures if any.
+func (c *HCL2Converter) bodySchema(schema *convertSchema, labels []string) *convertSchema {
+	if schema.plugin == "" || c.PluginSpec == nil || len(labels) == 0 {
+		return schema
+	}
+	// a source used in a build is referenced as "type.name"
+	typ := strings.SplitN(labels[0], ".", 2)[0]
+	spec := c.PluginSpec(schema.plugin, typ)
+	if spec == nil {
+		return schema
+	}
+	body := specConvertSchema(spec)
+	body.keywords = schema.keywords
+	for name, child := range schema.blocks {
+		body.blocks[name] = child
+	}
+	if schema.plugin == "source" {
+		body.blocks[sourceSchedulingLabel] = &convertSchema{}
+	}
+	return body
+}
+
+func keywordToHCL(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, v := range value {
+			items = append(items, keywordToHCL(v))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return valueToHCL(value)
+}
+
+func valueToHCL(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(value)
+	case json.Number:
+		return value.String()
+	case string:
+		return templateToHCL(value)
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, v := range value {
+			items = append(items, valueToHCL(v))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *jsonObject:
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, key := range value.keys {
+			name := key
+			if !hclsyntax.ValidIdentifier(key) {
+				name = templateToHCL(key)
+				if !strings.HasPrefix(name, `"`) {
+					// an expression key has to be in parentheses
+					name = "(" + name + ")"
+				}
+			}
+			fmt.Fprintf(&b, "%s = %s\n", name, valueToHCL(value.values[key]))
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	return "null"
+}
+
+// templateToHCL converts a JSON string, which is a template, to a native
+// expression.
+func templateToHCL(s string) string {
+	expr, diags := hclsyntax.ParseTemplate([]byte(s), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return quoteHCL(s)
+	}
+	src := []byte(s)
+	switch e := expr.(type) {
+	case *hclsyntax.TemplateWrapExpr:
+		// "${var.foo}" is var.foo
+		return sourceText(src, e.Wrapped.Range())
+	case *hclsyntax.TemplateExpr:
+		var b strings.Builder
+		b.WriteByte('"')
+		for _, part := range e.Parts {
+			if lit, ok := part.(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String {
+				b.WriteString(strings.Trim(quoteHCL(escapeTemplate(lit.Val.AsString())), `"`))
+				continue
+			}
+			b.WriteString(interpolation(sourceText(src, part.Range())))
+		}
+		b.WriteByte('"')
+		return b.String()
+	}
+	return quoteHCL(s)
+}
+
+// quoteHCL quotes a literal string for the native syntax.
+func quoteHCL(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
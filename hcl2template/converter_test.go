@@ -0,0 +1,50 @@
+package hcl2template
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	. "github.com/hashicorp/packer/hcl2template/internal"
+)
+
+func TestHCL2Converter(t *testing.T) {
+	converter := &HCL2Converter{
+		PluginSpec: func(kind, typ string) hcldec.ObjectSpec {
+			if kind == "source" && typ == "virtualbox-iso" {
+				return (&MockBuilder{}).ConfigSpec()
+			}
+			return nil
+		},
+	}
+
+	hclSrc, err := ioutil.ReadFile("testdata/convert/basic.pkr.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, err := ioutil.ReadFile("testdata/convert/basic.pkr.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHCL, err := ioutil.ReadFile("testdata/convert/basic.converted.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotJSON, diags := converter.ToJSON("basic.pkr.hcl", hclSrc)
+	if diags.HasErrors() {
+		t.Fatalf("ToJSON: %s", diags)
+	}
+	if diff := cmp.Diff(string(wantJSON), string(gotJSON)); diff != "" {
+		t.Errorf("ToJSON: unexpected output: %s", diff)
+	}
+
+	gotHCL, diags := converter.ToHCL("basic.pkr.json", wantJSON)
+	if diags.HasErrors() {
+		t.Fatalf("ToHCL: %s", diags)
+	}
+	if diff := cmp.Diff(string(wantHCL), string(gotHCL)); diff != "" {
+		t.Errorf("ToHCL: unexpected output: %s", diff)
+	}
+}
@@ -0,0 +1,735 @@
+package hcl2template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DowngradeToJSONTemplate converts an HCL2 config file to a legacy JSON
+// template, for tools that only read JSON templates.
+//
+// The conversion is lossy. Constructs that JSON templates cannot represent,
+// like data sources, most functions or the type of variables, are reported
+// as warnings; expressions that cannot be converted are kept as written, in
+// an interpolation sequence that the JSON template will not evaluate.
+func DowngradeToJSONTemplate(filename string, src []byte) ([]byte, hcl.Diagnostics) {
+	src = escapeRawHeredocs(src, "")
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	d := &downgrader{
+		src:         src,
+		localExprs:  map[string]hclsyntax.Expression{},
+		evalLocals:  map[string]bool{},
+		sources:     map[SourceRef]*hclsyntax.Block{},
+		usedNames:   map[string]bool{},
+		builderRefs: map[string]map[string]string{},
+	}
+	tpl := d.downgrade(file.Body.(*hclsyntax.Body))
+	diags = append(diags, d.diags...)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(tpl); err != nil {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to write JSON",
+			Detail:   err.Error(),
+		})
+	}
+	return buf.Bytes(), diags
+}
+
+// downgrader holds the state of a conversion to a JSON template.
+type downgrader struct {
+	src   []byte
+	diags hcl.Diagnostics
+
+	// localExprs are the expressions of the locals, by name. evalLocals are
+	// the locals being converted, to detect cycles.
+	localExprs map[string]hclsyntax.Expression
+	evalLocals map[string]bool
+
+	sources map[SourceRef]*hclsyntax.Block
+
+	// builders are the JSON builders, one per source of every build.
+	// usedNames are their names, which must be unique in JSON templates.
+	builders  []interface{}
+	usedNames map[string]bool
+	// builderRefs maps, for every build, the type.name of its sources and
+	// their name alone, used by overrides, to the names of their builders.
+	builderRefs map[string]map[string]string
+}
+
+// warn reports a construct that cannot be represented in a JSON template.
+func (d *downgrader) warn(rng hcl.Range, detail string) {
+	d.diags = append(d.diags, &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  "Cannot be represented in a JSON template",
+		Detail:   detail,
+		Subject:  rng.Ptr(),
+	})
+}
+
+// downgradeLabelCounts are the number of labels of the blocks read by the
+// downgrader.
+var downgradeLabelCounts = map[string]int{
+	variableLabel: 1,
+	localLabel:    1,
+	sourceLabel:   2,
+	buildLabel:    0,
+}
+
+// minVersionRe matches the required_version constraints that JSON templates
+// can represent with min_packer_version.
+var minVersionRe = regexp.MustCompile(`^\s*>=\s*v?(\d+(\.\d+)*)\s*$`)
+
+func (d *downgrader) downgrade(body *hclsyntax.Body) *jsonObject {
+	tpl := newJSONObject()
+	variables := newJSONObject()
+	var sensitive []interface{}
+	var builds []*hclsyntax.Block
+
+	for _, attr := range body.Attributes {
+		d.warn(attr.SrcRange, fmt.Sprintf("The %q attribute is not a part of JSON templates.", attr.Name))
+	}
+
+	// locals can be used before they are declared, they are collected first.
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case localsLabel:
+			for _, attr := range block.Body.Attributes {
+				d.localExprs[attr.Name] = attr.Expr
+			}
+		case localLabel:
+			if expr, found := block.Body.Attributes["expression"]; found && len(block.Labels) == 1 {
+				d.localExprs[block.Labels[0]] = expr.Expr
+			}
+		}
+	}
+
+	for _, block := range body.Blocks {
+		if n, found := downgradeLabelCounts[block.Type]; found && len(block.Labels) != n {
+			d.warn(block.DefRange(), fmt.Sprintf("A %s block must have %d labels, it is left out.", block.Type, n))
+			continue
+		}
+		switch block.Type {
+		case packerLabel:
+			d.downgradePackerBlock(tpl, block)
+		case variableLabel:
+			name := block.Labels[0]
+			variables.set(name, d.variableDefault(block))
+			if attr, found := block.Body.Attributes["sensitive"]; found {
+				if v, diags := attr.Expr.Value(nil); !diags.HasErrors() && v.Type() == cty.Bool && v.True() {
+					sensitive = append(sensitive, name)
+				}
+			}
+			for _, nested := range block.Body.Blocks {
+				d.warn(nested.DefRange(), fmt.Sprintf("JSON templates have no %s blocks in variables, the %s block of variable %q is left out.", nested.Type, nested.Type, name))
+			}
+		case variablesLabel:
+			for _, attr := range bodyAttributes(block.Body) {
+				variables.set(attr.Name, d.variableValue(attr.Expr))
+			}
+		case localsLabel, localLabel:
+			// locals are inlined where they are used.
+		case sourceLabel:
+			d.sources[SourceRef{Type: block.Labels[0], Name: block.Labels[1]}] = block
+		case buildLabel:
+			builds = append(builds, block)
+		default:
+			d.warn(block.DefRange(), fmt.Sprintf("JSON templates have no %s blocks, it is left out.", block.Type))
+		}
+	}
+
+	if len(builds) == 0 {
+		d.diags = append(d.diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No build block",
+			Detail:   "A JSON template runs all of its builders, so only the sources used by build blocks are converted to builders, and this config has no build block.",
+			Subject:  body.SrcRange.Ptr(),
+		})
+		return tpl
+	}
+
+	var descriptions []string
+	var provisioners, postProcessors []interface{}
+	for i, build := range builds {
+		buildID := fmt.Sprint(i)
+		if attr, found := build.Body.Attributes["description"]; found {
+			if s, ok := d.value(attr.Expr).(string); ok {
+				descriptions = append(descriptions, s)
+			}
+		}
+		d.downgradeBuildSources(buildID, build)
+
+		// The provisioners and post-processors of a JSON template run on
+		// all of its builders, the ones of a build only run on its sources.
+		var buildBuilders []string
+		if len(builds) > 1 {
+			for ref, name := range d.builderRefs[buildID] {
+				if strings.Contains(ref, ".") {
+					buildBuilders = append(buildBuilders, name)
+				}
+			}
+		}
+
+		var provisionerBlocks []*hclsyntax.Block
+		var cleanupBlock *hclsyntax.Block
+		for _, block := range build.Body.Blocks {
+			switch block.Type {
+			case buildProvisionerLabel:
+				provisionerBlocks = append(provisionerBlocks, block)
+			case buildParallelLabel:
+				d.warn(block.DefRange(), "JSON templates have no parallel blocks, its provisioners run one after the other.")
+				for _, nested := range block.Body.Blocks {
+					if nested.Type == buildProvisionerLabel {
+						provisionerBlocks = append(provisionerBlocks, nested)
+					}
+				}
+			case buildCleanupLabel:
+				cleanupBlock = block
+			case buildPostProcessorLabel:
+				if p := d.downgradePlugin(buildID, block, buildBuilders); p != nil {
+					postProcessors = append(postProcessors, p)
+				}
+			case buildPostProcessorsLabel:
+				var chain []interface{}
+				for _, nested := range block.Body.Blocks {
+					if p := d.downgradePlugin(buildID, nested, buildBuilders); p != nil {
+						chain = append(chain, p)
+					}
+				}
+				if len(chain) > 0 {
+					postProcessors = append(postProcessors, chain)
+				}
+			case sourceLabel:
+				// converted to builders above
+			default:
+				d.warn(block.DefRange(), fmt.Sprintf("JSON templates have no %s blocks in builds, it is left out.", block.Type))
+			}
+		}
+
+		for _, block := range d.orderProvisioners(provisionerBlocks) {
+			if p := d.downgradePlugin(buildID, block, buildBuilders); p != nil {
+				provisioners = append(provisioners, p)
+			}
+		}
+		if cleanupBlock != nil {
+			d.warn(cleanupBlock.DefRange(), "JSON templates have no cleanup blocks, it is converted to a shell provisioner running after the other provisioners, which does not run when a provisioner fails.")
+			cleanup := d.body(cleanupBlock.Body, nil)
+			obj := newJSONObject()
+			obj.set("type", cleanupProvisionerType)
+			for _, k := range cleanup.keys {
+				obj.set(k, cleanup.values[k])
+			}
+			if p := d.restrictToBuilders(obj, buildBuilders); p != nil {
+				provisioners = append(provisioners, p)
+			}
+		}
+	}
+
+	if len(descriptions) > 0 {
+		tpl.set("description", strings.Join(descriptions, "\n"))
+	}
+	if len(variables.keys) > 0 {
+		tpl.set("variables", variables)
+	}
+	if len(sensitive) > 0 {
+		tpl.set("sensitive-variables", sensitive)
+	}
+	tpl.set("builders", d.builders)
+	if len(provisioners) > 0 {
+		tpl.set("provisioners", provisioners)
+	}
+	if len(postProcessors) > 0 {
+		tpl.set("post-processors", postProcessors)
+	}
+	return tpl
+}
+
+func (d *downgrader) downgradePackerBlock(tpl *jsonObject, block *hclsyntax.Block) {
+	for _, attr := range bodyAttributes(block.Body) {
+		if attr.Name != "required_version" {
+			d.warn(attr.SrcRange, fmt.Sprintf("JSON templates have no %s setting, it is left out.", attr.Name))
+			continue
+		}
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || v.Type() != cty.String {
+			d.warn(attr.SrcRange, "The required_version of JSON templates must be a string, it is left out.")
+			continue
+		}
+		m := minVersionRe.FindStringSubmatch(v.AsString())
+		if m == nil {
+			d.warn(attr.SrcRange, fmt.Sprintf("JSON templates only support minimum versions, like \">= 1.6.0\", so required_version %q is left out.", v.AsString()))
+			continue
+		}
+		tpl.set("min_packer_version", m[1])
+	}
+	for _, nested := range block.Body.Blocks {
+		d.warn(nested.DefRange(), fmt.Sprintf("JSON templates have no %s blocks, it is left out: JSON templates use the plugins installed on the machine running Packer.", nested.Type))
+	}
+}
+
+// variableDefault returns the value of a variable of a JSON template: its
+// default, or nil when it has none, which makes it required.
+func (d *downgrader) variableDefault(block *hclsyntax.Block) interface{} {
+	attr, found := block.Body.Attributes["default"]
+	if !found {
+		return nil
+	}
+	return d.variableValue(attr.Expr)
+}
+
+// variableValue returns expr as a string, since the variables of JSON
+// templates are strings.
+func (d *downgrader) variableValue(expr hclsyntax.Expression) interface{} {
+	switch v := d.value(expr).(type) {
+	case nil, string:
+		return v
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		d.warn(expr.Range(), "The variables of JSON templates are strings, this default is written as a JSON string.")
+		b, _ := marshalJSON(v)
+		return string(b)
+	}
+}
+
+// downgradeBuildSources adds a builder for every source of build.
+func (d *downgrader) downgradeBuildSources(buildID string, build *hclsyntax.Block) {
+	refs := map[string]string{}
+	d.builderRefs[buildID] = refs
+
+	if attr, found := build.Body.Attributes["sources"]; found {
+		tuple, ok := attr.Expr.(*hclsyntax.TupleConsExpr)
+		if !ok {
+			d.warn(attr.SrcRange, "The sources of the build must be a list of source references, they are left out.")
+		}
+		for _, expr := range tupleExprs(tuple) {
+			var ref string
+			if v, diags := expr.Value(nil); !diags.HasErrors() && v.Type() == cty.String {
+				ref = v.AsString()
+			} else {
+				ref = sourceText(d.src, expr.Range())
+			}
+			d.addBuilder(refs, sourceRefFromString(ref), "", nil, expr.Range())
+		}
+	}
+
+	for _, block := range build.Body.Blocks {
+		if block.Type != sourceLabel || len(block.Labels) != 1 {
+			continue
+		}
+		name := ""
+		if attr, found := block.Body.Attributes["name"]; found {
+			if s, ok := d.value(attr.Expr).(string); ok {
+				name = s
+			}
+		}
+		d.addBuilder(refs, sourceRefFromString(block.Labels[0]), name, block.Body, block.DefRange())
+	}
+}
+
+// addBuilder adds the builder of the source ref, named localName when set,
+// with the settings of override, to the builders of a build.
+func (d *downgrader) addBuilder(refs map[string]string, ref SourceRef, localName string, override *hclsyntax.Body, rng hcl.Range) {
+	source, found := d.sources[ref]
+	if !found {
+		d.diags = append(d.diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unknown " + sourceLabel,
+			Detail:   fmt.Sprintf("There is no source %q in this file.", ref.String()),
+			Subject:  rng.Ptr(),
+		})
+		return
+	}
+	if localName == "" {
+		localName = ref.Name
+	}
+
+	// Builder names must be unique in a JSON template, a source used by
+	// several builds is run by as many builders.
+	name := localName
+	for i := 2; d.usedNames[name]; i++ {
+		name = fmt.Sprintf("%s_%d", localName, i)
+	}
+	d.usedNames[name] = true
+	refs[ref.Type+"."+localName] = name
+	refs[localName] = name
+
+	builder := newJSONObject()
+	builder.set("type", ref.Type)
+	builder.set("name", name)
+	config := d.body(source.Body, map[string]bool{sourceSchedulingLabel: true, sourceTimeoutsLabel: true})
+	if override != nil {
+		overrides := d.body(override, map[string]bool{"name": true, sourceSchedulingLabel: true, sourceTimeoutsLabel: true})
+		for _, k := range overrides.keys {
+			config.set(k, overrides.values[k])
+		}
+	}
+	for _, k := range config.keys {
+		builder.set(k, config.values[k])
+	}
+	d.builders = append(d.builders, builder)
+}
+
+// downgradePlugin converts a provisioner or post-processor block of a build.
+// buildBuilders, when set, are the builders the plugin is restricted to. It
+// returns nil when the plugin would not run on any builder.
+func (d *downgrader) downgradePlugin(buildID string, block *hclsyntax.Block, buildBuilders []string) interface{} {
+	if len(block.Labels) != 1 {
+		d.warn(block.DefRange(), fmt.Sprintf("A %s block must have a type label, it is left out.", block.Type))
+		return nil
+	}
+	refs := d.builderRefs[buildID]
+	obj := newJSONObject()
+	obj.set("type", block.Labels[0])
+	config := d.body(block.Body, nil)
+	for _, k := range config.keys {
+		v := config.values[k]
+		switch {
+		case block.Type == buildProvisionerLabel && (k == "name" || k == "after"):
+			// JSON provisioners have no name, and run in the order of the
+			// template.
+			continue
+		case k == "only" || k == "except":
+			v = d.builderNames(refs, v, block.Body.Attributes[k])
+		case k == "override":
+			if overrides, ok := v.(*jsonObject); ok {
+				renamed := newJSONObject()
+				for _, name := range overrides.keys {
+					builder, found := refs[name]
+					if !found {
+						builder = name
+					}
+					renamed.set(builder, overrides.values[name])
+				}
+				v = renamed
+			}
+		}
+		obj.set(k, v)
+	}
+	return d.restrictToBuilders(obj, buildBuilders)
+}
+
+// orderProvisioners sorts the provisioner blocks of a build like a build
+// does, following their after lists.
+func (d *downgrader) orderProvisioners(blocks []*hclsyntax.Block) []*hclsyntax.Block {
+	names := make([]string, len(blocks))
+	afters := make([][]string, len(blocks))
+	for i, block := range blocks {
+		if attr, found := block.Body.Attributes["name"]; found {
+			names[i], _ = d.value(attr.Expr).(string)
+		}
+		if attr, found := block.Body.Attributes["after"]; found {
+			list, _ := d.value(attr.Expr).([]interface{})
+			for _, item := range list {
+				if name, ok := item.(string); ok {
+					afters[i] = append(afters[i], name)
+				}
+			}
+		}
+	}
+	order, cycle := afterOrder(names, afters)
+	if len(cycle) > 0 {
+		d.diags = append(d.diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Provisioners depend on each other",
+			Detail:   "The provisioners of this build cannot be ordered, they run after each other through their after lists.",
+			Subject:  blocks[cycle[0]].DefRange().Ptr(),
+		})
+		return blocks
+	}
+	ordered := make([]*hclsyntax.Block, 0, len(blocks))
+	for _, i := range order {
+		ordered = append(ordered, blocks[i])
+	}
+	return ordered
+}
+
+// builderNames converts the sources of an only or except list to the names of
+// their builders.
+func (d *downgrader) builderNames(refs map[string]string, v interface{}, attr *hclsyntax.Attribute) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	names := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		s, _ := item.(string)
+		name, found := refs[s]
+		if !found {
+			d.warn(attr.SrcRange, fmt.Sprintf("%q is not a source of this build, it is left out.", s))
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// restrictToBuilders sets the only list of a provisioner or post-processor to
+// the builders it runs on, when the config has several builds.
+func (d *downgrader) restrictToBuilders(obj *jsonObject, buildBuilders []string) interface{} {
+	if len(buildBuilders) == 0 {
+		return obj
+	}
+	only, hasOnly := obj.values["only"].([]interface{})
+	except, _ := obj.values["except"].([]interface{})
+	sort.Strings(buildBuilders)
+	var names []interface{}
+	for _, name := range buildBuilders {
+		switch {
+		case hasOnly && !containsValue(only, name):
+		case containsValue(except, name):
+		default:
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	delete(obj.values, "except")
+	for i, k := range obj.keys {
+		if k == "except" {
+			obj.keys = append(obj.keys[:i], obj.keys[i+1:]...)
+			break
+		}
+	}
+	obj.set("only", names)
+	return obj
+}
+
+// body converts the attributes and nested blocks of body, but the ones of
+// skip. Nested blocks are converted to lists of objects.
+func (d *downgrader) body(body *hclsyntax.Body, skip map[string]bool) *jsonObject {
+	obj := newJSONObject()
+	for _, attr := range bodyAttributes(body) {
+		if skip[attr.Name] {
+			continue
+		}
+		obj.set(attr.Name, d.value(attr.Expr))
+	}
+	for _, block := range body.Blocks {
+		if skip[block.Type] {
+			d.warn(block.DefRange(), fmt.Sprintf("JSON templates have no %s blocks, it is left out.", block.Type))
+			continue
+		}
+		if block.Type == "dynamic" || len(block.Labels) > 0 {
+			d.warn(block.DefRange(), fmt.Sprintf("The %s block cannot be converted to a JSON value, it is left out.", block.Type))
+			continue
+		}
+		list, _ := obj.values[block.Type].([]interface{})
+		obj.set(block.Type, append(list, d.body(block.Body, nil)))
+	}
+	return obj
+}
+
+// value converts expr to a value of a JSON template.
+func (d *downgrader) value(expr hclsyntax.Expression) interface{} {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		if e.Val.IsNull() {
+			return nil
+		}
+		switch e.Val.Type() {
+		case cty.String:
+			return e.Val.AsString()
+		case cty.Number:
+			return json.Number(e.Val.AsBigFloat().Text('f', -1))
+		case cty.Bool:
+			return e.Val.True()
+		}
+	case *hclsyntax.TupleConsExpr:
+		list := make([]interface{}, 0, len(e.Exprs))
+		for _, expr := range e.Exprs {
+			list = append(list, d.value(expr))
+		}
+		return list
+	case *hclsyntax.ObjectConsExpr:
+		obj := newJSONObject()
+		for _, item := range e.Items {
+			key, ok := d.value(item.KeyExpr).(string)
+			if !ok {
+				d.warn(item.KeyExpr.Range(), "The keys of JSON objects must be strings, this item is left out.")
+				continue
+			}
+			obj.set(key, d.value(item.ValueExpr))
+		}
+		return obj
+	case *hclsyntax.ObjectConsKeyExpr:
+		if !e.ForceNonLiteral {
+			if kw := hcl.ExprAsKeyword(e.Wrapped); kw != "" {
+				return kw
+			}
+		}
+		return d.value(e.Wrapped)
+	case *hclsyntax.ScopeTraversalExpr:
+		if e.Traversal.RootName() == "local" {
+			if expr := d.local(e); expr != nil {
+				defer delete(d.evalLocals, localName(e))
+				return d.value(expr)
+			}
+		}
+	}
+	return d.template(expr)
+}
+
+// template converts expr to a string, where the values that are only known
+// at build time become Go template calls.
+func (d *downgrader) template(expr hclsyntax.Expression) string {
+	switch e := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		var b strings.Builder
+		for _, part := range e.Parts {
+			b.WriteString(d.template(part))
+		}
+		return b.String()
+	case *hclsyntax.TemplateWrapExpr:
+		return d.template(e.Wrapped)
+	case *hclsyntax.ScopeTraversalExpr:
+		if s, ok := d.traversalTemplate(e); ok {
+			return s
+		}
+	case *hclsyntax.FunctionCallExpr:
+		if s, ok := d.functionTemplate(e); ok {
+			return s
+		}
+	case *hclsyntax.LiteralValueExpr:
+		switch v := d.value(expr).(type) {
+		case string:
+			return v
+		case json.Number, bool:
+			return fmt.Sprint(v)
+		}
+	}
+	d.warn(expr.Range(), "This expression cannot be converted to a Go template, it is kept as written and will not be evaluated.")
+	return interpolation(sourceText(d.src, expr.Range()))
+}
+
+// traversalTemplate converts the references HCL2 and JSON templates have in
+// common.
+func (d *downgrader) traversalTemplate(e *hclsyntax.ScopeTraversalExpr) (string, bool) {
+	if len(e.Traversal) != 2 {
+		return "", false
+	}
+	attr, ok := e.Traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	switch root := e.Traversal.RootName(); {
+	case root == "var":
+		return fmt.Sprintf("{{ user `%s` }}", attr.Name), true
+	case root == "local":
+		expr := d.local(e)
+		if expr == nil {
+			return "", false
+		}
+		defer delete(d.evalLocals, attr.Name)
+		return d.template(expr), true
+	case root == "source" && attr.Name == "name":
+		return "{{ build_name }}", true
+	case root == "source" && attr.Name == "type":
+		return "{{ build_type }}", true
+	case root == "build":
+		return fmt.Sprintf("{{ build `%s` }}", attr.Name), true
+	case root == "path" && attr.Name == "root":
+		return "{{ template_dir }}", true
+	case root == "path" && attr.Name == "cwd":
+		return "{{ pwd }}", true
+	case root == "packer" && attr.Name == "version":
+		return "{{ packer_version }}", true
+	}
+	return "", false
+}
+
+// functionTemplate converts the functions that have an equivalent in JSON
+// templates.
+func (d *downgrader) functionTemplate(e *hclsyntax.FunctionCallExpr) (string, bool) {
+	var args []string
+	for _, arg := range e.Args {
+		v, diags := arg.Value(nil)
+		if diags.HasErrors() || v.Type() != cty.String {
+			return "", false
+		}
+		args = append(args, "`"+v.AsString()+"`")
+	}
+	switch {
+	case e.Name == "timestamp" && len(args) == 0:
+		return "{{ timestamp }}", true
+	case e.Name == "uuidv4" && len(args) == 0:
+		return "{{ uuid }}", true
+	case e.Name == "env" && len(args) == 1:
+		return fmt.Sprintf("{{ env %s }}", args[0]), true
+	case e.Name == "vault" && len(args) == 2:
+		return fmt.Sprintf("{{ vault %s %s }}", args[0], args[1]), true
+	case e.Name == "aws_secretsmanager" && len(args) == 2:
+		return fmt.Sprintf("{{ aws_secretsmanager %s %s }}", args[0], args[1]), true
+	}
+	return "", false
+}
+
+// local returns the expression of the local referenced by e, and marks it as
+// being converted; the caller unmarks it once converted. It returns nil when
+// the local does not exist or references itself.
+func (d *downgrader) local(e *hclsyntax.ScopeTraversalExpr) hclsyntax.Expression {
+	name := localName(e)
+	expr, found := d.localExprs[name]
+	if !found || d.evalLocals[name] {
+		return nil
+	}
+	d.evalLocals[name] = true
+	return expr
+}
+
+func localName(e *hclsyntax.ScopeTraversalExpr) string {
+	if len(e.Traversal) < 2 {
+		return ""
+	}
+	if attr, ok := e.Traversal[1].(hcl.TraverseAttr); ok {
+		return attr.Name
+	}
+	return ""
+}
+
+// bodyAttributes returns the attributes of body in the order of the file.
+func bodyAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+func tupleExprs(tuple *hclsyntax.TupleConsExpr) []hclsyntax.Expression {
+	if tuple == nil {
+		return nil
+	}
+	return tuple.Exprs
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
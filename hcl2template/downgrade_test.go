@@ -0,0 +1,75 @@
+package hcl2template
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDowngradeToJSONTemplate_severalBuilds(t *testing.T) {
+	src := []byte(`
+source "null" "example" {
+  communicator = "none"
+}
+
+build {
+  sources = ["source.null.example"]
+
+  provisioner "shell-local" {
+    inline = ["echo first"]
+  }
+}
+
+build {
+  source "null.example" {
+    communicator = "ssh"
+  }
+
+  provisioner "shell-local" {
+    inline   = ["echo second"]
+    override = {
+      example = { inline = ["echo overridden"] }
+    }
+  }
+}
+`)
+	out, diags := DowngradeToJSONTemplate("several.pkr.hcl", src)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags)
+	}
+
+	var tpl map[string]interface{}
+	if err := json.Unmarshal(out, &tpl); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, out)
+	}
+	expected := map[string]interface{}{
+		"builders": []interface{}{
+			map[string]interface{}{"type": "null", "name": "example", "communicator": "none"},
+			map[string]interface{}{"type": "null", "name": "example_2", "communicator": "ssh"},
+		},
+		"provisioners": []interface{}{
+			map[string]interface{}{
+				"type":   "shell-local",
+				"inline": []interface{}{"echo first"},
+				"only":   []interface{}{"example"},
+			},
+			map[string]interface{}{
+				"type":     "shell-local",
+				"inline":   []interface{}{"echo second"},
+				"override": map[string]interface{}{"example_2": map[string]interface{}{"inline": []interface{}{"echo overridden"}}},
+				"only":     []interface{}{"example_2"},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, tpl); diff != "" {
+		t.Errorf("unexpected template: %s", diff)
+	}
+}
+
+func TestDowngradeToJSONTemplate_noBuild(t *testing.T) {
+	_, diags := DowngradeToJSONTemplate("nobuild.pkr.hcl", []byte(`source "null" "example" {}`))
+	if !diags.HasErrors() {
+		t.Fatal("expected an error, a JSON template would run the sources not used by any build")
+	}
+}
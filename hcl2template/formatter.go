@@ -7,16 +7,27 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
 type HCL2Formatter struct {
 	ShowDiff, Write bool
-	Output          io.Writer
-	parser          *hclparse.Parser
+	// Recursive formats the files of the subdirectories of the path too,
+	// except the ones of hidden directories like .git.
+	Recursive bool
+	// SortAttributes sorts the attributes of every block by name. Attributes
+	// separated by a blank line or a nested block are sorted separately, so
+	// that groups of attributes are kept.
+	SortAttributes bool
+	Output         io.Writer
+	parser         *hclparse.Parser
 }
 
 // NewHCL2Formatter creates a new formatter, ready to format configuration files.
@@ -38,17 +49,33 @@ func (f *HCL2Formatter) Format(path string) (int, hcl.Diagnostics) {
 	if path == "-" {
 		allHclFiles = []string{"-"}
 	} else {
-		hclFiles, _, diags := GetHCL2Files(path, hcl2FileExt, hcl2JsonFileExt)
-		if diags.HasErrors() {
-			return 0, diags
+		dirs := []string{path}
+		if f.Recursive {
+			var err error
+			dirs, err = subdirectories(path)
+			if err != nil {
+				return 0, append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Cannot list the directories of %s", path),
+					Detail:   err.Error(),
+				})
+			}
 		}
 
-		hclVarFiles, _, diags := GetHCL2Files(path, hcl2VarFileExt, hcl2VarJsonFileExt)
-		if diags.HasErrors() {
-			return 0, diags
-		}
+		for _, dir := range dirs {
+			hclFiles, _, diags := GetHCL2Files(dir, hcl2FileExt, hcl2JsonFileExt)
+			if diags.HasErrors() {
+				return 0, diags
+			}
 
-		allHclFiles = append(hclFiles, hclVarFiles...)
+			hclVarFiles, _, diags := GetHCL2Files(dir, hcl2VarFileExt, hcl2VarJsonFileExt)
+			if diags.HasErrors() {
+				return 0, diags
+			}
+
+			allHclFiles = append(allHclFiles, hclFiles...)
+			allHclFiles = append(allHclFiles, hclVarFiles...)
+		}
 
 		if len(allHclFiles) == 0 {
 			diags = append(diags, &hcl.Diagnostic{
@@ -80,6 +107,10 @@ func (f *HCL2Formatter) Format(path string) (int, hcl.Diagnostics) {
 	return bytesModified, diags
 }
 
+// rawHeredocMarker prefixes the identifiers of the raw heredocs while
+// formatting.
+const rawHeredocMarker = "PACKER_RAW_HEREDOC_"
+
 // processFile formats the source contents of filename and return the formatted data.
 // overwriting the contents of the original when the f.Write is true; a diff of the changes
 // will be outputted if f.ShowDiff is true.
@@ -106,12 +137,21 @@ func (f *HCL2Formatter) processFile(filename string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read %s: %s", filename, err)
 	}
 
-	_, diags := f.parser.ParseHCL(inSrc, filename)
+	// raw heredocs are formatted as heredocs with a marked identifier
+	src := escapeRawHeredocs(inSrc, rawHeredocMarker)
+	_, diags := f.parser.ParseHCL(src, filename)
 	if diags.HasErrors() {
 		return nil, fmt.Errorf("failed to parse HCL %s", filename)
 	}
 
-	outSrc := hclwrite.Format(inSrc)
+	outSrc := src
+	if f.SortAttributes {
+		outSrc, diags = sortBodyAttributes(src, filename)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to sort the attributes of %s: %s", filename, diags)
+		}
+	}
+	outSrc = restoreRawHeredocs(hclwrite.Format(outSrc), rawHeredocMarker)
 
 	if bytes.Equal(inSrc, outSrc) {
 		return nil, nil
@@ -133,7 +173,7 @@ func (f *HCL2Formatter) processFile(filename string) ([]byte, error) {
 	}
 
 	if f.ShowDiff {
-		diff, err := bytesDiff(inSrc, outSrc, filename)
+		diff, err := BytesDiff(inSrc, outSrc, filename)
 		if err != nil {
 			return outSrc, fmt.Errorf("failed to generate diff for %s: %s", filename, err)
 		}
@@ -143,9 +183,143 @@ func (f *HCL2Formatter) processFile(filename string) ([]byte, error) {
 	return outSrc, nil
 }
 
-// bytesDiff returns the unified diff of b1 and b2
+// subdirectories returns path and its subdirectories, but the hidden ones.
+// When path is a file, it is returned alone.
+func subdirectories(path string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if p == path {
+				dirs = append(dirs, p)
+			}
+			return nil
+		}
+		if p != path && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	return dirs, err
+}
+
+// sortBodyAttributes sorts the attributes of the bodies of src by name. Only
+// the attributes written on consecutive lines are sorted together; blank
+// lines and nested blocks separate groups of attributes that are sorted
+// separately. Comments right above an attribute are moved with it.
+func sortBodyAttributes(src []byte, filename string) ([]byte, hcl.Diagnostics) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	out := append([]byte(nil), src...)
+	var walk func(body *hclsyntax.Body)
+	walk = func(body *hclsyntax.Body) {
+		attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+		for _, attr := range body.Attributes {
+			attrs = append(attrs, attr)
+		}
+		sort.Slice(attrs, func(i, j int) bool {
+			return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+		})
+
+		var group []attributeLines
+		for _, attr := range attrs {
+			lines, ok := wholeLines(src, attr.SrcRange)
+			if !ok {
+				sortAttributeLines(out, group)
+				group = nil
+				continue
+			}
+			if len(group) > 0 && group[len(group)-1].end != lines.start {
+				sortAttributeLines(out, group)
+				group = nil
+			}
+			group = append(group, lines)
+		}
+		sortAttributeLines(out, group)
+
+		for _, block := range body.Blocks {
+			walk(block.Body)
+		}
+	}
+	walk(file.Body.(*hclsyntax.Body))
+	return out, diags
+}
+
+// attributeLines are the lines of an attribute, from start to end, with the
+// final newline.
+type attributeLines struct {
+	name       string
+	start, end int
+}
+
+// wholeLines returns the lines of the attribute at rng, with the comments
+// right above it. The attribute must be the only thing written on its lines,
+// but for a trailing comment.
+func wholeLines(src []byte, rng hcl.Range) (attributeLines, bool) {
+	start := bytes.LastIndexByte(src[:rng.Start.Byte], '\n') + 1
+	if len(bytes.TrimSpace(src[start:rng.Start.Byte])) > 0 {
+		return attributeLines{}, false
+	}
+	// the comments right above the attribute are moved with it.
+	for start > 0 {
+		prev := bytes.LastIndexByte(src[:start-1], '\n') + 1
+		line := bytes.TrimSpace(src[prev:start])
+		if !bytes.HasPrefix(line, []byte("#")) && !bytes.HasPrefix(line, []byte("//")) {
+			break
+		}
+		start = prev
+	}
+	end := len(src)
+	if i := bytes.IndexByte(src[rng.End.Byte:], '\n'); i >= 0 {
+		end = rng.End.Byte + i + 1
+	}
+	rest := bytes.TrimSpace(src[rng.End.Byte:end])
+	if len(rest) > 0 && !bytes.HasPrefix(rest, []byte("#")) && !bytes.HasPrefix(rest, []byte("//")) {
+		return attributeLines{}, false
+	}
+	name := string(src[rng.Start.Byte:rng.End.Byte])
+	if i := strings.IndexAny(name, " \t="); i >= 0 {
+		name = name[:i]
+	}
+	return attributeLines{name: name, start: start, end: end}, true
+}
+
+// sortAttributeLines sorts, in out, the consecutive attribute lines of group.
+func sortAttributeLines(out []byte, group []attributeLines) {
+	if len(group) < 2 {
+		return
+	}
+	start, end := group[0].start, group[len(group)-1].end
+	chunks := make([][]byte, len(group))
+	for i, lines := range group {
+		chunks[i] = append([]byte(nil), out[lines.start:lines.end]...)
+		if !bytes.HasSuffix(chunks[i], []byte("\n")) {
+			// the last line of the file
+			chunks[i] = append(chunks[i], '\n')
+		}
+	}
+	sorted := make([]int, len(group))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return group[sorted[i]].name < group[sorted[j]].name })
+
+	var buf bytes.Buffer
+	for _, i := range sorted {
+		buf.Write(chunks[i])
+	}
+	copy(out[start:end], buf.Bytes()[:end-start])
+}
+
+// BytesDiff returns the unified diff of b1 and b2
 // Shamelessly copied from Terraform's fmt command.
-func bytesDiff(b1, b2 []byte, path string) (data []byte, err error) {
+func BytesDiff(b1, b2 []byte, path string) (data []byte, err error) {
 	f1, err := ioutil.TempFile("", "")
 	if err != nil {
 		return
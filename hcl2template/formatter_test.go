@@ -20,6 +20,7 @@ func TestHCL2Formatter_Format(t *testing.T) {
 		{Name: "Unformatted file", Path: "testdata/format/unformatted.pkr.hcl", FormatExpected: true},
 		{Name: "Unformatted vars file", Path: "testdata/format/unformatted.pkrvars.hcl", FormatExpected: true},
 		{Name: "Formatted file", Path: "testdata/format/formatted.pkr.hcl"},
+		{Name: "Formatted file with raw heredocs", Path: "testdata/format/raw_heredoc.pkr.hcl"},
 		{Name: "Directory", Path: "testdata/format", FormatExpected: true},
 	}
 
@@ -109,3 +110,74 @@ func TestHCL2Formatter_Format_ShowDiff(t *testing.T) {
 	}
 
 }
+
+func Test_sortBodyAttributes(t *testing.T) {
+	tc := []struct {
+		name, src, expected string
+	}{
+		{
+			name: "consecutive attributes",
+			src: `source "null" "example" {
+  zeta = 1
+  alpha = [
+    "a",
+  ]
+}
+`,
+			expected: `source "null" "example" {
+  alpha = [
+    "a",
+  ]
+  zeta = 1
+}
+`,
+		},
+		{
+			name: "groups and comments",
+			src: `variable "v" {
+  type = string
+  default = "x" # trailing
+
+  // about sensitive
+  sensitive = true
+  # the description
+  description = "d"
+  validation {
+    error_message = "e"
+    condition = true
+  }
+}
+`,
+			expected: `variable "v" {
+  default = "x" # trailing
+  type = string
+
+  # the description
+  description = "d"
+  // about sensitive
+  sensitive = true
+  validation {
+    condition = true
+    error_message = "e"
+  }
+}
+`,
+		},
+		{
+			name:     "last line without newline",
+			src:      "b = 1\na = 2",
+			expected: "a = 2\nb = 1",
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			out, diags := sortBodyAttributes([]byte(tt.src), "test.pkr.hcl")
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if diff := cmp.Diff(tt.expected, string(out)); diff != "" {
+				t.Errorf("unexpected output: %s", diff)
+			}
+		})
+	}
+}
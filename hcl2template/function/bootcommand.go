@@ -0,0 +1,139 @@
+package function
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// bootCommandLibrarySchema is the schema of a boot command library file:
+//
+//  boot_command "ubuntu-autoinstall" {
+//    defaults = {
+//      wait = "<wait5>"
+//    }
+//    keys = [
+//      "<esc>${wait}",
+//      "linux /casper/vmlinuz autoinstall ds=nocloud-net;s=http://${http_ip}:${http_port}/<enter>",
+//    ]
+//  }
+var bootCommandLibrarySchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "boot_command", LabelNames: []string{"name"}},
+	},
+}
+
+var bootCommandSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "keys", Required: true},
+		{Name: "defaults"},
+	},
+}
+
+// MakeBootCommandFunc constructs a function that reads a named boot command
+// from a library file and returns its keys, with the parameters substituted.
+//
+// basedir is used to resolve a relative library path.
+func MakeBootCommandFunc(basedir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+			{
+				Name: "name",
+				Type: cty.String,
+			},
+		},
+		VarParam: &function.Parameter{
+			Name: "params",
+			Type: cty.DynamicPseudoType,
+		},
+		Type: function.StaticReturnType(cty.List(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			name := args[1].AsString()
+			params := cty.EmptyObjectVal
+			switch len(args) {
+			case 2:
+			case 3:
+				params = args[2]
+				if !params.Type().IsObjectType() && !params.Type().IsMapType() {
+					return cty.NilVal, function.NewArgErrorf(2, "params must be an object")
+				}
+				if !params.IsWhollyKnown() {
+					return cty.UnknownVal(retType), nil
+				}
+			default:
+				return cty.NilVal, fmt.Errorf("bootcommand takes at most one params object")
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(basedir, path)
+			}
+			return bootCommand(path, name, params)
+		},
+	})
+}
+
+func bootCommand(path, name string, params cty.Value) (cty.Value, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("failed to read boot command library: %s", err)
+	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	content, diags := file.Body.Content(bootCommandLibrarySchema)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	for _, block := range content.Blocks {
+		if block.Labels[0] != name {
+			continue
+		}
+		attrs, diags := block.Body.Content(bootCommandSchema)
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+
+		vars := map[string]cty.Value{}
+		if attr, ok := attrs.Attributes["defaults"]; ok {
+			defaults, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+			if !defaults.Type().IsObjectType() && !defaults.Type().IsMapType() {
+				return cty.NilVal, fmt.Errorf("%s: defaults of boot command %q must be an object", attr.Range, name)
+			}
+			for k, v := range defaults.AsValueMap() {
+				vars[k] = v
+			}
+		}
+		for k, v := range params.AsValueMap() {
+			vars[k] = v
+		}
+
+		keys, diags := attrs.Attributes["keys"].Expr.Value(&hcl.EvalContext{
+			Variables: vars,
+		})
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+		keys, err := convert.Convert(keys, cty.List(cty.String))
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("%s: keys of boot command %q must be a list of strings: %s",
+				attrs.Attributes["keys"].Range, name, err)
+		}
+		return keys, nil
+	}
+	return cty.NilVal, fmt.Errorf("boot command %q not found in %s", name, path)
+}
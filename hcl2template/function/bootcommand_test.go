@@ -0,0 +1,79 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestBootCommand(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Params  []cty.Value
+		Want    cty.Value
+		WantErr bool
+	}{
+		{
+			"ubuntu-autoinstall",
+			[]cty.Value{cty.ObjectVal(map[string]cty.Value{
+				"http_ip":   cty.StringVal("10.0.2.2"),
+				"http_port": cty.NumberIntVal(8080),
+			})},
+			cty.ListVal([]cty.Value{
+				cty.StringVal("<esc><wait5>"),
+				cty.StringVal("linux /casper/vmlinuz autoinstall ds=nocloud-net;s=http://10.0.2.2:8080/<enter>"),
+			}),
+			false,
+		},
+		{
+			"ubuntu-autoinstall",
+			[]cty.Value{cty.ObjectVal(map[string]cty.Value{
+				"http_ip":   cty.StringVal("10.0.2.2"),
+				"http_port": cty.StringVal("8080"),
+				"wait":      cty.StringVal("<wait10>"),
+			})},
+			cty.ListVal([]cty.Value{
+				cty.StringVal("<esc><wait10>"),
+				cty.StringVal("linux /casper/vmlinuz autoinstall ds=nocloud-net;s=http://10.0.2.2:8080/<enter>"),
+			}),
+			false,
+		},
+		{
+			// missing parameters
+			"centos-kickstart",
+			nil,
+			cty.NilVal,
+			true,
+		},
+		{
+			"unknown",
+			[]cty.Value{cty.EmptyObjectVal},
+			cty.NilVal,
+			true,
+		},
+	}
+
+	bootCommand := MakeBootCommandFunc("testdata")
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			args := append([]cty.Value{
+				cty.StringVal("boot_commands.hcl"),
+				cty.StringVal(test.Name),
+			}, test.Params...)
+			got, err := bootCommand.Call(args)
+
+			if test.WantErr {
+				if err == nil {
+					t.Fatalf("succeeded; want error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !got.RawEquals(test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+boot_command "ubuntu-autoinstall" {
+  defaults = {
+    wait = "<wait5>"
+  }
+  keys = [
+    "<esc>${wait}",
+    "linux /casper/vmlinuz autoinstall ds=nocloud-net;s=http://${http_ip}:${http_port}/<enter>",
+  ]
+}
+
+boot_command "centos-kickstart" {
+  keys = ["<tab> text ks=http://${http_ip}:${http_port}/ks.cfg<enter><wait>"]
+}
@@ -35,6 +35,7 @@ func Functions(basedir string) map[string]function.Function {
 		"base64decode":       encoding.Base64DecodeFunc,
 		"base64encode":       encoding.Base64EncodeFunc,
 		"bcrypt":             crypto.BcryptFunc,
+		"bootcommand":        pkrfunction.MakeBootCommandFunc(basedir),
 		"can":                tryfunc.CanFunc,
 		"ceil":               stdlib.CeilFunc,
 		"chomp":              stdlib.ChompFunc,
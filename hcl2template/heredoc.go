@@ -0,0 +1,339 @@
+package hcl2template
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// A raw heredoc is a heredoc of which the identifier is quoted, like in
+// shells:
+//
+//	user_data = <<'EOF'
+//	echo ${HOME:-/root}
+//	EOF
+//
+// Its contents are taken literally: `${` and `%{` do not start template
+// sequences. HCL does not know raw heredocs: before parsing, they are blanked
+// out into plain heredocs of the same length, for the positions of the
+// diagnostics to stay right, which are then replaced by their contents in the
+// parsed file.
+
+// ParseHCLFile parses the HCL file filename, like hclparse does, but with its
+// raw heredocs.
+func (p *Parser) ParseHCLFile(filename string) (*hcl.File, hcl.Diagnostics) {
+	if f := p.Files()[filename]; f != nil {
+		return f, nil
+	}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   fmt.Sprintf("The configuration file %q could not be read: %s", filename, err),
+		}}
+	}
+	return p.ParseHCL(src, filename)
+}
+
+// ParseHCL parses src, the HCL config named filename, with its raw heredocs.
+func (p *Parser) ParseHCL(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	var raws []heredoc
+	for _, h := range findHeredocs(src) {
+		if h.raw {
+			raws = append(raws, h)
+		}
+	}
+	if len(raws) == 0 {
+		return p.Parser.ParseHCL(src, filename)
+	}
+
+	blanked := append([]byte(nil), src...)
+	for _, h := range raws {
+		blankRawHeredoc(blanked, h)
+	}
+	f, diags := p.Parser.ParseHCL(blanked, filename)
+	if f == nil {
+		return f, diags
+	}
+	// The snippets of the diagnostics are read from the original source.
+	f.Bytes = src
+	if body, ok := f.Body.(*hclsyntax.Body); ok {
+		values := map[int]heredoc{}
+		for _, h := range raws {
+			// blankRawHeredoc moved the start of the heredoc past the quotes
+			values[h.start+2] = h
+		}
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			replaceExpressions(reflect.ValueOf(node), func(expr hclsyntax.Expression) hclsyntax.Expression {
+				tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+				if !ok {
+					return expr
+				}
+				h, ok := values[tmpl.SrcRange.Start.Byte]
+				if !ok {
+					return expr
+				}
+				rng := tmpl.SrcRange
+				rng.Start.Byte -= 2
+				rng.Start.Column -= 2
+				return &hclsyntax.LiteralValueExpr{
+					Val:      cty.StringVal(string(h.value())),
+					SrcRange: rng,
+				}
+			})
+			return nil
+		})
+	}
+	return f, diags
+}
+
+// blankRawHeredoc turns the raw heredoc h of src into a plain heredoc of the
+// same length, and the same lines, of which the body is made of spaces:
+// `<<'EOF'` becomes `  <<EOF`.
+func blankRawHeredoc(src []byte, h heredoc) {
+	start := h.start
+	copy(src[start:], "  <<"+h.dash+h.id)
+	for i := h.bodyStart; i < h.bodyEnd; i++ {
+		if src[i] != '\n' {
+			src[i] = ' '
+		}
+	}
+}
+
+// exprType is the type of the hclsyntax.Expression fields of the nodes.
+var exprType = reflect.TypeOf((*hclsyntax.Expression)(nil)).Elem()
+
+// replaceExpressions replaces the expressions held by v, a node or a part of
+// it, with the results of f. Nodes hold expressions in fields and slices of
+// many types, they are found by reflection not to miss any.
+func replaceExpressions(v reflect.Value, f func(hclsyntax.Expression) hclsyntax.Expression) {
+	switch {
+	case v.Type() == exprType:
+		if !v.IsNil() && v.CanSet() {
+			v.Set(reflect.ValueOf(f(v.Interface().(hclsyntax.Expression))))
+		}
+	case v.Kind() == reflect.Ptr:
+		if !v.IsNil() {
+			replaceExpressions(v.Elem(), f)
+		}
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.CanSet() && (field.Type() == exprType || field.Kind() == reflect.Slice) {
+				replaceExpressions(field, f)
+			}
+		}
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if elem := v.Index(i); elem.Type() == exprType || elem.Kind() == reflect.Struct {
+				replaceExpressions(elem, f)
+			}
+		}
+	}
+}
+
+// heredoc is a heredoc of an HCL config.
+type heredoc struct {
+	// dash is "-" for the heredocs of which the body is unindented.
+	dash string
+	id   string
+	// raw tells whether the identifier is quoted.
+	raw bool
+	// newline ends the line starting the heredoc.
+	newline string
+	// indent is the indentation of the identifier ending the heredoc.
+	indent string
+	body   []byte
+
+	// start, bodyStart, bodyEnd and end are the offsets of the heredoc in
+	// the source it was found in.
+	start, bodyStart, bodyEnd, end int
+}
+
+// value returns the string the raw heredoc h is read as: its body, unindented
+// like HCL unindents the `<<-` heredocs.
+func (h heredoc) value() []byte {
+	if h.dash == "" {
+		return h.body
+	}
+	lines := bytes.SplitAfter(h.body, []byte("\n"))
+	minIndent := -1
+	for _, line := range lines {
+		content := bytes.TrimLeftFunc(line, unicode.IsSpace)
+		if len(content) == 0 {
+			// blank lines do not count
+			continue
+		}
+		indent := utf8.RuneCount(line[:len(line)-len(content)])
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+	var out bytes.Buffer
+	for _, line := range lines {
+		if len(bytes.TrimLeftFunc(line, unicode.IsSpace)) > 0 {
+			for n := 0; n < minIndent; n++ {
+				_, size := utf8.DecodeRune(line)
+				line = line[size:]
+			}
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+// heredocStartRe matches the start of a heredoc, with the newline ending it.
+var heredocStartRe = regexp.MustCompile(`\A<<(-?)('?)([\pL_][\pL\pN_-]*)('?)(\r?\n)`)
+
+// findHeredocs returns the heredocs of src. It reads src with the HCL lexer,
+// for the heredocs to be told apart from the strings, templates and comments
+// holding `<<`. Raw heredocs are read as two `<` by the lexer: their end is
+// found here, and the lexer is started again after them. The raw heredocs of
+// which the end cannot be found are left out for the parser to report.
+func findHeredocs(src []byte) []heredoc {
+	var heredocs []heredoc
+	tokens, _ := hclsyntax.LexConfig(src, "", hcl.Pos{Line: 1, Column: 1})
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.Type {
+		case hclsyntax.TokenOHeredoc:
+			m := heredocStartRe.FindSubmatch(tok.Bytes)
+			if m == nil {
+				continue
+			}
+			h := heredoc{
+				dash:      string(m[1]),
+				id:        string(m[3]),
+				newline:   string(m[5]),
+				start:     tok.Range.Start.Byte,
+				bodyStart: tok.Range.End.Byte,
+			}
+			for i++; i < len(tokens) && tokens[i].Type != hclsyntax.TokenCHeredoc; i++ {
+			}
+			if i == len(tokens) {
+				return heredocs
+			}
+			end := tokens[i]
+			h.bodyEnd = end.Range.Start.Byte
+			h.end = end.Range.End.Byte
+			h.indent = strings.TrimSuffix(string(end.Bytes), h.id)
+			h.body = src[h.bodyStart:h.bodyEnd]
+			heredocs = append(heredocs, h)
+		case hclsyntax.TokenLessThan:
+			start := tok.Range.Start.Byte
+			m := heredocStartRe.FindSubmatchIndex(src[start:])
+			if m == nil || m[5] == m[4] || m[9] == m[8] {
+				continue
+			}
+			h := heredoc{
+				dash:      string(src[start+m[2] : start+m[3]]),
+				id:        string(src[start+m[6] : start+m[7]]),
+				raw:       true,
+				newline:   string(src[start+m[10] : start+m[11]]),
+				start:     start,
+				bodyStart: start + m[1],
+			}
+			if !h.findEnd(src) {
+				continue
+			}
+			heredocs = append(heredocs, h)
+			// The lexer read the body as HCL, it is lexed again after it. Only
+			// the offsets of the tokens are used.
+			tokens, _ = hclsyntax.LexConfig(src[h.end:], "", hcl.Pos{Byte: h.end, Line: 1, Column: 1})
+			i = -1
+		}
+	}
+	return heredocs
+}
+
+// findEnd finds the end of the body of the raw heredoc h, started in src: the
+// line of its identifier alone.
+func (h *heredoc) findEnd(src []byte) bool {
+	for line := h.bodyStart; line < len(src); {
+		next := bytes.IndexByte(src[line:], '\n')
+		if next < 0 {
+			next = len(src)
+		} else {
+			next += line + 1
+		}
+		content := strings.TrimRight(string(src[line:next]), "\r\n")
+		if strings.TrimLeft(content, " \t") == h.id {
+			h.indent = content[:len(content)-len(h.id)]
+			h.bodyEnd = line
+			h.end = line + len(content)
+			h.body = src[h.bodyStart:h.bodyEnd]
+			return true
+		}
+		line = next
+	}
+	return false
+}
+
+// mapHeredocs returns src with every heredoc replaced by the result of f.
+func mapHeredocs(src []byte, f func(h heredoc) heredoc) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, h := range findHeredocs(src) {
+		out.Write(src[last:h.start])
+		h = f(h)
+		out.WriteString("<<" + h.dash)
+		if h.raw {
+			out.WriteString("'" + h.id + "'")
+		} else {
+			out.WriteString(h.id)
+		}
+		out.WriteString(h.newline)
+		out.Write(h.body)
+		out.WriteString(h.indent + h.id)
+		last = h.end
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// escapeRawHeredocs turns the raw heredocs of src into heredocs of which the
+// template sequences are escaped, with their identifier prefixed with prefix.
+func escapeRawHeredocs(src []byte, prefix string) []byte {
+	if !bytes.Contains(src, []byte("<<'")) && !bytes.Contains(src, []byte("<<-'")) {
+		return src
+	}
+	return mapHeredocs(src, func(h heredoc) heredoc {
+		if h.raw {
+			h.raw = false
+			h.id = prefix + h.id
+			h.body = escapeTemplateSequences(h.body)
+		}
+		return h
+	})
+}
+
+// restoreRawHeredocs turns back the heredocs escaped by escapeRawHeredocs
+// with prefix into raw heredocs.
+func restoreRawHeredocs(src []byte, prefix string) []byte {
+	return mapHeredocs(src, func(h heredoc) heredoc {
+		if !h.raw && strings.HasPrefix(h.id, prefix) {
+			h.raw = true
+			h.id = strings.TrimPrefix(h.id, prefix)
+			h.body = bytes.ReplaceAll(bytes.ReplaceAll(h.body, []byte("$${"), []byte("${")), []byte("%%{"), []byte("%{"))
+		}
+		return h
+	})
+}
+
+// escapeTemplateSequences escapes the template sequences of s, for it to be
+// the literal contents of a heredoc. Already escaped sequences are escaped
+// again: `$${` becomes `$$${`, which is read as `$${`.
+func escapeTemplateSequences(s []byte) []byte {
+	s = bytes.ReplaceAll(s, []byte("${"), []byte("$${"))
+	return bytes.ReplaceAll(s, []byte("%{"), []byte("%%{"))
+}
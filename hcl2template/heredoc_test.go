@@ -0,0 +1,98 @@
+package hcl2template
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEscapeRawHeredocs(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"raw heredoc",
+			"a = <<'EOF'\n${b} %{c} $${d}\nEOF\n",
+			"a = <<EOF\n$${b} %%{c} $$${d}\nEOF\n",
+		},
+		{
+			"indented raw heredoc",
+			"a = <<-'EOF'\r\n  ${b}\r\n  EOF\r\n",
+			"a = <<-EOF\r\n  $${b}\r\n  EOF\r\n",
+		},
+		{
+			"heredoc",
+			"a = <<EOF\n${b} <<'EOF'\nEOF\n",
+			"a = <<EOF\n${b} <<'EOF'\nEOF\n",
+		},
+		{
+			"string and comments",
+			"a = \"\\\"<<'EOF'\" # <<'EOF'\n/* <<'EOF'\n*/ // <<'EOF'\n",
+			"a = \"\\\"<<'EOF'\" # <<'EOF'\n/* <<'EOF'\n*/ // <<'EOF'\n",
+		},
+		{
+			"quotes in template",
+			"a = \"${fileset(path.root, \"scripts/*\")}\"\nb = <<'EOF'\n${c}\nEOF\n/* <<'EOF' */\n",
+			"a = \"${fileset(path.root, \"scripts/*\")}\"\nb = <<EOF\n$${c}\nEOF\n/* <<'EOF' */\n",
+		},
+		{
+			"unterminated raw heredoc",
+			"a = <<'EOF'\n${b}\n",
+			"a = <<'EOF'\n${b}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeRawHeredocs([]byte(tt.src), "")
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("unexpected escaped source: %s", diff)
+			}
+			back := restoreRawHeredocs(escapeRawHeredocs([]byte(tt.src), rawHeredocMarker), rawHeredocMarker)
+			if diff := cmp.Diff(tt.src, string(back)); diff != "" {
+				t.Errorf("the source was not restored: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParser_rawHeredoc(t *testing.T) {
+	src := "a = <<'EOF'\necho ${HOME:-/root} %{ if\nEOF\n" +
+		"b = [\"${c}\", <<-'EOF'\n    echo \"${PATH}\"\n\n      exit\n    EOF\n]\n" +
+		"c = d e\n"
+	p := &Parser{Parser: hclparse.NewParser()}
+	f, diags := p.ParseHCL([]byte(src), "raw.pkr.hcl")
+	if len(diags) != 1 {
+		t.Fatalf("ParseHCL: expected one error, got %s", diags)
+	}
+	if diff := cmp.Diff(hcl.Pos{Line: 10, Column: 7, Byte: 111}, diags[0].Subject.Start); diff != "" {
+		t.Errorf("the error is not reported where it is: %s", diff)
+	}
+	if diff := cmp.Diff(src, string(f.Bytes)); diff != "" {
+		t.Errorf("unexpected source: %s", diff)
+	}
+
+	attrs := f.Body.(*hclsyntax.Body).Attributes
+	val, diags := attrs["a"].Expr.Value(nil)
+	if diags.HasErrors() {
+		t.Fatalf("Value: %s", diags)
+	}
+	if diff := cmp.Diff("echo ${HOME:-/root} %{ if\n", val.AsString()); diff != "" {
+		t.Errorf("unexpected value: %s", diff)
+	}
+	if diff := cmp.Diff(hcl.Pos{Line: 1, Column: 5, Byte: 4}, attrs["a"].Expr.Range().Start); diff != "" {
+		t.Errorf("unexpected range: %s", diff)
+	}
+
+	val, diags = attrs["b"].Expr.Value(&hcl.EvalContext{Variables: map[string]cty.Value{"c": cty.StringVal("c")}})
+	if diags.HasErrors() {
+		t.Fatalf("Value: %s", diags)
+	}
+	want := cty.TupleVal([]cty.Value{cty.StringVal("c"), cty.StringVal("echo \"${PATH}\"\n\n  exit\n")})
+	if !val.RawEquals(want) {
+		t.Errorf("unexpected value: %#v", val)
+	}
+}
@@ -0,0 +1,188 @@
+package hcl2template
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/go-homedir"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+var localPathsSchema = func() *hcl.BodySchema {
+	schema := &hcl.BodySchema{}
+	for _, setting := range packer.LocalPathSettings {
+		schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: setting})
+	}
+	return schema
+}()
+
+// localPath is a local path referenced by a well-known setting of a plugin
+// body.
+type localPath struct {
+	setting, path string
+	rng           hcl.Range
+}
+
+// localPaths returns the local paths referenced by the well-known settings of
+// a plugin body. Values that are not known before the build starts are left
+// out.
+func localPaths(body hcl.Body, ectx *hcl.EvalContext) []localPath {
+	var res []localPath
+	content, _, _ := body.PartialContent(localPathsSchema)
+	for _, setting := range packer.LocalPathSettings {
+		attr, ok := content.Attributes[setting]
+		if !ok {
+			continue
+		}
+		// evaluation errors are reported when the plugin is started
+		value, moreDiags := attr.Expr.Value(ectx)
+		if moreDiags.HasErrors() || !value.IsWhollyKnown() || value.IsNull() {
+			continue
+		}
+		value, _ = value.UnmarkDeep()
+		paths := []cty.Value{value}
+		if value.CanIterateElements() {
+			paths = value.AsValueSlice()
+		}
+		for _, path := range paths {
+			if path.IsNull() || path.Type() != cty.String {
+				continue
+			}
+			res = append(res, localPath{setting, path.AsString(), attr.Expr.Range()})
+		}
+	}
+	return res
+}
+
+// checkLocalPaths checks that the local paths referenced by the well-known
+// settings of a plugin body exist.
+func checkLocalPaths(plugin string, body hcl.Body, ectx *hcl.EvalContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, lp := range localPaths(body, ectx) {
+		if diag := packer.CheckLocalPath(plugin, lp.setting, lp.path, lp.rng.Ptr()); diag != nil {
+			diags = append(diags, diag)
+		}
+	}
+	return diags
+}
+
+// checkBuildLocalPaths checks the local paths of the source and provisioners
+// of a build; diagnostics already in seen are skipped, as sources and
+// provisioners can be shared between builds.
+func (cfg *PackerConfig) checkBuildLocalPaths(build *BuildBlock, srcUsage SourceUseBlock, seen map[string]bool) hcl.Diagnostics {
+	diags := checkLocalPaths(fmt.Sprintf("source %q", srcUsage.String()), srcUsage.Body, cfg.EvalContext(nil))
+
+	variables := map[string]cty.Value{
+		sourcesAccessor: cty.ObjectVal(srcUsage.ctyValues()),
+		buildAccessor:   cty.DynamicVal,
+	}
+	for _, pb := range build.ProvisionerBlocks {
+		diags = append(diags, checkLocalPaths(fmt.Sprintf("provisioner %q", pb.PType), pb.HCL2Ref.Rest, cfg.EvalContext(variables))...)
+	}
+	if build.CleanupBlock != nil {
+		diags = append(diags, checkLocalPaths(buildCleanupLabel+" block", build.CleanupBlock.HCL2Ref.Rest, cfg.EvalContext(variables))...)
+	}
+
+	var res hcl.Diagnostics
+	for _, diag := range diags {
+		key := diag.Subject.String() + diag.Detail
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, diag)
+	}
+	return res
+}
+
+// LocalFiles returns the local files read by the file() function and the
+// local paths referenced by the well-known settings of the sources and
+// provisioners of the builds, for `packer plan` to tell when they change.
+func (cfg *PackerConfig) LocalFiles() []string {
+	paths := map[string]bool{}
+	if cfg.filesRead != nil {
+		cfg.filesRead.Lock()
+		for path := range cfg.filesRead.paths {
+			paths[path] = true
+		}
+		cfg.filesRead.Unlock()
+	}
+	for _, build := range cfg.Builds {
+		bodies := []hcl.Body{}
+		for _, pb := range build.ProvisionerBlocks {
+			bodies = append(bodies, pb.HCL2Ref.Rest)
+		}
+		if build.CleanupBlock != nil {
+			bodies = append(bodies, build.CleanupBlock.HCL2Ref.Rest)
+		}
+		for _, srcUsage := range build.Sources {
+			// the body of the source was merged with its definition when
+			// the config was initialized
+			if srcUsage.Body != nil {
+				for _, lp := range localPaths(srcUsage.Body, cfg.EvalContext(nil)) {
+					paths[lp.path] = true
+				}
+			}
+			variables := map[string]cty.Value{
+				sourcesAccessor: cty.ObjectVal(srcUsage.ctyValues()),
+				buildAccessor:   cty.DynamicVal,
+			}
+			for _, body := range bodies {
+				for _, lp := range localPaths(body, cfg.EvalContext(variables)) {
+					paths[lp.path] = true
+				}
+			}
+		}
+	}
+	res := make([]string, 0, len(paths))
+	for path := range paths {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// recordedFiles are the local files read by the functions of a config.
+type recordedFiles struct {
+	sync.Mutex
+	paths map[string]bool
+}
+
+// recordFileFunc returns the function f, reading the file of its first
+// argument relative to basedir, recording the files it reads.
+func (r *recordedFiles) recordFileFunc(f function.Function, basedir string) function.Function {
+	return function.New(&function.Spec{
+		Params:   f.Params(),
+		VarParam: f.VarParam(),
+		Type:     f.ReturnTypeForValues,
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			if len(args) > 0 {
+				path, _ := args[0].Unmark()
+				if path.IsKnown() && !path.IsNull() && path.Type() == cty.String {
+					r.record(basedir, path.AsString())
+				}
+			}
+			return f.Call(args)
+		},
+	})
+}
+
+func (r *recordedFiles) record(basedir, path string) {
+	if expanded, err := homedir.Expand(path); err == nil {
+		path = expanded
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(basedir, path)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.paths == nil {
+		r.paths = map[string]bool{}
+	}
+	r.paths[path] = true
+}
@@ -0,0 +1,92 @@
+package hcl2template
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// PackerIgnoreFile is the name of the file listing the files of a folder that
+// are not parsed when the folder is passed to packer. Its syntax is the one of
+// a .dockerignore file.
+const PackerIgnoreFile = ".packerignore"
+
+// ignoreRule is a line of a .packerignore file.
+type ignoreRule struct {
+	pattern glob.Glob
+	// exception is set for the patterns starting with a '!', which re-include
+	// files excluded by a previous pattern.
+	exception bool
+}
+
+// packerIgnore is the list of rules of a .packerignore file.
+type packerIgnore []ignoreRule
+
+// loadPackerIgnore reads the .packerignore file of dir, if any.
+func loadPackerIgnore(dir string) (packerIgnore, hcl.Diagnostics) {
+	filename := filepath.Join(dir, PackerIgnoreFile)
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, hcl.Diagnostics{&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Cannot read " + PackerIgnoreFile,
+			Detail:   err.Error(),
+		}}
+	}
+
+	var rules packerIgnore
+	var diags hcl.Diagnostics
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(pattern, "!") {
+			rule.exception = true
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+		pattern = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(pattern)), "/")
+		rule.pattern, err = glob.Compile(pattern, '/')
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + PackerIgnoreFile + " pattern",
+				Detail:   fmt.Sprintf("%q: %s", pattern, err),
+				Subject: &hcl.Range{
+					Filename: filename,
+					Start:    hcl.Pos{Line: line, Column: 1},
+					End:      hcl.Pos{Line: line, Column: 1},
+				},
+			})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, diags
+}
+
+// ignores tells whether name, a path relative to the folder of the
+// .packerignore file, is excluded. Like in a .dockerignore file the last
+// matching pattern wins.
+func (rules packerIgnore) ignores(name string) bool {
+	name = filepath.ToSlash(name)
+	ignored := false
+	for _, rule := range rules {
+		if rule.pattern.Match(name) {
+			ignored = !rule.exception
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,82 @@
+package hcl2template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetHCL2Files_packerignore(t *testing.T) {
+	dir := filepath.Join("testdata", "packerignore")
+	hclFiles, jsonFiles, diags := GetHCL2Files(dir, hcl2FileExt, hcl2JsonFileExt)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	expected := []string{
+		filepath.Join(dir, "build.pkr.hcl"),
+		filepath.Join(dir, "examples_kept.pkr.hcl"),
+	}
+	if diff := cmp.Diff(expected, hclFiles); diff != "" {
+		t.Errorf("unexpected hcl files: %s", diff)
+	}
+	if len(jsonFiles) != 0 {
+		t.Errorf("unexpected json files: %v", jsonFiles)
+	}
+
+	_, _, diags = GetHCL2Files(filepath.Join(dir, "invalid"), hcl2FileExt, hcl2JsonFileExt)
+	if !diags.HasErrors() {
+		t.Fatal("expected an invalid pattern error")
+	}
+	if diff := cmp.Diff("Invalid .packerignore pattern", diags[0].Summary); diff != "" {
+		t.Errorf("unexpected diagnostic: %s", diff)
+	}
+}
+
+func TestPackerIgnore_ignores(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		file     string
+		want     bool
+	}{
+		{"no rules", nil, "build.pkr.hcl", false},
+		{"match", []string{"*.example.pkr.hcl"}, "ubuntu.example.pkr.hcl", true},
+		{"no match", []string{"*.example.pkr.hcl"}, "ubuntu.pkr.hcl", false},
+		{"exception", []string{"*.pkr.hcl", "!build.pkr.hcl"}, "build.pkr.hcl", false},
+		{"last match wins", []string{"!build.pkr.hcl", "*.pkr.hcl"}, "build.pkr.hcl", true},
+		{"leading slash", []string{"/build.pkr.hcl"}, "build.pkr.hcl", true},
+		{"character class", []string{"gen[0-9].pkr.hcl"}, "gen1.pkr.hcl", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup := writePackerIgnore(t, tt.patterns)
+			defer cleanup()
+			rules, diags := loadPackerIgnore(dir)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if got := rules.ignores(tt.file); got != tt.want {
+				t.Errorf("ignores(%q) = %t, want %t", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+// writePackerIgnore writes a .packerignore file with patterns in a temporary
+// folder.
+func writePackerIgnore(t *testing.T, patterns []string) (string, func()) {
+	dir, err := ioutil.TempDir("", "packerignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Join(patterns, "\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, PackerIgnoreFile), []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -1,16 +1,21 @@
 package hcl2template
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/ext/dynblock"
+	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -24,6 +29,11 @@ const (
 	dataSourceLabel   = "data"
 	buildLabel        = "build"
 	communicatorLabel = "communicator"
+	functionLabel     = "function"
+	imageLabel        = "image"
+	imageMappingLabel = "image_mapping"
+
+	postProcessorsLabel = "post-processors"
 )
 
 var configSchema = &hcl.BodySchema{
@@ -37,6 +47,10 @@ var configSchema = &hcl.BodySchema{
 		{Type: dataSourceLabel, LabelNames: []string{"type", "name"}},
 		{Type: buildLabel},
 		{Type: communicatorLabel, LabelNames: []string{"type", "name"}},
+		{Type: functionLabel, LabelNames: []string{"name"}},
+		{Type: imageLabel, LabelNames: []string{"name"}},
+		{Type: imageMappingLabel, LabelNames: []string{"type"}},
+		{Type: postProcessorsLabel},
 	},
 }
 
@@ -45,6 +59,8 @@ var configSchema = &hcl.BodySchema{
 var packerBlockSchema = &hcl.BodySchema{
 	Attributes: []hcl.AttributeSchema{
 		{Name: "required_version"},
+		{Name: "suppress_warnings"},
+		{Name: "parallel_builds_by_type"},
 	},
 	Blocks: []hcl.BlockHeaderSchema{
 		{Type: "required_plugins"},
@@ -68,6 +84,7 @@ type Parser struct {
 const (
 	hcl2FileExt            = ".pkr.hcl"
 	hcl2JsonFileExt        = ".pkr.json"
+	hcl2YamlFileExt        = ".pkr.yaml"
 	hcl2VarFileExt         = ".pkrvars.hcl"
 	hcl2VarJsonFileExt     = ".pkrvars.json"
 	hcl2AutoVarFileExt     = ".auto.pkrvars.hcl"
@@ -83,41 +100,95 @@ const (
 // build; sources(builders)/provisioners/posts-processors will not be started
 // and their contents wont be verified; Most syntax errors will cause an error.
 func (p *Parser) Parse(filename string, varFiles []string, argVars map[string]string) (*PackerConfig, hcl.Diagnostics) {
-	var files []*hcl.File
+	var files, overrideFiles []*hcl.File
 	var diags hcl.Diagnostics
 
 	// parse config files
 	if filename != "" {
 		hclFiles, jsonFiles, moreDiags := GetHCL2Files(filename, hcl2FileExt, hcl2JsonFileExt)
 		diags = append(diags, moreDiags...)
-		if len(hclFiles)+len(jsonFiles) == 0 {
+		yamlFiles, moreDiags := getYAMLFiles(filename)
+		diags = append(diags, moreDiags...)
+		if len(hclFiles)+len(jsonFiles)+len(yamlFiles) == 0 {
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Could not find any config file in " + filename,
-				Detail: "A config file must be suffixed with `.pkr.hcl` or " +
-					"`.pkr.json`. A folder can be referenced.",
+				Detail: "A config file must be suffixed with `.pkr.hcl`, " +
+					"`.pkr.json` or `.pkr.yaml`. A folder can be referenced.",
 			})
 		}
+		// Override files only exist within a folder.
+		isFolder, _ := isDir(filename)
+		add := func(filename string, f *hcl.File) {
+			if isFolder && isOverrideFile(filename) {
+				overrideFiles = append(overrideFiles, f)
+				return
+			}
+			files = append(files, f)
+		}
 		for _, filename := range hclFiles {
 			f, moreDiags := p.ParseHCLFile(filename)
 			diags = append(diags, moreDiags...)
-			files = append(files, f)
+			add(filename, f)
 		}
 		for _, filename := range jsonFiles {
 			f, moreDiags := p.ParseJSONFile(filename)
 			diags = append(diags, moreDiags...)
-			files = append(files, f)
+			add(filename, f)
+		}
+		for _, filename := range yamlFiles {
+			f, moreDiags := p.ParseYAMLFile(filename)
+			diags = append(diags, moreDiags...)
+			add(filename, f)
 		}
 		if diags.HasErrors() {
 			return nil, diags
 		}
+
+		files, moreDiags = applyOverrides(files, overrideFiles)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			return nil, diags
+		}
 	}
 
 	basedir := filename
 	if isDir, err := isDir(basedir); err == nil && !isDir {
 		basedir = filepath.Dir(basedir)
 	}
+	cfg, moreDiags := p.parse(files, filename, basedir, varFiles, argVars)
+	return cfg, cfg.suppressWarnings(append(diags, moreDiags...))
+}
+
+// ParseSource parses the config file of content src, read from somewhere
+// else than a file, like stdin. filename names the file in diagnostics and
+// tells its syntax: JSON when suffixed with `.pkr.json`, HCL otherwise.
+// basedir is the folder the config is considered to be in, the value of
+// path.root. Unlike the files of a folder, auto var files are not loaded.
+func (p *Parser) ParseSource(src []byte, filename, basedir string, varFiles []string, argVars map[string]string) (*PackerConfig, hcl.Diagnostics) {
+	var f *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(filename, hcl2JsonFileExt) {
+		f, diags = p.ParseJSON(src, filename)
+	} else {
+		f, diags = p.ParseHCL(src, filename)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	cfg, moreDiags := p.parse([]*hcl.File{f}, "", basedir, varFiles, argVars)
+	return cfg, cfg.suppressWarnings(append(diags, moreDiags...))
+}
+
+// parse decodes the config files, in basedir. The auto var files of
+// autoVarsDir, when set, are loaded along with varFiles.
+func (p *Parser) parse(files []*hcl.File, autoVarsDir, basedir string, varFiles []string, argVars map[string]string) (*PackerConfig, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
 	wd, err := os.Getwd()
+	if err == nil {
+		wd, err = resolvePath(wd)
+	}
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
 			Severity: hcl.DiagError,
@@ -125,18 +196,54 @@ func (p *Parser) Parse(filename string, varFiles []string, argVars map[string]st
 			Detail:   err.Error(),
 		})
 	}
+	basedir, err = resolvePath(basedir)
+	if err != nil {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Could not find the directory of the config",
+			Detail:   err.Error(),
+		})
+	}
+	configDir, err := pathing.ConfigDir()
+	if err == nil {
+		configDir, err = resolvePath(configDir)
+	}
+	if err != nil {
+		// only path.config needs it, it is left empty
+		configDir = ""
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Could not find the Packer config directory",
+			Detail:   fmt.Sprintf("path.config is empty: %s", err),
+		})
+	}
 	cfg := &PackerConfig{
 		Basedir:                 basedir,
 		Cwd:                     wd,
+		ConfigDir:               configDir,
 		CorePackerVersionString: p.CorePackerVersionString,
 		parser:                  p,
 		files:                   files,
+		filesRead:               &recordedFiles{},
 	}
 
 	for _, file := range files {
 		coreVersionConstraints, moreDiags := sniffCoreVersionRequirements(file.Body)
 		cfg.Packer.VersionConstraints = append(cfg.Packer.VersionConstraints, coreVersionConstraints...)
 		diags = append(diags, moreDiags...)
+
+		suppressed, moreDiags := decodeSuppressWarnings(file.Body)
+		cfg.Packer.SuppressWarnings = append(cfg.Packer.SuppressWarnings, suppressed...)
+		diags = append(diags, moreDiags...)
+
+		limits, moreDiags := decodeParallelBuildsByType(file.Body)
+		for typ, limit := range limits {
+			if cfg.Packer.ParallelBuildsByType == nil {
+				cfg.Packer.ParallelBuildsByType = map[string]int64{}
+			}
+			cfg.Packer.ParallelBuildsByType[typ] = limit
+		}
+		diags = append(diags, moreDiags...)
 	}
 
 	// Before we go further, we'll check to make sure this version can read
@@ -170,6 +277,15 @@ func (p *Parser) Parse(filename string, varFiles []string, argVars map[string]st
 		}
 	}
 
+	// Decode function blocks first, so that they can be called from any
+	// expression.
+	{
+		for _, file := range files {
+			diags = append(diags, cfg.decodeFunctions(file)...)
+		}
+		diags = append(diags, cfg.checkFunctionCycles()...)
+	}
+
 	// Decode variable blocks so that they are available later on. Here locals
 	// can use input variables so we decode input variables first.
 	{
@@ -183,15 +299,15 @@ func (p *Parser) Parse(filename string, varFiles []string, argVars map[string]st
 		}
 
 		for _, file := range files {
-			moreLocals, morediags := cfg.parseLocalVariables(file)
+			// parseLocalVariables adds the locals of file to cfg.LocalBlocks
+			_, morediags := cfg.parseLocalVariables(file)
 			diags = append(diags, morediags...)
-			cfg.LocalBlocks = append(cfg.LocalBlocks, moreLocals...)
 		}
 	}
 
 	// parse var files
 	{
-		hclVarFiles, jsonVarFiles, moreDiags := GetHCL2Files(filename, hcl2AutoVarFileExt, hcl2AutoVarJsonFileExt)
+		hclVarFiles, jsonVarFiles, moreDiags := GetHCL2Files(autoVarsDir, hcl2AutoVarFileExt, hcl2AutoVarJsonFileExt)
 		diags = append(diags, moreDiags...)
 		for _, file := range varFiles {
 			switch filepath.Ext(file) {
@@ -232,6 +348,8 @@ func (p *Parser) Parse(filename string, varFiles []string, argVars map[string]st
 	for _, file := range cfg.files {
 		diags = append(diags, cfg.parser.parseConfig(file, cfg)...)
 	}
+	// images and their mappings can be in different files
+	diags = append(diags, cfg.expandImages()...)
 	return cfg, diags
 }
 
@@ -279,6 +397,82 @@ func sniffCoreVersionRequirements(body hcl.Body) ([]VersionConstraint, hcl.Diagn
 	return constraints, diags
 }
 
+// decodeSuppressWarnings returns the codes of the warnings listed in the
+// suppress_warnings attribute of the "packer" blocks of body. Only the codes
+// of warnings, like PKRW001, are allowed.
+func decodeSuppressWarnings(body hcl.Body) ([]messages.Code, hcl.Diagnostics) {
+	rootContent, _, diags := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: packerLabel}},
+	})
+
+	var codes []messages.Code
+	for _, block := range rootContent.Blocks {
+		// errors of the packer block are reported when sniffing its
+		// required_version
+		content, _ := block.Body.Content(packerBlockSchema)
+		attr, exists := content.Attributes["suppress_warnings"]
+		if !exists {
+			continue
+		}
+
+		var values []string
+		moreDiags := gohcl.DecodeExpression(attr.Expr, nil, &values)
+		diags = append(diags, moreDiags...)
+		for _, value := range values {
+			code := messages.Code(value)
+			if !code.IsWarning() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid warning code",
+					Detail:   fmt.Sprintf("%q is not the code of a warning, like PKRW001.", value),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+				continue
+			}
+			codes = append(codes, code)
+		}
+	}
+	return codes, diags
+}
+
+// decodeParallelBuildsByType returns the limits set by the
+// parallel_builds_by_type attribute of the "packer" blocks of body: the most
+// builds of a builder type, or of the builder types of a plugin, running at
+// the same time. 0 means no limit.
+func decodeParallelBuildsByType(body hcl.Body) (map[string]int64, hcl.Diagnostics) {
+	rootContent, _, diags := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: packerLabel}},
+	})
+
+	limits := map[string]int64{}
+	for _, block := range rootContent.Blocks {
+		// errors of the packer block are reported when sniffing its
+		// required_version
+		content, _ := block.Body.Content(packerBlockSchema)
+		attr, exists := content.Attributes["parallel_builds_by_type"]
+		if !exists {
+			continue
+		}
+
+		var values map[string]int64
+		moreDiags := gohcl.DecodeExpression(attr.Expr, nil, &values)
+		diags = append(diags, moreDiags...)
+		for typ, limit := range values {
+			if limit < 0 {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid build limit",
+					Detail:   fmt.Sprintf("The limit of %s builds must be positive, or 0 for no limit.", typ),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+				continue
+			}
+			limits[typ] = limit
+		}
+	}
+	return limits, diags
+}
+
 func filterVarsFromLogs(inputOrLocal Variables) {
 	for _, variable := range inputOrLocal {
 		if !variable.Sensitive {
@@ -301,22 +495,27 @@ func (cfg *PackerConfig) Initialize(opts packer.InitializeOptions) hcl.Diagnosti
 	moreDiags := cfg.detectPluginBinaries()
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
-		return diags
+		return cfg.suppressWarnings(diags)
 	}
 
 	_, moreDiags = cfg.InputVariables.Values()
 	diags = append(diags, moreDiags...)
 	_, moreDiags = cfg.LocalVariables.Values()
 	diags = append(diags, moreDiags...)
-	diags = append(diags, cfg.evaluateDatasources(opts.SkipDatasourcesExecution)...)
-	diags = append(diags, cfg.evaluateLocalVariables(cfg.LocalBlocks)...)
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	diags = append(diags, cfg.evaluateLocalsAndDatasources(ctx, opts.SkipDatasourcesExecution)...)
 
-	filterVarsFromLogs(cfg.InputVariables)
-	filterVarsFromLogs(cfg.LocalVariables)
+	if !opts.ShowSensitive {
+		filterVarsFromLogs(cfg.InputVariables)
+		filterVarsFromLogs(cfg.LocalVariables)
+	}
 
 	diags = append(diags, cfg.initializeBlocks()...)
 
-	return diags
+	return cfg.suppressWarnings(diags)
 }
 
 // parseConfig looks in the found blocks for everything that is not a variable
@@ -356,6 +555,46 @@ func (p *Parser) parseConfig(f *hcl.File, cfg *PackerConfig) hcl.Diagnostics {
 			}
 			cfg.Sources[ref] = source
 
+		case imageLabel:
+			img, moreDiags := decodeImageBlock(block)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			duplicate := false
+			for _, existing := range cfg.images {
+				if existing.Name == img.Name {
+					duplicate = true
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Duplicate " + imageLabel + " block",
+						Detail: fmt.Sprintf("This "+imageLabel+" block has the same name "+
+							"as a previous block declared at %s.", existing.defRange.Ptr()),
+						Subject: block.DefRange.Ptr(),
+					})
+				}
+			}
+			if !duplicate {
+				cfg.images = append(cfg.images, img)
+			}
+
+		case imageMappingLabel:
+			typ := block.Labels[0]
+			if existing, found := cfg.imageMappings[typ]; found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate " + imageMappingLabel + " block",
+					Detail: fmt.Sprintf("This "+imageMappingLabel+" block has the same "+
+						"type as a previous block declared at %s.", existing.block.DefRange.Ptr()),
+					Subject: block.DefRange.Ptr(),
+				})
+				continue
+			}
+			if cfg.imageMappings == nil {
+				cfg.imageMappings = map[string]*imageMapping{}
+			}
+			cfg.imageMappings[typ] = &imageMapping{Type: typ, block: block}
+
 		case buildLabel:
 			build, moreDiags := p.decodeBuildConfig(block, cfg)
 			diags = append(diags, moreDiags...)
@@ -364,6 +603,14 @@ func (p *Parser) parseConfig(f *hcl.File, cfg *PackerConfig) hcl.Diagnostics {
 			}
 			cfg.Builds = append(cfg.Builds, build)
 
+		case postProcessorsLabel:
+			postProcessors, moreDiags := p.decodeCombinedPostProcessors(block)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			cfg.CombinedPostProcessors = append(cfg.CombinedPostProcessors, postProcessors)
+
 		}
 	}
 
@@ -0,0 +1,94 @@
+package hcl2template
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
+)
+
+func TestParser_ParseSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		src      string
+	}{
+		{"hcl", "<stdin>.pkr.hcl", `
+variable "region" {
+  type = string
+}
+locals {
+  root = path.root
+}
+`},
+		{"json", "<stdin>.pkr.json", `{
+  "variable": {"region": {"type": "string"}},
+  "locals": {"root": "${path.root}"}
+}`},
+	}
+	root, err := filepath.Abs("images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, diags := getBasicParser().ParseSource([]byte(tt.src), tt.filename, "images", nil, map[string]string{"region": "eu-west-1"})
+			if diags.HasErrors() {
+				t.Fatalf("ParseSource: %s", diags)
+			}
+			if diags := cfg.Initialize(packer.InitializeOptions{}); diags.HasErrors() {
+				t.Fatalf("Initialize: %s", diags)
+			}
+			if region, _ := cfg.InputVariables["region"].Value(); region.AsString() != "eu-west-1" {
+				t.Errorf("var.region = %#v, expected eu-west-1", region)
+			}
+			if value, _ := cfg.LocalVariables["root"].Value(); value.AsString() != filepath.ToSlash(root) {
+				t.Errorf("local.root = %#v, expected %s", value, root)
+			}
+		})
+	}
+}
+
+func TestParser_parallelBuildsByType(t *testing.T) {
+	cfg, diags := getBasicParser().ParseSource([]byte(`packer {
+  parallel_builds_by_type = {
+    vmware = 2
+    docker = 0
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	limits := cfg.ParallelBuildsByType()
+	if len(limits) != 2 || limits["vmware"] != 2 || limits["docker"] != 0 {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+
+	_, diags = getBasicParser().ParseSource([]byte(`packer {
+  parallel_builds_by_type = { vmware = -1 }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if !diags.HasErrors() {
+		t.Error("expected an error, limits cannot be negative")
+	}
+}
+
+func TestParser_suppressWarnings(t *testing.T) {
+	cfg, diags := getBasicParser().Parse(filepath.Join("testdata", "suppress_warnings"), nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected the override warning to be suppressed, got %s", diags)
+	}
+	if len(cfg.Packer.SuppressWarnings) != 1 || cfg.Packer.SuppressWarnings[0] != messages.CodeOverrideApplied {
+		t.Errorf("unexpected suppressed warnings: %v", cfg.Packer.SuppressWarnings)
+	}
+
+	_, diags = getBasicParser().ParseSource([]byte(`packer {
+  suppress_warnings = ["PKR1003"]
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if !diags.HasErrors() {
+		t.Error("expected an error, PKR1003 is not the code of a warning")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/ext/dynblock"
 	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
+	"github.com/hashicorp/packer/packer/messages"
 	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
 )
 
@@ -101,6 +102,12 @@ func (cfg *PackerConfig) detectPluginBinaries() hcl.Diagnostics {
 			})
 			continue
 		}
+		if install.Version != "" {
+			if cfg.PluginVersions == nil {
+				cfg.PluginVersions = map[string]string{}
+			}
+			cfg.PluginVersions[pluginRequirement.Identifier.String()] = install.Version
+		}
 	}
 
 	return diags
@@ -119,7 +126,7 @@ func (cfg *PackerConfig) initializeBlocks() hcl.Diagnostics {
 				diags = append(diags, &hcl.Diagnostic{
 					Summary:  "Unknown " + buildSourceLabel + " type " + srcUsage.Type,
 					Subject:  &build.HCL2Ref.DefRange,
-					Detail:   fmt.Sprintf("known builders: %v", cfg.parser.PluginConfig.Builders.List()),
+					Detail:   messages.CodeUnknownComponent.Annotate(fmt.Sprintf("known builders: %v", cfg.parser.PluginConfig.Builders.List())),
 					Severity: hcl.DiagError,
 				})
 				continue
@@ -153,13 +160,16 @@ func (cfg *PackerConfig) initializeBlocks() hcl.Diagnostics {
 				diags = append(diags, &hcl.Diagnostic{
 					Summary:  fmt.Sprintf("Unknown "+buildProvisionerLabel+" type %q", provBlock.PType),
 					Subject:  provBlock.HCL2Ref.TypeRange.Ptr(),
-					Detail:   fmt.Sprintf("known "+buildProvisionerLabel+"s: %v", cfg.parser.PluginConfig.Provisioners.List()),
+					Detail:   messages.CodeUnknownComponent.Annotate(fmt.Sprintf("known "+buildProvisionerLabel+"s: %v", cfg.parser.PluginConfig.Provisioners.List())),
 					Severity: hcl.DiagError,
 				})
 			}
 			// Allow rest of the body to have dynamic blocks
 			provBlock.HCL2Ref.Rest = dynblock.Expand(provBlock.HCL2Ref.Rest, cfg.EvalContext(nil))
 		}
+		if build.CleanupBlock != nil {
+			build.CleanupBlock.HCL2Ref.Rest = dynblock.Expand(build.CleanupBlock.HCL2Ref.Rest, cfg.EvalContext(nil))
+		}
 
 		for _, ppList := range build.PostProcessorsLists {
 			for _, ppBlock := range ppList {
@@ -167,7 +177,7 @@ func (cfg *PackerConfig) initializeBlocks() hcl.Diagnostics {
 					diags = append(diags, &hcl.Diagnostic{
 						Summary:  fmt.Sprintf("Unknown "+buildPostProcessorLabel+" type %q", ppBlock.PType),
 						Subject:  ppBlock.HCL2Ref.TypeRange.Ptr(),
-						Detail:   fmt.Sprintf("known "+buildPostProcessorLabel+"s: %v", cfg.parser.PluginConfig.Provisioners.List()),
+						Detail:   messages.CodeUnknownComponent.Annotate(fmt.Sprintf("known "+buildPostProcessorLabel+"s: %v", cfg.parser.PluginConfig.Provisioners.List())),
 						Severity: hcl.DiagError,
 					})
 				}
@@ -0,0 +1,17 @@
+
+// starts resources to provision them.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    cleanup {
+        slice_string = ["rm -f /etc/ssh/ssh_host_*"]
+    }
+
+    provisioner "file" {
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
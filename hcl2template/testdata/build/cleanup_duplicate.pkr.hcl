@@ -0,0 +1,8 @@
+
+build {
+    cleanup {
+    }
+
+    cleanup {
+    }
+}
@@ -0,0 +1,23 @@
+
+// runs the file provisioners at the same time.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    parallel {
+        max_sessions = 2
+
+        provisioner "file" {
+        }
+
+        provisioner "file" {
+        }
+    }
+
+    provisioner "shell" {
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
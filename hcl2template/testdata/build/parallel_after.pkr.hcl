@@ -0,0 +1,21 @@
+
+// the provisioners of a parallel block cannot be ordered.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    parallel {
+        provisioner "file" {
+            name = "upload"
+        }
+    }
+
+    provisioner "shell" {
+        name  = "install"
+        after = ["upload"]
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
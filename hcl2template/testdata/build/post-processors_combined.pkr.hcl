@@ -0,0 +1,17 @@
+
+// the post-processors of the top-level post-processors block run on the
+// artifacts of all the builds.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+}
+
+post-processors {
+    post-processor "manifest" {
+        name = "combined"
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
@@ -0,0 +1,16 @@
+
+// the post-processors of all builds cannot be restricted to sources.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+}
+
+post-processors {
+    post-processor "manifest" {
+        only = ["virtualbox-iso.ubuntu-1204"]
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
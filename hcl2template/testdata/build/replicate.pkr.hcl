@@ -0,0 +1,14 @@
+// the mock builder cannot replicate its artifacts.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    replicate {
+        targets      = ["eu-west-1", "us-west-2"]
+        max_parallel = 1
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
@@ -0,0 +1,13 @@
+// an artifact is replicated once to each target.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    replicate {
+        targets = ["eu-west-1", "eu-west-1"]
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
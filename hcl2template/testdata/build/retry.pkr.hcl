@@ -0,0 +1,13 @@
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    retry {
+        attempts    = 3
+        min_backoff = "10s"
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
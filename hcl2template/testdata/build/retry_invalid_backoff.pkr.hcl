@@ -0,0 +1,15 @@
+// the wait between attempts only grows.
+build {
+    sources = [
+        "source.virtualbox-iso.ubuntu-1204"
+    ]
+
+    retry {
+        attempts    = 3
+        min_backoff = "10m"
+        max_backoff = "1m"
+    }
+}
+
+source "virtualbox-iso" "ubuntu-1204" {
+}
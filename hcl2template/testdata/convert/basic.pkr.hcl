@@ -0,0 +1,55 @@
+variable "names" {
+  type    = list(string)
+  default = ["a", "b"]
+}
+
+locals {
+  name    = "${var.names[0]}-image"
+  literal = "costs $${amount} <enter>"
+  tags = {
+    owner = "me"
+    "${var.names[1]}" = upper("b")
+  }
+}
+
+function "clean" {
+  params = [s]
+  result = lower(s)
+}
+
+source "virtualbox-iso" "example" {
+  string = local.name
+  int    = 42
+  bool   = true
+  nested {
+    string = "nested"
+  }
+  nested_slice {
+    int = 1
+  }
+  nested_slice {
+    int = 2
+  }
+}
+
+build {
+  sources = ["source.virtualbox-iso.example"]
+
+  provisioner "shell" {
+    inline = [
+      "echo ${clean(source.name)}",
+    ]
+  }
+
+  provisioner "file" {
+    string = <<EOT
+line one
+line ${2 + 0}
+EOT
+  }
+
+  post-processors {
+    post-processor "manifest" {
+    }
+  }
+}
@@ -0,0 +1,7 @@
+data "amazon-ami" "first" {
+  string = data.amazon-ami.second.string
+}
+
+data "amazon-ami" "second" {
+  string = data.amazon-ami.first.string
+}
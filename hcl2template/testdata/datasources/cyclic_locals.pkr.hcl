@@ -0,0 +1,7 @@
+locals {
+  name = data.amazon-ami.test.string
+}
+
+data "amazon-ami" "test" {
+  string = local.name
+}
@@ -0,0 +1,29 @@
+// "filtered" sorts before "images" but must be evaluated after it.
+data "amazon-ami" "filtered" {
+  slice_string = [for image in data.amazon-ami.images.nested_slice : image.string if image.bool]
+  int          = max(data.amazon-ami.images.nested_slice[*].int...)
+}
+
+data "amazon-ami" "images" {
+  nested_slice {
+    string = "ubuntu-20.04-20210101"
+    int    = 1
+    bool   = true
+  }
+  nested_slice {
+    string = "ubuntu-20.04-20210301"
+    int    = 3
+    bool   = true
+  }
+  nested_slice {
+    string = "ubuntu-20.04-20210201"
+    int    = 2
+    bool   = false
+  }
+}
+
+locals {
+  names  = data.amazon-ami.images.nested_slice[*].string
+  newest = [for image in data.amazon-ami.images.nested_slice : image.string if image.int == data.amazon-ami.filtered.int][0]
+  usable = data.amazon-ami.filtered.slice_string
+}
@@ -0,0 +1,15 @@
+// data.amazon-ami.test and local.image reference each other's values, but not
+// in a cycle; both depend on var.distribution, which can be set with -var.
+variable "distribution" {
+  type    = string
+  default = "ubuntu"
+}
+
+locals {
+  image_name = "${var.distribution}-20.04"
+  image      = data.amazon-ami.test.string
+}
+
+data "amazon-ami" "test" {
+  string = local.image_name
+}
@@ -0,0 +1,9 @@
+locals {
+  script   = <<'EOF'
+#!/bin/sh
+echo ${HOME:-/root}
+EOF
+  indented = <<-'EOF'
+    %{ not a directive }
+    EOF
+}
@@ -0,0 +1,14 @@
+function "clean" {
+  params = [s]
+  result = lower(replace(s, " ", "-"))
+}
+
+function "image_name" {
+  params = [prefix, name]
+  result = "${prefix}-${clean(name)}"
+}
+
+locals {
+  cleaned    = clean("My Image")
+  image_name = image_name("packer", "Ubuntu 20.04")
+}
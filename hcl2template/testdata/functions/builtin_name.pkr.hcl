@@ -0,0 +1,4 @@
+function "lower" {
+  params = [s]
+  result = s
+}
@@ -0,0 +1,4 @@
+function "twice" {
+  params = [s, s]
+  result = s
+}
@@ -0,0 +1,9 @@
+function "ping" {
+  params = [s]
+  result = pong(s)
+}
+
+function "pong" {
+  params = [s]
+  result = ping(s)
+}
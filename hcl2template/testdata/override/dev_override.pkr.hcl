@@ -0,0 +1,16 @@
+variable "region" {
+  default = "us-east-1"
+}
+
+locals {
+  size = "large"
+}
+
+source "virtualbox-iso" "ubuntu" {
+  int = 42
+
+  tag {
+    key   = "env"
+    value = "dev"
+  }
+}
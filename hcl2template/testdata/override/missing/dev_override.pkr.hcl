@@ -0,0 +1,7 @@
+source "virtualbox-iso" "debian" {
+  int = 42
+}
+
+locals {
+  unknown = "value"
+}
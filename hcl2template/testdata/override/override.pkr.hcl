@@ -0,0 +1,3 @@
+source "virtualbox-iso" "ubuntu" {
+  bool = true
+}
@@ -0,0 +1,28 @@
+variable "region" {
+  type    = string
+  default = "eu-west-1"
+}
+
+locals {
+  size = "small"
+  name = "base"
+}
+
+source "virtualbox-iso" "ubuntu" {
+  string       = "${var.region}-${local.size}"
+  int          = 1
+  slice_string = ["a", "b"]
+
+  tag {
+    key   = "owner"
+    value = "team"
+  }
+  tag {
+    key   = "env"
+    value = "prod"
+  }
+}
+
+build {
+  sources = ["source.virtualbox-iso.ubuntu"]
+}
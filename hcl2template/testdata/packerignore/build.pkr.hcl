@@ -0,0 +1,3 @@
+source "null" "test" {
+  communicator = "none"
+}
@@ -0,0 +1,3 @@
+variable "kept" {
+  default = "yes"
+}
@@ -0,0 +1,31 @@
+variable "size" {
+  default = "small"
+}
+
+image "ubuntu" {
+  family  = "ubuntu"
+  size    = var.size
+  regions = ["eu-west-1", "us-east-1"]
+}
+
+image "windows" {
+  family  = "windows"
+  targets = ["amazon-ebs"]
+}
+
+image_mapping "amazon-ebs" {
+  string       = image.size == "small" ? "t3.small" : "m5.large"
+  slice_string = coalesce(image.regions, ["us-east-1"])
+}
+
+image_mapping "virtualbox-iso" {
+  string = "${image.family}-${image.name}"
+}
+
+build {
+  sources = [
+    "source.amazon-ebs.ubuntu",
+    "source.virtualbox-iso.ubuntu",
+    "source.amazon-ebs.windows",
+  ]
+}
@@ -0,0 +1,11 @@
+// the scheduling block is read by packer and is not passed to the builder.
+source "virtualbox-iso" "ubuntu-1204" {
+    scheduling {
+        cpu    = 4
+        memory = "4GB"
+    }
+}
+
+build {
+    sources = ["source.virtualbox-iso.ubuntu-1204"]
+}
@@ -0,0 +1,15 @@
+// the timeouts block is read by packer: connect_timeout is set as the
+// ssh_timeout of the null builder and timeout is enforced by packer.
+source "null" "ssh" {
+    ssh_host     = "127.0.0.1"
+    ssh_username = "packer"
+    ssh_password = "packer"
+    timeouts {
+        connect_timeout = "15m"
+        timeout         = "1h"
+    }
+}
+
+build {
+    sources = ["source.null.ssh"]
+}
@@ -0,0 +1,13 @@
+source "null" "ssh" {
+    ssh_host     = "127.0.0.1"
+    ssh_username = "packer"
+    ssh_password = "packer"
+    ssh_timeout  = "5m"
+    timeouts {
+        connect_timeout = "15m"
+    }
+}
+
+build {
+    sources = ["source.null.ssh"]
+}
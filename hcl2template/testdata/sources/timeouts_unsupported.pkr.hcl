@@ -0,0 +1,9 @@
+source "virtualbox-iso" "ubuntu-1204" {
+    timeouts {
+        boot_wait = "10s"
+    }
+}
+
+build {
+    sources = ["source.virtualbox-iso.ubuntu-1204"]
+}
@@ -0,0 +1,3 @@
+source "virtualbox-iso" "ubuntu" {
+  int = 42
+}
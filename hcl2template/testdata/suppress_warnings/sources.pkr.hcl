@@ -0,0 +1,11 @@
+packer {
+  suppress_warnings = ["PKRW002"]
+}
+
+source "virtualbox-iso" "ubuntu" {
+  int = 1
+}
+
+build {
+  sources = ["source.virtualbox-iso.ubuntu"]
+}
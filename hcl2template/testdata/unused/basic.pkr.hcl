@@ -0,0 +1,47 @@
+variable "used" {
+  type    = string
+  default = "used"
+}
+
+variable "unused" {
+  type    = string
+  default = "unused"
+
+  validation {
+    condition     = length(var.unused) > 0
+    error_message = "The unused variable must not be empty."
+  }
+}
+
+variables {
+  by_index = "by_index"
+}
+
+locals {
+  name        = "${var.used}-${var["by_index"]}"
+  unused_name = "unused"
+}
+
+local "referenced_by_data" {
+  expression = "ami"
+}
+
+data "amazon-ami" "used" {
+  string = local.referenced_by_data
+}
+
+data "amazon-ami" "unused" {
+  string = "unused"
+}
+
+source "null" "test" {
+  communicator = "none"
+}
+
+build {
+  sources = ["source.null.test"]
+
+  provisioner "shell" {
+    inline = [local.name, data.amazon-ami.used.string]
+  }
+}
@@ -0,0 +1,5 @@
+
+variable "boot_wait" {
+  type    = duration
+  default = "90 seconds"
+}
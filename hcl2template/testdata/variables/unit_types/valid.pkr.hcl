@@ -0,0 +1,10 @@
+
+variable "boot_wait" {
+  type    = duration
+  default = "1m30s"
+}
+
+variable "disk_size" {
+  type    = size
+  default = "40GB"
+}
@@ -16,6 +16,17 @@ const (
 	buildPostProcessorLabel = "post-processor"
 
 	buildPostProcessorsLabel = "post-processors"
+
+	buildCleanupLabel = "cleanup"
+
+	buildParallelLabel = "parallel"
+
+	buildReplicateLabel = "replicate"
+
+	buildRetryLabel = "retry"
+
+	// cleanupProvisionerType is the provisioner running the cleanup block.
+	cleanupProvisionerType = "shell"
 )
 
 var buildSchema = &hcl.BodySchema{
@@ -25,6 +36,16 @@ var buildSchema = &hcl.BodySchema{
 		{Type: buildProvisionerLabel, LabelNames: []string{"type"}},
 		{Type: buildPostProcessorLabel, LabelNames: []string{"type"}},
 		{Type: buildPostProcessorsLabel, LabelNames: []string{}},
+		{Type: buildCleanupLabel, LabelNames: []string{}},
+		{Type: buildParallelLabel, LabelNames: []string{}},
+		{Type: buildReplicateLabel, LabelNames: []string{}},
+		{Type: buildRetryLabel, LabelNames: []string{}},
+	},
+}
+
+var parallelSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: buildProvisionerLabel, LabelNames: []string{"type"}},
 	},
 }
 
@@ -41,6 +62,12 @@ var postProcessorsSchema = &hcl.BodySchema{
 //			...
 //		]
 //		provisioner "" { ... }
+//		parallel {
+//			provisioner "" { ... }
+//		}
+//		cleanup { ... }
+//		replicate { ... }
+//		retry { ... }
 //		post-processor "" { ... }
 //	}
 type BuildBlock struct {
@@ -55,9 +82,24 @@ type BuildBlock struct {
 	Sources []SourceUseBlock
 
 	// ProvisionerBlocks references a list of HCL provisioner block that will
-	// will be ran against the sources.
+	// will be ran against the sources. The provisioners of a parallel block
+	// follow each other in the list.
 	ProvisionerBlocks []*ProvisionerBlock
 
+	// CleanupBlock is a shell provisioner block that runs after all the
+	// provisioners, right before the builder shuts the source down. It is nil
+	// when the build has no cleanup block.
+	CleanupBlock *ProvisionerBlock
+
+	// ReplicateBlock lists the targets the artifact of each source is copied
+	// to before the post-processors run. It is nil when the build has no
+	// replicate block.
+	ReplicateBlock *ReplicateBlock
+
+	// RetryBlock tells how many times a failed source is built again. It is
+	// nil when the build has no retry block.
+	RetryBlock *RetryBlock
+
 	// PostProcessorLists references the lists of lists of HCL post-processors
 	// block that will be run against the artifacts from the provisioning
 	// steps.
@@ -130,6 +172,68 @@ func (p *Parser) decodeBuildConfig(block *hcl.Block, cfg *PackerConfig) (*BuildB
 				continue
 			}
 			build.ProvisionerBlocks = append(build.ProvisionerBlocks, p)
+		case buildParallelLabel:
+			ps, moreDiags := p.decodeParallel(block, cfg)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			build.ProvisionerBlocks = append(build.ProvisionerBlocks, ps...)
+		case buildCleanupLabel:
+			if build.CleanupBlock != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate " + buildCleanupLabel + " block",
+					Detail: "A build can only have one " + buildCleanupLabel + " block, the first one is at " +
+						build.CleanupBlock.HCL2Ref.DefRange.String() + ".",
+					Subject: block.DefRange.Ptr(),
+				})
+				continue
+			}
+			// a cleanup block is the body of a shell provisioner
+			provisionerBlock := *block
+			provisionerBlock.Labels = []string{cleanupProvisionerType}
+			provisionerBlock.LabelRanges = []hcl.Range{block.TypeRange}
+			cleanup, moreDiags := p.decodeProvisioner(&provisionerBlock, cfg)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			build.CleanupBlock = cleanup
+		case buildReplicateLabel:
+			if build.ReplicateBlock != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate " + buildReplicateLabel + " block",
+					Detail: "A build can only have one " + buildReplicateLabel + " block, the first one is at " +
+						build.ReplicateBlock.HCL2Ref.DefRange.String() + ".",
+					Subject: block.DefRange.Ptr(),
+				})
+				continue
+			}
+			replicate, moreDiags := decodeReplicate(block, cfg)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			build.ReplicateBlock = replicate
+		case buildRetryLabel:
+			if build.RetryBlock != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate " + buildRetryLabel + " block",
+					Detail: "A build can only have one " + buildRetryLabel + " block, the first one is at " +
+						build.RetryBlock.HCL2Ref.DefRange.String() + ".",
+					Subject: block.DefRange.Ptr(),
+				})
+				continue
+			}
+			retry, moreDiags := decodeRetry(block, cfg)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+			build.RetryBlock = retry
 		case buildPostProcessorLabel:
 			pp, moreDiags := p.decodePostProcessor(block)
 			diags = append(diags, moreDiags...)
@@ -162,5 +266,8 @@ func (p *Parser) decodeBuildConfig(block *hcl.Block, cfg *PackerConfig) (*BuildB
 		}
 	}
 
+	build.ProvisionerBlocks, moreDiags = orderProvisioners(build.ProvisionerBlocks)
+	diags = append(diags, moreDiags...)
+
 	return build, diags
 }
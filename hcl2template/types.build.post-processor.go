@@ -6,6 +6,7 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
 // ProvisionerBlock references a detected but unparsed post processor
@@ -51,6 +52,38 @@ func (p *Parser) decodePostProcessor(block *hcl.Block) (*PostProcessorBlock, hcl
 	return postProcessor, diags
 }
 
+// decodeCombinedPostProcessors decodes a top-level post-processors block, a
+// chain of post-processors running on the artifacts of all the builds.
+func (p *Parser) decodeCombinedPostProcessors(block *hcl.Block) ([]*PostProcessorBlock, hcl.Diagnostics) {
+	content, diags := block.Body.Content(postProcessorsSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	var postProcessors []*PostProcessorBlock
+	for _, block := range content.Blocks {
+		pp, moreDiags := p.decodePostProcessor(block)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		if len(pp.OnlyExcept.Only) > 0 || len(pp.OnlyExcept.Except) > 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported only or except",
+				Detail: "The post-processors of a top-level " + postProcessorsLabel + " block run once, " +
+					"on the artifacts of all the builds, so they cannot be restricted to sources.",
+				Subject: pp.DefRange.Ptr(),
+			})
+			continue
+		}
+		postProcessors = append(postProcessors, pp)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return postProcessors, diags
+}
+
 func (cfg *PackerConfig) startPostProcessor(source SourceUseBlock, pp *PostProcessorBlock, ectx *hcl.EvalContext) (packersdk.PostProcessor, hcl.Diagnostics) {
 	// ProvisionerBlock represents a detected but unparsed provisioner
 	var diags hcl.Diagnostics
@@ -60,7 +93,7 @@ func (cfg *PackerConfig) startPostProcessor(source SourceUseBlock, pp *PostProce
 		diags = append(diags, &hcl.Diagnostic{
 			Summary: fmt.Sprintf("Failed loading %s", pp.PType),
 			Subject: pp.DefRange.Ptr(),
-			Detail:  err.Error(),
+			Detail:  messages.CodePluginStart.Annotate(err.Error()),
 		})
 		return nil, diags
 	}
@@ -70,6 +103,10 @@ func (cfg *PackerConfig) startPostProcessor(source SourceUseBlock, pp *PostProce
 		evalContext:        ectx,
 		builderVariables:   source.builderVariables(),
 	}
+	if cfg.schemaOnly {
+		_, moreDiags := decodeHCL2Spec(pp.HCL2Ref.Rest, ectx, postProcessor)
+		return hclPostProcessor, append(diags, moreDiags...)
+	}
 	err = hclPostProcessor.HCL2Prepare(nil)
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
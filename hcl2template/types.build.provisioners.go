@@ -2,12 +2,14 @@ package hcl2template
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	hcl2shim "github.com/hashicorp/packer/hcl2template/shim"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -66,6 +68,19 @@ type ProvisionerBlock struct {
 	Timeout     time.Duration
 	Override    map[string]interface{}
 	OnlyExcept  OnlyExcept
+	// After are the names of the provisioners this one runs after.
+	After []string
+	// Parallel is the parallel block of the provisioner, or nil.
+	Parallel *ParallelBlock
+	HCL2Ref
+}
+
+// ParallelBlock references an HCL 'parallel' block of a build, grouping
+// provisioners that run at the same time against the same guest.
+type ParallelBlock struct {
+	// MaxSessions is the maximum number of communicator sessions the
+	// provisioners use at the same time. Zero means no limit.
+	MaxSessions int
 	HCL2Ref
 }
 
@@ -82,6 +97,7 @@ func (p *Parser) decodeProvisioner(block *hcl.Block, cfg *PackerConfig) (*Provis
 		Only        []string  `hcl:"only,optional"`
 		Except      []string  `hcl:"except,optional"`
 		Override    cty.Value `hcl:"override,optional"`
+		After       []string  `hcl:"after,optional"`
 		Rest        hcl.Body  `hcl:",remain"`
 	}
 	diags := gohcl.DecodeBody(block.Body, cfg.EvalContext(nil), &b)
@@ -94,6 +110,7 @@ func (p *Parser) decodeProvisioner(block *hcl.Block, cfg *PackerConfig) (*Provis
 		PName:      b.Name,
 		MaxRetries: b.MaxRetries,
 		OnlyExcept: OnlyExcept{Only: b.Only, Except: b.Except},
+		After:      b.After,
 		HCL2Ref:    newHCL2Ref(block, b.Rest),
 	}
 
@@ -139,6 +156,166 @@ func (p *Parser) decodeProvisioner(block *hcl.Block, cfg *PackerConfig) (*Provis
 	return provisioner, diags
 }
 
+// decodeParallel decodes the provisioners of a parallel block.
+func (p *Parser) decodeParallel(block *hcl.Block, cfg *PackerConfig) ([]*ProvisionerBlock, hcl.Diagnostics) {
+	var b struct {
+		MaxSessions int      `hcl:"max_sessions,optional"`
+		Rest        hcl.Body `hcl:",remain"`
+	}
+	diags := gohcl.DecodeBody(block.Body, cfg.EvalContext(nil), &b)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if b.MaxSessions < 0 {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid max_sessions",
+			Detail:   "The max_sessions of a " + buildParallelLabel + " block cannot be negative.",
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+	content, moreDiags := b.Rest.Content(parallelSchema)
+	diags = append(diags, moreDiags...)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	parallel := &ParallelBlock{
+		MaxSessions: b.MaxSessions,
+		HCL2Ref:     newHCL2Ref(block, b.Rest),
+	}
+	var provisioners []*ProvisionerBlock
+	for _, block := range content.Blocks {
+		provisioner, moreDiags := p.decodeProvisioner(block, cfg)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		if len(provisioner.After) > 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported after",
+				Detail:   "The provisioners of a " + buildParallelLabel + " block run at the same time, they cannot be ordered with after.",
+				Subject:  provisioner.HCL2Ref.DefRange.Ptr(),
+			})
+			continue
+		}
+		provisioner.Parallel = parallel
+		provisioners = append(provisioners, provisioner)
+	}
+	return provisioners, diags
+}
+
+// orderProvisioners sorts the provisioners of a build so that each one runs
+// after the provisioners named in its after list. Provisioners keep the order
+// of the file otherwise, so the provisioners of a parallel block, which cannot
+// be ordered, still follow each other.
+func orderProvisioners(provisioners []*ProvisionerBlock) ([]*ProvisionerBlock, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	hasAfter := false
+	for _, p := range provisioners {
+		hasAfter = hasAfter || len(p.After) > 0
+	}
+	if !hasAfter {
+		return provisioners, nil
+	}
+
+	names := make([]string, len(provisioners))
+	afters := make([][]string, len(provisioners))
+	declared := map[string]bool{}
+	parallel := map[string]bool{}
+	for i, p := range provisioners {
+		names[i] = p.PName
+		afters[i] = p.After
+		if p.PName != "" {
+			declared[p.PName] = true
+			parallel[p.PName] = p.Parallel != nil
+		}
+	}
+	for _, p := range provisioners {
+		for _, name := range p.After {
+			if parallel[name] {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unsupported after",
+					Detail:   fmt.Sprintf("The provisioner %q runs in a %s block, it cannot be named in after.", name, buildParallelLabel),
+					Subject:  p.HCL2Ref.DefRange.Ptr(),
+				})
+				continue
+			}
+			if !declared[name] {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unknown provisioner in after",
+					Detail:   fmt.Sprintf("No provisioner of this build is named %q.", name),
+					Subject:  p.HCL2Ref.DefRange.Ptr(),
+				})
+			}
+		}
+	}
+	if diags.HasErrors() {
+		return provisioners, diags
+	}
+
+	order, cycle := afterOrder(names, afters)
+	if len(cycle) > 0 {
+		var cycleNames []string
+		for _, i := range cycle {
+			cycleNames = append(cycleNames, fmt.Sprintf("%q", names[i]))
+		}
+		return provisioners, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Provisioners depend on each other",
+			Detail: fmt.Sprintf("The provisioners %s cannot be ordered, they run after each other through their after lists.",
+				strings.Join(cycleNames, ", ")),
+			Subject: provisioners[cycle[0]].HCL2Ref.DefRange.Ptr(),
+		})
+	}
+	ordered := make([]*ProvisionerBlock, 0, len(provisioners))
+	for _, i := range order {
+		ordered = append(ordered, provisioners[i])
+	}
+	return ordered, diags
+}
+
+// afterOrder returns the order in which to run the steps named names, so that
+// each step runs after the steps named in its afters, and in the order of
+// names otherwise. A step does not depend on itself. When the steps cannot be
+// ordered, it returns the steps that depend on each other instead.
+func afterOrder(names []string, afters [][]string) (order []int, cycle []int) {
+	placed := make([]bool, len(names))
+	ready := func(i int) bool {
+		for _, after := range afters[i] {
+			for j, name := range names {
+				if j != i && name == after && !placed[j] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for len(order) < len(names) {
+		next := -1
+		for i := range names {
+			if !placed[i] && ready(i) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			for i := range names {
+				if !placed[i] {
+					cycle = append(cycle, i)
+				}
+			}
+			return nil, cycle
+		}
+		placed[next] = true
+		order = append(order, next)
+	}
+	return order, nil
+}
+
 func (cfg *PackerConfig) startProvisioner(source SourceUseBlock, pb *ProvisionerBlock, ectx *hcl.EvalContext) (packersdk.Provisioner, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
@@ -147,7 +324,7 @@ func (cfg *PackerConfig) startProvisioner(source SourceUseBlock, pb *Provisioner
 		diags = append(diags, &hcl.Diagnostic{
 			Summary: fmt.Sprintf("failed loading %s", pb.PType),
 			Subject: pb.HCL2Ref.LabelsRanges[0].Ptr(),
-			Detail:  err.Error(),
+			Detail:  messages.CodePluginStart.Annotate(err.Error()),
 		})
 		return nil, diags
 	}
@@ -165,6 +342,11 @@ func (cfg *PackerConfig) startProvisioner(source SourceUseBlock, pb *Provisioner
 		}
 	}
 
+	if cfg.schemaOnly {
+		_, moreDiags := decodeHCL2Spec(pb.HCL2Ref.Rest, ectx, provisioner)
+		return hclProvisioner, append(diags, moreDiags...)
+	}
+
 	err = hclProvisioner.HCL2Prepare(nil)
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
@@ -0,0 +1,78 @@
+package hcl2template
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+)
+
+// ReplicateBlock references an HCL 'replicate' block of a build, listing the
+// targets the builder copies its artifact to, for example the regions of a
+// cloud:
+//
+//	build {
+//		sources = ["source.amazon-ebs.example"]
+//		replicate {
+//			targets      = ["eu-west-1", "us-west-2"]
+//			max_parallel = 1
+//		}
+//	}
+type ReplicateBlock struct {
+	Targets []string
+	// MaxParallel is how many copies run at the same time. Zero means all of
+	// them.
+	MaxParallel int
+
+	HCL2Ref HCL2Ref
+}
+
+func decodeReplicate(block *hcl.Block, cfg *PackerConfig) (*ReplicateBlock, hcl.Diagnostics) {
+	var b struct {
+		Targets     []string `hcl:"targets"`
+		MaxParallel int      `hcl:"max_parallel,optional"`
+		Rest        hcl.Body `hcl:",remain"`
+	}
+	diags := gohcl.DecodeBody(block.Body, cfg.EvalContext(nil), &b)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	if b.MaxParallel < 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid max_parallel",
+			Detail:   "The max_parallel of a " + buildReplicateLabel + " block cannot be negative.",
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+	if len(b.Targets) == 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No replication targets",
+			Detail:   "The targets of a " + buildReplicateLabel + " block cannot be empty.",
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+	seen := map[string]bool{}
+	for _, target := range b.Targets {
+		if seen[target] {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate replication target",
+				Detail:   fmt.Sprintf("The artifact is already replicated to %q.", target),
+				Subject:  block.DefRange.Ptr(),
+			})
+		}
+		seen[target] = true
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return &ReplicateBlock{
+		Targets:     b.Targets,
+		MaxParallel: b.MaxParallel,
+		HCL2Ref:     newHCL2Ref(block, b.Rest),
+	}, diags
+}
@@ -0,0 +1,115 @@
+package hcl2template
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/packer/packer"
+)
+
+// RetryBlock references an HCL 'retry' block of a build, telling how many
+// times a failed source is built again from scratch:
+//
+//	build {
+//		sources = ["source.amazon-ebs.example"]
+//		retry {
+//			attempts    = 3
+//			min_backoff = "30s"
+//			max_backoff = "5m"
+//		}
+//	}
+type RetryBlock struct {
+	packer.BuildRetry
+
+	HCL2Ref HCL2Ref
+}
+
+var retryBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "attempts", Required: true},
+		{Name: "min_backoff"},
+		{Name: "max_backoff"},
+	},
+}
+
+func decodeRetry(block *hcl.Block, cfg *PackerConfig) (*RetryBlock, hcl.Diagnostics) {
+	content, rest, diags := block.Body.PartialContent(retryBlockSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	ectx := cfg.EvalContext(nil)
+
+	retry := &RetryBlock{
+		BuildRetry: packer.BuildRetry{
+			MinBackoff: packer.DefaultBuildRetryMinBackoff,
+			MaxBackoff: packer.DefaultBuildRetryMaxBackoff,
+		},
+		HCL2Ref: newHCL2Ref(block, rest),
+	}
+	attempts := content.Attributes["attempts"]
+	moreDiags := gohcl.DecodeExpression(attempts.Expr, ectx, &retry.Attempts)
+	diags = append(diags, moreDiags...)
+	if !moreDiags.HasErrors() && retry.Attempts < 1 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid attempts",
+			Detail:   "The attempts of a " + buildRetryLabel + " block must be at least 1, the first run included.",
+			Subject:  attempts.Expr.Range().Ptr(),
+		})
+	}
+	for _, setting := range []struct {
+		name string
+		out  *time.Duration
+	}{
+		{"min_backoff", &retry.MinBackoff},
+		{"max_backoff", &retry.MaxBackoff},
+	} {
+		attr, ok := content.Attributes[setting.name]
+		if !ok {
+			continue
+		}
+		var value string
+		moreDiags := gohcl.DecodeExpression(attr.Expr, ectx, &value)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err == nil && d < 0 {
+			err = fmt.Errorf("duration must be zero or positive")
+		}
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + setting.name,
+				Detail:   fmt.Sprintf("Could not parse %q, expected a duration like \"30s\": %s", value, err),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		*setting.out = d
+	}
+	// a default backoff gives way to the one that is set
+	_, minSet := content.Attributes["min_backoff"]
+	maxBackoff, maxSet := content.Attributes["max_backoff"]
+	switch {
+	case retry.MaxBackoff >= retry.MinBackoff:
+	case !maxSet:
+		retry.MaxBackoff = retry.MinBackoff
+	case !minSet:
+		retry.MinBackoff = retry.MaxBackoff
+	default:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid max_backoff",
+			Detail:   "The max_backoff of a " + buildRetryLabel + " block cannot be shorter than its min_backoff.",
+			Subject:  maxBackoff.Expr.Range().Ptr(),
+		})
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return retry, diags
+}
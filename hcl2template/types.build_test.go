@@ -2,8 +2,11 @@ package hcl2template
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	. "github.com/hashicorp/packer/hcl2template/internal"
 	"github.com/hashicorp/packer/packer"
@@ -218,6 +221,7 @@ func TestParse_build(t *testing.T) {
 			[]packersdk.Build{
 				&packer.CoreBuild{
 					Type:         "virtualbox-iso.ubuntu-1204",
+					BuilderType:  "virtualbox-iso",
 					Prepared:     true,
 					Builder:      emptyMockBuilder,
 					Provisioners: []packer.CoreBuildProvisioner{},
@@ -252,6 +256,7 @@ func TestParse_build(t *testing.T) {
 				},
 				&packer.CoreBuild{
 					Type:         "amazon-ebs.aws-ubuntu-16.04",
+					BuilderType:  "amazon-ebs",
 					Prepared:     true,
 					Builder:      emptyMockBuilder,
 					Provisioners: []packer.CoreBuildProvisioner{},
@@ -332,9 +337,10 @@ func TestParse_build(t *testing.T) {
 			false, false,
 			[]packersdk.Build{
 				&packer.CoreBuild{
-					Type:     "virtualbox-iso.ubuntu-1204",
-					Prepared: true,
-					Builder:  emptyMockBuilder,
+					Type:        "virtualbox-iso.ubuntu-1204",
+					BuilderType: "virtualbox-iso",
+					Prepared:    true,
+					Builder:     emptyMockBuilder,
 					Provisioners: []packer.CoreBuildProvisioner{
 						{
 							PType: "shell",
@@ -362,9 +368,10 @@ func TestParse_build(t *testing.T) {
 					PostProcessors: [][]packer.CoreBuildPostProcessor{},
 				},
 				&packer.CoreBuild{
-					Type:     "amazon-ebs.aws-ubuntu-16.04",
-					Prepared: true,
-					Builder:  emptyMockBuilder,
+					Type:        "amazon-ebs.aws-ubuntu-16.04",
+					BuilderType: "amazon-ebs",
+					Prepared:    true,
+					Builder:     emptyMockBuilder,
 					Provisioners: []packer.CoreBuildProvisioner{
 						{
 							PType: "file",
@@ -394,6 +401,481 @@ func TestParse_build(t *testing.T) {
 			},
 			false,
 		},
+		{"cleanup block",
+			defaultParser,
+			parseTestArgs{"testdata/build/cleanup.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+						ProvisionerBlocks: []*ProvisionerBlock{
+							{
+								PType: "file",
+							},
+						},
+						CleanupBlock: &ProvisionerBlock{
+							PType: "shell",
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:        "virtualbox-iso.ubuntu-1204",
+					BuilderType: "virtualbox-iso",
+					Prepared:    true,
+					Builder:     emptyMockBuilder,
+					Provisioners: []packer.CoreBuildProvisioner{
+						{
+							PType: "file",
+							Provisioner: &HCL2Provisioner{
+								Provisioner: &MockProvisioner{
+									Config: MockConfig{
+										NestedMockConfig: NestedMockConfig{Tags: []MockTag{}},
+										NestedSlice:      []NestedMockConfig{},
+									},
+								},
+							},
+						},
+						{
+							PType: "shell",
+							Provisioner: &HCL2Provisioner{
+								Provisioner: &MockProvisioner{
+									Config: MockConfig{
+										NestedMockConfig: NestedMockConfig{
+											SliceString: []string{"rm -f /etc/ssh/ssh_host_*"},
+											Tags:        []MockTag{},
+										},
+										NestedSlice: []NestedMockConfig{},
+									},
+								},
+							},
+						},
+					},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+			},
+			false,
+		},
+		{"duplicate cleanup block",
+			defaultParser,
+			parseTestArgs{"testdata/build/cleanup_duplicate.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Builds:                  nil,
+			},
+			true, true,
+			nil,
+			false,
+		},
+		{"parallel block",
+			defaultParser,
+			parseTestArgs{"testdata/build/parallel.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+						ProvisionerBlocks: []*ProvisionerBlock{
+							{
+								PType:    "file",
+								Parallel: &ParallelBlock{MaxSessions: 2},
+							},
+							{
+								PType:    "file",
+								Parallel: &ParallelBlock{MaxSessions: 2},
+							},
+							{
+								PType: "shell",
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:        "virtualbox-iso.ubuntu-1204",
+					BuilderType: "virtualbox-iso",
+					Prepared:    true,
+					Builder:     emptyMockBuilder,
+					Provisioners: []packer.CoreBuildProvisioner{
+						{
+							PType: "file",
+							Provisioner: &HCL2Provisioner{
+								Provisioner: &MockProvisioner{
+									Config: MockConfig{
+										NestedMockConfig: NestedMockConfig{Tags: []MockTag{}},
+										NestedSlice:      []NestedMockConfig{},
+									},
+								},
+							},
+							Parallel: &packer.ParallelGroup{MaxSessions: 2},
+						},
+						{
+							PType: "file",
+							Provisioner: &HCL2Provisioner{
+								Provisioner: &MockProvisioner{
+									Config: MockConfig{
+										NestedMockConfig: NestedMockConfig{Tags: []MockTag{}},
+										NestedSlice:      []NestedMockConfig{},
+									},
+								},
+							},
+							Parallel: &packer.ParallelGroup{MaxSessions: 2},
+						},
+						{
+							PType: "shell",
+							Provisioner: &HCL2Provisioner{
+								Provisioner: &MockProvisioner{
+									Config: MockConfig{
+										NestedMockConfig: NestedMockConfig{Tags: []MockTag{}},
+										NestedSlice:      []NestedMockConfig{},
+									},
+								},
+							},
+						},
+					},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+			},
+			false,
+		},
+		{"after a provisioner of a parallel block",
+			defaultParser,
+			parseTestArgs{"testdata/build/parallel_after.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: nil,
+			},
+			true, true,
+			nil,
+			false,
+		},
+		{"replicate block",
+			defaultParser,
+			parseTestArgs{"testdata/build/replicate.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+						ReplicateBlock: &ReplicateBlock{
+							Targets:     []string{"eu-west-1", "us-west-2"},
+							MaxParallel: 1,
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			true,
+		},
+		{"replicate block with a duplicate target",
+			defaultParser,
+			parseTestArgs{"testdata/build/replicate_duplicate.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: nil,
+			},
+			true, true,
+			nil,
+			false,
+		},
+		{"retry block",
+			defaultParser,
+			parseTestArgs{"testdata/build/retry.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+						RetryBlock: &RetryBlock{
+							BuildRetry: packer.BuildRetry{
+								Attempts:   3,
+								MinBackoff: 10 * time.Second,
+								MaxBackoff: packer.DefaultBuildRetryMaxBackoff,
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:           "virtualbox-iso.ubuntu-1204",
+					BuilderType:    "virtualbox-iso",
+					Prepared:       true,
+					Builder:        emptyMockBuilder,
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+					Retry: &packer.BuildRetry{
+						Attempts:   3,
+						MinBackoff: 10 * time.Second,
+						MaxBackoff: packer.DefaultBuildRetryMaxBackoff,
+					},
+				},
+			},
+			false,
+		},
+		{"retry block with a max_backoff shorter than its min_backoff",
+			defaultParser,
+			parseTestArgs{"testdata/build/retry_invalid_backoff.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: nil,
+			},
+			true, true,
+			nil,
+			false,
+		},
+		{"top-level post-processors block",
+			defaultParser,
+			parseTestArgs{"testdata/build/post-processors_combined.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+					},
+				},
+				CombinedPostProcessors: [][]*PostProcessorBlock{
+					{
+						{
+							PType: "manifest",
+							PName: "combined",
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:           "virtualbox-iso.ubuntu-1204",
+					BuilderType:    "virtualbox-iso",
+					Prepared:       true,
+					Builder:        emptyMockBuilder,
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+			},
+			false,
+		},
+		{"top-level post-processors block with only",
+			defaultParser,
+			parseTestArgs{"testdata/build/post-processors_combined_only.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "build"),
+				Sources: map[SourceRef]SourceBlock{
+					refVBIsoUbuntu1204: {Type: "virtualbox-iso", Name: "ubuntu-1204"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: refVBIsoUbuntu1204,
+							},
+						},
+					},
+				},
+			},
+			true, true,
+			nil,
+			false,
+		},
 	}
 	testParse(t, tests)
 }
+
+func Test_orderProvisioners(t *testing.T) {
+	provisioners := func(specs ...[]string) []*ProvisionerBlock {
+		var res []*ProvisionerBlock
+		for _, spec := range specs {
+			res = append(res, &ProvisionerBlock{PType: "shell", PName: spec[0], After: spec[1:]})
+		}
+		return res
+	}
+	names := func(pbs []*ProvisionerBlock) []string {
+		var res []string
+		for _, pb := range pbs {
+			res = append(res, pb.PName)
+		}
+		return res
+	}
+
+	tc := []struct {
+		name         string
+		provisioners []*ProvisionerBlock
+		expected     []string
+		wantErr      bool
+	}{
+		{
+			name:         "no after",
+			provisioners: provisioners([]string{"b"}, []string{"a"}),
+			expected:     []string{"b", "a"},
+		},
+		{
+			name:         "after a later provisioner",
+			provisioners: provisioners([]string{"configure", "install-docker"}, []string{"download"}, []string{"install-docker"}),
+			expected:     []string{"download", "install-docker", "configure"},
+		},
+		{
+			name:         "after itself",
+			provisioners: provisioners([]string{"a", "a"}, []string{"b"}),
+			expected:     []string{"a", "b"},
+		},
+		{
+			name:         "unknown provisioner",
+			provisioners: provisioners([]string{"a", "nope"}),
+			wantErr:      true,
+		},
+		{
+			name:         "cycle",
+			provisioners: provisioners([]string{"a", "b"}, []string{"b", "a"}, []string{"c"}),
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, diags := orderProvisioners(tt.provisioners)
+			if diags.HasErrors() != tt.wantErr {
+				t.Fatalf("orderProvisioners() diags = %s, wantErr %t", diags, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, names(ordered)); diff != "" {
+				t.Errorf("unexpected order: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParse_retry_diagnostics(t *testing.T) {
+	tc := []struct {
+		name   string
+		retry  string
+		line   int
+		column int
+	}{
+		{"attempts below 1", `attempts = 0`, 3, 16},
+		{"negative backoff", "attempts = 2\n    min_backoff = \"-1s\"", 4, 19},
+		{"max_backoff shorter than min_backoff", "attempts = 2\n    min_backoff = \"10m\"\n    max_backoff = \"1m\"", 5, 19},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diags := getBasicParser().ParseSource([]byte(`build {
+  retry {
+    `+tt.retry+`
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error")
+			}
+			// the error points at the value of the attribute
+			if subject := diags[0].Subject; subject == nil || subject.Start.Line != tt.line || subject.Start.Column != tt.column {
+				t.Errorf("got subject %v, expected line %d column %d", subject, tt.line, tt.column)
+			}
+		})
+	}
+}
+
+func TestParse_retry_default_backoffs(t *testing.T) {
+	tc := []struct {
+		name     string
+		retry    string
+		min, max time.Duration
+	}{
+		{"defaults", `attempts = 2`, packer.DefaultBuildRetryMinBackoff, packer.DefaultBuildRetryMaxBackoff},
+		{"min_backoff over the default max_backoff", "attempts = 2\n    min_backoff = \"10m\"", 10 * time.Minute, 10 * time.Minute},
+		{"max_backoff under the default min_backoff", "attempts = 2\n    max_backoff = \"10s\"", 10 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, diags := getBasicParser().ParseSource([]byte(`build {
+  retry {
+    `+tt.retry+`
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+			if diags.HasErrors() {
+				t.Fatalf("Parse: %s", diags)
+			}
+			retry := cfg.Builds[0].RetryBlock
+			if retry.MinBackoff != tt.min || retry.MaxBackoff != tt.max {
+				t.Errorf("got backoffs %s-%s, expected %s-%s", retry.MinBackoff, retry.MaxBackoff, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestGetBuilds_replicate_unsupported(t *testing.T) {
+	cfg, diags := getBasicParser().Parse("testdata/build/replicate.pkr.hcl", nil, nil)
+	diags = append(diags, cfg.Initialize(packer.InitializeOptions{})...)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	_, diags = cfg.GetBuilds(packer.GetBuildsOptions{})
+	if !diags.HasErrors() {
+		t.Fatal("expected an error, the mock builder cannot replicate its artifacts")
+	}
+	// the error tells which builders can
+	if detail := diags[0].Detail; !strings.Contains(detail, "supporting the replicate block are: file;") {
+		t.Errorf("the error must list the builders supporting the replicate block: %s", detail)
+	}
+}
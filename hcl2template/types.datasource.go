@@ -2,12 +2,14 @@ package hcl2template
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	hcl2shim "github.com/hashicorp/packer/hcl2template/shim"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -18,6 +20,8 @@ type Datasource struct {
 
 	value cty.Value
 	block *hcl.Block
+	// execution is set by the execution block of the data source.
+	execution datasourceExecution
 }
 
 type DatasourceRef struct {
@@ -53,7 +57,10 @@ func (ds *Datasources) Values() (map[string]cty.Value, hcl.Diagnostics) {
 			inner = map[string]cty.Value{}
 		}
 		inner[ref.Name] = datasource.value
-		res[ref.Type] = cty.MapVal(inner)
+		// An object rather than a map, so that two data sources of the same
+		// type can output values of different types; e.g. a list of one
+		// image and a list of three.
+		res[ref.Type] = cty.ObjectVal(inner)
 
 		// Keeps values of different datasources from same type
 		valuesMap[ref.Type] = inner
@@ -70,7 +77,7 @@ func (cfg *PackerConfig) startDatasource(dataSourceStore packer.DatasourceStore,
 		diags = append(diags, &hcl.Diagnostic{
 			Summary:  "Unknown " + dataSourceLabel + " type " + ref.Type,
 			Subject:  block.LabelRanges[0].Ptr(),
-			Detail:   fmt.Sprintf("packer does not currently know any data source."),
+			Detail:   messages.CodeUnknownComponent.Annotate(fmt.Sprintf("packer does not currently know any data source.")),
 			Severity: hcl.DiagError,
 		})
 		return nil, diags
@@ -80,7 +87,7 @@ func (cfg *PackerConfig) startDatasource(dataSourceStore packer.DatasourceStore,
 		diags = append(diags, &hcl.Diagnostic{
 			Summary:  "Unknown " + dataSourceLabel + " type " + ref.Type,
 			Subject:  block.LabelRanges[0].Ptr(),
-			Detail:   fmt.Sprintf("known data sources: %v", dataSourceStore.List()),
+			Detail:   messages.CodeUnknownComponent.Annotate(fmt.Sprintf("known data sources: %v", dataSourceStore.List())),
 			Severity: hcl.DiagError,
 		})
 		return nil, diags
@@ -125,13 +132,38 @@ func (cfg *PackerConfig) startDatasource(dataSourceStore packer.DatasourceStore,
 }
 
 func (p *Parser) decodeDataBlock(block *hcl.Block) (*Datasource, hcl.Diagnostics) {
-	var diags hcl.Diagnostics
 	r := &Datasource{
 		Type:  block.Labels[0],
 		Name:  block.Labels[1],
 		block: block,
 	}
 
+	content, rest, diags := block.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: datasourceExecutionLabel}},
+	})
+	if diags.HasErrors() {
+		return r, diags
+	}
+	// the execution block is for packer only, the data source gets the
+	// rest.
+	withoutExecution := *block
+	withoutExecution.Body = rest
+	r.block = &withoutExecution
+	for i, execution := range content.Blocks {
+		if i > 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate " + datasourceExecutionLabel + " block",
+				Detail:   "A data source can only have one " + datasourceExecutionLabel + " block.",
+				Subject:  execution.DefRange.Ptr(),
+			})
+			continue
+		}
+		var moreDiags hcl.Diagnostics
+		r.execution, moreDiags = decodeExecutionBlock(execution)
+		diags = append(diags, moreDiags...)
+	}
+
 	if !hclsyntax.ValidIdentifier(r.Type) {
 		diags = append(diags, &hcl.Diagnostic{
 			Severity: hcl.DiagError,
@@ -151,3 +183,48 @@ func (p *Parser) decodeDataBlock(block *hcl.Block) (*Datasource, hcl.Diagnostics
 
 	return r, diags
 }
+
+// bodyVariables returns all the variables referenced in body and its nested
+// blocks. Bodies that are not native syntax are only inspected for
+// attributes.
+func bodyVariables(body hcl.Body) []hcl.Traversal {
+	var res []hcl.Traversal
+	switch b := body.(type) {
+	case *hclsyntax.Body:
+		names := make([]string, 0, len(b.Attributes))
+		for name := range b.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res = append(res, b.Attributes[name].Expr.Variables()...)
+		}
+		for _, block := range b.Blocks {
+			res = append(res, bodyVariables(block.Body)...)
+		}
+	case *overrideBody:
+		res = append(bodyVariables(b.base), bodyVariables(b.override)...)
+	default:
+		attrs, _ := body.JustAttributes()
+		for _, attr := range attrs {
+			res = append(res, attr.Expr.Variables()...)
+		}
+	}
+	return res
+}
+
+// sortedRefs returns the references of all data sources, sorted by type and
+// name, so that evaluation order does not depend on map iteration.
+func (ds Datasources) sortedRefs() []DatasourceRef {
+	refs := make([]DatasourceRef, 0, len(ds))
+	for ref := range ds {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Type != refs[j].Type {
+			return refs[i].Type < refs[j].Type
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
+}
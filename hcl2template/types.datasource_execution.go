@@ -0,0 +1,201 @@
+package hcl2template
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	datasourceExecutionLabel = "execution"
+
+	// defaultDatasourceTimeout is how long a data source with an execution
+	// block may take to execute when the block does not say otherwise, so
+	// that a hanging API call cannot block packer forever.
+	defaultDatasourceTimeout = 10 * time.Minute
+	// defaultDatasourceRetryBackoff is how long to wait before retrying a
+	// data source the first time.
+	defaultDatasourceRetryBackoff = 2 * time.Second
+)
+
+// datasourceExecution tells how packer executes a data source: how long an
+// attempt may take, and how many times a failed attempt is retried. Without
+// an execution block, a data source is executed once, without time limit.
+type datasourceExecution struct {
+	// Timeout is how long an attempt may take, 0 means no limit.
+	Timeout time.Duration
+	// MaxRetries is the number of times a failed attempt is retried.
+	MaxRetries int
+	// RetryBackoff is how long to wait before the first retry, doubling
+	// with every retry.
+	RetryBackoff time.Duration
+}
+
+var executionBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "timeout"},
+		{Name: "max_retries"},
+		{Name: "retry_backoff"},
+	},
+}
+
+// decodeExecutionBlock decodes an 'execution' block in a data source:
+//  data "amazon-ami" "example" {
+//    execution {
+//      timeout       = "2m"
+//      max_retries   = 3
+//      retry_backoff = "5s"
+//    }
+//  }
+func decodeExecutionBlock(block *hcl.Block) (datasourceExecution, hcl.Diagnostics) {
+	res := datasourceExecution{
+		Timeout:      defaultDatasourceTimeout,
+		RetryBackoff: defaultDatasourceRetryBackoff,
+	}
+	content, diags := block.Body.Content(executionBlockSchema)
+
+	if attr, ok := content.Attributes["max_retries"]; ok {
+		moreDiags := gohcl.DecodeExpression(attr.Expr, nil, &res.MaxRetries)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() && res.MaxRetries < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + datasourceExecutionLabel + " max_retries",
+				Detail:   "max_retries must be zero or positive.",
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+		}
+	}
+	for _, setting := range []struct {
+		name string
+		out  *time.Duration
+	}{
+		{"timeout", &res.Timeout},
+		{"retry_backoff", &res.RetryBackoff},
+	} {
+		attr, ok := content.Attributes[setting.name]
+		if !ok {
+			continue
+		}
+		var value string
+		moreDiags := gohcl.DecodeExpression(attr.Expr, nil, &value)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err == nil && d < 0 {
+			err = fmt.Errorf("duration must be zero or positive")
+		}
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + datasourceExecutionLabel + " " + setting.name,
+				Detail:   fmt.Sprintf("Could not parse %q, expected a duration like \"10m\": %s", value, err),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		*setting.out = d
+	}
+	return res, diags
+}
+
+// datasourceTimeoutError is the error of an attempt to execute a data source
+// that timed out.
+type datasourceTimeoutError struct {
+	name    string
+	timeout time.Duration
+}
+
+func (e *datasourceTimeoutError) Error() string {
+	return fmt.Sprintf("data source %s did not complete within %s; "+
+		"the timeout of its %s block can be raised", e.name, e.timeout, datasourceExecutionLabel)
+}
+
+// killableDatasource is a data source running as a plugin, which can be
+// killed to end a call that takes too long.
+type killableDatasource interface {
+	packersdk.Datasource
+	Kill()
+}
+
+// execute executes the data source name, retrying the attempts that fail or
+// time out, until ctx is done. The Execute call of a data source cannot be
+// cancelled: when an attempt times out, the plugin of the data source is
+// killed, ending the call, and start starts the data source again for the
+// next attempt. A data source that cannot be killed is not retried after a
+// timeout, so that two calls never run at the same time.
+func (e datasourceExecution) execute(ctx context.Context, name string, datasource packersdk.Datasource, start func() (packersdk.Datasource, hcl.Diagnostics)) (cty.Value, error) {
+	backoff := e.RetryBackoff
+	for try := 0; ; try++ {
+		value, err := e.attempt(ctx, name, datasource)
+		if err == nil {
+			return value, nil
+		}
+		if ctx.Err() != nil {
+			return cty.NilVal, ctx.Err()
+		}
+		if _, timedOut := err.(*datasourceTimeoutError); timedOut && try < e.MaxRetries {
+			killable, ok := datasource.(killableDatasource)
+			if !ok {
+				return value, err
+			}
+			log.Printf("[WARN] Killing the plugin of data source %s, which timed out", name)
+			killable.Kill()
+			var diags hcl.Diagnostics
+			if datasource, diags = start(); diags.HasErrors() {
+				return cty.NilVal, fmt.Errorf("%s, then failed to restart: %s", err, diags)
+			}
+		}
+		if try >= e.MaxRetries {
+			if e.MaxRetries > 0 {
+				err = fmt.Errorf("%s, after %d attempts", err, try+1)
+			}
+			return value, err
+		}
+		log.Printf("[WARN] Data source %s failed: %s. Retrying in %s, %d retries left",
+			name, err, backoff, e.MaxRetries-try)
+		select {
+		case <-ctx.Done():
+			return cty.NilVal, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt executes the data source once, giving up after Timeout or when ctx
+// is done, leaving the call running.
+func (e datasourceExecution) attempt(ctx context.Context, name string, datasource packersdk.Datasource) (cty.Value, error) {
+	type result struct {
+		value cty.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := datasource.Execute()
+		done <- result{value, err}
+	}()
+
+	var timeout <-chan time.Time
+	if e.Timeout > 0 {
+		timer := time.NewTimer(e.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return cty.NilVal, ctx.Err()
+	case <-timeout:
+		return cty.NilVal, &datasourceTimeoutError{name: name, timeout: e.Timeout}
+	}
+}
@@ -1,10 +1,17 @@
 package hcl2template
 
 import (
+	"context"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestParse_datasource(t *testing.T) {
@@ -93,6 +100,285 @@ func TestParse_datasource(t *testing.T) {
 			nil,
 			false,
 		},
+		{"list outputs used in for and splat expressions",
+			defaultParser,
+			parseTestArgs{"testdata/datasources/lists.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "datasources"),
+				Datasources: Datasources{
+					{
+						Type: "amazon-ami",
+						Name: "filtered",
+					}: {
+						Type: "amazon-ami",
+						Name: "filtered",
+					},
+					{
+						Type: "amazon-ami",
+						Name: "images",
+					}: {
+						Type: "amazon-ami",
+						Name: "images",
+					},
+				},
+				LocalVariables: Variables{
+					"names": &Variable{
+						Name: "names",
+						Values: []VariableAssignment{{
+							From: "default",
+							Value: cty.ListVal([]cty.Value{
+								cty.StringVal("ubuntu-20.04-20210101"),
+								cty.StringVal("ubuntu-20.04-20210301"),
+								cty.StringVal("ubuntu-20.04-20210201"),
+							}),
+						}},
+						Type: cty.List(cty.String),
+					},
+					"newest": &Variable{
+						Name: "newest",
+						Values: []VariableAssignment{{
+							From:  "default",
+							Value: cty.StringVal("ubuntu-20.04-20210301"),
+						}},
+						Type: cty.String,
+					},
+					"usable": &Variable{
+						Name: "usable",
+						Values: []VariableAssignment{{
+							From: "default",
+							Value: cty.ListVal([]cty.Value{
+								cty.StringVal("ubuntu-20.04-20210101"),
+								cty.StringVal("ubuntu-20.04-20210301"),
+							}),
+						}},
+						Type: cty.List(cty.String),
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			false,
+		},
+		{"cyclic datasources",
+			defaultParser,
+			parseTestArgs{"testdata/datasources/cyclic.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "datasources"),
+				Datasources: Datasources{
+					{
+						Type: "amazon-ami",
+						Name: "first",
+					}: {
+						Type: "amazon-ami",
+						Name: "first",
+					},
+					{
+						Type: "amazon-ami",
+						Name: "second",
+					}: {
+						Type: "amazon-ami",
+						Name: "second",
+					},
+				},
+			},
+			true, true,
+			nil,
+			false,
+		},
+		{"datasource and locals depending on each other and on a -var",
+			defaultParser,
+			parseTestArgs{"testdata/datasources/locals.pkr.hcl", map[string]string{"distribution": "debian"}, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "datasources"),
+				InputVariables: Variables{
+					"distribution": &Variable{
+						Name: "distribution",
+						Type: cty.String,
+						Values: []VariableAssignment{
+							{From: "default", Value: cty.StringVal("ubuntu")},
+							{From: "cmd", Value: cty.StringVal("debian")},
+						},
+					},
+				},
+				LocalVariables: Variables{
+					"image_name": &Variable{
+						Name: "image_name",
+						Values: []VariableAssignment{{
+							From:  "default",
+							Value: cty.StringVal("debian-20.04"),
+						}},
+						Type: cty.String,
+					},
+					"image": &Variable{
+						Name: "image",
+						Values: []VariableAssignment{{
+							From:  "default",
+							Value: cty.StringVal("debian-20.04"),
+						}},
+						Type: cty.String,
+					},
+				},
+				Datasources: Datasources{
+					{
+						Type: "amazon-ami",
+						Name: "test",
+					}: {
+						Type: "amazon-ami",
+						Name: "test",
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			false,
+		},
+		{"cyclic datasource and locals",
+			defaultParser,
+			parseTestArgs{"testdata/datasources/cyclic_locals.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "datasources"),
+				LocalVariables:          Variables{},
+				Datasources: Datasources{
+					{
+						Type: "amazon-ami",
+						Name: "test",
+					}: {
+						Type: "amazon-ami",
+						Name: "test",
+					},
+				},
+			},
+			true, true,
+			nil,
+			false,
+		},
 	}
 	testParse(t, tests)
 }
+
+func TestParse_datasourceExecution(t *testing.T) {
+	cfg, diags := getBasicParser().ParseSource([]byte(`data "amazon-ami" "test" {
+  string = "string"
+  execution {
+    timeout     = "30s"
+    max_retries = 2
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	ds := cfg.Datasources[DatasourceRef{Type: "amazon-ami", Name: "test"}]
+	expected := datasourceExecution{Timeout: 30 * time.Second, MaxRetries: 2, RetryBackoff: defaultDatasourceRetryBackoff}
+	if ds.execution != expected {
+		t.Errorf("got %#v, expected %#v", ds.execution, expected)
+	}
+	if diags := cfg.Initialize(packer.InitializeOptions{}); diags.HasErrors() {
+		t.Fatalf("the execution block must not be given to the data source: %s", diags)
+	}
+
+	// without an execution block, there is no time limit
+	cfg, diags = getBasicParser().ParseSource([]byte(`data "amazon-ami" "test" {
+  string = "string"
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	if ds := cfg.Datasources[DatasourceRef{Type: "amazon-ami", Name: "test"}]; ds.execution != (datasourceExecution{}) {
+		t.Errorf("got %#v, expected no time limit nor retries", ds.execution)
+	}
+
+	_, diags = getBasicParser().ParseSource([]byte(`data "amazon-ami" "test" {
+  execution {
+    timeout = "soon"
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error, the timeout is not a duration")
+	}
+	// the error points at the value of the attribute
+	if subject := diags[0].Subject; subject == nil || subject.Start.Line != 3 || subject.Start.Column != 15 {
+		t.Errorf("unexpected subject %v: %s", subject, diags)
+	}
+}
+
+// flakyDatasource fails until it was executed failures times, and takes
+// delay to execute.
+type flakyDatasource struct {
+	packersdk.Datasource
+	failures int
+	delay    time.Duration
+
+	executions int
+}
+
+func (d *flakyDatasource) Execute() (cty.Value, error) {
+	d.executions++
+	time.Sleep(d.delay)
+	if d.executions <= d.failures {
+		return cty.NilVal, fmt.Errorf("failure %d", d.executions)
+	}
+	return cty.StringVal("ok"), nil
+}
+
+// killableFlakyDatasource is a flakyDatasource running as a plugin.
+type killableFlakyDatasource struct {
+	*flakyDatasource
+	killed bool
+}
+
+func (d *killableFlakyDatasource) Kill() {
+	d.killed = true
+}
+
+func TestDatasourceExecution_execute(t *testing.T) {
+	ctx := context.Background()
+	retried := datasourceExecution{MaxRetries: 2, RetryBackoff: time.Millisecond}
+	noRestart := func() (packersdk.Datasource, hcl.Diagnostics) {
+		t.Fatal("unexpected restart")
+		return nil, nil
+	}
+
+	ds := &flakyDatasource{failures: 2}
+	if value, err := retried.execute(ctx, "data.flaky.test", ds, noRestart); err != nil || value != cty.StringVal("ok") {
+		t.Fatalf("expected the third attempt to succeed, got %#v, %v", value, err)
+	}
+
+	ds = &flakyDatasource{failures: 3}
+	if _, err := retried.execute(ctx, "data.flaky.test", ds, noRestart); err == nil || err.Error() != "failure 3, after 3 attempts" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ds = &flakyDatasource{delay: time.Second}
+	timedOut := datasourceExecution{Timeout: 10 * time.Millisecond, MaxRetries: 1}
+	if _, err := timedOut.execute(ctx, "data.flaky.test", ds, noRestart); err == nil || !strings.Contains(err.Error(), "did not complete within 10ms") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if ds.executions != 1 {
+		t.Fatalf("a data source that cannot be killed must not be retried after a timeout, executed %d times", ds.executions)
+	}
+
+	// a plugin timing out is killed, and started again for the next attempt
+	plugin := &killableFlakyDatasource{flakyDatasource: &flakyDatasource{delay: time.Second}}
+	restarted := &flakyDatasource{}
+	restart := func() (packersdk.Datasource, hcl.Diagnostics) {
+		return restarted, nil
+	}
+	if value, err := timedOut.execute(ctx, "data.flaky.test", plugin, restart); err != nil || value != cty.StringVal("ok") {
+		t.Fatalf("expected the restarted data source to succeed, got %#v, %v", value, err)
+	}
+	if !plugin.killed || restarted.executions != 1 {
+		t.Fatalf("expected the plugin to be killed and the data source restarted: %t, %d", plugin.killed, restarted.executions)
+	}
+
+	// an interrupted packer stops waiting to retry
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	slow := datasourceExecution{MaxRetries: 1, RetryBackoff: time.Hour}
+	if _, err := slow.execute(ctx, "data.flaky.test", &flakyDatasource{failures: 1}, noRestart); err != context.Canceled {
+		t.Fatalf("expected the execution to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package hcl2template
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// namedValue is a value computed while initializing a config: either a local
+// variable or the output of a data source. Input variables are not named
+// values since they are set before anything else and cannot reference one.
+type namedValue struct {
+	local string
+	data  DatasourceRef
+}
+
+func (v namedValue) String() string {
+	if v.local != "" {
+		return localsAccessor + "." + v.local
+	}
+	return fmt.Sprintf("%s.%s.%s", dataAccessor, v.data.Type, v.data.Name)
+}
+
+// namedValueReference is a reference to a named value from an expression.
+type namedValueReference struct {
+	value namedValue
+	rng   hcl.Range
+}
+
+// namedValueReferences returns the locals and data sources referenced by
+// traversals, in order.
+func namedValueReferences(traversals []hcl.Traversal) []namedValueReference {
+	var res []namedValueReference
+	for _, traversal := range traversals {
+		switch traversal.RootName() {
+		case localsAccessor:
+			if len(traversal) < 2 {
+				continue
+			}
+			if name, ok := traversal[1].(hcl.TraverseAttr); ok {
+				res = append(res, namedValueReference{
+					value: namedValue{local: name.Name},
+					rng:   traversal.SourceRange(),
+				})
+			}
+		case dataAccessor:
+			if len(traversal) < 3 {
+				continue
+			}
+			typ, typOk := traversal[1].(hcl.TraverseAttr)
+			name, nameOk := traversal[2].(hcl.TraverseAttr)
+			if typOk && nameOk {
+				res = append(res, namedValueReference{
+					value: namedValue{data: DatasourceRef{Type: typ.Name, Name: name.Name}},
+					rng:   traversal.SourceRange(),
+				})
+			}
+		}
+	}
+	return res
+}
+
+// cycleDetail describes every hop of the cycle ending with a reference back
+// to the first value of path.
+func cycleDetail(path []namedValueReference) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s depends on itself:", path[0].value)
+	for i, hop := range path {
+		next := path[0].value
+		if i+1 < len(path) {
+			next = path[i+1].value
+		}
+		fmt.Fprintf(&b, "\n  %s:%d: %s references %s",
+			hop.rng.Filename, hop.rng.Start.Line, hop.value, next)
+	}
+	return b.String()
+}
+
+// evaluateLocalsAndDatasources evaluates all local variables and data
+// sources. Locals and data sources can reference input variables and each
+// other; a value is always evaluated after the values it references so the
+// order in which they are declared does not matter. References forming a
+// cycle are reported as an error.
+func (cfg *PackerConfig) evaluateLocalsAndDatasources(ctx context.Context, skipExecution bool) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	if len(cfg.LocalBlocks) > 0 && cfg.LocalVariables == nil {
+		cfg.LocalVariables = Variables{}
+	}
+
+	locals := map[string]*LocalBlock{}
+	for _, local := range cfg.LocalBlocks {
+		locals[local.Name] = local
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[namedValue]int{}
+	failed := map[namedValue]bool{}
+	// path holds the values being evaluated, each with the range of the
+	// reference to the next one.
+	var path []namedValueReference
+
+	var evaluate func(v namedValue)
+	evaluate = func(v namedValue) {
+		var (
+			subject    *hcl.Range
+			traversals []hcl.Traversal
+		)
+		if v.local != "" {
+			local, exists := locals[v.local]
+			if !exists {
+				// reported when evaluating the referencing expression
+				return
+			}
+			subject = local.Expr.Range().Ptr()
+			traversals = local.Expr.Variables()
+		} else {
+			ds, exists := cfg.Datasources[v.data]
+			if !exists {
+				return
+			}
+			subject = &ds.block.DefRange
+			traversals = bodyVariables(ds.block.Body)
+		}
+
+		switch state[v] {
+		case visited:
+			return
+		case visiting:
+			start := 0
+			for i := range path {
+				if path[i].value == v {
+					start = i
+					break
+				}
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cyclic dependency",
+				Detail:   cycleDetail(path[start:]),
+				Subject:  subject,
+			})
+			failed[v] = true
+			return
+		}
+		state[v] = visiting
+		path = append(path, namedValueReference{value: v})
+		defer func() {
+			state[v] = visited
+			path = path[:len(path)-1]
+		}()
+
+		for _, ref := range namedValueReferences(traversals) {
+			path[len(path)-1].rng = ref.rng
+			dep := ref.value
+			evaluate(dep)
+			if failed[dep] {
+				// the dependency already reported why it failed
+				failed[v] = true
+				return
+			}
+		}
+
+		var moreDiags hcl.Diagnostics
+		if v.local != "" {
+			moreDiags = cfg.evaluateLocalVariable(locals[v.local])
+		} else {
+			moreDiags = cfg.evaluateDatasource(ctx, v.data, skipExecution)
+		}
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			failed[v] = true
+		}
+	}
+
+	for _, ref := range cfg.Datasources.sortedRefs() {
+		evaluate(namedValue{data: ref})
+	}
+	for _, local := range cfg.LocalBlocks {
+		evaluate(namedValue{local: local.Name})
+	}
+
+	return diags
+}
@@ -0,0 +1,47 @@
+package hcl2template
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestPackerConfig_evaluateLocalsAndDatasources_cycle(t *testing.T) {
+	tests := []struct {
+		file       string
+		wantDetail string
+	}{
+		{
+			"testdata/variables/recursive_locals.pkr.hcl",
+			"local.first depends on itself:\n" +
+				"  " + filepath.Join("testdata", "variables", "recursive_locals.pkr.hcl") + ":2: local.first references local.second\n" +
+				"  " + filepath.Join("testdata", "variables", "recursive_locals.pkr.hcl") + ":3: local.second references local.first",
+		},
+		{
+			"testdata/datasources/cyclic_locals.pkr.hcl",
+			"data.amazon-ami.test depends on itself:\n" +
+				"  " + filepath.Join("testdata", "datasources", "cyclic_locals.pkr.hcl") + ":6: data.amazon-ami.test references local.name\n" +
+				"  " + filepath.Join("testdata", "datasources", "cyclic_locals.pkr.hcl") + ":2: local.name references data.amazon-ami.test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			cfg, diags := getBasicParser().Parse(tt.file, nil, nil)
+			if diags.HasErrors() {
+				t.Fatalf("Parse: %s", diags)
+			}
+			diags = cfg.Initialize(packer.InitializeOptions{})
+			if len(diags) != 1 {
+				t.Fatalf("expected a single diagnostic, got %s", diags)
+			}
+			if diags[0].Summary != "Cyclic dependency" {
+				t.Fatalf("unexpected diagnostic %s", diags[0].Summary)
+			}
+			if diff := cmp.Diff(tt.wantDetail, diags[0].Detail); diff != "" {
+				t.Fatalf("unexpected detail: %s", diff)
+			}
+		})
+	}
+}
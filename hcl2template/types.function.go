@@ -0,0 +1,238 @@
+package hcl2template
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// FunctionBlock represents a user-defined function:
+//
+//  function "clean" {
+//    params = [s]
+//    result = lower(replace(s, " ", "-"))
+//  }
+//
+// The result expression can only use the parameters of the function and call
+// other functions, builtin or user-defined.
+type FunctionBlock struct {
+	Name   string
+	Params []string
+	Result hcl.Expression
+
+	DefRange hcl.Range
+}
+
+var functionBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "params", Required: true},
+		{Name: "result", Required: true},
+	},
+}
+
+// decodeFunctions looks in the found blocks for 'function' blocks. It should
+// be called before any expression is evaluated so that they can be used
+// everywhere.
+func (cfg *PackerConfig) decodeFunctions(f *hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	// the parser reports the invalid blocks of the file
+	content, _, _ := f.Body.PartialContent(configSchema)
+
+	builtins := Functions(cfg.Basedir)
+	for _, block := range content.Blocks {
+		if block.Type != functionLabel {
+			continue
+		}
+		fb, moreDiags := decodeFunctionBlock(block)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		if _, found := builtins[fb.Name]; found {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid function name",
+				Detail:   fmt.Sprintf("%q is the name of a builtin function.", fb.Name),
+				Subject:  &block.LabelRanges[0],
+			})
+			continue
+		}
+		if existing, found := cfg.Functions[fb.Name]; found {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate " + functionLabel + " block",
+				Detail: fmt.Sprintf("This function block has the same name as a previous "+
+					"one defined in %s.", existing.DefRange),
+				Subject: &block.LabelRanges[0],
+			})
+			continue
+		}
+		if cfg.Functions == nil {
+			cfg.Functions = map[string]*FunctionBlock{}
+		}
+		cfg.Functions[fb.Name] = fb
+	}
+
+	return diags
+}
+
+func decodeFunctionBlock(block *hcl.Block) (*FunctionBlock, hcl.Diagnostics) {
+	fb := &FunctionBlock{
+		Name:     block.Labels[0],
+		DefRange: block.DefRange,
+	}
+
+	content, diags := block.Body.Content(functionBlockSchema)
+	if !hclsyntax.ValidIdentifier(fb.Name) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid function name",
+			Detail:   badIdentifierDetail,
+			Subject:  &block.LabelRanges[0],
+		})
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	exprs, moreDiags := hcl.ExprList(content.Attributes["params"].Expr)
+	diags = append(diags, moreDiags...)
+	seen := map[string]bool{}
+	for _, expr := range exprs {
+		name := hcl.ExprAsKeyword(expr)
+		switch {
+		case name == "":
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid function parameter",
+				Detail:   "A parameter must be a bare name, like `params = [a, b]`.",
+				Subject:  expr.Range().Ptr(),
+			})
+		case seen[name]:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate function parameter",
+				Detail:   fmt.Sprintf("Parameter %q is declared more than once.", name),
+				Subject:  expr.Range().Ptr(),
+			})
+		}
+		seen[name] = true
+		fb.Params = append(fb.Params, name)
+	}
+	fb.Result = content.Attributes["result"].Expr
+
+	return fb, diags
+}
+
+// checkFunctionCycles makes sure that no user-defined function ends up
+// calling itself, which would never end.
+func (cfg *PackerConfig) checkFunctionCycles() hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	names := make([]string, 0, len(cfg.Functions))
+	for name := range cfg.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fb := cfg.Functions[name]
+		visited := map[string]bool{}
+		stack := cfg.Functions[name].calls()
+		for len(stack) > 0 {
+			called := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if called == name {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Recursive function",
+					Detail:   fmt.Sprintf("Function %q calls itself, directly or through other functions.", name),
+					Subject:  fb.DefRange.Ptr(),
+				})
+				break
+			}
+			if visited[called] {
+				continue
+			}
+			visited[called] = true
+			if other, found := cfg.Functions[called]; found {
+				stack = append(stack, other.calls()...)
+			}
+		}
+	}
+	return diags
+}
+
+// calls returns the names of the functions called by the result expression.
+func (fb *FunctionBlock) calls() []string {
+	expr, ok := fb.Result.(hclsyntax.Expression)
+	if !ok {
+		return nil
+	}
+	var calls []string
+	_ = hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+			calls = append(calls, call.Name)
+		}
+		return nil
+	})
+	return calls
+}
+
+// functions returns the builtin functions along with the user-defined ones.
+func (cfg *PackerConfig) functions() map[string]function.Function {
+	funcs := Functions(cfg.Basedir)
+	if cfg.filesRead != nil {
+		funcs["file"] = cfg.filesRead.recordFileFunc(funcs["file"], cfg.Basedir)
+	}
+	for name, fb := range cfg.Functions {
+		funcs[name] = cfg.userFunction(fb)
+	}
+	return funcs
+}
+
+func (cfg *PackerConfig) userFunction(fb *FunctionBlock) function.Function {
+	params := make([]function.Parameter, 0, len(fb.Params))
+	for _, name := range fb.Params {
+		params = append(params, function.Parameter{
+			Name:             name,
+			Type:             cty.DynamicPseudoType,
+			AllowNull:        true,
+			AllowDynamicType: true,
+		})
+	}
+
+	eval := func(args []cty.Value) (cty.Value, error) {
+		ectx := &hcl.EvalContext{
+			Functions: cfg.functions(),
+			Variables: make(map[string]cty.Value, len(args)),
+		}
+		for i, name := range fb.Params {
+			ectx.Variables[name] = args[i]
+		}
+		value, diags := fb.Result.Value(ectx)
+		if diags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+		return value, nil
+	}
+
+	return function.New(&function.Spec{
+		Params: params,
+		Type: func(args []cty.Value) (cty.Type, error) {
+			value, err := eval(args)
+			if err != nil {
+				return cty.DynamicPseudoType, err
+			}
+			return value.Type(), nil
+		},
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return eval(args)
+		},
+	})
+}
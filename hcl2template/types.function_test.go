@@ -0,0 +1,112 @@
+package hcl2template
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParse_functions(t *testing.T) {
+	defaultParser := getBasicParser()
+
+	tests := []parseTest{
+		{"user-defined functions",
+			defaultParser,
+			parseTestArgs{"testdata/functions/basic.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "functions"),
+				Functions: map[string]*FunctionBlock{
+					"clean": {
+						Name:   "clean",
+						Params: []string{"s"},
+					},
+					"image_name": {
+						Name:   "image_name",
+						Params: []string{"prefix", "name"},
+					},
+				},
+				LocalVariables: Variables{
+					"cleaned": &Variable{
+						Name: "cleaned",
+						Values: []VariableAssignment{{
+							From:  "default",
+							Value: cty.StringVal("my-image"),
+						}},
+						Type: cty.String,
+					},
+					"image_name": &Variable{
+						Name: "image_name",
+						Values: []VariableAssignment{{
+							From:  "default",
+							Value: cty.StringVal("packer-ubuntu-20.04"),
+						}},
+						Type: cty.String,
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			false,
+		},
+		{"recursive functions",
+			defaultParser,
+			parseTestArgs{"testdata/functions/recursive.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "functions"),
+				Functions: map[string]*FunctionBlock{
+					"ping": {
+						Name:   "ping",
+						Params: []string{"s"},
+					},
+					"pong": {
+						Name:   "pong",
+						Params: []string{"s"},
+					},
+				},
+			},
+			true, true,
+			[]packersdk.Build{},
+			false,
+		},
+		{"function named like a builtin",
+			defaultParser,
+			parseTestArgs{"testdata/functions/builtin_name.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "functions"),
+			},
+			true, true,
+			[]packersdk.Build{},
+			false,
+		},
+		{"duplicate function parameter",
+			defaultParser,
+			parseTestArgs{"testdata/functions/duplicate_param.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "functions"),
+			},
+			true, true,
+			[]packersdk.Build{},
+			false,
+		},
+	}
+	testParse(t, tests)
+}
+
+func TestDecodeFunctions_unknownBlock(t *testing.T) {
+	file, diags := hclparse.NewParser().ParseHCL([]byte(`nope {}`), "<stdin>.pkr.hcl")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	// the parser reports the unknown block once, not every decoding pass
+	cfg := &PackerConfig{Basedir: "."}
+	if diags := cfg.decodeFunctions(file); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags)
+	}
+}
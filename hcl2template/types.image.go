@@ -0,0 +1,271 @@
+package hcl2template
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// imageBlock is an experimental, provider-agnostic description of an image:
+//  image "ubuntu" {
+//    family  = "ubuntu"
+//    os      = "linux"
+//    size    = "small"
+//    regions = ["eu-west-1"]
+//  }
+// Each image is expanded into one source per image_mapping block, named after
+// the image. Here, `source.amazon-ebs.ubuntu` if an "amazon-ebs" mapping is
+// defined.
+type imageBlock struct {
+	Name string
+	// Targets restricts the mappings the image is expanded with, all of them
+	// are used when empty.
+	Targets []string
+
+	attrs    hcl.Attributes
+	defRange hcl.Range
+}
+
+// imageMapping is an 'image_mapping' block; its body is the body of the
+// sources it expands to, in which `image` is the image being expanded:
+//  image_mapping "amazon-ebs" {
+//    instance_type = image.size == "small" ? "t3.small" : "m5.large"
+//    ami_regions   = image.regions
+//  }
+type imageMapping struct {
+	Type  string
+	block *hcl.Block
+}
+
+var imageBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "family", Required: true},
+		{Name: "os"},
+		{Name: "size"},
+		{Name: "regions"},
+		{Name: "targets"},
+	},
+}
+
+// imageAttributeTypes are the types of the attributes of the `image` object.
+var imageAttributeTypes = map[string]cty.Type{
+	"family":  cty.String,
+	"os":      cty.String,
+	"size":    cty.String,
+	"regions": cty.List(cty.String),
+}
+
+func decodeImageBlock(block *hcl.Block) (*imageBlock, hcl.Diagnostics) {
+	img := &imageBlock{
+		Name:     block.Labels[0],
+		defRange: block.DefRange,
+	}
+	content, diags := block.Body.Content(imageBlockSchema)
+	if !hclsyntax.ValidIdentifier(img.Name) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid " + imageLabel + " name",
+			Detail:   badIdentifierDetail,
+			Subject:  &block.LabelRanges[0],
+		})
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	if attr, found := content.Attributes["targets"]; found {
+		// targets tell what sources exist, it has to be known when parsing.
+		value, moreDiags := attr.Expr.Value(nil)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			targets, err := convert.Convert(value, cty.List(cty.String))
+			if err != nil || targets.IsNull() || !targets.IsWhollyKnown() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid " + imageLabel + " targets",
+					Detail:   "targets must be a list of source types, like [\"amazon-ebs\"].",
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			} else {
+				for _, target := range targets.AsValueSlice() {
+					img.Targets = append(img.Targets, target.AsString())
+				}
+			}
+		}
+		delete(content.Attributes, "targets")
+	}
+	img.attrs = content.Attributes
+
+	return img, diags
+}
+
+// value evaluates the attributes of the image in ctx, it is the value of
+// `image` in the body of a mapping.
+func (img *imageBlock) value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	vals := map[string]cty.Value{
+		"name": cty.StringVal(img.Name),
+	}
+	for name, typ := range imageAttributeTypes {
+		attr, found := img.attrs[name]
+		if !found {
+			vals[name] = cty.NullVal(typ)
+			continue
+		}
+		value, moreDiags := attr.Expr.Value(ctx)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+		value, err := convert.Convert(value, typ)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Invalid %s %s", imageLabel, name),
+				Detail:   err.Error(),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			return cty.DynamicVal, diags
+		}
+		vals[name] = value
+	}
+	return cty.ObjectVal(vals), diags
+}
+
+// expandImages adds a source for every image and mapping pair.
+func (cfg *PackerConfig) expandImages() hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	types := make([]string, 0, len(cfg.imageMappings))
+	for typ := range cfg.imageMappings {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	for _, img := range cfg.images {
+		targets := img.Targets
+		if len(targets) == 0 {
+			targets = types
+		}
+		for _, typ := range targets {
+			mapping, found := cfg.imageMappings[typ]
+			if !found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unknown " + imageLabel + " target " + typ,
+					Detail:   fmt.Sprintf("No %s block is defined for %q.", imageMappingLabel, typ),
+					Subject:  img.defRange.Ptr(),
+				})
+				continue
+			}
+
+			block := *mapping.block
+			block.Labels = []string{typ, img.Name}
+			block.Body = &imageBody{Body: mapping.block.Body, image: img}
+			source, moreDiags := cfg.parser.decodeSource(&block)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+
+			ref := source.Ref()
+			if existing, found := cfg.Sources[ref]; found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate " + sourceLabel + " block",
+					Detail: fmt.Sprintf("The %s %q expands to %s, which is already "+
+						"declared at %s.", imageLabel, img.Name, ref, existing.block.DefRange.Ptr()),
+					Subject: img.defRange.Ptr(),
+				})
+				continue
+			}
+			if cfg.Sources == nil {
+				cfg.Sources = map[SourceRef]SourceBlock{}
+			}
+			cfg.Sources[ref] = source
+		}
+	}
+	return diags
+}
+
+// imageBody is the body of a mapping for an image. Its expressions are
+// evaluated with `image` set to the value of the image.
+type imageBody struct {
+	hcl.Body
+	image *imageBlock
+}
+
+func (b *imageBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.Body.Content(schema)
+	return b.wrapContent(content), diags
+}
+
+func (b *imageBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, rest, diags := b.Body.PartialContent(schema)
+	return b.wrapContent(content), &imageBody{Body: rest, image: b.image}, diags
+}
+
+func (b *imageBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.Body.JustAttributes()
+	return b.wrapAttributes(attrs), diags
+}
+
+func (b *imageBody) wrapContent(content *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil {
+		return nil
+	}
+	wrapped := *content
+	wrapped.Attributes = b.wrapAttributes(content.Attributes)
+	wrapped.Blocks = make(hcl.Blocks, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		block := *block
+		block.Body = &imageBody{Body: block.Body, image: b.image}
+		wrapped.Blocks = append(wrapped.Blocks, &block)
+	}
+	return &wrapped
+}
+
+func (b *imageBody) wrapAttributes(attrs hcl.Attributes) hcl.Attributes {
+	if attrs == nil {
+		return nil
+	}
+	wrapped := make(hcl.Attributes, len(attrs))
+	for name, attr := range attrs {
+		attr := *attr
+		attr.Expr = &imageExpr{Expression: attr.Expr, image: b.image}
+		wrapped[name] = &attr
+	}
+	return wrapped
+}
+
+// imageExpr is an expression of a mapping, evaluated for an image.
+type imageExpr struct {
+	hcl.Expression
+	image *imageBlock
+}
+
+func (e *imageExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if ctx == nil {
+		ctx = &hcl.EvalContext{}
+	}
+	image, diags := e.image.value(ctx)
+	if diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{
+		imageLabel: image,
+	}
+	value, moreDiags := e.Expression.Value(child)
+	return value, append(diags, moreDiags...)
+}
+
+// UnwrapExpression allows static analysis of the expression, see
+// hcl.UnwrapExpression.
+func (e *imageExpr) UnwrapExpression() hcl.Expression {
+	return e.Expression
+}
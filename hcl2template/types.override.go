@@ -0,0 +1,342 @@
+package hcl2template
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/packer/messages"
+)
+
+// Override files are merged into the other files of their folder once these
+// are parsed, so that a local copy can tweak a checked-in template without
+// modifying it. Like in Terraform, an override file is named `override` or
+// ends with `_override`, for example `dev_override.pkr.hcl`.
+//
+// A source, data, variable, local or communicator block of an override file
+// is merged into the block of the same type and labels: its arguments replace
+// the ones of the original block and its nested blocks replace all the nested
+// blocks of the same type. An argument of a locals block replaces the local
+// of the same name.
+
+// isOverrideFile tells whether filename is an override file.
+func isOverrideFile(filename string) bool {
+	name := filepath.Base(filename)
+	for _, ext := range []string{hcl2FileExt, hcl2JsonFileExt, hcl2YamlFileExt} {
+		if strings.HasSuffix(name, ext) {
+			name = strings.TrimSuffix(name, ext)
+			return name == "override" || strings.HasSuffix(name, "_override")
+		}
+	}
+	return false
+}
+
+// overrideSchema lists the blocks an override file can hold.
+var overrideSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: sourceLabel, LabelNames: []string{"type", "name"}},
+		{Type: dataSourceLabel, LabelNames: []string{"type", "name"}},
+		{Type: variableLabel, LabelNames: []string{"name"}},
+		{Type: localLabel, LabelNames: []string{"name"}},
+		{Type: localsLabel},
+		{Type: communicatorLabel, LabelNames: []string{"type", "name"}},
+	},
+}
+
+// overrideKey identifies the block an override block applies to.
+func overrideKey(block *hcl.Block) string {
+	return strings.Join(append([]string{block.Type}, block.Labels...), ".")
+}
+
+// overrides holds the content of all the override files of a config, in the
+// order they are applied.
+type overrides struct {
+	blocks map[string][]*hcl.Block
+	locals map[string][]*hcl.Attribute
+}
+
+// applyOverrides decodes overrideFiles and returns files with the override
+// blocks merged in. Every override has to apply to an existing block, and a
+// warning lists what each override file changed.
+func applyOverrides(files, overrideFiles []*hcl.File) ([]*hcl.File, hcl.Diagnostics) {
+	if len(overrideFiles) == 0 {
+		return files, nil
+	}
+	var diags hcl.Diagnostics
+
+	// Find what can be overridden.
+	bases := map[string]bool{}
+	for _, file := range files {
+		content, _, _ := file.Body.PartialContent(overrideSchema)
+		for _, block := range content.Blocks {
+			if block.Type != localsLabel {
+				bases[overrideKey(block)] = true
+				continue
+			}
+			attrs, _ := block.Body.JustAttributes()
+			for name := range attrs {
+				bases[localsLabel+"."+name] = true
+			}
+		}
+	}
+
+	o := &overrides{
+		blocks: map[string][]*hcl.Block{},
+		locals: map[string][]*hcl.Attribute{},
+	}
+	for _, file := range overrideFiles {
+		content, moreDiags := file.Body.Content(overrideSchema)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		var applied []string
+		for _, block := range content.Blocks {
+			if block.Type == localsLabel {
+				attrs, moreDiags := block.Body.JustAttributes()
+				diags = append(diags, moreDiags...)
+				for _, attr := range sortedAttributes(attrs) {
+					key := localLabel + "." + attr.Name
+					if !bases[localsLabel+"."+attr.Name] {
+						diags = append(diags, missingOverrideBase(key, attr.NameRange))
+						continue
+					}
+					o.locals[attr.Name] = append(o.locals[attr.Name], attr)
+					applied = append(applied, key)
+				}
+				continue
+			}
+			key := overrideKey(block)
+			if !bases[key] {
+				diags = append(diags, missingOverrideBase(key, block.DefRange))
+				continue
+			}
+			o.blocks[key] = append(o.blocks[key], block)
+			applied = append(applied, key)
+		}
+		if len(applied) > 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Override file applied",
+				Detail: messages.CodeOverrideApplied.Annotate(fmt.Sprintf("%s overrides %s.",
+					file.Body.MissingItemRange().Filename, strings.Join(applied, ", "))),
+			})
+		}
+	}
+
+	res := make([]*hcl.File, len(files))
+	for i, file := range files {
+		overridden := *file
+		overridden.Body = &overriddenFileBody{Body: file.Body, overrides: o}
+		res[i] = &overridden
+	}
+	return res, diags
+}
+
+func missingOverrideBase(key string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Missing base " + key + " to override",
+		Detail: fmt.Sprintf("There is no %s in the files of this folder that "+
+			"are not override files. An override file can only change existing "+
+			"blocks and locals.", key),
+		Subject: rng.Ptr(),
+	}
+}
+
+func sortedAttributes(attrs hcl.Attributes) []*hcl.Attribute {
+	res := make([]*hcl.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		res = append(res, attr)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].NameRange.Start.Byte < res[j].NameRange.Start.Byte
+	})
+	return res
+}
+
+// overriddenFileBody is the body of a config file, with the overrides merged
+// into its top-level blocks.
+type overriddenFileBody struct {
+	hcl.Body
+	overrides *overrides
+}
+
+func (b *overriddenFileBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.Body.Content(schema)
+	return b.overrides.apply(content), diags
+}
+
+func (b *overriddenFileBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.Body.PartialContent(schema)
+	return b.overrides.apply(content), &overriddenFileBody{Body: remain, overrides: b.overrides}, diags
+}
+
+// apply returns content with the bodies of the overridden blocks replaced.
+func (o *overrides) apply(content *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil {
+		return nil
+	}
+	res := *content
+	res.Blocks = make(hcl.Blocks, len(content.Blocks))
+	for i, block := range content.Blocks {
+		res.Blocks[i] = block
+		if block.Type == localsLabel {
+			attrs, _ := block.Body.JustAttributes()
+			overridden := hcl.Attributes{}
+			for name := range attrs {
+				for _, attr := range o.locals[name] {
+					overridden[name] = attr
+				}
+			}
+			if len(overridden) > 0 {
+				b := *block
+				b.Body = &localsOverrideBody{Body: block.Body, attrs: overridden}
+				res.Blocks[i] = &b
+			}
+			continue
+		}
+		overrideBlocks := o.blocks[overrideKey(block)]
+		if len(overrideBlocks) == 0 {
+			continue
+		}
+		b := *block
+		for _, override := range overrideBlocks {
+			b.Body = &overrideBody{base: b.Body, override: override.Body}
+		}
+		res.Blocks[i] = &b
+	}
+	return &res
+}
+
+// localsOverrideBody is the body of a locals block of which some locals are
+// overridden.
+type localsOverrideBody struct {
+	hcl.Body
+	attrs hcl.Attributes
+}
+
+func (b *localsOverrideBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.Body.JustAttributes()
+	res := hcl.Attributes{}
+	for name, attr := range attrs {
+		res[name] = attr
+	}
+	for name, attr := range b.attrs {
+		res[name] = attr
+	}
+	return res, diags
+}
+
+// overrideBody is the body of a block merged with the body of an override
+// block: arguments of override replace the ones of base, and nested blocks of
+// override replace all the nested blocks of the same type of base.
+type overrideBody struct {
+	base, override hcl.Body
+}
+
+var _ hcl.Body = new(overrideBody)
+
+func (b *overrideBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	relaxed := relaxedSchema(schema)
+	base, diags := b.base.Content(relaxed)
+	override, moreDiags := b.override.Content(relaxed)
+	diags = append(diags, moreDiags...)
+	content := mergeOverrideContent(base, override)
+	return content, append(diags, checkRequiredAttributes(schema, content)...)
+}
+
+func (b *overrideBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	relaxed := relaxedSchema(schema)
+	base, baseRemain, diags := b.base.PartialContent(relaxed)
+	override, overrideRemain, moreDiags := b.override.PartialContent(relaxed)
+	diags = append(diags, moreDiags...)
+	content := mergeOverrideContent(base, override)
+	remain := &overrideBody{base: baseRemain, override: overrideRemain}
+	return content, remain, append(diags, checkRequiredAttributes(schema, content)...)
+}
+
+func (b *overrideBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.base.JustAttributes()
+	overrideAttrs, moreDiags := b.override.JustAttributes()
+	diags = append(diags, moreDiags...)
+	res := hcl.Attributes{}
+	for name, attr := range attrs {
+		res[name] = attr
+	}
+	for name, attr := range overrideAttrs {
+		res[name] = attr
+	}
+	return res, diags
+}
+
+func (b *overrideBody) MissingItemRange() hcl.Range {
+	return b.base.MissingItemRange()
+}
+
+// relaxedSchema returns schema with no required argument, so that an argument
+// can be set by either of the merged bodies.
+func relaxedSchema(schema *hcl.BodySchema) *hcl.BodySchema {
+	res := &hcl.BodySchema{Blocks: schema.Blocks}
+	for _, attr := range schema.Attributes {
+		attr.Required = false
+		res.Attributes = append(res.Attributes, attr)
+	}
+	return res
+}
+
+func checkRequiredAttributes(schema *hcl.BodySchema, content *hcl.BodyContent) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, attr := range schema.Attributes {
+		if _, found := content.Attributes[attr.Name]; attr.Required && !found {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", attr.Name),
+				Subject:  content.MissingItemRange.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// mergeOverrideContent merges override into base. Nested blocks of override
+// take the place of the first block of the same type of base.
+func mergeOverrideContent(base, override *hcl.BodyContent) *hcl.BodyContent {
+	res := &hcl.BodyContent{
+		Attributes:       hcl.Attributes{},
+		MissingItemRange: base.MissingItemRange,
+	}
+	for name, attr := range base.Attributes {
+		res.Attributes[name] = attr
+	}
+	for name, attr := range override.Attributes {
+		res.Attributes[name] = attr
+	}
+
+	overridden := map[string]hcl.Blocks{}
+	for _, block := range override.Blocks {
+		overridden[block.Type] = append(overridden[block.Type], block)
+	}
+	for _, block := range base.Blocks {
+		blocks, found := overridden[block.Type]
+		if !found {
+			res.Blocks = append(res.Blocks, block)
+			continue
+		}
+		res.Blocks = append(res.Blocks, blocks...)
+		// only the first base block of the type is replaced, the other
+		// ones are dropped.
+		overridden[block.Type] = nil
+	}
+	for _, block := range override.Blocks {
+		if blocks := overridden[block.Type]; blocks != nil {
+			// types the base did not have
+			res.Blocks = append(res.Blocks, blocks...)
+			overridden[block.Type] = nil
+		}
+	}
+	return res
+}
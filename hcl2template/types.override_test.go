@@ -0,0 +1,88 @@
+package hcl2template
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	. "github.com/hashicorp/packer/hcl2template/internal"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
+)
+
+func TestParse_override(t *testing.T) {
+	dir := filepath.Join("testdata", "override")
+	cfg, diags := getBasicParser().Parse(dir, nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	var warnings []string
+	for _, diag := range diags {
+		if diag.Severity == hcl.DiagWarning {
+			warnings = append(warnings, diag.Detail)
+		}
+	}
+	expectedWarnings := []string{
+		messages.CodeOverrideApplied.Annotate(filepath.Join(dir, "dev_override.pkr.hcl") + " overrides variable.region, local.size, source.virtualbox-iso.ubuntu."),
+		messages.CodeOverrideApplied.Annotate(filepath.Join(dir, "override.pkr.hcl") + " overrides source.virtualbox-iso.ubuntu."),
+	}
+	if diff := cmp.Diff(expectedWarnings, warnings); diff != "" {
+		t.Errorf("unexpected warnings: %s", diff)
+	}
+
+	if diags := cfg.Initialize(packer.InitializeOptions{}); diags.HasErrors() {
+		t.Fatalf("Initialize: %s", diags)
+	}
+	builds, diags := cfg.GetBuilds(packer.GetBuildsOptions{})
+	if diags.HasErrors() {
+		t.Fatalf("GetBuilds: %s", diags)
+	}
+	if len(builds) != 1 {
+		t.Fatalf("expected a single build, got %d", len(builds))
+	}
+
+	got := builds[0].(*packer.CoreBuild).Builder.(*MockBuilder).Config.NestedMockConfig
+	expected := NestedMockConfig{
+		// the variable default and the local are overridden
+		String: "us-east-1-large",
+		// arguments of the override files replace the ones of the source
+		Int:  42,
+		Bool: true,
+		// arguments not overridden are kept
+		SliceString: []string{"a", "b"},
+		// nested blocks of the override replace all the blocks of that type
+		Tags: []MockTag{{Key: "env", Value: "dev"}},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected builder config: %s", diff)
+	}
+}
+
+func TestParse_override_missingBase(t *testing.T) {
+	_, diags := getBasicParser().Parse(filepath.Join("testdata", "override", "missing"), nil, nil)
+	var errors []string
+	for _, diag := range diags {
+		if diag.Severity == hcl.DiagError {
+			errors = append(errors, diag.Summary)
+		}
+	}
+	expected := []string{
+		"Missing base source.virtualbox-iso.debian to override",
+		"Missing base local.unknown to override",
+	}
+	if diff := cmp.Diff(expected, errors); diff != "" {
+		t.Errorf("unexpected errors: %s", diff)
+	}
+}
+
+func TestParse_override_singleFile(t *testing.T) {
+	// An override file passed directly is a regular config file.
+	cfg, diags := getBasicParser().Parse(filepath.Join("testdata", "override", "override.pkr.hcl"), nil, nil)
+	if diags.HasErrors() {
+		t.Fatalf("Parse: %s", diags)
+	}
+	if len(cfg.Sources) != 1 {
+		t.Fatalf("expected the source of the file, got %v", cfg.Sources)
+	}
+}
@@ -1,19 +1,26 @@
 package hcl2template
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/gobwas/glob"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/resume"
 	pkrfunction "github.com/hashicorp/packer/hcl2template/function"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 // PackerConfig represents a loaded Packer HCL config. It will contain
@@ -22,11 +29,19 @@ type PackerConfig struct {
 	Packer struct {
 		VersionConstraints []VersionConstraint
 		RequiredPlugins    []*RequiredPlugins
+		// SuppressWarnings are the codes of the warnings not to report.
+		SuppressWarnings []messages.Code
+		// ParallelBuildsByType are the most builds of a builder type, or
+		// of the builder types of a plugin, running at the same time.
+		ParallelBuildsByType map[string]int64
 	}
 
 	// Directory where the config files are defined
 	Basedir string
 
+	// Packer config directory, PACKER_CONFIG_DIR or its default
+	ConfigDir string
+
 	// Core Packer version, for reference by plugins and template functions.
 	CorePackerVersionString string
 
@@ -47,13 +62,35 @@ type PackerConfig struct {
 
 	LocalBlocks []*LocalBlock
 
+	// Functions are the user-defined functions, by name.
+	Functions map[string]*FunctionBlock
+
+	// PluginVersions are the versions of the installed plugins selected for
+	// the required_plugins block, by source address. Plugins installed
+	// without a version are not in it.
+	PluginVersions map[string]string
+
 	ValidationOptions
 
 	// Builds is the list of Build blocks defined in the config files.
 	Builds Builds
 
+	// CombinedPostProcessors are the post-processors of the top-level
+	// post-processors blocks. They run once all the builds are done, on the
+	// artifacts of all of them.
+	CombinedPostProcessors [][]*PostProcessorBlock
+
+	// images and imageMappings are expanded into Sources.
+	images        []*imageBlock
+	imageMappings map[string]*imageMapping
+
 	except []glob.Glob
 	only   []glob.Glob
+	// schemaOnly is the SchemaOnly option of the last GetBuilds call.
+	schemaOnly bool
+
+	// filesRead are the files read by the file() function, for LocalFiles.
+	filesRead *recordedFiles
 
 	parser *Parser
 	files  []*hcl.File
@@ -73,6 +110,12 @@ const (
 	dataAccessor           = "data"
 )
 
+// suppressWarnings removes from diags the warnings suppressed by the
+// suppress_warnings of the packer blocks.
+func (cfg *PackerConfig) suppressWarnings(diags hcl.Diagnostics) hcl.Diagnostics {
+	return messages.SuppressWarnings(diags, cfg.Packer.SuppressWarnings)
+}
+
 // EvalContext returns the *hcl.EvalContext that will be passed to an hcl
 // decoder in order to tell what is the actual value of a var or a local and
 // the list of defined functions.
@@ -81,7 +124,7 @@ func (cfg *PackerConfig) EvalContext(variables map[string]cty.Value) *hcl.EvalCo
 	localVariables, _ := cfg.LocalVariables.Values()
 	datasourceVariables, _ := cfg.Datasources.Values()
 	ectx := &hcl.EvalContext{
-		Functions: Functions(cfg.Basedir),
+		Functions: cfg.functions(),
 		Variables: map[string]cty.Value{
 			inputVariablesAccessor: cty.ObjectVal(inputVariables),
 			localsAccessor:         cty.ObjectVal(localVariables),
@@ -94,8 +137,9 @@ func (cfg *PackerConfig) EvalContext(variables map[string]cty.Value) *hcl.EvalCo
 				"version": cty.StringVal(cfg.CorePackerVersionString),
 			}),
 			pathVariablesAccessor: cty.ObjectVal(map[string]cty.Value{
-				"cwd":  cty.StringVal(strings.ReplaceAll(cfg.Cwd, `\`, `/`)),
-				"root": cty.StringVal(strings.ReplaceAll(cfg.Basedir, `\`, `/`)),
+				"cwd":    cty.StringVal(strings.ReplaceAll(cfg.Cwd, `\`, `/`)),
+				"root":   cty.StringVal(strings.ReplaceAll(cfg.Basedir, `\`, `/`)),
+				"config": cty.StringVal(strings.ReplaceAll(cfg.ConfigDir, `\`, `/`)),
 			}),
 			dataAccessor: cty.ObjectVal(datasourceVariables),
 		},
@@ -164,7 +208,17 @@ func (c *PackerConfig) parseLocalVariables(f *hcl.File) ([]*LocalBlock, hcl.Diag
 		case localsLabel:
 			attrs, moreDiags := block.Body.JustAttributes()
 			diags = append(diags, moreDiags...)
-			for name, attr := range attrs {
+			// attributes are evaluated in the order they are declared in, for
+			// errors to be reported in a consistent order.
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool {
+				return attrs[names[i]].NameRange.Start.Byte < attrs[names[j]].NameRange.Start.Byte
+			})
+			for _, name := range names {
+				attr := attrs[name]
 				if _, found := c.LocalVariables[name]; found {
 					diags = append(diags, &hcl.Diagnostic{
 						Severity: hcl.DiagError,
@@ -176,8 +230,9 @@ func (c *PackerConfig) parseLocalVariables(f *hcl.File) ([]*LocalBlock, hcl.Diag
 					return locals, diags
 				}
 				locals = append(locals, &LocalBlock{
-					Name: name,
-					Expr: attr.Expr,
+					Name:  name,
+					Expr:  attr.Expr,
+					Range: attr.NameRange,
 				})
 			}
 		}
@@ -187,46 +242,6 @@ func (c *PackerConfig) parseLocalVariables(f *hcl.File) ([]*LocalBlock, hcl.Diag
 	return locals, diags
 }
 
-func (c *PackerConfig) evaluateLocalVariables(locals []*LocalBlock) hcl.Diagnostics {
-	var diags hcl.Diagnostics
-
-	if len(locals) > 0 && c.LocalVariables == nil {
-		c.LocalVariables = Variables{}
-	}
-
-	var retry, previousL int
-	for len(locals) > 0 {
-		local := locals[0]
-		moreDiags := c.evaluateLocalVariable(local)
-		if moreDiags.HasErrors() {
-			if len(locals) == 1 {
-				// If this is the only local left there's no need
-				// to try evaluating again
-				return append(diags, moreDiags...)
-			}
-			if previousL == len(locals) {
-				if retry == 100 {
-					// To get to this point, locals must have a circle dependency
-					return append(diags, moreDiags...)
-				}
-				retry++
-			}
-			previousL = len(locals)
-
-			// If local uses another local that has not been evaluated yet this could be the reason of errors
-			// Push local to the end of slice to be evaluated later
-			locals = append(locals, local)
-		} else {
-			retry = 0
-			diags = append(diags, moreDiags...)
-		}
-		// Remove local from slice
-		locals = append(locals[:0], locals[1:]...)
-	}
-
-	return diags
-}
-
 func (c *PackerConfig) evaluateLocalVariable(local *LocalBlock) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 	value, moreDiags := local.Expr.Value(c.EvalContext(nil))
@@ -248,40 +263,40 @@ func (c *PackerConfig) evaluateLocalVariable(local *LocalBlock) hcl.Diagnostics
 	return diags
 }
 
-func (cfg *PackerConfig) evaluateDatasources(skipExecution bool) hcl.Diagnostics {
+func (cfg *PackerConfig) evaluateDatasource(ctx context.Context, ref DatasourceRef, skipExecution bool) hcl.Diagnostics {
 	var diags hcl.Diagnostics
+	ds := cfg.Datasources[ref]
+	if ds.value != (cty.Value{}) {
+		return nil
+	}
 
-	for ref, ds := range cfg.Datasources {
-		if ds.value != (cty.Value{}) {
-			continue
-		}
-
-		datasource, startDiags := cfg.startDatasource(cfg.parser.PluginConfig.DataSources, ref)
-		diags = append(diags, startDiags...)
-		if diags.HasErrors() {
-			continue
-		}
-
-		if skipExecution {
-			placeholderValue := cty.UnknownVal(hcldec.ImpliedType(datasource.OutputSpec()))
-			ds.value = placeholderValue
-			cfg.Datasources[ref] = ds
-			continue
-		}
+	datasource, startDiags := cfg.startDatasource(cfg.parser.PluginConfig.DataSources, ref)
+	diags = append(diags, startDiags...)
+	if startDiags.HasErrors() {
+		return diags
+	}
 
-		realValue, err := datasource.Execute()
-		if err != nil {
-			diags = append(diags, &hcl.Diagnostic{
-				Summary:  err.Error(),
-				Subject:  &cfg.Datasources[ref].block.DefRange,
-				Severity: hcl.DiagError,
-			})
-			continue
-		}
-		ds.value = realValue
+	if skipExecution {
+		placeholderValue := cty.UnknownVal(hcldec.ImpliedType(datasource.OutputSpec()))
+		ds.value = placeholderValue
 		cfg.Datasources[ref] = ds
+		return diags
 	}
 
+	start := func() (packersdk.Datasource, hcl.Diagnostics) {
+		return cfg.startDatasource(cfg.parser.PluginConfig.DataSources, ref)
+	}
+	realValue, err := ds.execution.execute(ctx, fmt.Sprintf("%s.%s.%s", dataAccessor, ref.Type, ref.Name), datasource, start)
+	if err != nil {
+		diags = append(diags, &hcl.Diagnostic{
+			Summary:  err.Error(),
+			Subject:  &cfg.Datasources[ref].block.DefRange,
+			Severity: hcl.DiagError,
+		})
+		return diags
+	}
+	ds.value = realValue
+	cfg.Datasources[ref] = ds
 	return diags
 }
 
@@ -290,6 +305,7 @@ func (cfg *PackerConfig) evaluateDatasources(skipExecution bool) hcl.Diagnostics
 func (cfg *PackerConfig) getCoreBuildProvisioners(source SourceUseBlock, blocks []*ProvisionerBlock, ectx *hcl.EvalContext) ([]packer.CoreBuildProvisioner, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 	res := []packer.CoreBuildProvisioner{}
+	groups := map[*ParallelBlock]*packer.ParallelGroup{}
 	for _, pb := range blocks {
 		if pb.OnlyExcept.Skip(source.String()) {
 			continue
@@ -319,10 +335,19 @@ func (cfg *PackerConfig) getCoreBuildProvisioners(source SourceUseBlock, blocks
 			}
 		}
 
+		var group *packer.ParallelGroup
+		if pb.Parallel != nil {
+			if groups[pb.Parallel] == nil {
+				groups[pb.Parallel] = &packer.ParallelGroup{MaxSessions: pb.Parallel.MaxSessions}
+			}
+			group = groups[pb.Parallel]
+		}
+
 		res = append(res, packer.CoreBuildProvisioner{
 			PType:       pb.PType,
 			PName:       pb.PName,
 			Provisioner: provisioner,
+			Parallel:    group,
 		})
 	}
 	return res, diags
@@ -376,12 +401,39 @@ func (cfg *PackerConfig) getCoreBuildPostProcessors(source SourceUseBlock, block
 	return res, diags
 }
 
+// GetCombinedPostProcessors starts the post-processors of the top-level
+// post-processors blocks, the ones matching the -except option left out.
+func (cfg *PackerConfig) GetCombinedPostProcessors(opts packer.GetBuildsOptions) ([][]packer.CoreBuildPostProcessor, hcl.Diagnostics) {
+	if len(cfg.CombinedPostProcessors) == 0 {
+		return nil, nil
+	}
+	cfg.schemaOnly = opts.SchemaOnly
+	if len(opts.Except) > 0 {
+		exceptGlobs, diags := convertFilterOption(opts.Except, "except")
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		cfg.except = exceptGlobs
+	}
+	// the post-processors see the builds as one build, of type "combined".
+	source := SourceUseBlock{SourceRef: SourceRef{Type: "combined", Name: postProcessorsLabel}}
+	pps, diags := cfg.getCoreBuildPostProcessors(source, cfg.CombinedPostProcessors, cfg.EvalContext(nil))
+	return pps, cfg.suppressWarnings(diags)
+}
+
 // GetBuilds returns a list of packer Build based on the HCL2 parsed build
 // blocks. All Builders, Provisioners and Post Processors will be started and
 // configured.
 func (cfg *PackerConfig) GetBuilds(opts packer.GetBuildsOptions) ([]packersdk.Build, hcl.Diagnostics) {
+	builds, diags := cfg.getBuilds(opts)
+	return builds, cfg.suppressWarnings(diags)
+}
+
+func (cfg *PackerConfig) getBuilds(opts packer.GetBuildsOptions) ([]packersdk.Build, hcl.Diagnostics) {
 	res := []packersdk.Build{}
 	var diags hcl.Diagnostics
+	cfg.schemaOnly = opts.SchemaOnly
+	seenLocalPathDiags := map[string]bool{}
 
 	for _, build := range cfg.Builds {
 		for _, srcUsage := range build.Sources {
@@ -397,8 +449,9 @@ func (cfg *PackerConfig) GetBuilds(opts packer.GetBuildsOptions) ([]packersdk.Bu
 			}
 
 			pcb := &packer.CoreBuild{
-				BuildName: build.Name,
-				Type:      srcUsage.String(),
+				BuildName:   build.Name,
+				Type:        srcUsage.String(),
+				BuilderType: srcUsage.Type,
 			}
 
 			// Apply the -only and -except command-line options to exclude matching builds.
@@ -441,7 +494,20 @@ func (cfg *PackerConfig) GetBuilds(opts packer.GetBuildsOptions) ([]packersdk.Bu
 				}
 			}
 
-			builder, moreDiags, generatedVars := cfg.startBuilder(srcUsage, cfg.EvalContext(nil), opts)
+			if opts.CheckLocalPaths {
+				diags = append(diags, cfg.checkBuildLocalPaths(build, srcUsage, seenLocalPathDiags)...)
+			}
+
+			if srcUsage.Timeouts == nil {
+				srcUsage.Timeouts = src.Timeouts
+			}
+			vars := map[string]string{}
+			if opts.ResumeStateDir != "" {
+				stateFile := packer.BuildStateFile(opts.ResumeStateDir, buildName)
+				pcb.SetResumeStateFile(stateFile)
+				vars[resume.StateConfigKey] = stateFile
+			}
+			builder, moreDiags, generatedVars := cfg.startBuilder(srcUsage, cfg.EvalContext(nil), opts, vars)
 			diags = append(diags, moreDiags...)
 			if moreDiags.HasErrors() {
 				continue
@@ -462,8 +528,17 @@ func (cfg *PackerConfig) GetBuilds(opts packer.GetBuildsOptions) ([]packersdk.Bu
 				sourcesAccessor: cty.ObjectVal(srcUsage.ctyValues()),
 				buildAccessor:   cty.ObjectVal(unknownBuildValues),
 			}
+			if opts.SchemaOnly {
+				// the variables generated by the builder are only known once
+				// it is prepared.
+				variables[buildAccessor] = cty.DynamicVal
+			}
 
-			provisioners, moreDiags := cfg.getCoreBuildProvisioners(srcUsage, build.ProvisionerBlocks, cfg.EvalContext(variables))
+			provisionerBlocks := build.ProvisionerBlocks
+			if build.CleanupBlock != nil {
+				provisionerBlocks = append(provisionerBlocks[:len(provisionerBlocks):len(provisionerBlocks)], build.CleanupBlock)
+			}
+			provisioners, moreDiags := cfg.getCoreBuildProvisioners(srcUsage, provisionerBlocks, cfg.EvalContext(variables))
 			diags = append(diags, moreDiags...)
 			if moreDiags.HasErrors() {
 				continue
@@ -474,7 +549,52 @@ func (cfg *PackerConfig) GetBuilds(opts packer.GetBuildsOptions) ([]packersdk.Bu
 				continue
 			}
 
+			if replicate := build.ReplicateBlock; replicate != nil {
+				if !packer.CanReplicate(builder) {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Unsupported " + buildReplicateLabel + " block",
+						Detail: fmt.Sprintf("The %s builder of %s cannot replicate its artifacts. "+
+							"The builders of packer supporting the %s block are: %s; the builders "+
+							"of plugins may support it too, see their documentation.",
+							src.Type, src.Ref().String(), buildReplicateLabel,
+							strings.Join(packer.ReplicatingBuilders, ", ")),
+						Subject: replicate.HCL2Ref.DefRange.Ptr(),
+					})
+					continue
+				}
+				// every copy is made by a builder started again with the
+				// configuration of the source.
+				replicaSource := srcUsage
+				var startLock sync.Mutex
+				pcb.Replication = &packer.ArtifactReplication{
+					Targets:     replicate.Targets,
+					MaxParallel: replicate.MaxParallel,
+					StartBuilder: func(vars map[string]string) (packersdk.Builder, error) {
+						// the template is not evaluated concurrently
+						startLock.Lock()
+						defer startLock.Unlock()
+						builder, diags, _ := cfg.startBuilder(replicaSource, cfg.EvalContext(nil), opts, vars)
+						if diags.HasErrors() {
+							return nil, diags
+						}
+						return builder, nil
+					},
+				}
+			}
+
 			pcb.Builder = builder
+			pcb.Resources = src.Resources
+			if srcUsage.Resources != nil {
+				pcb.Resources = srcUsage.Resources
+			}
+			if srcUsage.Timeouts != nil {
+				pcb.Timeout = srcUsage.Timeouts.Timeout
+			}
+			if build.RetryBlock != nil {
+				retry := build.RetryBlock.BuildRetry
+				pcb.Retry = &retry
+			}
 			pcb.Provisioners = provisioners
 			pcb.PostProcessors = pps
 			pcb.Prepared = true
@@ -511,6 +631,12 @@ exists in your config file.
 
 "variables" will dump all available variables and their values.
 
+"datasources" will dump the values of the data sources of the config.
+
+Expressions can span several lines, like heredocs or lists: the console reads
+lines until their brackets are closed. Hit <tab> to complete the names of
+functions, variables, locals and data sources.
+
 To exit the console, type "exit" and hit <enter>, or use Control-C.
 
 /!\ It is not possible to use go templating interpolation like "{{timestamp}}"
@@ -526,13 +652,17 @@ func (p *PackerConfig) EvaluateExpression(line string) (out string, exit bool, d
 	case line == "help":
 		return PackerConsoleHelp, false, nil
 	case line == "variables":
-		return p.printVariables(), false, nil
+		return p.printVariables(false), false, nil
+	case line == "datasources":
+		return p.printDatasources(), false, nil
 	default:
 		return p.handleEval(line)
 	}
 }
 
-func (p *PackerConfig) printVariables() string {
+// printVariables prints the value of every variable. Values of sensitive
+// variables are masked unless showSensitive is set.
+func (p *PackerConfig) printVariables(showSensitive bool) string {
 	out := &strings.Builder{}
 	out.WriteString("> input-variables:\n\n")
 	keys := p.InputVariables.Keys()
@@ -540,6 +670,9 @@ func (p *PackerConfig) printVariables() string {
 	for _, key := range keys {
 		v := p.InputVariables[key]
 		val, _ := v.Value()
+		if v.Sensitive && !showSensitive {
+			val = maskSensitive(val)
+		}
 		fmt.Fprintf(out, "var.%s: %q\n", v.Name, PrintableCtyValue(val))
 	}
 	out.WriteString("\n> local-variables:\n\n")
@@ -548,11 +681,126 @@ func (p *PackerConfig) printVariables() string {
 	for _, key := range keys {
 		v := p.LocalVariables[key]
 		val, _ := v.Value()
+		if v.Sensitive && !showSensitive {
+			val = maskSensitive(val)
+		}
 		fmt.Fprintf(out, "local.%s: %q\n", v.Name, PrintableCtyValue(val))
 	}
 	return out.String()
 }
 
+// printResolvedVariables prints the value of every variable with where it was
+// set from, followed by the values it overrides from the last set to the
+// first, and the value of every local.
+func (p *PackerConfig) printResolvedVariables(showSensitive bool) string {
+	out := &strings.Builder{}
+	out.WriteString("> input-variables:\n\n")
+	keys := p.InputVariables.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := p.InputVariables[key]
+		printable := func(val cty.Value) string {
+			if v.Sensitive && !showSensitive {
+				val = maskSensitive(val)
+			}
+			return PrintableCtyValue(val)
+		}
+		if len(v.Values) == 0 {
+			fmt.Fprintf(out, "var.%s: %q\n  not set\n", v.Name, "<unknown>")
+			continue
+		}
+		last := len(v.Values) - 1
+		fmt.Fprintf(out, "var.%s: %q\n", v.Name, printable(v.Values[last].Value))
+		if last == 0 {
+			fmt.Fprintf(out, "  from %s\n", v.Values[last].origin(v.Name))
+			continue
+		}
+		fmt.Fprintf(out, "  from %s, overriding:\n", v.Values[last].origin(v.Name))
+		for i := last - 1; i >= 0; i-- {
+			fmt.Fprintf(out, "    %s: %q\n", v.Values[i].origin(v.Name), printable(v.Values[i].Value))
+		}
+	}
+	out.WriteString("\n> local-variables:\n\n")
+	keys = p.LocalVariables.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := p.LocalVariables[key]
+		val, _ := v.Value()
+		if v.Sensitive && !showSensitive {
+			val = maskSensitive(val)
+		}
+		fmt.Fprintf(out, "local.%s: %q\n", v.Name, PrintableCtyValue(val))
+	}
+	return out.String()
+}
+
+// printDatasources prints the value of every data source. The values of the
+// data sources that were not executed are unknown.
+func (p *PackerConfig) printDatasources() string {
+	out := &strings.Builder{}
+	out.WriteString("> data-sources:\n\n")
+	refs := make([]DatasourceRef, 0, len(p.Datasources))
+	for ref := range p.Datasources {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Type != refs[j].Type {
+			return refs[i].Type < refs[j].Type
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	for _, ref := range refs {
+		val := p.Datasources[ref].value
+		if val == (cty.Value{}) {
+			val = cty.DynamicVal
+		}
+		fmt.Fprintf(out, "data.%s.%s: %q\n", ref.Type, ref.Name, PrintableCtyValue(val))
+	}
+	return out.String()
+}
+
+// ParallelBuildsByType returns the limits set by the parallel_builds_by_type
+// attribute of the packer block.
+func (p *PackerConfig) ParallelBuildsByType() map[string]int64 {
+	return p.Packer.ParallelBuildsByType
+}
+
+// CompletionNames returns the names of the functions, variables, locals and
+// data sources of the config, to complete expressions in the console.
+func (p *PackerConfig) CompletionNames() []string {
+	var names []string
+	for name := range p.functions() {
+		names = append(names, name+"(")
+	}
+	for name := range p.InputVariables {
+		names = append(names, inputVariablesAccessor+"."+name)
+	}
+	for name := range p.LocalVariables {
+		names = append(names, localsAccessor+"."+name)
+	}
+	for ref := range p.Datasources {
+		names = append(names, fmt.Sprintf("%s.%s.%s", dataAccessor, ref.Type, ref.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// maskSensitive replaces every known primitive of val with "<sensitive>",
+// keeping the shape of collections so that it is still printed as a list or a
+// map.
+func maskSensitive(val cty.Value) cty.Value {
+	masked, err := cty.Transform(val, func(_ cty.Path, v cty.Value) (cty.Value, error) {
+		if v.IsNull() || !v.IsKnown() || !v.Type().IsPrimitiveType() {
+			return v, nil
+		}
+		return cty.StringVal("<sensitive>"), nil
+	})
+	if err != nil {
+		return cty.StringVal("<sensitive>")
+	}
+	return masked
+}
+
 func (p *PackerConfig) printBuilds() string {
 	out := &strings.Builder{}
 	out.WriteString("> builds:\n")
@@ -598,13 +846,27 @@ func (p *PackerConfig) printBuilds() string {
 			}
 		}
 	}
+	if len(p.CombinedPostProcessors) > 0 {
+		out.WriteString("\n> post-processors of all builds:\n")
+		for i, ppList := range p.CombinedPostProcessors {
+			fmt.Fprintf(out, "\n  %d:\n", i)
+			for _, pp := range ppList {
+				str := pp.PType
+				if pp.PName != "" {
+					str = strings.Join([]string{pp.PType, pp.PName}, ".")
+				}
+				fmt.Fprintf(out, "    %s\n", str)
+			}
+		}
+	}
 	return out.String()
 }
 
 func (p *PackerConfig) handleEval(line string) (out string, exit bool, diags hcl.Diagnostics) {
 
-	// Parse the given line as an expression
-	expr, parseDiags := hclsyntax.ParseExpression([]byte(line), "<console-input>", hcl.Pos{Line: 1, Column: 1})
+	// Parse the given line as an expression. The line can span several lines
+	// of input, a heredoc only ends with the newline after its marker.
+	expr, parseDiags := hclsyntax.ParseExpression([]byte(line+"\n"), "<console-input>", hcl.Pos{Line: 1, Column: 1})
 	diags = append(diags, parseDiags...)
 	if parseDiags.HasErrors() {
 		return "", false, diags
@@ -627,8 +889,140 @@ func (p *PackerConfig) FixConfig(_ packer.FixConfigOptions) (diags hcl.Diagnosti
 func (p *PackerConfig) InspectConfig(opts packer.InspectConfigOptions) int {
 
 	ui := opts.Ui
+	if opts.JSON {
+		return packer.WriteInspectOutput(ui, p.inspectOutput(opts.ShowSensitive))
+	}
+	if opts.Resolve {
+		ui.Say("Packer Inspect: HCL2 mode, resolved values\n")
+		ui.Say(p.printResolvedVariables(opts.ShowSensitive))
+		return 0
+	}
 	ui.Say("Packer Inspect: HCL2 mode\n")
-	ui.Say(p.printVariables())
+	ui.Say(p.printVariables(opts.ShowSensitive))
 	ui.Say(p.printBuilds())
 	return 0
 }
+
+// inspectOutput returns the structure of the config. Values of sensitive
+// variables are masked unless showSensitive is set.
+func (p *PackerConfig) inspectOutput(showSensitive bool) *packer.InspectOutput {
+	out := &packer.InspectOutput{
+		Mode:      "hcl2",
+		Variables: []packer.InspectVariable{},
+		Locals:    []packer.InspectLocal{},
+		Sources:   []packer.InspectSource{},
+		Builds:    []packer.InspectBuild{},
+	}
+	jsonValue := func(val cty.Value, sensitive bool) json.RawMessage {
+		if sensitive && !showSensitive {
+			val = maskSensitive(val)
+		}
+		if !val.IsWhollyKnown() {
+			return nil
+		}
+		js, err := ctyjson.Marshal(val, val.Type())
+		if err != nil {
+			return nil
+		}
+		return js
+	}
+
+	keys := p.InputVariables.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := p.InputVariables[key]
+		variable := packer.InspectVariable{
+			Name:        v.Name,
+			Description: v.Description,
+			Required:    true,
+			Sensitive:   v.Sensitive,
+		}
+		switch {
+		case v.Unit != "":
+			variable.Type = string(v.Unit)
+		case v.Type != cty.NilType:
+			variable.Type = typeexpr.TypeString(v.Type)
+		}
+		for _, assignment := range v.Values {
+			if assignment.From == "default" {
+				variable.Required = false
+				variable.Default = jsonValue(assignment.Value, v.Sensitive)
+			}
+		}
+		out.Variables = append(out.Variables, variable)
+	}
+
+	for _, local := range p.LocalBlocks {
+		l := packer.InspectLocal{
+			Name:      local.Name,
+			Sensitive: local.Sensitive,
+		}
+		if v, found := p.LocalVariables[local.Name]; found {
+			val, _ := v.Value()
+			l.Value = jsonValue(val, local.Sensitive)
+		}
+		out.Locals = append(out.Locals, l)
+	}
+
+	refs := make([]SourceRef, 0, len(p.Sources))
+	for ref := range p.Sources {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+	for _, ref := range refs {
+		out.Sources = append(out.Sources, packer.InspectSource{Name: ref.String(), Type: ref.Type})
+	}
+
+	postProcessors := func(lists [][]*PostProcessorBlock) [][]packer.InspectComponent {
+		res := [][]packer.InspectComponent{}
+		for _, list := range lists {
+			var chain []packer.InspectComponent
+			for _, pp := range list {
+				chain = append(chain, packer.InspectComponent{
+					Type:   pp.PType,
+					Name:   pp.PName,
+					Only:   pp.OnlyExcept.Only,
+					Except: pp.OnlyExcept.Except,
+				})
+			}
+			res = append(res, chain)
+		}
+		return res
+	}
+	for _, build := range p.Builds {
+		b := packer.InspectBuild{
+			Name:           build.Name,
+			Description:    build.Description,
+			Sources:        []string{},
+			Provisioners:   []packer.InspectComponent{},
+			PostProcessors: postProcessors(build.PostProcessorsLists),
+		}
+		for _, source := range build.Sources {
+			b.Sources = append(b.Sources, source.String())
+		}
+		parallel := map[*ParallelBlock]int{}
+		for _, prov := range build.ProvisionerBlocks {
+			c := packer.InspectComponent{
+				Type:   prov.PType,
+				Name:   prov.PName,
+				Only:   prov.OnlyExcept.Only,
+				Except: prov.OnlyExcept.Except,
+			}
+			if prov.Parallel != nil {
+				if _, found := parallel[prov.Parallel]; !found {
+					parallel[prov.Parallel] = len(parallel) + 1
+				}
+				c.Parallel = parallel[prov.Parallel]
+			}
+			b.Provisioners = append(b.Provisioners, c)
+		}
+		if prov := build.CleanupBlock; prov != nil {
+			b.ErrorCleanupProvisioner = &packer.InspectComponent{Type: prov.PType, Name: prov.PName}
+		}
+		out.Builds = append(out.Builds, b)
+	}
+	if len(p.CombinedPostProcessors) > 0 {
+		out.PostProcessors = postProcessors(p.CombinedPostProcessors)
+	}
+	return out
+}
@@ -9,6 +9,7 @@ import (
 	. "github.com/hashicorp/packer/hcl2template/internal"
 	hcl2template "github.com/hashicorp/packer/hcl2template/internal"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -28,8 +29,10 @@ func TestParser_complete(t *testing.T) {
 			parseTestArgs{"testdata/complete", nil, nil},
 			&PackerConfig{
 				Packer: struct {
-					VersionConstraints []VersionConstraint
-					RequiredPlugins    []*RequiredPlugins
+					VersionConstraints   []VersionConstraint
+					RequiredPlugins      []*RequiredPlugins
+					SuppressWarnings     []messages.Code
+					ParallelBuildsByType map[string]int64
 				}{
 					VersionConstraints: []VersionConstraint{
 						{
@@ -197,9 +200,10 @@ func TestParser_complete(t *testing.T) {
 			false, false,
 			[]packersdk.Build{
 				&packer.CoreBuild{
-					Type:     "virtualbox-iso.ubuntu-1204",
-					Prepared: true,
-					Builder:  basicMockBuilder,
+					Type:        "virtualbox-iso.ubuntu-1204",
+					BuilderType: "virtualbox-iso",
+					Prepared:    true,
+					Builder:     basicMockBuilder,
 					Provisioners: []packer.CoreBuildProvisioner{
 						{
 							PType: "shell",
@@ -269,8 +273,9 @@ func TestParser_complete(t *testing.T) {
 					},
 				},
 				&packer.CoreBuild{
-					Type:     "amazon-ebs.ubuntu-1604",
-					Prepared: true,
+					Type:        "amazon-ebs.ubuntu-1604",
+					BuilderType: "amazon-ebs",
+					Prepared:    true,
 					Builder: &MockBuilder{
 						Config: MockConfig{
 							NestedMockConfig: NestedMockConfig{
@@ -400,8 +405,10 @@ func TestParser_no_init(t *testing.T) {
 			parseTestArgs{"testdata/init/imports", nil, nil},
 			&PackerConfig{
 				Packer: struct {
-					VersionConstraints []VersionConstraint
-					RequiredPlugins    []*RequiredPlugins
+					VersionConstraints   []VersionConstraint
+					RequiredPlugins      []*RequiredPlugins
+					SuppressWarnings     []messages.Code
+					ParallelBuildsByType map[string]int64
 				}{
 					VersionConstraints: []VersionConstraint{
 						{
@@ -4,14 +4,21 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	hcl2shim "github.com/hashicorp/packer/hcl2template/shim"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
+const (
+	sourceSchedulingLabel = "scheduling"
+	sourceTimeoutsLabel   = "timeouts"
+)
+
 // SourceBlock references an HCL 'source' block to be used in a build for
 // example.
 type SourceBlock struct {
@@ -25,6 +32,64 @@ type SourceBlock struct {
 	// LocalName can be set in a singular source block from a build block, it
 	// allows to give a special name to a build in the logs.
 	LocalName string
+
+	// Resources are the scheduling hints set in the 'scheduling' block of
+	// the source, if any.
+	Resources *packer.BuildResources
+
+	// Timeouts are set in the 'timeouts' block of the source, if any.
+	Timeouts *SourceTimeouts
+}
+
+// schedulingBlock is a 'scheduling' block in a source, it tells what a build
+// of this source will use on the host:
+//  source "virtualbox-iso" "example" {
+//    scheduling {
+//      cpu    = 2
+//      memory = "4GB"
+//    }
+//  }
+type schedulingBlock struct {
+	// the attributes are decoded by resources, for its errors to point at
+	// them
+	CPU    *hcl.Attribute `hcl:"cpu,optional"`
+	Memory *hcl.Attribute `hcl:"memory,optional"`
+}
+
+func (b *schedulingBlock) resources() (*packer.BuildResources, hcl.Diagnostics) {
+	if b == nil {
+		return nil, nil
+	}
+	var diags hcl.Diagnostics
+	res := &packer.BuildResources{}
+	if b.CPU != nil {
+		moreDiags := gohcl.DecodeExpression(b.CPU.Expr, nil, &res.CPU)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() && res.CPU < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + sourceSchedulingLabel + " cpu",
+				Detail:   fmt.Sprintf("cpu must be a positive number, got %d.", res.CPU),
+				Subject:  b.CPU.Expr.Range().Ptr(),
+			})
+		}
+	}
+	if b.Memory != nil {
+		var memory string
+		moreDiags := gohcl.DecodeExpression(b.Memory.Expr, nil, &memory)
+		diags = append(diags, moreDiags...)
+		var size datasize.ByteSize
+		if err := size.UnmarshalText([]byte(memory)); !moreDiags.HasErrors() && err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + sourceSchedulingLabel + " memory",
+				Detail:   fmt.Sprintf("Could not parse memory %q, expected a size like \"4GB\": %s", memory, err),
+				Subject:  b.Memory.Expr.Range().Ptr(),
+			})
+		}
+		res.Memory = size.Bytes()
+	}
+	return res, diags
 }
 
 // SourceUseBlock is a SourceBlock 'usage' from a config stand point.
@@ -42,6 +107,12 @@ type SourceUseBlock struct {
 	// content
 	// Body can be expanded by a dynamic tag.
 	Body hcl.Body
+
+	// Resources overrides the scheduling hints of the source, if set.
+	Resources *packer.BuildResources
+
+	// Timeouts overrides the timeouts of the source, if set.
+	Timeouts *SourceTimeouts
 }
 
 func (b *SourceUseBlock) name() string {
@@ -73,8 +144,10 @@ func (p *Parser) decodeBuildSource(block *hcl.Block) (SourceUseBlock, hcl.Diagno
 	ref := sourceRefFromString(block.Labels[0])
 	out := SourceUseBlock{SourceRef: ref}
 	var b struct {
-		Name string   `hcl:"name,optional"`
-		Rest hcl.Body `hcl:",remain"`
+		Name       string           `hcl:"name,optional"`
+		Scheduling *schedulingBlock `hcl:"scheduling,block"`
+		Timeouts   *timeoutsBlock   `hcl:"timeouts,block"`
+		Rest       hcl.Body         `hcl:",remain"`
 	}
 	diags := gohcl.DecodeBody(block.Body, nil, &b)
 	if diags.HasErrors() {
@@ -82,7 +155,11 @@ func (p *Parser) decodeBuildSource(block *hcl.Block) (SourceUseBlock, hcl.Diagno
 	}
 	out.LocalName = b.Name
 	out.Body = b.Rest
-	return out, nil
+	out.Resources, diags = b.Scheduling.resources()
+	var moreDiags hcl.Diagnostics
+	out.Timeouts, moreDiags = b.Timeouts.timeouts(block.DefRange.Ptr())
+	diags = append(diags, moreDiags...)
+	return out, diags
 }
 
 func (p *Parser) decodeSource(block *hcl.Block) (SourceBlock, hcl.Diagnostics) {
@@ -91,19 +168,39 @@ func (p *Parser) decodeSource(block *hcl.Block) (SourceBlock, hcl.Diagnostics) {
 		Name:  block.Labels[1],
 		block: block,
 	}
-	var diags hcl.Diagnostics
+	var b struct {
+		Scheduling *schedulingBlock `hcl:"scheduling,block"`
+		Timeouts   *timeoutsBlock   `hcl:"timeouts,block"`
+		Rest       hcl.Body         `hcl:",remain"`
+	}
+	diags := gohcl.DecodeBody(block.Body, nil, &b)
+	if diags.HasErrors() {
+		return source, diags
+	}
+
+	// the scheduling and timeouts blocks are for packer only, the builder
+	// gets the rest.
+	withoutScheduling := *block
+	withoutScheduling.Body = b.Rest
+	source.block = &withoutScheduling
+	source.Resources, diags = b.Scheduling.resources()
+	var moreDiags hcl.Diagnostics
+	source.Timeouts, moreDiags = b.Timeouts.timeouts(block.DefRange.Ptr())
+	diags = append(diags, moreDiags...)
 
 	return source, diags
 }
 
-func (cfg *PackerConfig) startBuilder(source SourceUseBlock, ectx *hcl.EvalContext, opts packer.GetBuildsOptions) (packersdk.Builder, hcl.Diagnostics, []string) {
+// startBuilder starts and prepares the builder of source, with vars added to
+// the variables packer gives to builders.
+func (cfg *PackerConfig) startBuilder(source SourceUseBlock, ectx *hcl.EvalContext, opts packer.GetBuildsOptions, vars map[string]string) (packersdk.Builder, hcl.Diagnostics, []string) {
 	var diags hcl.Diagnostics
 
 	builder, err := cfg.parser.PluginConfig.Builders.Start(source.Type)
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
 			Summary: "Failed to load " + sourceLabel + " type",
-			Detail:  err.Error(),
+			Detail:  messages.CodePluginStart.Annotate(err.Error()),
 		})
 		return builder, diags, nil
 	}
@@ -115,6 +212,12 @@ func (cfg *PackerConfig) startBuilder(source SourceUseBlock, ectx *hcl.EvalConte
 		return builder, diags, nil
 	}
 
+	decoded, moreDiags = source.Timeouts.apply(source.Type, decoded, cfg.Sources[source.SourceRef].block.DefRange.Ptr())
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() || opts.SchemaOnly {
+		return builder, diags, nil
+	}
+
 	// In case of cty.Unknown values, this will write a equivalent placeholder of the same type
 	// Unknown types are not recognized by the json marshal during the RPC call and we have to do this here
 	// to avoid json parsing failures when running the validate command.
@@ -130,6 +233,10 @@ func (cfg *PackerConfig) startBuilder(source SourceUseBlock, ectx *hcl.EvalConte
 	builderVars["packer_debug"] = strconv.FormatBool(opts.Debug)
 	builderVars["packer_force"] = strconv.FormatBool(opts.Force)
 	builderVars["packer_on_error"] = opts.OnError
+	builderVars[packer.SkipCreateArtifactConfigKey] = strconv.FormatBool(opts.SkipCreateArtifact)
+	for k, v := range vars {
+		builderVars[k] = v
+	}
 
 	generatedVars, warning, err := builder.Prepare(builderVars, decoded)
 	moreDiags = warningErrorsToDiags(cfg.Sources[source.SourceRef].block, warning, err)
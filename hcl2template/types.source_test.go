@@ -3,8 +3,13 @@ package hcl2template
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/null"
+	. "github.com/hashicorp/packer/hcl2template/internal"
+	"github.com/hashicorp/packer/packer"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestParse_source(t *testing.T) {
@@ -90,6 +95,276 @@ func TestParse_source(t *testing.T) {
 			nil,
 			false,
 		},
+		{"source with scheduling hints",
+			defaultParser,
+			parseTestArgs{"testdata/sources/scheduling.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				Sources: map[SourceRef]SourceBlock{
+					{
+						Type: "virtualbox-iso",
+						Name: "ubuntu-1204",
+					}: {
+						Type:      "virtualbox-iso",
+						Name:      "ubuntu-1204",
+						Resources: &packer.BuildResources{CPU: 4, Memory: 4 << 30},
+					},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: SourceRef{Type: "virtualbox-iso", Name: "ubuntu-1204"},
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:           "virtualbox-iso.ubuntu-1204",
+					BuilderType:    "virtualbox-iso",
+					Prepared:       true,
+					Builder:        emptyMockBuilder,
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+					Resources:      &packer.BuildResources{CPU: 4, Memory: 4 << 30},
+				},
+			},
+			false,
+		},
+		{"source with timeouts",
+			defaultParser,
+			parseTestArgs{"testdata/sources/timeouts.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				Sources: map[SourceRef]SourceBlock{
+					{
+						Type: "null",
+						Name: "ssh",
+					}: {
+						Type:     "null",
+						Name:     "ssh",
+						Timeouts: &SourceTimeouts{ConnectTimeout: 15 * time.Minute, Timeout: time.Hour},
+					},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: SourceRef{Type: "null", Name: "ssh"},
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:           "null.ssh",
+					BuilderType:    "null",
+					Prepared:       true,
+					Builder:        &null.Builder{},
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+					Timeout:        time.Hour,
+				},
+			},
+			false,
+		},
+		{"source with a timeout the builder does not support",
+			defaultParser,
+			parseTestArgs{"testdata/sources/timeouts_unsupported.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				Sources: map[SourceRef]SourceBlock{
+					{
+						Type: "virtualbox-iso",
+						Name: "ubuntu-1204",
+					}: {
+						Type:     "virtualbox-iso",
+						Name:     "ubuntu-1204",
+						Timeouts: &SourceTimeouts{BootWait: 10 * time.Second},
+					},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: SourceRef{Type: "virtualbox-iso", Name: "ubuntu-1204"},
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			true,
+		},
+		{"source with a timeout also set in the builder config",
+			defaultParser,
+			parseTestArgs{"testdata/sources/timeouts_conflict.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				Sources: map[SourceRef]SourceBlock{
+					{
+						Type: "null",
+						Name: "ssh",
+					}: {
+						Type:     "null",
+						Name:     "ssh",
+						Timeouts: &SourceTimeouts{ConnectTimeout: 15 * time.Minute},
+					},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: SourceRef{Type: "null", Name: "ssh"},
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			true,
+		},
+		{"source from a YAML file",
+			defaultParser,
+			parseTestArgs{"testdata/sources/basic.pkr.yaml", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				InputVariables: Variables{
+					"cpus": &Variable{
+						Name:   "cpus",
+						Type:   cty.Number,
+						Values: []VariableAssignment{{From: "default", Value: cty.NumberIntVal(4)}},
+					},
+				},
+				Sources: map[SourceRef]SourceBlock{
+					{
+						Type: "virtualbox-iso",
+						Name: "ubuntu-1204",
+					}: {
+						Type:      "virtualbox-iso",
+						Name:      "ubuntu-1204",
+						Resources: &packer.BuildResources{CPU: 4, Memory: 4 << 30},
+					},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{
+								SourceRef: SourceRef{Type: "virtualbox-iso", Name: "ubuntu-1204"},
+							},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:           "virtualbox-iso.ubuntu-1204",
+					BuilderType:    "virtualbox-iso",
+					Prepared:       true,
+					Builder:        emptyMockBuilder,
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+					Resources:      &packer.BuildResources{CPU: 4, Memory: 4 << 30},
+				},
+			},
+			false,
+		},
+		{"sources expanded from images",
+			defaultParser,
+			parseTestArgs{"testdata/sources/image.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "sources"),
+				InputVariables: Variables{
+					"size": &Variable{
+						Name:   "size",
+						Type:   cty.String,
+						Values: []VariableAssignment{{From: "default", Value: cty.StringVal("small")}},
+					},
+				},
+				Sources: map[SourceRef]SourceBlock{
+					{Type: "amazon-ebs", Name: "ubuntu"}:     {Type: "amazon-ebs", Name: "ubuntu"},
+					{Type: "virtualbox-iso", Name: "ubuntu"}: {Type: "virtualbox-iso", Name: "ubuntu"},
+					{Type: "amazon-ebs", Name: "windows"}:    {Type: "amazon-ebs", Name: "windows"},
+				},
+				Builds: Builds{
+					&BuildBlock{
+						Sources: []SourceUseBlock{
+							{SourceRef: SourceRef{Type: "amazon-ebs", Name: "ubuntu"}},
+							{SourceRef: SourceRef{Type: "virtualbox-iso", Name: "ubuntu"}},
+							{SourceRef: SourceRef{Type: "amazon-ebs", Name: "windows"}},
+						},
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{
+				&packer.CoreBuild{
+					Type:        "amazon-ebs.ubuntu",
+					BuilderType: "amazon-ebs",
+					Prepared:    true,
+					Builder: &MockBuilder{
+						Config: MockConfig{
+							NestedMockConfig: NestedMockConfig{
+								String:      "t3.small",
+								SliceString: []string{"eu-west-1", "us-east-1"},
+								Tags:        []MockTag{},
+							},
+							NestedSlice: []NestedMockConfig{},
+						},
+					},
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+				&packer.CoreBuild{
+					Type:        "virtualbox-iso.ubuntu",
+					BuilderType: "virtualbox-iso",
+					Prepared:    true,
+					Builder: &MockBuilder{
+						Config: MockConfig{
+							NestedMockConfig: NestedMockConfig{
+								String: "ubuntu-ubuntu",
+								Tags:   []MockTag{},
+							},
+							NestedSlice: []NestedMockConfig{},
+						},
+					},
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+				&packer.CoreBuild{
+					Type:        "amazon-ebs.windows",
+					BuilderType: "amazon-ebs",
+					Prepared:    true,
+					Builder: &MockBuilder{
+						Config: MockConfig{
+							NestedMockConfig: NestedMockConfig{
+								String:      "m5.large",
+								SliceString: []string{"us-east-1"},
+								Tags:        []MockTag{},
+							},
+							NestedSlice: []NestedMockConfig{},
+						},
+					},
+					Provisioners:   []packer.CoreBuildProvisioner{},
+					PostProcessors: [][]packer.CoreBuildPostProcessor{},
+				},
+			},
+			false,
+		},
 		{"duplicate source",
 			defaultParser,
 			parseTestArgs{"testdata/sources/duplicate.pkr.hcl", nil, nil},
@@ -113,3 +388,31 @@ func TestParse_source(t *testing.T) {
 	}
 	testParse(t, tests)
 }
+
+func TestParse_scheduling_diagnostics(t *testing.T) {
+	tc := []struct {
+		name       string
+		scheduling string
+		line       int
+		column     int
+	}{
+		{"negative cpu", `cpu = -1`, 3, 11},
+		{"unparsable memory", `memory = "lots"`, 3, 14},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diags := getBasicParser().ParseSource([]byte(`source "virtualbox-iso" "ubuntu-1204" {
+  scheduling {
+    `+tt.scheduling+`
+  }
+}`), "<stdin>.pkr.hcl", ".", nil, nil)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error")
+			}
+			// the error points at the value of the attribute
+			if subject := diags[0].Subject; subject == nil || subject.Start.Line != tt.line || subject.Start.Column != tt.column {
+				t.Errorf("got subject %v, expected line %d column %d", subject, tt.line, tt.column)
+			}
+		})
+	}
+}
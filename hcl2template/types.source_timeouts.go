@@ -0,0 +1,140 @@
+package hcl2template
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SourceTimeouts are timeouts that packer handles the same way for every
+// source type.
+type SourceTimeouts struct {
+	// BootWait is how long to wait for the machine to boot before
+	// interacting with it. It sets the 'boot_wait' setting of the builder.
+	BootWait time.Duration
+	// ConnectTimeout is how long to wait for the communicator to connect. It
+	// sets the 'ssh_timeout' or 'winrm_timeout' setting of the builder,
+	// depending on the communicator used.
+	ConnectTimeout time.Duration
+	// Timeout is how long the whole build may take before it is cancelled.
+	Timeout time.Duration
+}
+
+// timeoutsBlock is a 'timeouts' block in a source:
+//  source "qemu" "example" {
+//    timeouts {
+//      boot_wait       = "10s"
+//      connect_timeout = "15m"
+//      timeout         = "2h"
+//    }
+//  }
+type timeoutsBlock struct {
+	BootWait       string `hcl:"boot_wait,optional"`
+	ConnectTimeout string `hcl:"connect_timeout,optional"`
+	Timeout        string `hcl:"timeout,optional"`
+}
+
+func (b *timeoutsBlock) timeouts(subject *hcl.Range) (*SourceTimeouts, hcl.Diagnostics) {
+	if b == nil {
+		return nil, nil
+	}
+	var diags hcl.Diagnostics
+	res := &SourceTimeouts{}
+	for _, setting := range []struct {
+		name  string
+		value string
+		out   *time.Duration
+	}{
+		{"boot_wait", b.BootWait, &res.BootWait},
+		{"connect_timeout", b.ConnectTimeout, &res.ConnectTimeout},
+		{"timeout", b.Timeout, &res.Timeout},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(setting.value)
+		if err == nil && d < 0 {
+			err = fmt.Errorf("duration must be positive")
+		}
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + sourceTimeoutsLabel + " " + setting.name,
+				Detail:   fmt.Sprintf("Could not parse %q, expected a duration like \"10m\": %s", setting.value, err),
+				Subject:  subject,
+			})
+			continue
+		}
+		*setting.out = d
+	}
+	return res, diags
+}
+
+// communicatorTimeoutSetting returns the builder setting to set for
+// connect_timeout, depending on the communicator of the builder config. It
+// returns an empty string when the builder does not connect to the machine.
+func communicatorTimeoutSetting(config cty.Value) string {
+	communicator := "ssh"
+	if config.Type().HasAttribute("communicator") {
+		if v := config.GetAttr("communicator"); v.IsKnown() && !v.IsNull() && v.Type() == cty.String {
+			communicator = v.AsString()
+		}
+	}
+	switch communicator {
+	case "none":
+		return ""
+	case "winrm":
+		return "winrm_timeout"
+	default:
+		return "ssh_timeout"
+	}
+}
+
+// apply sets the settings of the decoded builder config corresponding to the
+// timeouts. It is an error to set a timeout the builder has no setting for,
+// or that is also set directly in the builder config.
+func (t *SourceTimeouts) apply(builderType string, config cty.Value, subject *hcl.Range) (cty.Value, hcl.Diagnostics) {
+	if t == nil || config.IsNull() || !config.IsKnown() || !config.Type().IsObjectType() {
+		return config, nil
+	}
+	var diags hcl.Diagnostics
+	values := config.AsValueMap()
+	if values == nil {
+		values = map[string]cty.Value{}
+	}
+	for _, setting := range []struct {
+		name    string
+		builder string
+		value   time.Duration
+	}{
+		{"boot_wait", "boot_wait", t.BootWait},
+		{"connect_timeout", communicatorTimeoutSetting(config), t.ConnectTimeout},
+	} {
+		if setting.value == 0 || setting.builder == "" {
+			continue
+		}
+		if !config.Type().HasAttribute(setting.builder) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported " + sourceTimeoutsLabel + " " + setting.name,
+				Detail:   fmt.Sprintf("The %s builder has no %q setting.", builderType, setting.builder),
+				Subject:  subject,
+			})
+			continue
+		}
+		if !values[setting.builder].IsNull() {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting " + sourceTimeoutsLabel + " " + setting.name,
+				Detail: fmt.Sprintf("%s.%s and %s are both set, remove one of them.",
+					sourceTimeoutsLabel, setting.name, setting.builder),
+				Subject: subject,
+			})
+			continue
+		}
+		values[setting.builder] = cty.StringVal(setting.value.String())
+	}
+	return cty.ObjectVal(values), diags
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/packer/hcl2template/addrs"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/convert"
 )
@@ -24,6 +25,9 @@ const badIdentifierDetail = "A name must start with a letter or underscore and m
 type LocalBlock struct {
 	Name string
 	Expr hcl.Expression
+	// Range is the definition of the local block, or the name of the local in
+	// a locals block.
+	Range hcl.Range
 	// When Sensitive is set to true Packer will try its best to hide/obfuscate
 	// the variable from the output stream. By replacing the text.
 	Sensitive bool
@@ -39,6 +43,22 @@ type VariableAssignment struct {
 	Expr  hcl.Expression
 }
 
+// origin tells where the value of the variable name was set from: its
+// default, its PKR_VAR_ environment variable, the position in a var file, or
+// -var.
+func (a VariableAssignment) origin(name string) string {
+	switch a.From {
+	case "env":
+		return VarEnvPrefix + name
+	case "varfile":
+		r := a.Expr.Range()
+		return fmt.Sprintf("%s:%d", r.Filename, r.Start.Line)
+	case "cmd":
+		return "-var"
+	}
+	return a.From
+}
+
 type Variable struct {
 	// Values contains possible values for the variable; The last value set
 	// from these will be the one used. If none is set; an error will be
@@ -59,6 +79,9 @@ type Variable struct {
 	// declaration, the type of the default variable will be used. This will
 	// allow to ensure that users set this variable correctly.
 	Type cty.Type
+	// Unit is set for the variables declared with `type = duration` or
+	// `type = size`, their Type is then cty.String.
+	Unit VariableUnit
 	// Common name of the variable
 	Name string
 	// Description of the variable
@@ -143,7 +166,7 @@ func (v *Variable) validateValue(val VariableAssignment) (diags hcl.Diagnostics)
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  fmt.Sprintf("Invalid value for %s variable", val.From),
-				Detail:   fmt.Sprintf("%s\n\nThis was checked by the validation rule at %s.", validation.ErrorMessage, validation.DeclRange.String()),
+				Detail:   messages.CodeInvalidVariable.Annotate(fmt.Sprintf("%s\n\nThis was checked by the validation rule at %s.", validation.ErrorMessage, validation.DeclRange.String())),
 				Subject:  subj,
 			})
 		}
@@ -158,13 +181,16 @@ func (v *Variable) Value() (cty.Value, hcl.Diagnostics) {
 		return cty.UnknownVal(v.Type), hcl.Diagnostics{&hcl.Diagnostic{
 			Severity: hcl.DiagError,
 			Summary:  fmt.Sprintf("Unset variable %q", v.Name),
-			Detail: "A used variable must be set or have a default value; see " +
+			Detail: messages.CodeVariableNotSet.Annotate("A used variable must be set or have a default value; see " +
 				"https://packer.io/docs/templates/hcl_templates/syntax for " +
-				"details.",
+				"details."),
 			Context: v.Range.Ptr(),
 		}}
 	}
 	val := v.Values[len(v.Values)-1]
+	if diags := v.validateUnit(val); diags.HasErrors() {
+		return val.Value, diags
+	}
 	return val.Value, v.validateValue(v.Values[len(v.Values)-1])
 }
 
@@ -284,7 +310,8 @@ func decodeLocalBlock(block *hcl.Block, locals []*LocalBlock) (*LocalBlock, hcl.
 	}
 
 	l := &LocalBlock{
-		Name: name,
+		Name:  name,
+		Range: block.DefRange,
 	}
 
 	if attr, exists := content.Attributes["sensitive"]; exists {
@@ -338,13 +365,18 @@ func (variables *Variables) decodeVariableBlock(block *hcl.Block, ectx *hcl.Eval
 	}
 
 	if t, ok := content.Attributes["type"]; ok {
-		tp, moreDiags := typeexpr.Type(t.Expr)
-		diags = append(diags, moreDiags...)
-		if moreDiags.HasErrors() {
-			return diags
-		}
+		if unit, ok := variableUnit(t.Expr); ok {
+			v.Type = cty.String
+			v.Unit = unit
+		} else {
+			tp, moreDiags := typeexpr.Type(t.Expr)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				return diags
+			}
 
-		v.Type = tp
+			v.Type = tp
+		}
 	}
 
 	if attr, exists := content.Attributes["sensitive"]; exists {
@@ -591,7 +623,7 @@ func (cfg *PackerConfig) collectInputVariableValues(env []string, files []*hcl.F
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: hcl.DiagError,
 					Summary:  "Invalid value for variable",
-					Detail:   fmt.Sprintf("The value for %s is not compatible with the variable's type constraint: %s.", name, err),
+					Detail:   messages.CodeInvalidVariable.Annotate(fmt.Sprintf("The value for %s is not compatible with the variable's type constraint: %s.", name, err)),
 					Subject:  expr.Range().Ptr(),
 				})
 				val = cty.DynamicVal
@@ -658,11 +690,11 @@ func (cfg *PackerConfig) collectInputVariableValues(env []string, files []*hcl.F
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: sev,
 					Summary:  "Undefined variable",
-					Detail: fmt.Sprintf("A %q variable was set but was "+
+					Detail: messages.CodeUndeclaredVariable.Annotate(fmt.Sprintf("A %q variable was set but was "+
 						"not found in known variables. To declare "+
 						"variable %q, place this block in one of your "+
 						".pkr files, such as variables.pkr.hcl",
-						name, name),
+						name, name)),
 					Context: attr.Range.Ptr(),
 				})
 				continue
@@ -678,7 +710,7 @@ func (cfg *PackerConfig) collectInputVariableValues(env []string, files []*hcl.F
 					diags = append(diags, &hcl.Diagnostic{
 						Severity: hcl.DiagError,
 						Summary:  "Invalid value for variable",
-						Detail:   fmt.Sprintf("The value for %s is not compatible with the variable's type constraint: %s.", name, err),
+						Detail:   messages.CodeInvalidVariable.Annotate(fmt.Sprintf("The value for %s is not compatible with the variable's type constraint: %s.", name, err)),
 						Subject:  attr.Expr.Range().Ptr(),
 					})
 					val = cty.DynamicVal
@@ -726,7 +758,7 @@ func (cfg *PackerConfig) collectInputVariableValues(env []string, files []*hcl.F
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: hcl.DiagError,
 					Summary:  "Invalid argument value for -var variable",
-					Detail:   fmt.Sprintf("The received arg value for %s is not compatible with the variable's type constraint: %s.", name, err),
+					Detail:   messages.CodeInvalidVariable.Annotate(fmt.Sprintf("The received arg value for %s is not compatible with the variable's type constraint: %s.", name, err)),
 					Subject:  expr.Range().Ptr(),
 				})
 				val = cty.DynamicVal
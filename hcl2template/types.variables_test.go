@@ -419,9 +419,10 @@ func TestParse_variables(t *testing.T) {
 			},
 			false, false,
 			[]packersdk.Build{&packer.CoreBuild{
-				Type:     "null.null-builder",
-				Prepared: true,
-				Builder:  &null.Builder{},
+				Type:        "null.null-builder",
+				BuilderType: "null",
+				Prepared:    true,
+				Builder:     &null.Builder{},
 				Provisioners: []packer.CoreBuildProvisioner{
 					{
 						PType: "shell",
@@ -511,6 +512,52 @@ func TestParse_variables(t *testing.T) {
 			nil,
 			false,
 		},
+
+		{"duration and size variables",
+			defaultParser,
+			parseTestArgs{"testdata/variables/unit_types/valid.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "variables", "unit_types"),
+				InputVariables: Variables{
+					"boot_wait": &Variable{
+						Values: []VariableAssignment{{"default", cty.StringVal("1m30s"), nil}},
+						Name:   "boot_wait",
+						Type:   cty.String,
+						Unit:   DurationUnit,
+					},
+					"disk_size": &Variable{
+						Values: []VariableAssignment{{"default", cty.StringVal("40GB"), nil}},
+						Name:   "disk_size",
+						Type:   cty.String,
+						Unit:   SizeUnit,
+					},
+				},
+			},
+			false, false,
+			[]packersdk.Build{},
+			false,
+		},
+
+		{"duration variable - invalid default",
+			defaultParser,
+			parseTestArgs{"testdata/variables/unit_types/invalid_default.pkr.hcl", nil, nil},
+			&PackerConfig{
+				CorePackerVersionString: lockedVersion,
+				Basedir:                 filepath.Join("testdata", "variables", "unit_types"),
+				InputVariables: Variables{
+					"boot_wait": &Variable{
+						Values: []VariableAssignment{{"default", cty.StringVal("90 seconds"), nil}},
+						Name:   "boot_wait",
+						Type:   cty.String,
+						Unit:   DurationUnit,
+					},
+				},
+			},
+			true, true,
+			nil,
+			false,
+		},
 	}
 	testParse(t, tests)
 }
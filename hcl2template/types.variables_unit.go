@@ -0,0 +1,76 @@
+package hcl2template
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/packer/messages"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VariableUnit is the unit of a variable declared with `type = duration` or
+// `type = size`. Such a variable is a string, checked when it is set so that
+// a malformed timeout or disk size fails right away instead of deep into a
+// build:
+//
+//	variable "boot_wait" {
+//	  type    = duration
+//	  default = "90s"
+//	}
+type VariableUnit string
+
+const (
+	// DurationUnit variables are durations like "90s" or "1h30m".
+	DurationUnit VariableUnit = "duration"
+	// SizeUnit variables are byte sizes like "512MB" or "40GB".
+	SizeUnit VariableUnit = "size"
+)
+
+// variableUnit returns the unit of the type expression expr, if it is the
+// keyword of one.
+func variableUnit(expr hcl.Expression) (VariableUnit, bool) {
+	switch unit := VariableUnit(hcl.ExprAsKeyword(expr)); unit {
+	case DurationUnit, SizeUnit:
+		return unit, true
+	}
+	return "", false
+}
+
+// check returns an error when s is not a valid value of the unit.
+func (u VariableUnit) check(s string) error {
+	switch u {
+	case DurationUnit:
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("%q is not a valid duration, expected something like \"90s\" or \"5m\"", s)
+		}
+	case SizeUnit:
+		var size datasize.ByteSize
+		if err := size.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%q is not a valid size, expected something like \"512MB\" or \"40GB\"", s)
+		}
+	}
+	return nil
+}
+
+// validateUnit checks that the value of a duration or size variable is valid.
+func (v *Variable) validateUnit(val VariableAssignment) hcl.Diagnostics {
+	if v.Unit == "" || !val.Value.IsKnown() || val.Value.IsNull() || val.Value.Type() != cty.String {
+		return nil
+	}
+	err := v.Unit.check(val.Value.AsString())
+	if err == nil {
+		return nil
+	}
+	subj := v.Range.Ptr()
+	if val.Expr != nil {
+		subj = val.Expr.Range().Ptr()
+	}
+	return hcl.Diagnostics{&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Invalid value for %s variable", val.From),
+		Detail:   messages.CodeInvalidVariable.Annotate(fmt.Sprintf("The %s variable is a %s: %s.", v.Name, v.Unit, err)),
+		Subject:  subj,
+	}}
+}
@@ -0,0 +1,162 @@
+package hcl2template
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/packer/packer/messages"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// References are the input variables, locals and data sources referenced by
+// a config. A reference to a whole object, like `var` or `data.amazon-ami`,
+// or with an index that is only known once evaluated, like `var[local.name]`,
+// references all of its values.
+type References struct {
+	variables map[string]bool
+	locals    map[string]bool
+	data      map[DatasourceRef]bool
+
+	allVariables, allLocals bool
+	// allData are the types of data sources referenced as a whole; "" when
+	// `data` itself is referenced.
+	allData map[string]bool
+}
+
+// FindReferences returns the references of the top-level blocks of bodies, the
+// files of a config. The variable blocks are left out: their validation rules
+// reference the variable they declare, which does not make it used.
+func FindReferences(bodies []*hclsyntax.Body) *References {
+	refs := &References{
+		variables: map[string]bool{},
+		locals:    map[string]bool{},
+		data:      map[DatasourceRef]bool{},
+		allData:   map[string]bool{},
+	}
+	for _, body := range bodies {
+		for _, block := range body.Blocks {
+			switch block.Type {
+			case variableLabel, variablesLabel:
+				continue
+			}
+			for _, traversal := range bodyVariables(block.Body) {
+				refs.add(traversal)
+			}
+		}
+	}
+	return refs
+}
+
+// VariableUsed tells whether the input variable name is referenced.
+func (r *References) VariableUsed(name string) bool {
+	return r.allVariables || r.variables[name]
+}
+
+// LocalUsed tells whether the local name is referenced.
+func (r *References) LocalUsed(name string) bool {
+	return r.allLocals || r.locals[name]
+}
+
+// DataSourceUsed tells whether the data source ref is referenced.
+func (r *References) DataSourceUsed(ref DatasourceRef) bool {
+	return r.allData[""] || r.allData[ref.Type] || r.data[ref]
+}
+
+func (r *References) add(traversal hcl.Traversal) {
+	name := func(i int) (string, bool) {
+		if len(traversal) <= i {
+			return "", false
+		}
+		switch step := traversal[i].(type) {
+		case hcl.TraverseAttr:
+			return step.Name, true
+		case hcl.TraverseIndex:
+			if step.Key.Type() == cty.String && step.Key.IsKnown() {
+				return step.Key.AsString(), true
+			}
+		}
+		return "", false
+	}
+
+	switch traversal.RootName() {
+	case inputVariablesAccessor:
+		if n, ok := name(1); ok {
+			r.variables[n] = true
+		} else {
+			r.allVariables = true
+		}
+	case localsAccessor:
+		if n, ok := name(1); ok {
+			r.locals[n] = true
+		} else {
+			r.allLocals = true
+		}
+	case dataAccessor:
+		typ, ok := name(1)
+		if !ok {
+			r.allData[""] = true
+			return
+		}
+		if n, ok := name(2); ok {
+			r.data[DatasourceRef{Type: typ, Name: n}] = true
+		} else {
+			r.allData[typ] = true
+		}
+	}
+}
+
+// UnusedDeclarations returns a warning for every input variable, local and
+// data source of cfg that is declared but never referenced, by the sources,
+// the builds, the data sources or the other locals of cfg.
+//
+// The references of the files in the JSON or YAML syntax, and of override
+// files, cannot all be found: configs with such files are not checked.
+func (cfg *PackerConfig) UnusedDeclarations() hcl.Diagnostics {
+	bodies := make([]*hclsyntax.Body, 0, len(cfg.files))
+	for _, file := range cfg.files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil
+		}
+		bodies = append(bodies, body)
+	}
+	refs := FindReferences(bodies)
+
+	var diags hcl.Diagnostics
+	unused := func(kind, ref string, rng hcl.Range) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  fmt.Sprintf("Unused %s", kind),
+			Detail: messages.CodeUnusedDeclaration.Annotate(fmt.Sprintf(
+				"%s is declared but never referenced.", ref)),
+			Subject: rng.Ptr(),
+		})
+	}
+
+	for name, v := range cfg.InputVariables {
+		if !refs.VariableUsed(name) {
+			unused("variable", inputVariablesAccessor+"."+name, v.Range)
+		}
+	}
+	for _, local := range cfg.LocalBlocks {
+		if !refs.LocalUsed(local.Name) {
+			unused("local", localsAccessor+"."+local.Name, local.Range)
+		}
+	}
+	for ref, ds := range cfg.Datasources {
+		if !refs.DataSourceUsed(ref) {
+			unused("data source", fmt.Sprintf("%s.%s.%s", dataAccessor, ref.Type, ref.Name), ds.block.DefRange)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Subject, diags[j].Subject
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return cfg.suppressWarnings(diags)
+}
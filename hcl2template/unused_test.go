@@ -0,0 +1,51 @@
+package hcl2template
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/packer/messages"
+)
+
+func TestPackerConfig_UnusedDeclarations(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{
+			"testdata/unused/basic.pkr.hcl",
+			[]string{
+				"6:1: Unused variable: var.unused",
+				"22:3: Unused local: local.unused_name",
+				"33:1: Unused data source: data.amazon-ami.unused",
+			},
+		},
+		// JSON configs are not checked
+		{"testdata/convert/basic.pkr.json", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			cfg, diags := getBasicParser().Parse(tt.file, nil, nil)
+			if diags.HasErrors() {
+				t.Fatalf("Parse: %s", diags)
+			}
+			var got []string
+			for _, diag := range cfg.UnusedDeclarations() {
+				if diag.Severity != hcl.DiagWarning {
+					t.Errorf("expected a warning, got %s", diag)
+				}
+				if !strings.Contains(diag.Detail, string(messages.CodeUnusedDeclaration)) {
+					t.Errorf("expected the code of unused declarations in %q", diag.Detail)
+				}
+				got = append(got, fmt.Sprintf("%d:%d: %s: %s", diag.Subject.Start.Line, diag.Subject.Start.Column,
+					diag.Summary, strings.SplitN(diag.Detail, " ", 2)[0]))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected diagnostics: %s", diff)
+			}
+		})
+	}
+}
@@ -3,14 +3,17 @@ package hcl2template
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/gobwas/glob"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/packer/hcl2template/repl"
 	hcl2shim "github.com/hashicorp/packer/hcl2template/shim"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -20,6 +23,7 @@ func warningErrorsToDiags(block *hcl.Block, warnings []string, err error) hcl.Di
 	for _, warning := range warnings {
 		diags = append(diags, &hcl.Diagnostic{
 			Summary:  warning,
+			Detail:   messages.CodePluginWarning.Annotate(""),
 			Subject:  &block.DefRange,
 			Severity: hcl.DiagWarning,
 		})
@@ -42,13 +46,28 @@ func isDir(name string) (bool, error) {
 	return s.IsDir(), nil
 }
 
+// resolvePath returns the absolute path of name, with its symbolic links
+// resolved when it exists, so that the path variables have the same value
+// whatever the command and however the config was designated.
+func resolvePath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name, err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
 // GetHCL2Files returns two slices of json formatted and hcl formatted files,
 // hclSuffix and jsonSuffix tell which file is what. Filename can be a folder
 // or a file.
 //
-// When filename is a folder all files of folder matching the suffixes will be
-// returned. Otherwise if filename references a file and filename matches one
-// of the suffixes it is returned in the according slice.
+// When filename is a folder all files of folder matching the suffixes and not
+// excluded by its .packerignore file will be returned. Otherwise if filename
+// references a file and filename matches one of the suffixes it is returned
+// in the according slice.
 func GetHCL2Files(filename, hclSuffix, jsonSuffix string) (hclFiles, jsonFiles []string, diags hcl.Diagnostics) {
 	if filename == "" {
 		return
@@ -82,10 +101,19 @@ func GetHCL2Files(filename, hclSuffix, jsonSuffix string) (hclFiles, jsonFiles [
 		diags = append(diags, diag)
 		return nil, nil, diags
 	}
+	ignore, moreDiags := loadPackerIgnore(filename)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return nil, nil, diags
+	}
 	for _, fileInfo := range fileInfos {
 		if fileInfo.IsDir() {
 			continue
 		}
+		if ignore.ignores(fileInfo.Name()) {
+			log.Printf("[TRACE] %s is ignored by %s", fileInfo.Name(), PackerIgnoreFile)
+			continue
+		}
 		filename := filepath.Join(filename, fileInfo.Name())
 		if strings.HasSuffix(filename, hclSuffix) {
 			hclFiles = append(hclFiles, filename)
@@ -97,13 +125,37 @@ func GetHCL2Files(filename, hclSuffix, jsonSuffix string) (hclFiles, jsonFiles [
 	return hclFiles, jsonFiles, diags
 }
 
-// Convert -only and -except globs to glob.Glob instances.
+// regexpFilter is a -only or -except regular expression, matching like a
+// glob.Glob does.
+type regexpFilter struct {
+	*regexp.Regexp
+}
+
+func (r regexpFilter) Match(s string) bool {
+	return r.MatchString(s)
+}
+
+// compileFilter compiles a -only or -except pattern: a regular expression
+// when it is enclosed in slashes, like /^amazon-.*-prod$/, a glob otherwise.
+func compileFilter(pattern string) (glob.Glob, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		return regexpFilter{re}, nil
+	}
+	return glob.Compile(pattern)
+}
+
+// Convert -only and -except globs and regular expressions to glob.Glob
+// instances.
 func convertFilterOption(patterns []string, optionName string) ([]glob.Glob, hcl.Diagnostics) {
 	var globs []glob.Glob
 	var diags hcl.Diagnostics
 
 	for _, pattern := range patterns {
-		g, err := glob.Compile(pattern)
+		g, err := compileFilter(pattern)
 		if err != nil {
 			diags = append(diags, &hcl.Diagnostic{
 				Summary:  fmt.Sprintf("Invalid -%s pattern %s: %s", optionName, pattern, err),
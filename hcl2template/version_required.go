@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
 // CheckCoreVersionRequirements visits each of the block in the given
@@ -26,10 +27,10 @@ func (cfg *PackerConfig) CheckCoreVersionRequirements(coreVersion *version.Versi
 			diags = diags.Append(&hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Unsupported Packer Core version",
-				Detail: fmt.Sprintf(
+				Detail: messages.CodeVersionRequired.Annotate(fmt.Sprintf(
 					"This configuration does not support Packer version %s. To proceed, either choose another supported Packer version or update this version constraint. Version constraints are normally set for good reason, so updating the constraint may lead to other errors or unexpected behavior.",
 					coreVersion.String(),
-				),
+				)),
 				Subject: constraint.DeclRange.Ptr(),
 			})
 		}
@@ -0,0 +1,83 @@
+package hcl2template
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// ParseYAMLFile parses a YAML config file. YAML files follow the JSON syntax
+// of HCL, of which YAML is a superset: a YAML file is converted to JSON and
+// then parsed as a '.pkr.json' file would be. Diagnostics will then show the
+// JSON equivalent of the YAML file.
+func (p *Parser) ParseYAMLFile(filename string) (*hcl.File, hcl.Diagnostics) {
+	if f := p.Files()[filename]; f != nil {
+		return f, nil
+	}
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   fmt.Sprintf("The configuration file %q could not be read.", filename),
+		}}
+	}
+	value, err := ctyyaml.Standard.Unmarshal(src, cty.DynamicPseudoType)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to parse YAML file",
+			Detail:   fmt.Sprintf("%s: %s", filename, err),
+		}}
+	}
+	if value.IsNull() {
+		// an empty file is an empty config
+		value = cty.EmptyObjectVal
+	}
+	js, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to convert YAML file",
+			Detail:   fmt.Sprintf("%s: %s", filename, err),
+		}}
+	}
+	return p.ParseJSON(js, filename)
+}
+
+// getYAMLFiles returns the YAML config files of filename. Filename can be a
+// folder or a file.
+func getYAMLFiles(filename string) ([]string, hcl.Diagnostics) {
+	if filename == "" {
+		return nil, nil
+	}
+	isDir, err := isDir(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Cannot tell wether " + filename + " is a directory",
+			Detail:   err.Error(),
+		}}
+	}
+	if !isDir {
+		if strings.HasSuffix(filename, hcl2YamlFileExt) {
+			return []string{filename}, nil
+		}
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(filename, "*"+hcl2YamlFileExt))
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Cannot read hcl directory",
+			Detail:   err.Error(),
+		}}
+	}
+	return files, nil
+}
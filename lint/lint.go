@@ -0,0 +1,302 @@
+// Package lint checks HCL2 configs for problems that are not errors, like
+// unused variables or hardcoded credentials, with a set of rules that can be
+// enabled, disabled or made errors by a .packerlint.hcl file.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/packer/hcl2template"
+)
+
+// SettingsFile is the name of the file configuring the rules of a folder.
+const SettingsFile = ".packerlint.hcl"
+
+// A Rule checks an HCL2 config for a kind of problem.
+type Rule interface {
+	// Check returns the issues found in cfg. Their Rule and Severity are set
+	// by Lint.
+	Check(cfg *Config) []Issue
+
+	// Synopsis returns a string description of what the rule checks.
+	Synopsis() string
+}
+
+// Rules is the map of all available rules, by name.
+var Rules map[string]Rule
+
+// RuleOrder is the order the rules are run in.
+var RuleOrder []string
+
+func init() {
+	Rules = map[string]Rule{
+		"unused-variable":          new(RuleUnusedVariable),
+		"unused-source":            new(RuleUnusedSource),
+		"deprecated-field":         new(RuleDeprecatedField),
+		"hardcoded-credential":     new(RuleHardcodedCredential),
+		"missing-required-plugins": new(RuleMissingRequiredPlugins),
+	}
+
+	RuleOrder = []string{
+		"unused-variable",
+		"unused-source",
+		"deprecated-field",
+		"hardcoded-credential",
+		"missing-required-plugins",
+	}
+}
+
+// Severity is the severity of an issue.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Issue is a problem found by a rule.
+type Issue struct {
+	Rule     string    `json:"rule"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message"`
+	Range    hcl.Range `json:"-"`
+	Filename string    `json:"filename"`
+	Line     int       `json:"line"`
+	Column   int       `json:"column"`
+}
+
+// Config is the HCL2 config being linted.
+type Config struct {
+	// Files are the parsed files of the config, by name. Only files in the
+	// native syntax are linted.
+	Files map[string]*hcl.File
+
+	// Builtin tells whether the component of type typ, of kind "source",
+	// "data", "provisioner" or "post-processor", is built into Packer and
+	// needs no plugin. When nil, no component is built in.
+	Builtin func(kind, typ string) bool
+}
+
+// LoadConfig parses the HCL2 files of path, a file or a folder.
+func LoadConfig(path string) (*Config, hcl.Diagnostics) {
+	files, _, diags := hcl2template.GetHCL2Files(path, ".pkr.hcl", ".pkr.json")
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if len(files) == 0 {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No HCL2 file to lint",
+			Detail:   fmt.Sprintf("%s has no .pkr.hcl file.", path),
+		})
+	}
+	parser := &hcl2template.Parser{Parser: hclparse.NewParser()}
+	for _, filename := range files {
+		_, moreDiags := parser.ParseHCLFile(filename)
+		diags = append(diags, moreDiags...)
+	}
+	return &Config{Files: parser.Files()}, diags
+}
+
+// bodies returns the bodies of the files of cfg, in the order of their names.
+func (cfg *Config) bodies() []*hclsyntax.Body {
+	names := make([]string, 0, len(cfg.Files))
+	for name := range cfg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var bodies []*hclsyntax.Body
+	for _, name := range names {
+		if body, ok := cfg.Files[name].Body.(*hclsyntax.Body); ok {
+			bodies = append(bodies, body)
+		}
+	}
+	return bodies
+}
+
+// blocks returns the top-level blocks of type typ of cfg.
+func (cfg *Config) blocks(typ string) []*hclsyntax.Block {
+	var blocks []*hclsyntax.Block
+	for _, body := range cfg.bodies() {
+		for _, block := range body.Blocks {
+			if block.Type == typ {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks
+}
+
+// builtin tells whether a component is built into Packer.
+func (cfg *Config) builtin(kind, typ string) bool {
+	return cfg.Builtin != nil && cfg.Builtin(kind, typ)
+}
+
+// Settings are the settings of the rules.
+type Settings struct {
+	Rules map[string]RuleSettings
+}
+
+// RuleSettings are the settings of a rule.
+type RuleSettings struct {
+	Enabled  bool
+	Severity Severity
+}
+
+// DefaultSettings enables all the rules, reporting warnings.
+func DefaultSettings() *Settings {
+	s := &Settings{Rules: map[string]RuleSettings{}}
+	for _, name := range RuleOrder {
+		s.Rules[name] = RuleSettings{Enabled: true, Severity: SeverityWarning}
+	}
+	return s
+}
+
+// LoadSettings reads the settings of the rules from filename, a .packerlint.hcl
+// file, over the default settings. A missing file is not an error.
+func LoadSettings(filename string) (*Settings, hcl.Diagnostics) {
+	settings := DefaultSettings()
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return settings, nil
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	content, moreDiags := file.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "rule", LabelNames: []string{"name"}}},
+	})
+	diags = append(diags, moreDiags...)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	for _, block := range content.Blocks {
+		var rule struct {
+			Enabled  *bool     `hcl:"enabled,optional"`
+			Severity *Severity `hcl:"severity,optional"`
+		}
+		if moreDiags := gohcl.DecodeBody(block.Body, nil, &rule); moreDiags.HasErrors() {
+			diags = append(diags, moreDiags...)
+			continue
+		}
+		name := block.Labels[0]
+		s, found := settings.Rules[name]
+		if !found {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown rule",
+				Detail:   fmt.Sprintf("There is no %q rule, see packer lint -help for the list of rules.", name),
+				Subject:  block.LabelRanges[0].Ptr(),
+			})
+			continue
+		}
+		if rule.Enabled != nil {
+			s.Enabled = *rule.Enabled
+		}
+		if rule.Severity != nil {
+			if *rule.Severity != SeverityWarning && *rule.Severity != SeverityError {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid severity",
+					Detail:   fmt.Sprintf("The severity of a rule is %q or %q.", SeverityWarning, SeverityError),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			s.Severity = *rule.Severity
+		}
+		settings.Rules[name] = s
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return settings, diags
+}
+
+// Lint runs the enabled rules of settings on cfg, and returns their issues
+// sorted by position.
+func Lint(cfg *Config, settings *Settings) []Issue {
+	var issues []Issue
+	for _, name := range RuleOrder {
+		s := settings.Rules[name]
+		if !s.Enabled {
+			continue
+		}
+		for _, issue := range Rules[name].Check(cfg) {
+			issue.Rule = name
+			issue.Severity = s.Severity
+			issue.Filename = issue.Range.Filename
+			issue.Line = issue.Range.Start.Line
+			issue.Column = issue.Range.Start.Column
+			issues = append(issues, issue)
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].Range, issues[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return issues
+}
+
+// HasErrors tells whether one of issues is an error.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// component is a source, data source, provisioner or post-processor block of
+// a config.
+type component struct {
+	// kind is "source", "data", "provisioner" or "post-processor".
+	kind  string
+	typ   string
+	block *hclsyntax.Block
+}
+
+// components returns the components of cfg, in the order of the files.
+func (cfg *Config) components() []component {
+	var components []component
+	for _, body := range cfg.bodies() {
+		for _, block := range body.Blocks {
+			switch block.Type {
+			case "source", "data":
+				if len(block.Labels) == 2 {
+					components = append(components, component{block.Type, block.Labels[0], block})
+				}
+			case "build", "post-processors":
+				components = append(components, buildComponents(block.Body)...)
+			}
+		}
+	}
+	return components
+}
+
+func buildComponents(body *hclsyntax.Body) []component {
+	var components []component
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "provisioner", "post-processor":
+			if len(block.Labels) == 1 {
+				components = append(components, component{block.Type, block.Labels[0], block})
+			}
+		case "post-processors", "parallel":
+			components = append(components, buildComponents(block.Body)...)
+		}
+	}
+	return components
+}
@@ -0,0 +1,128 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testBuiltin(kind, typ string) bool {
+	switch kind + "." + typ {
+	case "source.null", "provisioner.shell", "provisioner.shell-local", "post-processor.manifest":
+		return true
+	}
+	return false
+}
+
+func testLint(t *testing.T, path string, settings *Settings) []string {
+	cfg, diags := LoadConfig(path)
+	if diags.HasErrors() {
+		t.Fatalf("LoadConfig: %s", diags)
+	}
+	cfg.Builtin = testBuiltin
+
+	var res []string
+	for _, issue := range Lint(cfg, settings) {
+		res = append(res, fmt.Sprintf("%d:%d %s %s", issue.Line, issue.Column, issue.Severity, issue.Rule))
+	}
+	return res
+}
+
+func TestLint(t *testing.T) {
+	expected := []string{
+		"14:1 warning unused-variable",
+		"20:13 warning hardcoded-credential",
+		"25:3 warning deprecated-field",
+		"26:25 warning hardcoded-credential",
+		"29:1 warning unused-source",
+		"33:8 warning missing-required-plugins",
+		"49:5 warning deprecated-field",
+	}
+	res := testLint(t, "test-fixtures/config", DefaultSettings())
+	if diff := cmp.Diff(expected, res); diff != "" {
+		t.Errorf("unexpected issues: %s", diff)
+	}
+
+	if res := testLint(t, "test-fixtures/clean", DefaultSettings()); len(res) != 0 {
+		t.Errorf("expected no issue, got %v", res)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	settings, diags := LoadSettings("test-fixtures/settings.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("LoadSettings: %s", diags)
+	}
+	expected := []string{
+		"14:1 warning unused-variable",
+		"20:13 error hardcoded-credential",
+		"25:3 warning deprecated-field",
+		"26:25 error hardcoded-credential",
+		"33:8 warning missing-required-plugins",
+		"49:5 warning deprecated-field",
+	}
+	res := testLint(t, "test-fixtures/config", settings)
+	if diff := cmp.Diff(expected, res); diff != "" {
+		t.Errorf("unexpected issues: %s", diff)
+	}
+
+	if _, diags := LoadSettings("test-fixtures/unknown-rule.hcl"); !diags.HasErrors() {
+		t.Error("expected an error for an unknown rule")
+	}
+	if _, diags := LoadSettings(filepath.Join("test-fixtures", "missing.hcl")); diags.HasErrors() {
+		t.Errorf("a missing settings file should not be an error: %s", diags)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	cfg, _ := LoadConfig("test-fixtures/config")
+	cfg.Builtin = testBuiltin
+	issues := Lint(cfg, DefaultSettings())
+
+	b := &bytes.Buffer{}
+	if err := WriteSARIF(b, issues); err != nil {
+		t.Fatalf("WriteSARIF: %s", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %s", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %s", b.String())
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != len(RuleOrder) || len(log.Runs[0].Results) != len(issues) {
+		t.Errorf("unexpected rules or results: %s", b.String())
+	}
+}
+
+func TestRuleUnusedVariable(t *testing.T) {
+	tests := []struct {
+		dir      string
+		expected []string
+	}{
+		// var[local.pick] can reference any variable
+		{"dynamic", nil},
+		// the references of a validation rule to its own variable do not
+		// count
+		{"validation", []string{`1:1 Variable "validated" is declared but never used.`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			cfg, diags := LoadConfig(filepath.Join("test-fixtures", "unused-variable", tt.dir))
+			if diags.HasErrors() {
+				t.Fatalf("LoadConfig: %s", diags)
+			}
+			var res []string
+			for _, issue := range new(RuleUnusedVariable).Check(cfg) {
+				res = append(res, fmt.Sprintf("%d:%d %s", issue.Range.Start.Line, issue.Range.Start.Column, issue.Message))
+			}
+			if diff := cmp.Diff(tt.expected, res); diff != "" {
+				t.Errorf("unexpected issues: %s", diff)
+			}
+		})
+	}
+}
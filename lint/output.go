@@ -0,0 +1,122 @@
+package lint
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Diagnostics returns issues as diagnostics, to be written with their snippet
+// of config.
+func Diagnostics(issues []Issue) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, issue := range issues {
+		severity := hcl.DiagWarning
+		if issue.Severity == SeverityError {
+			severity = hcl.DiagError
+		}
+		rng := issue.Range
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: severity,
+			Summary:  issue.Rule,
+			Detail:   issue.Message,
+			Subject:  &rng,
+		})
+	}
+	return diags
+}
+
+// WriteJSON writes issues as a JSON array.
+func WriteJSON(w io.Writer, issues []Issue) error {
+	if issues == nil {
+		issues = []Issue{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(issues)
+}
+
+// sarifLog is a log in the Static Analysis Results Interchange Format 2.1.0,
+// read by code scanning services.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			InformationURI string      `json:"informationUri"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+			EndLine     int `json:"endLine"`
+			EndColumn   int `json:"endColumn"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// WriteSARIF writes issues as a SARIF log.
+func WriteSARIF(w io.Writer, issues []Issue) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "packer lint"
+	run.Tool.Driver.InformationURI = "https://www.packer.io/docs/commands/lint"
+	for _, name := range RuleOrder {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               name,
+			ShortDescription: sarifMessage{Text: Rules[name].Synopsis()},
+		})
+	}
+	for _, issue := range issues {
+		var location sarifLocation
+		location.PhysicalLocation.ArtifactLocation.URI = issue.Range.Filename
+		location.PhysicalLocation.Region.StartLine = issue.Range.Start.Line
+		location.PhysicalLocation.Region.StartColumn = issue.Range.Start.Column
+		location.PhysicalLocation.Region.EndLine = issue.Range.End.Line
+		location.PhysicalLocation.Region.EndColumn = issue.Range.End.Column
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    issue.Rule,
+			Level:     string(issue.Severity),
+			Message:   sarifMessage{Text: issue.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,80 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/hashicorp/packer/fix"
+)
+
+// RuleDeprecatedField reports the settings of components that `packer fix`
+// would change, from the deprecated options of the fixers.
+type RuleDeprecatedField struct{}
+
+func (RuleDeprecatedField) Synopsis() string {
+	return "Reports deprecated settings of sources, provisioners and post-processors."
+}
+
+func (RuleDeprecatedField) Check(cfg *Config) []Issue {
+	var issues []Issue
+	for _, c := range cfg.components() {
+		names := make([]string, 0, len(c.block.Body.Attributes))
+		for name := range c.block.Body.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fixer := deprecatingFixer(c.kind, c.typ, name)
+			if fixer == nil {
+				continue
+			}
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("%q is deprecated: %s", name, fixer.Synopsis()),
+				Range:   c.block.Body.Attributes[name].NameRange,
+			})
+		}
+	}
+	return issues
+}
+
+// deprecatingFixer returns the fixer deprecating the option of a component, or
+// nil.
+func deprecatingFixer(kind, typ, option string) fix.Fixer {
+	for _, name := range fix.FixerOrder {
+		fixer := fix.Fixers[name]
+		for key, options := range fixer.DeprecatedOptions() {
+			if !deprecatedOptionsApply(key, kind, typ) {
+				continue
+			}
+			for _, o := range options {
+				if o == option {
+					return fixer
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deprecatedOptionsApply tells whether the key of the deprecated options of a
+// fixer applies to a component. Keys are globs on the type of a component, like
+// "*amazon*", plugin ids of builders, like "packer.docker", or plugin ids of
+// post-processors, like "packer.post-processor.manifest".
+func deprecatedOptionsApply(key, kind, typ string) bool {
+	if strings.HasPrefix(key, "packer.post-processor.") {
+		return kind == "post-processor" && typ == strings.TrimPrefix(key, "packer.post-processor.")
+	}
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return kind == "source" && strings.HasPrefix(typ, key[i+1:])
+	}
+	if kind == "data" {
+		return false
+	}
+	g, err := glob.Compile(strings.ToLower(key))
+	if err != nil {
+		return false
+	}
+	return g.Match(strings.ToLower(typ))
+}
@@ -0,0 +1,65 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// RuleHardcodedCredential reports the credentials written in clear in a
+// config, like `ssh_password = "vagrant"`, instead of being set from a
+// sensitive variable or a data source.
+type RuleHardcodedCredential struct{}
+
+// credentialNameRe matches the names of settings holding credentials.
+var credentialNameRe = regexp.MustCompile(`(?i)(^|_)(password|passwd|secret|secret_key|token|api_key|access_key|client_secret|private_key)$`)
+
+func (RuleHardcodedCredential) Synopsis() string {
+	return "Reports passwords, tokens and keys written in clear in the config."
+}
+
+func (RuleHardcodedCredential) Check(cfg *Config) []Issue {
+	var issues []Issue
+	for _, body := range cfg.bodies() {
+		_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			attr, ok := node.(*hclsyntax.Attribute)
+			if !ok || !credentialNameRe.MatchString(attr.Name) || !literalString(attr.Expr) {
+				return nil
+			}
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("%q is set to a hardcoded credential, use a sensitive variable instead.", attr.Name),
+				Range:   attr.Expr.Range(),
+			})
+			return nil
+		})
+	}
+
+	// the default of a credential variable is a hardcoded credential too
+	for _, block := range cfg.blocks("variable") {
+		if len(block.Labels) != 1 || !credentialNameRe.MatchString(block.Labels[0]) {
+			continue
+		}
+		if attr, found := block.Body.Attributes["default"]; found && literalString(attr.Expr) {
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("Variable %q has a hardcoded credential as default, set it when running Packer instead.", block.Labels[0]),
+				Range:   attr.Expr.Range(),
+			})
+		}
+	}
+	return issues
+}
+
+// literalString tells whether expr is a constant, non-empty string.
+func literalString(expr hclsyntax.Expression) bool {
+	if len(expr.Variables()) > 0 {
+		return false
+	}
+	v, diags := expr.Value(nil)
+	if diags.HasErrors() || !v.IsKnown() || v.IsNull() || v.Type() != cty.String {
+		return false
+	}
+	return v.AsString() != ""
+}
@@ -0,0 +1,53 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleMissingRequiredPlugins reports the components that are not built into
+// Packer and that no plugin of the required_plugins block provides, so that
+// packer init would not install them.
+type RuleMissingRequiredPlugins struct{}
+
+func (RuleMissingRequiredPlugins) Synopsis() string {
+	return "Reports components of plugins missing from the required_plugins block."
+}
+
+func (RuleMissingRequiredPlugins) Check(cfg *Config) []Issue {
+	var plugins []string
+	for _, packer := range cfg.blocks("packer") {
+		for _, block := range packer.Body.Blocks {
+			if block.Type != "required_plugins" {
+				continue
+			}
+			for name := range block.Body.Attributes {
+				plugins = append(plugins, name)
+			}
+		}
+	}
+
+	var issues []Issue
+	for _, c := range cfg.components() {
+		if cfg.builtin(c.kind, c.typ) || providedBy(c.typ, plugins) {
+			continue
+		}
+		issues = append(issues, Issue{
+			Message: fmt.Sprintf("The %s type %q is not built into Packer, add the plugin providing it to the required_plugins block so that packer init installs it.", c.kind, c.typ),
+			Range:   c.block.LabelRanges[0],
+		})
+	}
+	return issues
+}
+
+// providedBy tells whether one of plugins provides the component of type typ.
+// The components of a plugin are named after it, like amazon-ebs for the amazon
+// plugin, or have its name when the plugin has a single component.
+func providedBy(typ string, plugins []string) bool {
+	for _, plugin := range plugins {
+		if typ == plugin || strings.HasPrefix(typ, plugin+"-") {
+			return true
+		}
+	}
+	return false
+}
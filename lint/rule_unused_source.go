@@ -0,0 +1,50 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+)
+
+// RuleUnusedSource reports the sources that no build uses.
+type RuleUnusedSource struct{}
+
+func (RuleUnusedSource) Synopsis() string {
+	return "Reports sources that are not used by any build."
+}
+
+func (RuleUnusedSource) Check(cfg *Config) []Issue {
+	used := map[string]bool{}
+	for _, build := range cfg.blocks("build") {
+		if attr, found := build.Body.Attributes["sources"]; found {
+			var sources []string
+			if diags := gohcl.DecodeExpression(attr.Expr, nil, &sources); diags.HasErrors() {
+				// the used sources can not be known, they could all be used
+				return nil
+			}
+			for _, source := range sources {
+				used[source] = true
+			}
+		}
+		for _, block := range build.Body.Blocks {
+			if block.Type == "source" && len(block.Labels) == 1 {
+				used["source."+block.Labels[0]] = true
+			}
+		}
+	}
+
+	var issues []Issue
+	for _, block := range cfg.blocks("source") {
+		if len(block.Labels) != 2 {
+			continue
+		}
+		ref := fmt.Sprintf("source.%s.%s", block.Labels[0], block.Labels[1])
+		if !used[ref] {
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("Source %q is not used by any build.", ref),
+				Range:   block.DefRange(),
+			})
+		}
+	}
+	return issues
+}
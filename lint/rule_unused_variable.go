@@ -0,0 +1,41 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer/hcl2template"
+)
+
+// RuleUnusedVariable reports the input variables that are never referenced.
+// It finds references like `packer validate` does.
+type RuleUnusedVariable struct{}
+
+func (RuleUnusedVariable) Synopsis() string {
+	return "Reports input variables that are declared but never used."
+}
+
+func (RuleUnusedVariable) Check(cfg *Config) []Issue {
+	refs := hcl2template.FindReferences(cfg.bodies())
+
+	var issues []Issue
+	report := func(name string, rng hcl.Range) {
+		if !refs.VariableUsed(name) {
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("Variable %q is declared but never used.", name),
+				Range:   rng,
+			})
+		}
+	}
+	for _, block := range cfg.blocks("variable") {
+		if len(block.Labels) == 1 {
+			report(block.Labels[0], block.DefRange())
+		}
+	}
+	for _, block := range cfg.blocks("variables") {
+		for _, attr := range block.Body.Attributes {
+			report(attr.Name, attr.NameRange)
+		}
+	}
+	return issues
+}
@@ -0,0 +1,16 @@
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+source "null" "example" {
+  communicator = "none"
+}
+
+build {
+  sources = ["source.null.example"]
+
+  provisioner "shell-local" {
+    inline = ["echo ${var.password}"]
+  }
+}
@@ -0,0 +1,51 @@
+packer {
+  required_plugins {
+    amazon = {
+      version = ">= 1.0.0"
+      source  = "github.com/hashicorp/amazon"
+    }
+  }
+}
+
+variable "region" {
+  type = string
+}
+
+variable "unused" {
+  type = string
+}
+
+variable "api_token" {
+  type    = string
+  default = "s3cr3t"
+}
+
+source "amazon-ebs" "ubuntu" {
+  region              = var.region
+  enhanced_networking = true
+  ssh_password        = "vagrant"
+}
+
+source "amazon-ebs" "forgotten" {
+  region = var["region"]
+}
+
+source "docker" "ubuntu" {
+  image        = "ubuntu"
+  ssh_password = var.api_token
+}
+
+build {
+  sources = ["source.amazon-ebs.ubuntu"]
+
+  source "docker.ubuntu" {
+  }
+
+  provisioner "shell" {
+    inline = ["echo hello"]
+  }
+
+  post-processor "manifest" {
+    filename = "manifest.json"
+  }
+}
@@ -0,0 +1,7 @@
+rule "unused-source" {
+  enabled = false
+}
+
+rule "hardcoded-credential" {
+  severity = "error"
+}
@@ -0,0 +1,3 @@
+rule "no-such-rule" {
+  enabled = false
+}
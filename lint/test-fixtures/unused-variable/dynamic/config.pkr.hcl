@@ -0,0 +1,20 @@
+variable "picked" {
+  type    = string
+  default = "picked"
+}
+
+locals {
+  pick = "picked"
+}
+
+source "null" "test" {
+  communicator = "none"
+}
+
+build {
+  sources = ["source.null.test"]
+
+  provisioner "shell" {
+    inline = [var[local.pick]]
+  }
+}
@@ -0,0 +1,17 @@
+variable "validated" {
+  type    = string
+  default = "validated"
+
+  validation {
+    condition     = length(var.validated) > 0
+    error_message = "The validated variable must not be empty."
+  }
+}
+
+source "null" "test" {
+  communicator = "none"
+}
+
+build {
+  sources = ["source.null.test"]
+}
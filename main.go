@@ -10,7 +10,9 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,6 +24,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 	"github.com/hashicorp/packer/command"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/packer/messages"
 	"github.com/hashicorp/packer/version"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/panicwrap"
@@ -49,6 +52,18 @@ func realMain() int {
 		return wrappedMain()
 	}
 
+	// The wrapped process applies -chdir, but the output of its errors is
+	// only logged: check the option here, where errors can still be seen.
+	if _, chdir, err := extractChdir(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	} else if chdir != "" {
+		if _, err := ioutil.ReadDir(chdir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error handling -chdir option: %s\n", err)
+			return 1
+		}
+	}
+
 	// Generate a UUID for this packer run and pass it to the environment.
 	// GenerateUUID always returns a nil error (based on rand.Read) so we'll
 	// just ignore it.
@@ -149,6 +164,22 @@ func wrappedMain() int {
 		runtime.Version(),
 		runtime.GOOS, runtime.GOARCH)
 
+	// -chdir is applied before anything else, so that everything - config,
+	// plugins and templates - is looked for as if packer was started from
+	// that directory.
+	args, chdir, err := extractChdir(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	if chdir != "" {
+		if err := os.Chdir(chdir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error handling -chdir option: %s\n", err)
+			return 1
+		}
+		log.Printf("[INFO] Changed working directory to %s", chdir)
+	}
+
 	// The config being loaded here is the Packer config -- it defines
 	// the location of third party builder plugins, plugin ports to use, and
 	// whether to disable telemetry. It is a global config.
@@ -160,6 +191,14 @@ func wrappedMain() int {
 		return 1
 	}
 
+	// Load the translations of user-facing messages, a missing or broken
+	// translation only means messages are shown in English.
+	if configDir, err := pathing.ConfigDir(); err != nil {
+		log.Printf("[WARN] Error detecting the config directory, not loading translations: %s", err)
+	} else if err := messages.Load(filepath.Join(configDir, "locales")); err != nil {
+		log.Printf("[WARN] %s", err)
+	}
+
 	// Fire off the checkpoint.
 	go runCheckpoint(config)
 	if !config.DisableCheckpoint {
@@ -177,31 +216,31 @@ func wrappedMain() int {
 
 	// Determine if we're in machine-readable mode by mucking around with
 	// the arguments...
-	args, machineReadable := extractMachineReadable(os.Args[1:])
+	args, machineReadable := extractMachineReadable(args)
 
 	defer packer.CleanupClients()
 
-	var ui packersdk.Ui
+	// The UI backend is set by the config file, PACKER_UI, or
+	// -machine-readable, from the weakest to the strongest.
+	uiName := config.Ui
+	if v := os.Getenv("PACKER_UI"); v != "" {
+		uiName = v
+	}
 	if machineReadable {
-		// Setup the UI as we're being machine-readable
-		ui = &packer.MachineReadableUi{
-			Writer: os.Stdout,
-		}
-
-		// Set this so that we don't get colored output in our machine-
-		// readable UI.
-		if err := os.Setenv("PACKER_NO_COLOR", "1"); err != nil {
-			fmt.Fprintf(os.Stderr, "Packer failed to initialize UI: %s\n", err)
-			return 1
-		}
-	} else {
-		basicUi := &packersdk.BasicUi{
-			Reader:      os.Stdin,
-			Writer:      os.Stdout,
-			ErrorWriter: os.Stdout,
-			PB:          &packersdk.NoopProgressTracker{},
-		}
-		ui = basicUi
+		uiName = "machine-readable"
+	}
+	if uiName == "" || inPlugin {
+		// Plugins talk to packer on their standard output: another UI, like
+		// a UI plugin writing there, would break the plugin protocol.
+		uiName = "plain"
+	}
+	uiOpts := packer.UiBackendOptions{
+		Reader:        os.Stdin,
+		Writer:        os.Stdout,
+		ErrorWriter:   os.Stdout,
+		PluginFolders: config.Plugins.KnownPluginFolders,
+	}
+	if uiName == "plain" {
 		if !inPlugin {
 			currentPID := os.Getpid()
 			backgrounded, err := checkProcess(currentPID)
@@ -214,12 +253,31 @@ func wrappedMain() int {
 			} else if TTY, err := openTTY(); err != nil {
 				fmt.Fprintf(os.Stderr, "No tty available: %s\n", err)
 			} else {
-				basicUi.TTY = TTY
-				basicUi.PB = &packer.UiProgressBar{}
+				uiOpts.TTY = TTY
 				defer TTY.Close()
 			}
 		}
 	}
+	ui, err := packer.NewUi(uiName, uiOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Packer failed to initialize UI: %s\n", err)
+		return 1
+	}
+	if closer, ok := ui.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}()
+	}
+	if packer.IsStructuredUi(ui) {
+		// Set this so that we don't get colored output in our structured
+		// UI.
+		if err := os.Setenv("PACKER_NO_COLOR", "1"); err != nil {
+			fmt.Fprintf(os.Stderr, "Packer failed to initialize UI: %s\n", err)
+			return 1
+		}
+	}
 	// Create the CLI meta
 	CommandMeta = &command.Meta{
 		CoreConfig: &packer.CoreConfig{
@@ -232,11 +290,18 @@ func wrappedMain() int {
 		Ui: ui,
 	}
 
+	// Plugins can offer subcommands, run as `packer <plugin> <command>`. A
+	// plugin cannot shadow a command of packer.
+	commands := command.PluginCommandFactories(CommandMeta, config.Plugins.Commands, Commands)
+	for name, factory := range Commands {
+		commands[name] = factory
+	}
+
 	cli := &cli.CLI{
 		Args:         args,
 		Autocomplete: true,
-		Commands:     Commands,
-		HelpFunc:     excludeHelpFunc(Commands, []string{"plugin"}),
+		Commands:     commands,
+		HelpFunc:     globalOptionsHelpFunc(excludeHelpFunc(commands, []string{"plugin"})),
 		HelpWriter:   os.Stdout,
 		Name:         "packer",
 		Version:      version.Version,
@@ -278,6 +343,45 @@ func excludeHelpFunc(commands map[string]cli.CommandFactory, exclude []string) c
 	return cli.FilteredHelpFunc(helpCommands, cli.BasicHelpFunc("packer"))
 }
 
+// globalOptionsHelpFunc adds the options that go before the subcommand to
+// the help text.
+func globalOptionsHelpFunc(f cli.HelpFunc) cli.HelpFunc {
+	return func(commands map[string]cli.CommandFactory) string {
+		return f(commands) + `
+Global options (use these before the subcommand, if any):
+    -chdir=DIR    Switch to a different working directory before executing the
+                  given subcommand.
+`
+	}
+}
+
+// extractChdir checks the args for the global -chdir option, which has to
+// come before the subcommand, and returns the directory it is set to. It
+// modifies the args to remove this option.
+func extractChdir(args []string) ([]string, string, error) {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			// This is the subcommand, the options after it are its own.
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name != "chdir" && !strings.HasPrefix(name, "chdir=") {
+			continue
+		}
+		dir := strings.TrimPrefix(name, "chdir=")
+		if name == "chdir" || dir == "" {
+			return nil, "", fmt.Errorf("Invalid -chdir option: must include an " +
+				"equals sign followed by a directory path, like -chdir=example")
+		}
+		result := make([]string, 0, len(args)-1)
+		result = append(result, args[:i]...)
+		result = append(result, args[i+1:]...)
+		return result, dir, nil
+	}
+
+	return args, "", nil
+}
+
 // extractMachineReadable checks the args for the machine readable
 // flag and returns whether or not it is on. It modifies the args
 // to remove this flag.
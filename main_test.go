@@ -62,6 +62,38 @@ func TestExtractMachineReadable(t *testing.T) {
 	}
 }
 
+func TestExtractChdir(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected []string
+		dir      string
+		err      bool
+	}{
+		{[]string{"build", "."}, []string{"build", "."}, "", false},
+		{[]string{"-chdir=images", "build", "."}, []string{"build", "."}, "images", false},
+		{[]string{"--chdir=images", "build", "."}, []string{"build", "."}, "images", false},
+		{[]string{"-machine-readable", "-chdir=images", "build", "."}, []string{"-machine-readable", "build", "."}, "images", false},
+		// -chdir after the subcommand belongs to the subcommand
+		{[]string{"build", "-chdir=images", "."}, []string{"build", "-chdir=images", "."}, "", false},
+		{[]string{"-chdir", "images", "build", "."}, nil, "", true},
+		{[]string{"-chdir=", "build", "."}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			result, dir, err := extractChdir(tt.args)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Fatalf("bad: %#v", result)
+			}
+			if dir != tt.dir {
+				t.Fatalf("bad dir: %q", dir)
+			}
+		})
+	}
+}
+
 func TestRandom(t *testing.T) {
 	if rand.Intn(9999999) == 8498210 {
 		t.Fatal("math.rand is not seeded properly")
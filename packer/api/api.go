@@ -0,0 +1,232 @@
+// Package api is the supported Go API to load Packer templates and run their
+// builds from a Go program, instead of running the packer command and parsing
+// its output.
+//
+// Compatibility: the exported identifiers of this package follow semantic
+// versioning on their own. Within a major version they are only added to,
+// never removed or changed in an incompatible way. This is not the case of
+// the other packages of this module, which can change in any release; the
+// only types of these packages used here are the ones of the packer-plugin-sdk
+// and packer.ComponentFinder, see Config.Components.
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template"
+	kvflag "github.com/hashicorp/packer/command/flag-kv"
+	"github.com/hashicorp/packer/hcl2template"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/version"
+)
+
+// Config is what to load and how.
+type Config struct {
+	// Path is a JSON template, an HCL2 file or a folder of HCL2 files.
+	Path string
+
+	// Vars are the values of variables, like the -var flag of packer.
+	Vars map[string]string
+
+	// VarFiles are files setting variables, like the -var-file flag of
+	// packer.
+	VarFiles []string
+
+	// PluginDirs are the folders in which to discover plugins. When empty,
+	// plugins are discovered in the same folders as the packer command.
+	PluginDirs []string
+
+	// Components, when set, are the components to use instead of discovering
+	// plugins; to run in-process builders in tests for example. PluginDirs
+	// is then ignored.
+	Components *packer.ComponentFinder
+}
+
+// Template is a loaded template, with its variables set and its data sources
+// read.
+type Template struct {
+	handler handler
+}
+
+// handler is what Template needs of JSON templates and HCL2 configurations.
+type handler interface {
+	Initialize(packer.InitializeOptions) hcl.Diagnostics
+	packer.BuildGetter
+}
+
+// Load loads the template of cfg, sets its variables and reads its data
+// sources.
+func Load(cfg Config) (*Template, error) {
+	components, err := cfg.components()
+	if err != nil {
+		return nil, err
+	}
+
+	var handler handler
+	if isHCL2(cfg.Path) {
+		parser := &hcl2template.Parser{
+			CorePackerVersion:       version.SemVer,
+			CorePackerVersionString: version.FormattedVersion(),
+			Parser:                  hclparse.NewParser(),
+			PluginConfig:            components.PluginConfig,
+		}
+		pc, diags := parser.Parse(cfg.Path, cfg.VarFiles, cfg.Vars)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		handler = pc
+	} else {
+		core, err := jsonCore(cfg, components)
+		if err != nil {
+			return nil, err
+		}
+		handler = &jsonHandler{core}
+	}
+
+	if diags := handler.Initialize(packer.InitializeOptions{}); diags.HasErrors() {
+		return nil, diags
+	}
+	return &Template{handler: handler}, nil
+}
+
+func (cfg Config) components() (packer.ComponentFinder, error) {
+	if cfg.Components != nil {
+		return *cfg.Components, nil
+	}
+	plugins := &packer.PluginConfig{
+		KnownPluginFolders: cfg.PluginDirs,
+		PluginMinPort:      10000,
+		PluginMaxPort:      25000,
+	}
+	if len(plugins.KnownPluginFolders) == 0 {
+		plugins.KnownPluginFolders = packer.PluginFolders(".")
+	}
+	if err := plugins.Discover(); err != nil {
+		return packer.ComponentFinder{}, fmt.Errorf("discovering plugins: %w", err)
+	}
+	return packer.ComponentFinder{PluginConfig: plugins}, nil
+}
+
+// isHCL2 tells whether path is an HCL2 configuration, like the packer command
+// does.
+func isHCL2(path string) bool {
+	for _, ext := range []string{".pkr.hcl", ".pkr.json", ".pkr.yaml"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+func jsonCore(cfg Config, components packer.ComponentFinder) (*packer.Core, error) {
+	tpl, err := template.ParseFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template: %w", err)
+	}
+
+	// Variables set in Vars take precedence over the ones of var files.
+	vars := kvflag.FlagJSON{}
+	for _, file := range cfg.VarFiles {
+		if err := vars.Set(file); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range cfg.Vars {
+		vars[k] = v
+	}
+
+	return packer.NewCore(&packer.CoreConfig{
+		Components: components,
+		Template:   tpl,
+		Variables:  vars,
+		Version:    version.Version,
+	}), nil
+}
+
+// jsonHandler returns the errors of the initialization of a JSON template as
+// diagnostics, like HCL2 configurations do.
+type jsonHandler struct {
+	*packer.Core
+}
+
+func (h *jsonHandler) Initialize(opts packer.InitializeOptions) hcl.Diagnostics {
+	if err := h.Core.InitializeWithOptions(opts); err != nil {
+		return hcl.Diagnostics{{Severity: hcl.DiagError, Summary: err.Error()}}
+	}
+	return nil
+}
+
+// RunOptions are the options of Template.Run.
+type RunOptions struct {
+	// Only and Except select the builds to run, like the -only and -except
+	// flags of packer build.
+	Only, Except []string
+
+	// OnError is what to do when a build fails, like the -on-error flag of
+	// packer build: "cleanup", the default, or "abort". Other values of the
+	// flag need a terminal.
+	OnError string
+
+	// OnEvent, when set, is called with the output of the builds and when
+	// they start and finish. It is called from the goroutines of the builds,
+	// so it must be safe for concurrent use.
+	OnEvent func(Event)
+}
+
+// Result is the result of a build.
+type Result struct {
+	// Name is the name of the build.
+	Name      string
+	Artifacts []packersdk.Artifact
+	// Err is the error of the build, if any.
+	Err error
+}
+
+// Run runs the builds of the template in parallel, and returns their results
+// in the order of the template. The returned error is only set when builds
+// could not be started; errors of builds are in their result. Cancelling ctx
+// cancels the builds.
+func (t *Template) Run(ctx context.Context, opts RunOptions) ([]Result, error) {
+	onError := opts.OnError
+	if onError == "" {
+		onError = "cleanup"
+	}
+	if onError != "cleanup" && onError != "abort" {
+		return nil, fmt.Errorf("unsupported OnError value %q, use \"cleanup\" or \"abort\"", onError)
+	}
+
+	builds, diags := t.handler.GetBuilds(packer.GetBuildsOptions{
+		Only:    opts.Only,
+		Except:  opts.Except,
+		OnError: onError,
+	})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	results := make([]Result, len(builds))
+	var wg sync.WaitGroup
+	for i, b := range builds {
+		wg.Add(1)
+		go func(i int, b packersdk.Build) {
+			defer wg.Done()
+			name := b.Name()
+			ui := &eventUi{build: name, onEvent: opts.OnEvent}
+			ui.emit(Event{Type: EventBuildStart})
+			artifacts, err := b.Run(ctx, ui)
+			ui.emit(Event{Type: EventBuildFinish, Err: err})
+			results[i] = Result{Name: name, Artifacts: artifacts, Err: err}
+		}(i, b)
+	}
+	wg.Wait()
+
+	return results, nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/packer"
+)
+
+// sayBuilder says hello when it runs.
+type sayBuilder struct {
+	packersdk.MockBuilder
+}
+
+func (b *sayBuilder) Run(ctx context.Context, ui packersdk.Ui, h packersdk.Hook) (packersdk.Artifact, error) {
+	ui.Say("hello")
+	return b.MockBuilder.Run(ctx, ui, h)
+}
+
+func testComponents(t *testing.T) *packer.ComponentFinder {
+	return &packer.ComponentFinder{
+		PluginConfig: &packer.PluginConfig{
+			Builders: packer.MapOfBuilder{
+				"test": func() (packersdk.Builder, error) { return &sayBuilder{}, nil },
+			},
+		},
+	}
+}
+
+func TestTemplateRun(t *testing.T) {
+	tc := []struct {
+		name   string
+		cfg    Config
+		opts   RunOptions
+		builds []string
+	}{
+		{"json", Config{Path: "test-fixtures/template.json"}, RunOptions{}, []string{"first", "second"}},
+		{"json only", Config{Path: "test-fixtures/template.json"}, RunOptions{Only: []string{"second"}}, []string{"second"}},
+		{"hcl", Config{Path: "test-fixtures/hcl", Vars: map[string]string{"greeting": "hi"}}, RunOptions{}, []string{"test.first"}},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Components = testComponents(t)
+			tpl, err := Load(tt.cfg)
+			if err != nil {
+				t.Fatalf("Load: %s", err)
+			}
+
+			var mu sync.Mutex
+			events := map[string][]EventType{}
+			tt.opts.OnEvent = func(e Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				events[e.Build] = append(events[e.Build], e.Type)
+			}
+			results, err := tpl.Run(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Run: %s", err)
+			}
+
+			var builds []string
+			for _, r := range results {
+				builds = append(builds, r.Name)
+				if r.Err != nil {
+					t.Errorf("build %s failed: %s", r.Name, r.Err)
+				}
+				if len(r.Artifacts) != 1 {
+					t.Errorf("build %s: expected one artifact, got %d", r.Name, len(r.Artifacts))
+				}
+				expected := []EventType{EventBuildStart, EventSay, EventBuildFinish}
+				if diff := cmp.Diff(expected, events[r.Name]); diff != "" {
+					t.Errorf("unexpected events of %s: %s", r.Name, diff)
+				}
+			}
+			if diff := cmp.Diff(tt.builds, builds); diff != "" {
+				t.Errorf("unexpected builds: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLoad_unsetVariable(t *testing.T) {
+	_, err := Load(Config{Path: "test-fixtures/hcl", Components: testComponents(t)})
+	if err == nil {
+		t.Fatal("expected an error, the greeting variable is not set")
+	}
+}
+
+func TestRun_onError(t *testing.T) {
+	tpl, err := Load(Config{Path: "test-fixtures/template.json", Components: testComponents(t)})
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if _, err := tpl.Run(context.Background(), RunOptions{OnError: "ask"}); err == nil {
+		t.Fatal("expected an error, asking needs a terminal")
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+const (
+	// EventBuildStart is sent when a build starts.
+	EventBuildStart EventType = "build-start"
+	// EventBuildFinish is sent when a build finishes, with its error if it
+	// failed.
+	EventBuildFinish EventType = "build-finish"
+
+	// EventSay, EventMessage and EventError are the output of a build, what
+	// packer build prints.
+	EventSay     EventType = "say"
+	EventMessage EventType = "message"
+	EventError   EventType = "error"
+
+	// EventMachine is a machine-readable output of a build, what packer build
+	// -machine-readable prints.
+	EventMachine EventType = "machine"
+)
+
+// Event is something that happened during a build.
+type Event struct {
+	Type EventType
+	// Build is the name of the build.
+	Build string
+
+	// Message is the message of say, message and error events, and the type
+	// of machine events.
+	Message string
+	// Args are the arguments of machine events.
+	Args []string
+	// Err is the error of a failed build, for build-finish events.
+	Err error
+}
+
+// ErrInputNotSupported is returned to builds asking for an input, which
+// cannot be answered when packer is embedded.
+var ErrInputNotSupported = errors.New("asking for input is not supported by the packer API")
+
+// eventUi is the Ui of a build, turning its output into events.
+type eventUi struct {
+	packersdk.NoopProgressTracker
+
+	build   string
+	onEvent func(Event)
+}
+
+var _ packersdk.Ui = new(eventUi)
+
+func (ui *eventUi) emit(e Event) {
+	if ui.onEvent == nil {
+		return
+	}
+	e.Build = ui.build
+	ui.onEvent(e)
+}
+
+func (ui *eventUi) Ask(string) (string, error) {
+	return "", ErrInputNotSupported
+}
+
+func (ui *eventUi) Say(msg string) {
+	ui.emit(Event{Type: EventSay, Message: msg})
+}
+
+func (ui *eventUi) Message(msg string) {
+	ui.emit(Event{Type: EventMessage, Message: msg})
+}
+
+func (ui *eventUi) Error(msg string) {
+	ui.emit(Event{Type: EventError, Message: msg})
+}
+
+func (ui *eventUi) Machine(t string, args ...string) {
+	ui.emit(Event{Type: EventMachine, Message: t, Args: args})
+}
@@ -0,0 +1,10 @@
+variable "greeting" {
+  type = string
+}
+
+source "test" "first" {
+}
+
+build {
+  sources = ["source.test.first"]
+}
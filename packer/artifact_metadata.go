@@ -0,0 +1,111 @@
+package packer
+
+import (
+	"log"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// MachineArtifactMetadata is the type of the machine-readable message a
+// provisioner sends to attach a key/value pair to the artifacts of its build,
+// for example the version of an installed package:
+//
+//	ui.Machine("artifact-metadata", "nginx_version", "1.18.0")
+//
+// The Ui of a provisioner is served by packer core, so this works for
+// provisioners running as plugins too.
+const MachineArtifactMetadata = "artifact-metadata"
+
+// ArtifactMetadataState is the name of the state of an artifact holding the
+// metadata attached by provisioners, as a *map[string]string.
+const ArtifactMetadataState = "artifact_metadata"
+
+// artifactMetadata collects the metadata attached by the provisioners of a
+// build. The provisioners of a parallel block set it at the same time.
+type artifactMetadata struct {
+	l sync.Mutex
+	m map[string]string
+}
+
+func (m *artifactMetadata) set(key, value string) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if m.m == nil {
+		m.m = map[string]string{}
+	}
+	m.m[key] = value
+}
+
+// copy returns the metadata collected so far, or nil if there is none.
+func (m *artifactMetadata) copy() map[string]string {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if len(m.m) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(m.m))
+	for k, v := range m.m {
+		res[k] = v
+	}
+	return res
+}
+
+// metadataUi is the Ui given to provisioners. It records the
+// artifact-metadata messages and forwards everything to Ui.
+type metadataUi struct {
+	packersdk.Ui
+	metadata *artifactMetadata
+}
+
+func (u *metadataUi) Machine(t string, args ...string) {
+	if t == MachineArtifactMetadata {
+		if len(args) != 2 {
+			log.Printf("[WARN] ignoring %s message with %d arguments, expected a key and a value", t, len(args))
+		} else {
+			u.metadata.set(args[0], args[1])
+		}
+	}
+	u.Ui.Machine(t, args...)
+}
+
+func (u *metadataUi) Event(event UiEvent) bool {
+	return EmitEvent(u.Ui, event)
+}
+
+// metadataArtifact is an artifact of a build with the metadata attached by
+// the provisioners of the build.
+type metadataArtifact struct {
+	packersdk.Artifact
+	metadata map[string]string
+}
+
+func (a *metadataArtifact) State(name string) interface{} {
+	if name == ArtifactMetadataState {
+		// gob, used to pass states to plugins, decodes maps of strings as
+		// pointers. A pointer is returned here too so that there is only
+		// one type to handle.
+		return &a.metadata
+	}
+	return a.Artifact.State(name)
+}
+
+// withArtifactMetadata returns artifact with the metadata attached by
+// provisioners, if any.
+func withArtifactMetadata(artifact packersdk.Artifact, metadata map[string]string) packersdk.Artifact {
+	if artifact == nil || len(metadata) == 0 {
+		return artifact
+	}
+	return &metadataArtifact{Artifact: artifact, metadata: metadata}
+}
+
+// ArtifactMetadata returns the metadata attached by provisioners to artifact.
+func ArtifactMetadata(artifact packersdk.Artifact) map[string]string {
+	if artifact == nil {
+		return nil
+	}
+	if metadata, ok := artifact.State(ArtifactMetadataState).(*map[string]string); ok && metadata != nil {
+		return *metadata
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/replicate"
+)
+
+// ReplicatingBuilders are the builders of packer able to copy their artifacts
+// to other targets, listed when a build with a replicate block uses another
+// builder. Builders of external plugins can support it too.
+var ReplicatingBuilders = []string{"file"}
+
+// CanReplicate tells whether builder can copy its artifact to other targets,
+// like the regions or the accounts of a cloud. Builders tell so by declaring
+// the replicate.TargetConfigKey setting, which also works for plugins.
+func CanReplicate(builder packersdk.Builder) bool {
+	spec := builder.ConfigSpec()
+	if spec == nil {
+		return false
+	}
+	_, ok := spec[replicate.TargetConfigKey]
+	return ok
+}
+
+// ArtifactReplication lists the targets to copy the artifact of a build to.
+// Packer core copies the artifact to the targets at the same time, and
+// reports the outcome of every copy.
+type ArtifactReplication struct {
+	Targets []string
+	// MaxParallel is how many copies run at the same time. Zero means all of
+	// them.
+	MaxParallel int
+	// StartBuilder starts the builder of the build again and prepares it
+	// with vars added to its configuration, for its Run to copy an artifact
+	// to a target instead of building it.
+	StartBuilder func(vars map[string]string) (packersdk.Builder, error)
+}
+
+// replicateFunc copies artifact to target and returns the copy. It must stop
+// when ctx is cancelled.
+type replicateFunc func(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact, target string) (packersdk.Artifact, error)
+
+// replicateWithBuilder copies artifact to target with a builder started by
+// replication.StartBuilder.
+func (replication *ArtifactReplication) replicateWithBuilder(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact, target string) (packersdk.Artifact, error) {
+	encoded, err := replicate.EncodeArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := replication.StartBuilder(map[string]string{
+		replicate.TargetConfigKey:   target,
+		replicate.ArtifactConfigKey: encoded,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the builder does not provision the copy
+	return builder.Run(ctx, ui, &packersdk.DispatchHook{})
+}
+
+// MachineArtifactReplication is the type of the machine-readable messages
+// telling the status of the copy of an artifact to a target:
+//
+//	artifact-replication,<target>,running
+//	artifact-replication,<target>,succeeded,<artifact id>
+//	artifact-replication,<target>,failed,<error>
+//	artifact-replication,<target>,cancelled
+const MachineArtifactReplication = "artifact-replication"
+
+// ReplicaStatus is the status of the copy of an artifact to a target.
+type ReplicaStatus string
+
+const (
+	ReplicaRunning   ReplicaStatus = "running"
+	ReplicaSucceeded ReplicaStatus = "succeeded"
+	ReplicaFailed    ReplicaStatus = "failed"
+	// ReplicaCancelled is a copy that was stopped, or never started, because
+	// the build was cancelled.
+	ReplicaCancelled ReplicaStatus = "cancelled"
+)
+
+// Replica is the outcome of the copy of an artifact to a target.
+type Replica struct {
+	Target   string
+	Status   ReplicaStatus
+	Artifact packersdk.Artifact
+	Duration time.Duration
+	Err      error
+}
+
+// replicateArtifact copies artifact to every target of replication with
+// replicate, at most replication.MaxParallel at a time, and returns the
+// outcome of each copy in the order of the targets.
+func replicateArtifact(ctx context.Context, ui packersdk.Ui, replicate replicateFunc, artifact packersdk.Artifact, replication *ArtifactReplication) []Replica {
+	replicas := make([]Replica, len(replication.Targets))
+	max := replication.MaxParallel
+	if max <= 0 || max > len(replication.Targets) {
+		max = len(replication.Targets)
+	}
+	sem := make(chan struct{}, max)
+
+	var wg sync.WaitGroup
+	for i, target := range replication.Targets {
+		replicas[i].Target = target
+		wg.Add(1)
+		go func(replica *Replica) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				replica.Status, replica.Err = ReplicaCancelled, ctx.Err()
+				ui.Machine(MachineArtifactReplication, replica.Target, string(replica.Status))
+				return
+			}
+
+			ui.Say(fmt.Sprintf("Replicating artifact to %s", replica.Target))
+			ui.Machine(MachineArtifactReplication, replica.Target, string(ReplicaRunning))
+			start := time.Now()
+			copied, err := replicate(ctx, ui, artifact, replica.Target)
+			replica.Duration = time.Since(start)
+			switch {
+			case err != nil && ctx.Err() != nil:
+				replica.Status, replica.Err = ReplicaCancelled, err
+				ui.Machine(MachineArtifactReplication, replica.Target, string(replica.Status))
+			case err == nil && copied == nil:
+				err = fmt.Errorf("the builder returned no artifact")
+				fallthrough
+			case err != nil:
+				replica.Status, replica.Err = ReplicaFailed, err
+				ui.Error(fmt.Sprintf("Replicating artifact to %s failed: %s", replica.Target, err))
+				ui.Machine(MachineArtifactReplication, replica.Target, string(replica.Status), err.Error())
+			default:
+				replica.Status, replica.Artifact = ReplicaSucceeded, copied
+				ui.Say(fmt.Sprintf("Replicated artifact to %s: %s", replica.Target, copied.Id()))
+				ui.Machine(MachineArtifactReplication, replica.Target, string(replica.Status), copied.Id())
+			}
+			log.Printf("Replication of %s to %s: %s after %s", artifact.Id(), replica.Target, replica.Status, replica.Duration)
+		}(&replicas[i])
+	}
+	wg.Wait()
+	return replicas
+}
@@ -0,0 +1,237 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/replicate"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// replicator copies artifacts to the targets that are not in fail.
+type replicator struct {
+	fail map[string]bool
+
+	l                   sync.Mutex
+	running, maxRunning int
+}
+
+func (r *replicator) replicate(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact, target string) (packersdk.Artifact, error) {
+	r.l.Lock()
+	r.running++
+	if r.running > r.maxRunning {
+		r.maxRunning = r.running
+	}
+	r.l.Unlock()
+	defer func() {
+		r.l.Lock()
+		r.running--
+		r.l.Unlock()
+	}()
+
+	if r.fail[target] {
+		return nil, errors.New("quota exceeded")
+	}
+	return &packersdk.MockArtifact{IdValue: artifact.Id() + "@" + target}, nil
+}
+
+// replicatingBuilder is a mock builder declaring that it can copy its
+// artifacts.
+type replicatingBuilder struct {
+	packersdk.MockBuilder
+	replicator *replicator
+
+	target, artifact string
+}
+
+func (b *replicatingBuilder) ConfigSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		replicate.TargetConfigKey:   &hcldec.AttrSpec{Name: replicate.TargetConfigKey, Type: cty.String},
+		replicate.ArtifactConfigKey: &hcldec.AttrSpec{Name: replicate.ArtifactConfigKey, Type: cty.String},
+	}
+}
+
+func (b *replicatingBuilder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	for _, raw := range raws {
+		if vars, ok := raw.(map[string]string); ok {
+			b.target, b.artifact = vars[replicate.TargetConfigKey], vars[replicate.ArtifactConfigKey]
+		}
+	}
+	return b.MockBuilder.Prepare(raws...)
+}
+
+func (b *replicatingBuilder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	if b.target == "" {
+		return b.MockBuilder.Run(ctx, ui, hook)
+	}
+	artifact, err := replicate.DecodeArtifact(b.artifact, new(packersdk.MockArtifact).BuilderId())
+	if err != nil {
+		return nil, err
+	}
+	return b.replicator.replicate(ctx, ui, &packersdk.MockArtifact{IdValue: artifact.Id}, b.target)
+}
+
+func replicaStatuses(replicas []Replica) map[string]ReplicaStatus {
+	res := map[string]ReplicaStatus{}
+	for _, r := range replicas {
+		res[r.Target] = r.Status
+	}
+	return res
+}
+
+func TestReplicateArtifact(t *testing.T) {
+	r := &replicator{fail: map[string]bool{"b": true}}
+	replication := &ArtifactReplication{Targets: []string{"a", "b", "c", "d"}, MaxParallel: 2}
+	artifact := &packersdk.MockArtifact{IdValue: "img"}
+
+	replicas := replicateArtifact(context.Background(), testUi(), r.replicate, artifact, replication)
+
+	expected := map[string]ReplicaStatus{"a": ReplicaSucceeded, "b": ReplicaFailed, "c": ReplicaSucceeded, "d": ReplicaSucceeded}
+	if diff := cmp.Diff(expected, replicaStatuses(replicas)); diff != "" {
+		t.Errorf("unexpected statuses: %s", diff)
+	}
+	for i, target := range replication.Targets {
+		if replicas[i].Target != target {
+			t.Errorf("replica %d is for %s, expected %s", i, replicas[i].Target, target)
+		}
+	}
+	if id := replicas[0].Artifact.Id(); id != "img@a" {
+		t.Errorf("unexpected artifact of a: %s", id)
+	}
+	if replicas[1].Err == nil || replicas[1].Artifact != nil {
+		t.Errorf("the failed copy should have an error and no artifact: %#v", replicas[1])
+	}
+	if r.maxRunning > 2 {
+		t.Errorf("%d copies ran at the same time, expected at most 2", r.maxRunning)
+	}
+}
+
+func TestReplicateArtifact_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	replication := &ArtifactReplication{Targets: []string{"a", "b"}}
+
+	replicas := replicateArtifact(ctx, testUi(), new(replicator).replicate, &packersdk.MockArtifact{}, replication)
+
+	expected := map[string]ReplicaStatus{"a": ReplicaCancelled, "b": ReplicaCancelled}
+	if diff := cmp.Diff(expected, replicaStatuses(replicas)); diff != "" {
+		t.Errorf("unexpected statuses: %s", diff)
+	}
+}
+
+func TestBuild_Run_Replication(t *testing.T) {
+	build := testBuild()
+	r := &replicator{fail: map[string]bool{"eu": true}}
+	build.Builder = &replicatingBuilder{MockBuilder: packersdk.MockBuilder{ArtifactId: "b"}}
+	build.Replication = &ArtifactReplication{
+		Targets: []string{"us", "eu"},
+		StartBuilder: func(vars map[string]string) (packersdk.Builder, error) {
+			b := &replicatingBuilder{replicator: r}
+			_, _, err := b.Prepare(vars)
+			return b, err
+		},
+	}
+	build.Prepare()
+
+	artifacts, err := build.Run(context.Background(), testUi())
+	if err == nil {
+		t.Fatal("expected the failed copy to fail the build")
+	}
+
+	var ids []string
+	for _, a := range artifacts {
+		ids = append(ids, a.Id())
+	}
+	// the builder artifact, the one of the post-processor and the copy
+	if diff := cmp.Diff([]string{"b", "pp", "b@us"}, ids); diff != "" {
+		t.Errorf("unexpected artifacts: %s", diff)
+	}
+	expected := map[string]ReplicaStatus{"us": ReplicaSucceeded, "eu": ReplicaFailed}
+	if diff := cmp.Diff(expected, replicaStatuses(build.Replicas())); diff != "" {
+		t.Errorf("unexpected statuses: %s", diff)
+	}
+}
+
+func TestBuild_Run_ReplicationUnsupported(t *testing.T) {
+	build := testBuild()
+	build.Replication = &ArtifactReplication{Targets: []string{"us"}}
+	build.Prepare()
+
+	if _, err := build.Run(context.Background(), testUi()); err == nil {
+		t.Fatal("expected an error, the mock builder cannot replicate its artifacts")
+	}
+	if CanReplicate(build.Builder) {
+		t.Error("the mock builder cannot replicate its artifacts")
+	}
+}
+
+func TestBuild_Run_ReplicationPlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-replicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := map[string]interface{}{"target": filepath.Join(dir, "out.txt"), "content": "created"}
+
+	// startBuilder starts the file builder as a plugin and prepares it.
+	var l sync.Mutex
+	var clients []*PluginClient
+	defer func() {
+		for _, c := range clients {
+			c.Kill()
+		}
+	}()
+	startBuilder := func(vars map[string]string) (packersdk.Builder, error) {
+		c := NewClient(&PluginClientConfig{Cmd: helperProcess("file-builder")})
+		l.Lock()
+		clients = append(clients, c)
+		l.Unlock()
+		builder, err := c.Builder()
+		if err != nil {
+			return nil, err
+		}
+		_, _, err = builder.Prepare(config, vars)
+		return builder, err
+	}
+
+	builder, err := startBuilder(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CanReplicate(builder) {
+		t.Fatal("the file builder must be able to replicate its artifacts")
+	}
+	build := &CoreBuild{
+		Type:          "file",
+		BuilderType:   "file",
+		Builder:       builder,
+		BuilderConfig: config,
+		Prepared:      true,
+		Replication: &ArtifactReplication{
+			Targets:      []string{filepath.Join(dir, "us"), filepath.Join(dir, "eu")},
+			StartBuilder: startBuilder,
+		},
+	}
+	build.Prepare()
+	artifacts, err := build.Run(context.Background(), testUi())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 3 {
+		t.Fatalf("expected the artifact and its 2 copies, got %d", len(artifacts))
+	}
+	for _, target := range []string{"us", "eu"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, target, "out.txt"))
+		if err != nil || string(content) != "created" {
+			t.Errorf("bad copy to %s: %q, %v", target, content, err)
+		}
+	}
+}
@@ -5,13 +5,22 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer/builder/common/events"
+	"github.com/hashicorp/packer/builder/common/resume"
 	"github.com/hashicorp/packer/version"
 )
 
+// SkipCreateArtifactConfigKey is the key in the configuration of builders that
+// is set to true by `packer build -skip-create-artifact`. Builders supporting
+// it still run their provisioners but do not create their artifact, for
+// example an AMI, so that provisioning can be tested cheaply.
+const SkipCreateArtifactConfigKey = "packer_skip_create_artifact"
+
 // A CoreBuild struct represents a single build job, the result of which should
 // be a single machine image artifact. This artifact may be comprised of
 // multiple files, of course, but it should be for only a single provider (such
@@ -29,14 +38,47 @@ type CoreBuild struct {
 	TemplatePath       string
 	Variables          map[string]string
 
+	// Resources are the host resources this build declared it will use while
+	// running. They are used to schedule parallel builds; nil when the build
+	// did not declare any.
+	Resources *BuildResources
+
+	// Timeout is how long the build, post-processors included, may run
+	// before it is cancelled. Zero means no timeout.
+	Timeout time.Duration
+
+	// Replication, when set, copies the artifact of the builder to other
+	// targets before the post-processors run. The builder must be able to,
+	// see CanReplicate.
+	Replication *ArtifactReplication
+
+	// Retry, when set, runs the build again from scratch when it fails.
+	Retry *BuildRetry
+
 	// Indicates whether the build is already initialized before calling Prepare(..)
 	Prepared bool
 
-	debug         bool
-	force         bool
-	onError       string
-	l             sync.Mutex
-	prepareCalled bool
+	// checkpoint saves the state of the build as it runs, for it to be
+	// resumed when interrupted. nil when the build is not resumable.
+	checkpoint *BuildCheckpoint
+	// resumeStateFile is the file the state of the build is saved in, given
+	// to the builder when it is prepared.
+	resumeStateFile string
+
+	debug              bool
+	force              bool
+	onError            string
+	skipCreateArtifact bool
+	l                  sync.Mutex
+	prepareCalled      bool
+	replicas           []Replica
+}
+
+// BuildResources are the CPU and memory of the packer host a build is
+// expected to use, for example by a local VM.
+type BuildResources struct {
+	CPU    int64
+	Memory uint64 // in bytes
 }
 
 // CoreBuildPostProcessor Keeps track of the post-processor and the
@@ -56,6 +98,9 @@ type CoreBuildProvisioner struct {
 	PName       string
 	Provisioner packersdk.Provisioner
 	config      []interface{}
+	// Parallel is the group of provisioners this one runs at the same time
+	// as, or nil.
+	Parallel *ParallelGroup
 }
 
 // Returns the name of the build.
@@ -97,12 +142,18 @@ func (b *CoreBuild) Prepare() (warn []string, err error) {
 		common.OnErrorConfigKey:       b.onError,
 		common.TemplatePathKey:        b.TemplatePath,
 		common.UserVariablesConfigKey: b.Variables,
+		SkipCreateArtifactConfigKey:   b.skipCreateArtifact,
+	}
+
+	if b.resumeStateFile != "" {
+		packerConfig[resume.StateConfigKey] = b.resumeStateFile
 	}
 
 	// Prepare the builder
 	generatedVars, warn, err := b.Builder.Prepare(b.BuilderConfig, packerConfig)
 	if err != nil {
 		log.Printf("Build '%s' prepare failure: %s\n", b.Type, err)
+		err = suggestConfigKeys(err, b.Builder.ConfigSpec())
 		return
 	}
 
@@ -118,6 +169,10 @@ func (b *CoreBuild) Prepare() (warn []string, err error) {
 		}
 	}
 
+	if err = b.checkBuildVariables(generatedPlaceholderMap); err != nil {
+		return
+	}
+
 	// Prepare the provisioners
 	for _, coreProv := range b.Provisioners {
 		configs := make([]interface{}, len(coreProv.config), len(coreProv.config)+1)
@@ -126,6 +181,7 @@ func (b *CoreBuild) Prepare() (warn []string, err error) {
 		configs = append(configs, generatedPlaceholderMap)
 
 		if err = coreProv.Provisioner.Prepare(configs...); err != nil {
+			err = suggestConfigKeys(err, coreProv.Provisioner.ConfigSpec())
 			return
 		}
 	}
@@ -138,6 +194,7 @@ func (b *CoreBuild) Prepare() (warn []string, err error) {
 		configs = append(configs, generatedPlaceholderMap)
 		err = b.CleanupProvisioner.Provisioner.Prepare(configs...)
 		if err != nil {
+			err = suggestConfigKeys(err, b.CleanupProvisioner.Provisioner.ConfigSpec())
 			return
 		}
 	}
@@ -147,6 +204,7 @@ func (b *CoreBuild) Prepare() (warn []string, err error) {
 		for _, corePP := range ppSeq {
 			err = corePP.PostProcessor.Configure(corePP.config, packerConfig, generatedPlaceholderMap)
 			if err != nil {
+				err = suggestConfigKeys(err, corePP.PostProcessor.ConfigSpec())
 				return
 			}
 		}
@@ -161,6 +219,12 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 		panic("Prepare must be called first")
 	}
 
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+
 	// Copy the hooks
 	hooks := make(map[string][]packersdk.Hook)
 	for hookName, hookList := range b.hooks {
@@ -168,6 +232,32 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 		copy(hooks[hookName], hookList)
 	}
 
+	// The builder just has a normal Ui, but targeted
+	builderUi := &TargetedUI{
+		Target: b.Name(),
+		Ui:     originalUi,
+	}
+
+	// Provisioners can attach metadata to the artifacts of the build.
+	metadata := &artifactMetadata{}
+
+	// Only the builders able to resume their machine can skip the
+	// provisioners that completed.
+	var checkpoint *BuildCheckpoint
+	if b.checkpoint != nil {
+		if b.resumeStateFile != "" && CanResume(b.Builder) {
+			checkpoint = b.checkpoint
+			hooks[resume.HookStepCompleted] = append(hooks[resume.HookStepCompleted], &stepCompletedHook{checkpoint})
+			hooks[resume.HookNewMachine] = append(hooks[resume.HookNewMachine], &newMachineHook{checkpoint})
+		} else if b.checkpoint.Resumed() {
+			builderUi.Say(fmt.Sprintf("The %s builder cannot resume an interrupted build, starting it over.", b.BuilderType))
+			b.checkpoint.Reset()
+		}
+	}
+
+	// Output the steps the builder reports it starts
+	hooks[events.HookStepStarted] = append(hooks[events.HookStepStarted], &stepStartedHook{ui: builderUi})
+
 	// Add a hook for the provisioners if we have provisioners
 	if len(b.Provisioners) > 0 {
 		hookedProvisioners := make([]*HookedProvisioner, len(b.Provisioners))
@@ -177,16 +267,19 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 				pConfig = p.config[0]
 			}
 			if b.debug {
+				// debugged provisioners ask before running, one at a time
 				hookedProvisioners[i] = &HookedProvisioner{
 					&DebuggedProvisioner{Provisioner: p.Provisioner},
 					pConfig,
 					p.PType,
+					nil,
 				}
 			} else {
 				hookedProvisioners[i] = &HookedProvisioner{
 					p.Provisioner,
 					pConfig,
 					p.PType,
+					p.Parallel,
 				}
 			}
 		}
@@ -197,6 +290,9 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 
 		hooks[packersdk.HookProvision] = append(hooks[packersdk.HookProvision], &ProvisionHook{
 			Provisioners: hookedProvisioners,
+			metadata:     metadata,
+			checkpoint:   checkpoint,
+			events:       builderUi,
 		})
 	}
 
@@ -205,25 +301,25 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 			b.CleanupProvisioner.Provisioner,
 			b.CleanupProvisioner.config,
 			b.CleanupProvisioner.PType,
+			nil,
 		}
 		hooks[packersdk.HookCleanupProvision] = []packersdk.Hook{&ProvisionHook{
 			Provisioners: []*HookedProvisioner{hookedCleanupProvisioner},
+			metadata:     metadata,
+			events:       builderUi,
 		}}
 	}
 
 	hook := &packersdk.DispatchHook{Mapping: hooks}
 	artifacts := make([]packersdk.Artifact, 0, 1)
 
-	// The builder just has a normal Ui, but targeted
-	builderUi := &TargetedUI{
-		Target: b.Name(),
-		Ui:     originalUi,
-	}
-
 	log.Printf("Running builder: %s", b.BuilderType)
 	ts := CheckpointReporter.AddSpan(b.BuilderType, "builder", b.BuilderConfig)
 	builderArtifact, err := b.Builder.Run(ctx, builderUi, hook)
 	ts.End(err)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("Build timed out after %s: %w", b.Timeout, ctx.Err())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -233,24 +329,42 @@ func (b *CoreBuild) Run(ctx context.Context, originalUi packersdk.Ui) ([]packers
 	if builderArtifact == nil {
 		return nil, nil
 	}
+	buildMetadata := metadata.copy()
+	builderArtifact = withArtifactMetadata(builderArtifact, buildMetadata)
 
 	errors := make([]error, 0)
 	keepOriginalArtifact := len(b.PostProcessors) == 0
 
 	select {
 	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("Build timed out after %s: %w", b.Timeout, ctx.Err())
+		}
 		log.Println("Build was cancelled. Skipping post-processors.")
 		return nil, nil
 	default:
 	}
 
+	var replicaArtifacts []packersdk.Artifact
+	if b.Replication != nil && len(b.Replication.Targets) > 0 {
+		replicas := b.replicate(ctx, builderUi, builderArtifact)
+		for _, replica := range replicas {
+			switch replica.Status {
+			case ReplicaSucceeded:
+				replicaArtifacts = append(replicaArtifacts, replica.Artifact)
+			case ReplicaFailed:
+				errors = append(errors, fmt.Errorf("Replication to %s failed: %s", replica.Target, replica.Err))
+			}
+		}
+	}
+
 	// Run the post-processors
 PostProcessorRunSeqLoop:
 	for _, ppSeq := range b.PostProcessors {
 		priorArtifact := builderArtifact
 		for i, corePP := range ppSeq {
 			ppUi := &TargetedUI{
-				Target: fmt.Sprintf("%s (%s)", b.Name(), corePP.PType),
+				Target: b.postProcessorTarget(corePP),
 				Ui:     originalUi,
 			}
 
@@ -335,6 +449,17 @@ PostProcessorRunSeqLoop:
 		}
 	}
 
+	// The copies of the artifact are kept whatever the post-processors do
+	// with it.
+	artifacts = append(artifacts, replicaArtifacts...)
+
+	// The artifacts of post-processors carry the metadata of the build too.
+	for i, artifact := range artifacts {
+		if _, ok := artifact.(*metadataArtifact); !ok {
+			artifacts[i] = withArtifactMetadata(artifact, buildMetadata)
+		}
+	}
+
 	if len(errors) > 0 {
 		err = &packersdk.MultiError{Errors: errors}
 	}
@@ -342,6 +467,33 @@ PostProcessorRunSeqLoop:
 	return artifacts, err
 }
 
+// replicate copies artifact to the targets of the replication of the build,
+// and records the outcome of each copy for Replicas.
+func (b *CoreBuild) replicate(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) []Replica {
+	var replicas []Replica
+	if b.Replication.StartBuilder != nil && CanReplicate(b.Builder) {
+		replicas = replicateArtifact(ctx, ui, b.Replication.replicateWithBuilder, artifact, b.Replication)
+	} else {
+		err := fmt.Errorf("the %s builder cannot replicate its artifacts", b.BuilderType)
+		for _, target := range b.Replication.Targets {
+			replicas = append(replicas, Replica{Target: target, Status: ReplicaFailed, Err: err})
+		}
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.replicas = replicas
+	return replicas
+}
+
+// Replicas returns the outcome of the copy of the artifact of the build to
+// each target of its replication, once the build ran.
+func (b *CoreBuild) Replicas() []Replica {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.replicas
+}
+
 func (b *CoreBuild) SetDebug(val bool) {
 	if b.prepareCalled {
 		panic("prepare has already been called")
@@ -365,3 +517,63 @@ func (b *CoreBuild) SetOnError(val string) {
 
 	b.onError = val
 }
+
+// SetCheckpoint sets the checkpoint saving the state of the build as it runs,
+// to resume it when interrupted. The steps of the builder are only resumed
+// when it CanResume and was prepared with the file of the checkpoint.
+func (b *CoreBuild) SetCheckpoint(checkpoint *BuildCheckpoint) {
+	b.checkpoint = checkpoint
+}
+
+// SetResumeStateFile sets the file the state of the build is saved in, given
+// to the builder when it is prepared for it to resume the build. HCL2
+// templates give it to the builder when they start it.
+func (b *CoreBuild) SetResumeStateFile(path string) {
+	b.resumeStateFile = path
+}
+
+// SetSkipCreateArtifact sets whether the builder is asked not to create its
+// artifact.
+func (b *CoreBuild) SetSkipCreateArtifact(val bool) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.skipCreateArtifact = val
+}
+
+// stepStartedHook outputs the steps builders report they start by running
+// the events.HookStepStarted hook, as events of the UI of the build.
+type stepStartedHook struct {
+	ui packersdk.Ui
+}
+
+var _ packersdk.Hook = new(stepStartedHook)
+
+func (h *stepStartedHook) Run(_ context.Context, _ string, _ packersdk.Ui, _ packersdk.Communicator, data interface{}) error {
+	// hook data goes through the plugin RPC as a string
+	step, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("unexpected data of hook %s: %T", events.HookStepStarted, data)
+	}
+	EmitEvent(h.ui, UiEvent{Type: EventStep, Message: step})
+	return nil
+}
+
+// postProcessorTarget returns the target of the messages of the
+// post-processor pp of the build.
+func (b *CoreBuild) postProcessorTarget(pp CoreBuildPostProcessor) string {
+	return fmt.Sprintf("%s (%s)", b.Name(), pp.PType)
+}
+
+// UiTargets returns the targets the messages of the build are output under:
+// its name, then the ones of its post-processors.
+func (b *CoreBuild) UiTargets() []string {
+	targets := []string{b.Name()}
+	for _, ppSeq := range b.PostProcessors {
+		for _, pp := range ppSeq {
+			targets = append(targets, b.postProcessorTarget(pp))
+		}
+	}
+	return targets
+}
@@ -0,0 +1,41 @@
+package packer
+
+import "time"
+
+const (
+	// DefaultBuildRetryMinBackoff is how long to wait before retrying a
+	// failed build the first time, unless the retry block of the build says
+	// otherwise.
+	DefaultBuildRetryMinBackoff = 30 * time.Second
+	// DefaultBuildRetryMaxBackoff is the longest wait between two attempts
+	// of a build, unless the retry block of the build says otherwise.
+	DefaultBuildRetryMaxBackoff = 5 * time.Minute
+)
+
+// BuildRetry tells how many times a failed build is run again from scratch,
+// for failures that are likely transient like a lack of spot capacity or
+// rate limiting.
+type BuildRetry struct {
+	// Attempts is how many times the build runs at most, the first run
+	// included.
+	Attempts int
+	// MinBackoff is how long to wait before the second attempt. The wait
+	// doubles with every attempt, up to MaxBackoff.
+	MinBackoff, MaxBackoff time.Duration
+}
+
+// Backoff returns how long to wait after the failed attempt number attempt,
+// from 1.
+func (r *BuildRetry) Backoff(attempt int) time.Duration {
+	backoff := r.MinBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if r.MaxBackoff > 0 && backoff >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,20 @@
+package packer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildRetry_Backoff(t *testing.T) {
+	retry := &BuildRetry{Attempts: 5, MinBackoff: 30 * time.Second, MaxBackoff: 100 * time.Second}
+	for attempt, expected := range map[int]time.Duration{
+		1: 30 * time.Second,
+		2: 60 * time.Second,
+		3: 100 * time.Second,
+		4: 100 * time.Second,
+	} {
+		if backoff := retry.Backoff(attempt); backoff != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, backoff)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package packer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer/builder/common/resume"
+)
+
+// StateDir is the folder the states of the builds run with packer build
+// -resume are saved in, for the builds that get interrupted to be resumed.
+const StateDir = ".packer-state"
+
+// CanResume tells whether builder is able to resume an interrupted build: it
+// records the steps it completes, like downloading an ISO or creating a VM,
+// and skips them when the build is resumed, reusing what the steps created.
+// The provisioners that completed are only skipped for these builders, as the
+// other ones start from a new machine. Builders tell so by declaring the
+// resume.StateConfigKey setting, which also works for plugins.
+func CanResume(builder packersdk.Builder) bool {
+	spec := builder.ConfigSpec()
+	if spec == nil {
+		return false
+	}
+	_, ok := spec[resume.StateConfigKey]
+	return ok
+}
+
+// BuildStateFile returns the absolute path of the file the state of the build
+// name is saved in, in dir. It is given to the builders able to resume builds
+// when they are prepared, before the file is written.
+func BuildStateFile(dir, name string) string {
+	path := filepath.Join(dir, unsafeFilenameRe.ReplaceAllString(name, "_")+".json")
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// buildState is the state of a build saved in StateDir.
+type buildState struct {
+	// Fingerprint identifies the configuration of the build: a build only
+	// resumes a state saved by the same configuration.
+	Fingerprint string `json:"fingerprint"`
+	// Steps are the completed steps of the builder, with their state.
+	Steps map[string]map[string]string `json:"steps,omitempty"`
+	// Provisioners are the indexes of the completed provisioners.
+	Provisioners []int `json:"provisioners,omitempty"`
+}
+
+// BuildCheckpoint saves the state of a build to its file as the build runs.
+type BuildCheckpoint struct {
+	path    string
+	resumed bool
+
+	l     sync.Mutex
+	state buildState
+}
+
+var _ resume.StepState = new(BuildCheckpoint)
+
+var unsafeFilenameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// OpenBuildCheckpoint returns the checkpoint of the build name in dir, with
+// the state saved by the interrupted run of the build if it was saved with
+// the same fingerprint; otherwise the build starts over.
+func OpenBuildCheckpoint(dir, name, fingerprint string) (*BuildCheckpoint, error) {
+	c := &BuildCheckpoint{
+		path:  BuildStateFile(dir, name),
+		state: buildState{Fingerprint: fingerprint},
+	}
+	js, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var saved buildState
+	if err := json.Unmarshal(js, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %s", c.path, err)
+	}
+	if saved.Fingerprint != fingerprint {
+		log.Printf("[INFO] The configuration of %s changed since %s was saved, not resuming it", name, c.path)
+		// the builder reads the file when it runs
+		if err := os.Remove(c.path); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	c.state, c.resumed = saved, true
+	return c, nil
+}
+
+// Resumed tells whether the state of a previous run of the build was loaded.
+func (c *BuildCheckpoint) Resumed() bool {
+	return c.resumed
+}
+
+// CompletedStep returns the state saved by step when it completed.
+func (c *BuildCheckpoint) CompletedStep(step string) (map[string]string, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	state, ok := c.state.Steps[step]
+	return state, ok
+}
+
+// CompleteStep records that step completed, with its state.
+func (c *BuildCheckpoint) CompleteStep(step string, state map[string]string) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.state.Steps == nil {
+		c.state.Steps = map[string]map[string]string{}
+	}
+	c.state.Steps[step] = state
+	return c.save()
+}
+
+func (c *BuildCheckpoint) completedProvisioner(i int) bool {
+	c.l.Lock()
+	defer c.l.Unlock()
+	for _, completed := range c.state.Provisioners {
+		if completed == i {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BuildCheckpoint) completeProvisioner(i int) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.state.Provisioners = append(c.state.Provisioners, i)
+	return c.save()
+}
+
+// resetProvisioners forgets the completed provisioners, for them to run again
+// on a new machine.
+func (c *BuildCheckpoint) resetProvisioners() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if len(c.state.Provisioners) == 0 {
+		return nil
+	}
+	c.state.Provisioners = nil
+	return c.save()
+}
+
+// Reset forgets the saved state, for the build to start over.
+func (c *BuildCheckpoint) Reset() {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.state = buildState{Fingerprint: c.state.Fingerprint}
+	c.resumed = false
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Failed to remove %s: %s", c.path, err)
+	}
+}
+
+// save writes the state to its file, the lock being held.
+func (c *BuildCheckpoint) save() error {
+	js, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	// write then rename, not to leave a partial state when interrupted
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, js, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Remove removes the state of the build, once it succeeded.
+func (c *BuildCheckpoint) Remove() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// remove the state folder once empty
+	_ = os.Remove(filepath.Dir(c.path))
+	return nil
+}
+
+// stepCompletedHook saves the steps that builders report completed by running
+// the resume.HookStepCompleted hook.
+type stepCompletedHook struct {
+	checkpoint *BuildCheckpoint
+}
+
+var _ packersdk.Hook = new(stepCompletedHook)
+
+func (h *stepCompletedHook) Run(_ context.Context, _ string, _ packersdk.Ui, _ packersdk.Communicator, data interface{}) error {
+	// hook data goes through the plugin RPC as a string
+	js, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("unexpected data of hook %s: %T", resume.HookStepCompleted, data)
+	}
+	var step resume.CompletedStep
+	if err := json.Unmarshal([]byte(js), &step); err != nil {
+		return fmt.Errorf("unexpected data of hook %s: %s", resume.HookStepCompleted, err)
+	}
+	return h.checkpoint.CompleteStep(step.Step, step.State)
+}
+
+// newMachineHook forgets the completed provisioners when builders report that
+// they start a new machine by running the resume.HookNewMachine hook.
+type newMachineHook struct {
+	checkpoint *BuildCheckpoint
+}
+
+var _ packersdk.Hook = new(newMachineHook)
+
+func (h *newMachineHook) Run(context.Context, string, packersdk.Ui, packersdk.Communicator, interface{}) error {
+	return h.checkpoint.resetProvisioners()
+}
@@ -0,0 +1,141 @@
+package packer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/common/resume"
+)
+
+func TestBuildCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateDir := filepath.Join(dir, StateDir)
+
+	cp, err := OpenBuildCheckpoint(stateDir, "virtualbox-iso.ubuntu", "fp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Resumed() {
+		t.Fatal("a build without state must not be resumed")
+	}
+	if err := cp.CompleteStep("create_vm", map[string]string{"vm": "ubuntu"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.completeProvisioner(0); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = OpenBuildCheckpoint(stateDir, "virtualbox-iso.ubuntu", "fp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Resumed() {
+		t.Fatal("the saved state must be resumed")
+	}
+	if state, ok := cp.CompletedStep("create_vm"); !ok || state["vm"] != "ubuntu" {
+		t.Fatalf("bad state of the step: %#v", state)
+	}
+	if _, ok := cp.CompletedStep("export"); ok {
+		t.Fatal("export did not complete")
+	}
+	if !cp.completedProvisioner(0) || cp.completedProvisioner(1) {
+		t.Fatalf("bad completed provisioners: %#v", cp.state.Provisioners)
+	}
+
+	// a new machine runs the provisioners again, the steps stay completed
+	hook := &newMachineHook{cp}
+	if err := hook.Run(context.Background(), resume.HookNewMachine, nil, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = OpenBuildCheckpoint(stateDir, "virtualbox-iso.ubuntu", "fp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.completedProvisioner(0) {
+		t.Fatal("the provisioners must run again on a new machine")
+	}
+	if _, ok := cp.CompletedStep("create_vm"); !ok {
+		t.Fatal("the completed steps must be kept")
+	}
+
+	cp, err = OpenBuildCheckpoint(stateDir, "virtualbox-iso.ubuntu", "fp2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Resumed() {
+		t.Fatal("the state of another configuration must not be resumed")
+	}
+
+	if err := cp.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stateDir); !os.IsNotExist(err) {
+		t.Fatalf("the empty state folder must be removed: %v", err)
+	}
+}
+
+func TestCoreBuild_resumePlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateDir := filepath.Join(dir, StateDir)
+	target := filepath.Join(dir, "target")
+
+	// run runs the file builder as a plugin, resuming its saved state.
+	run := func() *BuildCheckpoint {
+		c := NewClient(&PluginClientConfig{Cmd: helperProcess("file-builder")})
+		defer c.Kill()
+		builder, err := c.Builder()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !CanResume(builder) {
+			t.Fatal("the file builder must be able to resume builds")
+		}
+
+		b := &CoreBuild{
+			Type:          "file",
+			BuilderType:   "file",
+			Builder:       builder,
+			BuilderConfig: map[string]interface{}{"target": target, "content": "created"},
+		}
+		b.SetResumeStateFile(BuildStateFile(stateDir, b.Name()))
+		if _, err := b.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		cp, err := OpenBuildCheckpoint(stateDir, b.Name(), "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.SetCheckpoint(cp)
+		if _, err := b.Run(context.Background(), TestUi(t)); err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+
+	cp := run()
+	if _, ok := cp.CompletedStep("write_target"); !ok {
+		t.Fatal("the step completed by the plugin must be saved")
+	}
+
+	// The resumed build does not write the target again.
+	if err := ioutil.WriteFile(target, []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cp := run(); !cp.Resumed() {
+		t.Fatal("the build must be resumed")
+	}
+	if content, err := ioutil.ReadFile(target); err != nil || string(content) != "kept" {
+		t.Fatalf("the completed step must be skipped: %q, %v", content, err)
+	}
+}
@@ -2,8 +2,11 @@ package packer
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -48,6 +51,7 @@ func testDefaultPackerConfig() map[string]interface{} {
 		common.DebugConfigKey:         false,
 		common.ForceConfigKey:         false,
 		common.OnErrorConfigKey:       "cleanup",
+		SkipCreateArtifactConfigKey:   false,
 		common.TemplatePathKey:        "",
 		common.UserVariablesConfigKey: make(map[string]string),
 	}
@@ -224,6 +228,32 @@ func TestBuildPrepare_ProvisionerGetsGeneratedMap(t *testing.T) {
 	}
 }
 
+func TestBuildPrepare_UnknownBuildVariable(t *testing.T) {
+	build := testBuild()
+	builder := build.Builder.(*packersdk.MockBuilder)
+	builder.GeneratedVars = []string{"ArtifactID"}
+	build.Provisioners[0].config = []interface{}{map[string]interface{}{
+		"inline": []interface{}{"echo {{ build `ArtifactID` }} {{ build `Host` }}"},
+	}}
+	build.PostProcessors[0][0].config = map[string]interface{}{
+		"output": "{{build `ID`}}-{{ build \"ArtifactId\" }}.box",
+	}
+
+	_, err := build.Prepare()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), `post-processor "testPP" references the unknown build variable "ArtifactId"`) {
+		t.Fatalf("bad: %s", err)
+	}
+	if strings.Count(err.Error(), "unknown build variable") != 1 {
+		t.Fatalf("only ArtifactId should be reported: %s", err)
+	}
+	if build.Provisioners[0].Provisioner.(*packersdk.MockProvisioner).PrepCalled {
+		t.Fatal("provisioners should not be prepared")
+	}
+}
+
 func TestBuild_Run(t *testing.T) {
 	ui := testUi()
 
@@ -273,6 +303,88 @@ func TestBuild_Run(t *testing.T) {
 	}
 }
 
+// metadataProvisioner attaches metadata to the artifacts of its build.
+type metadataProvisioner struct {
+	packersdk.MockProvisioner
+	metadata [][]string
+}
+
+func (p *metadataProvisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, data map[string]interface{}) error {
+	for _, args := range p.metadata {
+		ui.Machine(MachineArtifactMetadata, args...)
+	}
+	return nil
+}
+
+func TestBuild_Run_ArtifactMetadata(t *testing.T) {
+	build := testBuild()
+	build.Provisioners = []CoreBuildProvisioner{{
+		PType: "metadata",
+		Provisioner: &metadataProvisioner{metadata: [][]string{
+			{"nginx_version", "1.18.0"},
+			{"cis_score", "87"},
+			{"ignored"},
+			{"cis_score", "92"},
+		}},
+	}}
+	build.Prepare()
+
+	artifacts, err := build.Run(context.Background(), testUi())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("bad: %#v", artifacts)
+	}
+	expected := map[string]string{
+		"nginx_version": "1.18.0",
+		"cis_score":     "92",
+	}
+	for _, artifact := range artifacts {
+		if got := ArtifactMetadata(artifact); !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected metadata of %s: %#v", artifact, got)
+		}
+	}
+}
+
+func TestBuild_Run_NoArtifactMetadata(t *testing.T) {
+	build := testBuild()
+	build.Prepare()
+
+	artifacts, err := build.Run(context.Background(), testUi())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, artifact := range artifacts {
+		if _, ok := artifact.(*metadataArtifact); ok {
+			t.Errorf("artifact %s should not be wrapped", artifact)
+		}
+		if metadata := ArtifactMetadata(artifact); metadata != nil {
+			t.Errorf("unexpected metadata: %v", metadata)
+		}
+	}
+}
+
+func TestBuild_Run_Timeout(t *testing.T) {
+	build := testBuild()
+	build.Timeout = 10 * time.Millisecond
+	build.Builder.(*packersdk.MockBuilder).RunFn = func(ctx context.Context) {
+		<-ctx.Done()
+	}
+	build.Prepare()
+
+	_, err := build.Run(context.Background(), testUi())
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("the error must wrap the deadline of the build: %#v", err)
+	}
+}
+
 func TestBuild_Run_Artifacts(t *testing.T) {
 	ui := testUi()
 
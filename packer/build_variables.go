@@ -0,0 +1,77 @@
+package packer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+var buildVariableCall = regexp.MustCompile("{{\\s*build\\s+[`\"]([^`\"]*)[`\"]\\s*}}")
+
+// checkBuildVariables returns an error for each `{{ build "key" }}` call of a
+// provisioner or post-processor config whose key is not exported by the
+// builder, so that a typo fails the validation instead of being rendered as an
+// empty string at the end of the build. available are the exported keys.
+func (b *CoreBuild) checkBuildVariables(available map[string]string) error {
+	keys := make([]string, 0, len(available))
+	for k := range available {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs error
+	check := func(plugin string, config interface{}) {
+		for _, key := range buildVariableReferences(config) {
+			if _, ok := available[key]; ok {
+				continue
+			}
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"%s references the unknown build variable %q; the %s builder exports: %s",
+				plugin, key, b.BuilderType, strings.Join(keys, ", ")))
+		}
+	}
+
+	for _, coreProv := range b.Provisioners {
+		if len(coreProv.config) > 0 {
+			check(fmt.Sprintf("provisioner %q", coreProv.PType), coreProv.config[0])
+		}
+	}
+	if b.CleanupProvisioner.PType != "" && len(b.CleanupProvisioner.config) > 0 {
+		check(fmt.Sprintf("error-cleanup-provisioner %q", b.CleanupProvisioner.PType), b.CleanupProvisioner.config[0])
+	}
+	for _, ppSeq := range b.PostProcessors {
+		for _, corePP := range ppSeq {
+			check(fmt.Sprintf("post-processor %q", corePP.PType), corePP.config)
+		}
+	}
+	return errs
+}
+
+// buildVariableReferences returns the build variable keys used in the string
+// values of a raw config, in order of appearance.
+func buildVariableReferences(config interface{}) []string {
+	var keys []string
+	switch config := config.(type) {
+	case string:
+		for _, match := range buildVariableCall.FindAllStringSubmatch(config, -1) {
+			keys = append(keys, match[1])
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(config))
+		for k := range config {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			keys = append(keys, buildVariableReferences(config[k])...)
+		}
+	case []interface{}:
+		for _, v := range config {
+			keys = append(keys, buildVariableReferences(v)...)
+		}
+	}
+	return keys
+}
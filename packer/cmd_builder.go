@@ -14,6 +14,7 @@ type cmdBuilder struct {
 }
 
 func (b *cmdBuilder) ConfigSpec() hcldec.ObjectSpec {
+	defer b.client.measure("Builder.ConfigSpec")()
 	defer func() {
 		r := recover()
 		b.checkExit(r, nil)
@@ -23,6 +24,7 @@ func (b *cmdBuilder) ConfigSpec() hcldec.ObjectSpec {
 }
 
 func (b *cmdBuilder) Prepare(config ...interface{}) ([]string, []string, error) {
+	defer b.client.measure("Builder.Prepare")()
 	defer func() {
 		r := recover()
 		b.checkExit(r, nil)
@@ -32,6 +34,7 @@ func (b *cmdBuilder) Prepare(config ...interface{}) ([]string, []string, error)
 }
 
 func (b *cmdBuilder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	defer b.client.measure("Builder.Run")()
 	defer func() {
 		r := recover()
 		b.checkExit(r, nil)
@@ -14,6 +14,7 @@ type cmdDatasource struct {
 }
 
 func (d *cmdDatasource) ConfigSpec() hcldec.ObjectSpec {
+	defer d.client.measure("Datasource.ConfigSpec")()
 	defer func() {
 		r := recover()
 		d.checkExit(r, nil)
@@ -23,6 +24,7 @@ func (d *cmdDatasource) ConfigSpec() hcldec.ObjectSpec {
 }
 
 func (d *cmdDatasource) Configure(configs ...interface{}) error {
+	defer d.client.measure("Datasource.Configure")()
 	defer func() {
 		r := recover()
 		d.checkExit(r, nil)
@@ -32,6 +34,7 @@ func (d *cmdDatasource) Configure(configs ...interface{}) error {
 }
 
 func (d *cmdDatasource) OutputSpec() hcldec.ObjectSpec {
+	defer d.client.measure("Datasource.OutputSpec")()
 	defer func() {
 		r := recover()
 		d.checkExit(r, nil)
@@ -41,6 +44,7 @@ func (d *cmdDatasource) OutputSpec() hcldec.ObjectSpec {
 }
 
 func (d *cmdDatasource) Execute() (cty.Value, error) {
+	defer d.client.measure("Datasource.Execute")()
 	defer func() {
 		r := recover()
 		d.checkExit(r, nil)
@@ -49,6 +53,12 @@ func (d *cmdDatasource) Execute() (cty.Value, error) {
 	return d.d.Execute()
 }
 
+// Kill stops the plugin of the data source, ending its calls, like an
+// Execute call that takes too long.
+func (d *cmdDatasource) Kill() {
+	d.client.Kill()
+}
+
 func (d *cmdDatasource) checkExit(p interface{}, cb func()) {
 	if d.client.Exited() && cb != nil {
 		cb()
@@ -13,6 +13,7 @@ type cmdHook struct {
 }
 
 func (c *cmdHook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packersdk.Communicator, data interface{}) error {
+	defer c.client.measure("Hook.Run")()
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
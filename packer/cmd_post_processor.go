@@ -14,6 +14,7 @@ type cmdPostProcessor struct {
 }
 
 func (b *cmdPostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	defer b.client.measure("PostProcessor.ConfigSpec")()
 	defer func() {
 		r := recover()
 		b.checkExit(r, nil)
@@ -23,6 +24,7 @@ func (b *cmdPostProcessor) ConfigSpec() hcldec.ObjectSpec {
 }
 
 func (c *cmdPostProcessor) Configure(config ...interface{}) error {
+	defer c.client.measure("PostProcessor.Configure")()
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
@@ -32,6 +34,7 @@ func (c *cmdPostProcessor) Configure(config ...interface{}) error {
 }
 
 func (c *cmdPostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	defer c.client.measure("PostProcessor.PostProcess")()
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
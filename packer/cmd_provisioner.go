@@ -14,6 +14,7 @@ type cmdProvisioner struct {
 }
 
 func (p *cmdProvisioner) ConfigSpec() hcldec.ObjectSpec {
+	defer p.client.measure("Provisioner.ConfigSpec")()
 	defer func() {
 		r := recover()
 		p.checkExit(r, nil)
@@ -23,6 +24,7 @@ func (p *cmdProvisioner) ConfigSpec() hcldec.ObjectSpec {
 }
 
 func (c *cmdProvisioner) Prepare(configs ...interface{}) error {
+	defer c.client.measure("Provisioner.Prepare")()
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
@@ -32,6 +34,7 @@ func (c *cmdProvisioner) Prepare(configs ...interface{}) error {
 }
 
 func (c *cmdProvisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
+	defer c.client.measure("Provisioner.Provision")()
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
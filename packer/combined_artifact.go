@@ -0,0 +1,133 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// CombinedArtifactBuilderId is the builder id of combined artifacts.
+const CombinedArtifactBuilderId = "packer.combined"
+
+// CombinedArtifact is the artifacts of all the builds of a run, given as one
+// artifact to the post-processors of the template.
+type CombinedArtifact struct {
+	// Builds are the names of the builds of Artifacts.
+	Builds    []string
+	Artifacts []packersdk.Artifact
+}
+
+func (a *CombinedArtifact) BuilderId() string {
+	return CombinedArtifactBuilderId
+}
+
+// Files returns the files of all the artifacts.
+func (a *CombinedArtifact) Files() []string {
+	var files []string
+	for _, artifact := range a.Artifacts {
+		files = append(files, artifact.Files()...)
+	}
+	return files
+}
+
+// Id returns the ids of the artifacts, separated by commas.
+func (a *CombinedArtifact) Id() string {
+	ids := make([]string, len(a.Artifacts))
+	for i, artifact := range a.Artifacts {
+		ids[i] = artifact.Id()
+	}
+	return strings.Join(ids, ",")
+}
+
+func (a *CombinedArtifact) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d artifacts:", len(a.Artifacts))
+	for i, artifact := range a.Artifacts {
+		fmt.Fprintf(&b, "\n%s: %s", a.Builds[i], artifact.String())
+	}
+	return b.String()
+}
+
+// State returns nil, the state of each artifact is only known to the builder
+// that created it.
+func (a *CombinedArtifact) State(name string) interface{} {
+	return nil
+}
+
+// Destroy does nothing: the artifacts of the builds are kept whatever the
+// post-processors of the template do.
+func (a *CombinedArtifact) Destroy() error {
+	return nil
+}
+
+// RunCombinedPostProcessors runs the post-processors of the template on the
+// combined artifact of the builds, and returns the artifacts they created.
+// Like the post-processors of a build, each chain of post-processors runs on
+// the output of the previous post-processor of the chain.
+func RunCombinedPostProcessors(ctx context.Context, originalUi packersdk.Ui, chains [][]CoreBuildPostProcessor, combined *CombinedArtifact) ([]packersdk.Artifact, error) {
+	var artifacts []packersdk.Artifact
+	var errors []error
+
+ChainLoop:
+	for _, chain := range chains {
+		var priorArtifact packersdk.Artifact = combined
+		for i, corePP := range chain {
+			if err := ctx.Err(); err != nil {
+				return artifacts, err
+			}
+			ppUi := &TargetedUI{
+				Target: fmt.Sprintf("post-processors (%s)", corePP.PType),
+				Ui:     originalUi,
+			}
+
+			if corePP.PName == corePP.PType {
+				ppUi.Say(fmt.Sprintf("Running post-processor on the artifacts of all builds: %s", corePP.PType))
+			} else {
+				ppUi.Say(fmt.Sprintf("Running post-processor on the artifacts of all builds: %s (type %s)", corePP.PName, corePP.PType))
+			}
+			ts := CheckpointReporter.AddSpan(corePP.PType, "post-processor", corePP.config)
+			artifact, defaultKeep, forceOverride, err := corePP.PostProcessor.PostProcess(ctx, ppUi, priorArtifact)
+			ts.End(err)
+			if err != nil {
+				errors = append(errors, fmt.Errorf("Post-processor failed: %s", err))
+				continue ChainLoop
+			}
+
+			if artifact == nil {
+				log.Println("Nil artifact, halting post-processor chain.")
+				continue ChainLoop
+			}
+
+			// The combined artifact is never destroyed, the artifacts created
+			// along a chain are unless they are kept.
+			if i > 0 {
+				keep := defaultKeep
+				if corePP.KeepInputArtifact != nil && !(defaultKeep && forceOverride) {
+					keep = *corePP.KeepInputArtifact
+				}
+				if keep {
+					artifacts = append(artifacts, priorArtifact)
+				} else {
+					log.Printf("Deleting prior artifact from post-processor '%s'", corePP.PType)
+					if err := priorArtifact.Destroy(); err != nil {
+						errors = append(errors, fmt.Errorf("Failed cleaning up prior artifact: %s; pp is %s", err, corePP.PType))
+					}
+				}
+			}
+
+			priorArtifact = artifact
+		}
+
+		if len(chain) > 0 {
+			artifacts = append(artifacts, priorArtifact)
+		}
+	}
+
+	if len(errors) > 0 {
+		return artifacts, &packersdk.MultiError{Errors: errors}
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,59 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestCombinedArtifact(t *testing.T) {
+	a := &CombinedArtifact{
+		Builds: []string{"amazon-ebs.us", "amazon-ebs.eu"},
+		Artifacts: []packersdk.Artifact{
+			&packersdk.MockArtifact{IdValue: "ami-1", FilesValue: []string{"a"}},
+			&packersdk.MockArtifact{IdValue: "ami-2", FilesValue: []string{"b", "c"}},
+		},
+	}
+	if a.Id() != "ami-1,ami-2" {
+		t.Errorf("unexpected id %q", a.Id())
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, a.Files()); diff != "" {
+		t.Errorf("unexpected files: %s", diff)
+	}
+}
+
+func TestRunCombinedPostProcessors(t *testing.T) {
+	combined := &CombinedArtifact{
+		Builds:    []string{"a", "b"},
+		Artifacts: []packersdk.Artifact{new(packersdk.MockArtifact), new(packersdk.MockArtifact)},
+	}
+	first := &MockPostProcessor{ArtifactId: "first"}
+	second := &MockPostProcessor{ArtifactId: "second"}
+	failing := &MockPostProcessor{Error: errors.New("replication failed")}
+
+	chains := [][]CoreBuildPostProcessor{
+		{
+			{PostProcessor: first, PType: "first", PName: "first"},
+			{PostProcessor: second, PType: "second", PName: "second"},
+		},
+		{
+			{PostProcessor: failing, PType: "failing", PName: "failing"},
+		},
+	}
+	artifacts, err := RunCombinedPostProcessors(context.Background(), testUi(), chains, combined)
+	if err == nil {
+		t.Fatal("expected the error of the failing post-processor")
+	}
+	if first.PostProcessArtifact != combined || failing.PostProcessArtifact != combined {
+		t.Error("the first post-processor of each chain should receive the combined artifact")
+	}
+	if second.PostProcessArtifact == nil || second.PostProcessArtifact.Id() != "first" {
+		t.Errorf("the second post-processor should receive the artifact of the first, got %v", second.PostProcessArtifact)
+	}
+	if len(artifacts) != 1 || artifacts[0].Id() != "second" {
+		t.Errorf("expected the artifact of the second post-processor, got %v", artifacts)
+	}
+}
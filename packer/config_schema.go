@@ -0,0 +1,64 @@
+package packer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// checkSchema checks that the configurations of the builder, provisioners and
+// post-processors of build b only set settings of their schema, without
+// preparing them. Unlike Prepare, it does not check the values of the
+// settings, so it needs no credentials or network.
+func checkSchema(b *CoreBuild) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	check := func(plugin string, speccer packersdk.HCL2Speccer, config interface{}) {
+		raw, ok := config.(map[string]interface{})
+		if !ok {
+			return
+		}
+		spec := speccer.ConfigSpec()
+		if spec == nil {
+			return
+		}
+		keys := make([]string, 0, len(raw))
+		for k := range raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// like the config decoder of the plugins, ignore the keys set
+			// by packer.
+			if k == "type" || strings.HasPrefix(k, "packer_") {
+				continue
+			}
+			if _, found := spec[k]; !found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unknown setting",
+					Detail:   fmt.Sprintf("The %s has no %q setting.", plugin, k),
+				})
+			}
+		}
+	}
+
+	check(fmt.Sprintf("%s builder of build %q", b.BuilderType, b.Name()), b.Builder, b.BuilderConfig)
+	provisioners := b.Provisioners
+	if b.CleanupProvisioner.PType != "" {
+		provisioners = append(provisioners[:len(provisioners):len(provisioners)], b.CleanupProvisioner)
+	}
+	for _, p := range provisioners {
+		if len(p.config) > 0 {
+			check(fmt.Sprintf("%s provisioner", p.PType), p.Provisioner, p.config[0])
+		}
+	}
+	for _, pps := range b.PostProcessors {
+		for _, pp := range pps {
+			check(fmt.Sprintf("%s post-processor", pp.PType), pp.PostProcessor, pp.config)
+		}
+	}
+	return diags
+}
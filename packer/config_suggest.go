@@ -0,0 +1,53 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/agext/levenshtein"
+	"github.com/hashicorp/hcl/v2/hcldec"
+)
+
+// unknownConfigKeyRe matches the errors of the plugin SDK about keys of JSON
+// templates that a component does not know.
+var unknownConfigKeyRe = regexp.MustCompile(`unknown configuration key: '"([^"]+)"'`)
+
+// suggestConfigKeys adds to the unknown configuration key errors of err the
+// closest key of spec, the config spec of the component that failed to
+// prepare, as HCL2 does for unsupported arguments.
+func suggestConfigKeys(err error, spec hcldec.ObjectSpec) error {
+	if err == nil || len(spec) == 0 {
+		return err
+	}
+	msg := err.Error()
+	if !unknownConfigKeyRe.MatchString(msg) {
+		return err
+	}
+	var keys []string
+	for key := range spec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msg = unknownConfigKeyRe.ReplaceAllStringFunc(msg, func(match string) string {
+		unknown := unknownConfigKeyRe.FindStringSubmatch(match)[1]
+		if suggestion := nameSuggestion(unknown, keys); suggestion != "" {
+			return fmt.Sprintf("%s. Did you mean %q?", match, suggestion)
+		}
+		return match
+	})
+	return errors.New(msg)
+}
+
+// nameSuggestion returns the name of suggestions closest to given, or "" when
+// none is close enough.
+func nameSuggestion(given string, suggestions []string) string {
+	best, bestDist := "", 3 // like HCL2
+	for _, suggestion := range suggestions {
+		if dist := levenshtein.Distance(given, suggestion, nil); dist < bestDist {
+			best, bestDist = suggestion, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,37 @@
+package packer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSuggestConfigKeys(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"iso_checksum": &hcldec.AttrSpec{Name: "iso_checksum", Type: cty.String},
+		"iso_url":      &hcldec.AttrSpec{Name: "iso_url", Type: cty.String},
+	}
+	for _, tc := range []struct {
+		err      string
+		expected string
+	}{
+		{
+			`unknown configuration key: '"iso_chksum"'`,
+			`unknown configuration key: '"iso_chksum"'. Did you mean "iso_checksum"?`,
+		},
+		{
+			"2 errors occurred:\n\t* unknown configuration key: '\"iso_ur\"'\n\t* unknown configuration key: '\"memory\"'\n\n",
+			"2 errors occurred:\n\t* unknown configuration key: '\"iso_ur\"'. Did you mean \"iso_url\"?\n\t* unknown configuration key: '\"memory\"'\n\n",
+		},
+		{
+			"iso_url is required",
+			"iso_url is required",
+		},
+	} {
+		if err := suggestConfigKeys(errors.New(tc.err), spec); err.Error() != tc.expected {
+			t.Errorf("got %q, expected %q", err, tc.expected)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
 // Core is the main executor of Packer. If Packer is being used as a
@@ -128,14 +129,22 @@ func NewCore(c *CoreConfig) *Core {
 }
 
 func (core *Core) Initialize() error {
+	return core.InitializeWithOptions(InitializeOptions{})
+}
+
+// InitializeWithOptions is Initialize with options. Initialize keeps taking
+// none since plugins call it from the acceptance tests of the SDK.
+func (core *Core) InitializeWithOptions(opts InitializeOptions) error {
 	if err := core.validate(); err != nil {
 		return err
 	}
 	if err := core.init(); err != nil {
 		return err
 	}
-	for _, secret := range core.secrets {
-		packersdk.LogSecretFilter.Set(secret)
+	if !opts.ShowSensitive {
+		for _, secret := range core.secrets {
+			packersdk.LogSecretFilter.Set(secret)
+		}
 	}
 
 	// Go through and interpolate all the build names. We should be able
@@ -185,12 +194,12 @@ func (c *Core) generateCoreBuildProvisioner(rawP *template.Provisioner, rawName
 	cbp := CoreBuildProvisioner{}
 	provisioner, err := c.components.PluginConfig.Provisioners.Start(rawP.Type)
 	if err != nil {
-		return cbp, fmt.Errorf(
+		return cbp, messages.Codef(messages.CodePluginStart,
 			"error initializing provisioner '%s': %s",
 			rawP.Type, err)
 	}
 	if provisioner == nil {
-		return cbp, fmt.Errorf(
+		return cbp, messages.Codef(messages.CodeUnknownComponent,
 			"provisioner type not found: %s", rawP.Type)
 	}
 
@@ -246,7 +255,18 @@ func (c *Core) GetBuilds(opts GetBuildsOptions) ([]packersdk.Build, hcl.Diagnost
 	buildNames := c.BuildNames(opts.Only, opts.Except)
 	builds := []packersdk.Build{}
 	diags := hcl.Diagnostics{}
+	seenDiags := map[string]bool{}
 	for _, n := range buildNames {
+		if opts.CheckLocalPaths {
+			for _, diag := range c.checkLocalPaths(n) {
+				// provisioners are shared between builds
+				if !seenDiags[diag.Detail] {
+					seenDiags[diag.Detail] = true
+					diags = append(diags, diag)
+				}
+			}
+		}
+
 		b, err := c.Build(n)
 		if err != nil {
 			diags = append(diags, &hcl.Diagnostic{
@@ -262,6 +282,27 @@ func (c *Core) GetBuilds(opts GetBuildsOptions) ([]packersdk.Build, hcl.Diagnost
 		b.SetDebug(opts.Debug)
 		b.SetForce(opts.Force)
 		b.SetOnError(opts.OnError)
+		if cb, ok := b.(*CoreBuild); ok {
+			cb.SetSkipCreateArtifact(opts.SkipCreateArtifact)
+			if opts.ResumeStateDir != "" {
+				cb.SetResumeStateFile(BuildStateFile(opts.ResumeStateDir, cb.Name()))
+			}
+		}
+
+		if opts.SchemaOnly {
+			if cb, ok := b.(*CoreBuild); ok {
+				for _, diag := range checkSchema(cb) {
+					// provisioners and post-processors are shared between
+					// builds
+					if !seenDiags[diag.Detail] {
+						seenDiags[diag.Detail] = true
+						diags = append(diags, diag)
+					}
+				}
+			}
+			builds = append(builds, b)
+			continue
+		}
 
 		warnings, err := b.Prepare()
 		if err != nil {
@@ -281,7 +322,7 @@ func (c *Core) GetBuilds(opts GetBuildsOptions) ([]packersdk.Build, hcl.Diagnost
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: hcl.DiagWarning,
 					Summary:  fmt.Sprintf("Warning when preparing build: %q", n),
-					Detail:   warning,
+					Detail:   messages.CodePluginWarning.Annotate(warning),
 				})
 			}
 		}
@@ -304,12 +345,12 @@ func (c *Core) Build(n string) (packersdk.Build, error) {
 	// calling Prepare() or passing any build-specific details.
 	builder, err := c.components.PluginConfig.Builders.Start(configBuilder.Type)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, messages.Codef(messages.CodePluginStart,
 			"error initializing builder '%s': %s",
 			configBuilder.Type, err)
 	}
 	if builder == nil {
-		return nil, fmt.Errorf(
+		return nil, messages.Codef(messages.CodeUnknownComponent,
 			"builder type not found: %s", configBuilder.Type)
 	}
 
@@ -364,12 +405,12 @@ func (c *Core) Build(n string) (packersdk.Build, error) {
 			// Get the post-processor
 			postProcessor, err := c.components.PluginConfig.PostProcessors.Start(rawP.Type)
 			if err != nil {
-				return nil, fmt.Errorf(
+				return nil, messages.Codef(messages.CodePluginStart,
 					"error initializing post-processor '%s': %s",
 					rawP.Type, err)
 			}
 			if postProcessor == nil {
-				return nil, fmt.Errorf(
+				return nil, messages.Codef(messages.CodeUnknownComponent,
 					"post-processor type not found: %s", rawP.Type)
 			}
 
@@ -461,7 +502,27 @@ func (c *Core) EvaluateExpression(line string) (string, bool, hcl.Diagnostics) {
 	}
 }
 
+// isSensitiveVariable tells whether the template lists name in its
+// sensitive-variables.
+func (c *Core) isSensitiveVariable(name string) bool {
+	for _, sensitive := range c.Template.SensitiveVariables {
+		if sensitive.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Core) InspectConfig(opts InspectConfigOptions) int {
+	if opts.JSON {
+		return WriteInspectOutput(opts.Ui, c.inspectOutput(opts.ShowSensitive))
+	}
+	if opts.Resolve {
+		// JSON templates read their variables from -var and -var-file
+		// only, which inspect shows as is.
+		opts.Ui.Error("-resolve is only supported by HCL2 templates.")
+		return 1
+	}
 
 	// Convenience...
 	ui := opts.Ui
@@ -479,12 +540,22 @@ func (c *Core) InspectConfig(opts InspectConfigOptions) int {
 		ui.Say("Variables:\n")
 		ui.Say("  <No variables>")
 	} else {
+		keys := make([]string, 0, len(tpl.Variables))
+		max := 0
+		for k := range tpl.Variables {
+			keys = append(keys, k)
+			if len(k) > max {
+				max = len(k)
+			}
+		}
+
+		sort.Strings(keys)
+
 		requiredHeader := false
-		for k, v := range tpl.Variables {
-			for _, sensitive := range tpl.SensitiveVariables {
-				if ok := strings.Compare(sensitive.Default, v.Default); ok == 0 {
-					v.Default = "<sensitive>"
-				}
+		for _, k := range keys {
+			v := tpl.Variables[k]
+			if c.isSensitiveVariable(k) && !opts.ShowSensitive {
+				v.Default = "<sensitive>"
 			}
 			if v.Required {
 				if !requiredHeader {
@@ -502,26 +573,13 @@ func (c *Core) InspectConfig(opts InspectConfigOptions) int {
 		}
 
 		ui.Say("Optional variables and their defaults:\n")
-		keys := make([]string, 0, len(tpl.Variables))
-		max := 0
-		for k := range tpl.Variables {
-			keys = append(keys, k)
-			if len(k) > max {
-				max = len(k)
-			}
-		}
-
-		sort.Strings(keys)
-
 		for _, k := range keys {
 			v := tpl.Variables[k]
 			if v.Required {
 				continue
 			}
-			for _, sensitive := range tpl.SensitiveVariables {
-				if ok := strings.Compare(sensitive.Default, v.Default); ok == 0 {
-					v.Default = "<sensitive>"
-				}
+			if c.isSensitiveVariable(k) && !opts.ShowSensitive {
+				v.Default = "<sensitive>"
 			}
 
 			padding := strings.Repeat(" ", max-len(k))
@@ -584,6 +642,80 @@ func (c *Core) InspectConfig(opts InspectConfigOptions) int {
 	return 0
 }
 
+// inspectOutput returns the structure of the template, with a single build
+// of all the builders.
+func (c *Core) inspectOutput(showSensitive bool) *InspectOutput {
+	tpl := c.Template
+	out := &InspectOutput{
+		Mode:        "json",
+		Description: tpl.Description,
+		Variables:   []InspectVariable{},
+		Locals:      []InspectLocal{},
+		Sources:     []InspectSource{},
+	}
+
+	keys := make([]string, 0, len(tpl.Variables))
+	for k := range tpl.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := tpl.Variables[k]
+		variable := InspectVariable{
+			Name:      k,
+			Required:  v.Required,
+			Sensitive: c.isSensitiveVariable(k),
+		}
+		if !v.Required {
+			def := v.Default
+			if variable.Sensitive && !showSensitive {
+				def = "<sensitive>"
+			}
+			variable.Default, _ = json.Marshal(def)
+		}
+		out.Variables = append(out.Variables, variable)
+	}
+
+	build := InspectBuild{
+		Sources:        []string{},
+		Provisioners:   []InspectComponent{},
+		PostProcessors: [][]InspectComponent{},
+	}
+	keys = make([]string, 0, len(tpl.Builders))
+	for k := range tpl.Builders {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out.Sources = append(out.Sources, InspectSource{Name: k, Type: tpl.Builders[k].Type})
+		build.Sources = append(build.Sources, k)
+	}
+	for _, p := range tpl.Provisioners {
+		build.Provisioners = append(build.Provisioners, InspectComponent{
+			Type:   p.Type,
+			Only:   p.Only,
+			Except: p.Except,
+		})
+	}
+	if p := tpl.CleanupProvisioner; p != nil {
+		build.ErrorCleanupProvisioner = &InspectComponent{Type: p.Type}
+	}
+	for _, list := range tpl.PostProcessors {
+		var chain []InspectComponent
+		for _, p := range list {
+			chain = append(chain, InspectComponent{
+				Type:   p.Type,
+				Name:   p.Name,
+				Only:   p.Only,
+				Except: p.Except,
+			})
+		}
+		build.PostProcessors = append(build.PostProcessors, chain)
+	}
+	out.Builds = []InspectBuild{build}
+	return out
+}
+
 func (c *Core) FixConfig(opts FixConfigOptions) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 
@@ -670,7 +802,7 @@ func (c *Core) validate() error {
 		}
 
 		if versionActual.LessThan(versionMin) {
-			return fmt.Errorf(
+			return messages.Codef(messages.CodeVersionRequired,
 				"This template requires Packer version %s or higher; using %s",
 				versionMin,
 				versionActual)
@@ -682,7 +814,7 @@ func (c *Core) validate() error {
 	for n, v := range c.Template.Variables {
 		if v.Required {
 			if _, ok := c.variables[n]; !ok {
-				err = multierror.Append(err, fmt.Errorf(
+				err = multierror.Append(err, messages.Codef(messages.CodeVariableNotSet,
 					"required variable not set: %s", n))
 			}
 		}
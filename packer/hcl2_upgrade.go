@@ -0,0 +1,76 @@
+package packer
+
+// HCL2Upgrader helps `packer hcl2_upgrade` convert the JSON configuration of a
+// type of builder, provisioner or post-processor to HCL2 when a plain
+// translation of the settings is not enough, for example because a setting
+// was renamed or is better expressed as a data source. HCL2Upgraders are
+// registered in packer by the type of plugin they convert, as the plugin
+// protocol cannot ask plugins.
+type HCL2Upgrader interface {
+	// HCL2Upgrade tells how to convert the JSON configuration of the plugin.
+	// It returns nil when the configuration can be translated as is.
+	HCL2Upgrade(config map[string]interface{}) (*HCL2Upgrade, error)
+}
+
+// HCL2Upgrade tells `packer hcl2_upgrade` how to convert the JSON
+// configuration of a plugin.
+type HCL2Upgrade struct {
+	// Config, when set, replaces the configuration of the plugin, for
+	// conversions that cannot be expressed with Renamed, like transforming
+	// nested settings. Renamed and Datasources then apply to Config.
+	Config map[string]interface{}
+
+	// Renamed maps the settings to rename to their new name.
+	Renamed map[string]string
+
+	// Datasources are generated in place of some settings of the builder.
+	Datasources []HCL2UpgradeDatasource
+}
+
+// HCL2UpgradeDatasource is a data source generated in place of some settings
+// of a plugin.
+type HCL2UpgradeDatasource struct {
+	// Type of the data source, for example "amazon-ami".
+	Type string
+
+	// Description is written as a comment above the first data source of
+	// this type. It should tell what the data source was generated from.
+	Description string
+
+	// Config is the configuration of the data source. Identical data sources
+	// are only written once.
+	Config map[string]interface{}
+
+	// Replaces are the settings of the plugin removed in favor of the data
+	// source.
+	Replaces []string
+
+	// Setting of the plugin that is set to the Output of the data source.
+	Setting string
+	Output  string
+}
+
+// HCL2UpgraderRegistry holds HCL2Upgraders by the type of the plugin they
+// upgrade.
+type HCL2UpgraderRegistry struct {
+	Builders       map[string]HCL2Upgrader
+	Provisioners   map[string]HCL2Upgrader
+	PostProcessors map[string]HCL2Upgrader
+}
+
+// Lookup returns the HCL2Upgrader registered for the plugin of kind, one of
+// "builder", "provisioner" or "post-processor", and typ, or nil.
+func (r *HCL2UpgraderRegistry) Lookup(kind, typ string) HCL2Upgrader {
+	if r == nil {
+		return nil
+	}
+	switch kind {
+	case "builder":
+		return r.Builders[typ]
+	case "provisioner":
+		return r.Provisioners[typ]
+	case "post-processor":
+		return r.PostProcessors[typ]
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// InspectOutput is the structure of a template written by packer inspect
+// -output=json, for tools auditing templates. JSON templates have a single
+// build, of all their builders.
+type InspectOutput struct {
+	// Mode is "hcl2" or "json", the language of the template.
+	Mode        string            `json:"mode"`
+	Description string            `json:"description,omitempty"`
+	Variables   []InspectVariable `json:"variables"`
+	Locals      []InspectLocal    `json:"locals"`
+	Sources     []InspectSource   `json:"sources"`
+	Builds      []InspectBuild    `json:"builds"`
+	// PostProcessors are the post-processors of all the builds, of the
+	// top-level post-processors blocks of HCL2 templates.
+	PostProcessors [][]InspectComponent `json:"post_processors,omitempty"`
+}
+
+// InspectVariable is an input variable of a template. Default is the JSON
+// value of its default, "<sensitive>" for the sensitive variables unless
+// their values are shown.
+type InspectVariable struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Required    bool            `json:"required"`
+	Sensitive   bool            `json:"sensitive"`
+}
+
+// InspectLocal is a local variable of an HCL2 template. Value is not set when
+// it is not known without building, like the outputs of data sources.
+type InspectLocal struct {
+	Name      string          `json:"name"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Sensitive bool            `json:"sensitive"`
+}
+
+// InspectSource is a source block of an HCL2 template, or a builder of a JSON
+// template. Type is the type of builder, which tells the plugin running it.
+type InspectSource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// InspectBuild is a build of a template, with its provisioners in the order
+// they run in.
+type InspectBuild struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Sources     []string `json:"sources"`
+
+	Provisioners            []InspectComponent `json:"provisioners"`
+	ErrorCleanupProvisioner *InspectComponent  `json:"error_cleanup_provisioner,omitempty"`
+	// PostProcessors are the chains of post-processors of the build.
+	PostProcessors [][]InspectComponent `json:"post_processors"`
+}
+
+// InspectComponent is a provisioner or a post-processor.
+type InspectComponent struct {
+	Type   string   `json:"type"`
+	Name   string   `json:"name,omitempty"`
+	Only   []string `json:"only,omitempty"`
+	Except []string `json:"except,omitempty"`
+	// Parallel numbers the parallel blocks of a build, from 1: the
+	// provisioners of the same block run at the same time.
+	Parallel int `json:"parallel,omitempty"`
+}
+
+// WriteInspectOutput writes out to ui as indented JSON.
+func WriteInspectOutput(ui packersdk.Ui, out *InspectOutput) int {
+	js, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to write the template as JSON: %s", err))
+		return 1
+	}
+	ui.Say(string(js))
+	return 0
+}
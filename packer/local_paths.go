@@ -0,0 +1,107 @@
+package packer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// LocalPathSettings are the well-known builder and provisioner settings
+// referencing local files or directories, checked by `packer validate`.
+var LocalPathSettings = []string{
+	"cd_files",
+	"floppy_dirs",
+	"floppy_files",
+	"http_directory",
+	"script",
+	"scripts",
+	"ssh_private_key_file",
+}
+
+// CheckLocalPath returns an error diagnostic when path, referenced by the
+// setting of a plugin, does not exist. path can be a glob pattern, as
+// floppy_files and cd_files accept them. It returns nil when path exists.
+func CheckLocalPath(plugin, setting, path string, subject *hcl.Range) *hcl.Diagnostic {
+	if matches, err := filepath.Glob(path); err == nil && len(matches) > 0 {
+		return nil
+	}
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Missing local file",
+		Detail:   fmt.Sprintf("The %s setting of %s references %q, which does not exist.", setting, plugin, path),
+		Subject:  subject,
+	}
+}
+
+// localPaths calls fn with the local paths referenced by the builder and the
+// provisioners of build n. Paths that cannot be interpolated before the build
+// starts are left out.
+func (c *Core) localPaths(n string, fn func(plugin, setting, path string)) {
+	configBuilder, ok := c.builds[n]
+	if !ok {
+		return
+	}
+	walk := func(plugin string, config map[string]interface{}) {
+		for _, setting := range LocalPathSettings {
+			var paths []interface{}
+			switch v := config[setting].(type) {
+			case string:
+				paths = []interface{}{v}
+			case []interface{}:
+				paths = v
+			}
+			for _, path := range paths {
+				path, ok := path.(string)
+				if !ok || path == "" {
+					continue
+				}
+				rendered, err := interpolate.Render(path, c.Context())
+				if err != nil {
+					continue
+				}
+				fn(plugin, setting, rendered)
+			}
+		}
+	}
+
+	walk(fmt.Sprintf("builder %q", n), configBuilder.Config)
+	for _, rawP := range c.Template.Provisioners {
+		if rawP.OnlyExcept.Skip(configBuilder.Name) {
+			continue
+		}
+		walk(fmt.Sprintf("provisioner %q", rawP.Type), rawP.Config)
+	}
+}
+
+// checkLocalPaths checks the local paths referenced by the builder and the
+// provisioners of build n.
+func (c *Core) checkLocalPaths(n string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	c.localPaths(n, func(plugin, setting, path string) {
+		if diag := CheckLocalPath(plugin, setting, path, nil); diag != nil {
+			diags = append(diags, diag)
+		}
+	})
+	return diags
+}
+
+// LocalFiles returns the local paths referenced by the well-known settings
+// of the builders and provisioners of the builds, for `packer plan` to tell
+// when they change.
+func (c *Core) LocalFiles() []string {
+	seen := map[string]bool{}
+	var res []string
+	for n := range c.builds {
+		c.localPaths(n, func(_, _, path string) {
+			if !seen[path] {
+				seen[path] = true
+				res = append(res, path)
+			}
+		})
+	}
+	sort.Strings(res)
+	return res
+}
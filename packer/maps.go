@@ -2,6 +2,7 @@ package packer
 
 import (
 	"fmt"
+	"sort"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -30,6 +31,7 @@ func (mop MapOfProvisioner) List() []string {
 	for k := range mop {
 		res = append(res, k)
 	}
+	sort.Strings(res)
 	return res
 }
 
@@ -57,6 +59,7 @@ func (mopp MapOfPostProcessor) List() []string {
 	for k := range mopp {
 		res = append(res, k)
 	}
+	sort.Strings(res)
 	return res
 }
 
@@ -84,6 +87,7 @@ func (mob MapOfBuilder) List() []string {
 	for k := range mob {
 		res = append(res, k)
 	}
+	sort.Strings(res)
 	return res
 }
 
@@ -111,5 +115,6 @@ func (mod MapOfDatasource) List() []string {
 	for k := range mod {
 		res = append(res, k)
 	}
+	sort.Strings(res)
 	return res
 }
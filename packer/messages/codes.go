@@ -0,0 +1,185 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// MachineErrorCode is the type of the machine-readable line giving the code
+// and the documentation of an error, output along with the error.
+const MachineErrorCode = "error-code"
+
+// Code is the stable code of a common error, like PKR1101. Unlike the text of
+// errors, codes never change, so that tools and runbooks can match on them.
+// Codes are never reused: remove a code rather than change its meaning.
+type Code string
+
+const (
+	// Configuration errors.
+	CodeTemplateParse    Code = "PKR1001"
+	CodeVersionRequired  Code = "PKR1002"
+	CodeVariableNotSet   Code = "PKR1003"
+	CodeInvalidVariable  Code = "PKR1004"
+	CodeUnknownComponent Code = "PKR1005"
+
+	// Plugin errors.
+	CodePluginStart Code = "PKR1101"
+
+	// Warnings. Their codes start with PKRW, see IsWarning.
+	CodeUndeclaredVariable Code = "PKRW001"
+	CodeOverrideApplied    Code = "PKRW002"
+	CodePluginWarning      Code = "PKRW003"
+	CodeUnusedDeclaration  Code = "PKRW004"
+	CodePluginConflict     Code = "PKRW005"
+)
+
+// codeDocs is the base URL of the documentation of error codes, which has one
+// section per code.
+const codeDocs = "https://www.packer.io/docs/errors#"
+
+// IsWarning tells whether c is the code of a warning. Warnings can be
+// suppressed, or turned into errors.
+func (c Code) IsWarning() bool {
+	return strings.HasPrefix(string(c), "PKRW")
+}
+
+// URL returns the address of the documentation of the code.
+func (c Code) URL() string {
+	return codeDocs + strings.ToLower(string(c))
+}
+
+// Annotate appends the code and the address of its documentation to msg, the
+// detail of a diagnostic for example, on a line of their own.
+func (c Code) Annotate(msg string) string {
+	annotation := fmt.Sprintf("(%s, see %s)", string(c), c.URL())
+	if msg == "" {
+		return annotation
+	}
+	return msg + "\n" + annotation
+}
+
+// annotationRe matches the annotations of Annotate.
+var annotationRe = regexp.MustCompile(`\((PKRW?[0-9]+), see ` + regexp.QuoteMeta(codeDocs) + `pkrw?[0-9]+\)`)
+
+// CodedError is an error with a stable code.
+type CodedError struct {
+	Code Code
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Code.Annotate(e.Err.Error())
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode returns err with code, or nil when err is nil.
+func WithCode(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// Codef is like fmt.Errorf, returning an error with code.
+func Codef(code Code, format string, args ...interface{}) error {
+	return &CodedError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Codes returns the codes of err, looking into the errors it wraps and into
+// multierrors.
+func Codes(err error) []Code {
+	if merr, ok := err.(*multierror.Error); ok {
+		var codes []Code
+		for _, err := range merr.Errors {
+			codes = append(codes, Codes(err)...)
+		}
+		return codes
+	}
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return []Code{coded.Code}
+	}
+	return nil
+}
+
+// DiagnosticCodes returns the codes of the errors of diags, found in their
+// detail.
+func DiagnosticCodes(diags hcl.Diagnostics) []Code {
+	var codes []Code
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		codes = append(codes, diagnosticCodes(diag)...)
+	}
+	return codes
+}
+
+func diagnosticCodes(diag *hcl.Diagnostic) []Code {
+	var codes []Code
+	for _, match := range annotationRe.FindAllStringSubmatch(diag.Detail, -1) {
+		codes = append(codes, Code(match[1]))
+	}
+	return codes
+}
+
+// SuppressWarnings returns diags without the warnings having one of the
+// suppressed codes.
+func SuppressWarnings(diags hcl.Diagnostics, suppressed []Code) hcl.Diagnostics {
+	if len(suppressed) == 0 {
+		return diags
+	}
+	var res hcl.Diagnostics
+	for _, diag := range diags {
+		if diag.Severity == hcl.DiagWarning && hasCode(diagnosticCodes(diag), suppressed) {
+			continue
+		}
+		res = append(res, diag)
+	}
+	return res
+}
+
+func hasCode(codes, search []Code) bool {
+	for _, c := range codes {
+		for _, s := range search {
+			if c == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WarningsAsErrors returns diags with their warnings turned into errors, and
+// whether there were any.
+func WarningsAsErrors(diags hcl.Diagnostics) (hcl.Diagnostics, bool) {
+	res := make(hcl.Diagnostics, len(diags))
+	promoted := false
+	for i, diag := range diags {
+		if diag.Severity == hcl.DiagWarning {
+			copied := *diag
+			copied.Severity = hcl.DiagError
+			diag = &copied
+			promoted = true
+		}
+		res[i] = diag
+	}
+	return res, promoted
+}
+
+// MachineCodes outputs the codes in machine-readable mode, with the address of
+// their documentation.
+func MachineCodes(ui packersdk.Ui, codes []Code) {
+	for _, code := range codes {
+		ui.Machine(MachineErrorCode, string(code), code.URL())
+	}
+}
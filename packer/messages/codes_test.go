@@ -0,0 +1,88 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestCodedError(t *testing.T) {
+	err := Codef(CodeUnknownComponent, "builder type not found: %s", "foo")
+	expected := "builder type not found: foo\n(PKR1005, see https://www.packer.io/docs/errors#pkr1005)"
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+	}
+
+	if WithCode(CodePluginStart, nil) != nil {
+		t.Error("WithCode of a nil error should be nil")
+	}
+}
+
+func TestCodes(t *testing.T) {
+	var err error
+	err = multierror.Append(err, Codef(CodeVariableNotSet, "required variable not set: a"))
+	err = multierror.Append(err, errors.New("not a coded error"))
+	err = multierror.Append(err, fmt.Errorf("wrapped: %w", WithCode(CodePluginStart, errors.New("boom"))))
+
+	expected := []Code{CodeVariableNotSet, CodePluginStart}
+	if diff := cmp.Diff(expected, Codes(err)); diff != "" {
+		t.Errorf("unexpected codes: %s", diff)
+	}
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	diags := hcl.Diagnostics{
+		{Severity: hcl.DiagError, Summary: "Unset variable", Detail: CodeVariableNotSet.Annotate("A used variable must be set.")},
+		{Severity: hcl.DiagWarning, Summary: "Warning", Detail: CodeInvalidVariable.Annotate("")},
+		{Severity: hcl.DiagError, Summary: "Uncoded error"},
+		// errors of the JSON core are diagnostics with the text of the error
+		{Severity: hcl.DiagError, Detail: Codef(CodeVersionRequired, "too old").Error()},
+	}
+
+	expected := []Code{CodeVariableNotSet, CodeVersionRequired}
+	if diff := cmp.Diff(expected, DiagnosticCodes(diags)); diff != "" {
+		t.Errorf("unexpected codes: %s", diff)
+	}
+}
+
+func TestSuppressWarnings(t *testing.T) {
+	diags := hcl.Diagnostics{
+		{Severity: hcl.DiagWarning, Summary: "Override file applied", Detail: CodeOverrideApplied.Annotate("")},
+		{Severity: hcl.DiagWarning, Summary: "Plugin warning", Detail: CodePluginWarning.Annotate("deprecated")},
+		{Severity: hcl.DiagWarning, Summary: "Uncoded warning"},
+		// errors are never suppressed
+		{Severity: hcl.DiagError, Summary: "Undefined variable", Detail: CodeUndeclaredVariable.Annotate("")},
+	}
+
+	res := SuppressWarnings(diags, []Code{CodeOverrideApplied, CodeUndeclaredVariable})
+	var summaries []string
+	for _, diag := range res {
+		summaries = append(summaries, diag.Summary)
+	}
+	expected := []string{"Plugin warning", "Uncoded warning", "Undefined variable"}
+	if diff := cmp.Diff(expected, summaries); diff != "" {
+		t.Errorf("unexpected diagnostics: %s", diff)
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	warning := &hcl.Diagnostic{Severity: hcl.DiagWarning, Detail: CodePluginWarning.Annotate("deprecated")}
+	res, promoted := WarningsAsErrors(hcl.Diagnostics{warning})
+	if !promoted || !res.HasErrors() {
+		t.Fatalf("expected the warning to be an error, got %v", res)
+	}
+	if warning.Severity != hcl.DiagWarning {
+		t.Error("the original diagnostic should not be changed")
+	}
+	if diff := cmp.Diff([]Code{CodePluginWarning}, DiagnosticCodes(res)); diff != "" {
+		t.Errorf("unexpected codes: %s", diff)
+	}
+
+	if _, promoted := WarningsAsErrors(hcl.Diagnostics{{Severity: hcl.DiagError}}); promoted {
+		t.Error("errors should not be reported as promoted")
+	}
+}
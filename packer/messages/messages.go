@@ -0,0 +1,172 @@
+// Package messages holds the messages packer shows to users, so that they can
+// be translated and identified.
+//
+// Every message has a stable ID, output in machine-readable mode before the
+// message itself, and an English default. Translations are JSON files mapping
+// IDs to messages, loaded from the locales folder of the packer config
+// directory for the locale set with PACKER_LOCALE, LC_ALL, LC_MESSAGES or
+// LANG, for example ~/.packer.d/locales/fr_FR.json or fr.json.
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// LocaleEnvVar sets the locale of messages. When it is not set, the usual
+// LC_ALL, LC_MESSAGES and LANG variables are looked at.
+const LocaleEnvVar = "PACKER_LOCALE"
+
+// MachineMessageID is the type of the machine-readable line giving the ID of
+// the message that follows it.
+const MachineMessageID = "message-id"
+
+// ID is the stable identifier of a message.
+type ID string
+
+const (
+	DebugNotParallel           ID = "debug-not-parallel"
+	BuildCancelled             ID = "build-cancelled"
+	BuildErrored               ID = "build-errored"
+	BuildFinished              ID = "build-finished"
+	BuildsCancelled            ID = "builds-cancelled"
+	BuildsCancelledBeforeStart ID = "builds-cancelled-before-start"
+	BuildsWaitCompleted        ID = "builds-wait-completed"
+	BuildsErrored              ID = "builds-errored"
+	BuildsArtifacts            ID = "builds-artifacts"
+	BuildsNoArtifacts          ID = "builds-no-artifacts"
+	InterruptCancelling        ID = "interrupt-cancelling"
+	InterruptExiting           ID = "interrupt-exiting"
+)
+
+// defaults are the English messages, shown when the current locale does not
+// translate a message. They are fmt formats.
+var defaults = map[ID]string{
+	DebugNotParallel:           "Debug mode enabled. Builds will not be parallelized.",
+	BuildCancelled:             "Build '%s' cancelled after %s: %s",
+	BuildErrored:               "Build '%s' errored after %s: %s",
+	BuildFinished:              "Build '%s' finished after %s.",
+	BuildsCancelled:            "Cleanly cancelled builds after being interrupted.",
+	BuildsCancelledBeforeStart: "Cancelled before starting any build after being interrupted.",
+	BuildsWaitCompleted:        "\n==> Wait completed after %s",
+	BuildsErrored:              "\n==> Some builds didn't complete successfully and had errors:",
+	BuildsArtifacts:            "\n==> Builds finished. The artifacts of successful builds are:",
+	BuildsNoArtifacts:          "\n==> Builds finished but no artifacts were created.",
+	InterruptCancelling:        "Cancelling after receiving %s, interrupt again to exit immediately",
+	InterruptExiting:           "Exiting after receiving %s again",
+}
+
+var catalog = struct {
+	sync.RWMutex
+	m map[ID]string
+}{}
+
+// Locale returns the locale set in the environment, like "fr_FR", or an empty
+// string when there is none.
+func Locale() string {
+	for _, name := range []string{LocaleEnvVar, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		// drop the encoding and modifier, like in fr_FR.UTF-8@euro
+		if i := strings.IndexAny(locale, ".@"); i >= 0 {
+			locale = locale[:i]
+		}
+		switch locale {
+		case "":
+			continue
+		case "C", "POSIX":
+			return ""
+		}
+		return locale
+	}
+	return ""
+}
+
+// Load loads the translations of the current locale from dir. The file of the
+// full locale, like fr_FR.json, is preferred to the one of its language, like
+// fr.json. Having no translation is not an error.
+func Load(dir string) error {
+	locale := Locale()
+	if locale == "" {
+		return nil
+	}
+	candidates := []string{locale}
+	if i := strings.Index(locale, "_"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	for _, name := range candidates {
+		path := filepath.Join(dir, name+".json")
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var translations map[ID]string
+		if err := json.Unmarshal(b, &translations); err != nil {
+			return fmt.Errorf("Error reading translations in %s: %s", path, err)
+		}
+		log.Printf("[INFO] Using the %s translations of %s", locale, path)
+		SetTranslations(translations)
+		return nil
+	}
+	return nil
+}
+
+// SetTranslations replaces the translations in use. A translation must use
+// the same number of fmt verbs as its default message, otherwise it is
+// ignored.
+func SetTranslations(translations map[ID]string) {
+	m := make(map[ID]string, len(translations))
+	for id, msg := range translations {
+		def, known := defaults[id]
+		switch {
+		case !known:
+			log.Printf("[WARN] ignoring the translation of unknown message %q", id)
+		case countVerbs(msg) != countVerbs(def):
+			log.Printf("[WARN] ignoring the translation of message %q, it does not have the arguments of %q", id, def)
+		default:
+			m[id] = msg
+		}
+	}
+	catalog.Lock()
+	defer catalog.Unlock()
+	catalog.m = m
+}
+
+// countVerbs counts the fmt verbs of format.
+func countVerbs(format string) int {
+	return strings.Count(format, "%") - 2*strings.Count(format, "%%")
+}
+
+// Sprintf formats the message id in the current locale.
+func Sprintf(id ID, args ...interface{}) string {
+	catalog.RLock()
+	format, found := catalog.m[id]
+	catalog.RUnlock()
+	if !found {
+		format = defaults[id]
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Say says the message id on ui, after its ID in machine-readable mode.
+func Say(ui packersdk.Ui, id ID, args ...interface{}) {
+	ui.Machine(MachineMessageID, string(id))
+	ui.Say(Sprintf(id, args...))
+}
+
+// Error shows the error message id on ui, after its ID in machine-readable
+// mode.
+func Error(ui packersdk.Ui, id ID, args ...interface{}) {
+	ui.Machine(MachineMessageID, string(id))
+	ui.Error(Sprintf(id, args...))
+}
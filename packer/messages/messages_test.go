@@ -0,0 +1,113 @@
+package messages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestLocale(t *testing.T) {
+	for _, tc := range []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"LANG": "fr_FR.UTF-8"}, "fr_FR"},
+		{map[string]string{"LANG": "de_DE@euro"}, "de_DE"},
+		{map[string]string{"LANG": "C"}, ""},
+		{map[string]string{"LANG": "en_US", "LC_ALL": "POSIX"}, ""},
+		{map[string]string{"LANG": "en_US", "LC_MESSAGES": "es"}, "es"},
+		{map[string]string{"LC_ALL": "en_US", LocaleEnvVar: "ja_JP"}, "ja_JP"},
+	} {
+		for _, name := range []string{LocaleEnvVar, "LC_ALL", "LC_MESSAGES", "LANG"} {
+			os.Setenv(name, tc.env[name])
+		}
+		if got := Locale(); got != tc.expected {
+			t.Errorf("Locale() with %v = %q, expected %q", tc.env, got, tc.expected)
+		}
+	}
+	for _, name := range []string{LocaleEnvVar, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetTranslations(nil)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{
+		"build-finished": "Build '%s' terminé après %s.",
+		"build-errored": "Build '%s' en erreur",
+		"unknown-message": "inconnu"
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(LocaleEnvVar, "fr_FR.UTF-8")
+	defer os.Unsetenv(LocaleEnvVar)
+	if err := Load(dir); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if got, expected := Sprintf(BuildFinished, "vm", "2s"), "Build 'vm' terminé après 2s."; got != expected {
+		t.Errorf("translated message is %q, expected %q", got, expected)
+	}
+	// the translation without the arguments of the default is ignored
+	if got, expected := Sprintf(BuildErrored, "vm", "2s", "boom"), "Build 'vm' errored after 2s: boom"; got != expected {
+		t.Errorf("untranslatable message is %q, expected %q", got, expected)
+	}
+	// messages without translation fall back to English
+	if got, expected := Sprintf(BuildsNoArtifacts), defaults[BuildsNoArtifacts]; got != expected {
+		t.Errorf("untranslated message is %q, expected %q", got, expected)
+	}
+}
+
+func TestLoad_broken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(LocaleEnvVar, "fr")
+	defer os.Unsetenv(LocaleEnvVar)
+	if err := Load(dir); err == nil {
+		t.Fatal("expected an error loading broken translations")
+	}
+}
+
+func TestSay(t *testing.T) {
+	ui := &packersdk.MockUi{}
+	Say(ui, BuildFinished, "vm", "2s")
+
+	if ui.MachineType != MachineMessageID || len(ui.MachineArgs) != 1 || ui.MachineArgs[0] != string(BuildFinished) {
+		t.Errorf("unexpected machine-readable message %s %v", ui.MachineType, ui.MachineArgs)
+	}
+	if len(ui.SayMessages) != 1 || ui.SayMessages[0].Message != "Build 'vm' finished after 2s." {
+		t.Errorf("unexpected messages %v", ui.SayMessages)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	for _, id := range []ID{
+		DebugNotParallel, BuildCancelled, BuildErrored, BuildFinished,
+		BuildsCancelled, BuildsCancelledBeforeStart, BuildsWaitCompleted,
+		BuildsErrored, BuildsArtifacts, BuildsNoArtifacts,
+		InterruptCancelling, InterruptExiting,
+	} {
+		if defaults[id] == "" {
+			t.Errorf("message %q has no default", id)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package github
 import (
 	"bufio"
 	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +10,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,6 +28,11 @@ const (
 type Getter struct {
 	Client    *github.Client
 	UserAgent string
+
+	// BaseURL, when set, is the address of a registry serving the GitHub API
+	// and the downloads of releases, like a mirror or a TestRegistry. It
+	// replaces both api.github.com and github.com.
+	BaseURL string
 }
 
 var _ plugingetter.Getter = &Getter{}
@@ -154,7 +159,7 @@ func (t *HostSpecificTokenAuthTransport) base() http.RoundTripper {
 }
 
 func (g *Getter) Get(what string, opts plugingetter.GetOptions) (io.ReadCloser, error) {
-	ctx := context.TODO()
+	ctx := opts.Context()
 	if g.Client == nil {
 		var tc *http.Client
 		if tk := os.Getenv(ghTokenAccessor); tk != "" {
@@ -175,6 +180,17 @@ func (g *Getter) Get(what string, opts plugingetter.GetOptions) (io.ReadCloser,
 		if g.UserAgent != "" {
 			g.Client.UserAgent = g.UserAgent
 		}
+		if g.BaseURL != "" {
+			u, err := url.Parse(strings.TrimSuffix(g.BaseURL, "/") + "/")
+			if err != nil {
+				return nil, fmt.Errorf("invalid registry URL %q: %w", g.BaseURL, err)
+			}
+			g.Client.BaseURL = u
+		}
+	}
+	downloadURL := "https://github.com/"
+	if g.BaseURL != "" {
+		downloadURL = strings.TrimSuffix(g.BaseURL, "/") + "/"
 	}
 
 	var req *http.Request
@@ -190,7 +206,7 @@ func (g *Getter) Get(what string, opts plugingetter.GetOptions) (io.ReadCloser,
 		transform = transformVersionStream
 	case "sha256":
 		// something like https://github.com/sylviamoss/packer-plugin-comment/releases/download/v0.2.11/packer-plugin-comment_v0.2.11_x5_SHA256SUMS
-		u := filepath.ToSlash("https://github.com/" + opts.PluginRequirement.Identifier.RealRelativePath() + "/releases/download/" + opts.Version() + "/" + opts.PluginRequirement.FilenamePrefix() + opts.Version() + "_SHA256SUMS")
+		u := filepath.ToSlash(downloadURL + opts.PluginRequirement.Identifier.RealRelativePath() + "/releases/download/" + opts.Version() + "/" + opts.PluginRequirement.FilenamePrefix() + opts.Version() + "_SHA256SUMS")
 		req, err = g.Client.NewRequest(
 			"GET",
 			u,
@@ -198,7 +214,7 @@ func (g *Getter) Get(what string, opts plugingetter.GetOptions) (io.ReadCloser,
 		)
 		transform = tranformChecksumStream()
 	case "zip":
-		u := filepath.ToSlash("https://github.com/" + opts.PluginRequirement.Identifier.RealRelativePath() + "/releases/download/" + opts.Version() + "/" + opts.ExpectedZipFilename())
+		u := filepath.ToSlash(downloadURL + opts.PluginRequirement.Identifier.RealRelativePath() + "/releases/download/" + opts.Version() + "/" + opts.ExpectedZipFilename())
 		req, err = g.Client.NewRequest(
 			"GET",
 			u,
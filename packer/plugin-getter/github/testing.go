@@ -0,0 +1,95 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
+)
+
+// TestRegistry is a plugin registry serving releases the way GitHub does, so
+// that tests installing plugins do not depend on GitHub. Set the BaseURL of a
+// Getter, or the -registry-url flag of packer init, to its URL.
+type TestRegistry struct {
+	*httptest.Server
+
+	l sync.Mutex
+	// tags of the repositories, like "hashicorp/packer-plugin-comment"
+	tags map[string][]string
+	// files of the releases, by path
+	files map[string][]byte
+}
+
+// NewTestRegistry starts a TestRegistry without releases. Close it at the end
+// of the test.
+func NewTestRegistry(t *testing.T) *TestRegistry {
+	r := &TestRegistry{
+		tags:  map[string][]string{},
+		files: map[string][]byte{},
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// AddRelease adds the version release of the plugin of repo, like
+// "hashicorp/packer-plugin-comment", with binary built for opts.
+func (r *TestRegistry) AddRelease(t *testing.T, repo, version string, opts plugingetter.BinaryInstallationOptions, binary []byte) {
+	name := strings.TrimPrefix(path.Base(repo), "packer-plugin-")
+	prefix := fmt.Sprintf("packer-plugin-%s_%s", name, version)
+	binaryName := fmt.Sprintf("%s_x%s.%s_%s_%s", prefix, opts.APIVersionMajor, opts.APIVersionMinor, opts.OS, opts.ARCH)
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create(binaryName + opts.Ext)
+	if err != nil {
+		t.Fatalf("zip: %s", err)
+	}
+	if _, err := w.Write(binary); err != nil {
+		t.Fatalf("zip: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %s", err)
+	}
+
+	releaseDir := path.Join("/", repo, "releases/download", version)
+	sum := sha256.Sum256(zipped.Bytes())
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.tags[repo] = append(r.tags[repo], version)
+	r.files[path.Join(releaseDir, binaryName+".zip")] = zipped.Bytes()
+	r.files[path.Join(releaseDir, prefix+"_SHA256SUMS")] = []byte(fmt.Sprintf("%x  %s.zip\n", sum, binaryName))
+}
+
+func (r *TestRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	// GitHub API: /repos/{repo}/git/matching-refs/tags
+	if strings.HasPrefix(req.URL.Path, "/repos/") && strings.HasSuffix(req.URL.Path, "/git/matching-refs/tags") {
+		repo := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/repos/"), "/git/matching-refs/tags")
+		refs := []map[string]string{}
+		for _, tag := range r.tags[repo] {
+			refs = append(refs, map[string]string{"ref": "refs/tags/" + tag})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(refs)
+		return
+	}
+
+	content, found := r.files[req.URL.Path]
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	_, _ = w.Write(content)
+}
@@ -2,6 +2,7 @@ package plugingetter
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -221,6 +222,17 @@ type GetOptions struct {
 	version *version.Version
 
 	expectedZipFilename string
+
+	ctx context.Context
+}
+
+// Context is cancelled when the installation is interrupted, getters should
+// stop their requests then.
+func (gp *GetOptions) Context() context.Context {
+	if gp.ctx == nil {
+		return context.Background()
+	}
+	return gp.ctx
 }
 
 // ExpectedZipFilename is the filename of the zip we expect to find, the
@@ -340,7 +352,24 @@ func ParseChecksumFileEntries(f io.Reader) ([]ChecksumFileEntry, error) {
 	return entries, json.NewDecoder(f).Decode(&entries)
 }
 
-func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error) {
+// contextReader stops reading from Reader once ctx is cancelled, so that
+// copying or checksumming a big file can be interrupted.
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// InstallLatest installs the highest version of the plugin matching the
+// requirement. Cancelling ctx stops the installation and removes the files
+// being downloaded or extracted.
+func (pr *Requirement) InstallLatest(ctx context.Context, opts InstallOptions) (*Installation, error) {
 
 	getters := opts.Getters
 	fail := fmt.Errorf("could not find a local nor a remote checksum for plugin %q %q", pr.Identifier, pr.VersionConstraints)
@@ -352,7 +381,11 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 		releasesFile, err := getter.Get("releases", GetOptions{
 			PluginRequirement:         pr,
 			BinaryInstallationOptions: opts.BinaryInstallationOptions,
+			ctx:                       ctx,
 		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err != nil {
 			err := fmt.Errorf("%q getter could not get release: %w", getter, err)
 			log.Printf("[TRACE] %s", err.Error())
@@ -426,7 +459,11 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 					PluginRequirement:         pr,
 					BinaryInstallationOptions: opts.BinaryInstallationOptions,
 					version:                   version,
+					ctx:                       ctx,
 				})
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
 				if err != nil {
 					err := fmt.Errorf("could not get %s checksum file for %s version %s. Is the file present on the release and correctly named ? %s", checksummer.Type, pr.Identifier.ForDisplay(), version, err)
 					log.Printf("[TRACE] %s", err.Error())
@@ -509,6 +546,7 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 						if err != nil {
 							return nil, fmt.Errorf("could not create temporary file to dowload plugin: %w", err)
 						}
+						defer os.Remove(tmpFile.Name())
 						defer tmpFile.Close()
 
 						// start fetching binary
@@ -517,7 +555,11 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 							BinaryInstallationOptions: opts.BinaryInstallationOptions,
 							version:                   version,
 							expectedZipFilename:       expectedZipFilename,
+							ctx:                       ctx,
 						})
+						if ctx.Err() != nil {
+							return nil, ctx.Err()
+						}
 						if err != nil {
 							err := fmt.Errorf("could not get binary for %s version %s. Is the file present on the release and correctly named ? %s", pr.Identifier.ForDisplay(), version, err)
 							log.Printf("[TRACE] %v", err)
@@ -525,8 +567,11 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 						}
 
 						// write binary to tmp file
-						_, err = io.Copy(tmpFile, remoteZipFile)
+						_, err = io.Copy(tmpFile, &contextReader{ctx, remoteZipFile})
 						_ = remoteZipFile.Close()
+						if ctx.Err() != nil {
+							return nil, ctx.Err()
+						}
 						if err != nil {
 							err := fmt.Errorf("Error getting plugin: %w", err)
 							log.Printf("[TRACE] %v, trying another getter", err)
@@ -540,7 +585,10 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 						}
 
 						// verify that the checksum for the zip is what we expect.
-						if err := checksum.Checksummer.Checksum(checksum.Expected, tmpFile); err != nil {
+						if err := checksum.Checksummer.Checksum(checksum.Expected, &contextReader{ctx, tmpFile}); err != nil {
+							if ctx.Err() != nil {
+								return nil, ctx.Err()
+							}
 							err := fmt.Errorf("%w. Is the checksum file correct ? Is the binary file correct ?", err)
 							log.Printf("%s, truncating the zipfile", err)
 							if err := tmpFile.Truncate(0); err != nil {
@@ -584,7 +632,13 @@ func (pr *Requirement) InstallLatest(opts InstallOptions) (*Installation, error)
 						}
 						defer outputFile.Close()
 
-						if _, err := io.Copy(outputFile, copyFrom); err != nil {
+						if _, err := io.Copy(outputFile, &contextReader{ctx, copyFrom}); err != nil {
+							// Do not leave a partial binary behind.
+							_ = outputFile.Close()
+							_ = os.Remove(outputFileName)
+							if ctx.Err() != nil {
+								return nil, ctx.Err()
+							}
 							err := fmt.Errorf("Extract file: %v", err)
 							return nil, err
 						}
@@ -3,6 +3,7 @@ package plugingetter
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -554,7 +555,7 @@ func TestRequirement_InstallLatest(t *testing.T) {
 				Identifier:         identifier,
 				VersionConstraints: cts,
 			}
-			got, err := pr.InstallLatest(tt.args.opts)
+			got, err := pr.InstallLatest(context.Background(), tt.args.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Requirement.InstallLatest() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -577,6 +578,80 @@ func TestRequirement_InstallLatest(t *testing.T) {
 	}
 }
 
+func TestRequirement_InstallLatest_cancelled(t *testing.T) {
+	identifier, diags := addrs.ParsePluginSourceString("amazon")
+	if len(diags) != 0 {
+		t.Fatalf("ParsePluginSourceString: %v", diags)
+	}
+	cts, err := version.NewConstraint(">= v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := &Requirement{
+		Identifier:         identifier,
+		VersionConstraints: cts,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	getter := &cancellingPluginGetter{
+		Getter: &mockPluginGetter{
+			Releases: []Release{
+				{Version: "v2.10.1"},
+			},
+			ChecksumFileEntries: map[string][]ChecksumFileEntry{
+				"2.10.1": {{
+					Filename: "packer-plugin-amazon_v2.10.1_x6.1_darwin_amd64.zip",
+					Checksum: "90ca5b0f13a90238b62581bbf30bacd7e2c9af6592c7f4849627bddbcb039dec",
+				}},
+			},
+			Zips: map[string]io.ReadCloser{
+				"github.com/hashicorp/packer-plugin-amazon/packer-plugin-amazon_v2.10.1_x6.1_darwin_amd64.zip": zipFile(map[string]string{
+					"packer-plugin-amazon_v2.10.1_x6.1_darwin_amd64": "v2.10.1_x6.1_darwin_amd64",
+				}),
+			},
+		},
+		cancelOn: "zip",
+		cancel:   cancel,
+	}
+
+	got, err := pr.InstallLatest(ctx, InstallOptions{
+		Getters:   []Getter{getter},
+		InFolders: []string{pluginFolderOne, pluginFolderTwo},
+		BinaryInstallationOptions: BinaryInstallationOptions{
+			APIVersionMajor: "6", APIVersionMinor: "1",
+			OS: "darwin", ARCH: "amd64",
+			Checksummers: []Checksummer{
+				{Type: "sha256", Hash: sha256.New()},
+			},
+		},
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected the installation to be cancelled, got %v, %v", got, err)
+	}
+	binary := filepath.Join(pluginFolderTwo, "github.com", "hashicorp", "amazon", "packer-plugin-amazon_v2.10.1_x6.1_darwin_amd64")
+	if _, err := os.Stat(binary); !os.IsNotExist(err) {
+		os.Remove(binary)
+		t.Fatalf("expected no binary to be installed, stat: %v", err)
+	}
+}
+
+// cancellingPluginGetter cancels the installation when it is asked to get
+// cancelOn, as if it was interrupted during that download.
+type cancellingPluginGetter struct {
+	Getter
+	cancelOn string
+	cancel   context.CancelFunc
+}
+
+func (g *cancellingPluginGetter) Get(what string, options GetOptions) (io.ReadCloser, error) {
+	rc, err := g.Getter.Get(what, options)
+	if what == g.cancelOn {
+		g.cancel()
+	}
+	return rc, err
+}
+
 type mockPluginGetter struct {
 	Releases            []Release
 	ChecksumFileEntries map[string][]ChecksumFileEntry
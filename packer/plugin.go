@@ -2,6 +2,7 @@ package packer
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -10,9 +11,11 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/hashicorp/hcl/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
+	"github.com/hashicorp/packer/packer/messages"
 )
 
 // PluginConfig helps load and use packer plugins
@@ -24,6 +27,45 @@ type PluginConfig struct {
 	Provisioners       ProvisionerSet
 	PostProcessors     PostProcessorSet
 	DataSources        DatasourceSet
+	// Commands are the subcommands offered by multi-component plugins,
+	// keyed by `<plugin> <command>`.
+	Commands map[string]PluginCommand
+
+	// installs are the plugin binaries found for each component, keyed
+	// like `builder "amazon-ebs"`, in the order they were found: the last one
+	// is used.
+	installs map[string][]pluginInstall
+}
+
+// pluginInstall is a plugin binary providing a component.
+type pluginInstall struct {
+	path string
+	// origin tells where the plugin was found, like "PACKER_PLUGIN_PATH".
+	origin string
+}
+
+// PluginCommand is a subcommand offered by a plugin, run as
+// `packer <plugin> <command>`.
+type PluginCommand struct {
+	Plugin   string
+	Name     string
+	Synopsis string
+	// Path is the path to the plugin binary.
+	Path string
+}
+
+// Cmd returns the command running the subcommand with args. A plugin runs its
+// subcommands when started as `packer-plugin-NAME command <command> [args]`.
+func (c PluginCommand) Cmd(args ...string) *exec.Cmd {
+	return exec.Command(c.Path, append([]string{"command", c.Name}, args...)...)
+}
+
+// pluginDescription is the output of the describe command of a multi-component
+// plugin. On top of the components the SDK knows about, a plugin can list the
+// subcommands it offers along with their synopsis.
+type pluginDescription struct {
+	pluginsdk.SetDescription
+	Commands map[string]string `json:"commands,omitempty"`
 }
 
 // PACKERSPACE is used to represent the spaces that separate args for a command
@@ -50,6 +92,9 @@ func (c *PluginConfig) Discover() error {
 	if c.DataSources == nil {
 		c.DataSources = MapOfDatasource{}
 	}
+	if c.Commands == nil {
+		c.Commands = map[string]PluginCommand{}
+	}
 
 	// If we are already inside a plugin process we should not need to
 	// discover anything.
@@ -65,7 +110,7 @@ func (c *PluginConfig) Discover() error {
 	if err != nil {
 		log.Printf("[ERR] Error loading exe directory: %s", err)
 	} else {
-		if err := c.discoverExternalComponents(filepath.Dir(exePath)); err != nil {
+		if err := c.discoverExternalComponents(filepath.Dir(exePath), "the folder of the packer executable"); err != nil {
 			return err
 		}
 	}
@@ -75,13 +120,13 @@ func (c *PluginConfig) Discover() error {
 	if err != nil {
 		log.Printf("[ERR] Error loading config directory: %s", err)
 	} else {
-		if err := c.discoverExternalComponents(filepath.Join(dir, "plugins")); err != nil {
+		if err := c.discoverExternalComponents(filepath.Join(dir, "plugins"), "the plugins folder"); err != nil {
 			return err
 		}
 	}
 
 	// Next, look in the CWD.
-	if err := c.discoverExternalComponents("."); err != nil {
+	if err := c.discoverExternalComponents(".", "the working directory"); err != nil {
 		return err
 	}
 
@@ -95,7 +140,7 @@ func (c *PluginConfig) Discover() error {
 		}
 		plugPaths := strings.Split(packerPluginPath, sep)
 		for _, plugPath := range plugPaths {
-			if err := c.discoverExternalComponents(plugPath); err != nil {
+			if err := c.discoverExternalComponents(plugPath, "PACKER_PLUGIN_PATH"); err != nil {
 				return err
 			}
 		}
@@ -104,7 +149,7 @@ func (c *PluginConfig) Discover() error {
 	return nil
 }
 
-func (c *PluginConfig) discoverExternalComponents(path string) error {
+func (c *PluginConfig) discoverExternalComponents(path, origin string) error {
 	var err error
 
 	if !filepath.IsAbs(path) {
@@ -122,6 +167,7 @@ func (c *PluginConfig) discoverExternalComponents(path string) error {
 	for pluginName, pluginPath := range pluginPaths {
 		newPath := pluginPath // this needs to be stored in a new variable for the func below
 		c.Builders.Set(pluginName, c.Client(newPath).Builder)
+		c.recordInstall("builder", pluginName, newPath, origin)
 		externallyUsed = append(externallyUsed, pluginName)
 	}
 	if len(externallyUsed) > 0 {
@@ -137,6 +183,7 @@ func (c *PluginConfig) discoverExternalComponents(path string) error {
 	for pluginName, pluginPath := range pluginPaths {
 		newPath := pluginPath // this needs to be stored in a new variable for the func below
 		c.PostProcessors.Set(pluginName, c.Client(newPath).PostProcessor)
+		c.recordInstall("post-processor", pluginName, newPath, origin)
 		externallyUsed = append(externallyUsed, pluginName)
 	}
 	if len(externallyUsed) > 0 {
@@ -152,6 +199,7 @@ func (c *PluginConfig) discoverExternalComponents(path string) error {
 	for pluginName, pluginPath := range pluginPaths {
 		newPath := pluginPath // this needs to be stored in a new variable for the func below
 		c.Provisioners.Set(pluginName, c.Client(newPath).Provisioner)
+		c.recordInstall("provisioner", pluginName, newPath, origin)
 		externallyUsed = append(externallyUsed, pluginName)
 	}
 	if len(externallyUsed) > 0 {
@@ -166,6 +214,7 @@ func (c *PluginConfig) discoverExternalComponents(path string) error {
 	for pluginName, pluginPath := range pluginPaths {
 		newPath := pluginPath // this needs to be stored in a new variable for the func below
 		c.DataSources.Set(pluginName, c.Client(newPath).Datasource)
+		c.recordInstall("data source", pluginName, newPath, origin)
 		externallyUsed = append(externallyUsed, pluginName)
 	}
 	if len(externallyUsed) > 0 {
@@ -179,7 +228,7 @@ func (c *PluginConfig) discoverExternalComponents(path string) error {
 	}
 
 	for pluginName, pluginPath := range pluginPaths {
-		if err := c.DiscoverMultiPlugin(pluginName, pluginPath); err != nil {
+		if err := c.discoverMultiPlugin(pluginName, pluginPath, origin); err != nil {
 			return err
 		}
 	}
@@ -235,12 +284,20 @@ func (c *PluginConfig) discoverSingle(glob string) (map[string]string, error) {
 // pluginName can be extrapolated from the filename of the binary; so
 // if the "packer-plugin-amazon" binary had an "ebs" builder one could use
 // the "amazon-ebs" builder.
+//
+// DiscoverMultiPlugin is used for the plugins installed for the
+// required_plugins of a config, which take precedence over the plugins found
+// by Discover.
 func (c *PluginConfig) DiscoverMultiPlugin(pluginName, pluginPath string) error {
+	return c.discoverMultiPlugin(pluginName, pluginPath, "required_plugins")
+}
+
+func (c *PluginConfig) discoverMultiPlugin(pluginName, pluginPath, origin string) error {
 	out, err := exec.Command(pluginPath, "describe").Output()
 	if err != nil {
 		return err
 	}
-	var desc pluginsdk.SetDescription
+	var desc pluginDescription
 	if err := json.Unmarshal(out, &desc); err != nil {
 		return err
 	}
@@ -256,6 +313,7 @@ func (c *PluginConfig) DiscoverMultiPlugin(pluginName, pluginPath string) error
 		c.Builders.Set(key, func() (packersdk.Builder, error) {
 			return c.Client(pluginPath, "start", "builder", builderName).Builder()
 		})
+		c.recordInstall("builder", key, pluginPath, origin)
 	}
 
 	if len(desc.Builders) > 0 {
@@ -271,6 +329,7 @@ func (c *PluginConfig) DiscoverMultiPlugin(pluginName, pluginPath string) error
 		c.PostProcessors.Set(key, func() (packersdk.PostProcessor, error) {
 			return c.Client(pluginPath, "start", "post-processor", postProcessorName).PostProcessor()
 		})
+		c.recordInstall("post-processor", key, pluginPath, origin)
 	}
 
 	if len(desc.PostProcessors) > 0 {
@@ -286,6 +345,7 @@ func (c *PluginConfig) DiscoverMultiPlugin(pluginName, pluginPath string) error
 		c.Provisioners.Set(key, func() (packersdk.Provisioner, error) {
 			return c.Client(pluginPath, "start", "provisioner", provisionerName).Provisioner()
 		})
+		c.recordInstall("provisioner", key, pluginPath, origin)
 	}
 	if len(desc.Provisioners) > 0 {
 		log.Printf("found external %v provisioner from %s plugin", desc.Provisioners, pluginName)
@@ -296,14 +356,105 @@ func (c *PluginConfig) DiscoverMultiPlugin(pluginName, pluginPath string) error
 		c.DataSources.Set(pluginPrefix+datasourceName, func() (packersdk.Datasource, error) {
 			return c.Client(pluginPath, "start", "datasource", datasourceName).Datasource()
 		})
+		c.recordInstall("data source", pluginPrefix+datasourceName, pluginPath, origin)
 	}
 	if len(desc.Datasources) > 0 {
 		log.Printf("found external %v datasource from %s plugin", desc.Datasources, pluginName)
 	}
 
+	if c.Commands == nil {
+		c.Commands = map[string]PluginCommand{}
+	}
+	for name, synopsis := range desc.Commands {
+		if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+			log.Printf("[WARN] ignoring invalid command name %q of %s plugin", name, pluginName)
+			continue
+		}
+		c.Commands[pluginName+" "+name] = PluginCommand{
+			Plugin:   pluginName,
+			Name:     name,
+			Synopsis: synopsis,
+			Path:     pluginPath,
+		}
+	}
+	if len(desc.Commands) > 0 {
+		log.Printf("found external commands from %s plugin", pluginName)
+	}
+
 	return nil
 }
 
+// recordInstall records that the plugin binary at path, found in origin,
+// provides the component name of kind, like the "amazon-ebs" "builder".
+func (c *PluginConfig) recordInstall(kind, name, path, origin string) {
+	if c.installs == nil {
+		c.installs = map[string][]pluginInstall{}
+	}
+	component := fmt.Sprintf("%s %q", kind, name)
+	installs := c.installs[component]
+	for i, install := range installs {
+		if install.path == path {
+			installs = append(installs[:i:i], installs[i+1:]...)
+			break
+		}
+	}
+	c.installs[component] = append(installs, pluginInstall{path: path, origin: origin})
+}
+
+// ConflictDiagnostics returns a warning for every component provided by
+// several plugin binaries, for example a multi-component plugin both in the
+// plugins folder and installed for required_plugins, or a single-component
+// plugin and a multi-component one, telling which binary is used: the last one
+// found. A stale binary can otherwise shadow the expected one. The components
+// provided by the same binaries, like the ones of a plugin found twice, are
+// reported together.
+func (c *PluginConfig) ConflictDiagnostics() hcl.Diagnostics {
+	conflicts := map[string][]string{}
+	for component, installs := range c.installs {
+		if len(installs) < 2 {
+			continue
+		}
+		paths := make([]string, 0, len(installs))
+		for _, install := range installs {
+			paths = append(paths, install.path)
+		}
+		key := strings.Join(paths, "\n")
+		conflicts[key] = append(conflicts[key], component)
+	}
+	groups := make([][]string, 0, len(conflicts))
+	for _, components := range conflicts {
+		sort.Strings(components)
+		groups = append(groups, components)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	var diags hcl.Diagnostics
+	for _, components := range groups {
+		installs := c.installs[components[0]]
+		detail := &strings.Builder{}
+		if len(components) == 1 {
+			fmt.Fprintf(detail, "The %s was found in the plugins at:\n", components[0])
+		} else {
+			fmt.Fprintf(detail, "The %s were found in the plugins at:\n", strings.Join(components, ", "))
+		}
+		for _, install := range installs {
+			fmt.Fprintf(detail, "  %s, from %s\n", install.path, install.origin)
+		}
+		fmt.Fprintf(detail, "Packer uses the last one, %s. Remove the other copies so that they cannot shadow it.",
+			installs[len(installs)-1].path)
+		summary := fmt.Sprintf("Plugin %s installed several times", components[0])
+		if len(components) > 1 {
+			summary = fmt.Sprintf("Plugin %s and %d more components installed several times", components[0], len(components)-1)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  summary,
+			Detail:   messages.CodePluginConflict.Annotate(detail.String()),
+		})
+	}
+	return diags
+}
+
 func (c *PluginConfig) Client(path string, args ...string) *PluginClient {
 	originalPath := path
 
@@ -38,6 +38,12 @@ type PluginClient struct {
 	doneLogging chan struct{}
 	l           sync.Mutex
 	address     net.Addr
+
+	// metered is set when the RPC metrics are enabled; bytesSent and
+	// bytesReceived then count the traffic of all connections to the plugin.
+	metered       bool
+	bytesSent     int64
+	bytesReceived int64
 }
 
 // PluginClientConfig is the configuration used to initialize a new
@@ -114,7 +120,7 @@ func NewClient(config *PluginClientConfig) (c *PluginClient) {
 		config.Stderr = ioutil.Discard
 	}
 
-	c = &PluginClient{config: config}
+	c = &PluginClient{config: config, metered: pluginRPCMetricsEnabled()}
 	if config.Managed {
 		managedClients = append(managedClients, c)
 	}
@@ -407,6 +413,10 @@ func (c *PluginClient) Client() (*packerrpc.Client, error) {
 		tcpConn.SetKeepAlive(true)
 	}
 
+	if c.metered {
+		conn = &meteredConn{Conn: conn, client: c}
+	}
+
 	client, err := packerrpc.NewClient(conn)
 	if err != nil {
 		conn.Close()
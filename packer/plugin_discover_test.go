@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
@@ -206,6 +209,22 @@ func TestHelperPlugins(t *testing.T) {
 
 	pluginName, args := args[0], args[1:]
 
+	if commands, found := commandsMock[pluginName]; found {
+		// the SDK does not know about commands yet, so the description is
+		// written here.
+		if len(args) > 0 && args[0] == "describe" {
+			desc := pluginDescription{Commands: commands}
+			desc.Builders = []string{pluginsdk.DEFAULT_NAME}
+			if err := json.NewEncoder(os.Stdout).Encode(desc); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Unexpected arguments %v\n", args)
+		os.Exit(2)
+	}
+
 	allMocks := []map[string]pluginsdk.Set{mockPlugins, defaultNameMock, doubleDefaultMock, badDefaultNameMock}
 	for _, mock := range allMocks {
 		plugin, found := mock[pluginName]
@@ -341,6 +360,13 @@ var (
 		},
 	}
 
+	commandsMock = map[string]map[string]string{
+		"cloud": {
+			"cleanup":  "delete unused images",
+			"bad name": "not a valid command name",
+		},
+	}
+
 	badDefaultNameMock = map[string]pluginsdk.Set{
 		"foo": pluginsdk.Set{
 			Builders: map[string]packersdk.Builder{
@@ -422,3 +448,98 @@ func Test_only_one_multiplugin_defaultName_each_plugin_type(t *testing.T) {
 		t.Fatal("Should not have error because pluginsdk.DEFAULT_NAME is used twice but only once per plugin type.")
 	}
 }
+
+func Test_multiplugin_commands(t *testing.T) {
+	createMockPlugins(t, map[string]pluginsdk.Set{"cloud": {}})
+	pluginDir := os.Getenv("PACKER_PLUGIN_PATH")
+	defer os.RemoveAll(pluginDir)
+
+	c := PluginConfig{}
+	if err := c.Discover(); err != nil {
+		t.Fatalf("error discovering plugins; %s", err.Error())
+	}
+
+	expected := map[string]PluginCommand{
+		"cloud cleanup": {
+			Plugin:   "cloud",
+			Name:     "cleanup",
+			Synopsis: "delete unused images",
+			Path:     filepath.Join(pluginDir, "packer-plugin-cloud"),
+		},
+	}
+	if diff := cmp.Diff(expected, c.Commands); diff != "" {
+		t.Fatalf("unexpected commands: %s", diff)
+	}
+	if !c.Builders.Has("cloud") {
+		t.Fatalf("expected the components of the plugin to be discovered")
+	}
+}
+
+func Test_multiplugin_conflicts(t *testing.T) {
+	createMockPlugins(t, map[string]pluginsdk.Set{"cloud": {}})
+	staleDir := os.Getenv("PACKER_PLUGIN_PATH")
+	defer os.RemoveAll(staleDir)
+	createMockPlugins(t, map[string]pluginsdk.Set{"cloud": {}, "bird": {}})
+	pluginDir := os.Getenv("PACKER_PLUGIN_PATH")
+	defer os.RemoveAll(pluginDir)
+	os.Setenv("PACKER_PLUGIN_PATH", staleDir+string(os.PathListSeparator)+pluginDir)
+
+	c := PluginConfig{}
+	if err := c.Discover(); err != nil {
+		t.Fatalf("error discovering plugins; %s", err.Error())
+	}
+	diags := c.ConflictDiagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected a conflict for the cloud plugin, got %v", diags)
+	}
+	used := filepath.Join(pluginDir, "packer-plugin-cloud")
+	if diags[0].Severity != hcl.DiagWarning ||
+		!strings.Contains(diags[0].Summary, `"cloud"`) ||
+		!strings.Contains(diags[0].Detail, "Packer uses the last one, "+used+".") {
+		t.Fatalf("unexpected diagnostic: %s: %s", diags[0].Summary, diags[0].Detail)
+	}
+
+	// the plugins of required_plugins take precedence
+	required := filepath.Join(staleDir, "packer-plugin-cloud")
+	if err := c.DiscoverMultiPlugin("cloud", required); err != nil {
+		t.Fatalf("error discovering plugin; %s", err.Error())
+	}
+	diags = c.ConflictDiagnostics()
+	if len(diags) != 1 || !strings.Contains(diags[0].Detail, "Packer uses the last one, "+required+".") {
+		t.Fatalf("expected the required plugin to be used, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, required+", from required_plugins") {
+		t.Fatalf("expected the origin of the required plugin, got %s", diags[0].Detail)
+	}
+}
+
+func Test_singleplugin_multiplugin_conflicts(t *testing.T) {
+	createMockPlugins(t, map[string]pluginsdk.Set{"bird": {}})
+	pluginDir := os.Getenv("PACKER_PLUGIN_PATH")
+	defer os.RemoveAll(pluginDir)
+	singleDir, err := tmp.Dir("pkr-singleplugin-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(singleDir)
+	single := filepath.Join(singleDir, "packer-builder-bird-feather")
+	if err := ioutil.WriteFile(single, []byte("#!/bin/sh\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PACKER_PLUGIN_PATH", singleDir+string(os.PathListSeparator)+pluginDir)
+
+	c := PluginConfig{}
+	if err := c.Discover(); err != nil {
+		t.Fatalf("error discovering plugins; %s", err.Error())
+	}
+	diags := c.ConflictDiagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected a conflict for the bird-feather builder, got %v", diags)
+	}
+	used := filepath.Join(pluginDir, "packer-plugin-bird")
+	if !strings.Contains(diags[0].Summary, `builder "bird-feather"`) ||
+		!strings.Contains(diags[0].Detail, single+", from PACKER_PLUGIN_PATH") ||
+		!strings.Contains(diags[0].Detail, "Packer uses the last one, "+used+".") {
+		t.Fatalf("unexpected diagnostic: %s: %s", diags[0].Summary, diags[0].Detail)
+	}
+}
@@ -0,0 +1,60 @@
+package packer
+
+import (
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// PluginRPCMetricsEnvVar is the environment variable that, when set, makes
+// packer log the latency and payload size of each call it makes to a plugin.
+const PluginRPCMetricsEnvVar = "PACKER_PLUGIN_RPC_METRICS"
+
+func pluginRPCMetricsEnabled() bool {
+	v := os.Getenv(PluginRPCMetricsEnvVar)
+	return v != "" && v != "0"
+}
+
+// meteredConn counts the bytes going through a plugin connection.
+type meteredConn struct {
+	net.Conn
+	client *PluginClient
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.client.bytesReceived, int64(n))
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.client.bytesSent, int64(n))
+	return n, err
+}
+
+// measure starts measuring the call to the plugin method and returns the
+// function logging the measure, to be deferred:
+//  defer c.client.measure("Builder.Prepare")()
+// Calls made concurrently to the same plugin are measured together, so the
+// sizes of a call also include what the other calls exchanged meanwhile.
+func (c *PluginClient) measure(method string) func() {
+	if c == nil || !c.metered {
+		return func() {}
+	}
+	start := time.Now()
+	sent := atomic.LoadInt64(&c.bytesSent)
+	received := atomic.LoadInt64(&c.bytesReceived)
+	return func() {
+		sent = atomic.LoadInt64(&c.bytesSent) - sent
+		received = atomic.LoadInt64(&c.bytesReceived) - received
+		log.Printf("[DEBUG] plugin rpc: %s %s took %s, sent %s, received %s",
+			filepath.Base(c.config.Cmd.Path), method, time.Since(start).Round(time.Millisecond),
+			datasize.ByteSize(sent).HumanReadable(), datasize.ByteSize(received).HumanReadable())
+	}
+}
@@ -0,0 +1,60 @@
+package packer
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPluginClient_measure(t *testing.T) {
+	client := &PluginClient{
+		config:  &PluginClientConfig{Cmd: exec.Command("/bin/packer-plugin-test")},
+		metered: true,
+	}
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	conn := &meteredConn{Conn: local, client: client}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	done := client.measure("Builder.Prepare")
+	go func() {
+		buf := make([]byte, 2048)
+		n, _ := remote.Read(buf)
+		remote.Write(buf[:n/2])
+	}()
+	if _, err := conn.Write(make([]byte, 2048)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 2048)); err != nil {
+		t.Fatal(err)
+	}
+	done()
+
+	out := logs.String()
+	for _, want := range []string{"plugin rpc: packer-plugin-test Builder.Prepare took", "sent 2.0 KB", "received 1024 B"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %q should contain %q", out, want)
+		}
+	}
+}
+
+func TestPluginClient_measure_disabled(t *testing.T) {
+	client := &PluginClient{config: &PluginClientConfig{Cmd: exec.Command("/bin/packer-plugin-test")}}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client.measure("Builder.Prepare")()
+	if logs.Len() != 0 {
+		t.Fatalf("nothing should be logged, got %q", logs.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	pluginsdk "github.com/hashicorp/packer-plugin-sdk/plugin"
+	"github.com/hashicorp/packer/builder/file"
 )
 
 func helperProcess(s ...string) *exec.Cmd {
@@ -67,6 +68,18 @@ func TestHelperProcess(*testing.T) {
 			os.Exit(1)
 		}
 		server.Serve()
+	case "file-builder":
+		server, err := pluginsdk.Server()
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			os.Exit(1)
+		}
+		err = server.RegisterBuilder(new(file.Builder))
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			os.Exit(1)
+		}
+		server.Serve()
 	case "hook":
 		server, err := pluginsdk.Server()
 		if err != nil {
@@ -17,6 +17,9 @@ type HookedProvisioner struct {
 	Provisioner packersdk.Provisioner
 	Config      interface{}
 	TypeName    string
+	// Parallel is the group of provisioners this one runs at the same time
+	// as, or nil.
+	Parallel *ParallelGroup
 }
 
 // A Hook implementation that runs the given provisioners.
@@ -24,6 +27,19 @@ type ProvisionHook struct {
 	// The provisioners to run as part of the hook. These should already
 	// be prepared (by calling Prepare) at some earlier stage.
 	Provisioners []*HookedProvisioner
+
+	// metadata collects the artifact metadata set by the provisioners.
+	metadata *artifactMetadata
+
+	// checkpoint records the provisioners that completed, for a resumed
+	// build to skip them. nil when the build is not resumable.
+	checkpoint *BuildCheckpoint
+
+	// events is the UI of the build the events of the provisioners are
+	// output to, when it outputs events. The UI given to Run by builders
+	// running as plugins comes through the plugin RPC, which carries no
+	// events.
+	events packersdk.Ui
 }
 
 // BuilderDataCommonKeys is the list of common keys that all builder will
@@ -115,21 +131,114 @@ func (h *ProvisionHook) Run(ctx context.Context, name string, ui packersdk.Ui, c
 				"`communicator` config was set to \"none\". If you have any provisioners\n" +
 				"then a communicator is required. Please fix this to continue.")
 	}
-	for _, p := range h.Provisioners {
+	if h.metadata != nil {
+		ui = &metadataUi{Ui: ui, metadata: h.metadata}
+	}
+	for i := 0; i < len(h.Provisioners); i++ {
+		p := h.Provisioners[i]
+		if p.Parallel != nil {
+			// the provisioners of a group follow each other
+			first := i
+			group := []*HookedProvisioner{p}
+			for i+1 < len(h.Provisioners) && h.Provisioners[i+1].Parallel == p.Parallel {
+				i++
+				group = append(group, h.Provisioners[i])
+			}
+			if h.completed(ui, first, i) {
+				continue
+			}
+			if err := h.runParallel(ctx, p.Parallel, group, ui, comm, data); err != nil {
+				return err
+			}
+			h.complete(first, i)
+			continue
+		}
+		if h.completed(ui, i, i) {
+			continue
+		}
+
 		ts := CheckpointReporter.AddSpan(p.TypeName, "provisioner", p.Config)
 
 		cast := CastDataToMap(data)
-		err := p.Provisioner.Provision(ctx, ui, comm, cast)
+		err := p.Provisioner.Provision(ctx, h.provisionerUi(ui, p), comm, cast)
 
 		ts.End(err)
 		if err != nil {
 			return err
 		}
+		h.complete(i, i)
 	}
 
 	return nil
 }
 
+// provisionerUi outputs the start of p and returns the UI p outputs its
+// messages to: as events tagged with its type when the build outputs events,
+// to ui otherwise.
+func (h *ProvisionHook) provisionerUi(ui packersdk.Ui, p *HookedProvisioner) packersdk.Ui {
+	if h.events == nil || !EmitEvent(h.events, UiEvent{Type: EventProvisioner, Provisioner: p.TypeName}) {
+		return ui
+	}
+	return &provisionerUi{Ui: ui, events: h.events, provisioner: p.TypeName}
+}
+
+// provisionerUi is the UI of a provisioner outputting its messages as events
+// of the build tagged with its type. Everything else, like the
+// machine-readable messages setting artifact metadata, goes to Ui.
+type provisionerUi struct {
+	packersdk.Ui
+	events      packersdk.Ui
+	provisioner string
+}
+
+func (u *provisionerUi) Say(message string) {
+	u.Event(UiEvent{Type: "say", Message: message})
+}
+
+func (u *provisionerUi) Message(message string) {
+	u.Event(UiEvent{Type: "message", Message: message})
+}
+
+func (u *provisionerUi) Error(message string) {
+	u.Event(UiEvent{Type: "error", Message: message})
+}
+
+func (u *provisionerUi) Event(event UiEvent) bool {
+	if event.Provisioner == "" {
+		event.Provisioner = u.provisioner
+	}
+	return EmitEvent(u.events, event)
+}
+
+// completed tells whether the provisioners from first to last completed in
+// the interrupted run of the build being resumed.
+func (h *ProvisionHook) completed(ui packersdk.Ui, first, last int) bool {
+	if h.checkpoint == nil {
+		return false
+	}
+	for i := first; i <= last; i++ {
+		if !h.checkpoint.completedProvisioner(i) {
+			return false
+		}
+	}
+	for i := first; i <= last; i++ {
+		ui.Say(fmt.Sprintf("Skipping provisioner %s, completed by the interrupted build", h.Provisioners[i].TypeName))
+	}
+	return true
+}
+
+// complete records that the provisioners from first to last completed.
+func (h *ProvisionHook) complete(first, last int) {
+	if h.checkpoint == nil {
+		return
+	}
+	for i := first; i <= last; i++ {
+		if err := h.checkpoint.completeProvisioner(i); err != nil {
+			log.Printf("[WARN] Failed to save the state of provisioner %s, it won't be resumed: %s", h.Provisioners[i].TypeName, err)
+		}
+	}
+}
+
 // PausedProvisioner is a Provisioner implementation that pauses before
 // the provisioner is actually run.
 type PausedProvisioner struct {
@@ -137,7 +246,7 @@ type PausedProvisioner struct {
 	Provisioner packersdk.Provisioner
 }
 
-func (p *PausedProvisioner) ConfigSpec() hcldec.ObjectSpec { return p.ConfigSpec() }
+func (p *PausedProvisioner) ConfigSpec() hcldec.ObjectSpec { return p.Provisioner.ConfigSpec() }
 func (p *PausedProvisioner) FlatConfig() interface{}       { return p.FlatConfig() }
 func (p *PausedProvisioner) Prepare(raws ...interface{}) error {
 	return p.Provisioner.Prepare(raws...)
@@ -163,7 +272,7 @@ type RetriedProvisioner struct {
 	Provisioner packersdk.Provisioner
 }
 
-func (r *RetriedProvisioner) ConfigSpec() hcldec.ObjectSpec { return r.ConfigSpec() }
+func (r *RetriedProvisioner) ConfigSpec() hcldec.ObjectSpec { return r.Provisioner.ConfigSpec() }
 func (r *RetriedProvisioner) FlatConfig() interface{}       { return r.FlatConfig() }
 func (r *RetriedProvisioner) Prepare(raws ...interface{}) error {
 	return r.Provisioner.Prepare(raws...)
@@ -208,7 +317,7 @@ type DebuggedProvisioner struct {
 	lock     sync.Mutex
 }
 
-func (p *DebuggedProvisioner) ConfigSpec() hcldec.ObjectSpec { return p.ConfigSpec() }
+func (p *DebuggedProvisioner) ConfigSpec() hcldec.ObjectSpec { return p.Provisioner.ConfigSpec() }
 func (p *DebuggedProvisioner) FlatConfig() interface{}       { return p.FlatConfig() }
 func (p *DebuggedProvisioner) Prepare(raws ...interface{}) error {
 	return p.Provisioner.Prepare(raws...)
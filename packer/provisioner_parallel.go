@@ -0,0 +1,143 @@
+package packer
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ParallelGroup is a group of provisioners of a build that run at the same
+// time, against the same guest. Provisioners of the same group share a pointer
+// to it.
+type ParallelGroup struct {
+	// MaxSessions is the maximum number of communicator sessions the
+	// provisioners of the group use at the same time, like running commands
+	// or uploading files. Zero means no limit.
+	MaxSessions int
+}
+
+// runParallel runs the provisioners of a parallel group at the same time, and
+// waits for all of them to finish. The first failing provisioner cancels the
+// others, and its error is returned.
+func (h *ProvisionHook) runParallel(ctx context.Context, group *ParallelGroup, provisioners []*HookedProvisioner, ui packersdk.Ui, comm packersdk.Communicator, data interface{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	comm = newSessionPool(ctx, comm, group.MaxSessions)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for _, p := range provisioners {
+		wg.Add(1)
+		go func(p *HookedProvisioner) {
+			defer wg.Done()
+			ts := CheckpointReporter.AddSpan(p.TypeName, "provisioner", p.Config)
+			err := p.Provisioner.Provision(ctx, h.provisionerUi(ui, p), comm, CastDataToMap(data))
+			ts.End(err)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(p)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// sessionPool is a communicator sharing the sessions of a communicator between
+// provisioners running at the same time, so that they do not open more
+// sessions than the guest allows.
+type sessionPool struct {
+	packersdk.Communicator
+
+	ctx      context.Context
+	sessions chan struct{}
+}
+
+// newSessionPool returns comm limited to max sessions at the same time. When
+// max is zero comm is returned as is.
+func newSessionPool(ctx context.Context, comm packersdk.Communicator, max int) packersdk.Communicator {
+	if max <= 0 {
+		return comm
+	}
+	return &sessionPool{
+		Communicator: comm,
+		ctx:          ctx,
+		sessions:     make(chan struct{}, max),
+	}
+}
+
+func (p *sessionPool) acquire() error {
+	select {
+	case p.sessions <- struct{}{}:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+func (p *sessionPool) release() {
+	<-p.sessions
+}
+
+// Start holds a session until the remote command exits.
+func (p *sessionPool) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	if err := p.Communicator.Start(ctx, cmd); err != nil {
+		p.release()
+		return err
+	}
+	go func() {
+		exited := make(chan struct{})
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+		select {
+		case <-exited:
+		case <-p.ctx.Done():
+		}
+		p.release()
+	}()
+	return nil
+}
+
+func (p *sessionPool) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer p.release()
+	return p.Communicator.Upload(dst, r, fi)
+}
+
+func (p *sessionPool) UploadDir(dst string, src string, exclude []string) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer p.release()
+	return p.Communicator.UploadDir(dst, src, exclude)
+}
+
+func (p *sessionPool) Download(src string, w io.Writer) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer p.release()
+	return p.Communicator.Download(src, w)
+}
+
+func (p *sessionPool) DownloadDir(src string, dst string, exclude []string) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer p.release()
+	return p.Communicator.DownloadDir(src, dst, exclude)
+}
@@ -0,0 +1,131 @@
+package packer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisionHook_parallel(t *testing.T) {
+	// both provisioners of the group must run at the same time to return
+	var started sync.WaitGroup
+	started.Add(2)
+	both := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("the provisioners of the group did not run at the same time")
+		}
+	}
+	pA := &packersdk.MockProvisioner{ProvFunc: both}
+	pB := &packersdk.MockProvisioner{ProvFunc: both}
+	pC := &packersdk.MockProvisioner{ProvFunc: func(context.Context) error {
+		if !pA.ProvCalled || !pB.ProvCalled {
+			return errors.New("the group should run before the next provisioner")
+		}
+		return nil
+	}}
+
+	group := &ParallelGroup{}
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, "", group},
+			{pB, nil, "", group},
+			{pC, nil, "", nil},
+		},
+	}
+
+	if err := hook.Run(context.Background(), "foo", testUi(), new(packersdk.MockCommunicator), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !pC.ProvCalled {
+		t.Error("provision should be called on pC")
+	}
+}
+
+func TestProvisionHook_parallelError(t *testing.T) {
+	pA := &packersdk.MockProvisioner{ProvFunc: func(context.Context) error {
+		return errors.New("upload failed")
+	}}
+	pB := &packersdk.MockProvisioner{ProvFunc: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	group := &ParallelGroup{}
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, "", group},
+			{pB, nil, "", group},
+		},
+	}
+
+	err := hook.Run(context.Background(), "foo", testUi(), new(packersdk.MockCommunicator), nil)
+	if err == nil || err.Error() != "upload failed" {
+		t.Fatalf("expected the error of the failing provisioner, got %v", err)
+	}
+}
+
+// countingCommunicator records the highest number of uploads running at the
+// same time.
+type countingCommunicator struct {
+	packersdk.MockCommunicator
+
+	l       sync.Mutex
+	running int
+	max     int
+}
+
+func (c *countingCommunicator) Upload(string, io.Reader, *os.FileInfo) error {
+	c.l.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.l.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.l.Lock()
+	c.running--
+	c.l.Unlock()
+	return nil
+}
+
+func TestSessionPool(t *testing.T) {
+	comm := &countingCommunicator{}
+	pool := newSessionPool(context.Background(), comm, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.Upload("/tmp/file", strings.NewReader("content"), nil); err != nil {
+				t.Errorf("Upload: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if comm.max != 2 {
+		t.Errorf("expected 2 uploads at the same time at most, got %d", comm.max)
+	}
+	if newSessionPool(context.Background(), comm, 0) != packersdk.Communicator(comm) {
+		t.Error("a pool without limit should be the communicator itself")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -28,8 +30,8 @@ func TestProvisionHook(t *testing.T) {
 
 	hook := &ProvisionHook{
 		Provisioners: []*HookedProvisioner{
-			{pA, nil, ""},
-			{pB, nil, ""},
+			{pA, nil, "", nil},
+			{pB, nil, "", nil},
 		},
 	}
 
@@ -44,6 +46,43 @@ func TestProvisionHook(t *testing.T) {
 	}
 }
 
+func TestProvisionHook_resumed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cp, err := OpenBuildCheckpoint(dir, "foo", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.completeProvisioner(0); err != nil {
+		t.Fatal(err)
+	}
+
+	pA := &packersdk.MockProvisioner{}
+	pB := &packersdk.MockProvisioner{}
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, "shell", nil},
+			{pB, nil, "file", nil},
+		},
+		checkpoint: cp,
+	}
+	if err := hook.Run(context.Background(), "foo", testUi(), new(packersdk.MockCommunicator), nil); err != nil {
+		t.Fatal(err)
+	}
+	if pA.ProvCalled {
+		t.Error("the completed provisioner must be skipped")
+	}
+	if !pB.ProvCalled {
+		t.Error("provision should be called on pB")
+	}
+	if !cp.completedProvisioner(1) {
+		t.Error("pB must be recorded as completed")
+	}
+}
+
 func TestProvisionHook_nilComm(t *testing.T) {
 	pA := &packersdk.MockProvisioner{}
 	pB := &packersdk.MockProvisioner{}
@@ -54,8 +93,8 @@ func TestProvisionHook_nilComm(t *testing.T) {
 
 	hook := &ProvisionHook{
 		Provisioners: []*HookedProvisioner{
-			{pA, nil, ""},
-			{pB, nil, ""},
+			{pA, nil, "", nil},
+			{pB, nil, "", nil},
 		},
 	}
 
@@ -78,7 +117,7 @@ func TestProvisionHook_cancel(t *testing.T) {
 
 	hook := &ProvisionHook{
 		Provisioners: []*HookedProvisioner{
-			{p, nil, ""},
+			{p, nil, "", nil},
 		},
 	}
 
@@ -94,6 +133,19 @@ func TestPausedProvisioner_impl(t *testing.T) {
 	var _ packersdk.Provisioner = new(PausedProvisioner)
 }
 
+func TestWrappedProvisionerConfigSpec(t *testing.T) {
+	mock := new(packersdk.MockProvisioner)
+	for _, p := range []packersdk.Provisioner{
+		&PausedProvisioner{Provisioner: mock},
+		&RetriedProvisioner{Provisioner: mock},
+		&DebuggedProvisioner{Provisioner: mock},
+	} {
+		if p.ConfigSpec() == nil {
+			t.Errorf("%T should return the spec of the provisioner it wraps", p)
+		}
+	}
+}
+
 func TestPausedProvisionerPrepare(t *testing.T) {
 	mock := new(packersdk.MockProvisioner)
 	prov := &PausedProvisioner{
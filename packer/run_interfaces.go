@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"context"
 	"github.com/hashicorp/hcl/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	plugingetter "github.com/hashicorp/packer/packer/plugin-getter"
@@ -12,6 +13,20 @@ type GetBuildsOptions struct {
 	Except, Only []string
 	Debug, Force bool
 	OnError      string
+	// When set, builders that support it run their provisioners but do not
+	// create their artifact.
+	SkipCreateArtifact bool
+	// When set, the builders able to resume builds are given the file the
+	// state of their build is saved in, in this folder.
+	ResumeStateDir string
+	// When set, check that the local files referenced by well-known settings
+	// of builders and provisioners exist.
+	CheckLocalPaths bool
+	// When set, the configurations of builders, provisioners and
+	// post-processors are only checked against their schema: they are not
+	// prepared, so no credentials or network are needed. The builds cannot
+	// run.
+	SchemaOnly bool
 }
 
 type BuildGetter interface {
@@ -21,6 +36,14 @@ type BuildGetter interface {
 	GetBuilds(GetBuildsOptions) ([]packersdk.Build, hcl.Diagnostics)
 }
 
+// CombinedPostProcessorsGetter is implemented by the configs that have
+// post-processors running once on the artifacts of all the builds of a run.
+type CombinedPostProcessorsGetter interface {
+	// GetCombinedPostProcessors starts the post-processors receiving the
+	// combined artifact of the builds. It is called after GetBuilds.
+	GetCombinedPostProcessors(GetBuildsOptions) ([][]CoreBuildPostProcessor, hcl.Diagnostics)
+}
+
 type Evaluator interface {
 	// EvaluateExpression is meant to be used in the `packer console` command.
 	// It parses the input string and returns what needs to be displayed. In
@@ -28,10 +51,45 @@ type Evaluator interface {
 	EvaluateExpression(expr string) (output string, exit bool, diags hcl.Diagnostics)
 }
 
+// ConsoleCompleter is an optional interface of Evaluators, for `packer
+// console` to complete what is typed in with the tab key.
+type ConsoleCompleter interface {
+	// CompletionNames returns the names an expression can refer to, sorted:
+	// functions, suffixed with "(", variables, locals and data sources.
+	CompletionNames() []string
+}
+
+// BuildTypeLimiter is an optional interface of Handlers whose config limits
+// the builds running at the same time by builder type.
+type BuildTypeLimiter interface {
+	// ParallelBuildsByType returns the most builds of a builder type, or of
+	// the builder types of a plugin like "vmware", running at the same time,
+	// by type. 0 means no limit.
+	ParallelBuildsByType() map[string]int64
+}
+
+// LocalFilesReferencer is an optional interface of Handlers able to tell
+// which local files their config depends on.
+type LocalFilesReferencer interface {
+	// LocalFiles returns the local files and folders the config reads or
+	// references, like provisioning scripts or an http_directory. Paths can
+	// be glob patterns.
+	LocalFiles() []string
+}
+
 type InitializeOptions struct {
 	// When set, the execution of datasources will be skipped and the datasource will provide
 	// a output spec that will be used for validation only.
 	SkipDatasourcesExecution bool
+
+	// When set, the values of sensitive variables are not registered to be
+	// scrubbed from the output and the logs.
+	ShowSensitive bool
+
+	// Context cancels the execution of the data sources, like waiting to
+	// retry one, when packer is interrupted. nil means it is never
+	// cancelled.
+	Context context.Context
 }
 
 // The Handler handles all Packer things. This interface reflects the Packer
@@ -73,6 +131,18 @@ type ConfigFixer interface {
 
 type InspectConfigOptions struct {
 	packersdk.Ui
+
+	// ShowSensitive displays the values of sensitive variables instead of
+	// masking them.
+	ShowSensitive bool
+
+	// JSON writes the InspectOutput of the config as JSON instead of text.
+	JSON bool
+
+	// Resolve prints the values of the variables once set, with where they
+	// were set from and the values they override, instead of the components
+	// of the config.
+	Resolve bool
 }
 
 type ConfigInspector interface {
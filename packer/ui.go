@@ -8,7 +8,9 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 	"unicode"
@@ -30,12 +32,42 @@ const (
 	UiColorCyan            = 36
 )
 
+// DebugPausePrompt is contained in the prompts of the pauses of debug mode.
+const DebugPausePrompt = "Press enter to continue."
+
+// AnsweringUi answers the prompts containing one of the keys of Answers
+// without asking, so that they can be pre-answered in non-interactive
+// environments. Other prompts are passed to the wrapped Ui.
+type AnsweringUi struct {
+	packersdk.Ui
+	Answers map[string]string
+}
+
+var _ EventUi = new(AnsweringUi)
+
+func (u *AnsweringUi) Ask(query string) (string, error) {
+	for prompt, answer := range u.Answers {
+		if strings.Contains(query, prompt) {
+			log.Printf("ui: answering %q with %q", query, answer)
+			return answer, nil
+		}
+	}
+	return u.Ui.Ask(query)
+}
+
+func (u *AnsweringUi) Event(event UiEvent) bool {
+	return EmitEvent(u.Ui, event)
+}
+
 // ColoredUi is a UI that is colored using terminal colors.
 type ColoredUi struct {
 	Color      UiColor
 	ErrorColor UiColor
 	Ui         packersdk.Ui
 	PB         getter.ProgressTracker
+	// Force colors the output even when the terminal does not seem to
+	// support colors. PACKER_NO_COLOR still disables them.
+	Force bool
 }
 
 var _ packersdk.Ui = new(ColoredUi)
@@ -90,7 +122,7 @@ func (u *ColoredUi) supportsColors() bool {
 	}
 
 	// For now, on non-Windows machine, just assume it does
-	if runtime.GOOS != "windows" {
+	if u.Force || runtime.GOOS != "windows" {
 		return true
 	}
 
@@ -106,28 +138,44 @@ func (u *ColoredUi) supportsColors() bool {
 // the output to indicate a specific target. Specifically, all Say output
 // is prefixed with the target name. Message output is not prefixed but
 // is offset by the length of the target so that output is lined up properly
-// with Say output. Machine-readable output has the proper target set.
+// with Say output. Machine-readable output has the proper target set. When
+// the wrapped UI outputs events, messages are output as events of the target
+// instead.
 type TargetedUI struct {
 	Target string
 	Ui     packersdk.Ui
 }
 
-var _ packersdk.Ui = new(TargetedUI)
+var _ EventUi = new(TargetedUI)
 
 func (u *TargetedUI) Ask(query string) (string, error) {
 	return u.Ui.Ask(u.prefixLines(true, query))
 }
 
 func (u *TargetedUI) Say(message string) {
-	u.Ui.Say(u.prefixLines(true, message))
+	if !u.Event(UiEvent{Type: "say", Message: message}) {
+		u.Ui.Say(u.prefixLines(true, message))
+	}
 }
 
 func (u *TargetedUI) Message(message string) {
-	u.Ui.Message(u.prefixLines(false, message))
+	if !u.Event(UiEvent{Type: "message", Message: message}) {
+		u.Ui.Message(u.prefixLines(false, message))
+	}
 }
 
 func (u *TargetedUI) Error(message string) {
-	u.Ui.Error(u.prefixLines(true, message))
+	if !u.Event(UiEvent{Type: "error", Message: message}) {
+		u.Ui.Error(u.prefixLines(true, message))
+	}
+}
+
+// Event outputs event as an event of the target, unless it already has one.
+func (u *TargetedUI) Event(event UiEvent) bool {
+	if event.Target == "" {
+		event.Target = u.Target
+	}
+	return EmitEvent(u.Ui, event)
 }
 
 func (u *TargetedUI) Machine(t string, args ...string) {
@@ -150,10 +198,251 @@ func (u *TargetedUI) prefixLines(arrow bool, message string) string {
 	return strings.TrimRightFunc(result.String(), unicode.IsSpace)
 }
 
+// AlignedUi pads the target of the messages of a TargetedUI to Width, so that
+// the messages of targets with names of different lengths line up. The
+// targets are the build Target and its post-processors, named like
+// "Target (type)".
+type AlignedUi struct {
+	packersdk.Ui
+	Target string
+	Width  int
+}
+
+var _ packersdk.Ui = new(AlignedUi)
+
+func (u *AlignedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(u.align(query))
+}
+
+func (u *AlignedUi) Say(message string) {
+	u.Ui.Say(u.align(message))
+}
+
+func (u *AlignedUi) Message(message string) {
+	u.Ui.Message(u.align(message))
+}
+
+func (u *AlignedUi) Error(message string) {
+	u.Ui.Error(u.align(message))
+}
+
+func (u *AlignedUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return u.Ui.TrackProgress(u.align(src), currentSize, totalSize, stream)
+}
+
+func (u *AlignedUi) align(message string) string {
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		for _, arrow := range []string{"==> ", "    "} {
+			if !strings.HasPrefix(line, arrow+u.Target) {
+				continue
+			}
+			target := u.targetOf(line[len(arrow):])
+			if target == "" {
+				continue
+			}
+			if n := len(arrow) + len(target); u.Width > len(target) {
+				lines[i] = line[:n] + strings.Repeat(" ", u.Width-len(target)) + line[n:]
+			}
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// targetOf returns the target line is prefixed with, without its arrow, when
+// it is the build or one of its post-processors, or "".
+func (u *AlignedUi) targetOf(line string) string {
+	i := strings.Index(line, ": ")
+	if i < 0 && strings.HasSuffix(line, ":") {
+		// the trailing space of an empty last line is trimmed
+		i = len(line) - 1
+	}
+	if i < 0 {
+		return ""
+	}
+	target := line[:i]
+	rest := strings.TrimPrefix(target, u.Target)
+	if rest == "" || (strings.HasPrefix(rest, " (") && strings.HasSuffix(rest, ")")) {
+		return target
+	}
+	return ""
+}
+
+// LineWriter writes to Writer a whole line at a time: what is written is held
+// until it completes a line, so that the partial lines written at the same
+// time by the builds to the same output do not interleave. The LineWriters
+// of an output share Lock.
+type LineWriter struct {
+	Writer io.Writer
+	Lock   *sync.Mutex
+
+	partial []byte
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	i := bytes.LastIndexByte(p, '\n')
+	if i < 0 {
+		w.partial = append(w.partial, p...)
+		return len(p), nil
+	}
+	lines := append(w.partial, p[:i+1]...)
+	w.partial = append([]byte(nil), p[i+1:]...)
+
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	if _, err := w.Writer.Write(lines); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the partial line held, if any.
+func (w *LineWriter) Flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	_, err := w.Writer.Write(w.partial)
+	w.partial = nil
+	return err
+}
+
 func (u *TargetedUI) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
 	return u.Ui.TrackProgress(u.prefixLines(false, src), currentSize, totalSize, stream)
 }
 
+// MachineHeartbeat is the type of the machine-readable messages telling that
+// a build is still running a step that has been silent for a while:
+//
+//	<build>,heartbeat,<step>,<seconds since the step started>
+//
+// The step is the last message the build said, like "Waiting for AMI to
+// become ready...".
+const MachineHeartbeat = "heartbeat"
+
+// HeartbeatUi is a UI that wraps the UI of a build, and outputs a heartbeat
+// machine-readable message when the build output nothing for Interval, so
+// that CI systems killing silent jobs do not kill builds during long steps.
+// Heartbeats are output between Start and Stop.
+type HeartbeatUi struct {
+	packersdk.Ui
+	// Target is the name of the build.
+	Target   string
+	Interval time.Duration
+
+	l sync.Mutex
+	// output is the time of the last output of the build, or heartbeat.
+	output    time.Time
+	step      string
+	stepStart time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Start starts outputting heartbeats.
+func (u *HeartbeatUi) Start() {
+	u.l.Lock()
+	u.output, u.stepStart = time.Now(), time.Now()
+	u.stop, u.done = make(chan struct{}), make(chan struct{})
+	u.l.Unlock()
+
+	go func() {
+		defer close(u.done)
+		timer := time.NewTimer(u.Interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-timer.C:
+			}
+			u.l.Lock()
+			idle := time.Since(u.output)
+			beat := idle >= u.Interval
+			step, elapsed := u.step, time.Since(u.stepStart)
+			if beat {
+				u.output, idle = time.Now(), 0
+			}
+			u.l.Unlock()
+			if beat {
+				u.Ui.Machine(fmt.Sprintf("%s,%s", u.Target, MachineHeartbeat),
+					step, strconv.Itoa(int(elapsed.Seconds())))
+			}
+			timer.Reset(u.Interval - idle)
+		}
+	}()
+}
+
+// Stop stops outputting heartbeats.
+func (u *HeartbeatUi) Stop() {
+	close(u.stop)
+	<-u.done
+}
+
+func (u *HeartbeatUi) Ask(query string) (string, error) {
+	u.touch("")
+	return u.Ui.Ask(query)
+}
+
+func (u *HeartbeatUi) Say(message string) {
+	u.touch(message)
+	u.Ui.Say(message)
+}
+
+func (u *HeartbeatUi) Message(message string) {
+	u.touch("")
+	u.Ui.Message(message)
+}
+
+func (u *HeartbeatUi) Error(message string) {
+	u.touch("")
+	u.Ui.Error(message)
+}
+
+func (u *HeartbeatUi) Machine(t string, args ...string) {
+	u.touch("")
+	u.Ui.Machine(t, args...)
+}
+
+func (u *HeartbeatUi) Event(event UiEvent) bool {
+	switch event.Type {
+	case "say", EventStep:
+		u.touch(event.Message)
+	default:
+		u.touch("")
+	}
+	return EmitEvent(u.Ui, event)
+}
+
+// touch records an output of the build. A message said starts a new step.
+func (u *HeartbeatUi) touch(said string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	u.output = time.Now()
+	if said != "" {
+		u.step, u.stepStart = u.stepOf(said), u.output
+	}
+}
+
+// stepOf returns the first line of message without the prefix of the
+// TargetedUI of the build or of its post-processors, like
+// "==> amazon-ebs.example: ".
+func (u *HeartbeatUi) stepOf(message string) string {
+	line := strings.SplitN(message, "\n", 2)[0]
+	for _, arrow := range []string{"==> ", "    "} {
+		prefix := arrow + u.Target
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		if i := strings.Index(line[len(prefix):], ": "); i >= 0 {
+			return line[len(prefix)+i+2:]
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
 // MachineReadableUi is a UI that only outputs machine-readable output
 // to the given Writer.
 type MachineReadableUi struct {
@@ -161,7 +450,9 @@ type MachineReadableUi struct {
 	PB     packersdk.NoopProgressTracker
 }
 
-var _ packersdk.Ui = new(MachineReadableUi)
+var _ StructuredUi = new(MachineReadableUi)
+
+func (u *MachineReadableUi) StructuredOutput() {}
 
 func (u *MachineReadableUi) Ask(query string) (string, error) {
 	return "", errors.New("machine-readable UI can't ask")
@@ -193,8 +484,8 @@ func (u *MachineReadableUi) Machine(category string, args ...string) {
 	// Prepare the args
 	for i, v := range args {
 		// Use packersdk.LogSecretFilter to scrub out sensitive variables
-		args[i] = packersdk.LogSecretFilter.FilterString(args[i])
-		args[i] = strings.Replace(v, ",", "%!(PACKER_COMMA)", -1)
+		args[i] = packersdk.LogSecretFilter.FilterString(v)
+		args[i] = strings.Replace(args[i], ",", "%!(PACKER_COMMA)", -1)
 		args[i] = strings.Replace(args[i], "\r", "\\r", -1)
 		args[i] = strings.Replace(args[i], "\n", "\\n", -1)
 	}
@@ -245,6 +536,11 @@ func (u *TimestampedUi) Machine(message string, args ...string) {
 	u.Ui.Machine(message, args...)
 }
 
+// Event outputs event as is, events are timestamped already.
+func (u *TimestampedUi) Event(event UiEvent) bool {
+	return EmitEvent(u.Ui, event)
+}
+
 func (u *TimestampedUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
 	return u.Ui.TrackProgress(src, currentSize, totalSize, stream)
 }
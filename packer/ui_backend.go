@@ -0,0 +1,400 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// UiBackendOptions are what a UI backend outputs to and reads from.
+type UiBackendOptions struct {
+	Reader      io.Reader
+	Writer      io.Writer
+	ErrorWriter io.Writer
+
+	// TTY is the terminal to ask questions on, nil when there is none.
+	TTY packersdk.TTY
+
+	// PluginFolders are the folders the executables of UI plugins are looked
+	// up in, before the PATH.
+	PluginFolders []string
+}
+
+// UiBackend creates the UI packer outputs to.
+type UiBackend func(opts UiBackendOptions) (packersdk.Ui, error)
+
+// UiBackends are the built-in UI backends, by name, that can be selected with
+// the "ui" setting of the packer config file or PACKER_UI. Programs embedding
+// packer can add theirs.
+var UiBackends = map[string]UiBackend{
+	"plain":            newPlainUi,
+	"machine-readable": newMachineReadableUi,
+	"json":             newJSONUi,
+	"quiet":            newQuietUi,
+}
+
+// UiPluginPrefix prefixes the name of the executables of UI plugins: the
+// "teamcity" UI is run by a packer-ui-teamcity executable.
+const UiPluginPrefix = "packer-ui-"
+
+// NewUi creates the UI of the backend name, which is a built-in backend or a
+// UI plugin.
+func NewUi(name string, opts UiBackendOptions) (packersdk.Ui, error) {
+	if backend, ok := UiBackends[name]; ok {
+		return backend(opts)
+	}
+	path, err := lookupUiPlugin(name, opts.PluginFolders)
+	if err != nil {
+		var names []string
+		for name := range UiBackends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown UI %q: it is not one of %s and no %s%s plugin was found",
+			name, strings.Join(names, ", "), UiPluginPrefix, name)
+	}
+	return newPluginUi(path, opts)
+}
+
+// lookupUiPlugin returns the path to the executable of the UI plugin name.
+func lookupUiPlugin(name string, folders []string) (string, error) {
+	filename := UiPluginPrefix + name
+	for _, folder := range folders {
+		path := filepath.Join(folder, filename)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return exec.LookPath(filename)
+}
+
+// StructuredUi is implemented by the UIs outputting events for programs to
+// read, rather than text for people: packer does not color nor prefix their
+// messages, and they cannot ask questions.
+type StructuredUi interface {
+	packersdk.Ui
+	StructuredOutput()
+}
+
+// IsStructuredUi tells whether ui is a StructuredUi.
+func IsStructuredUi(ui packersdk.Ui) bool {
+	_, ok := ui.(StructuredUi)
+	return ok
+}
+
+func newPlainUi(opts UiBackendOptions) (packersdk.Ui, error) {
+	ui := &packersdk.BasicUi{
+		Reader:      opts.Reader,
+		Writer:      opts.Writer,
+		ErrorWriter: opts.ErrorWriter,
+		PB:          &packersdk.NoopProgressTracker{},
+	}
+	if opts.TTY != nil {
+		ui.TTY = opts.TTY
+		ui.PB = &UiProgressBar{}
+	} else {
+		// Without a terminal nothing is asked, so that the output, like the
+		// log of a CI job following builds running at the same time, is
+		// written a whole line at a time.
+		lock := new(sync.Mutex)
+		ui.Writer = &LineWriter{Writer: opts.Writer, Lock: lock}
+		if opts.ErrorWriter != nil {
+			ui.ErrorWriter = &LineWriter{Writer: opts.ErrorWriter, Lock: lock}
+		}
+	}
+	return ui, nil
+}
+
+func newMachineReadableUi(opts UiBackendOptions) (packersdk.Ui, error) {
+	return &MachineReadableUi{Writer: opts.Writer}, nil
+}
+
+func newJSONUi(opts UiBackendOptions) (packersdk.Ui, error) {
+	return &JSONUi{Writer: opts.Writer}, nil
+}
+
+func newQuietUi(opts UiBackendOptions) (packersdk.Ui, error) {
+	return &QuietUi{ErrorWriter: opts.ErrorWriter}, nil
+}
+
+// UiEvent is an event output by the json UI, as a line of JSON.
+type UiEvent struct {
+	Timestamp time.Time `json:"@timestamp"`
+	// Type is "say", "message" or "error" for the messages meant for people,
+	// EventStep, EventProvisioner or EventArtifact for what builds do, or the
+	// type of a machine-readable message.
+	Type string `json:"type"`
+	// Target is the build or the post-processor the event is about, if any.
+	Target string `json:"target,omitempty"`
+	// Provisioner is the type of the provisioner the event is about, or that
+	// output the message, if any.
+	Provisioner string   `json:"provisioner,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Data        []string `json:"data,omitempty"`
+	// Artifact is the artifact of an EventArtifact event.
+	Artifact *ArtifactEvent `json:"artifact,omitempty"`
+	// Summary is the JSON summary of the builds of an EventSummary event, or
+	// of the templates of an EventWorkspaceSummary event.
+	Summary json.RawMessage `json:"summary,omitempty"`
+}
+
+const (
+	// EventStep is the type of the events of a build starting a step, named
+	// by their Message. Builders report their steps with the
+	// events.HookStepStarted hook.
+	EventStep = "step"
+	// EventProvisioner is the type of the events of a build starting to run
+	// a provisioner.
+	EventProvisioner = "provisioner"
+	// EventArtifact is the type of the events of a build, or of the
+	// post-processors of all builds, producing an artifact.
+	EventArtifact = "artifact"
+	// EventSummary is the type of the event summarizing all builds once they
+	// are done.
+	EventSummary = "summary"
+	// EventWorkspaceSummary is the type of the event summarizing all
+	// templates of a workspace once they are done.
+	EventWorkspaceSummary = "workspace-summary"
+)
+
+// ArtifactEvent is the artifact of an EventArtifact event.
+type ArtifactEvent struct {
+	BuilderID string            `json:"builder_id"`
+	ID        string            `json:"id"`
+	String    string            `json:"string"`
+	Files     []string          `json:"files,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// NewArtifactEvent returns the EventArtifact event of target producing
+// artifact.
+func NewArtifactEvent(target string, artifact packersdk.Artifact) UiEvent {
+	return UiEvent{
+		Type:   EventArtifact,
+		Target: target,
+		Artifact: &ArtifactEvent{
+			BuilderID: artifact.BuilderId(),
+			ID:        artifact.Id(),
+			String:    artifact.String(),
+			Files:     artifact.Files(),
+			Metadata:  ArtifactMetadata(artifact),
+		},
+	}
+}
+
+// EventUi is implemented by the UIs outputting events, like the json UI, and
+// by the UIs wrapping other UIs, so that packer outputs what builds do as
+// events where it happens, rather than as messages for people. Event tells
+// whether the event was output: it is not when the wrapped UI does not
+// output events.
+type EventUi interface {
+	packersdk.Ui
+	Event(event UiEvent) bool
+}
+
+// EmitEvent outputs event to ui when it outputs events, and tells whether it
+// did.
+func EmitEvent(ui packersdk.Ui, event UiEvent) bool {
+	eu, ok := ui.(EventUi)
+	return ok && eu.Event(event)
+}
+
+// JSONUi is a UI that outputs every message as a UiEvent, one per line, to
+// Writer.
+type JSONUi struct {
+	Writer io.Writer
+	PB     packersdk.NoopProgressTracker
+
+	l sync.Mutex
+}
+
+var _ StructuredUi = new(JSONUi)
+var _ EventUi = new(JSONUi)
+
+func (u *JSONUi) StructuredOutput() {}
+
+func (u *JSONUi) Ask(query string) (string, error) {
+	return "", errors.New("json UI can't ask")
+}
+
+func (u *JSONUi) Say(message string) {
+	u.write(UiEvent{Type: "say", Message: message})
+}
+
+func (u *JSONUi) Message(message string) {
+	u.write(UiEvent{Type: "message", Message: message})
+}
+
+func (u *JSONUi) Error(message string) {
+	u.write(UiEvent{Type: "error", Message: message})
+}
+
+func (u *JSONUi) Event(event UiEvent) bool {
+	u.write(event)
+	return true
+}
+
+func (u *JSONUi) Machine(category string, args ...string) {
+	event := UiEvent{Type: category}
+	if i := strings.Index(category, ","); i > -1 {
+		event.Target, event.Type = category[:i], category[i+1:]
+	}
+	for _, arg := range args {
+		event.Data = append(event.Data, packersdk.LogSecretFilter.FilterString(arg))
+	}
+	u.write(event)
+}
+
+func (u *JSONUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
+	return u.PB.TrackProgress(src, currentSize, totalSize, stream)
+}
+
+func (u *JSONUi) write(event UiEvent) {
+	event.Timestamp = time.Now().UTC()
+	event.Message = packersdk.LogSecretFilter.FilterString(event.Message)
+	if a := event.Artifact; a != nil {
+		filtered := *a
+		filtered.ID = packersdk.LogSecretFilter.FilterString(a.ID)
+		filtered.String = packersdk.LogSecretFilter.FilterString(a.String)
+		event.Artifact = &filtered
+	}
+	var js bytes.Buffer
+	enc := json.NewEncoder(&js)
+	// keep messages like "==> " readable
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(event); err != nil {
+		log.Printf("[ERR] Failed to write UI event: %s", err)
+		return
+	}
+
+	u.l.Lock()
+	defer u.l.Unlock()
+	if _, err := u.Writer.Write(js.Bytes()); err != nil {
+		if err == syscall.EPIPE || strings.Contains(err.Error(), "broken pipe") {
+			// Like the machine-readable UI, ignore the output being
+			// closed.
+			return
+		}
+		panic(err)
+	}
+}
+
+// QuietUi is a UI that only outputs errors, to ErrorWriter.
+type QuietUi struct {
+	ErrorWriter io.Writer
+	PB          packersdk.NoopProgressTracker
+
+	l sync.Mutex
+}
+
+var _ packersdk.Ui = new(QuietUi)
+
+func (u *QuietUi) Ask(query string) (string, error) {
+	return "", errors.New("quiet UI can't ask")
+}
+
+func (u *QuietUi) Say(message string) {
+	log.Printf("ui: %s", message)
+}
+
+func (u *QuietUi) Message(message string) {
+	log.Printf("ui: %s", message)
+}
+
+func (u *QuietUi) Error(message string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	log.Printf("ui error: %s", message)
+	fmt.Fprintln(u.ErrorWriter, message)
+}
+
+func (u *QuietUi) Machine(t string, args ...string) {
+	log.Printf("machine readable: %s %#v", t, args)
+}
+
+func (u *QuietUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
+	return u.PB.TrackProgress(src, currentSize, totalSize, stream)
+}
+
+// PluginUi is the UI of a UI plugin: an executable reading the UiEvents of
+// the json UI on its standard input, and outputting them the way it likes,
+// for example as the service messages of a CI system.
+type PluginUi struct {
+	*JSONUi
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *pluginUiWriter
+}
+
+// pluginUiWriter writes the events to the input of a UI plugin, or to
+// fallback once the plugin stopped reading it, for example because it
+// crashed, so that no event is lost.
+type pluginUiWriter struct {
+	path     string
+	w        io.Writer
+	fallback io.Writer
+
+	err error
+}
+
+func (w *pluginUiWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		n, err := w.w.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		w.err = err
+		fmt.Fprintf(w.fallback, "UI plugin %s stopped reading its events (%s), writing them to stderr.\n", w.path, err)
+	}
+	return w.fallback.Write(p)
+}
+
+func newPluginUi(path string, opts UiBackendOptions) (packersdk.Ui, error) {
+	cmd := exec.Command(path)
+	cmd.Stdout = opts.Writer
+	cmd.Stderr = opts.ErrorWriter
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start UI plugin %s: %s", path, err)
+	}
+	log.Printf("[INFO] Started UI plugin %s", path)
+	out := &pluginUiWriter{path: path, w: stdin, fallback: os.Stderr}
+	return &PluginUi{
+		JSONUi: &JSONUi{Writer: out},
+		cmd:    cmd,
+		stdin:  stdin,
+		out:    out,
+	}, nil
+}
+
+// Close ends the input of the plugin and waits for it to exit, for its
+// output to be complete. It fails when the plugin stopped reading its events
+// or did not exit successfully.
+func (u *PluginUi) Close() error {
+	closeErr := u.stdin.Close()
+	waitErr := u.cmd.Wait()
+	switch {
+	case u.out.err != nil:
+		return fmt.Errorf("UI plugin %s stopped reading its events: %s", u.out.path, u.out.err)
+	case waitErr != nil:
+		return fmt.Errorf("UI plugin %s failed: %s", u.out.path, waitErr)
+	}
+	return closeErr
+}
@@ -0,0 +1,158 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestNewUi(t *testing.T) {
+	for name, expected := range map[string]packersdk.Ui{
+		"plain":            &packersdk.BasicUi{},
+		"machine-readable": &MachineReadableUi{},
+		"json":             &JSONUi{},
+		"quiet":            &QuietUi{},
+	} {
+		ui, err := NewUi(name, UiBackendOptions{Writer: new(bytes.Buffer)})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if actual, expected := fmt.Sprintf("%T", ui), fmt.Sprintf("%T", expected); actual != expected {
+			t.Fatalf("%s: got a %s, expected a %s", name, actual, expected)
+		}
+	}
+
+	_, err := NewUi("unknown", UiBackendOptions{})
+	if err == nil || !strings.Contains(err.Error(), `unknown UI "unknown"`) {
+		t.Fatalf("expected an unknown UI error, got %v", err)
+	}
+}
+
+func TestJSONUi(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &JSONUi{Writer: buf}
+	if !IsStructuredUi(ui) {
+		t.Fatal("the json UI must be structured")
+	}
+
+	packersdk.LogSecretFilter.Set("json-ui-secret")
+	ui.Say("the json-ui-secret")
+	ui.Machine("foo,artifact", "0", "id", "a,b")
+
+	var events []UiEvent
+	dec := json.NewDecoder(buf)
+	for {
+		var event UiEvent
+		if err := dec.Decode(&event); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if event.Timestamp.IsZero() {
+			t.Fatalf("event without timestamp: %#v", event)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %#v", events)
+	}
+	if e := events[0]; e.Type != "say" || e.Message != "the <sensitive>" {
+		t.Fatalf("bad say event: %#v", e)
+	}
+	if e := events[1]; e.Type != "artifact" || e.Target != "foo" || strings.Join(e.Data, "|") != "0|id|a,b" {
+		t.Fatalf("bad machine event: %#v", e)
+	}
+}
+
+func TestQuietUi(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &QuietUi{ErrorWriter: buf}
+	ui.Say("said")
+	ui.Message("message")
+	ui.Machine("foo", "bar")
+	ui.Error("failed")
+	if buf.String() != "failed\n" {
+		t.Fatalf("bad: %q", buf.String())
+	}
+	if _, err := ui.Ask("Name"); err == nil {
+		t.Fatal("the quiet UI must not ask")
+	}
+}
+
+func TestPluginUi(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "packer-ui-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := "#!/bin/sh\nwhile read -r line; do echo \"plugin: $line\"; done\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, UiPluginPrefix+"test"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	ui, err := NewUi("test", UiBackendOptions{Writer: buf, ErrorWriter: buf, PluginFolders: []string{dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsStructuredUi(ui) {
+		t.Fatal("the UI of plugins must be structured")
+	}
+	ui.Say("hello")
+	if err := ui.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.HasPrefix(out, `plugin: {"@timestamp":`) || !strings.Contains(out, `"type":"say","message":"hello"}`) {
+		t.Fatalf("bad plugin output: %q", out)
+	}
+}
+
+func TestPluginUi_stoppedReading(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "packer-ui-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, UiPluginPrefix+"crash"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ui, err := NewUi("crash", UiBackendOptions{Writer: ioutil.Discard, ErrorWriter: ioutil.Discard, PluginFolders: []string{dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := new(bytes.Buffer)
+	ui.(*PluginUi).out.fallback = stderr
+
+	// the events written before the plugin exits are in the pipe
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(stderr.String(), "stopped reading its events") {
+		if time.Now().After(deadline) {
+			t.Fatal("the failure of the plugin was not reported")
+		}
+		ui.Say("hello")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(stderr.String(), `"type":"say","message":"hello"}`) {
+		t.Fatalf("the events must be written to stderr: %q", stderr.String())
+	}
+	if err := ui.(io.Closer).Close(); err == nil {
+		t.Fatal("closing the UI must report that the plugin stopped reading")
+	}
+}
@@ -2,10 +2,14 @@ package packer
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -51,7 +55,7 @@ func (tty *testTTY) ReadString() (string, error) {
 
 func TestColoredUi(t *testing.T) {
 	bufferUi := testUi()
-	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi, &UiProgressBar{}}
+	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi, &UiProgressBar{}, false}
 
 	if !ui.supportsColors() {
 		t.Skip("skipping for ui without color support")
@@ -83,7 +87,7 @@ func TestColoredUi(t *testing.T) {
 
 func TestColoredUi_noColorEnv(t *testing.T) {
 	bufferUi := testUi()
-	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi, &UiProgressBar{}}
+	ui := &ColoredUi{UiColorYellow, UiColorRed, bufferUi, &UiProgressBar{}, false}
 
 	// Set the env var to get rid of the color
 	oldenv := os.Getenv("PACKER_NO_COLOR")
@@ -249,6 +253,37 @@ func TestBasicUi_Ask(t *testing.T) {
 
 }
 
+func TestAnsweringUi_Ask(t *testing.T) {
+	bufferUi := testUi()
+	bufferUi.TTY = &testTTY{"asked\n"}
+	ui := &AnsweringUi{
+		Ui: &TargetedUI{
+			Target: "foo",
+			Ui:     bufferUi,
+		},
+		Answers: map[string]string{DebugPausePrompt: "answered"},
+	}
+
+	actual, err := ui.Ask("Pausing after run of step 'StepFoo'. " + DebugPausePrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "answered" {
+		t.Fatalf("bad answer: %#v", actual)
+	}
+	if prompt := readWriter(bufferUi); prompt != "" {
+		t.Fatalf("should not prompt: %#v", prompt)
+	}
+
+	actual, err = ui.Ask("Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "asked" {
+		t.Fatalf("bad answer: %#v", actual)
+	}
+}
+
 func TestMachineReadableUi_ImplUi(t *testing.T) {
 	var raw interface{}
 	raw = &MachineReadableUi{}
@@ -297,4 +332,127 @@ func TestMachineReadableUi(t *testing.T) {
 	if data != expected {
 		t.Fatalf("bad: %#v", data)
 	}
+
+	// Secrets
+	buf.Reset()
+	packersdk.LogSecretFilter.Set("machine-readable-secret")
+	ui.Machine("foo", "the machine-readable-secret")
+	data = strings.SplitN(buf.String(), ",", 2)[1]
+	expected = ",foo,the <sensitive>\n"
+	if data != expected {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+func TestHeartbeatUi(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &HeartbeatUi{
+		Ui:       &MachineReadableUi{Writer: buf},
+		Target:   "foo",
+		Interval: 20 * time.Millisecond,
+	}
+
+	ui.Start()
+	ui.Say("==> foo: Waiting for AMI to become ready...")
+	time.Sleep(100 * time.Millisecond)
+	ui.Stop()
+
+	if !strings.Contains(buf.String(), ",foo,heartbeat,Waiting for AMI to become ready...,") {
+		t.Fatalf("no heartbeat: %s", buf.String())
+	}
+
+	// No heartbeat is output after Stop
+	buf.Reset()
+	time.Sleep(50 * time.Millisecond)
+	if buf.Len() != 0 {
+		t.Fatalf("heartbeat after Stop: %s", buf.String())
+	}
+}
+
+func TestTargetedUI_events(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &TargetedUI{Target: "foo", Ui: &HeartbeatUi{Ui: &JSONUi{Writer: buf}, Target: "foo"}}
+	ui.Say("Creating keypair...\nline 2")
+	pp := &TargetedUI{Target: "foo (shell-local)", Ui: ui}
+	pp.Message("==> not a prefix")
+	EmitEvent(ui, UiEvent{Type: EventStep, Message: "write_target"})
+
+	var events []UiEvent
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event UiEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		event.Timestamp = time.Time{}
+		events = append(events, event)
+	}
+	expected := []UiEvent{
+		{Type: "say", Target: "foo", Message: "Creating keypair...\nline 2"},
+		{Type: "message", Target: "foo (shell-local)", Message: "==> not a prefix"},
+		{Type: EventStep, Target: "foo", Message: "write_target"},
+	}
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Fatalf("unexpected events: %s", diff)
+	}
+
+	// UIs not outputting events get the messages prefixed
+	bufferUi := testUi()
+	ui = &TargetedUI{Target: "foo", Ui: bufferUi}
+	if EmitEvent(ui, UiEvent{Type: EventStep, Message: "write_target"}) {
+		t.Fatal("a basic UI output an event")
+	}
+	ui.Say("bar")
+	if out := readWriter(bufferUi); out != "==> foo: bar\n" {
+		t.Fatalf("bad: %q", out)
+	}
+}
+
+func TestAlignedUi(t *testing.T) {
+	bufferUi := testUi()
+	ui := &TargetedUI{
+		Target: "foo",
+		Ui:     &AlignedUi{Ui: bufferUi, Target: "foo", Width: 6},
+	}
+
+	ui.Say("one\ntwo")
+	if actual, expected := readWriter(bufferUi), "==> foo   : one\n==> foo   : two\n"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+	ui.Message("output")
+	if actual, expected := readWriter(bufferUi), "    foo   : output\n"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// The messages of the post-processors of the build are aligned too
+	aligned := &AlignedUi{Ui: bufferUi, Target: "foo", Width: 20}
+	pp := &TargetedUI{Target: "foo (shell-local)", Ui: aligned}
+	pp.Say("running\n")
+	if actual, expected := readWriter(bufferUi), "==> foo (shell-local)   : running\n==> foo (shell-local)   :\n"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// The messages of other targets are left as is
+	other := &TargetedUI{Target: "foobar", Ui: aligned}
+	other.Say("running")
+	if actual, expected := readWriter(bufferUi), "==> foobar: running\n"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	lock := new(sync.Mutex)
+	a := &LineWriter{Writer: buf, Lock: lock}
+	b := &LineWriter{Writer: buf, Lock: lock}
+
+	a.Write([]byte("==> a: one"))
+	b.Write([]byte("==> b: two\n==> b: thr"))
+	a.Write([]byte(" line\n"))
+	b.Write([]byte("ee"))
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := buf.String(), "==> b: two\n==> a: one line\n==> b: three"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
 }
@@ -0,0 +1,131 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ColorThemeFile is the name of the file of the color theme, in the packer
+// config directory.
+const ColorThemeFile = "color_theme.json"
+
+// ColorMode tells when the output is colored.
+type ColorMode string
+
+const (
+	// ColorModeAuto colors the output when the terminal supports it.
+	ColorModeAuto ColorMode = "auto"
+	// ColorModeNo never colors the output.
+	ColorModeNo ColorMode = "no"
+	// ColorModeForce colors the output even when the terminal does not seem
+	// to support it.
+	ColorModeForce ColorMode = "force"
+)
+
+// uiColorNames are the names of the colors of a theme.
+var uiColorNames = map[string]UiColor{
+	"red":     UiColorRed,
+	"green":   UiColorGreen,
+	"yellow":  UiColorYellow,
+	"blue":    UiColorBlue,
+	"magenta": UiColorMagenta,
+	"cyan":    UiColorCyan,
+}
+
+// ColorTheme tells how the output of builds is colored.
+type ColorTheme struct {
+	Mode ColorMode `json:"mode"`
+	// Palette are the colors of the builds, given in the order of the builds
+	// so that builds following each other get different colors.
+	Palette []string `json:"palette"`
+	// Builds are the colors of some builds, by name, over the palette.
+	Builds map[string]string `json:"builds"`
+	// Error is the color of the errors.
+	Error string `json:"error"`
+}
+
+// DefaultColorTheme returns the theme used without a theme file.
+func DefaultColorTheme() *ColorTheme {
+	return &ColorTheme{
+		Mode:    ColorModeAuto,
+		Palette: []string{"green", "cyan", "magenta", "yellow", "blue"},
+		Error:   "red",
+	}
+}
+
+// LoadColorTheme reads the theme of filename over the default theme. A
+// missing file is not an error.
+func LoadColorTheme(filename string) (*ColorTheme, error) {
+	theme := DefaultColorTheme()
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return theme, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(theme); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	switch theme.Mode {
+	case "":
+		theme.Mode = ColorModeAuto
+	case ColorModeAuto, ColorModeNo, ColorModeForce:
+	default:
+		return nil, fmt.Errorf("%s: unknown mode %q, it is %q, %q or %q",
+			filename, theme.Mode, ColorModeAuto, ColorModeNo, ColorModeForce)
+	}
+	if len(theme.Palette) == 0 {
+		theme.Palette = DefaultColorTheme().Palette
+	}
+	colors := append([]string{theme.Error}, theme.Palette...)
+	for _, color := range theme.Builds {
+		colors = append(colors, color)
+	}
+	for _, color := range colors {
+		if _, ok := uiColorNames[color]; !ok && color != "" {
+			return nil, fmt.Errorf("%s: unknown color %q, it is one of %s",
+				filename, color, strings.Join(colorNames(), ", "))
+		}
+	}
+	return theme, nil
+}
+
+func colorNames() []string {
+	var names []string
+	for name := range uiColorNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildColors returns the colors of the builds names, in order: the one set
+// for a build, or the next color of the palette. The builds of a config
+// being always in the same order, a build keeps its color from one run to the
+// other.
+func (t *ColorTheme) BuildColors(names []string) []UiColor {
+	colors := make([]UiColor, len(names))
+	next := 0
+	for i, name := range names {
+		if color, ok := uiColorNames[t.Builds[name]]; ok {
+			colors[i] = color
+			continue
+		}
+		colors[i] = uiColorNames[t.Palette[next%len(t.Palette)]]
+		next++
+	}
+	return colors
+}
+
+// ErrorColor returns the color of the errors.
+func (t *ColorTheme) ErrorColor() UiColor {
+	if color, ok := uiColorNames[t.Error]; ok {
+		return color
+	}
+	return UiColorRed
+}
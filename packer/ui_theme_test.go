@@ -0,0 +1,67 @@
+package packer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadColorTheme(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-color-theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	theme, err := LoadColorTheme(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Mode != ColorModeAuto || theme.ErrorColor() != UiColorRed {
+		t.Fatalf("a missing theme must be the default one: %#v", theme)
+	}
+
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{`{"mode": "force", "palette": ["blue"], "builds": {"file.pinned": "magenta"}, "error": "yellow"}`, ""},
+		{`{"mode": "always"}`, `unknown mode "always"`},
+		{`{"palette": ["pink"]}`, `unknown color "pink"`},
+		{`{"mode":`, "unexpected EOF"},
+	}
+	for _, c := range cases {
+		filename := filepath.Join(dir, ColorThemeFile)
+		if err := ioutil.WriteFile(filename, []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadColorTheme(filename)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("%s: expected an error containing %q, got %v", c.content, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", c.content, err)
+		}
+	}
+
+}
+
+func TestColorTheme_BuildColors(t *testing.T) {
+	theme := &ColorTheme{
+		Palette: []string{"blue", "cyan"},
+		Builds:  map[string]string{"file.pinned": "magenta"},
+	}
+	colors := theme.BuildColors([]string{"file.a", "file.pinned", "file.b", "file.c"})
+	// the pinned build does not use up a color of the palette
+	expected := []UiColor{UiColorBlue, UiColorMagenta, UiColorCyan, UiColorBlue}
+	if diff := cmp.Diff(expected, colors); diff != "" {
+		t.Fatalf("unexpected colors: %s", diff)
+	}
+}
@@ -17,6 +17,9 @@ type Artifact struct {
 	ArtifactId    string            `json:"artifact_id"`
 	PackerRunUUID string            `json:"packer_run_uuid"`
 	CustomData    map[string]string `json:"custom_data"`
+	// Metadata is what the provisioners of the build attached to the
+	// artifact.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func (a *Artifact) BuilderId() string {
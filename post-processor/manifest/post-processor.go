@@ -109,6 +109,11 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, source
 	}
 	artifact.ArtifactId = source.Id()
 	artifact.CustomData = p.config.CustomData
+	// packer core hands the metadata attached by provisioners as a pointer
+	// to a map of strings.
+	if metadata, ok := source.State("artifact_metadata").(*map[string]string); ok && metadata != nil {
+		artifact.Metadata = *metadata
+	}
 	artifact.BuilderType = p.config.PackerBuilderType
 	artifact.BuildName = p.config.PackerBuildName
 	artifact.BuildTime = time.Now().Unix()
@@ -10,7 +10,7 @@ export default [
   'terminology',
   {
     category: 'commands',
-    content: ['init', 'build', 'console', 'fix', 'fmt', 'inspect', 'validate', 'hcl2_upgrade'],
+    content: ['init', 'build', 'console', 'convert', 'fix', 'fmt', 'inspect', 'lint', 'plan', 'validate', 'hcl2_upgrade'],
   },
   {
     category: 'templates',
@@ -37,6 +37,10 @@ export default [
                 content: [
                   'source',
                   'provisioner',
+                  'cleanup',
+                  'parallel',
+                  'replicate',
+                  'retry',
                   'post-processor',
                   'post-processors',
                 ],
@@ -45,7 +49,10 @@ export default [
               'source',
               'variable',
               'packer',
-              'data'
+              'data',
+              'function',
+              'image',
+              'post-processors',
             ],
           },
           {
@@ -141,6 +148,7 @@ export default [
                 content: [
                   'abspath',
                   'basename',
+                  'bootcommand',
                   'dirname',
                   'file',
                   'fileexists',
@@ -186,6 +194,7 @@ export default [
           'onlyexcept',
           'expressions',
           'syntax-json',
+          'syntax-yaml',
         ],
       },
     ],
@@ -331,4 +340,5 @@ export default [
   
   '---------',
   'debugging',
+  'errors',
 ]